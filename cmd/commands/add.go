@@ -0,0 +1,337 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/completion"
+	"github.com/sboehler/knut/lib/journal/printer"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/bayes"
+)
+
+// CreateAddCommand creates the command.
+func CreateAddCommand() *cobra.Command {
+	var r addRunner
+	c := &cobra.Command{
+		Use:   "add",
+		Short: "interactively add a transaction",
+		Long: `Prompt for the date, description and bookings of a transaction and append it
+to the given journal file. Accounts can be abbreviated: "ex:gro" matches
+Expenses:Groceries, and candidates are ranked by how often and how recently they
+were used. The debit account is suggested based on the existing transactions.`,
+		Args: cobra.ExactArgs(1),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+// tbdAccount is the placeholder for the suggested account. It is not created
+// in the registry, which would offer it as a completion.
+const tbdAccount = "Expenses:TBD"
+
+type addRunner struct {
+	candidates int
+}
+
+func (r *addRunner) setupFlags(c *cobra.Command) {
+	c.Flags().IntVar(&r.candidates, "candidates", 5, "maximum number of account candidates to show")
+}
+
+func (r *addRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	c := completion.New(reg)
+	if err := b.Build().ProcessWithOptions(flags.Processing(cmd), c.Record()); err != nil {
+		return err
+	}
+	m, err := inferRunner{}.train(cmd.Context(), args[0], tbdAccount)
+	if err != nil {
+		return err
+	}
+	pr := &prompter{
+		in:         bufio.NewScanner(cmd.InOrStdin()),
+		out:        cmd.OutOrStdout(),
+		registry:   reg,
+		completer:  c,
+		model:      m,
+		candidates: r.candidates,
+	}
+	t, err := pr.transaction()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	p := printer.New(&buf)
+	p.UpdatePadding(t)
+	if _, err := p.PrintDirective(t); err != nil {
+		return err
+	}
+	fmt.Fprintf(pr.out, "\n%s", buf.String())
+	ok, err := pr.ask(fmt.Sprintf("Append to %s?", args[0]), "y")
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(ok, "y") && !strings.EqualFold(ok, "yes") {
+		return nil
+	}
+	return appendText(args[0], buf.Bytes())
+}
+
+// appendText appends text to a file, separated from the existing content by
+// a blank line.
+func appendText(path string, text []byte) error {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch {
+	case len(existing) == 0:
+	case bytes.HasSuffix(existing, []byte("\n")):
+		text = append([]byte("\n"), text...)
+	default:
+		text = append([]byte("\n\n"), text...)
+	}
+	if _, err := f.Write(text); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+type prompter struct {
+	in         *bufio.Scanner
+	out        io.Writer
+	registry   *model.Registry
+	completer  *completion.Completer
+	model      *bayes.Model
+	candidates int
+}
+
+// ask prompts for a line of input. The default is returned if the input is
+// empty.
+func (pr *prompter) ask(prompt, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(pr.out, "%s [%s] ", prompt, def)
+	} else {
+		fmt.Fprintf(pr.out, "%s ", prompt)
+	}
+	if !pr.in.Scan() {
+		if err := pr.in.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("unexpected end of input")
+	}
+	if s := strings.TrimSpace(pr.in.Text()); s != "" {
+		return s, nil
+	}
+	return def, nil
+}
+
+func (pr *prompter) transaction() (*model.Transaction, error) {
+	d, err := pr.date()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := pr.ask("Description:", "")
+	if err != nil {
+		return nil, err
+	}
+	var (
+		postings  posting.Builders
+		commodity *model.Commodity
+		credit    *model.Account
+	)
+	for {
+		prompt := "Amount (empty to finish):"
+		if len(postings) == 0 {
+			prompt = "Amount:"
+		}
+		qty, c, err := pr.amount(prompt, commodity)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			if len(postings) == 0 {
+				continue
+			}
+			break
+		}
+		commodity = c
+		var def string
+		if credit != nil {
+			def = credit.Name()
+		}
+		if credit, err = pr.account("Credit account:", def); err != nil {
+			return nil, err
+		}
+		debit, err := pr.account("Debit account:", pr.suggest(desc, qty, c, credit))
+		if err != nil {
+			return nil, err
+		}
+		postings = append(postings, posting.Builder{
+			Credit:    credit,
+			Debit:     debit,
+			Commodity: c,
+			Quantity:  qty,
+		})
+	}
+	return transaction.Builder{
+		Date:        d,
+		Description: desc,
+		Postings:    postings.Build(),
+	}.Build(), nil
+}
+
+func (pr *prompter) date() (time.Time, error) {
+	for {
+		s, err := pr.ask("Date:", date.Today().Format("2006-01-02"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		d, err := time.Parse("2006-01-02", s)
+		if err == nil {
+			return d, nil
+		}
+		fmt.Fprintf(pr.out, "invalid date %q, want YYYY-MM-DD\n", s)
+	}
+}
+
+// amount prompts for a quantity and a commodity, which defaults to the given
+// commodity. It returns a nil commodity if the input is empty.
+func (pr *prompter) amount(prompt string, def *model.Commodity) (decimal.Decimal, *model.Commodity, error) {
+	for {
+		s, err := pr.ask(prompt, "")
+		if err != nil {
+			return decimal.Zero, nil, err
+		}
+		if s == "" {
+			return decimal.Zero, nil, nil
+		}
+		fields := strings.Fields(s)
+		qty, err := decimal.NewFromString(fields[0])
+		if err != nil || len(fields) > 2 {
+			fmt.Fprintf(pr.out, "invalid amount %q, want <quantity> [<commodity>]\n", s)
+			continue
+		}
+		if len(fields) == 1 {
+			if def == nil {
+				fmt.Fprintln(pr.out, "missing commodity")
+				continue
+			}
+			return qty, def, nil
+		}
+		c, err := pr.registry.Commodities().Get(fields[1])
+		if err != nil {
+			fmt.Fprintln(pr.out, err)
+			continue
+		}
+		return qty, c, nil
+	}
+}
+
+// account prompts for an account. The input may be an abbreviation, which is
+// resolved by the completer. If there are several candidates, the user
+// chooses one of them.
+func (pr *prompter) account(prompt, def string) (*model.Account, error) {
+	for {
+		s, err := pr.ask(prompt, def)
+		if err != nil {
+			return nil, err
+		}
+		if s == "" {
+			continue
+		}
+		candidates := pr.completer.Complete(s, 0)
+		for _, a := range candidates {
+			if a.Name() == s {
+				return a, nil
+			}
+		}
+		if len(candidates) > pr.candidates {
+			candidates = candidates[:pr.candidates]
+		}
+		switch len(candidates) {
+		case 0:
+			a, err := pr.registry.Accounts().Get(s)
+			if err != nil {
+				fmt.Fprintln(pr.out, err)
+				continue
+			}
+			fmt.Fprintf(pr.out, "new account %s\n", a.Name())
+			return a, nil
+		case 1:
+			return candidates[0], nil
+		}
+		for i, a := range candidates {
+			fmt.Fprintf(pr.out, "  %d) %s\n", i+1, a.Name())
+		}
+		choice, err := pr.ask("Choose:", "1")
+		if err != nil {
+			return nil, err
+		}
+		if i, err := strconv.Atoi(choice); err == nil && i >= 1 && i <= len(candidates) {
+			return candidates[i-1], nil
+		}
+	}
+}
+
+// suggest infers the debit account of a booking from the existing
+// transactions.
+func (pr *prompter) suggest(desc string, qty decimal.Decimal, c *model.Commodity, credit *model.Account) string {
+	t := syntax.Transaction{
+		Description: syntax.QuotedString{Content: textRange(syntax.Escape(desc))},
+		Bookings: []syntax.Booking{
+			{
+				Credit:    syntax.Account{Range: textRange(credit.Name())},
+				Debit:     syntax.Account{Range: textRange(tbdAccount)},
+				Quantity:  syntax.Decimal{Range: textRange(qty.String())},
+				Commodity: syntax.Commodity{Range: textRange(c.Name())},
+			},
+		},
+	}
+	pr.model.Infer(&t)
+	return t.Bookings[0].Debit.Extract()
+}
+
+func textRange(s string) syntax.Range {
+	return syntax.Range{End: len(s), Text: s}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestAdd(t *testing.T) {
+	journal := strings.Join([]string{
+		"2023-01-01 open Assets:Checking",
+		"2023-01-01 open Expenses:Groceries",
+		"2023-01-01 open Expenses:Garden",
+		"",
+		`2023-02-01 "Migros food"`,
+		"Assets:Checking Expenses:Groceries 20 CHF",
+		"",
+		`2023-02-02 "Garden center plants"`,
+		"Assets:Checking Expenses:Garden 30 CHF",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "journal.knut")
+	if err := os.WriteFile(path, []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		"2023-03-01",
+		"Migros weekly",
+		"12.50 CHF",
+		"as:ch",
+		"", // accept the suggested debit account
+		"3",
+		"as:ch",
+		"ex:g",
+		"1", // the most recently used candidate
+		"",
+		"y",
+	}, "\n")
+	cmd := CreateAddCommand()
+	cmd.SetIn(strings.NewReader(input))
+
+	cmdtest.Run(t, cmd, path)
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := journal + "\n\n" + strings.Join([]string{
+		`2023-03-01 "Migros weekly"`,
+		"Assets:Checking    Expenses:Groceries       12.5 CHF",
+		"Assets:Checking    Expenses:Garden             3 CHF",
+		"",
+	}, "\n")
+	if string(got) != want {
+		t.Errorf("journal is\n%s\nwant\n%s", got, want)
+	}
+}
@@ -15,17 +15,22 @@
 package commands
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
 	"runtime/pprof"
+	"strings"
 
 	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/common/table"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/journal/check"
@@ -34,7 +39,9 @@ import (
 	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/registry"
 	"github.com/sboehler/knut/lib/reports/balance"
+	"github.com/sboehler/knut/lib/reports/query"
 
+	"github.com/natefinch/atomic"
 	"github.com/spf13/cobra"
 )
 
@@ -47,9 +54,21 @@ func CreateBalanceCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "balance",
 		Short: "create a balance sheet",
-		Long:  `Compute a balance for a date or set of dates.`,
-		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-		Run:   r.run,
+		Long: `Compute a balance for a date or set of dates.
+
+With --consolidate, the balances of several independent journals are consolidated,
+with a column for each journal next to the consolidated column of each period.
+
+With --cost, the historical cost of the asset and liability accounts is shown in a
+column next to their market value in each period, such that the difference is the
+unrealized gain. Reductions of a position are valued at its average cost.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if r.consolidate {
+				return cobra.MinimumNArgs(2)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: r.run,
 	}
 	r.setupFlags(c)
 	return c
@@ -62,61 +81,110 @@ type balanceRunner struct {
 	cpuprofile string
 
 	// journal structure
-	close     bool
-	valuation flags.CommodityFlag
+	close       bool
+	virtual     bool
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+
+	// consolidation
+	consolidate bool
+	cost        bool
+	eliminate   flags.RegexFlag
 
 	// mapping
 	mapping flags.MappingFlag
+	mapFile flags.MapFileFlag
 	remap   flags.RegexFlag
+	depth   int
 
 	// filters
 	accounts    flags.RegexFlag
 	commodities flags.RegexFlag
+	groups      flags.RegexFlag
+
+	// commodity groups
+	groupsFile string
+	groupBy    string
+	byGroup    bool
 
 	// report structure
 	diff               bool
+	compare            string
+	notes              bool
 	showCommodities    flags.RegexFlag
 	sortAlphabetically bool
+	subtotals          bool
+	netTotals          bool
+	invertSigns        bool
+	invert             flags.AccountTypesFlag
 
 	// formatting
 	thousands bool
-	color     bool
+	colors    flags.Colors
 	digits    int32
+	rounding  flags.Rounding
 	csv       bool
+	format    string
+	output    string
+	maxWidth  int
+	page      int
+	drillDown string
 }
 
-func (r *balanceRunner) run(cmd *cobra.Command, args []string) {
+func (r *balanceRunner) run(cmd *cobra.Command, args []string) error {
 	if r.cpuprofile != "" {
-		f, err := os.Create(r.cpuprofile)
+		f, err := os.Create(env.Path(cmd.Context(), r.cpuprofile))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
-
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintf(cmd.ErrOrStderr(), "%+v\n", err)
-		os.Exit(1)
-	}
+	return r.execute(cmd, args)
 }
 
 func (r *balanceRunner) setupFlags(c *cobra.Command) {
 	r.Multiperiod.Setup(c)
 	c.Flags().StringVar(&r.cpuprofile, "cpuprofile", "", "file to write profile")
 	c.Flags().BoolVarP(&r.diff, "diff", "d", false, "diff")
-	c.Flags().BoolVarP(&r.csv, "csv", "", false, "csv")
+	c.Flags().StringVar(&r.compare, "compare", "", "compare each period to a baseline (previous-year)")
+	c.Flags().BoolVar(&r.notes, "notes", false, "add account notes as footnotes (html only)")
+	c.Flags().BoolVarP(&r.csv, "csv", "", false, "csv (same as --format csv)")
+	c.Flags().StringVar(&r.format, "format", "text", "output format (text, csv or html)")
+	c.Flags().StringVarP(&r.output, "output", "o", "", "write the report to the given file")
+	c.Flags().StringVar(&r.drillDown, "drill-down", "", "link account cells to the register query at the given URL (html only)")
+	c.Flags().BoolVar(&r.consolidate, "consolidate", false, "consolidate the journals given as arguments, with a column per journal")
+	c.Flags().Var(&r.eliminate, "eliminate", "with --consolidate, eliminate the inter-entity accounts matching the regex")
+	c.Flags().BoolVar(&r.cost, "cost", false, "add a column with the historical cost of asset and liability accounts (requires --val)")
+	c.MarkFlagsMutuallyExclusive("cost", "consolidate")
 	c.Flags().BoolVar(&r.close, "close", true, "close")
+	c.Flags().BoolVar(&r.virtual, "virtual", false, "include virtual postings")
 	c.Flags().BoolVarP(&r.sortAlphabetically, "sort", "a", false, "Sort accounts alphabetically")
+	c.Flags().BoolVar(&r.subtotals, "subtotals", false, "add a total row for each account type")
+	c.Flags().BoolVar(&r.netTotals, "net", false, "add the net worth (assets and liabilities) and the net income (income and expenses)")
+	c.Flags().BoolVar(&r.invertSigns, "invert-signs", false, "show liabilities, equity and income with inverted sign (same as --invert liabilities,equity,income)")
+	c.Flags().Var(&r.invert, "invert", "show the accounts of the given types with inverted sign, e.g. income,liabilities,equity")
+	c.MarkFlagsMutuallyExclusive("invert-signs", "invert")
 	c.Flags().VarP(&r.showCommodities, "show-commodities", "s", "<regex>")
 	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
 	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
+	r.mapFile.Setup(c)
 	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
+	c.Flags().IntVar(&r.depth, "depth", 0, "collapse accounts below the given depth into their parent")
 	c.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
 	c.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	c.Flags().StringVar(&r.groupsFile, "commodity-groups", "", "YAML file assigning commodities to groups")
+	c.Flags().Var(&r.groups, "group", "filter commodity groups with a regex")
+	c.Flags().StringVar(&r.groupBy, "group-by", "", "aggregate commodities by the value of a metadata key, such as asset-class (requires --val)")
+	c.Flags().BoolVar(&r.byGroup, "by-group", false, "aggregate commodities by group (requires --val)")
+	c.MarkFlagsMutuallyExclusive("commodity-groups", "group-by")
 	c.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(c)
 	c.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
-	c.Flags().BoolVar(&r.color, "color", true, "print output in color")
+	r.colors.Setup(c)
+	c.Flags().IntVar(&r.maxWidth, "max-width", 0, "maximum width of text output, split wider reports into pages (default: terminal width)")
+	c.Flags().IntVar(&r.page, "page", 0, "print only the given page of a report split by --max-width")
 }
 
 func (r balanceRunner) execute(cmd *cobra.Command, args []string) error {
@@ -125,58 +193,259 @@ func (r balanceRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	j, err := journal.FromPath(cmd.Context(), reg, args[0])
+	roundingMode, valuationRounding, err := r.rounding.Value()
 	if err != nil {
 		return err
 	}
-	partition := r.Multiperiod.Partition(j.Period())
-	report := balance.NewReport(reg, partition)
-	procs := []*journal.Processor{
-		check.Check(),
-		journal.ComputePrices(valuation),
-		journal.Valuate(reg, valuation),
-		journal.Filter(partition),
-		journal.CloseAccounts(j, reg, r.close, partition),
-		journal.Query{
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	if r.compare != "" && r.compare != "previous-year" {
+		return fmt.Errorf("invalid comparison %q, want previous-year", r.compare)
+	}
+	if len(r.eliminate.Regex()) > 0 && !r.consolidate {
+		return fmt.Errorf("--eliminate requires --consolidate")
+	}
+	if r.consolidate && r.compare != "" {
+		return fmt.Errorf("--compare is not supported with --consolidate")
+	}
+	if r.cost && valuation == nil {
+		return fmt.Errorf("--cost requires --val")
+	}
+	// Amounts of different commodities of a group can only be added up
+	// once they have been valuated.
+	if (r.byGroup || r.groupBy != "") && valuation == nil {
+		return fmt.Errorf("--by-group and --group-by require --val")
+	}
+	var (
+		journals []*journal.Builder
+		period   date.Period
+	)
+	for _, path := range args {
+		// The journals share the registry, such that accounts and
+		// commodities with the same name are consolidated.
+		j, err := journal.FromPathUntil(cmd.Context(), reg, path, r.Multiperiod.Until(pricePolicy))
+		if err != nil {
+			return err
+		}
+		journals = append(journals, j)
+		period = period.Union(j.Period())
+	}
+	// Targets may have account types declared in the journal.
+	substitute, err := r.mapFile.Value(cmd.Context(), reg)
+	if err != nil {
+		return err
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	invert, err := r.invert.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	// Groups by metadata depend on the commodity declarations of the
+	// journal, so they are loaded after it.
+	groups, err := r.loadGroups(cmd.Context(), reg)
+	if err != nil {
+		return err
+	}
+	commodityMapper := mapper.Identity[*model.Commodity]
+	if r.byGroup || r.groupBy != "" {
+		commodityMapper = groups.Map()
+	}
+	partition := r.Multiperiod.Partition(period)
+	collapsed := set.New[*model.Account]()
+	// process processes a journal into the report. If entity is not nil,
+	// the amounts are also inserted into it. If elims is not nil, the
+	// amounts of the eliminated accounts are left out of the report and
+	// are inserted negated into elims instead. If costs is not nil, the
+	// historical costs are inserted into it.
+	process := func(j *journal.Builder, partition date.Partition, report, entity, elims, costs *balance.Report) error {
+		accountMapper := mapper.Sequence(
+			account.Remap(reg.Accounts(), r.remap.Regex()),
+			substitute,
+			account.Shorten(reg.Accounts(), mapping),
+			account.Collapse(reg.Accounts(), r.depth, collapsed),
+		)
+		var notes *journal.Processor
+		if r.notes {
+			notes = journal.Notes(partition, accountMapper, report.AddNote)
+		}
+		query := journal.Query{
 			Select: amounts.KeyMapper{
-				Date: partition.Align(),
-				Account: mapper.Sequence(
-					account.Remap(reg.Accounts(), r.remap.Regex()),
-					account.Shorten(reg.Accounts(), r.mapping.Value()),
-				),
-				Commodity: mapper.Identity[*model.Commodity],
+				Date:      partition.Align(),
+				Account:   accountMapper,
+				Commodity: commodityMapper,
 				Valuation: commodity.IdentityIf(valuation != nil),
 			}.Build(),
 			Where: predicate.And(
+				predicate.Not(amounts.AccountIsOffBalance),
 				amounts.AccountMatches(r.accounts.Regex()),
 				amounts.CommodityMatches(r.commodities.Regex()),
+				amounts.CommoditySatisfies(groups.Matches(r.groups.Regex())),
 			),
 			Valuation: valuation,
-		}.Into(report),
+			Virtual:   r.virtual,
+		}
+		reportQuery := query
+		var entityProc, elimsProc, costsProc *journal.Processor
+		if entity != nil {
+			entityProc = query.Into(entity)
+		}
+		if costs != nil {
+			// Costs are computed before the journal is filtered, as they
+			// depend on the entire history of a position.
+			costQuery := query
+			costQuery.Where = predicate.And(query.Where, amounts.FilterDates(partition.Contains))
+			costsProc = costQuery.IntoCost(costs)
+		}
+		if elims != nil {
+			eliminated := amounts.AccountMatches(r.eliminate.Regex())
+			reportQuery.Where = predicate.And(query.Where, predicate.Not(eliminated))
+			elimQuery := query
+			elimQuery.Where = predicate.And(query.Where, eliminated)
+			elimsProc = elimQuery.Into(journal.Negate(elims))
+		}
+		// The processors are created before the journal is built, as
+		// CloseAccounts adds the days on which accounts are closed.
+		procs := []*journal.Processor{
+			journal.ApplyValues(reg),
+			check.Check(),
+			journal.ComputePricesWithPolicy(j, valuation, pricePolicy),
+			journal.ValuateWithRounding(reg, valuation, valuationRounding),
+			costsProc,
+			journal.Filter(partition),
+			journal.CloseAccounts(j, reg, r.close, partition),
+			reportQuery.Into(report),
+			entityProc,
+			elimsProc,
+			notes,
+		}
+		return j.Build().ProcessWithOptions(flags.Processing(cmd), procs...)
 	}
-	err = j.Build().Process(procs...)
-	if err != nil {
-		return err
+	report := balance.NewReport(reg, partition)
+	var entities []balance.Entity
+	if !r.consolidate {
+		var costs *balance.Report
+		if r.cost {
+			costs = balance.NewReport(reg, partition)
+		}
+		if err := process(journals[0], partition, report, nil, nil, costs); err != nil {
+			return err
+		}
+		if costs != nil {
+			entities = append(entities, balance.Entity{Name: "Cost", Report: costs})
+		}
+	} else {
+		var elims *balance.Report
+		if len(r.eliminate.Regex()) > 0 {
+			elims = balance.NewReport(reg, partition)
+		}
+		for i, j := range journals {
+			entity := balance.NewReport(reg, partition)
+			if err := process(j, partition, report, entity, elims, nil); err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(filepath.Base(args[i]), filepath.Ext(args[i]))
+			entities = append(entities, balance.Entity{Name: name, Report: entity})
+		}
+		if elims != nil {
+			entities = append(entities, balance.Entity{Name: "Elim.", Report: elims})
+		}
+	}
+	var baseline *balance.Report
+	if r.compare == "previous-year" {
+		// Processing consumes the journal, so the baseline is computed
+		// from a fresh copy.
+		j, err := journal.FromPathUntil(cmd.Context(), reg, args[0], r.Multiperiod.Until(pricePolicy))
+		if err != nil {
+			return err
+		}
+		baseline = balance.NewReport(reg, partition.PreviousYear())
+		if err := process(j, partition.PreviousYear(), baseline, nil, nil, nil); err != nil {
+			return err
+		}
 	}
 	reportRenderer := balance.Renderer{
 		Valuation:          valuation,
 		CommodityDetails:   r.showCommodities.Regex(),
 		SortAlphabetically: r.sortAlphabetically,
 		Diff:               r.diff,
+		Collapsed:          collapsed,
+		Baseline:           baseline,
+		Entities:           entities,
+		Notes:              r.notes,
+		Subtotals:          r.subtotals,
+		NetTotals:          r.netTotals,
+		Invert:             invert,
+	}
+	if r.invertSigns {
+		reportRenderer.Invert = set.Of(account.LIABILITIES, account.EQUITY, account.INCOME)
+	}
+	if r.drillDown != "" {
+		reportRenderer.Links = func(q query.Params) string {
+			return r.drillDown + "?" + q.Encode()
+		}
 	}
-	var tableRenderer Renderer
 	if r.csv {
+		r.format = "csv"
+	}
+	var tableRenderer Renderer
+	switch r.format {
+	case "csv":
 		tableRenderer = &table.CSVRenderer{}
-	} else {
+	case "html":
+		tableRenderer = &table.HTMLRenderer{
+			Title:     "Balance",
+			Thousands: r.thousands,
+			Round:     r.digits,
+			Rounding:  roundingMode,
+		}
+	case "text":
+		color, theme, err := r.colors.Value(cmd)
+		if err != nil {
+			return err
+		}
+		maxWidth := r.maxWidth
+		if maxWidth == 0 && r.output == "" {
+			maxWidth = env.Terminal(cmd.Context()).Width
+		}
 		tableRenderer = &table.TextRenderer{
-			Color:     r.color,
+			Color:     color && r.output == "",
+			Theme:     theme,
 			Thousands: r.thousands,
 			Round:     r.digits,
+			Rounding:  roundingMode,
+			MaxWidth:  maxWidth,
+			Page:      r.page,
+		}
+	default:
+		return fmt.Errorf("invalid format %q, want text, csv or html", r.format)
+	}
+	var buf bytes.Buffer
+	if err := tableRenderer.Render(reportRenderer.Render(report), &buf); err != nil {
+		return err
+	}
+	if r.output != "" {
+		return atomic.WriteFile(env.Path(cmd.Context(), r.output), &buf)
+	}
+	_, err = buf.WriteTo(cmd.OutOrStdout())
+	return err
+}
+
+func (r balanceRunner) loadGroups(ctx context.Context, reg *model.Registry) (commodity.Groups, error) {
+	if r.groupBy != "" {
+		return commodity.GroupsByMetadata(reg.Commodities(), r.groupBy)
+	}
+	if r.groupsFile == "" {
+		if r.byGroup || len(r.groups.Regex()) > 0 {
+			return nil, fmt.Errorf("--by-group and --group require --commodity-groups or --group-by")
 		}
+		return nil, nil
 	}
-	out := bufio.NewWriter(cmd.OutOrStdout())
-	defer out.Flush()
-	return tableRenderer.Render(reportRenderer.Render(report), out)
+	return commodity.LoadGroupsFromFile(reg.Commodities(), env.Path(ctx, r.groupsFile))
 }
 
 type Renderer interface {
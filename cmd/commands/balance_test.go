@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestBalanceGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "by-group",
+			args: []string{"--commodity-groups", "testdata/balance/groups.yaml", "--by-group", "--val", "CHF", "--show-commodities", "."},
+		},
+		{
+			name: "group",
+			args: []string{"--commodity-groups", "testdata/balance/groups.yaml", "--group", "Cash"},
+		},
+		{
+			name: "depth",
+			args: []string{"--depth", "2"},
+		},
+		{
+			name: "subtotals",
+			args: []string{"--subtotals"},
+		},
+		{
+			name: "net",
+			args: []string{"--net"},
+		},
+		{
+			name: "invert-signs",
+			args: []string{"--invert-signs", "--subtotals"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := append([]string{"--digits", "1", "--sort", "testdata/balance/example.knut"}, test.args...)
+
+			got := cmdtest.Run(t, CreateBalanceCommand(), args...)
+
+			goldie.New(t, goldie.WithFixtureDir("testdata/balance")).Assert(t, test.name, got)
+		})
+	}
+}
+
+// TestBalanceGroupsRequireValuation checks that commodities are only
+// aggregated by group once they have been valuated.
+func TestBalanceGroupsRequireValuation(t *testing.T) {
+	for _, args := range [][]string{
+		{"--commodity-groups", "testdata/balance/groups.yaml", "--by-group"},
+		{"--group-by", "asset-class"},
+	} {
+		c := CreateBalanceCommand()
+		c.SetArgs(append(args, "testdata/balance/example.knut"))
+		c.SetOut(&bytes.Buffer{})
+		c.SetErr(&bytes.Buffer{})
+
+		err := c.Execute()
+
+		if err == nil || !strings.Contains(err.Error(), "require --val") {
+			t.Errorf("Execute() with %v returned %v, want an error requiring --val", args, err)
+		}
+	}
+}
+
+// TestBalanceMultiperiodGolden checks that income and expenses are closed
+// into equity at the start of each period.
+func TestBalanceMultiperiodGolden(t *testing.T) {
+	got := cmdtest.Run(t, CreateBalanceCommand(), "--months", "--from", "2024-01-01", "--to", "2024-03-31", "testdata/balance/months.knut")
+
+	goldie.New(t, goldie.WithFixtureDir("testdata/balance")).Assert(t, "months", got)
+}
+
+func TestBalanceAccountTypeFlags(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	text := `accounttype Project al
+
+2024-01-01 open Equity:Equity
+2024-01-01 open Project:Acme
+
+2024-01-02 "Hours"
+Equity:Equity Project:Acme 5 h
+`
+	if err := os.WriteFile(journal, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc    string
+		args    []string
+		wantErr bool
+	}{
+		{desc: "standard type", args: []string{"--map", "Expenses=1"}},
+		{desc: "declared type", args: []string{"--map", "Project=1", "--invert", "Project"}},
+		{desc: "unknown type in mapping", args: []string{"--map", "Expnses=1"}, wantErr: true},
+		{desc: "unknown type to invert", args: []string{"--invert", "Expnses"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := CreateBalanceCommand()
+			c.SetArgs(append(test.args, journal))
+			c.SetOut(&bytes.Buffer{})
+			c.SetErr(&bytes.Buffer{})
+
+			err := c.Execute()
+
+			if test.wantErr != (err != nil) {
+				t.Errorf("Execute() returned %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/natefinch/atomic"
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/chart"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateChartCommand creates the command.
+func CreateChartCommand() *cobra.Command {
+
+	var r chartRunner
+
+	c := &cobra.Command{
+		Use:   "chart",
+		Short: "chart balances over time",
+		Long: `Chart the balances of accounts at the end of each period, valuated in the given
+commodity. By default, asset and liability accounts are charted. Use --map or --remap to
+group accounts, and --account to chart other accounts.
+
+Area charts stack positive balances above and negative balances below zero, and show their
+total as a line. The format is determined by the extension of the output file (.svg or
+.png), or by --format.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type chartRunner struct {
+	flags.Multiperiod
+
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+	rounding    flags.Rounding
+
+	// mapping
+	mapping flags.MappingFlag
+	remap   flags.RegexFlag
+
+	// filters
+	accounts    flags.RegexFlag
+	commodities flags.RegexFlag
+
+	// formatting
+	kind   string
+	title  string
+	width  int
+	height int
+	format string
+	output string
+}
+
+func (r *chartRunner) setupFlags(c *cobra.Command) {
+	r.Multiperiod.Setup(c)
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	c.MarkFlagRequired("val")
+	r.pricePolicy.Setup(c)
+	r.rounding.Setup(c)
+	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
+	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
+	c.Flags().Var(&r.accounts, "account", "chart the accounts matching the regex instead of asset and liability accounts")
+	c.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	c.Flags().StringVar(&r.kind, "type", "area", "chart type (area or line)")
+	c.Flags().StringVar(&r.title, "title", "", "title of the chart")
+	c.Flags().IntVar(&r.width, "width", 800, "width of the chart in pixels")
+	c.Flags().IntVar(&r.height, "height", 500, "height of the chart in pixels")
+	c.Flags().StringVar(&r.format, "format", "", "output format (svg or png, default: from the extension of the output file, or svg)")
+	c.Flags().StringVarP(&r.output, "output", "o", "", "write the chart to the given file")
+}
+
+func (r *chartRunner) execute(cmd *cobra.Command, args []string) error {
+	kind, err := chart.ParseKind(r.kind)
+	if err != nil {
+		return err
+	}
+	format := r.format
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(r.output), ".")
+	}
+	if format == "" {
+		format = "svg"
+	}
+	if format != "svg" && format != "png" {
+		return fmt.Errorf("invalid format %q, want svg or png", format)
+	}
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	_, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	j, err := journal.FromPathUntil(cmd.Context(), reg, args[0], r.Multiperiod.Until(pricePolicy))
+	if err != nil {
+		return err
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	partition := r.Multiperiod.Partition(j.Period())
+	accounts := predicate.Or(amounts.AccountTypeIs(account.ASSETS), amounts.AccountTypeIs(account.LIABILITIES))
+	if len(r.accounts.Regex()) > 0 {
+		accounts = amounts.AccountMatches(r.accounts.Regex())
+	}
+	rep := chart.NewReport(partition)
+	// The processors are created before the journal is built, as
+	// CloseAccounts adds the days on which accounts are closed.
+	procs := []*journal.Processor{
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ComputePricesWithPolicy(j, valuation, pricePolicy),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
+		journal.Filter(partition),
+		journal.CloseAccounts(j, reg, true, partition),
+		journal.Query{
+			Select: amounts.KeyMapper{
+				Date: partition.Align(),
+				Account: mapper.Sequence(
+					account.Remap(reg.Accounts(), r.remap.Regex()),
+					account.Shorten(reg.Accounts(), mapping),
+				),
+			}.Build(),
+			Where: predicate.And(
+				predicate.Not(amounts.AccountIsOffBalance),
+				accounts,
+				amounts.CommodityMatches(r.commodities.Regex()),
+			),
+			Valuation: valuation,
+		}.Into(rep),
+	}
+	err = j.Build().ProcessWithOptions(flags.Processing(cmd), procs...)
+	if err != nil {
+		return err
+	}
+	rn := chart.Renderer{
+		Kind:   kind,
+		Title:  r.title,
+		Width:  r.width,
+		Height: r.height,
+	}
+	var buf bytes.Buffer
+	if format == "png" {
+		err = rn.PNG(&buf, rep.Chart())
+	} else {
+		err = rn.SVG(&buf, rep.Chart())
+	}
+	if err != nil {
+		return err
+	}
+	if r.output != "" {
+		return atomic.WriteFile(env.Path(cmd.Context(), r.output), &buf)
+	}
+	_, err = buf.WriteTo(cmd.OutOrStdout())
+	return err
+}
@@ -0,0 +1,17 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+// TestChartExpensesGolden checks that expenses are charted per period, as
+// income and expenses are closed into equity at the start of each period.
+func TestChartExpensesGolden(t *testing.T) {
+	got := cmdtest.Run(t, CreateChartCommand(), "--val", "CHF", "--months", "--from", "2024-01-01", "--to", "2024-03-31", "--account", "Expenses", "--type", "line", "testdata/balance/months.knut")
+
+	goldie.New(t, goldie.WithFixtureDir("testdata/chart")).Assert(t, "expenses", got)
+}
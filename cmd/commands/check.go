@@ -17,8 +17,9 @@ package commands
 import (
 	"bufio"
 	"fmt"
-	"os"
+	"io"
 
+	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/journal/check"
 	"github.com/sboehler/knut/lib/model"
@@ -36,9 +37,17 @@ func CreateCheckCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "check",
 		Short: "check the journal",
-		Long:  `Check the journal.`,
-		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-		Run:   r.run,
+		Long: `Check the journal.
+
+Checks can be disabled for a single directive by preceding it with a comment of the form
+'; knut:disable <rule>[,<rule>...]'. Available rules are already-open, not-open, assertion and
+nonzero-close. Suppressions which are not used are reported.
+
+With --fix-account, a failing balance assertion is reported together with a transaction
+which books the difference to the given account. It can be pasted into the journal
+before the assertion.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
 	}
 	r.setupFlags(c)
 	return c
@@ -47,19 +56,15 @@ func CreateCheckCommand() *cobra.Command {
 type checkRunner struct {
 	write   bool
 	noCheck bool
-}
-
-func (r *checkRunner) run(cmd *cobra.Command, args []string) {
-
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintf(cmd.ErrOrStderr(), "%s\n", err.Error())
-		os.Exit(1)
-	}
+	booking flags.BookingFlag
+	fix     flags.AccountFlag
 }
 
 func (r *checkRunner) setupFlags(c *cobra.Command) {
 	c.Flags().BoolVar(&r.write, "write", false, "create a complete set of assertions")
 	c.Flags().BoolVar(&r.noCheck, "no-check", false, "do not check assertions")
+	c.Flags().Var(&r.booking, "booking", "booking method for lot assertions")
+	c.Flags().Var(&r.fix, "fix-account", "suggest a transaction booking the difference of a failing assertion to the given account")
 }
 
 func (r *checkRunner) execute(cmd *cobra.Command, args []string) error {
@@ -69,27 +74,35 @@ func (r *checkRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	slack, err := r.fix.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
 	checker := check.Checker{
 		Write:   r.write,
 		NoCheck: r.noCheck,
+		Booking: r.booking.Value(),
+		Slack:   slack,
 	}
 
-	err = j.Build().Process(
+	err = j.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.ApplyValues(reg),
 		checker.Check(),
 	)
 	if err != nil {
 		return err
 	}
+	for _, s := range checker.Unused() {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: unused suppression of rule %q\n", s.Range.Position(), s.Rule)
+	}
 	if r.write {
-		out := bufio.NewWriter(os.Stdout)
-		defer out.Flush()
-		return r.writeFile(checker.Assertions())
+		return r.writeFile(cmd.OutOrStdout(), checker.Assertions())
 	}
 	return nil
 }
 
-func (r *checkRunner) writeFile(assertions []*model.Assertion) error {
-	out := bufio.NewWriter(os.Stdout)
+func (r *checkRunner) writeFile(w io.Writer, assertions []*model.Assertion) error {
+	out := bufio.NewWriter(w)
 	defer out.Flush()
 	j := journal.New()
 	for _, a := range assertions {
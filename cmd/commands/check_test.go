@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestCheckWrite(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	text := `2024-01-01 open Assets:Bank
+2024-01-01 open Income:Salary
+
+2024-01-25 "Salary"
+Income:Salary Assets:Bank 5000 CHF
+`
+	if err := os.WriteFile(journal, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := cmdtest.Run(t, CreateCheckCommand(), "--write", journal)
+
+	if got := string(out); !strings.Contains(got, "balance Assets:Bank") || !strings.Contains(got, "5000 CHF") {
+		t.Errorf("assertions not written to the command output:\n%s", got)
+	}
+}
+
+func TestCheckTimeclock(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	text := `accounttype Project al
+
+2023-01-01 open Project:Acme
+
+i 2023-03-01 09:00 Project:Acme
+o 2023-03-01 12:30
+i 2023-03-01 13:15 Project:Acm
+o 2023-03-01 17:45
+`
+	if err := os.WriteFile(journal, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := CreateCheckCommand()
+	c.SetArgs([]string{journal})
+	c.SetOut(&bytes.Buffer{})
+	c.SetErr(&bytes.Buffer{})
+
+	err := c.Execute()
+
+	if err == nil {
+		t.Fatalf("Execute() succeeded, want an error")
+	}
+	if got := err.Error(); !strings.Contains(got, "journal.knut:7:") || !strings.Contains(got, "account Project:Acm is not open") {
+		t.Errorf("unexpected error:\n%s", got)
+	}
+}
@@ -17,7 +17,6 @@ package commands
 import (
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -58,14 +57,15 @@ $ knut completion zsh > "${fpath[1]}/_knut"
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
 		Run: func(cmd *cobra.Command, args []string) {
+			out := cmd.OutOrStdout()
 			switch args[0] {
 			case `bash`:
-				rootCmd.GenBashCompletion(os.Stdout)
+				rootCmd.GenBashCompletion(out)
 			case `zsh`:
-				rootCmd.GenZshCompletion(os.Stdout)
-				io.WriteString(os.Stdout, "\ncompdef _knut knut\n")
+				rootCmd.GenZshCompletion(out)
+				io.WriteString(out, "\ncompdef _knut knut\n")
 			default:
-				fmt.Printf("Unknown shell: %s", args[0])
+				fmt.Fprintf(cmd.ErrOrStderr(), "Unknown shell: %s", args[0])
 			}
 		},
 	}
@@ -0,0 +1,62 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sboehler/knut/cmd/daemon"
+	"github.com/spf13/cobra"
+)
+
+// CreateDaemonCommand creates the command. newRoot must return a fresh root
+// command, which is used to execute each forwarded command.
+func CreateDaemonCommand(newRoot func() *cobra.Command) *cobra.Command {
+	runner := daemonRunner{newRoot: newRoot}
+	c := &cobra.Command{
+		Use:   "daemon",
+		Short: "run knut in the background to speed up commands",
+		Long: `Run knut as a daemon, which keeps parsed journal files in memory. While the daemon is
+running, knut commands are transparently executed by the daemon, and only files which
+have changed are parsed again. Set KNUT_NO_DAEMON to execute a command locally, and
+KNUT_SOCKET to change the path of the socket.`,
+
+		Args: cobra.NoArgs,
+
+		RunE: runner.execute,
+	}
+	runner.setupFlags(c)
+	return c
+}
+
+type daemonRunner struct {
+	newRoot func() *cobra.Command
+	socket  string
+}
+
+func (r *daemonRunner) setupFlags(c *cobra.Command) {
+	c.Flags().StringVar(&r.socket, "socket", daemon.SocketPath(), "path of the socket to listen on")
+}
+
+func (r *daemonRunner) execute(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	s := daemon.Server{NewRoot: r.newRoot}
+	fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", r.socket)
+	return s.Serve(ctx, r.socket)
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/daemon"
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/journal/doctor"
+)
+
+// CreateDoctorCommand creates the command.
+func CreateDoctorCommand() *cobra.Command {
+
+	var r doctorRunner
+
+	c := &cobra.Command{
+		Use:   "doctor [journal]",
+		Short: "diagnose the journal and the environment",
+		Long: `Diagnose the journal and the environment.
+
+Reports files which are not UTF-8 or mix line endings, files which are included twice or
+cyclically, accounts which are opened twice or used after they have been closed, and
+commodities whose names differ only in case. The journal defaults to $KNUT_JOURNAL. The
+command exits with a non-zero status if problems are found.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: r.execute,
+	}
+	return c
+}
+
+type doctorRunner struct{}
+
+func (r *doctorRunner) execute(cmd *cobra.Command, args []string) error {
+	problems := r.checkEnvironment(cmd.Context())
+	file := env.Getenv(cmd.Context(), flags.JournalEnv)
+	if len(args) > 0 {
+		file = args[0]
+	}
+	if file == "" {
+		return fmt.Errorf("no journal given and %s is not set", flags.JournalEnv)
+	}
+	ps, err := doctor.Diagnose(cmd.Context(), file)
+	if err != nil {
+		return err
+	}
+	for _, p := range ps {
+		problems = append(problems, p.String())
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	if len(problems) == 0 {
+		fmt.Fprintln(out, "no problems found")
+		return nil
+	}
+	for _, p := range problems {
+		fmt.Fprintln(out, p)
+	}
+	return fmt.Errorf("%d problems found", len(problems))
+}
+
+// checkEnvironment checks the environment variables and the daemon socket.
+func (r *doctorRunner) checkEnvironment(ctx context.Context) []string {
+	var problems []string
+	if file := env.Getenv(ctx, flags.JournalEnv); file != "" {
+		if _, err := os.Stat(env.Path(ctx, file)); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v; set it to the path of your journal", flags.JournalEnv, err))
+		}
+	}
+	socket := daemon.SocketPath()
+	if _, err := os.Stat(socket); err == nil {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: no daemon is listening on the socket; remove it or restart `knut daemon`", socket))
+		} else {
+			conn.Close()
+		}
+	}
+	return problems
+}
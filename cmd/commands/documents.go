@@ -0,0 +1,167 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateDocumentsCommand creates the command.
+func CreateDocumentsCommand() *cobra.Command {
+
+	var r documentsRunner
+
+	c := &cobra.Command{
+		Use:   "documents",
+		Short: "list and validate linked documents",
+		Long: `List the documents linked to accounts by document directives and check that
+the files exist. Relative paths are resolved against the directory of the journal
+file containing the directive.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type documentsRunner struct {
+	period   flags.PeriodFlag
+	accounts flags.RegexFlag
+	open     bool
+	colors   flags.Colors
+}
+
+func (r *documentsRunner) setupFlags(c *cobra.Command) {
+	r.period.Setup(c, date.Period{})
+	c.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
+	c.Flags().BoolVar(&r.open, "open", false, "open the listed documents with the default application")
+	r.colors.Setup(c)
+}
+
+func (r *documentsRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	period := r.period.Value()
+	filter := predicate.ByName[*model.Account](r.accounts.Regex())
+	var docs []*model.Document
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd), &journal.Processor{
+		Document: func(d *model.Document) error {
+			if d.Date.Before(period.Start) || !period.End.IsZero() && d.Date.After(period.End) {
+				return nil
+			}
+			if filter(d.Account) {
+				docs = append(docs, d)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	compare.Sort(docs, compareDocuments)
+
+	tbl := table.New(1, 1, 1, 1)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().
+		AddText("Date", table.Center).
+		AddText("Account", table.Center).
+		AddText("File", table.Center).
+		AddText("Status", table.Center)
+	tbl.AddSeparatorRow()
+	var missing int
+	for _, d := range docs {
+		status := "ok"
+		if _, err := os.Stat(env.Path(cmd.Context(), d.File())); err != nil {
+			status = "missing"
+			missing++
+		}
+		tbl.AddRow().
+			AddText(d.Date.Format("2006-01-02"), table.Left).
+			AddText(d.Account.Name(), table.Left).
+			AddText(d.File(), table.Left).
+			AddText(status, table.Left)
+	}
+	tbl.AddSeparatorRow()
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	tableRenderer := table.TextRenderer{Color: color, Theme: theme}
+	if err := tableRenderer.Render(tbl, out); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d of %d documents are missing", missing, len(docs))
+	}
+	if r.open {
+		for _, d := range docs {
+			if err := openFile(d.File()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compareDocuments(d1, d2 *model.Document) compare.Order {
+	if o := compare.Time(d1.Date, d2.Date); o != compare.Equal {
+		return o
+	}
+	if o := account.Compare(d1.Account, d2.Account); o != compare.Equal {
+		return o
+	}
+	return compare.Ordered(d1.Path, d2.Path)
+}
+
+// openFile opens a file with the default application of the platform.
+func openFile(path string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		c = exec.Command("open", path)
+	case "windows":
+		c = exec.Command("cmd", "/c", "start", "", path)
+	default:
+		c = exec.Command("xdg-open", path)
+	}
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/envelopes"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateEnvelopesCommand creates the command.
+func CreateEnvelopesCommand() *cobra.Command {
+
+	var r envelopesRunner
+
+	c := &cobra.Command{
+		Use:   "envelopes",
+		Short: "print an envelope budget",
+		Long: `Print an envelope budget. Money is assigned to envelopes by transfers between
+Envelopes accounts, income funds the unallocated envelope and expenses are charged
+to the envelope with the longest matching name.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type envelopesRunner struct {
+	period      flags.PeriodFlag
+	unallocated string
+	virtual     bool
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+
+	// formatting
+	thousands bool
+	colors    flags.Colors
+	digits    int32
+	rounding  flags.Rounding
+}
+
+func (r *envelopesRunner) setupFlags(c *cobra.Command) {
+	r.period.Setup(c, date.Period{End: date.Today()})
+	c.Flags().StringVar(&r.unallocated, "unallocated", "Envelopes:Unallocated", "envelope funded by income")
+	c.Flags().BoolVar(&r.virtual, "virtual", false, "include virtual postings")
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
+	c.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(c)
+	c.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
+	r.colors.Setup(c)
+}
+
+func (r *envelopesRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	unallocated, err := reg.Accounts().Get(r.unallocated)
+	if err != nil {
+		return err
+	}
+	if !unallocated.IsEnvelope() {
+		return fmt.Errorf("%s is not an envelope account", unallocated)
+	}
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	roundingMode, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	rep := envelopes.NewReport(r.period.Value(), unallocated)
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ComputePricesWithPolicy(b, valuation, pricePolicy),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
+		journal.Query{
+			Select: amounts.KeyMapper{
+				Date:      mapper.Identity[time.Time],
+				Account:   mapper.Identity[*model.Account],
+				Commodity: commodity.IdentityIf(valuation == nil),
+			}.Build(),
+			Valuation: valuation,
+			Virtual:   r.virtual,
+		}.Into(rep),
+	)
+	if err != nil {
+		return err
+	}
+	reportRenderer := envelopes.Renderer{
+		ShowCommodities: valuation == nil,
+	}
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	tableRenderer := table.TextRenderer{
+		Color:     color,
+		Theme:     theme,
+		Thousands: r.thousands,
+		Round:     r.digits,
+		Rounding:  roundingMode,
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return tableRenderer.Render(reportRenderer.Render(rep), out)
+}
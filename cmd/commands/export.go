@@ -0,0 +1,33 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/commands/export"
+)
+
+// CreateExportCommand creates the command.
+func CreateExportCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "export",
+		Short: "Export the journal to other formats",
+		Long:  `Export the journal to other formats`,
+	}
+	c.AddCommand(export.CreateGraphCommand())
+	c.AddCommand(export.CreateSQLiteCommand())
+	return c
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/graph"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateGraphCommand creates the command.
+func CreateGraphCommand() *cobra.Command {
+
+	var r graphRunner
+
+	c := &cobra.Command{
+		Use:   "graph",
+		Short: "export the account tree and flows as a diagram",
+		Long: `Export the account hierarchy as a Graphviz (dot) or d2 diagram. Edges show the
+flows between accounts in the given period, with a width proportional to the amount.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type graphRunner struct {
+	period      flags.PeriodFlag
+	format      string
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+	mapping     flags.MappingFlag
+	remap       flags.RegexFlag
+	accounts    flags.RegexFlag
+	digits      int32
+	rounding    flags.Rounding
+}
+
+func (r *graphRunner) setupFlags(c *cobra.Command) {
+	r.period.Setup(c, date.Period{End: date.Today()})
+	c.Flags().StringVar(&r.format, "format", "dot", "output format (dot or d2)")
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
+	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
+	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
+	c.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
+	c.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(c)
+	c.MarkFlagRequired("val")
+}
+
+func (r *graphRunner) execute(cmd *cobra.Command, args []string) error {
+	format, err := graph.ParseFormat(r.format)
+	if err != nil {
+		return err
+	}
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	roundingMode, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	partition := date.NewPartition(r.period.Value().Clip(b.Period()), date.Once, 0)
+	accountMapper := mapper.Sequence(
+		account.Remap(reg.Accounts(), r.remap.Regex()),
+		account.Shorten(reg.Accounts(), mapping),
+	)
+	rep := graph.NewReport()
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ComputePricesWithPolicy(b, valuation, pricePolicy),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
+		journal.Filter(partition),
+		journal.Query{
+			Select: amounts.KeyMapper{
+				Account: accountMapper,
+				Other:   accountMapper,
+			}.Build(),
+			Where: predicate.And(
+				amounts.AccountMatches(r.accounts.Regex()),
+				amounts.OtherAccountMatches(r.accounts.Regex()),
+			),
+			Valuation: valuation,
+		}.Into(rep),
+	)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return graph.Renderer{Format: format, Digits: r.digits, Rounding: roundingMode}.Render(rep, out)
+}
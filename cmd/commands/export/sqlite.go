@@ -0,0 +1,123 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/journal/sqlite"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// CreateSQLiteCommand creates the command.
+func CreateSQLiteCommand() *cobra.Command {
+	var r sqliteRunner
+	c := &cobra.Command{
+		Use:   "sqlite <journal> <database>",
+		Short: "export the journal to a SQLite database",
+		Long: `Export the accounts, commodities, prices, transactions and postings of the
+journal to a new SQLite database, replacing an existing file. Each booking is
+stored as two postings, one for each account. With --val, the postings carry
+their value in the given commodity.`,
+		Args: cobra.ExactArgs(2),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type sqliteRunner struct {
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+	rounding    flags.Rounding
+}
+
+func (r *sqliteRunner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
+	r.rounding.Setup(c)
+}
+
+func (r *sqliteRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	_, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	path := env.Path(cmd.Context(), args[1])
+	f, err := os.CreateTemp(filepath.Dir(path), ".knut-*.db")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := r.write(tmp, reg, b, flags.Processing(cmd), valuation != nil, journal.ComputePricesWithPolicy(b, valuation, pricePolicy), journal.ValuateWithRounding(reg, valuation, valuationRounding)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (r *sqliteRunner) write(path string, reg *registry.Registry, b *journal.Builder, opts journal.Options, valuated bool, procs ...*journal.Processor) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	e, err := sqlite.New(tx, reg, valuated)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	procs = append([]*journal.Processor{journal.ApplyValues(reg), check.Check()}, procs...)
+	if err := b.Build().ProcessWithOptions(opts, append(procs, e.Processor())...); err != nil {
+		return err
+	}
+	if err := e.Close(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return db.Close()
+}
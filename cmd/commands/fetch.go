@@ -16,11 +16,13 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/price"
@@ -47,24 +49,17 @@ func CreateFetchCommand() *cobra.Command {
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
-		Run: runner.run,
+		RunE: runner.execute,
 	}
 }
 
 type fetchRunner struct{}
 
-func (r *fetchRunner) run(cmd *cobra.Command, args []string) {
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
-}
-
 const fetchConcurrency = 5
 
-func (r *fetchRunner) execute(_ *cobra.Command, args []string) error {
+func (r *fetchRunner) execute(cmd *cobra.Command, args []string) error {
 	reg := registry.New()
-	configs, err := r.readConfig(args[0])
+	configs, err := r.readConfig(env.Path(cmd.Context(), args[0]))
 	if err != nil {
 		return err
 	}
@@ -75,22 +70,22 @@ func (r *fetchRunner) execute(_ *cobra.Command, args []string) error {
 		cfg := cfg
 		p.Go(func() error {
 			defer bar.Increment()
-			return r.fetch(reg, args[0], cfg)
+			return r.fetch(cmd.Context(), reg, args[0], cfg)
 		})
 	}
 	return multierr.Combine(p.Wait())
 }
 
-func (r *fetchRunner) fetch(reg *registry.Registry, f string, cfg fetchConfig) error {
+func (r *fetchRunner) fetch(ctx context.Context, reg *registry.Registry, f string, cfg fetchConfig) error {
 	absPath := filepath.Join(filepath.Dir(f), cfg.File)
-	pricesByDate, err := r.readFile(reg, absPath)
+	pricesByDate, err := r.readFile(ctx, reg, absPath)
 	if err != nil {
 		return err
 	}
 	if err := r.fetchPrices(reg, cfg, time.Now().AddDate(-1, 0, 0), time.Now(), pricesByDate); err != nil {
 		return err
 	}
-	if err := r.writeFile(pricesByDate, absPath); err != nil {
+	if err := r.writeFile(pricesByDate, env.Path(ctx, absPath)); err != nil {
 		return err
 	}
 	return nil
@@ -111,15 +106,15 @@ func (r *fetchRunner) readConfig(path string) ([]fetchConfig, error) {
 	return t, nil
 }
 
-func (r *fetchRunner) readFile(ctx *registry.Registry, filepath string) (res map[time.Time]*model.Price, err error) {
-	f, err := syntax.ParseFile(filepath)
+func (r *fetchRunner) readFile(ctx context.Context, reg *registry.Registry, filepath string) (res map[time.Time]*model.Price, err error) {
+	f, err := syntax.ParseFile(ctx, filepath)
 	if err != nil {
 		return nil, err
 	}
 	prices := make(map[time.Time]*model.Price)
 	for _, d := range f.Directives {
 		if p, ok := d.Directive.(syntax.Price); ok {
-			m, err := price.Create(ctx, &p)
+			m, err := price.Create(reg, &p)
 			if err != nil {
 				return nil, err
 			}
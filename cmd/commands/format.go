@@ -16,14 +16,15 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"os"
 
 	"github.com/natefinch/atomic"
 	"github.com/sourcegraph/conc/iter"
 	"github.com/spf13/cobra"
 	"go.uber.org/multierr"
 
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/syntax"
 )
 
@@ -35,25 +36,20 @@ func CreateFormatCommand() *cobra.Command {
 		Short: "Format the given journal",
 		Long:  `Format the given journal in-place. Any white space and comments between directives is preserved.`,
 
-		Run: runner.run,
+		RunE: runner.execute,
 	}
 }
 
 type formatRunner struct{}
 
-func (r formatRunner) run(cmd *cobra.Command, args []string) {
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
-}
-
 func (r formatRunner) execute(cmd *cobra.Command, args []string) error {
-	return multierr.Combine(iter.Map(args, r.formatFile)...)
+	return multierr.Combine(iter.Map(args, func(target *string) error {
+		return r.formatFile(cmd.Context(), *target)
+	})...)
 }
 
-func (formatRunner) formatFile(target *string) error {
-	file, err := syntax.ParseFile(*target)
+func (formatRunner) formatFile(ctx context.Context, target string) error {
+	file, err := syntax.ParseFile(ctx, target)
 	if err != nil {
 		return err
 	}
@@ -61,5 +57,8 @@ func (formatRunner) formatFile(target *string) error {
 	if err := syntax.FormatFile(&dest, file); err != nil {
 		return err
 	}
-	return atomic.WriteFile(*target, &dest)
+	if err := syntax.Verify(file.Text, target); err != nil {
+		return fmt.Errorf("formatting %s would change its directives, leaving it unchanged: %w", target, err)
+	}
+	return atomic.WriteFile(env.Path(ctx, target), &dest)
 }
@@ -15,18 +15,139 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax/parser"
 	"github.com/spf13/cobra"
 )
 
 // CreateImportCommand is the import command.
 func CreateImportCommand() *cobra.Command {
+	var (
+		dedupAgainst string
+		ids          bool
+	)
 	cmd := cobra.Command{
 		Use:   "import",
 		Short: "Import financial account statements",
 	}
+	cmd.PersistentFlags().StringVar(&dedupAgainst, "dedup-against", "", "omit transactions which already exist in the given journal")
+	cmd.PersistentFlags().BoolVar(&ids, "ids", false, "add a stable id to each imported transaction")
 	for _, constructor := range importer.GetImporters() {
-		cmd.AddCommand(constructor())
+		c := constructor()
+		run := c.RunE
+		if run == nil && c.Run != nil {
+			run = wrapRun(c.Run)
+			c.Run = nil
+		}
+		if run == nil {
+			cmd.AddCommand(c)
+			continue
+		}
+		c.RunE = func(cmd *cobra.Command, args []string) error {
+			if dedupAgainst == "" && !ids {
+				return run(cmd, args)
+			}
+			out := cmd.OutOrStdout()
+			var buf bytes.Buffer
+			cmd.SetOut(&buf)
+			err := run(cmd, args)
+			cmd.SetOut(out)
+			if err != nil {
+				return err
+			}
+			var source string
+			if ids {
+				source = cmd.Name()
+			}
+			return postprocess(cmd, buf.String(), source, dedupAgainst, out)
+		}
+		cmd.AddCommand(c)
 	}
+	flags.RegisterCompletions(&cmd, flags.JournalFromEnv)
 	return &cmd
 }
+
+// wrapRun adapts the Run function of an importer which does not return
+// errors.
+func wrapRun(run func(*cobra.Command, []string)) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		run(cmd, args)
+		return nil
+	}
+}
+
+// postprocess prints the imported journal text to w. If source is not empty,
+// transactions are assigned stable IDs derived from source, which is the name
+// of the importer, and their content. If path is not
+// empty, transactions which already exist in the journal at path are
+// omitted.
+func postprocess(cmd *cobra.Command, imported, source, path string, w io.Writer) error {
+	reg := registry.New()
+	var d *journal.Dedup
+	if path != "" {
+		existing, err := journal.FromPath(cmd.Context(), reg, path)
+		if err != nil {
+			return err
+		}
+		d = journal.NewDedup(existing.Build())
+	}
+	directives, err := parseImported(reg, imported)
+	if err != nil {
+		return err
+	}
+	var ids *journal.IDs
+	if source != "" {
+		ids = journal.NewIDs(source)
+	}
+	res := journal.New()
+	var skipped int
+	for _, dir := range directives {
+		if t, ok := dir.(*model.Transaction); ok {
+			if ids != nil {
+				ids.Assign(t)
+			}
+			if d != nil && d.IsDuplicate(t) {
+				skipped++
+				continue
+			}
+		}
+		if err := res.Add(dir); err != nil {
+			return err
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "omitted %d transactions which already exist in %s\n", skipped, path)
+	}
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	return journal.Print(out, res.Build())
+}
+
+func parseImported(reg *model.Registry, text string) ([]model.Directive, error) {
+	p := parser.New(text, "<import>")
+	if err := p.Advance(); err != nil {
+		return nil, err
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		return nil, err
+	}
+	var res []model.Directive
+	for _, d := range f.Directives {
+		ds, err := model.ParseDirective(reg, d)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ds...)
+	}
+	return res, nil
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/sboehler/knut/cmd/importer/coinbase"
+	_ "github.com/sboehler/knut/cmd/importer/comdirect"
+	_ "github.com/sboehler/knut/cmd/importer/cumulus"
+	_ "github.com/sboehler/knut/cmd/importer/dkb"
+	_ "github.com/sboehler/knut/cmd/importer/external"
+	_ "github.com/sboehler/knut/cmd/importer/ing"
+	_ "github.com/sboehler/knut/cmd/importer/interactivebrokers"
+	_ "github.com/sboehler/knut/cmd/importer/kraken"
+	_ "github.com/sboehler/knut/cmd/importer/migrosbank"
+	_ "github.com/sboehler/knut/cmd/importer/monzo"
+	_ "github.com/sboehler/knut/cmd/importer/n26"
+	_ "github.com/sboehler/knut/cmd/importer/payslip"
+	_ "github.com/sboehler/knut/cmd/importer/postfinance"
+	_ "github.com/sboehler/knut/cmd/importer/raiffeisen"
+	_ "github.com/sboehler/knut/cmd/importer/revolut"
+	_ "github.com/sboehler/knut/cmd/importer/revolut2"
+	_ "github.com/sboehler/knut/cmd/importer/revolutbusiness"
+	_ "github.com/sboehler/knut/cmd/importer/supercard"
+	_ "github.com/sboehler/knut/cmd/importer/swisscard"
+	_ "github.com/sboehler/knut/cmd/importer/swisscard2"
+	_ "github.com/sboehler/knut/cmd/importer/swissquote"
+	_ "github.com/sboehler/knut/cmd/importer/timetracking"
+	_ "github.com/sboehler/knut/cmd/importer/ubs"
+	_ "github.com/sboehler/knut/cmd/importer/viac"
+	_ "github.com/sboehler/knut/cmd/importer/viseca"
+	_ "github.com/sboehler/knut/cmd/importer/wise"
+	_ "github.com/sboehler/knut/cmd/importer/zkb"
+)
+
+func TestImportRunsEveryImporter(t *testing.T) {
+	c := CreateImportCommand()
+	if err := c.PersistentFlags().Set("ids", "true"); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.csv")
+	for _, sub := range c.Commands() {
+		t.Run(sub.Name(), func(t *testing.T) {
+			if sub.RunE == nil {
+				t.Fatalf("importer has no RunE")
+			}
+			sub.SetContext(context.Background())
+			sub.SetOut(&bytes.Buffer{})
+			sub.SetErr(&bytes.Buffer{})
+
+			if err := sub.RunE(sub, []string{missing}); err == nil {
+				t.Errorf("importer returned no error for a missing file")
+			}
+		})
+	}
+}
+
+func TestImportPostfinance(t *testing.T) {
+	c := CreateImportCommand()
+	var out bytes.Buffer
+	c.SetArgs([]string{"--ids", "ch.postfinance", "--account", "Assets:Postfinance", "../importer/postfinance/testdata/example1.input"})
+	c.SetOut(&out)
+	c.SetErr(&bytes.Buffer{})
+
+	if err := c.Execute(); err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Assets:Postfinance") || !strings.Contains(got, "id:") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
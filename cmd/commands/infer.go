@@ -18,14 +18,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"fmt"
-	"os"
 
 	"github.com/natefinch/atomic"
 	"github.com/sourcegraph/conc/pool"
 	"github.com/spf13/cobra"
 
 	"github.com/sboehler/knut/lib/common/cpr"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/sboehler/knut/lib/syntax/bayes"
 )
@@ -39,7 +38,7 @@ func CreateInferCmd() *cobra.Command {
 		Long: `Build a Bayes model using the supplied training file and apply it to replace
 		the indicated account in the target file. Training file and target file may be the same.`,
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-		Run:  r.run,
+		RunE: r.execute,
 	}
 	r.setupFlags(cmd)
 	return cmd
@@ -58,13 +57,6 @@ func (r *inferRunner) setupFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("training-file")
 }
 
-func (r *inferRunner) run(cmd *cobra.Command, args []string) {
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
-}
-
 func (r *inferRunner) execute(cmd *cobra.Command, args []string) (errors error) {
 	var (
 		targetFile = args[0]
@@ -83,7 +75,7 @@ func (r *inferRunner) execute(cmd *cobra.Command, args []string) (errors error)
 		if err := syntax.FormatFile(&buf, file); err != nil {
 			return err
 		}
-		return atomic.WriteFile(targetFile, &buf)
+		return atomic.WriteFile(env.Path(cmd.Context(), targetFile), &buf)
 	} else {
 		out := bufio.NewWriter(cmd.OutOrStdout())
 		defer out.Flush()
@@ -110,7 +102,7 @@ func (inferRunner) train(ctx context.Context, file string, account string) (*bay
 }
 
 func (r *inferRunner) parseAndInfer(ctx context.Context, model *bayes.Model, targetFile string) (syntax.File, error) {
-	f, err := syntax.ParseFile(targetFile)
+	f, err := syntax.ParseFile(ctx, targetFile)
 	if err != nil {
 		return syntax.File{}, err
 	}
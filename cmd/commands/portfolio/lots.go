@@ -0,0 +1,176 @@
+// Copyright 2020 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package portfolio
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/lots"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// CreateLotsCommand creates the command.
+func CreateLotsCommand() *cobra.Command {
+	var r lotsRunner
+	c := &cobra.Command{
+		Use:   "lots",
+		Short: "list lots and the cost basis of disposals",
+		Long: `List the open lots of commodities held at cost, or, with --disposals, the
+cost basis of the quantities sold. With --booking avg, lots are pooled at their
+weighted average cost and disposals are valued at the running average cost.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type lotsRunner struct {
+	booking               flags.BookingFlag
+	disposals             bool
+	accounts, commodities flags.RegexFlag
+
+	// formatting
+	thousands bool
+	colors    flags.Colors
+	digits    int32
+	rounding  flags.Rounding
+}
+
+func (r *lotsRunner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().Var(&r.booking, "booking", "booking method")
+	cmd.Flags().BoolVar(&r.disposals, "disposals", false, "list the disposals instead of the open lots")
+	cmd.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
+	cmd.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	cmd.Flags().Int32Var(&r.digits, "digits", 2, "round to number of digits")
+	r.rounding.Setup(cmd)
+	cmd.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
+	r.colors.Setup(cmd)
+}
+
+// disposal is the part of a lot reduced by a posting.
+type disposal struct {
+	lots.Lot
+	Date      time.Time
+	Account   *model.Account
+	Commodity *model.Commodity
+}
+
+func (r *lotsRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	roundingMode, _, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	j, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	var (
+		inv             = lots.New(r.booking.Value())
+		disposals       []disposal
+		accountFilter   = predicate.ByName[*model.Account](r.accounts.Regex())
+		commodityFilter = predicate.ByName[*model.Commodity](r.commodities.Regex())
+	)
+	err = j.Build().ProcessWithOptions(flags.Processing(cmd), &journal.Processor{
+		Posting: func(t *model.Transaction, p *model.Posting) error {
+			for _, l := range inv.Book(t.Date, p) {
+				disposals = append(disposals, disposal{Lot: l, Date: t.Date, Account: p.Account, Commodity: p.Commodity})
+			}
+			return nil
+		},
+		Close: func(c *model.Close) error {
+			inv.Close(c.Account)
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var tbl *table.Table
+	if r.disposals {
+		tbl = table.New(1, 1, 1, 1, 1, 1, 1, 1)
+		tbl.AddSeparatorRow()
+		tbl.AddRow().
+			AddText("Date", table.Center).
+			AddText("Account", table.Center).
+			AddText("Commodity", table.Center).
+			AddText("Acquired", table.Center).
+			AddText("Quantity", table.Center).
+			AddText("Unit cost", table.Center).
+			AddText("Cost basis", table.Center).
+			AddText("", table.Center)
+		tbl.AddSeparatorRow()
+		for _, d := range disposals {
+			if !accountFilter(d.Account) || !commodityFilter(d.Commodity) {
+				continue
+			}
+			tbl.AddRow().
+				AddText(d.Date.Format("2006-01-02"), table.Left).
+				AddText(d.Account.Name(), table.Left).
+				AddCommodity(d.Commodity.Name()).
+				AddText(d.Lot.Date.Format("2006-01-02"), table.Left).
+				AddDecimal(d.Quantity).
+				AddDecimal(d.Cost).
+				AddDecimal(d.Quantity.Mul(d.Cost)).
+				AddCommodity(d.CostCommodity.Name())
+		}
+	} else {
+		tbl = table.New(1, 1, 1, 1, 1, 1, 1)
+		tbl.AddSeparatorRow()
+		tbl.AddRow().
+			AddText("Account", table.Center).
+			AddText("Commodity", table.Center).
+			AddText("Acquired", table.Center).
+			AddText("Quantity", table.Center).
+			AddText("Unit cost", table.Center).
+			AddText("Cost", table.Center).
+			AddText("", table.Center)
+		tbl.AddSeparatorRow()
+		for _, k := range inv.Positions() {
+			if !accountFilter(k.Account) || !commodityFilter(k.Commodity) {
+				continue
+			}
+			for _, l := range inv.Lots(k.Account, k.Commodity) {
+				tbl.AddRow().
+					AddText(k.Account.Name(), table.Left).
+					AddCommodity(k.Commodity.Name()).
+					AddText(l.Date.Format("2006-01-02"), table.Left).
+					AddDecimal(l.Quantity).
+					AddDecimal(l.Cost).
+					AddDecimal(l.Quantity.Mul(l.Cost)).
+					AddCommodity(l.CostCommodity.Name())
+			}
+		}
+	}
+	tbl.AddSeparatorRow()
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	tableRenderer := table.TextRenderer{Color: color, Theme: theme, Thousands: r.thousands, Round: r.digits, Rounding: roundingMode}
+	return tableRenderer.Render(tbl, out)
+}
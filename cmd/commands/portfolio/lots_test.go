@@ -0,0 +1,46 @@
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestLotsGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "fifo",
+			args: []string{"--booking", "fifo"},
+		},
+		{
+			name: "fifo-disposals",
+			args: []string{"--booking", "fifo", "--disposals"},
+		},
+		{
+			name: "avg",
+			args: []string{"--booking", "avg"},
+		},
+		{
+			name: "avg-disposals",
+			args: []string{"--booking", "avg", "--disposals"},
+		},
+		{
+			name: "filter",
+			args: []string{"--account", "Savings"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := append([]string{"testdata/lots/example.knut"}, test.args...)
+
+			got := cmdtest.Run(t, CreateLotsCommand(), args...)
+
+			goldie.New(t, goldie.WithFixtureDir("testdata/lots")).Assert(t, test.name, got)
+		})
+	}
+}
@@ -15,18 +15,19 @@ package portfolio
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"runtime/pprof"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/common/predicate"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/journal/check"
 	"github.com/sboehler/knut/lib/journal/performance"
 	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/registry"
 )
 
@@ -38,11 +39,24 @@ func CreateReturnsCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "returns",
 		Short: "compute portfolio returns",
-		Long:  `Compute portfolio returns.`,
+		Long: `Compute portfolio returns.
+
+The @performance annotation of a transaction lists the commodities to which its
+performance effect is attributed. Besides commodities, it accepts regex patterns
+enclosed in slashes, e.g. @performance(/^(BTC|ETH)$/), which select the matching
+commodities held in the portfolio or booked in the transaction. With
+--commodity-groups, patterns also match the qualified name <group>:<commodity>,
+e.g. @performance(/^Crypto:/).
+
+By default, the inflows of a day are assumed to arrive at its start and the
+outflows to leave at its end. With --flow-time, all external flows of a day are
+weighted by the fraction of the day during which they are invested (Modified
+Dietz), e.g. --flow-time 0.5 for flows at midday. This reduces the distortion of
+days with large deposits or withdrawals and market moves.`,
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
-		Run: r.run,
+		RunE: r.run,
 	}
 	r.setupFlags(c)
 	return c
@@ -52,57 +66,88 @@ type returnsRunner struct {
 	flags.Multiperiod
 	cpuprofile            string
 	valuation             flags.CommodityFlag
+	rounding              flags.Rounding
 	accounts, commodities flags.RegexFlag
+	breakdown             string
+	groupsFile            string
+	flowTime              float64
 }
 
 func (r *returnsRunner) setupFlags(cmd *cobra.Command) {
 	r.Multiperiod.Setup(cmd)
 	cmd.Flags().StringVar(&r.cpuprofile, "cpuprofile", "", "file to write profile")
 	cmd.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.rounding.Setup(cmd)
 	cmd.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
 	cmd.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	cmd.Flags().StringVar(&r.breakdown, "breakdown", "", "break the returns down by contribution (commodity)")
+	cmd.Flags().StringVar(&r.groupsFile, "commodity-groups", "", "YAML file assigning commodities to groups, for matching @performance patterns")
+	cmd.Flags().Float64Var(&r.flowTime, "flow-time", 0, "time of day of external flows, as a fraction between 0 and 1 (default: inflows at the start, outflows at the end)")
 }
 
-func (r *returnsRunner) run(cmd *cobra.Command, args []string) {
+func (r *returnsRunner) run(cmd *cobra.Command, args []string) error {
 	if r.cpuprofile != "" {
-		f, err := os.Create(r.cpuprofile)
+		f, err := os.Create(env.Path(cmd.Context(), r.cpuprofile))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
+	return r.execute(cmd, args)
 }
 
 func (r *returnsRunner) execute(cmd *cobra.Command, args []string) error {
+	if r.breakdown != "" && r.breakdown != "commodity" {
+		return fmt.Errorf("invalid breakdown %q, want commodity", r.breakdown)
+	}
+	weights := performance.EndOfDay
+	if cmd.Flags().Changed("flow-time") {
+		var err error
+		if weights, err = performance.AtTime(r.flowTime); err != nil {
+			return err
+		}
+	}
 	ctx := cmd.Context()
 	reg := registry.New()
 	valuation, err := r.valuation.Value(reg)
 	if err != nil {
 		return err
 	}
+	_, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	var groups commodity.Groups
+	if r.groupsFile != "" {
+		if groups, err = commodity.LoadGroupsFromFile(reg.Commodities(), env.Path(cmd.Context(), r.groupsFile)); err != nil {
+			return err
+		}
+	}
 	j, err := journal.FromPath(ctx, reg, args[0])
 	if err != nil {
 		return err
 	}
 	partition := r.Multiperiod.Partition(j.Period())
+	perf := performance.Perf(cmd.OutOrStdout(), j, partition, weights)
+	if r.breakdown == "commodity" {
+		perf = performance.PerfByCommodity(cmd.OutOrStdout(), j, partition, weights)
+	}
 	calculator := &performance.Calculator{
 		Context:         reg,
 		Valuation:       valuation,
 		AccountFilter:   predicate.ByName[*model.Account](r.accounts.Regex()),
 		CommodityFilter: predicate.ByName[*model.Commodity](r.commodities.Regex()),
+		Groups:          groups,
 	}
-	err = j.Build().Process(
+	err = j.Build().ProcessWithOptions(flags.Processing(cmd),
 		journal.ComputePrices(valuation),
+		journal.ApplyValues(reg),
 		check.Check(),
-		journal.Valuate(reg, valuation),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
 		calculator.ComputeValues(),
 		calculator.ComputeFlows(),
-		performance.Perf(j, partition),
+		perf,
 	)
 	return err
 }
@@ -15,13 +15,12 @@ package portfolio
 
 import (
 	"bufio"
-	"fmt"
 	"io"
-	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/common/predicate"
 	"github.com/sboehler/knut/lib/common/table"
 	"github.com/sboehler/knut/lib/journal"
@@ -44,7 +43,7 @@ func CreateWeightsCommand() *cobra.Command {
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
-		Run: r.run,
+		RunE: r.execute,
 	}
 	r.setupFlags(c)
 	return c
@@ -58,13 +57,15 @@ type weightsRunner struct {
 
 	// formatting
 	thousands bool
-	color     bool
+	colors    flags.Colors
 	digits    int32
+	rounding  flags.Rounding
 
 	mapping            flags.MappingFlag
 	sortAlphabetically bool
 
 	universe string
+	groupBy  string
 
 	csv bool
 }
@@ -72,6 +73,8 @@ type weightsRunner struct {
 func (r *weightsRunner) setupFlags(cmd *cobra.Command) {
 	r.Multiperiod.Setup(cmd)
 	cmd.Flags().StringVarP(&r.universe, "universe", "", "", "universe file")
+	cmd.Flags().StringVar(&r.groupBy, "group-by", "", "classify commodities by the value of a metadata key, such as asset-class")
+	cmd.MarkFlagsMutuallyExclusive("universe", "group-by")
 	cmd.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
 	cmd.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
 	cmd.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
@@ -80,25 +83,19 @@ func (r *weightsRunner) setupFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&r.csv, "csv", false, "render csv")
 	cmd.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
 	cmd.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(cmd)
 	cmd.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
-	cmd.Flags().BoolVar(&r.color, "color", true, "print output in color")
+	r.colors.Setup(cmd)
 
 }
 
-func (r *weightsRunner) run(cmd *cobra.Command, args []string) {
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
-}
-
 func (r *weightsRunner) execute(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	reg := registry.New()
 	var universe performance.Universe
 	if len(r.universe) > 0 {
 		var err error
-		universe, err = performance.LoadUniverseFromFile(reg.Commodities(), r.universe)
+		universe, err = performance.LoadUniverseFromFile(reg.Commodities(), env.Path(ctx, r.universe))
 		if err != nil {
 			return err
 		}
@@ -107,10 +104,21 @@ func (r *weightsRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	roundingMode, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
 	j, err := journal.FromPath(ctx, reg, args[0])
 	if err != nil {
 		return err
 	}
+	if len(r.groupBy) > 0 {
+		universe = performance.UniverseByMetadata(reg.Commodities(), r.groupBy)
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
 	partition := r.Multiperiod.Partition(j.Period())
 	calculator := &performance.Calculator{
 		Context:         reg,
@@ -120,15 +128,16 @@ func (r *weightsRunner) execute(cmd *cobra.Command, args []string) error {
 	}
 	j.Days(partition.EndDates())
 	rep := weights.NewReport()
-	err = j.Build().Process(
+	err = j.Build().ProcessWithOptions(flags.Processing(cmd),
 		journal.ComputePrices(valuation),
+		journal.ApplyValues(reg),
 		check.Check(),
-		journal.Valuate(reg, valuation),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
 		calculator.ComputeValues(),
 		weights.Query{
 			Universe:  universe,
 			Partition: partition,
-			Mapping:   r.mapping.Value(),
+			Mapping:   mapping,
 		}.Execute(j, rep),
 	)
 	if err != nil {
@@ -141,9 +150,15 @@ func (r *weightsRunner) execute(cmd *cobra.Command, args []string) error {
 	if r.csv {
 		tableRenderer = &table.CSVRenderer{}
 	} else {
+		color, theme, err := r.colors.Value(cmd)
+		if err != nil {
+			return err
+		}
 		tableRenderer = &table.TextRenderer{
-			Color: r.color,
-			Round: r.digits,
+			Color:    color,
+			Theme:    theme,
+			Round:    r.digits,
+			Rounding: roundingMode,
 		}
 	}
 	out := bufio.NewWriter(cmd.OutOrStdout())
@@ -0,0 +1,34 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/commands/prices"
+)
+
+// CreatePricesCommand creates the command.
+func CreatePricesCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "prices",
+		Short: "Price file maintenance commands",
+		Long:  `Price file maintenance commands`,
+	}
+	c.AddCommand(prices.CreateCompactCommand())
+	c.AddCommand(prices.CreateCheckCommand())
+	c.AddCommand(prices.CreateExplainCommand())
+	return c
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prices
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// CreateCheckCommand creates the command.
+func CreateCheckCommand() *cobra.Command {
+	var r checkRunner
+	c := &cobra.Command{
+		Use:   "check <journal>",
+		Short: "Check the prices of a journal for gaps and typos",
+		Long: `Report commodities which are held in asset or liability accounts while their most
+recent price is older than --max-age days, price directives on the same day which disagree,
+also when one quotes the inverse of the other, and changes between consecutive prices of a
+commodity pair larger than --max-change, which often indicate typos. The command exits with
+a non-zero status if problems are found.`,
+
+		Args: cobra.ExactArgs(1),
+
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type checkRunner struct {
+	maxAge    int
+	maxChange float64
+}
+
+func (r *checkRunner) setupFlags(c *cobra.Command) {
+	c.Flags().IntVar(&r.maxAge, "max-age", 30, "maximum age of the price of a held commodity in days")
+	c.Flags().Float64Var(&r.maxChange, "max-change", 0.5, "maximum relative change between consecutive prices, 0 to disable")
+}
+
+func (r *checkRunner) execute(cmd *cobra.Command, args []string) error {
+	if r.maxAge < 0 {
+		return fmt.Errorf("max-age must not be negative, got %d", r.maxAge)
+	}
+	if r.maxChange < 0 {
+		return fmt.Errorf("max-change must not be negative, got %f", r.maxChange)
+	}
+	reg := registry.New()
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	problems := journal.CheckPrices(b.Build(), journal.PriceCheck{
+		MaxAge:    r.maxAge,
+		MaxChange: decimal.NewFromFloat(r.maxChange),
+	})
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	if len(problems) == 0 {
+		fmt.Fprintln(out, "no problems found")
+		return nil
+	}
+	for _, p := range problems {
+		fmt.Fprintln(out, p)
+	}
+	return fmt.Errorf("%d problems found", len(problems))
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prices
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/natefinch/atomic"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/price"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// CreateCompactCommand creates the command.
+func CreateCompactCommand() *cobra.Command {
+	var r compactRunner
+	c := &cobra.Command{
+		Use:   "compact",
+		Short: "Remove redundant prices from price files",
+		Long: `Remove redundant prices from the given price files and sort them. Prices which do not
+deviate from the previously retained price by more than the given relative tolerance are removed.
+With the default tolerance of 0, valuation results remain identical. The files are rewritten in-place,
+unless an output file is given, in which case all input files are merged into it.`,
+
+		Args: cobra.MinimumNArgs(1),
+
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type compactRunner struct {
+	tolerance float64
+	output    string
+}
+
+func (r *compactRunner) setupFlags(c *cobra.Command) {
+	c.Flags().Float64Var(&r.tolerance, "tolerance", 0, "maximum relative price change to consider a price redundant")
+	c.Flags().StringVarP(&r.output, "output", "o", "", "merge all files into the given file")
+}
+
+func (r *compactRunner) execute(cmd *cobra.Command, args []string) error {
+	if r.tolerance < 0 {
+		return fmt.Errorf("tolerance must not be negative, got %f", r.tolerance)
+	}
+	reg := registry.New()
+	if r.output != "" {
+		var all []*model.Price
+		for _, path := range args {
+			ps, err := readFile(cmd.Context(), reg, path)
+			if err != nil {
+				return err
+			}
+			all = append(all, ps...)
+		}
+		return r.writeFile(env.Path(cmd.Context(), r.output), all)
+	}
+	for _, path := range args {
+		ps, err := readFile(cmd.Context(), reg, path)
+		if err != nil {
+			return err
+		}
+		if err := r.writeFile(env.Path(cmd.Context(), path), ps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *compactRunner) writeFile(path string, ps []*model.Price) error {
+	j := journal.New()
+	for _, p := range price.Compact(ps, decimal.NewFromFloat(r.tolerance)) {
+		if err := j.Add(p); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if err := journal.Print(&buf, j.Build()); err != nil {
+		return err
+	}
+	return atomic.WriteFile(path, &buf)
+}
+
+func readFile(ctx context.Context, reg *registry.Registry, path string) ([]*model.Price, error) {
+	f, err := syntax.ParseFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var res []*model.Price
+	for _, d := range f.Directives {
+		p, ok := d.Directive.(syntax.Price)
+		if !ok {
+			return nil, fmt.Errorf("unexpected directive in prices file: %v", d)
+		}
+		m, err := price.Create(reg, &p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prices
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// CreateExplainCommand creates the command.
+func CreateExplainCommand() *cobra.Command {
+	var r explainRunner
+	c := &cobra.Command{
+		Use:   "explain <journal> <commodity> <target> [<date>]",
+		Short: "Show the chain of prices used to valuate a commodity",
+		Long: `Show the chain of prices which valuates the commodity in the target commodity
+on the given date (default: today), together with the price directives it is based
+on. Prices are triangulated along the shortest chain of prices, preferring the
+commodities given with --price-path. With --price-policy interpolate, prices between
+two price directives are interpolated, as in the balance and register commands.`,
+
+		Args: cobra.RangeArgs(3, 4),
+
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type explainRunner struct {
+	pricePolicy flags.PricePolicy
+}
+
+func (r *explainRunner) setupFlags(c *cobra.Command) {
+	r.pricePolicy.Setup(c)
+}
+
+func (r *explainRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	c, err := reg.Commodities().Get(args[1])
+	if err != nil {
+		return err
+	}
+	target, err := reg.Commodities().Get(args[2])
+	if err != nil {
+		return err
+	}
+	d := date.Today()
+	if len(args) == 4 {
+		if d, err = time.Parse("2006-01-02", args[3]); err != nil {
+			return err
+		}
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	steps, err := journal.ExplainPrice(b.Build(), target, c, d, pricePolicy)
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	total := decimal.NewFromInt(1)
+	for _, s := range steps {
+		total = total.Mul(s.Price)
+	}
+	fmt.Fprintf(w, "%s: 1 %s = %s %s\n", d.Format("2006-01-02"), c.Name(), total.Truncate(8), target.Name())
+	for _, s := range steps {
+		fmt.Fprintf(w, "  1 %s = %s %s (%s)\n", s.Commodity.Name(), s.Price, s.Target.Name(), source(s))
+	}
+	return nil
+}
+
+// source describes the price directive of a step.
+func source(s journal.PriceStep) string {
+	p := s.Source
+	if p == nil {
+		return "unknown source"
+	}
+	res := directive(p)
+	if s.Next != nil {
+		res = "interpolated between " + res + " and " + directive(s.Next)
+	}
+	if p.Commodity != s.Commodity {
+		res += ", inverted"
+	}
+	return res
+}
+
+// directive describes a price directive and its location.
+func directive(p *model.Price) string {
+	res := fmt.Sprintf("%s price %s %s %s", p.Date.Format("2006-01-02"), p.Commodity.Name(), p.Price, p.Target.Name())
+	if p.Src != nil {
+		res += ", " + p.Src.Position()
+	}
+	return res
+}
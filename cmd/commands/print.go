@@ -16,9 +16,8 @@ package commands
 
 import (
 	"bufio"
-	"fmt"
-	"os"
 
+	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/journal/check"
 	"github.com/sboehler/knut/lib/model/registry"
@@ -38,7 +37,7 @@ func CreatePrintCommand() *cobra.Command {
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
-		Run: r.run,
+		RunE: r.execute,
 	}
 	r.setupFlags(cmd)
 	return cmd
@@ -50,20 +49,13 @@ type printRunner struct {
 func (r *printRunner) setupFlags(c *cobra.Command) {
 }
 
-func (r *printRunner) run(cmd *cobra.Command, args []string) {
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
-}
-
 func (r *printRunner) execute(cmd *cobra.Command, args []string) (errors error) {
 	reg := registry.New()
 	j, err := journal.FromPath(cmd.Context(), reg, args[0])
 	if err != nil {
 		return err
 	}
-	if err := j.Build().Process(check.Check()); err != nil {
+	if err := j.Build().ProcessWithOptions(flags.Processing(cmd), check.Check()); err != nil {
 		return err
 	}
 	w := bufio.NewWriter(cmd.OutOrStdout())
@@ -0,0 +1,180 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/natefinch/atomic"
+)
+
+// page is a report published as part of a static site. Reports of the chart
+// command are published as an image, CSV reports as a table and all other
+// reports as text.
+type page struct {
+	Title string
+	File  string
+	Text  string
+	Rows  [][]string
+
+	// Image is the file name of a chart, and image is its content.
+	Image string
+	image []byte
+}
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// imageExtension returns the file extension of a chart, or false if res is
+// not a chart.
+func imageExtension(res []byte) (string, bool) {
+	if bytes.HasPrefix(res, pngHeader) {
+		return ".png", true
+	}
+	head := res[:min(len(res), 512)]
+	if bytes.HasPrefix(bytes.TrimSpace(head), []byte("<")) && bytes.Contains(head, []byte("<svg")) {
+		return ".svg", true
+	}
+	return "", false
+}
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+	colorCodes      = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+func newPage(cfg reportConfig, res []byte) page {
+	title := cfg.Name
+	if title == "" {
+		title = strings.Join(cfg.Args, " ")
+	}
+	p := page{
+		Title: title,
+		File:  strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(title), "-"), "-") + ".html",
+	}
+	if ext, ok := imageExtension(res); ok {
+		p.Image = strings.TrimSuffix(p.File, ".html") + ext
+		p.image = res
+		return p
+	}
+	if strings.HasSuffix(cfg.Output, ".csv") || slices.Contains(cfg.Args, "--csv") {
+		if rows, err := csv.NewReader(bytes.NewReader(res)).ReadAll(); err == nil {
+			p.Rows = rows
+			return p
+		}
+	}
+	p.Text = colorCodes.ReplaceAllString(string(res), "")
+	return p
+}
+
+var (
+	indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Reports</title>
+</head>
+<body>
+<h1>Reports</h1>
+<ul>
+{{- range .Pages}}
+<li><a href="{{.File}}">{{.Title}}</a></li>
+{{- end}}
+</ul>
+<p>Generated {{.Generated}}</p>
+</body>
+</html>
+`))
+
+	pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.6em; border: 1px solid #ccc; }
+td { text-align: right; }
+td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<p><a href="index.html">Reports</a></p>
+<h1>{{.Title}}</h1>
+{{- if .Image}}
+<img src="{{.Image}}" alt="{{.Title}}">
+{{- else if .Rows}}
+<table>
+{{- range $i, $row := .Rows}}
+<tr>{{range $row}}{{if eq $i 0}}<th>{{.}}</th>{{else}}<td>{{.}}</td>{{end}}{{end}}</tr>
+{{- end}}
+</table>
+{{- else}}
+<pre>{{.Text}}</pre>
+{{- end}}
+</body>
+</html>
+`))
+)
+
+// publish writes the pages, their charts and an index to dir.
+func publish(dir string, pages []page) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	seen := make(map[string]int)
+	for i := range pages {
+		if n := seen[pages[i].File]; n > 0 {
+			name := fmt.Sprintf("%s-%d", strings.TrimSuffix(pages[i].File, ".html"), n)
+			pages[i].File = name + ".html"
+			if pages[i].Image != "" {
+				pages[i].Image = name + filepath.Ext(pages[i].Image)
+			}
+		}
+		seen[pages[i].File]++
+		if pages[i].Image != "" {
+			if err := atomic.WriteFile(filepath.Join(dir, pages[i].Image), bytes.NewReader(pages[i].image)); err != nil {
+				return err
+			}
+		}
+		var buf bytes.Buffer
+		if err := pageTemplate.Execute(&buf, pages[i]); err != nil {
+			return err
+		}
+		if err := atomic.WriteFile(filepath.Join(dir, pages[i].File), &buf); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	err := indexTemplate.Execute(&buf, struct {
+		Pages     []page
+		Generated string
+	}{
+		Pages:     pages,
+		Generated: time.Now().Format("2006-01-02 15:04"),
+	})
+	if err != nil {
+		return err
+	}
+	return atomic.WriteFile(filepath.Join(dir, "index.html"), &buf)
+}
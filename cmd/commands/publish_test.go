@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRunPublish(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	text := `2024-01-01 open Assets:Bank
+2024-01-01 open Income:Salary
+
+2024-01-25 "Salary"
+Income:Salary Assets:Bank 5000 CHF
+
+2024-02-25 "Salary"
+Income:Salary Assets:Bank 5000 CHF
+`
+	if err := os.WriteFile(journal, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "reports.yaml")
+	reports := fmt.Sprintf(`
+- name: Balance
+  args: [balance, --csv, %[1]q]
+- name: Net worth
+  args: [chart, --val, CHF, --interval, monthly, %[1]q]
+- name: Balance
+  args: [balance, %[1]q]
+`, journal)
+	if err := os.WriteFile(config, []byte(reports), 0644); err != nil {
+		t.Fatal(err)
+	}
+	site := filepath.Join(dir, "site")
+	newRoot := func() *cobra.Command {
+		c := &cobra.Command{Use: "knut"}
+		c.AddCommand(CreateBalanceCommand(), CreateChartCommand())
+		return c
+	}
+	c := CreateRunCommand(newRoot)
+	c.SetArgs([]string{"--publish", site, config})
+	c.SetOut(&bytes.Buffer{})
+	c.SetErr(&bytes.Buffer{})
+
+	if err := c.Execute(); err != nil {
+		t.Fatalf("run returned unexpected error: %v", err)
+	}
+
+	for file, want := range map[string]string{
+		"index.html":     `<a href="net-worth.html">Net worth</a>`,
+		"balance.html":   "<table>",
+		"net-worth.html": `<img src="net-worth.svg" alt="Net worth">`,
+		"net-worth.svg":  "<svg",
+		"balance-1.html": "<pre>",
+	} {
+		got, err := os.ReadFile(filepath.Join(site, file))
+		if err != nil {
+			t.Errorf("%s was not published: %v", file, err)
+			continue
+		}
+		if !strings.Contains(string(got), want) {
+			t.Errorf("%s does not contain %q:\n%s", file, want, got)
+		}
+	}
+}
+
+func TestImageExtension(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{input: "\x89PNG\r\n\x1a\n...", want: ".png", ok: true},
+		{input: `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`, want: ".svg", ok: true},
+		{input: "Account,Comm,2024-01-31\n"},
+		{input: "| <svg> |\n"},
+	} {
+		got, ok := imageExtension([]byte(test.input))
+		if got != test.want || ok != test.ok {
+			t.Errorf("imageExtension(%q) = %q, %t, want %q, %t", test.input, got, ok, test.want, test.ok)
+		}
+	}
+}
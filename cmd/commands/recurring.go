@@ -0,0 +1,172 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"slices"
+	"time"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/recurring"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateRecurringCommand creates the command.
+func CreateRecurringCommand() *cobra.Command {
+
+	var r recurringRunner
+
+	c := &cobra.Command{
+		Use:   "recurring",
+		Short: "detect subscriptions and standing orders",
+		Long: `Detect recurring payments, such as subscriptions and standing orders, by clustering
+the bookings of expense accounts by payee and looking for weekly, monthly, quarterly or
+yearly periodicity. Payees are derived from the descriptions, ignoring case, digits and
+punctuation. Recurring payments are listed with their estimated annual cost.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type recurringRunner struct {
+	period      flags.PeriodFlag
+	minCount    int
+	all         bool
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+
+	// mapping
+	mapping flags.MappingFlag
+	remap   flags.RegexFlag
+
+	// filters
+	accounts    flags.RegexFlag
+	commodities flags.RegexFlag
+
+	// formatting
+	thousands bool
+	colors    flags.Colors
+	digits    int32
+	rounding  flags.Rounding
+}
+
+func (r *recurringRunner) setupFlags(c *cobra.Command) {
+	r.period.Setup(c, date.Period{End: date.Today()})
+	c.Flags().IntVar(&r.minCount, "min-count", 3, "minimum number of bookings of a recurring payment")
+	c.Flags().BoolVar(&r.all, "all", false, "include recurring payments which have ended")
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
+	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
+	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
+	c.Flags().Var(&r.accounts, "account", "consider the accounts matching the regex instead of expense accounts")
+	c.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	c.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(c)
+	c.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
+	r.colors.Setup(c)
+}
+
+func (r *recurringRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	roundingMode, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	period := r.period.Value().Clip(b.Period())
+	accounts := amounts.AccountTypeIs(account.EXPENSES)
+	if len(r.accounts.Regex()) > 0 {
+		accounts = amounts.AccountMatches(r.accounts.Regex())
+	}
+	rep := recurring.NewReport()
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ComputePricesWithPolicy(b, valuation, pricePolicy),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
+		journal.Filter(date.NewPartition(period, date.Once, 0)),
+		journal.Query{
+			Select: amounts.KeyMapper{
+				Date: mapper.Identity[time.Time],
+				Account: mapper.Sequence(
+					account.Remap(reg.Accounts(), r.remap.Regex()),
+					account.Shorten(reg.Accounts(), mapping),
+				),
+				Commodity:   commodity.IdentityIf(valuation == nil),
+				Description: mapper.Identity[string],
+			}.Build(),
+			Where: predicate.And(
+				accounts,
+				amounts.CommodityMatches(r.commodities.Regex()),
+			),
+			Valuation: valuation,
+		}.Into(rep),
+	)
+	if err != nil {
+		return err
+	}
+	rs := rep.Detect(r.minCount)
+	if !r.all {
+		rs = slices.DeleteFunc(rs, func(rec recurring.Recurrence) bool {
+			return rec.Ended(period.End)
+		})
+	}
+	reportRenderer := recurring.Renderer{
+		ShowCommodities: valuation == nil,
+	}
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	tableRenderer := table.TextRenderer{
+		Color:     color,
+		Theme:     theme,
+		Thousands: r.thousands,
+		Round:     r.digits,
+		Rounding:  roundingMode,
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return tableRenderer.Render(reportRenderer.Render(rs), out)
+}
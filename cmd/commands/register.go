@@ -17,12 +17,13 @@ package commands
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"os"
 	"runtime/pprof"
+	"time"
 
 	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/predicate"
 	"github.com/sboehler/knut/lib/common/table"
@@ -32,6 +33,7 @@ import (
 	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/recurring"
 	"github.com/sboehler/knut/lib/reports/register"
 
 	"github.com/spf13/cobra"
@@ -48,7 +50,7 @@ func CreateRegisterCmd() *cobra.Command {
 		Short:  "create a register sheet",
 		Long:   `Compute a register report.`,
 		Args:   cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-		Run:    r.run,
+		RunE:   r.run,
 		Hidden: true,
 	}
 	r.setupFlags(c)
@@ -65,31 +67,38 @@ type registerRunner struct {
 	showCommodities               bool
 	showSource                    bool
 	showDescriptions              bool
+	showComments                  bool
+	showTrades                    bool
+	subtotals                     bool
+	virtual                       bool
 	mapping                       flags.MappingFlag
+	mapFile                       flags.MapFileFlag
 	remap                         flags.RegexFlag
 	valuation                     flags.CommodityFlag
+	pricePolicy                   flags.PricePolicy
 	accounts, others, commodities flags.RegexFlag
+	links                         flags.RegexFlag
+	groupBy                       string
 
 	// formatting
-	thousands, color   bool
+	thousands          bool
+	colors             flags.Colors
 	sortAlphabetically bool
 	digits             int32
+	rounding           flags.Rounding
+	format             string
 }
 
-func (r *registerRunner) run(cmd *cobra.Command, args []string) {
+func (r *registerRunner) run(cmd *cobra.Command, args []string) error {
 	if r.cpuprofile != "" {
-		f, err := os.Create(r.cpuprofile)
+		f, err := os.Create(env.Path(cmd.Context(), r.cpuprofile))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
-
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintf(cmd.ErrOrStderr(), "%+v\n", err)
-		os.Exit(1)
-	}
+	return r.execute(cmd, args)
 }
 
 func (r *registerRunner) setupFlags(c *cobra.Command) {
@@ -98,16 +107,26 @@ func (r *registerRunner) setupFlags(c *cobra.Command) {
 	c.Flags().BoolVarP(&r.sortAlphabetically, "sort", "s", false, "Sort accounts alphabetically")
 	c.Flags().BoolVarP(&r.showCommodities, "show-commodities", "c", false, "Show commodities")
 	c.Flags().BoolVarP(&r.showDescriptions, "show-descriptions", "d", false, "Show descriptions")
+	c.Flags().BoolVar(&r.showComments, "show-comments", false, "Show posting comments")
 	c.Flags().BoolVarP(&r.showSource, "show-source", "a", false, "Show the source accounts")
+	c.Flags().BoolVar(&r.showTrades, "trades", false, "Show quantity, cost per unit of the lot and value per row (requires --val)")
+	c.Flags().BoolVar(&r.subtotals, "subtotal", false, "Show daily rows grouped by period, with a subtotal per period and a grand total")
+	c.Flags().BoolVar(&r.virtual, "virtual", false, "Include virtual postings")
 	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
 	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
+	r.mapFile.Setup(c)
 	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
 	c.Flags().Var(&r.accounts, "source", "filter source accounts with a regex")
 	c.Flags().Var(&r.others, "dest", "filter dest accounts with a regex")
 	c.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	c.Flags().Var(&r.links, "link", "show only transactions with a link matching the regex")
+	c.Flags().StringVar(&r.groupBy, "group-by", "", "sum the amounts per payee or description instead of per dest account (payee or description)")
 	c.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(c)
 	c.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
-	c.Flags().BoolVar(&r.color, "color", true, "print output in color")
+	c.Flags().StringVar(&r.format, "format", "text", "output format (text, html, csv or json)")
+	r.colors.Setup(c)
 }
 
 func (r registerRunner) execute(cmd *cobra.Command, args []string) error {
@@ -117,8 +136,46 @@ func (r registerRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	r.showCommodities = r.showCommodities || valuation == nil
-	b, err := journal.FromPath(ctx, reg, args[0])
+	roundingMode, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	if r.showTrades && valuation == nil {
+		return fmt.Errorf("--trades requires a valuation commodity")
+	}
+	var descriptions mapper.Mapper[string]
+	switch r.groupBy {
+	case "":
+		descriptions = mapper.IdentityIf[string](r.showDescriptions)
+	case "payee":
+		descriptions = recurring.Payee
+	case "description":
+		descriptions = mapper.Identity[string]
+	default:
+		return fmt.Errorf("invalid --group-by %q, want payee or description", r.groupBy)
+	}
+	if r.groupBy != "" && r.showTrades {
+		return fmt.Errorf("--trades is not supported with --group-by")
+	}
+	streamed := r.format == "csv" || r.format == "json"
+	if streamed && (r.showTrades || r.subtotals) {
+		return fmt.Errorf("--trades and --subtotal are not supported with --format %s", r.format)
+	}
+	r.showCommodities = r.showCommodities || valuation == nil || r.showTrades
+	b, err := journal.FromPathUntil(ctx, reg, args[0], r.Multiperiod.Until(pricePolicy))
+	if err != nil {
+		return err
+	}
+	// Targets may have account types declared in the journal.
+	substitute, err := r.mapFile.Value(cmd.Context(), reg)
+	if err != nil {
+		return err
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
 	if err != nil {
 		return err
 	}
@@ -127,49 +184,121 @@ func (r registerRunner) execute(cmd *cobra.Command, args []string) error {
 		am = account.Remap(reg.Accounts(), r.remap.Regex())
 	}
 	partition := r.Multiperiod.Partition(b.Period())
-	rep := register.NewReport(reg)
+	align := partition.Align()
+	if r.subtotals {
+		// Rows are grouped by period when rendering.
+		align = mapper.Identity[time.Time]
+	}
+	// Grouped rows are summed over the dest accounts.
+	var om mapper.Mapper[*model.Account]
+	if r.groupBy == "" {
+		om = mapper.Sequence(
+			account.Remap(reg.Accounts(), r.remap.Regex()),
+			substitute,
+			account.Shorten(reg.Accounts(), mapping),
+		)
+	}
 	j := b.Build()
-	err = j.Process(
-		journal.Sort(),
-		journal.ComputePrices(valuation),
-		check.Check(),
-		journal.Valuate(reg, valuation),
-		journal.Filter(partition),
-		journal.Query{
-			Select: amounts.KeyMapper{
-				Date:    partition.Align(),
-				Account: am,
-				Other: mapper.Sequence(
-					account.Remap(reg.Accounts(), r.remap.Regex()),
-					account.Shorten(reg.Accounts(), r.mapping.Value()),
-				),
-				Commodity:   commodity.IdentityIf(r.showCommodities),
-				Valuation:   mapper.Identity[*commodity.Commodity],
-				Description: mapper.IdentityIf[string](r.showDescriptions),
-			}.Build(),
-			Where: predicate.And(
-				amounts.AccountMatches(r.accounts.Regex()),
-				amounts.OtherAccountMatches(r.others.Regex()),
-				amounts.CommodityMatches(r.commodities.Regex()),
-			),
-			Valuation: valuation,
-		}.Into(rep),
-	)
-	if err != nil {
-		return err
+	query := journal.Query{
+		Select: amounts.KeyMapper{
+			Date:        align,
+			Account:     am,
+			Other:       om,
+			Commodity:   commodity.IdentityIf(r.showCommodities),
+			Valuation:   mapper.Identity[*commodity.Commodity],
+			Description: descriptions,
+			Comment:     mapper.IdentityIf[string](r.showComments),
+			Virtual:     mapper.Identity[bool],
+		}.Build(),
+		Where: predicate.And(
+			amounts.AccountMatches(r.accounts.Regex()),
+			amounts.OtherAccountMatches(r.others.Regex()),
+			amounts.CommodityMatches(r.commodities.Regex()),
+		),
+		Valuation: valuation,
+		Virtual:   r.virtual,
 	}
 	reportRenderer := register.Renderer{
 		ShowCommodities:    r.showCommodities,
-		ShowDescriptions:   r.showDescriptions,
+		ShowDescriptions:   r.showDescriptions && r.groupBy == "",
+		ShowComments:       r.showComments,
 		ShowSource:         r.showSource,
+		ShowTrades:         r.showTrades,
 		SortAlphabetically: r.sortAlphabetically,
+		ByDescription:      r.groupBy != "",
 	}
-	tableRenderer := table.TextRenderer{
-		Color:     r.color,
-		Thousands: r.thousands,
-		Round:     r.digits,
+	if r.subtotals {
+		reportRenderer.Periods = partition.Align()
 	}
 	out := bufio.NewWriter(cmd.OutOrStdout())
 	defer out.Flush()
+	if streamed {
+		// Rows are written as the journal is processed, without building
+		// the report in memory.
+		var stream *register.Stream
+		if r.format == "csv" {
+			stream = reportRenderer.NewCSVStream(out)
+		} else {
+			stream = reportRenderer.NewJSONStream(out)
+		}
+		err = j.ProcessWithOptions(flags.Processing(cmd),
+			journal.Sort(),
+			journal.ComputePricesWithPolicy(b, valuation, pricePolicy),
+			journal.ApplyValues(reg),
+			check.Check(),
+			journal.ValuateWithRounding(reg, valuation, valuationRounding),
+			journal.Filter(partition),
+			journal.FilterLinks(r.links.Regex()),
+			query.Into(stream),
+		)
+		if err != nil {
+			return err
+		}
+		return stream.Close()
+	}
+	rep := register.NewReport(reg)
+	rep.Grow(partition.Size())
+	var trades *journal.Processor
+	if r.showTrades {
+		trades = query.IntoPostings(rep.Trades())
+	}
+	err = j.ProcessWithOptions(flags.Processing(cmd),
+		journal.Sort(),
+		journal.ComputePricesWithPolicy(b, valuation, pricePolicy),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
+		journal.Filter(partition),
+		journal.FilterLinks(r.links.Regex()),
+		query.Into(rep),
+		trades,
+	)
+	if err != nil {
+		return err
+	}
+	var tableRenderer Renderer
+	switch r.format {
+	case "html":
+		tableRenderer = &table.HTMLRenderer{
+			Title:     "Register",
+			Thousands: r.thousands,
+			Round:     r.digits,
+			Rounding:  roundingMode,
+		}
+	case "text":
+		color, theme, err := r.colors.Value(cmd)
+		if err != nil {
+			return err
+		}
+		tableRenderer = &table.TextRenderer{
+			Color:     color,
+			Theme:     theme,
+			Thousands: r.thousands,
+			Round:     r.digits,
+			Rounding:  roundingMode,
+		}
+	default:
+		return fmt.Errorf("invalid format %q, want text, html, csv or json", r.format)
+	}
 	return tableRenderer.Render(reportRenderer.Render(rep), out)
 }
@@ -0,0 +1,109 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/journal/claims"
+	"github.com/sboehler/knut/lib/journal/printer"
+	"github.com/sboehler/knut/lib/model/registry"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateReimburseCommand creates the command.
+func CreateReimburseCommand() *cobra.Command {
+
+	var r reimburseRunner
+
+	c := &cobra.Command{
+		Use:   "reimburse",
+		Short: "compute reimbursement claims from tagged postings",
+		Long: `Compute reimbursement claims (mileage, per diem, expenses) from postings whose
+comment carries a tag such as #km:42, according to a rules file. Prints the claim
+report, or the transaction booking the claim as a receivable with --book.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type reimburseRunner struct {
+	period      flags.PeriodFlag
+	rules       string
+	book        bool
+	description string
+	colors      flags.Colors
+}
+
+func (r *reimburseRunner) setupFlags(c *cobra.Command) {
+	r.period.Setup(c, date.Period{End: date.Today()})
+	c.Flags().StringVar(&r.rules, "rules", "", "YAML file with reimbursement rules")
+	c.Flags().BoolVar(&r.book, "book", false, "print the receivable transaction instead of the report")
+	c.Flags().StringVar(&r.description, "description", "Reimbursement claim", "description of the receivable transaction")
+	r.colors.Setup(c)
+	c.MarkFlagRequired("rules")
+}
+
+func (r *reimburseRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	rules, err := claims.LoadRulesFromFile(reg, env.Path(cmd.Context(), r.rules))
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	period := r.period.Value()
+	rep := claims.NewReport(rules)
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.Filter(date.NewPartition(period.Clip(b.Period()), date.Once, 0)),
+		rep.Collect(),
+	)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	if r.book {
+		t := rep.Transaction(period.End, r.description)
+		if t == nil {
+			return nil
+		}
+		_, err := printer.New(out).PrintDirectiveLn(t)
+		return err
+	}
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	tableRenderer := table.TextRenderer{
+		Color: color,
+		Theme: theme,
+		Round: 2,
+	}
+	return tableRenderer.Render(claims.Renderer{}.Render(rep), out)
+}
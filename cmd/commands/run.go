@@ -0,0 +1,155 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"github.com/sboehler/knut/lib/common/env"
+
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/natefinch/atomic"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// CreateRunCommand creates the command. newRoot must return a fresh root
+// command, which is used to execute each report.
+func CreateRunCommand(newRoot func() *cobra.Command) *cobra.Command {
+	runner := runRunner{newRoot: newRoot}
+	c := &cobra.Command{
+		Use:   "run",
+		Short: "run a batch of reports",
+		Long: `Run a batch of reports based on the supplied configuration in yaml format. Each report
+consists of the arguments to a knut command and an output file, which is resolved relative
+to the configuration file. With --publish, the reports are additionally written as a static
+HTML site, with a page for each report and an index. Reports of the chart command are
+published as images. See doc/reports.yaml for an example.
+
+A failing report does not stop the batch. The failed reports are listed at the end, and
+the command exits with a nonzero code.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: runner.execute,
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	runner.setupFlags(c)
+	return c
+}
+
+type runRunner struct {
+	newRoot func() *cobra.Command
+	publish string
+}
+
+func (r *runRunner) setupFlags(c *cobra.Command) {
+	c.Flags().StringVar(&r.publish, "publish", "", "write the reports as a static HTML site to the given directory")
+}
+
+type reportConfig struct {
+	Name   string   `yaml:"name"`
+	Args   []string `yaml:"args"`
+	Output string   `yaml:"output"`
+}
+
+func (r *runRunner) execute(cmd *cobra.Command, args []string) error {
+	configs, err := r.readConfig(env.Path(cmd.Context(), args[0]))
+	if err != nil {
+		return err
+	}
+	var (
+		pages  []page
+		failed []string
+	)
+	// A failing report does not abort the batch, such that the other reports
+	// are still written.
+	for _, cfg := range configs {
+		res, err := r.runReport(cmd, cfg)
+		if err == nil {
+			if r.publish != "" {
+				pages = append(pages, newPage(cfg, res))
+			}
+			err = r.writeOutput(cmd, args[0], cfg, res)
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "report %s: %v\n", cfg.Name, err)
+			failed = append(failed, cfg.Name)
+		}
+	}
+	if r.publish != "" {
+		if err := publish(env.Path(cmd.Context(), r.publish), pages); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d reports failed: %s", len(failed), len(configs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (r *runRunner) runReport(cmd *cobra.Command, cfg reportConfig) ([]byte, error) {
+	if len(cfg.Args) == 0 {
+		return nil, fmt.Errorf("no arguments given")
+	}
+	if cfg.Args[0] == cmd.Name() {
+		return nil, fmt.Errorf("nested %s commands are not supported", cmd.Name())
+	}
+	var buf bytes.Buffer
+	c := r.newRoot()
+	c.SetArgs(cfg.Args)
+	c.SetOut(&buf)
+	c.SetErr(cmd.ErrOrStderr())
+	c.SetContext(cmd.Context())
+	if err := c.Execute(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *runRunner) writeOutput(cmd *cobra.Command, f string, cfg reportConfig, res []byte) error {
+	if cfg.Output == "" {
+		if r.publish != "" {
+			return nil
+		}
+		_, err := cmd.OutOrStdout().Write(res)
+		return err
+	}
+	output := env.Path(cmd.Context(), filepath.Join(filepath.Dir(f), cfg.Output))
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	return atomic.WriteFile(output, bytes.NewReader(res))
+}
+
+func (r *runRunner) readConfig(path string) ([]reportConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	var t []reportConfig
+	if err := dec.Decode(&t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
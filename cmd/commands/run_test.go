@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newTestRoot() *cobra.Command {
+	c := &cobra.Command{Use: "knut", SilenceErrors: true, SilenceUsage: true}
+	c.AddCommand(CreateBalanceCommand())
+	return c
+}
+
+func TestRunContinuesAfterFailedReport(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	if err := os.WriteFile(journal, []byte("2024-01-01 open Assets:Bank\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "reports.yaml")
+	reports := fmt.Sprintf(`
+- name: missing
+  args: [balance, %q]
+  output: missing.txt
+- name: balance
+  args: [balance, %q]
+  output: balance.txt
+`, filepath.Join(dir, "missing.knut"), journal)
+	if err := os.WriteFile(config, []byte(reports), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := CreateRunCommand(newTestRoot)
+	c.SetArgs([]string{config})
+	var stderr bytes.Buffer
+	c.SetOut(&bytes.Buffer{})
+	c.SetErr(&stderr)
+
+	err := c.Execute()
+
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 reports failed: missing") {
+		t.Fatalf("Execute() returned %v, want a summary of the failed reports", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "balance.txt")); err != nil {
+		t.Errorf("report after the failed report was not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Errorf("failed report was written")
+	}
+	if got := stderr.String(); !strings.Contains(got, "report missing: stat "+filepath.Join(dir, "missing.knut")) {
+		t.Errorf("stderr = %q, want the error of the failed report", got)
+	}
+}
+
+func TestRunRejectsNestedRun(t *testing.T) {
+	r := runRunner{newRoot: newTestRoot}
+	c := CreateRunCommand(newTestRoot)
+
+	if _, err := r.runReport(c, reportConfig{Name: "nested", Args: []string{"run", "reports.yaml"}}); err == nil {
+		t.Fatalf("runReport() returned no error for a nested run command")
+	}
+}
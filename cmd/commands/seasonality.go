@@ -0,0 +1,168 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/seasonality"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateSeasonalityCommand creates the command.
+func CreateSeasonalityCommand() *cobra.Command {
+
+	var r seasonalityRunner
+
+	c := &cobra.Command{
+		Use:   "seasonality",
+		Short: "aggregate expenses by weekday or calendar month",
+		Long: `Aggregate expenses by weekday or by calendar month across all years in the
+given period, to spot recurring spending patterns. With --average, each season shows
+the average per occurrence in the period, e.g. per Monday or per January, instead of
+the sum.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type seasonalityRunner struct {
+	period      flags.PeriodFlag
+	by          string
+	average     bool
+	valuation   flags.CommodityFlag
+	pricePolicy flags.PricePolicy
+
+	// mapping
+	mapping flags.MappingFlag
+	remap   flags.RegexFlag
+
+	// filters
+	accounts    flags.RegexFlag
+	commodities flags.RegexFlag
+
+	// formatting
+	thousands bool
+	colors    flags.Colors
+	digits    int32
+	rounding  flags.Rounding
+}
+
+func (r *seasonalityRunner) setupFlags(c *cobra.Command) {
+	r.period.Setup(c, date.Period{End: date.Today()})
+	c.Flags().StringVar(&r.by, "by", "month", "group by weekday or month")
+	c.Flags().BoolVar(&r.average, "average", false, "show the average per occurrence of each season instead of the sum")
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.pricePolicy.Setup(c)
+	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
+	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
+	c.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
+	c.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	c.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	r.rounding.Setup(c)
+	c.Flags().BoolVarP(&r.thousands, "thousands", "k", false, "show numbers in units of 1000")
+	r.colors.Setup(c)
+}
+
+func (r *seasonalityRunner) execute(cmd *cobra.Command, args []string) error {
+	cycle, err := date.ParseCycle(r.by)
+	if err != nil {
+		return err
+	}
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	roundingMode, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
+	pricePolicy, err := r.pricePolicy.Value(cmd, reg)
+	if err != nil {
+		return err
+	}
+	b, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	mapping, err := r.mapping.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	period := r.period.Value().Clip(b.Period())
+	partition := date.NewPartition(period, date.Once, 0)
+	rep := seasonality.NewReport(cycle)
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ComputePricesWithPolicy(b, valuation, pricePolicy),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
+		journal.Filter(partition),
+		journal.Query{
+			Select: amounts.KeyMapper{
+				Account: mapper.Sequence(
+					account.Remap(reg.Accounts(), r.remap.Regex()),
+					account.Shorten(reg.Accounts(), mapping),
+				),
+				Commodity: commodity.IdentityIf(valuation == nil),
+				Season:    cycle.Season,
+			}.Build(),
+			Where: predicate.And(
+				amounts.AccountTypeIs(account.EXPENSES),
+				amounts.AccountMatches(r.accounts.Regex()),
+				amounts.CommodityMatches(r.commodities.Regex()),
+			),
+			Valuation: valuation,
+		}.Into(rep),
+	)
+	if err != nil {
+		return err
+	}
+	reportRenderer := seasonality.Renderer{
+		ShowCommodities: valuation == nil,
+	}
+	if r.average {
+		reportRenderer.Occurrences = cycle.Occurrences(period)
+	}
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	tableRenderer := table.TextRenderer{
+		Color:     color,
+		Theme:     theme,
+		Thousands: r.thousands,
+		Round:     r.digits,
+		Rounding:  roundingMode,
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return tableRenderer.Render(reportRenderer.Render(rep), out)
+}
@@ -0,0 +1,312 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sboehler/knut/cmd/daemon"
+	"github.com/sboehler/knut/lib/common/cpr"
+	"github.com/sboehler/knut/lib/reports/query"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sourcegraph/conc/pool"
+	"github.com/spf13/cobra"
+)
+
+// CreateServeCommand creates the command. newRoot must return a fresh root
+// command, which is used to compute each report.
+func CreateServeCommand(newRoot func() *cobra.Command) *cobra.Command {
+	runner := serveRunner{newRoot: newRoot}
+	c := &cobra.Command{
+		Use:   "serve journal",
+		Short: "serve reports over HTTP",
+		Long: `Serve the balance and the register of a journal as HTML pages. Both pages accept the
+query parameters account, descendants, commodity, from, to, interval and val. The cells of
+the balance link to the register listing the bookings behind them. Parsed files are kept in
+memory, and only files which have changed are parsed again.
+
+The endpoint /events streams server-sent events. The files of the journal are polled, and a
+change event is sent to all connected clients after the journal has been parsed again, such
+that dashboards can refresh. The served pages subscribe to it and reload themselves.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: runner.execute,
+	}
+	runner.setupFlags(c)
+	return c
+}
+
+type serveRunner struct {
+	newRoot func() *cobra.Command
+	addr    string
+	poll    time.Duration
+}
+
+func (r *serveRunner) setupFlags(c *cobra.Command) {
+	c.Flags().StringVar(&r.addr, "addr", "localhost:8080", "address to listen on")
+	c.Flags().DurationVar(&r.poll, "poll", time.Second, "interval at which the journal files are checked for changes")
+}
+
+func (r *serveRunner) execute(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	cache := syntax.NewCache()
+	w := newWatcher(args[0], cache)
+	w.parse(ctx)
+	go w.run(ctx, r.poll)
+	s := &http.Server{
+		Addr:    r.addr,
+		Handler: newReportHandler(args[0], &daemon.Server{NewRoot: r.newRoot, Cache: cache}, w),
+	}
+	go func() {
+		<-ctx.Done()
+		s.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(cmd.ErrOrStderr(), "listening on http://%s\n", r.addr)
+	if err := s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newReportHandler returns a handler serving the reports of the given
+// journal, which are computed by executing knut commands, and the events of
+// the watcher.
+func newReportHandler(journal string, s *daemon.Server, w *watcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		http.Redirect(w, req, "/balance", http.StatusFound)
+	})
+	mux.HandleFunc("/balance", func(w http.ResponseWriter, req *http.Request) {
+		serveReport(w, req, s, func(q query.Params) []string {
+			args := []string{"balance", journal, "--format", "html", "--drill-down", "/register"}
+			return append(args, q.BalanceArgs()...)
+		})
+	})
+	mux.HandleFunc("/register", func(w http.ResponseWriter, req *http.Request) {
+		serveReport(w, req, s, func(q query.Params) []string {
+			args := []string{"register", journal, "--format", "html"}
+			return append(args, q.RegisterArgs()...)
+		})
+	})
+	mux.Handle("/events", w)
+	return mux
+}
+
+func serveReport(w http.ResponseWriter, req *http.Request, s *daemon.Server, args func(query.Params) []string) {
+	q, err := query.Parse(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stdout, stderr, code := s.Execute(args(q))
+	if code != 0 {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<pre>%s</pre>\n", html.EscapeString(string(stderr)))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if i := bytes.LastIndex(stdout, []byte("</body>")); i >= 0 {
+		w.Write(stdout[:i])
+		io.WriteString(w, reloadScript)
+		stdout = stdout[i:]
+	}
+	w.Write(stdout)
+}
+
+// reloadScript reloads a served page when the journal has changed.
+const reloadScript = `<script>new EventSource("/events").addEventListener("change", () => location.reload());</script>
+`
+
+// watcher polls the files of a journal and notifies its clients when the
+// journal has been parsed again after a file has changed.
+type watcher struct {
+	journal string
+	cache   *syntax.Cache
+
+	mu      sync.Mutex
+	files   map[string]fileStamp
+	clients map[chan event]bool
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// event is sent to the clients after the journal has been parsed. Error is
+// the parse error, if any.
+type event struct {
+	Error string `json:"error,omitempty"`
+}
+
+func newWatcher(journal string, cache *syntax.Cache) *watcher {
+	return &watcher{
+		journal: journal,
+		cache:   cache,
+		files:   make(map[string]fileStamp),
+		clients: make(map[chan event]bool),
+	}
+}
+
+func (w *watcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.changed() {
+				w.notify(w.parse(ctx))
+			}
+		}
+	}
+}
+
+// changed returns whether a file of the journal has changed since it has
+// been parsed.
+func (w *watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for file, stamp := range w.files {
+		// A missing file has a zero stamp.
+		if s, _ := stat(file); s != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+// parse parses the journal and records the files it consists of. Unchanged
+// files are taken from the cache, which is shared with the reports.
+func (w *watcher) parse(ctx context.Context) event {
+	ctx, release := w.cache.Lease(ctx)
+	defer release()
+	files := make(map[string]fileStamp)
+	ch, worker := syntax.ParseFileRecursively(w.journal)
+	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
+	p.Go(worker)
+	p.Go(func(ctx context.Context) error {
+		return cpr.ForEach(ctx, ch, func(f syntax.File) error {
+			if s, err := stat(f.Path); err == nil {
+				files[f.Path] = s
+			}
+			return nil
+		})
+	})
+	err := p.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		// The files which have not been parsed are still watched, such that
+		// fixing them triggers another event.
+		for file := range w.files {
+			if _, ok := files[file]; !ok {
+				files[file], _ = stat(file)
+			}
+		}
+		if _, ok := files[w.journal]; !ok {
+			files[w.journal], _ = stat(w.journal)
+		}
+		w.files = files
+		return event{Error: err.Error()}
+	}
+	w.files = files
+	return event{}
+}
+
+func stat(file string) (fileStamp, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+func (w *watcher) notify(e event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for c := range w.clients {
+		// Slow clients miss events rather than blocking the watcher.
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+func (w *watcher) subscribe() chan event {
+	c := make(chan event, 1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.clients[c] = true
+	return c
+}
+
+func (w *watcher) unsubscribe(c chan event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.clients, c)
+}
+
+// ServeHTTP streams the events as server-sent events.
+func (w *watcher) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	c := w.subscribe()
+	defer w.unsubscribe(c)
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case e := <-c:
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(rw, "event: change\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+func TestWatcherEvents(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	included := filepath.Join(dir, "included.knut")
+	write := func(file, text string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(journal, "include \"included.knut\"\n")
+	write(included, "2023-01-01 open Assets:A\n")
+	ctx := context.Background()
+	w := newWatcher(journal, syntax.NewCache())
+	if e := w.parse(ctx); e.Error != "" {
+		t.Fatalf("parse() returned error %s", e.Error)
+	}
+	s := httptest.NewServer(w)
+	defer s.Close()
+	res, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if got := res.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("got content type %q, want text/event-stream", got)
+	}
+	lines := bufio.NewScanner(res.Body)
+	read := func(want ...string) {
+		t.Helper()
+		for _, line := range want {
+			if !lines.Scan() {
+				t.Fatalf("stream ended, want %q", line)
+			}
+			if lines.Text() != line {
+				t.Fatalf("got line %q, want %q", lines.Text(), line)
+			}
+		}
+	}
+
+	if w.changed() {
+		t.Fatalf("changed() = true for unchanged files")
+	}
+	write(included, "2023-01-01 open Assets:A\n2023-01-01 open Assets:B\n")
+	if !w.changed() {
+		t.Fatalf("changed() = false after changing an included file")
+	}
+	w.notify(w.parse(ctx))
+	read("event: change", "data: {}", "")
+
+	write(included, "2023-01-01 foo\n")
+	if !w.changed() {
+		t.Fatalf("changed() = false after breaking an included file")
+	}
+	e := w.parse(ctx)
+	if e.Error == "" {
+		t.Fatalf("parse() returned no error for an invalid file")
+	}
+	w.notify(e)
+	read("event: change")
+	write(included, "2023-01-01 open Assets:A\n")
+	if !w.changed() {
+		t.Fatalf("changed() = false after fixing an included file")
+	}
+}
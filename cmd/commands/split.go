@@ -0,0 +1,171 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/natefinch/atomic"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// CreateSplitCommand creates the command.
+func CreateSplitCommand() *cobra.Command {
+	var r splitRunner
+	c := &cobra.Command{
+		Use:   "split",
+		Short: "split a journal into files per year or month",
+		Long: `Split the given journal into one file per year or month in the output
+directory, and write an index file with the same name as the journal, which
+includes them. Comments are kept with the directive which follows them, and
+directives are formatted. Include, accounttype and commodity directives are
+moved to the index file. Existing files are not overwritten.`,
+
+		Args: cobra.ExactArgs(1),
+
+		RunE: r.execute,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type splitRunner struct {
+	by  string
+	out string
+}
+
+func (r *splitRunner) setupFlags(c *cobra.Command) {
+	c.Flags().StringVar(&r.by, "by", "year", "split by year or month")
+	c.Flags().StringVar(&r.out, "out", "", "output directory")
+	c.MarkFlagRequired("out")
+}
+
+func (r *splitRunner) execute(cmd *cobra.Command, args []string) error {
+	var length int
+	switch r.by {
+	case "year":
+		length = len("2006")
+	case "month":
+		length = len("2006-01")
+	default:
+		return fmt.Errorf("invalid value %q for --by, want year or month", r.by)
+	}
+	f, err := syntax.ParseFile(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	parts, err := syntax.Split(f, func(d syntax.Directive) string {
+		if date, ok := syntax.DateOf(d); ok {
+			return date.Extract()[:length]
+		}
+		return ""
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(parts, func(p1, p2 syntax.Part) int {
+		return strings.Compare(p1.Key, p2.Key)
+	})
+	var (
+		indexName = filepath.Base(args[0])
+		out       = env.Path(cmd.Context(), r.out)
+		index     strings.Builder
+		files     = make(map[string]string)
+	)
+	for _, part := range parts {
+		if part.Key == "" {
+			text, err := rewriteIncludes(part.Text, filepath.Dir(env.Path(cmd.Context(), args[0])), out)
+			if err != nil {
+				return err
+			}
+			index.WriteString(text)
+			index.WriteString("\n")
+			continue
+		}
+		name := part.Key + ".knut"
+		if name == indexName {
+			return fmt.Errorf("the index file %s conflicts with the file for %s", indexName, part.Key)
+		}
+		files[name] = part.Text
+		fmt.Fprintf(&index, "include \"%s\"\n", syntax.Escape(name))
+	}
+	files[indexName] = index.String()
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(out, name)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(r.out, name))
+		}
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	for name, text := range files {
+		if err := atomic.WriteFile(filepath.Join(out, name), strings.NewReader(text)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "wrote %d files to %s\n", len(files), r.out)
+	return nil
+}
+
+// rewriteIncludes makes the paths of the include directives in text, which
+// are relative to dir, relative to the output directory out.
+func rewriteIncludes(text, dir, out string) (string, error) {
+	p := parser.New(text, "")
+	if err := p.Advance(); err != nil {
+		return "", err
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		return "", err
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return "", err
+	}
+	var (
+		b   strings.Builder
+		pos int
+	)
+	for _, d := range f.Directives {
+		inc, ok := d.Directive.(syntax.Include)
+		if !ok {
+			continue
+		}
+		target, err := inc.IncludePath.Parse()
+		if err != nil {
+			return "", err
+		}
+		if target, err = filepath.Abs(filepath.Join(dir, target)); err != nil {
+			return "", err
+		}
+		path, err := filepath.Rel(out, target)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text[pos:inc.IncludePath.Content.Start])
+		b.WriteString(syntax.Escape(filepath.ToSlash(path)))
+		pos = inc.IncludePath.Content.End
+	}
+	b.WriteString(text[pos:])
+	return b.String(), nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/cpr"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/journal/stats"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sourcegraph/conc/pool"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateStatsCommand creates the command.
+func CreateStatsCommand() *cobra.Command {
+
+	var r statsRunner
+
+	c := &cobra.Command{
+		Use:   "stats",
+		Short: "print statistics about a journal",
+		Long:  `Print statistics about a journal, such as the number of directives, gaps between transactions, included files and timings.`,
+		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE:  r.execute,
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type statsRunner struct {
+	gaps   int
+	colors flags.Colors
+	format string
+}
+
+func (r *statsRunner) setupFlags(c *cobra.Command) {
+	c.Flags().IntVar(&r.gaps, "gaps", 5, "number of gaps between transactions to show")
+	r.colors.Setup(c)
+	c.Flags().StringVar(&r.format, "format", "text", "output format (text or json)")
+}
+
+func (r *statsRunner) execute(cmd *cobra.Command, args []string) error {
+	if r.format != "text" && r.format != "json" {
+		return fmt.Errorf("invalid format %q, want text or json", r.format)
+	}
+	reg := registry.New()
+	s := stats.New()
+
+	start := time.Now()
+	files, err := r.parse(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		s.AddFile(f)
+	}
+	s.AddTiming("parse", time.Since(start))
+
+	start = time.Now()
+	b, err := r.build(cmd.Context(), reg, files)
+	if err != nil {
+		return err
+	}
+	s.AddTiming("build", time.Since(start))
+
+	start = time.Now()
+	err = b.Build().ProcessWithOptions(flags.Processing(cmd),
+		journal.Sort(),
+		check.Check(),
+		s.Collect(),
+	)
+	if err != nil {
+		return err
+	}
+	s.AddTiming("process", time.Since(start))
+
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	if r.format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s.Summary(r.gaps, date.Today()))
+	}
+	color, theme, err := r.colors.Value(cmd)
+	if err != nil {
+		return err
+	}
+	tableRenderer := table.TextRenderer{Color: color, Theme: theme}
+	return tableRenderer.Render(stats.Renderer{Gaps: r.gaps}.Render(s), out)
+}
+
+func (r *statsRunner) parse(ctx context.Context, path string) ([]syntax.File, error) {
+	syntaxCh, worker := syntax.ParseFileRecursively(path)
+	var files []syntax.File
+	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
+	p.Go(worker)
+	p.Go(func(ctx context.Context) error {
+		return cpr.ForEach(ctx, syntaxCh, func(f syntax.File) error {
+			files = append(files, f)
+			return nil
+		})
+	})
+	if err := p.Wait(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *statsRunner) build(ctx context.Context, reg *model.Registry, files []syntax.File) (*journal.Builder, error) {
+	fileCh, worker1 := cpr.Produce(func(ctx context.Context, ch chan<- syntax.File) error {
+		return cpr.Push(ctx, ch, files...)
+	})
+	modelCh, worker2 := model.FromStream(reg, fileCh)
+	journalCh, worker3 := journal.FromModelStream(modelCh)
+	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
+	p.Go(worker1)
+	p.Go(worker2)
+	p.Go(worker3)
+	if err := p.Wait(); err != nil {
+		return nil, err
+	}
+	return <-journalCh, nil
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+	"github.com/sboehler/knut/lib/journal/stats"
+)
+
+func TestStatsJSON(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	text := `2024-01-01 open Assets:Bank
+2024-01-01 open Income:Salary
+
+2024-01-25 "Salary"
+Income:Salary Assets:Bank 5000 CHF
+
+2024-03-25 "Salary"
+Income:Salary Assets:Bank 5000 CHF
+
+2024-03-31 balance Assets:Bank 10000 CHF
+`
+	if err := os.WriteFile(journal, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := cmdtest.Run(t, CreateStatsCommand(), "--format", "json", "--gaps", "1", journal)
+
+	var got stats.Summary
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, out)
+	}
+	if got.Transactions != 2 || got.Assertions != 1 {
+		t.Errorf("got %d transactions and %d assertions, want 2 and 1", got.Transactions, got.Assertions)
+	}
+	if got.FirstTransaction != "2024-01-25" || got.LastTransaction != "2024-03-25" {
+		t.Errorf("got transactions from %s to %s, want 2024-01-25 to 2024-03-25", got.FirstTransaction, got.LastTransaction)
+	}
+	if diff := cmp.Diff([]stats.GapSummary{{From: "2024-01-25", To: "2024-03-25", Days: 60}}, got.Gaps); diff != "" {
+		t.Errorf("unexpected gaps (-want/+got):\n%s", diff)
+	}
+	var accounts []string
+	for _, a := range got.Accounts {
+		if a.LastTransaction != "2024-03-25" || a.DaysSince == nil {
+			t.Errorf("account %s: got last transaction %s, want 2024-03-25 with days since", a.Account, a.LastTransaction)
+		}
+		accounts = append(accounts, a.Account)
+	}
+	if diff := cmp.Diff([]string{"Assets:Bank", "Income:Salary"}, accounts); diff != "" {
+		t.Errorf("unexpected accounts (-want/+got):\n%s", diff)
+	}
+}
+
+func TestStatsReturnsErrors(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "journal.knut")
+	if err := os.WriteFile(journal, []byte("2024-01-01 open Assets:Bank\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"--format", "xml", journal},
+		{filepath.Join(t.TempDir(), "missing.knut")},
+	} {
+		c := CreateStatsCommand()
+		c.SetArgs(args)
+		c.SetOut(&bytes.Buffer{})
+		c.SetErr(&bytes.Buffer{})
+
+		if err := c.Execute(); err == nil {
+			t.Errorf("Execute(%v) returned no error", args)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/natefinch/atomic"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// CreateToggleCommand creates the command.
+func CreateToggleCommand() *cobra.Command {
+	var r toggleRunner
+	return &cobra.Command{
+		Use:   "toggle <file>:<line>",
+		Short: "exclude a directive from processing, or include it again",
+		Long: `Exclude the directive at the given line from processing, keeping it in the file, by
+enclosing it in ignore pragmas. If the line lies in an ignored section, the pragmas are
+removed instead. Locations printed by knut, of the form <file>:<line>:<column>, are
+accepted as well.`,
+
+		Args: cobra.ExactArgs(1),
+
+		RunE: r.execute,
+	}
+}
+
+type toggleRunner struct{}
+
+func (r toggleRunner) execute(cmd *cobra.Command, args []string) error {
+	path, line, err := parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+	f, err := syntax.ParseFile(cmd.Context(), path)
+	if err != nil {
+		return err
+	}
+	text, excluded, err := syntax.Toggle(f, line)
+	if err != nil {
+		return err
+	}
+	p := parser.New(text, path)
+	if err := p.Advance(); err != nil {
+		return err
+	}
+	if _, err := p.ParseFile(); err != nil {
+		return fmt.Errorf("toggling %s:%d would break the file, leaving it unchanged: %w", path, line, err)
+	}
+	if err := atomic.WriteFile(env.Path(cmd.Context(), path), strings.NewReader(text)); err != nil {
+		return err
+	}
+	if excluded {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s:%d: excluded\n", path, line)
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s:%d: included\n", path, line)
+	}
+	return nil
+}
+
+// parseLocation parses a location of the form <file>:<line> or
+// <file>:<line>:<column>.
+func parseLocation(s string) (string, int, error) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid location %q, want <file>:<line>", s)
+	}
+	line, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid location %q, want <file>:<line>", s)
+	}
+	if j := strings.LastIndexByte(s[:i], ':'); j >= 0 {
+		if l, err := strconv.Atoi(s[j+1 : i]); err == nil {
+			return s[:j], l, nil
+		}
+	}
+	return s[:i], line, nil
+}
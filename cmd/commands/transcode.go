@@ -16,8 +16,6 @@ package commands
 
 import (
 	"bufio"
-	"fmt"
-	"os"
 
 	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/journal"
@@ -42,7 +40,7 @@ func CreateTranscodeCommand() *cobra.Command {
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
-		Run: r.run,
+		RunE: r.execute,
 	}
 	r.setupFlags(cmd)
 	return cmd
@@ -50,17 +48,12 @@ func CreateTranscodeCommand() *cobra.Command {
 
 type transcodeRunner struct {
 	valuation flags.CommodityFlag
+	rounding  flags.Rounding
 }
 
 func (r *transcodeRunner) setupFlags(c *cobra.Command) {
 	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
-}
-
-func (r *transcodeRunner) run(cmd *cobra.Command, args []string) {
-	if err := r.execute(cmd, args); err != nil {
-		fmt.Fprintln(cmd.ErrOrStderr(), err)
-		os.Exit(1)
-	}
+	r.rounding.Setup(c)
 }
 
 func (r *transcodeRunner) execute(cmd *cobra.Command, args []string) (errors error) {
@@ -72,16 +65,21 @@ func (r *transcodeRunner) execute(cmd *cobra.Command, args []string) (errors err
 	if valuation, err = r.valuation.Value(reg); err != nil {
 		return err
 	}
+	_, valuationRounding, err := r.rounding.Value()
+	if err != nil {
+		return err
+	}
 	b, err := journal.FromPath(cmd.Context(), reg, args[0])
 	if err != nil {
 		return err
 	}
 	j := b.Build()
-	err = j.Process(
+	err = j.ProcessWithOptions(flags.Processing(cmd),
 		journal.Sort(),
 		journal.ComputePrices(valuation),
+		journal.ApplyValues(reg),
 		check.Check(),
-		journal.Valuate(reg, valuation),
+		journal.ValuateWithRounding(reg, valuation, valuationRounding),
 	)
 	if err != nil {
 		return err
@@ -0,0 +1,194 @@
+// Package daemon runs knut commands in a long-running process, which keeps
+// parsed journal files in memory. Command line invocations are forwarded to
+// the daemon if it is running, and executed locally otherwise.
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/spf13/cobra"
+)
+
+// SocketPath returns the path of the daemon socket. It can be overridden
+// with the KNUT_SOCKET environment variable.
+func SocketPath() string {
+	if p := os.Getenv("KNUT_SOCKET"); p != "" {
+		return p
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("knut-%d.sock", os.Getuid()))
+}
+
+type request struct {
+	Args     []string       `json:"args"`
+	Dir      string         `json:"dir"`
+	Env      []string       `json:"env"`
+	Terminal table.Terminal `json:"terminal"`
+}
+
+type response struct {
+	Stdout []byte `json:"stdout"`
+	Stderr []byte `json:"stderr"`
+	Code   int    `json:"code"`
+}
+
+// Server executes forwarded commands, one at a time. A command is executed
+// in the environment of the client, which is passed to it in its context.
+type Server struct {
+	// NewRoot must return a fresh root command for each request.
+	NewRoot func() *cobra.Command
+
+	// Cache holds the files parsed by the commands. Serve creates a cache
+	// if it is nil.
+	Cache *syntax.Cache
+
+	mu sync.Mutex
+}
+
+// Serve listens on the given socket path and serves requests until the
+// context is canceled.
+func (s *Server) Serve(ctx context.Context, path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	if s.Cache == nil {
+		s.Cache = syntax.NewCache()
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	json.NewEncoder(conn).Encode(s.execute(req))
+}
+
+func (s *Server) execute(req request) (res response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stdout, stderr bytes.Buffer
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(&stderr, "panic: %v\n", r)
+			res.Code = 2
+		}
+		res.Stdout, res.Stderr = stdout.Bytes(), stderr.Bytes()
+	}()
+	ctx := env.With(context.Background(), env.Env{Dir: req.Dir, Vars: req.Env, Terminal: req.Terminal})
+	if s.Cache != nil {
+		// The files parsed by the command stay mapped until it has
+		// finished, even if another request evicts them from the cache.
+		var release func()
+		ctx, release = s.Cache.Lease(ctx)
+		defer release()
+	}
+
+	c := s.NewRoot()
+	c.SetArgs(req.Args)
+	c.SetIn(bytes.NewReader(nil))
+	c.SetOut(&stdout)
+	c.SetErr(&stderr)
+	if err := c.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(&stderr, err)
+		return response{Code: 1}
+	}
+	return response{}
+}
+
+// Execute executes the command with the given arguments in the environment
+// of the process, and returns its output and exit code.
+func (s *Server) Execute(args []string) (stdout, stderr []byte, code int) {
+	e, err := env.Current()
+	if err != nil {
+		return nil, []byte(err.Error()), 1
+	}
+	res := s.execute(request{Args: args, Dir: e.Dir, Env: e.Vars, Terminal: e.Terminal})
+	return res.Stdout, res.Stderr, res.Code
+}
+
+// Forward executes the command with the given arguments in a running
+// daemon, and copies its output to stdout and stderr. It returns false if
+// no daemon is running or the command can't be forwarded.
+func Forward(args []string, stdout, stderr io.Writer) (int, bool) {
+	if os.Getenv("KNUT_NO_DAEMON") != "" || len(args) == 0 || args[0] == "daemon" {
+		return 0, false
+	}
+	if args[0] == "add" || args[0] == "doctor" || args[0] == "serve" {
+		// Interactive and long-running commands, and diagnostics which
+		// inspect the daemon itself, are executed locally.
+		return 0, false
+	}
+	if args[0] == cobra.ShellCompRequestCmd || args[0] == cobra.ShellCompNoDescRequestCmd {
+		// Completions only scan the journal for names, which is fast
+		// enough without the cache of the daemon.
+		return 0, false
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			// Commands reading from stdin are executed locally.
+			return 0, false
+		}
+	}
+	e, err := env.Current()
+	if err != nil {
+		return 0, false
+	}
+	conn, err := net.Dial("unix", SocketPath())
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+	req := request{Args: args, Dir: e.Dir, Env: e.Vars, Terminal: e.Terminal}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return 0, false
+	}
+	var res response
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, false
+		}
+		fmt.Fprintf(stderr, "daemon: %v\n", err)
+		return 1, true
+	}
+	stdout.Write(res.Stdout)
+	stderr.Write(res.Stderr)
+	return res.Code, true
+}
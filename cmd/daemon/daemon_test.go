@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/spf13/cobra"
+)
+
+func newRoot() *cobra.Command {
+	root := &cobra.Command{Use: "knut"}
+	root.AddCommand(&cobra.Command{
+		Use: "echo",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s in %s with %s\n", strings.Join(args, " "), filepath.Base(env.Dir(cmd.Context())), env.Getenv(cmd.Context(), "GREETING"))
+		},
+	})
+	root.AddCommand(&cobra.Command{
+		Use:           "fail",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("failed")
+		},
+	})
+	return root
+}
+
+func TestForward(t *testing.T) {
+	dir, err := os.MkdirTemp("", "knut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "knut.sock")
+	t.Setenv("KNUT_SOCKET", socket)
+	t.Setenv("GREETING", "hi")
+
+	var stdout, stderr bytes.Buffer
+	if _, ok := Forward([]string{"echo"}, &stdout, &stderr); ok {
+		t.Fatalf("Forward() succeeded without a daemon")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		s := Server{NewRoot: newRoot}
+		done <- s.Serve(ctx, socket)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve() returned unexpected error: %v", err)
+		}
+	}()
+	for i := 0; ; i++ {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatalf("daemon did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code, ok := Forward([]string{"echo", "hello"}, &stdout, &stderr)
+
+	if !ok || code != 0 {
+		t.Fatalf("Forward() = %d, %t, want 0, true", code, ok)
+	}
+	if got, want := stdout.String(), fmt.Sprintf("hello in %s with hi\n", filepath.Base(mustGetwd(t))); got != want {
+		t.Errorf("got stdout %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	code, ok = Forward([]string{"fail"}, &stdout, &stderr)
+
+	if !ok || code != 1 {
+		t.Fatalf("Forward() = %d, %t, want 1, true", code, ok)
+	}
+	if got, want := stderr.String(), "failed\n"; got != want {
+		t.Errorf("got stderr %q, want %q", got, want)
+	}
+	if _, ok := Forward([]string{"daemon"}, &stdout, &stderr); ok {
+		t.Errorf("Forward() forwarded the daemon command")
+	}
+	if _, ok := Forward([]string{cobra.ShellCompRequestCmd, "echo", ""}, &stdout, &stderr); ok {
+		t.Errorf("Forward() forwarded a completion request")
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+func TestExecute(t *testing.T) {
+	wd := mustGetwd(t)
+	dir := t.TempDir()
+	s := Server{NewRoot: newRoot, Cache: syntax.NewCache()}
+
+	res := s.execute(request{Args: []string{"echo", "hello"}, Dir: dir, Env: []string{"GREETING=hi"}})
+
+	if res.Code != 0 {
+		t.Fatalf("execute() returned code %d, want 0", res.Code)
+	}
+	if got, want := string(res.Stdout), fmt.Sprintf("hello in %s with hi\n", filepath.Base(dir)); got != want {
+		t.Errorf("got stdout %q, want %q", got, want)
+	}
+	if got := mustGetwd(t); got != wd {
+		t.Errorf("execute() changed the working directory of the process to %s", got)
+	}
+}
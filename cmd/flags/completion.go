@@ -0,0 +1,93 @@
+package flags
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// JournalEnv is the environment variable with the path of the journal used
+// for completions if a command does not reference a journal.
+const JournalEnv = "KNUT_JOURNAL"
+
+// JournalFunc returns the path of the journal referenced by a command
+// invoked with the given arguments.
+type JournalFunc func(args []string) string
+
+// JournalArgument takes the journal from the first argument, falling back
+// to the journal in the environment.
+func JournalArgument(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return os.Getenv(JournalEnv)
+}
+
+// JournalFromEnv takes the journal from the environment, for commands whose
+// arguments are not journals.
+func JournalFromEnv(args []string) string {
+	return os.Getenv(JournalEnv)
+}
+
+// accountFlags and commodityFlags are regex flags which filter accounts and
+// commodities, respectively.
+var (
+	accountFlags   = []string{"account", "source", "dest"}
+	commodityFlags = []string{"commodity", "show-commodities"}
+)
+
+// RegisterCompletions registers completions for the account and commodity
+// flags of the command and its subcommands, taken from the names in the
+// journal. Flags which already have a completion are left unchanged.
+func RegisterCompletions(c *cobra.Command, journal JournalFunc) {
+	c.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		switch f.Value.(type) {
+		case *AccountFlag:
+			c.RegisterFlagCompletionFunc(f.Name, completeNames(journal, accounts))
+		case *CommodityFlag:
+			c.RegisterFlagCompletionFunc(f.Name, completeNames(journal, commodities))
+		case *RegexFlag:
+			if slices.Contains(accountFlags, f.Name) {
+				c.RegisterFlagCompletionFunc(f.Name, completeNames(journal, accounts))
+			} else if slices.Contains(commodityFlags, f.Name) {
+				c.RegisterFlagCompletionFunc(f.Name, completeNames(journal, commodities))
+			}
+		}
+	})
+	for _, ch := range c.Commands() {
+		RegisterCompletions(ch, journal)
+	}
+}
+
+func accounts(ns syntax.Names) []string {
+	return ns.Accounts
+}
+
+func commodities(ns syntax.Names) []string {
+	return ns.Commodities
+}
+
+func completeNames(journal JournalFunc, names func(syntax.Names) []string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		path := journal(args)
+		if path == "" {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		ns, err := syntax.ScanNames(path)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var res []string
+		for _, n := range names(ns) {
+			if strings.HasPrefix(n, toComplete) {
+				res = append(res, n)
+			}
+		}
+		return res, cobra.ShellCompDirectiveNoFileComp
+	}
+}
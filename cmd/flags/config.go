@@ -0,0 +1,174 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flags
+
+import (
+	"github.com/sboehler/knut/lib/common/env"
+
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ConfigFile is the name of the configuration file at the journal root.
+const ConfigFile = ".knut.toml"
+
+// mutuallyExclusive is the annotation cobra uses to mark flags in a mutually
+// exclusive group.
+const mutuallyExclusive = "cobra_annotation_mutually_exclusive"
+
+// Config holds default values for flags, read from a configuration file.
+//
+// Keys at the top level apply to all commands which have a flag of that name.
+// Keys in a table named after a command, such as [balance] or
+// [portfolio.returns], apply to that command only and take precedence.
+type Config struct {
+	path   string
+	values map[string]any
+}
+
+// LoadConfig loads the configuration from the given file.
+func LoadConfig(ctx context.Context, path string) (*Config, error) {
+	values := make(map[string]any)
+	if _, err := toml.DecodeFile(env.Path(ctx, path), &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Config{path: path, values: values}, nil
+}
+
+// FindConfig loads the configuration from the given path, if it is not
+// empty. Otherwise, it looks for a configuration file in the directory of
+// the file given as the first argument, and then in the working directory.
+// It returns nil if there is no configuration file.
+func FindConfig(ctx context.Context, path string, args []string) (*Config, error) {
+	if path != "" {
+		return LoadConfig(ctx, path)
+	}
+	var dirs []string
+	if len(args) > 0 {
+		if _, err := os.Stat(env.Path(ctx, args[0])); err == nil {
+			dirs = append(dirs, filepath.Dir(args[0]))
+		}
+	}
+	dirs = append(dirs, ".")
+	for _, dir := range dirs {
+		p := filepath.Join(dir, ConfigFile)
+		if _, err := os.Stat(env.Path(ctx, p)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		return LoadConfig(ctx, p)
+	}
+	return nil, nil
+}
+
+// Apply sets the flags of the command which have not been set on the
+// command line to the values in the configuration.
+func (c *Config) Apply(cmd *cobra.Command) error {
+	section := c.values
+	for _, name := range strings.Fields(cmd.CommandPath())[1:] {
+		s, ok := section[name].(map[string]any)
+		if !ok {
+			section = nil
+			break
+		}
+		section = s
+	}
+	// Values of the command take precedence over the top-level values, so
+	// they are applied first. Flags which are set are not changed again.
+	if err := c.apply(cmd.Flags(), section, true); err != nil {
+		return err
+	}
+	return c.apply(cmd.Flags(), c.values, false)
+}
+
+func (c *Config) apply(fset *pflag.FlagSet, values map[string]any, strict bool) error {
+	names := make([]string, 0, len(values))
+	for name, value := range values {
+		if _, ok := value.(map[string]any); !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := fset.Lookup(name)
+		if f == nil {
+			if strict {
+				return fmt.Errorf("%s: unknown flag %q", c.path, name)
+			}
+			continue
+		}
+		if f.Changed || isExcluded(fset, f) {
+			continue
+		}
+		vs, ok := values[name].([]any)
+		if !ok {
+			vs = []any{values[name]}
+		}
+		for _, v := range vs {
+			s, err := formatValue(v)
+			if err != nil {
+				return fmt.Errorf("%s: flag %q: %w", c.path, name, err)
+			}
+			if err := fset.Set(name, s); err != nil {
+				return fmt.Errorf("%s: flag %q: %w", c.path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isExcluded returns whether a flag which is mutually exclusive with the
+// given flag has been set.
+func isExcluded(fset *pflag.FlagSet, f *pflag.Flag) bool {
+	for _, group := range f.Annotations[mutuallyExclusive] {
+		for _, name := range strings.Fields(group) {
+			if o := fset.Lookup(name); o != nil && o != f && o.Changed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatValue(v any) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case time.Time:
+		return t.Format("2006-01-02"), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v of type %T", v, v)
+	}
+}
@@ -0,0 +1,161 @@
+package flags
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/spf13/cobra"
+)
+
+const testConfig = `
+val = "CHF"
+thousands = true
+from = 2023-01-01
+
+[balance]
+val = "USD"
+map = ["1,Expenses", "2,Assets"]
+months = true
+digits = 2
+
+[balance.nested]
+val = "EUR"
+`
+
+type testFlags struct {
+	val, from string
+	mapping   []string
+	interval  string
+	months    bool
+	thousands bool
+	digits    int
+}
+
+func createTestCommand(f *testFlags) *cobra.Command {
+	root := &cobra.Command{Use: "knut"}
+	c := &cobra.Command{Use: "balance"}
+	c.Flags().StringVar(&f.val, "val", "", "")
+	c.Flags().StringVar(&f.from, "from", "", "")
+	c.Flags().StringArrayVar(&f.mapping, "map", nil, "")
+	c.Flags().BoolVar(&f.months, "months", false, "")
+	c.Flags().StringVar(&f.interval, "interval", "", "")
+	c.MarkFlagsMutuallyExclusive("months", "interval")
+	c.Flags().BoolVar(&f.thousands, "thousands", false, "")
+	c.Flags().IntVar(&f.digits, "digits", 0, "")
+	root.AddCommand(c)
+	return c
+}
+
+func TestConfigApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		desc string
+		args []string
+		want testFlags
+	}{
+		{
+			desc: "defaults from the file",
+			want: testFlags{
+				val:       "USD",
+				from:      "2023-01-01",
+				mapping:   []string{"1,Expenses", "2,Assets"},
+				months:    true,
+				thousands: true,
+				digits:    2,
+			},
+		},
+		{
+			desc: "flags override the file",
+			args: []string{"--val", "GBP", "--map", "0,Income", "--thousands=false", "--interval", "weekly"},
+			want: testFlags{
+				val:      "GBP",
+				from:     "2023-01-01",
+				mapping:  []string{"0,Income"},
+				interval: "weekly",
+				digits:   2,
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var got testFlags
+			c := createTestCommand(&got)
+			if err := c.ParseFlags(test.args); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := FindConfig(context.Background(), path, nil)
+			if err != nil {
+				t.Fatalf("FindConfig() returned error %v", err)
+			}
+
+			if err := cfg.Apply(c); err != nil {
+				t.Fatalf("Apply() returned error %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(testFlags{})); diff != "" {
+				t.Errorf("Apply() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+			if err := c.ValidateFlagGroups(); err != nil {
+				t.Errorf("ValidateFlagGroups() returned error %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigApplyUnknownFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	if err := os.WriteFile(path, []byte("[balance]\nvalutaion = \"CHF\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := createTestCommand(new(testFlags))
+	cfg, err := LoadConfig(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadConfig() returned error %v", err)
+	}
+
+	err = cfg.Apply(c)
+
+	if err == nil || !strings.Contains(err.Error(), `unknown flag "valutaion"`) {
+		t.Errorf("Apply() returned error %v, want unknown flag", err)
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "journal.knut")
+	if err := os.WriteFile(journal, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := FindConfig(context.Background(), "", []string{journal}); err != nil || cfg != nil {
+		t.Fatalf("FindConfig() = %v, %v, want nil, nil", cfg, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("val = \"CHF\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := FindConfig(context.Background(), "", []string{journal})
+
+	if err != nil {
+		t.Fatalf("FindConfig() returned error %v", err)
+	}
+	if cfg == nil || cfg.path != filepath.Join(dir, ConfigFile) {
+		t.Errorf("FindConfig() = %v, want config in %s", cfg, dir)
+	}
+
+	// A relative journal is resolved against the working directory of the
+	// context, rather than that of the process.
+	ctx := env.With(context.Background(), env.Env{Dir: dir})
+	if cfg, err = FindConfig(ctx, "", []string{"journal.knut"}); err != nil {
+		t.Fatalf("FindConfig() returned error %v", err)
+	}
+	if cfg == nil || cfg.path != ConfigFile {
+		t.Errorf("FindConfig() = %v, want config in the working directory %s", cfg, dir)
+	}
+}
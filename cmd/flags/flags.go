@@ -16,6 +16,7 @@ package flags
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"regexp"
@@ -27,7 +28,11 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/regex"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal/lots"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/account"
 )
@@ -103,10 +108,59 @@ func (rf *RegexFlag) Regex() regex.Regexes {
 	return rf.rxs
 }
 
+// AccountTypesFlag manages a flag with a comma-separated list of account
+// types. As types can be declared in the journal, they are resolved after
+// it has been loaded.
+type AccountTypesFlag struct {
+	text  string
+	names []string
+}
+
+var _ pflag.Value = (*AccountTypesFlag)(nil)
+
+func (af AccountTypesFlag) String() string {
+	return af.text
+}
+
+// Set implements pflag.Value.
+func (af *AccountTypesFlag) Set(v string) error {
+	names := []string{}
+	for _, t := range strings.Split(v, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			names = append(names, t)
+		}
+	}
+	af.text, af.names = v, names
+	return nil
+}
+
+// Type implements pflag.Value.
+func (af AccountTypesFlag) Type() string {
+	return "<type>,..."
+}
+
+// Value returns the account types, which must be standard types or declared
+// in the registry. It is nil if the flag has not been set.
+func (af AccountTypesFlag) Value(reg *account.Registry) (set.Set[account.Type], error) {
+	if af.names == nil {
+		return nil, nil
+	}
+	types := set.New[account.Type]()
+	for _, name := range af.names {
+		t, err := reg.ParseType(name)
+		if err != nil {
+			return nil, err
+		}
+		types.Add(t)
+	}
+	return types, nil
+}
+
 // IntervalFlags manages multiple flags to determine a time period.
 type IntervalFlags struct {
-	def   date.Interval
-	flags [6]bool
+	def      date.Interval
+	flags    [6]bool
+	interval IntervalFlag
 }
 
 // Setup configures the flags.
@@ -117,12 +171,16 @@ func (pf *IntervalFlags) Setup(cmd *cobra.Command, def date.Interval) {
 	cmd.Flags().BoolVar(&pf.flags[date.Monthly], "months", false, "months")
 	cmd.Flags().BoolVar(&pf.flags[date.Quarterly], "quarters", false, "quarters")
 	cmd.Flags().BoolVar(&pf.flags[date.Yearly], "years", false, "years")
-	cmd.MarkFlagsMutuallyExclusive("days", "weeks", "months", "quarters", "years")
+	cmd.Flags().Var(&pf.interval, "interval", "interval (once, daily, weekly, week-iso, monthly, quarterly or yearly)")
+	cmd.MarkFlagsMutuallyExclusive("days", "weeks", "months", "quarters", "years", "interval")
 	pf.def = def
 }
 
 // Value returns the period.
 func (pf IntervalFlags) Value() date.Interval {
+	if pf.interval.set {
+		return pf.interval.interval
+	}
 	for i, val := range pf.flags {
 		if val {
 			return date.Interval(i)
@@ -131,8 +189,70 @@ func (pf IntervalFlags) Value() date.Interval {
 	return pf.def
 }
 
+// IntervalFlag manages a flag to determine an interval by name.
+type IntervalFlag struct {
+	interval date.Interval
+	set      bool
+}
+
+var _ pflag.Value = (*IntervalFlag)(nil)
+
+func (f IntervalFlag) String() string {
+	if !f.set {
+		return ""
+	}
+	return f.interval.String()
+}
+
+// Set implements pflag.Value.
+func (f *IntervalFlag) Set(v string) error {
+	interval, err := date.ParseInterval(v)
+	if err != nil {
+		return err
+	}
+	f.interval, f.set = interval, true
+	return nil
+}
+
+// Type implements pflag.Value.
+func (f IntervalFlag) Type() string {
+	return "<interval>"
+}
+
+// FiscalYearFlag manages a flag to determine the start of the fiscal year.
+type FiscalYearFlag date.FiscalYear
+
+var _ pflag.Value = (*FiscalYearFlag)(nil)
+
+func (f FiscalYearFlag) String() string {
+	return f.Value().String()
+}
+
+// Set implements pflag.Value.
+func (f *FiscalYearFlag) Set(v string) error {
+	fy, err := date.ParseFiscalYear(v)
+	if err != nil {
+		return err
+	}
+	*f = FiscalYearFlag(fy)
+	return nil
+}
+
+// Type implements pflag.Value.
+func (f FiscalYearFlag) Type() string {
+	return "MM-DD"
+}
+
+// Value returns the flag value.
+func (f FiscalYearFlag) Value() date.FiscalYear {
+	return date.FiscalYear(f)
+}
+
+// PeriodFlag manages the flags which determine a period, either given by its
+// start and end dates or by a period expression.
 type PeriodFlag struct {
 	start, end DateFlag
+	period     periodExpr
 }
 
 func (pf *PeriodFlag) Setup(cmd *cobra.Command, def date.Period) {
@@ -140,13 +260,47 @@ func (pf *PeriodFlag) Setup(cmd *cobra.Command, def date.Period) {
 	pf.end = DateFlag(def.End)
 	cmd.Flags().Var(&pf.start, "from", "from date")
 	cmd.Flags().Var(&pf.end, "to", "to date")
+	cmd.Flags().Var(&pf.period, "period", "period (e.g. 2023, 2023-Q2, 2023-05, last-month, ytd or 2022..2023-Q1)")
+	cmd.MarkFlagsMutuallyExclusive("period", "from")
+	cmd.MarkFlagsMutuallyExclusive("period", "to")
 }
 
 func (pf *PeriodFlag) Value() date.Period {
+	if pf.period.set {
+		return pf.period.period
+	}
 	return date.Period{Start: pf.start.Value(), End: pf.end.Value()}
 }
 
-// MappingFlag manages a flag of type -c1,<regex>.
+// periodExpr manages a flag to determine a period by an expression.
+type periodExpr struct {
+	text   string
+	period date.Period
+	set    bool
+}
+
+var _ pflag.Value = (*periodExpr)(nil)
+
+func (f periodExpr) String() string {
+	return f.text
+}
+
+// Set implements pflag.Value.
+func (f *periodExpr) Set(v string) error {
+	period, err := date.ParsePeriod(v, date.Today())
+	if err != nil {
+		return err
+	}
+	f.text, f.period, f.set = v, period, true
+	return nil
+}
+
+// Type implements pflag.Value.
+func (f periodExpr) Type() string {
+	return "<period>"
+}
+
+// MappingFlag manages a flag of type -m[<type>[|<type>]=]<level>[:<suffix>],<regex>.
 type MappingFlag struct {
 	m account.Mapping
 }
@@ -159,20 +313,30 @@ func (cf MappingFlag) String() string {
 
 // Type implements pflag.Value.
 func (cf MappingFlag) Type() string {
-	return "<level>[:<suffix>],<regex>"
+	return "[<type>=]<level>[:<suffix>],<regex>"
 }
 
 // Set implements pflag.Value.
 func (cf *MappingFlag) Set(v string) error {
-	s := strings.SplitN(v, ",", 2)
-	if len(s) == 0 || len(s) > 2 {
-		return fmt.Errorf("expected <level>:[:<suffix>],<regex>, got %q", v)
-	}
 	var (
 		level, suffix int
 		regex         *regexp.Regexp
+		types         set.Set[account.Type]
 		err           error
 	)
+	if ts, rest, ok := strings.Cut(v, "="); ok && !strings.Contains(ts, ",") {
+		// The types are resolved by Value, as they can be declared in the
+		// journal.
+		types = set.New[account.Type]()
+		for _, t := range strings.Split(ts, "|") {
+			types.Add(account.Type(t))
+		}
+		v = rest
+	}
+	s := strings.SplitN(v, ",", 2)
+	if len(s) == 0 || len(s) > 2 {
+		return fmt.Errorf("expected <level>:[:<suffix>],<regex>, got %q", v)
+	}
 	switch nn := strings.Split(s[0], ":"); len(nn) {
 	case 1:
 		if level, err = strconv.Atoi(nn[0]); err != nil {
@@ -194,6 +358,7 @@ func (cf *MappingFlag) Set(v string) error {
 		}
 	}
 	cf.m = append(cf.m, account.Rule{
+		Types:  types,
 		Level:  level,
 		Suffix: suffix,
 		Regex:  regex,
@@ -201,9 +366,53 @@ func (cf *MappingFlag) Set(v string) error {
 	return nil
 }
 
-// Value returns the value of this flag.
-func (cf *MappingFlag) Value() account.Mapping {
-	return cf.m
+// Value returns the value of this flag. The account types of the rules must
+// be standard types or declared in the registry.
+func (cf *MappingFlag) Value(reg *account.Registry) (account.Mapping, error) {
+	res := make(account.Mapping, 0, len(cf.m))
+	for _, rule := range cf.m {
+		if rule.Types != nil {
+			types := set.New[account.Type]()
+			for t := range rule.Types {
+				at, err := reg.ParseType(t.String())
+				if err != nil {
+					return nil, err
+				}
+				types.Add(at)
+			}
+			rule.Types = types
+		}
+		res = append(res, rule)
+	}
+	return res, nil
+}
+
+// MapFileFlag manages a flag with the path of a file of account
+// substitutions.
+type MapFileFlag struct {
+	path string
+}
+
+// Setup sets up the flag.
+func (mf *MapFileFlag) Setup(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&mf.path, "map-file", "", "map accounts with the ordered rules in the file (match: <regex> => <account>)")
+}
+
+// Value returns a mapper which applies the rules in the file.
+func (mf MapFileFlag) Value(ctx context.Context, reg *model.Registry) (mapper.Mapper[*model.Account], error) {
+	if mf.path == "" {
+		return mapper.Identity[*model.Account], nil
+	}
+	f, err := os.Open(env.Path(ctx, mf.path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ss, err := account.ReadSubstitutions(reg.Accounts(), f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", mf.path, err)
+	}
+	return account.Substitute(ss), nil
 }
 
 // CommodityFlag manages a flag to parse a commodity.
@@ -235,6 +444,38 @@ func (cf CommodityFlag) Value(reg *model.Registry) (*model.Commodity, error) {
 	return nil, nil
 }
 
+// BookingFlag manages a flag to parse a booking method.
+type BookingFlag struct {
+	method lots.Method
+}
+
+var _ pflag.Value = (*BookingFlag)(nil)
+
+// Set implements pflag.Value.
+func (bf *BookingFlag) Set(v string) error {
+	m, err := lots.ParseMethod(v)
+	if err != nil {
+		return err
+	}
+	bf.method = m
+	return nil
+}
+
+// Type implements pflag.Value.
+func (bf BookingFlag) Type() string {
+	return "fifo|avg"
+}
+
+// String implements pflag.Value.
+func (bf BookingFlag) String() string {
+	return bf.method.String()
+}
+
+// Value returns the booking method.
+func (bf BookingFlag) Value() lots.Method {
+	return bf.method
+}
+
 // AccountFlag manages a flag to parse a commodity.
 type AccountFlag struct {
 	val string
@@ -277,8 +518,8 @@ func (cf AccountFlag) ValueWithDefault(ctx *account.Registry, def *model.Account
 }
 
 // OpenFile opens the file at the given path as a buffered reader.
-func OpenFile(p string) (*bufio.Reader, error) {
-	f, err := os.Open(p)
+func OpenFile(ctx context.Context, p string) (*bufio.Reader, error) {
+	f, err := os.Open(env.Path(ctx, p))
 	if err != nil {
 		return nil, err
 	}
@@ -1,22 +1,155 @@
 package flags
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/common/rounding"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
 	"github.com/spf13/cobra"
 )
 
 type Multiperiod struct {
-	period   PeriodFlag
-	last     int
-	interval IntervalFlags
+	period     PeriodFlag
+	last       int
+	interval   IntervalFlags
+	fiscalYear FiscalYearFlag
 }
 
 func (mp *Multiperiod) Setup(cmd *cobra.Command) {
 	mp.period.Setup(cmd, date.Period{End: date.Today()})
 	cmd.Flags().IntVar(&mp.last, "last", 0, "last n periods")
 	mp.interval.Setup(cmd, date.Once)
+	cmd.Flags().Var(&mp.fiscalYear, "fiscal-year-start", "start of the fiscal year, for quarters and years")
 }
 
 func (mp *Multiperiod) Partition(clip date.Period) date.Partition {
-	return date.NewPartition(mp.period.Value().Clip(clip), mp.interval.Value(), mp.last)
+	return mp.fiscalYear.Value().Partition(mp.period.Value().Clip(clip), mp.interval.Value(), mp.last)
+}
+
+// Until returns the day after which directives can't affect the reports for
+// the period, for journal.FromPathUntil. It is zero if prices are
+// interpolated, as interpolated prices depend on later prices.
+func (mp *Multiperiod) Until(policy journal.PricePolicy) time.Time {
+	if policy.Mode == journal.PriceInterpolate {
+		return time.Time{}
+	}
+	return mp.period.Value().End
+}
+
+// SetupProcessing sets up the global flags which determine how the journal
+// is processed.
+func SetupProcessing(cmd *cobra.Command) {
+	cmd.PersistentFlags().Bool("auto-open", false, "open accounts on their first use instead of reporting them as not open")
+	cmd.PersistentFlags().Bool("keep-going", false, "continue processing the journal after an error and report all processing errors together (syntax errors still stop at the first error)")
+}
+
+// Processing returns the options for processing the journal, taken from the
+// global flags. A command without these flags, such as in tests, processes
+// the journal with the default options.
+func Processing(cmd *cobra.Command) journal.Options {
+	var opts journal.Options
+	opts.AutoOpen, _ = cmd.Flags().GetBool("auto-open")
+	opts.KeepGoing, _ = cmd.Flags().GetBool("keep-going")
+	return opts
+}
+
+// PricePolicy manages the flags which determine how prices are computed
+// between price directives.
+type PricePolicy struct {
+	mode   string
+	maxAge int
+	via    []string
+}
+
+func (pp *PricePolicy) Setup(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&pp.mode, "price-policy", "last", "prices between price directives: last, interpolate or strict")
+	cmd.Flags().IntVar(&pp.maxAge, "max-price-age", 30, "maximum age of a price in days for --price-policy=strict")
+	SetupPricePath(cmd, &pp.via)
+}
+
+// SetupPricePath sets up the flag with the commodities through which prices
+// are triangulated.
+func SetupPricePath(cmd *cobra.Command, via *[]string) {
+	cmd.Flags().StringSliceVar(via, "price-path", nil, "triangulate prices through these commodities if possible, in order of preference")
+}
+
+// PricePath resolves the commodities through which prices are triangulated.
+func PricePath(reg *model.Registry, via []string) ([]*model.Commodity, error) {
+	var res []*model.Commodity
+	for _, name := range via {
+		c, err := reg.Commodities().Get(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (pp *PricePolicy) Value(cmd *cobra.Command, reg *model.Registry) (journal.PricePolicy, error) {
+	mode, err := journal.ParsePriceMode(pp.mode)
+	if err != nil {
+		return journal.PricePolicy{}, err
+	}
+	via, err := PricePath(reg, pp.via)
+	if err != nil {
+		return journal.PricePolicy{}, err
+	}
+	return journal.PricePolicy{
+		Mode:   mode,
+		MaxAge: pp.maxAge,
+		Via:    via,
+		Warn: func(err error) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", err)
+		},
+	}, nil
+}
+
+// Colors manages the flags which determine colored output.
+type Colors struct {
+	color bool
+	theme string
+}
+
+func (cs *Colors) Setup(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&cs.color, "color", true, "print output in color (default: if stdout is a terminal and NO_COLOR is not set)")
+	cmd.Flags().StringVar(&cs.theme, "theme", "default", "color theme: default, minimal or mono")
+}
+
+// Value returns whether to print in color, and the color theme. Unless
+// --color is given explicitly, output is colored if the terminal supports it.
+func (cs *Colors) Value(cmd *cobra.Command) (bool, *table.Theme, error) {
+	theme, err := table.ParseTheme(cs.theme)
+	if err != nil {
+		return false, nil, err
+	}
+	if cmd.Flags().Changed("color") {
+		return cs.color, &theme, nil
+	}
+	return env.Terminal(cmd.Context()).Color, &theme, nil
+}
+
+// Rounding manages the flag which determines how numbers are rounded, both
+// when they are rendered and when they are valuated.
+type Rounding struct {
+	mode string
+}
+
+func (r *Rounding) Setup(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&r.mode, "rounding", "", "rounding mode: half-up, half-even or truncate (default: half-up when rendering, truncate when valuating)")
+}
+
+// Value returns the rounding modes for rendering and for valuating numbers.
+// Without the flag, numbers are rendered half-up and values are truncated.
+func (r *Rounding) Value() (render, valuation rounding.Mode, err error) {
+	if r.mode == "" {
+		return rounding.HalfUp, rounding.Truncate, nil
+	}
+	mode, err := rounding.Parse(r.mode)
+	return mode, mode, err
 }
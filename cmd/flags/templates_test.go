@@ -0,0 +1,90 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/lib/common/rounding"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestPricePolicyWarn(t *testing.T) {
+	var pp PricePolicy
+	cmd := &cobra.Command{}
+	pp.Setup(cmd)
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+
+	policy, err := pp.Value(cmd, registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.Warn(errors.New("conflicting prices"))
+
+	if got, want := stderr.String(), "warning: conflicting prices\n"; got != want {
+		t.Errorf("Warn() wrote %q, want %q", got, want)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	for _, test := range []struct {
+		args              []string
+		render, valuation rounding.Mode
+	}{
+		{render: rounding.HalfUp, valuation: rounding.Truncate},
+		{args: []string{"--rounding", "half-up"}, render: rounding.HalfUp, valuation: rounding.HalfUp},
+		{args: []string{"--rounding", "half-even"}, render: rounding.HalfEven, valuation: rounding.HalfEven},
+	} {
+		var r Rounding
+		cmd := &cobra.Command{}
+		r.Setup(cmd)
+		if err := cmd.ParseFlags(test.args); err != nil {
+			t.Fatal(err)
+		}
+
+		render, valuation, err := r.Value()
+
+		if err != nil {
+			t.Fatalf("Value() returned unexpected error %v", err)
+		}
+		if render != test.render || valuation != test.valuation {
+			t.Errorf("Value() with %v = %s, %s, want %s, %s", test.args, render, valuation, test.render, test.valuation)
+		}
+	}
+}
+
+func TestProcessing(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want journal.Options
+	}{
+		{args: []string{"child"}},
+		{args: []string{"child", "--auto-open"}, want: journal.Options{AutoOpen: true}},
+		{args: []string{"--keep-going", "child"}, want: journal.Options{KeepGoing: true}},
+	} {
+		var got journal.Options
+		root := &cobra.Command{Use: "root"}
+		SetupProcessing(root)
+		root.AddCommand(&cobra.Command{
+			Use: "child",
+			Run: func(cmd *cobra.Command, args []string) {
+				got = Processing(cmd)
+			},
+		})
+		root.SetArgs(test.args)
+		if err := root.Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("Processing() with %v = %+v, want %+v", test.args, got, test.want)
+		}
+	}
+	if got := Processing(&cobra.Command{}); got != (journal.Options{}) {
+		t.Errorf("Processing() without the flags = %+v, want the default options", got)
+	}
+}
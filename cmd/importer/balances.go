@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+)
+
+// AssertionPolicy determines which balance assertions an importer creates
+// from the balances of a statement. The zero value creates no assertions.
+type AssertionPolicy int
+
+const (
+	// AssertNone creates no assertions.
+	AssertNone AssertionPolicy = iota
+	// AssertDaily asserts the balance at the end of each day with bookings.
+	AssertDaily
+	// AssertLast asserts the balance at the end of the statement.
+	AssertLast
+)
+
+var _ pflag.Value = (*AssertionPolicy)(nil)
+
+// AddFlag adds the --assertions flag to the command, with the given default.
+func (ap *AssertionPolicy) AddFlag(cmd *cobra.Command, def AssertionPolicy) {
+	*ap = def
+	cmd.Flags().Var(ap, "assertions", "create a balance assertion for each day (daily), for the end of the statement (last) or none")
+}
+
+// Set implements pflag.Value.
+func (ap *AssertionPolicy) Set(v string) error {
+	switch v {
+	case "daily":
+		*ap = AssertDaily
+	case "last":
+		*ap = AssertLast
+	case "none":
+		*ap = AssertNone
+	default:
+		return fmt.Errorf("invalid assertion policy %q, expected last, daily or none", v)
+	}
+	return nil
+}
+
+// Type implements pflag.Value.
+func (ap AssertionPolicy) Type() string {
+	return "last|daily|none"
+}
+
+// String implements pflag.Value.
+func (ap AssertionPolicy) String() string {
+	switch ap {
+	case AssertDaily:
+		return "daily"
+	case AssertLast:
+		return "last"
+	}
+	return "none"
+}
+
+// Balances collects the balances of an account after each booking of a
+// statement, in the order of the statement, which may be chronological or
+// reverse chronological.
+type Balances struct {
+	Account   *model.Account
+	Commodity *model.Commodity
+
+	dates    []time.Time
+	balances []decimal.Decimal
+	known    []bool
+}
+
+// Add adds the balance after a booking on the given date.
+func (b *Balances) Add(date time.Time, balance decimal.Decimal) {
+	b.dates = append(b.dates, date)
+	b.balances = append(b.balances, balance)
+	b.known = append(b.known, true)
+}
+
+// AddUnknown adds a booking on the given date whose balance is not known.
+func (b *Balances) AddUnknown(date time.Time) {
+	b.dates = append(b.dates, date)
+	b.balances = append(b.balances, decimal.Zero)
+	b.known = append(b.known, false)
+}
+
+// Assertions returns the assertions according to the policy. With
+// AssertDaily, the balance at the end of each day is asserted, which is the
+// balance after the last booking of the day, unless that balance is not
+// known. With AssertLast, only the latest of these is asserted. Statements
+// are considered reverse chronological if the first booking is later than
+// the last one.
+func (b *Balances) Assertions(policy AssertionPolicy) []*model.Assertion {
+	var res []*model.Assertion
+	if len(b.dates) == 0 || policy == AssertNone {
+		return res
+	}
+	reverse := b.dates[0].After(b.dates[len(b.dates)-1])
+	for i, d := range b.dates {
+		// The last booking of a day is the first one in a reverse
+		// chronological statement.
+		var last bool
+		if reverse {
+			last = i == 0 || !b.dates[i-1].Equal(d)
+		} else {
+			last = i == len(b.dates)-1 || !b.dates[i+1].Equal(d)
+		}
+		if !last || !b.known[i] {
+			continue
+		}
+		res = append(res, &model.Assertion{
+			Date: d,
+			Balances: []model.Balance{
+				{
+					Account:   b.Account,
+					Quantity:  b.balances[i],
+					Commodity: b.Commodity,
+				},
+			},
+		})
+	}
+	if policy == AssertLast && len(res) > 0 {
+		last := res[0]
+		for _, a := range res[1:] {
+			if a.Date.After(last.Date) {
+				last = a
+			}
+		}
+		return []*model.Assertion{last}
+	}
+	return res
+}
+
+// CommodityBalances collects the balances of an account in several
+// commodities, such as the currencies of a multi-currency account.
+type CommodityBalances struct {
+	Account *model.Account
+
+	balances map[*model.Commodity]*Balances
+}
+
+// Add adds the balance in the commodity after a booking on the given date.
+func (cb *CommodityBalances) Add(date time.Time, commodity *model.Commodity, balance decimal.Decimal) {
+	b, ok := cb.balances[commodity]
+	if !ok {
+		if cb.balances == nil {
+			cb.balances = make(map[*model.Commodity]*Balances)
+		}
+		b = &Balances{Account: cb.Account, Commodity: commodity}
+		cb.balances[commodity] = b
+	}
+	b.Add(date, balance)
+}
+
+// Assertions returns the assertions of each commodity according to the
+// policy, ordered by commodity.
+func (cb *CommodityBalances) Assertions(policy AssertionPolicy) []*model.Assertion {
+	var res []*model.Assertion
+	for _, c := range dict.SortedKeys(cb.balances, commodity.Compare) {
+		res = append(res, cb.balances[c].Assertions(policy)...)
+	}
+	return res
+}
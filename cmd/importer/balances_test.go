@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestBalancesAssertions(t *testing.T) {
+	var (
+		reg  = registry.New()
+		acc  = reg.Accounts().MustGet("Assets:Bank")
+		chf  = reg.Commodities().MustGet("CHF")
+		day1 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+		day3 = time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	)
+	// A reverse chronological statement, whose latest balance is not known.
+	b := Balances{Account: acc, Commodity: chf}
+	b.AddUnknown(day3)
+	b.Add(day2, decimal.NewFromInt(30))
+	b.Add(day2, decimal.NewFromInt(20))
+	b.Add(day1, decimal.NewFromInt(10))
+
+	for _, test := range []struct {
+		policy string
+		want   []string
+	}{
+		{
+			policy: "daily",
+			want:   []string{"2024-01-02 30", "2024-01-01 10"},
+		},
+		{
+			policy: "last",
+			want:   []string{"2024-01-02 30"},
+		},
+		{
+			policy: "none",
+		},
+	} {
+		t.Run(test.policy, func(t *testing.T) {
+			var policy AssertionPolicy
+			if err := policy.Set(test.policy); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range b.Assertions(policy) {
+				got = append(got, a.Date.Format("2006-01-02")+" "+a.Balances[0].Quantity.String())
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommodityBalancesAssertions(t *testing.T) {
+	var (
+		reg = registry.New()
+		acc = reg.Accounts().MustGet("Assets:Bank")
+		day = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		cb  = CommodityBalances{Account: acc}
+	)
+	for _, name := range []string{"USD", "CHF", "EUR", "BTC"} {
+		cb.Add(day, reg.Commodities().MustGet(name), decimal.NewFromInt(1))
+	}
+	want := []string{"BTC", "CHF", "EUR", "USD"}
+
+	for i := 0; i < 10; i++ {
+		var got []string
+		for _, a := range cb.Assertions(AssertDaily) {
+			got = append(got, a.Balances[0].Commodity.Name())
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("unexpected diff (-want/+got):\n%s", diff)
+		}
+	}
+}
+
+func TestAssertionPolicyDefault(t *testing.T) {
+	var policy AssertionPolicy
+	if policy != AssertNone || policy.String() != "none" {
+		t.Fatalf("zero AssertionPolicy is %s, want none", policy)
+	}
+}
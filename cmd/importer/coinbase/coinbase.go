@@ -0,0 +1,353 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coinbase
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "us.coinbase",
+		Short: "Import Coinbase transaction history",
+		Long: `Download the transaction history as CSV file (Taxes > Documents > Transaction history).
+Buys, sells and conversions are booked against the trading account, fees against the fee
+account and rewards against the income account. Transfers are booked against Expenses:TBD.`,
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account, fee, trading, income flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.Flags().VarP(&r.fee, "fee", "f", "account name of the fee account")
+	cmd.Flags().VarP(&r.trading, "trading", "t", "account name of the trading gain / loss account")
+	cmd.Flags().VarP(&r.income, "income", "i", "account name of the staking and rewards income account")
+	cmd.MarkFlagRequired("account")
+	cmd.MarkFlagRequired("fee")
+	cmd.MarkFlagRequired("trading")
+	cmd.MarkFlagRequired("income")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	builder := journal.New()
+	for _, path := range args {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
+			return err
+		}
+		p := parser{
+			registry: reg,
+			reader:   csv.NewReader(utfbom.SkipOnly(f)),
+			builder:  builder,
+		}
+		if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.fee, err = r.fee.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.trading, err = r.trading.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.income, err = r.income.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if err = p.parse(); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	builder  *journal.Builder
+
+	account, fee, trading, income *model.Account
+}
+
+func (p *parser) parse() error {
+	p.reader.TrimLeadingSpace = true
+	p.reader.FieldsPerRecord = -1
+
+	if err := p.skipToHeader(); err != nil {
+		return err
+	}
+	for {
+		r, err := p.reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(r) != len(header) {
+			return fmt.Errorf("expected %d fields, got %v", len(header), r)
+		}
+		if err := p.parseBooking(r); err != nil {
+			return err
+		}
+	}
+}
+
+type bookingField int
+
+const (
+	bfTimestamp bookingField = iota
+	bfTransactionType
+	bfAsset
+	bfQuantityTransacted
+	bfSpotPriceCurrency
+	bfSpotPriceAtTransaction
+	bfSubtotal
+	bfTotal
+	bfFees
+	bfNotes
+)
+
+var header = []string{"Timestamp", "Transaction Type", "Asset", "Quantity Transacted", "Spot Price Currency", "Spot Price at Transaction", "Subtotal", "Total (inclusive of fees and/or spread)", "Fees and/or Spread", "Notes"}
+
+// skipToHeader skips the preamble of the report.
+func (p *parser) skipToHeader() error {
+	for {
+		r, err := p.reader.Read()
+		if err == io.EOF {
+			return fmt.Errorf("header not found")
+		}
+		if err != nil {
+			return err
+		}
+		if len(r) == len(header) && r[0] == header[0] {
+			for i := range r {
+				if r[i] != header[i] {
+					return fmt.Errorf("invalid header: %v", r)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+type record struct {
+	date                    time.Time
+	trxType                 string
+	asset, currency         *model.Commodity
+	quantity, subtotal, fee decimal.Decimal
+	notes                   string
+}
+
+var (
+	rewards      = set.Of("Rewards Income", "Staking Income", "Coinbase Earn", "Learning Reward", "Inflation Reward")
+	buys         = set.Of("Buy", "Advanced Trade Buy")
+	sells        = set.Of("Sell", "Advanced Trade Sell")
+	transfersIn  = set.Of("Receive", "Deposit")
+	transfersOut = set.Of("Send", "Withdrawal")
+)
+
+func (p *parser) parseBooking(r []string) error {
+	rec, err := p.parseRecord(r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case buys.Has(rec.trxType):
+		p.addTrade(rec, rec.quantity, rec.subtotal.Neg())
+	case sells.Has(rec.trxType):
+		p.addTrade(rec, rec.quantity.Neg(), rec.subtotal)
+	case rec.trxType == "Convert":
+		return p.addConversion(rec)
+	case rewards.Has(rec.trxType):
+		p.addTransfer(rec, p.income, rec.quantity)
+	case transfersIn.Has(rec.trxType):
+		p.addTransfer(rec, p.registry.Accounts().TBDAccount(), rec.quantity)
+	case transfersOut.Has(rec.trxType):
+		p.addTransfer(rec, p.registry.Accounts().TBDAccount(), rec.quantity.Neg())
+	default:
+		return fmt.Errorf("unsupported transaction type %q: %v", rec.trxType, r)
+	}
+	return nil
+}
+
+func (p *parser) parseRecord(r []string) (*record, error) {
+	var (
+		rec record
+		err error
+	)
+	if rec.date, err = time.Parse(time.RFC3339, r[bfTimestamp]); err != nil {
+		if rec.date, err = time.Parse("2006-01-02 15:04:05 MST", r[bfTimestamp]); err != nil {
+			return nil, fmt.Errorf("invalid date in row %v: %w", r, err)
+		}
+	}
+	rec.date = time.Date(rec.date.Year(), rec.date.Month(), rec.date.Day(), 0, 0, 0, 0, time.UTC)
+	rec.trxType = r[bfTransactionType]
+	if rec.asset, err = p.registry.Commodities().Get(r[bfAsset]); err != nil {
+		return nil, fmt.Errorf("invalid asset in row %v: %w", r, err)
+	}
+	if rec.currency, err = p.registry.Commodities().Get(r[bfSpotPriceCurrency]); err != nil {
+		return nil, fmt.Errorf("invalid currency in row %v: %w", r, err)
+	}
+	if rec.quantity, err = parseAmount(r[bfQuantityTransacted]); err != nil {
+		return nil, fmt.Errorf("invalid quantity in row %v: %w", r, err)
+	}
+	if rec.subtotal, err = parseAmount(r[bfSubtotal]); err != nil {
+		return nil, fmt.Errorf("invalid subtotal in row %v: %w", r, err)
+	}
+	if rec.fee, err = parseAmount(r[bfFees]); err != nil {
+		return nil, fmt.Errorf("invalid fee in row %v: %w", r, err)
+	}
+	rec.quantity, rec.subtotal, rec.fee = rec.quantity.Abs(), rec.subtotal.Abs(), rec.fee.Abs()
+	rec.notes = r[bfNotes]
+	return &rec, nil
+}
+
+var amountCleaner = strings.NewReplacer(",", "", "$", "", "€", "", "£", "")
+
+// parseAmount parses an amount, ignoring currency symbols and thousands
+// separators. Empty amounts are zero.
+func parseAmount(s string) (decimal.Decimal, error) {
+	s = amountCleaner.Replace(strings.TrimSpace(s))
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(s)
+}
+
+func (p *parser) description(rec *record) string {
+	if rec.notes != "" {
+		return rec.notes
+	}
+	return fmt.Sprintf("%s %s %s", rec.trxType, rec.quantity, rec.asset.Name())
+}
+
+func (p *parser) addTrade(rec *record, quantity, proceeds decimal.Decimal) {
+	p.builder.Add(transaction.Builder{
+		Date:        rec.date,
+		Description: p.description(rec),
+		Postings: posting.Builders{
+			{
+				Credit:    p.trading,
+				Debit:     p.account,
+				Commodity: rec.asset,
+				Quantity:  quantity,
+			},
+			{
+				Credit:    p.trading,
+				Debit:     p.account,
+				Commodity: rec.currency,
+				Quantity:  proceeds,
+			},
+			{
+				Credit:    p.account,
+				Debit:     p.fee,
+				Commodity: rec.currency,
+				Quantity:  rec.fee,
+			},
+		}.Build(),
+		Targets: []*model.Commodity{rec.asset, rec.currency},
+	}.Build())
+}
+
+var conversion = regexp.MustCompile(`^Converted ([\d.,]+) (\w+) to ([\d.,]+) (\w+)$`)
+
+func (p *parser) addConversion(rec *record) error {
+	m := conversion.FindStringSubmatch(rec.notes)
+	if m == nil {
+		return fmt.Errorf("invalid conversion notes: %q", rec.notes)
+	}
+	sold, err := parseAmount(m[1])
+	if err != nil {
+		return err
+	}
+	bought, err := parseAmount(m[3])
+	if err != nil {
+		return err
+	}
+	target, err := p.registry.Commodities().Get(m[4])
+	if err != nil {
+		return err
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        rec.date,
+		Description: p.description(rec),
+		Postings: posting.Builders{
+			{
+				Credit:    p.account,
+				Debit:     p.trading,
+				Commodity: rec.asset,
+				Quantity:  sold,
+			},
+			{
+				Credit:    p.trading,
+				Debit:     p.account,
+				Commodity: target,
+				Quantity:  bought,
+			},
+		}.Build(),
+		Targets: []*model.Commodity{rec.asset, target},
+	}.Build())
+	return nil
+}
+
+func (p *parser) addTransfer(rec *record, other *model.Account, quantity decimal.Decimal) {
+	p.builder.Add(transaction.Builder{
+		Date:        rec.date,
+		Description: p.description(rec),
+		Postings: posting.Builder{
+			Credit:    other,
+			Debit:     p.account,
+			Commodity: rec.asset,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+}
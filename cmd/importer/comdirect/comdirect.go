@@ -0,0 +1,197 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package comdirect
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "de.comdirect",
+		Short: "Import comdirect CSV account statements",
+		Long: `Download the CSV file of the account statement from the online banking
+(Umsätze > Export). Only the first account of the file is imported, and
+pending bookings are skipped.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
+		return err
+	}
+	text, err := importer.DecodeText(f)
+	if err != nil {
+		return err
+	}
+	p := parser{
+		registry: reg,
+		reader:   csv.NewReader(text),
+		builder:  journal.New(),
+	}
+	if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+
+	currency *model.Commodity
+
+	// the indices of the columns; the date is in the first column
+	text, amount int
+}
+
+func (p *parser) parse() error {
+	p.reader.LazyQuotes = true
+	p.reader.Comma = ';'
+	p.reader.FieldsPerRecord = -1
+	if err := p.readHeader(); err != nil {
+		return err
+	}
+	for {
+		if err := p.readLine(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// readHeader skips the account information preceding the header and
+// determines the columns and the account currency, which is part of the
+// amount column name (e.g. "Umsatz in EUR").
+func (p *parser) readHeader() error {
+	for {
+		r, err := p.reader.Read()
+		if err == io.EOF {
+			return fmt.Errorf("no header found")
+		}
+		if err != nil {
+			return err
+		}
+		if r[0] != "Buchungstag" {
+			continue
+		}
+		cs := importer.NewColumns(r)
+		if p.text, err = cs.Index("Buchungstext"); err != nil {
+			return err
+		}
+		for i, name := range r {
+			if sym, ok := strings.CutPrefix(name, "Umsatz in "); ok {
+				p.amount = i
+				p.currency, err = p.registry.Commodities().Get(sym)
+				return err
+			}
+		}
+		return fmt.Errorf("unexpected header %q, want an amount column", r)
+	}
+}
+
+// readLine reads a booking. Pending bookings have no date. The bookings of
+// the first account end with its old balance, which is followed by the
+// bookings of the other accounts of the file.
+func (p *parser) readLine() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	if r[0] == "Offen" {
+		return nil
+	}
+	if r[0] == "Alter Kontostand" {
+		return io.EOF
+	}
+	if len(r) <= max(p.text, p.amount) {
+		return fmt.Errorf("record %q with invalid length %d", r, len(r))
+	}
+	date, err := importer.ParseGermanDate(r[0])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	quantity, err := importer.ParseGermanDecimal(r[p.amount])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: parseDescription(r[p.text]),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	return nil
+}
+
+var space = regexp.MustCompile(`\s+`)
+
+func parseDescription(s string) string {
+	return strings.TrimSpace(space.ReplaceAllString(s, " "))
+}
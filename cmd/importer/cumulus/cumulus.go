@@ -75,7 +75,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	if account, err = r.account.Value(ctx.Accounts()); err != nil {
 		return err
 	}
-	if reader, err = flags.OpenFile(args[0]); err != nil {
+	if reader, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
 		return err
 	}
 	p := parser{
@@ -0,0 +1,156 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec -- <program> [args...]",
+		Short: "Import transactions emitted as JSON by an external program",
+		Long: `Run an external importer and convert its output to knut directives.
+
+The program must write a stream of JSON transactions to stdout, for example:
+
+  {"date": "2023-01-31", "description": "Groceries",
+   "postings": [{"credit": "Assets:Bank", "debit": "Expenses:Food",
+                 "amount": "45.20", "commodity": "CHF", "comment": "optional"}]}
+
+Amounts may be given as strings or numbers. Postings without a credit or
+debit account are booked against the TBD account.`,
+
+		Args: cobra.MinimumNArgs(1),
+		RunE: run,
+	}
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	var stdout bytes.Buffer
+	c := exec.CommandContext(cmd.Context(), args[0], args[1:]...)
+	c.Dir, c.Env = env.Dir(cmd.Context()), env.Environ(cmd.Context())
+	c.Stdout = &stdout
+	c.Stderr = cmd.ErrOrStderr()
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", args[0], err)
+	}
+	reg := registry.New()
+	j := journal.New()
+	if err := parse(reg, &stdout, j); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, j.Build())
+}
+
+// Transaction is a transaction as emitted by an external importer.
+type Transaction struct {
+	Date        string    `json:"date"`
+	Description string    `json:"description"`
+	Postings    []Posting `json:"postings"`
+}
+
+// Posting is a posting as emitted by an external importer.
+type Posting struct {
+	Credit    string          `json:"credit"`
+	Debit     string          `json:"debit"`
+	Amount    decimal.Decimal `json:"amount"`
+	Commodity string          `json:"commodity"`
+	Comment   string          `json:"comment"`
+}
+
+func parse(reg *model.Registry, r io.Reader, j *journal.Builder) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	for i := 1; ; i++ {
+		var t Transaction
+		if err := dec.Decode(&t); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		trx, err := t.build(reg)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if err := j.Add(trx); err != nil {
+			return err
+		}
+	}
+}
+
+func (t Transaction) build(reg *model.Registry) (*model.Transaction, error) {
+	date, err := time.Parse("2006-01-02", t.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, want YYYY-MM-DD", t.Date)
+	}
+	if len(t.Postings) == 0 {
+		return nil, fmt.Errorf("transaction has no postings")
+	}
+	var bs posting.Builders
+	for _, p := range t.Postings {
+		b, err := p.builder(reg)
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return transaction.Builder{
+		Date:        date,
+		Description: t.Description,
+		Postings:    bs.Build(),
+	}.Build(), nil
+}
+
+func (p Posting) builder(reg *model.Registry) (posting.Builder, error) {
+	credit, err := account(reg, p.Credit)
+	if err != nil {
+		return posting.Builder{}, err
+	}
+	debit, err := account(reg, p.Debit)
+	if err != nil {
+		return posting.Builder{}, err
+	}
+	commodity, err := reg.Commodities().Get(p.Commodity)
+	if err != nil {
+		return posting.Builder{}, err
+	}
+	return posting.Builder{
+		Credit:    credit,
+		Debit:     debit,
+		Quantity:  p.Amount,
+		Commodity: commodity,
+		Comment:   p.Comment,
+	}, nil
+}
+
+func account(reg *model.Registry, name string) (*model.Account, error) {
+	if name == "" {
+		return reg.Accounts().TBDAccount(), nil
+	}
+	return reg.Accounts().Get(name)
+}
@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// DecodeText reads text as exported by German banks and returns it as UTF-8.
+// A byte order mark is removed, and text which is not valid UTF-8 is decoded
+// as Windows-1252, which agrees with ISO-8859-1 on printable characters.
+func DecodeText(r io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	if !utf8.Valid(b) {
+		if b, err = charmap.Windows1252.NewDecoder().Bytes(b); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(b), nil
+}
+
+// ParseGermanDecimal parses a number in German notation, such as -1.234,56.
+func ParseGermanDecimal(s string) (decimal.Decimal, error) {
+	s = strings.NewReplacer(".", "", ",", ".", " ", "").Replace(strings.TrimSpace(s))
+	return decimal.NewFromString(s)
+}
+
+// ParseGermanDate parses a day-first date with a four- or two-digit year,
+// such as 31.01.2024 or 31.01.24.
+func ParseGermanDate(s string) (time.Time, error) {
+	if len(s) == len("02.01.06") {
+		return time.Parse("02.01.06", s)
+	}
+	return time.Parse("02.01.2006", s)
+}
+
+// Columns maps the names of the columns of a CSV header to their index.
+type Columns map[string]int
+
+// NewColumns creates the columns of the given header. If a name occurs
+// several times, the first column is used.
+func NewColumns(header []string) Columns {
+	cs := make(Columns)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if _, ok := cs[name]; !ok {
+			cs[name] = i
+		}
+	}
+	return cs
+}
+
+// Index returns the index of the first of the given names which is a column.
+// Several names allow for different versions of an export format.
+func (cs Columns) Index(names ...string) (int, error) {
+	for _, name := range names {
+		if i, ok := cs[name]; ok {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("missing column %q", names[0])
+}
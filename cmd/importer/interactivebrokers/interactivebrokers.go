@@ -79,7 +79,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 		reg = registry.New()
 		err error
 	)
-	f, err := flags.OpenFile(args[0])
+	f, err := flags.OpenFile(cmd.Context(), args[0])
 	if err != nil {
 		return err
 	}
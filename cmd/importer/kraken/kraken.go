@@ -0,0 +1,326 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kraken
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "us.kraken",
+		Short: "Import Kraken ledger exports",
+		Long: `Export the ledger as CSV file (History > Export > Ledgers). Ledger entries are grouped
+by their reference ID. Trades are booked against the trading account, fees against the fee
+account and staking rewards against the income account. Deposits, withdrawals and transfers
+are booked against Expenses:TBD.`,
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account, fee, trading, income flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.Flags().VarP(&r.fee, "fee", "f", "account name of the fee account")
+	cmd.Flags().VarP(&r.trading, "trading", "t", "account name of the trading gain / loss account")
+	cmd.Flags().VarP(&r.income, "income", "i", "account name of the staking income account")
+	cmd.MarkFlagRequired("account")
+	cmd.MarkFlagRequired("fee")
+	cmd.MarkFlagRequired("trading")
+	cmd.MarkFlagRequired("income")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	builder := journal.New()
+	for _, path := range args {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
+			return err
+		}
+		p := parser{
+			registry: reg,
+			reader:   csv.NewReader(utfbom.SkipOnly(f)),
+			builder:  builder,
+		}
+		if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.fee, err = r.fee.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.trading, err = r.trading.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.income, err = r.income.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if err = p.parse(); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	builder  *journal.Builder
+
+	account, fee, trading, income *model.Account
+}
+
+func (p *parser) parse() error {
+	p.reader.TrimLeadingSpace = true
+	p.reader.FieldsPerRecord = len(header)
+
+	if err := p.parseHeader(); err != nil {
+		return err
+	}
+	var (
+		refs   []string
+		groups = make(map[string][]*record)
+	)
+	for {
+		r, err := p.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rec, err := p.parseRecord(r)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[rec.refID]; !ok {
+			refs = append(refs, rec.refID)
+		}
+		groups[rec.refID] = append(groups[rec.refID], rec)
+	}
+	for _, ref := range refs {
+		if err := p.parseGroup(groups[ref]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type bookingField int
+
+const (
+	bfTxID bookingField = iota
+	bfRefID
+	bfTime
+	bfType
+	bfSubtype
+	bfAClass
+	bfAsset
+	bfAmount
+	bfFee
+	bfBalance
+)
+
+var header = []string{"txid", "refid", "time", "type", "subtype", "aclass", "asset", "amount", "fee", "balance"}
+
+func (p *parser) parseHeader() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	for i := range r {
+		if r[i] != header[i] {
+			return fmt.Errorf("invalid header: %v", r)
+		}
+	}
+	return nil
+}
+
+type record struct {
+	refID, trxType string
+	date           time.Time
+	commodity      *model.Commodity
+	amount, fee    decimal.Decimal
+}
+
+func (p *parser) parseRecord(r []string) (*record, error) {
+	var (
+		rec = record{refID: r[bfRefID], trxType: r[bfType]}
+		err error
+	)
+	if rec.date, err = time.Parse("2006-01-02 15:04:05", r[bfTime]); err != nil {
+		return nil, fmt.Errorf("invalid date in row %v: %w", r, err)
+	}
+	rec.date = time.Date(rec.date.Year(), rec.date.Month(), rec.date.Day(), 0, 0, 0, 0, time.UTC)
+	if rec.commodity, err = p.registry.Commodities().Get(normalizeAsset(r[bfAsset])); err != nil {
+		return nil, fmt.Errorf("invalid asset in row %v: %w", r, err)
+	}
+	if rec.amount, err = decimal.NewFromString(r[bfAmount]); err != nil {
+		return nil, fmt.Errorf("invalid amount in row %v: %w", r, err)
+	}
+	if rec.fee, err = decimal.NewFromString(r[bfFee]); err != nil {
+		return nil, fmt.Errorf("invalid fee in row %v: %w", r, err)
+	}
+	return &rec, nil
+}
+
+// assets maps Kraken's legacy asset codes to common symbols.
+var assets = map[string]string{
+	"XXBT": "BTC",
+	"XBT":  "BTC",
+	"XXDG": "DOGE",
+	"XDG":  "DOGE",
+	"XETH": "ETH",
+	"XETC": "ETC",
+	"XLTC": "LTC",
+	"XXLM": "XLM",
+	"XXMR": "XMR",
+	"XXRP": "XRP",
+	"XZEC": "ZEC",
+	"XREP": "REP",
+	"ZEUR": "EUR",
+	"ZUSD": "USD",
+	"ZGBP": "GBP",
+	"ZCAD": "CAD",
+	"ZJPY": "JPY",
+	"ZCHF": "CHF",
+	"ZAUD": "AUD",
+}
+
+// normalizeAsset maps Kraken asset codes to common symbols. Suffixes denoting
+// staked or opt-in rewards balances (e.g. DOT.S, ETH2.S) are removed.
+func normalizeAsset(s string) string {
+	s, _, _ = strings.Cut(s, ".")
+	if s == "ETH2" {
+		s = "ETH"
+	}
+	if a, ok := assets[s]; ok {
+		return a
+	}
+	return s
+}
+
+func (p *parser) parseGroup(recs []*record) error {
+	first := recs[0]
+	switch first.trxType {
+	case "trade", "spend", "receive":
+		return p.addTrade(recs)
+	case "staking", "earn":
+		for _, rec := range recs {
+			p.addSingle(rec, p.income, "Staking reward")
+		}
+	case "deposit", "withdrawal", "transfer":
+		for _, rec := range recs {
+			p.addSingle(rec, p.registry.Accounts().TBDAccount(), strings.ToUpper(rec.trxType[:1])+rec.trxType[1:])
+		}
+	default:
+		return fmt.Errorf("unsupported ledger entry type %q (refid %s)", first.trxType, first.refID)
+	}
+	return nil
+}
+
+func (p *parser) addTrade(recs []*record) error {
+	var (
+		postings posting.Builders
+		targets  []*model.Commodity
+		words    []string
+	)
+	for _, rec := range recs {
+		if rec.trxType != "trade" && rec.trxType != "spend" && rec.trxType != "receive" {
+			return fmt.Errorf("unexpected ledger entry type %q in trade %s", rec.trxType, rec.refID)
+		}
+		postings = append(postings, posting.Builder{
+			Credit:    p.trading,
+			Debit:     p.account,
+			Commodity: rec.commodity,
+			Quantity:  rec.amount,
+		})
+		postings = append(postings, p.feePostings(rec)...)
+		targets = append(targets, rec.commodity)
+		words = append(words, fmt.Sprintf("%s %s", rec.amount, rec.commodity.Name()))
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        recs[0].date,
+		Description: fmt.Sprintf("Trade %s (%s)", strings.Join(words, " / "), recs[0].refID),
+		Postings:    postings.Build(),
+		Targets:     targets,
+	}.Build())
+	return nil
+}
+
+func (p *parser) addSingle(rec *record, other *model.Account, desc string) {
+	postings := posting.Builders{
+		{
+			Credit:    other,
+			Debit:     p.account,
+			Commodity: rec.commodity,
+			Quantity:  rec.amount,
+		},
+	}
+	postings = append(postings, p.feePostings(rec)...)
+	p.builder.Add(transaction.Builder{
+		Date:        rec.date,
+		Description: fmt.Sprintf("%s %s %s (%s)", desc, rec.amount, rec.commodity.Name(), rec.refID),
+		Postings:    postings.Build(),
+	}.Build())
+}
+
+func (p *parser) feePostings(rec *record) posting.Builders {
+	if rec.fee.IsZero() {
+		return nil
+	}
+	return posting.Builders{
+		{
+			Credit:    p.account,
+			Debit:     p.fee,
+			Commodity: rec.commodity,
+			Quantity:  rec.fee,
+		},
+	}
+}
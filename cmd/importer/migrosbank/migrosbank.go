@@ -0,0 +1,283 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrosbank
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "ch.migrosbank",
+		Short: "Import Migros Bank CSV account statements",
+		Long: `Download the CSV file of the account statement from the e-banking. The balance
+after the last booking of each day is imported as a balance assertion, or only the last
+one with --assertions last. If the statement has no balance column, the balance in the
+account information preceding the bookings is asserted at the end of the statement period.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account    flags.AccountFlag
+	assertions importer.AssertionPolicy
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	r.assertions.AddFlag(cmd, importer.AssertDaily)
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
+		return err
+	}
+	text, err := importer.DecodeText(f)
+	if err != nil {
+		return err
+	}
+	p := parser{
+		registry:   reg,
+		reader:     csv.NewReader(text),
+		builder:    journal.New(),
+		assertions: r.assertions,
+	}
+	if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+
+	// info holds the account information preceding the bookings, such as
+	// "Saldo" and "Kontoauszug bis".
+	info       map[string]string
+	currency   *model.Commodity
+	balances   importer.Balances
+	assertions importer.AssertionPolicy
+
+	// the indices of the columns; the date is in the first column, and
+	// message and balance are -1 if the statement has no such column
+	text, message, amount, balance int
+}
+
+func (p *parser) parse() error {
+	p.reader.LazyQuotes = true
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = ';'
+	p.reader.FieldsPerRecord = -1
+	if err := p.readHeader(); err != nil {
+		return err
+	}
+	p.balances = importer.Balances{Account: p.account, Commodity: p.currency}
+	for {
+		if err := p.readLine(); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+	}
+	if p.balance < 0 {
+		return p.addClosingBalance()
+	}
+	for _, a := range p.balances.Assertions(p.assertions) {
+		p.builder.Add(a)
+	}
+	return nil
+}
+
+// readHeader reads the account information preceding the header, which
+// consists of lines of the form "Key: value", and determines the columns.
+func (p *parser) readHeader() error {
+	p.info = make(map[string]string)
+	for {
+		r, err := p.reader.Read()
+		if err == io.EOF {
+			return fmt.Errorf("no header found")
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(r[0]) != "Datum" {
+			if k, v, ok := strings.Cut(r[0], ":"); ok {
+				p.info[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+			continue
+		}
+		cs := importer.NewColumns(r)
+		if p.text, err = cs.Index("Buchungstext"); err != nil {
+			return err
+		}
+		if p.amount, err = cs.Index("Betrag"); err != nil {
+			return err
+		}
+		p.message, p.balance = -1, -1
+		if i, err := cs.Index("Mitteilung"); err == nil {
+			p.message = i
+		}
+		if i, err := cs.Index("Saldo"); err == nil {
+			p.balance = i
+		}
+		return p.readCurrency()
+	}
+}
+
+// readCurrency determines the currency from the balance in the account
+// information, such as "CHF 5'233.80". It defaults to CHF.
+func (p *parser) readCurrency() error {
+	sym := "CHF"
+	if fields := strings.Fields(p.info["Saldo"]); len(fields) == 2 {
+		sym = fields[0]
+	}
+	var err error
+	p.currency, err = p.registry.Commodities().Get(sym)
+	return err
+}
+
+func (p *parser) readLine() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	// Empty lines separate the bookings from the account information.
+	if strings.TrimSpace(r[0]) == "" {
+		return nil
+	}
+	if len(r) <= max(p.text, p.message, p.amount, p.balance) {
+		return fmt.Errorf("record %q with invalid length %d", r, len(r))
+	}
+	date, err := importer.ParseGermanDate(strings.TrimSpace(r[0]))
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	quantity, err := parseDecimal(r[p.amount])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	var message string
+	if p.message >= 0 {
+		message = r[p.message]
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: parseDescription(r[p.text], message),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	if p.balance >= 0 {
+		if strings.TrimSpace(r[p.balance]) == "" {
+			p.balances.AddUnknown(date)
+			return nil
+		}
+		balance, err := parseDecimal(r[p.balance])
+		if err != nil {
+			return fmt.Errorf("%q: %w", r, err)
+		}
+		p.balances.Add(date, balance)
+	}
+	return nil
+}
+
+// addClosingBalance asserts the balance in the account information at the
+// end of the statement period, if both are given.
+func (p *parser) addClosingBalance() error {
+	if p.assertions == importer.AssertNone {
+		return nil
+	}
+	fields := strings.Fields(p.info["Saldo"])
+	end, ok := p.info["Kontoauszug bis"]
+	if len(fields) != 2 || !ok {
+		return nil
+	}
+	date, err := importer.ParseGermanDate(end)
+	if err != nil {
+		return err
+	}
+	balance, err := parseDecimal(fields[1])
+	if err != nil {
+		return err
+	}
+	p.builder.Add(&model.Assertion{
+		Date: date,
+		Balances: []model.Balance{
+			{
+				Account:   p.account,
+				Quantity:  balance,
+				Commodity: p.currency,
+			},
+		},
+	})
+	return nil
+}
+
+var space = regexp.MustCompile(`\s+`)
+
+func parseDescription(text, message string) string {
+	desc := strings.Join([]string{text, message}, " ")
+	return strings.TrimSpace(space.ReplaceAllString(desc, " "))
+}
+
+// parseDecimal parses an amount, removing the thousands separators.
+func parseDecimal(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.NewReplacer("'", "", "’", "").Replace(strings.TrimSpace(s)))
+}
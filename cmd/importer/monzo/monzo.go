@@ -0,0 +1,199 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monzo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "uk.monzo",
+		Short: "Import Monzo CSV account statements",
+		Long:  `Export the CSV file through the app (Account > Statements > Export). Card payments in foreign currencies are booked in the account currency.`,
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	builder := journal.New()
+	for _, path := range args {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
+			return err
+		}
+		p := parser{
+			registry: reg,
+			reader:   csv.NewReader(utfbom.SkipOnly(f)),
+			builder:  builder,
+		}
+		if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if err = p.parse(); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+}
+
+func (p *parser) parse() error {
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = ','
+	p.reader.FieldsPerRecord = 18
+
+	if err := p.parseHeader(); err != nil {
+		return err
+	}
+	for {
+		if err := p.parseBooking(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+type bookingField int
+
+const (
+	bfTransactionID bookingField = iota
+	bfDate
+	bfTime
+	bfType
+	bfName
+	bfEmoji
+	bfCategory
+	bfAmount
+	bfCurrency
+	bfLocalAmount
+	bfLocalCurrency
+	bfNotes
+	bfAddress
+	bfReceipt
+	bfDescription
+	bfCategorySplit
+	bfMoneyOut
+	bfMoneyIn
+)
+
+func (p *parser) parseHeader() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	header := []string{"Transaction ID", "Date", "Time", "Type", "Name", "Emoji", "Category", "Amount", "Currency", "Local amount", "Local currency", "Notes and #tags", "Address", "Receipt", "Description", "Category split", "Money Out", "Money In"}
+	for i := range r {
+		if r[i] != header[i] {
+			return fmt.Errorf("invalid header: %v", r)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseBooking() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	d, err := time.Parse("02/01/2006", r[bfDate])
+	if err != nil {
+		return fmt.Errorf("invalid date in row %v: %w", r, err)
+	}
+	c, err := p.registry.Commodities().Get(r[bfCurrency])
+	if err != nil {
+		return fmt.Errorf("invalid commodity in row %v: %v", r, err)
+	}
+	quantity, err := decimal.NewFromString(r[bfAmount])
+	if err != nil {
+		return fmt.Errorf("invalid amount in row %v: %v", r, err)
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        d,
+		Description: p.description(r),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: c,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	return nil
+}
+
+func (p *parser) description(r []string) string {
+	var words []string
+	for _, f := range []bookingField{bfName, bfNotes} {
+		if s := strings.TrimSpace(r[f]); s != "" {
+			words = append(words, s)
+		}
+	}
+	if len(words) == 0 {
+		words = append(words, strings.TrimSpace(r[bfDescription]))
+	}
+	if r[bfLocalCurrency] != "" && r[bfLocalCurrency] != r[bfCurrency] {
+		words = append(words, fmt.Sprintf("(%s %s)", r[bfLocalAmount], r[bfLocalCurrency]))
+	}
+	return strings.Join(words, " ")
+}
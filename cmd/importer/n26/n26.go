@@ -0,0 +1,179 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package n26
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "de.n26",
+		Short: "Import N26 CSV account statements",
+		Long:  `Download the CSV file through the web app (Downloads > CSV export). Amounts are booked in EUR.`,
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	builder := journal.New()
+	for _, path := range args {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
+			return err
+		}
+		p := parser{
+			registry: reg,
+			reader:   csv.NewReader(utfbom.SkipOnly(f)),
+			builder:  builder,
+		}
+		if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if err = p.parse(); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+	currency *model.Commodity
+}
+
+func (p *parser) parse() error {
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = ','
+	p.reader.FieldsPerRecord = 10
+	p.currency = p.registry.Commodities().MustGet("EUR")
+
+	if err := p.parseHeader(); err != nil {
+		return err
+	}
+	for {
+		if err := p.parseBooking(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+type bookingField int
+
+const (
+	bfDate bookingField = iota
+	bfPayee
+	bfAccountNumber
+	bfTransactionType
+	bfPaymentReference
+	bfCategory
+	bfAmountEUR
+	bfAmountForeignCurrency
+	bfTypeForeignCurrency
+	bfExchangeRate
+)
+
+func (p *parser) parseHeader() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	header := []string{"Date", "Payee", "Account number", "Transaction type", "Payment reference", "Category", "Amount (EUR)", "Amount (Foreign Currency)", "Type Foreign Currency", "Exchange Rate"}
+	for i := range r {
+		if r[i] != header[i] {
+			return fmt.Errorf("invalid header: %v", r)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseBooking() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	d, err := time.Parse("2006-01-02", r[bfDate])
+	if err != nil {
+		return fmt.Errorf("invalid date in row %v: %w", r, err)
+	}
+	quantity, err := decimal.NewFromString(r[bfAmountEUR])
+	if err != nil {
+		return fmt.Errorf("invalid amount in row %v: %v", r, err)
+	}
+	var words []string
+	for _, f := range []bookingField{bfPayee, bfPaymentReference, bfCategory} {
+		if s := strings.TrimSpace(r[f]); s != "" && s != "-" {
+			words = append(words, s)
+		}
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        d,
+		Description: strings.Join(words, " "),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	return nil
+}
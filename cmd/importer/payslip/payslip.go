@@ -0,0 +1,278 @@
+package payslip
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "payslip",
+		Short: "Import PDF payslips using extraction rules",
+		Long: `Extract the amounts of payslips with the regular expressions in a rules file, and book
+them as one transaction per payslip. PDF files are converted to text with pdftotext
+(from poppler-utils), other files are read as text. See doc/payslip.yaml for an example
+of a rules file.`,
+
+		Args: cobra.MinimumNArgs(1),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	rules string
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&r.rules, "rules", "", "YAML file with the extraction rules")
+	cmd.MarkFlagRequired("rules")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	rules, err := readRules(env.Path(cmd.Context(), r.rules))
+	if err != nil {
+		return err
+	}
+	reg := registry.New()
+	j := journal.New()
+	for _, path := range args {
+		text, err := readText(env.Path(cmd.Context(), path))
+		if err != nil {
+			return err
+		}
+		t, err := rules.extract(reg, text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := j.Add(t); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, j.Build())
+}
+
+func readText(path string) (string, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+		b, err := os.ReadFile(path)
+		return string(b), err
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.Command("pdftotext", "-layout", path, "-")
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("pdftotext %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// rules describe how to extract a transaction from the text of a payslip.
+// Each pattern must contain a capturing group, which matches the value.
+type rules struct {
+	Description string `yaml:"description"`
+	Commodity   string `yaml:"commodity"`
+
+	// Decimal is the decimal separator. All other characters except digits
+	// and a minus sign are ignored when parsing amounts.
+	Decimal string `yaml:"decimal"`
+
+	Date struct {
+		Pattern string `yaml:"pattern"`
+		Layout  string `yaml:"layout"`
+	} `yaml:"date"`
+
+	Postings []postingRule `yaml:"postings"`
+
+	// Net optionally verifies the extracted amounts against the net
+	// payment: the sum of the postings to Account must equal the value.
+	Net struct {
+		Pattern string `yaml:"pattern"`
+		Account string `yaml:"account"`
+	} `yaml:"net"`
+}
+
+type postingRule struct {
+	Name     string `yaml:"name"`
+	Pattern  string `yaml:"pattern"`
+	Credit   string `yaml:"credit"`
+	Debit    string `yaml:"debit"`
+	Optional bool   `yaml:"optional"`
+}
+
+func readRules(path string) (*rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	var r rules
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if r.Description == "" {
+		r.Description = "Salary"
+	}
+	if r.Decimal == "" {
+		r.Decimal = "."
+	}
+	if r.Date.Layout == "" {
+		r.Date.Layout = "02.01.2006"
+	}
+	if r.Commodity == "" || r.Date.Pattern == "" || len(r.Postings) == 0 {
+		return nil, fmt.Errorf("%s: commodity, date and postings are required", path)
+	}
+	return &r, nil
+}
+
+func (r *rules) extract(reg *model.Registry, text string) (*model.Transaction, error) {
+	ds, ok, err := match(r.Date.Pattern, text)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("date not found")
+	}
+	date, err := time.Parse(r.Date.Layout, ds)
+	if err != nil {
+		return nil, err
+	}
+	commodity, err := reg.Commodities().Get(r.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	var bs posting.Builders
+	for _, pr := range r.Postings {
+		amount, ok, err := r.amount(pr.Pattern, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pr.Name, err)
+		}
+		if !ok {
+			if pr.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("%s not found", pr.Name)
+		}
+		if amount.IsZero() {
+			continue
+		}
+		credit, err := reg.Accounts().Get(pr.Credit)
+		if err != nil {
+			return nil, err
+		}
+		debit, err := reg.Accounts().Get(pr.Debit)
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, posting.Builder{
+			Credit:    credit,
+			Debit:     debit,
+			Commodity: commodity,
+			Quantity:  amount.Abs(),
+			Comment:   pr.Name,
+		})
+	}
+	if err := r.verifyNet(reg, text, bs); err != nil {
+		return nil, err
+	}
+	return transaction.Builder{
+		Date:        date,
+		Description: r.Description,
+		Postings:    bs.Build(),
+	}.Build(), nil
+}
+
+func (r *rules) verifyNet(reg *model.Registry, text string, bs posting.Builders) error {
+	if r.Net.Pattern == "" {
+		return nil
+	}
+	net, ok, err := r.amount(r.Net.Pattern, text)
+	if err != nil {
+		return fmt.Errorf("net payment: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("net payment not found")
+	}
+	account, err := reg.Accounts().Get(r.Net.Account)
+	if err != nil {
+		return err
+	}
+	var sum decimal.Decimal
+	for _, b := range bs {
+		if b.Debit == account {
+			sum = sum.Add(b.Quantity)
+		}
+		if b.Credit == account {
+			sum = sum.Sub(b.Quantity)
+		}
+	}
+	if !sum.Equal(net.Abs()) {
+		return fmt.Errorf("postings to %s sum up to %s, but the net payment is %s", account, sum, net)
+	}
+	return nil
+}
+
+func (r *rules) amount(pattern, text string) (decimal.Decimal, bool, error) {
+	s, ok, err := match(pattern, text)
+	if err != nil || !ok {
+		return decimal.Zero, ok, err
+	}
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9', c == '-':
+			b.WriteRune(c)
+		case string(c) == r.Decimal:
+			b.WriteRune('.')
+		}
+	}
+	d, err := decimal.NewFromString(b.String())
+	if err != nil {
+		return decimal.Zero, false, fmt.Errorf("invalid amount %q", s)
+	}
+	return d, true, nil
+}
+
+func match(pattern, text string) (string, bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", false, err
+	}
+	if re.NumSubexp() != 1 {
+		return "", false, fmt.Errorf("pattern %q must have exactly one capturing group", pattern)
+	}
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		return "", false, nil
+	}
+	return strings.TrimSpace(m[1]), true, nil
+}
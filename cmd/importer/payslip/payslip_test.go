@@ -0,0 +1,17 @@
+package payslip
+
+import (
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestGolden(t *testing.T) {
+
+	got := cmdtest.Run(t, CreateCmd(), "--rules", "testdata/rules.yaml", "testdata/example1.input")
+
+	goldie.New(t).Assert(t, "example1", got)
+
+}
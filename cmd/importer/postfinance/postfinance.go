@@ -19,7 +19,6 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"time"
 
@@ -47,7 +46,7 @@ func CreateCmd() *cobra.Command {
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
-		Run: r.run,
+		RunE: r.run,
 	}
 	r.setupFlags(cmd)
 	return cmd
@@ -62,20 +61,13 @@ func (r *runner) setupFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("account")
 }
 
-func (r *runner) run(cmd *cobra.Command, args []string) {
-	if err := r.runE(cmd, args); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-}
-
-func (r *runner) runE(cmd *cobra.Command, args []string) error {
+func (r *runner) run(cmd *cobra.Command, args []string) error {
 	var (
 		reader *bufio.Reader
 		reg    = registry.New()
 		err    error
 	)
-	if reader, err = flags.OpenFile(args[0]); err != nil {
+	if reader, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
 		return err
 	}
 	p := Parser{
@@ -179,7 +171,6 @@ func (p *Parser) readBookingLine() (bool, error) {
 		return false, err
 	}
 	if len(rec) < 7 || len(rec) > 8 {
-		fmt.Println(len(rec), rec)
 		return false, nil
 	}
 	date, err := time.Parse("02.01.2006", rec[bfBuchungsdatum])
@@ -0,0 +1,237 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raiffeisen
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "ch.raiffeisen",
+		Short: "Import Raiffeisen CSV account statements",
+		Long: `Download the CSV file of the account statement from the e-banking, in English or
+German. The balance after the last booking of each day is imported as a balance assertion,
+or only the last one with --assertions last. The currency is taken from the account information preceding the bookings, if any, and
+defaults to CHF.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account    flags.AccountFlag
+	assertions importer.AssertionPolicy
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	r.assertions.AddFlag(cmd, importer.AssertDaily)
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
+		return err
+	}
+	text, err := importer.DecodeText(f)
+	if err != nil {
+		return err
+	}
+	p := parser{
+		registry:   reg,
+		reader:     csv.NewReader(text),
+		builder:    journal.New(),
+		assertions: r.assertions,
+	}
+	if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+
+	currency   *model.Commodity
+	balances   importer.Balances
+	assertions importer.AssertionPolicy
+
+	// the indices of the columns
+	date, text, amount, balance int
+}
+
+func (p *parser) parse() error {
+	p.reader.LazyQuotes = true
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = ';'
+	p.reader.FieldsPerRecord = -1
+	if err := p.readHeader(); err != nil {
+		return err
+	}
+	p.balances = importer.Balances{Account: p.account, Commodity: p.currency}
+	for {
+		if err := p.readLine(); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+	}
+	for _, a := range p.balances.Assertions(p.assertions) {
+		p.builder.Add(a)
+	}
+	return nil
+}
+
+// readHeader reads the account information preceding the header, which
+// consists of key-value lines such as "Währung;CHF", and determines the
+// columns.
+func (p *parser) readHeader() error {
+	sym := "CHF"
+	for {
+		r, err := p.reader.Read()
+		if err == io.EOF {
+			return fmt.Errorf("no header found")
+		}
+		if err != nil {
+			return err
+		}
+		if key := strings.TrimSpace(strings.TrimSuffix(r[0], ":")); key != "IBAN" {
+			if (key == "Währung" || key == "Currency") && len(r) > 1 {
+				sym = strings.TrimSpace(r[1])
+			}
+			continue
+		}
+		cs := importer.NewColumns(r)
+		if p.date, err = cs.Index("Booked At", "Buchungsdatum"); err != nil {
+			return err
+		}
+		if p.text, err = cs.Index("Text", "Buchungstext"); err != nil {
+			return err
+		}
+		if p.amount, err = cs.Index("Credit/Debit Amount", "Betrag"); err != nil {
+			return err
+		}
+		if p.balance, err = cs.Index("Balance", "Saldo"); err != nil {
+			return err
+		}
+		p.currency, err = p.registry.Commodities().Get(sym)
+		return err
+	}
+}
+
+func (p *parser) readLine() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	if len(r) <= max(p.date, p.text, p.amount, p.balance) {
+		return fmt.Errorf("record %q with invalid length %d", r, len(r))
+	}
+	// Lines without a date are the details of a collective booking,
+	// whose total is on the preceding line.
+	if strings.TrimSpace(r[p.date]) == "" {
+		return nil
+	}
+	date, err := parseDate(r[p.date])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	quantity, err := parseDecimal(r[p.amount])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: strings.TrimSpace(space.ReplaceAllString(r[p.text], " ")),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	if strings.TrimSpace(r[p.balance]) == "" {
+		p.balances.AddUnknown(date)
+		return nil
+	}
+	balance, err := parseDecimal(r[p.balance])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	p.balances.Add(date, balance)
+	return nil
+}
+
+var space = regexp.MustCompile(`\s+`)
+
+// parseDate parses a date, which is followed by a time in the English
+// export, such as 2023-01-03 00:00:00.0, and day-first in the German one.
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, _, ok := strings.Cut(s, " "); ok {
+		s = d
+	}
+	if strings.Contains(s, "-") {
+		return time.Parse("2006-01-02", s)
+	}
+	return importer.ParseGermanDate(s)
+}
+
+// parseDecimal parses an amount, removing the thousands separators.
+func parseDecimal(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.NewReplacer("'", "", "’", "").Replace(strings.TrimSpace(s)))
+}
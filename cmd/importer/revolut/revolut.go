@@ -41,7 +41,9 @@ func CreateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "revolut",
 		Short: "Import Revolut CSV account statements",
-		Long:  `Download one CSV file per account through their app. Make sure the app language is set to English, as they use localized formats.`,
+		Long: `Download one CSV file per account through their app. Make sure the app language is set to English, as they use localized formats.
+
+The balance at the end of each day is imported as a balance assertion, or only the last one with --assertions last.`,
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
@@ -56,11 +58,13 @@ func init() {
 }
 
 type runner struct {
-	account flags.AccountFlag
+	account    flags.AccountFlag
+	assertions importer.AssertionPolicy
 }
 
 func (r *runner) setupFlags(cmd *cobra.Command) {
 	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	r.assertions.AddFlag(cmd, importer.AssertDaily)
 	cmd.MarkFlagRequired("account")
 }
 
@@ -70,13 +74,14 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 		f   *bufio.Reader
 		err error
 	)
-	if f, err = flags.OpenFile(args[0]); err != nil {
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
 		return err
 	}
 	p := parser{
-		registry: reg,
-		reader:   csv.NewReader(f),
-		builder:  journal.New(),
+		registry:   reg,
+		reader:     csv.NewReader(f),
+		builder:    journal.New(),
+		assertions: r.assertions,
 	}
 	if p.account, err = r.account.Value(reg.Accounts()); err != nil {
 		return err
@@ -95,7 +100,9 @@ type parser struct {
 	account  *model.Account
 	builder  *journal.Builder
 	currency *model.Commodity
-	date     time.Time
+
+	balances   importer.Balances
+	assertions importer.AssertionPolicy
 }
 
 func (p *parser) parse() error {
@@ -113,9 +120,13 @@ func (p *parser) parse() error {
 	if err = p.parseHeader(r); err != nil {
 		return err
 	}
+	p.balances = importer.Balances{Account: p.account, Commodity: p.currency}
 	for {
 		if r, err = p.reader.Read(); err != nil {
 			if err == io.EOF {
+				for _, a := range p.balances.Assertions(p.assertions) {
+					p.builder.Add(a)
+				}
 				return nil
 			}
 			return err
@@ -169,22 +180,14 @@ func (p *parser) parseBooking(r []string) error {
 	if err != nil {
 		return err
 	}
-	if date != p.date {
-		balance, err := parseDecimal(r[6])
+	if strings.TrimSpace(r[bfBalance]) == "" {
+		p.balances.AddUnknown(date)
+	} else {
+		balance, err := parseDecimal(r[bfBalance])
 		if err != nil {
 			return err
 		}
-		p.builder.Add(&model.Assertion{
-			Date: date,
-			Balances: []model.Balance{
-				{
-					Account:   p.account,
-					Quantity:  balance,
-					Commodity: p.currency,
-				},
-			},
-		})
-		p.date = date
+		p.balances.Add(date, balance)
 	}
 
 	var words []string
@@ -26,7 +26,6 @@ import (
 
 	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/cmd/importer"
-	"github.com/sboehler/knut/lib/amounts"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/posting"
@@ -40,7 +39,9 @@ func CreateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "revolut2",
 		Short: "Import Revolut CSV account statements",
-		Long:  `Download one CSV file per account through their app. Make sure the app language is set to English, as they use localized formats.`,
+		Long: `Download one CSV file per account through their app. Make sure the app language is set to English, as they use localized formats.
+
+The balance at the end of each day is imported as a balance assertion for each currency, or only the last one with --assertions last.`,
 
 		RunE: r.run,
 	}
@@ -54,11 +55,13 @@ func init() {
 
 type runner struct {
 	account, feeAccount flags.AccountFlag
+	assertions          importer.AssertionPolicy
 }
 
 func (r *runner) setupFlags(cmd *cobra.Command) {
 	cmd.Flags().VarP(&r.account, "account", "a", "account name")
 	cmd.Flags().VarP(&r.feeAccount, "fee", "f", "fee account name")
+	r.assertions.AddFlag(cmd, importer.AssertDaily)
 	cmd.MarkFlagRequired("account")
 	cmd.MarkFlagRequired("fee")
 }
@@ -71,13 +74,14 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	)
 	builder := journal.New()
 	for _, path := range args {
-		if f, err = flags.OpenFile(path); err != nil {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
 			return err
 		}
 		p := parser{
-			registry: reg,
-			reader:   csv.NewReader(f),
-			builder:  builder,
+			registry:   reg,
+			reader:     csv.NewReader(f),
+			builder:    builder,
+			assertions: r.assertions,
 		}
 		if p.account, err = r.account.Value(reg.Accounts()); err != nil {
 			return err
@@ -99,14 +103,15 @@ type parser struct {
 	reader              *csv.Reader
 	account, feeAccount *model.Account
 	builder             *journal.Builder
-	balance             amounts.Amounts
+	balances            importer.CommodityBalances
+	assertions          importer.AssertionPolicy
 }
 
 func (p *parser) parse() error {
 	p.reader.TrimLeadingSpace = true
 	p.reader.Comma = ','
 	p.reader.FieldsPerRecord = 10
-	p.balance = make(amounts.Amounts)
+	p.balances = importer.CommodityBalances{Account: p.account}
 
 	if err := p.parseHeader(); err != nil {
 		return err
@@ -119,7 +124,9 @@ func (p *parser) parse() error {
 			return err
 		}
 	}
-	p.addBalances()
+	for _, a := range p.balances.Assertions(p.assertions) {
+		p.builder.Add(a)
+	}
 	return nil
 }
 
@@ -203,21 +210,6 @@ func (p *parser) parseBooking() error {
 	if err != nil {
 		return fmt.Errorf("invalid balance in row %v: %v", r, err)
 	}
-	p.balance[amounts.DateCommodityKey(d, c)] = bal
+	p.balances.Add(d, c, bal)
 	return nil
 }
-
-func (p *parser) addBalances() {
-	for k, bal := range p.balance {
-		p.builder.Add(&model.Assertion{
-			Date: k.Date,
-			Balances: []model.Balance{
-				{
-					Commodity: k.Commodity,
-					Quantity:  bal,
-					Account:   p.account,
-				},
-			},
-		})
-	}
-}
@@ -29,3 +29,11 @@ func TestGolden(t *testing.T) {
 	goldie.New(t).Assert(t, "example1", got)
 
 }
+
+func TestGoldenLast(t *testing.T) {
+
+	got := cmdtest.Run(t, CreateCmd(), "--account", "Assets:Accounts:Revolut", "--fee", "Expenses:Fees", "--assertions", "last", "testdata/example1.input")
+
+	goldie.New(t).Assert(t, "example1-last", got)
+
+}
@@ -0,0 +1,267 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revolutbusiness
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "revolut-business",
+		Short: "Import Revolut Business CSV account statements",
+		Long: `Download the CSV statement of one account through the web app (Transactions > Statement).
+Only completed transactions are imported. The columns are matched by name, as the
+set of columns varies between exports. The balance at the end of each day is imported as
+a balance assertion for each currency, or only the last one with --assertions last.`,
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account, feeAccount flags.AccountFlag
+	assertions          importer.AssertionPolicy
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.Flags().VarP(&r.feeAccount, "fee", "f", "fee account name")
+	r.assertions.AddFlag(cmd, importer.AssertDaily)
+	cmd.MarkFlagRequired("account")
+	cmd.MarkFlagRequired("fee")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	builder := journal.New()
+	for _, path := range args {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
+			return err
+		}
+		p := parser{
+			registry:   reg,
+			reader:     csv.NewReader(utfbom.SkipOnly(f)),
+			builder:    builder,
+			assertions: r.assertions,
+		}
+		if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if p.feeAccount, err = r.feeAccount.Value(reg.Accounts()); err != nil {
+			return err
+		}
+		if err = p.parse(); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, builder.Build())
+}
+
+type parser struct {
+	registry            *model.Registry
+	reader              *csv.Reader
+	account, feeAccount *model.Account
+	builder             *journal.Builder
+	balances            importer.CommodityBalances
+	assertions          importer.AssertionPolicy
+
+	// columns contains the index of each column in the header.
+	columns [numFields]int
+}
+
+type bookingField int
+
+const (
+	bfDateCompleted bookingField = iota
+	bfState
+	bfDescription
+	bfReference
+	bfCurrency
+	bfAmount
+	bfFee
+	bfFeeCurrency
+	bfBalance
+	numFields
+)
+
+var header = [numFields]string{
+	bfDateCompleted: "Date completed (UTC)",
+	bfState:         "State",
+	bfDescription:   "Description",
+	bfReference:     "Reference",
+	bfCurrency:      "Payment currency",
+	bfAmount:        "Amount",
+	bfFee:           "Fee",
+	bfFeeCurrency:   "Fee currency",
+	bfBalance:       "Balance",
+}
+
+func (p *parser) parse() error {
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = ','
+	p.balances = importer.CommodityBalances{Account: p.account}
+
+	if err := p.parseHeader(); err != nil {
+		return err
+	}
+	rows, err := p.reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	// Statements list the most recent transactions first. Process the rows
+	// in chronological order, such that the last balance of a day wins.
+	var dates []string
+	for _, r := range rows {
+		if d := p.field(r, bfDateCompleted); d != "" {
+			dates = append(dates, d)
+		}
+	}
+	if len(dates) > 0 && dates[0] > dates[len(dates)-1] {
+		slices.Reverse(rows)
+	}
+	for _, r := range rows {
+		if err := p.parseBooking(r); err != nil {
+			return err
+		}
+	}
+	for _, a := range p.balances.Assertions(p.assertions) {
+		p.builder.Add(a)
+	}
+	return nil
+}
+
+func (p *parser) parseHeader() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	for f, name := range header {
+		i := slices.Index(r, name)
+		if i < 0 {
+			return fmt.Errorf("invalid header: missing column %q in %v", name, r)
+		}
+		p.columns[f] = i
+	}
+	return nil
+}
+
+func (p *parser) field(r []string, f bookingField) string {
+	return r[p.columns[f]]
+}
+
+func (p *parser) parseBooking(r []string) error {
+	if p.field(r, bfState) != "COMPLETED" {
+		return nil
+	}
+	date := p.field(r, bfDateCompleted)
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return fmt.Errorf("invalid completed date in row %v: %w", r, err)
+	}
+	c, err := p.registry.Commodities().Get(p.field(r, bfCurrency))
+	if err != nil {
+		return fmt.Errorf("invalid commodity in row %v: %v", r, err)
+	}
+	quantity, err := decimal.NewFromString(p.field(r, bfAmount))
+	if err != nil {
+		return fmt.Errorf("invalid amount in row %v: %v", r, err)
+	}
+	postings := posting.Builders{
+		{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: c,
+			Quantity:  quantity,
+		},
+	}
+	if s := p.field(r, bfFee); s != "" {
+		fee, err := decimal.NewFromString(s)
+		if err != nil {
+			return fmt.Errorf("invalid fee in row %v: %v", r, err)
+		}
+		feeCommodity := c
+		if s := p.field(r, bfFeeCurrency); s != "" {
+			if feeCommodity, err = p.registry.Commodities().Get(s); err != nil {
+				return fmt.Errorf("invalid fee commodity in row %v: %v", r, err)
+			}
+		}
+		if !fee.IsZero() {
+			postings = append(postings, posting.Builder{
+				Credit:    p.account,
+				Debit:     p.feeAccount,
+				Commodity: feeCommodity,
+				Quantity:  fee.Abs(),
+			})
+		}
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        d,
+		Description: p.description(r),
+		Postings:    postings.Build(),
+	}.Build())
+	if s := p.field(r, bfBalance); s != "" {
+		bal, err := decimal.NewFromString(s)
+		if err != nil {
+			return fmt.Errorf("invalid balance in row %v: %v", r, err)
+		}
+		p.balances.Add(d, c, bal)
+	}
+	return nil
+}
+
+func (p *parser) description(r []string) string {
+	var words []string
+	for _, f := range []bookingField{bfDescription, bfReference} {
+		if s := strings.TrimSpace(p.field(r, f)); s != "" {
+			words = append(words, s)
+		}
+	}
+	return strings.Join(words, " ")
+}
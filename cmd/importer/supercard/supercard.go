@@ -71,7 +71,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 		err error
 	)
 
-	if f, err = flags.OpenFile(args[0]); err != nil {
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
 		return err
 	}
 	p := parser{
@@ -19,6 +19,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"time"
 
@@ -33,6 +34,8 @@ import (
 	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/registry"
 	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/model/use"
+	"github.com/sboehler/knut/lib/syntax"
 )
 
 // CreateCmd creates the command.
@@ -41,7 +44,14 @@ func CreateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "ch.swissquote",
 		Short: "Import Swissquote account reports",
-		Long:  `Parses CSV files from Swissquote's transactions overview.`,
+		Long: `Parses CSV files from Swissquote's transactions overview.
+
+With --dividend-template, dividends are booked with a template of the journal given by
+--templates, whose parameters are taken from the following arguments: security, name,
+isin, amount, tax, commodity, account, dividend and taxaccount.
+
+With --assertions daily, the balance at the end of each day is imported as a balance
+assertion for each currency, or only the last one with --assertions last.`,
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 		RunE: r.run,
@@ -56,6 +66,10 @@ func init() {
 
 type runner struct {
 	account, dividend, tax, fee, interest, trading flags.AccountFlag
+
+	templates, dividendTemplate string
+
+	assertions importer.AssertionPolicy
 }
 
 func (r *runner) setupFlags(cmd *cobra.Command) {
@@ -65,6 +79,10 @@ func (r *runner) setupFlags(cmd *cobra.Command) {
 	cmd.Flags().VarP(&r.tax, "tax", "w", "account name of the withholding tax account")
 	cmd.Flags().VarP(&r.fee, "fee", "f", "account name of the fee account")
 	cmd.Flags().VarP(&r.trading, "trading", "t", "account name of the trading gain / loss account")
+	cmd.Flags().StringVar(&r.templates, "templates", "", "journal declaring the templates")
+	cmd.Flags().StringVar(&r.dividendTemplate, "dividend-template", "", "book dividends with the given template")
+	r.assertions.AddFlag(cmd, importer.AssertNone)
+	cmd.MarkFlagsRequiredTogether("templates", "dividend-template")
 	cmd.MarkFlagRequired("account")
 	cmd.MarkFlagRequired("interest")
 	cmd.MarkFlagRequired("dividend")
@@ -79,13 +97,14 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 		f   *bufio.Reader
 		err error
 	)
-	if f, err = flags.OpenFile(args[0]); err != nil {
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
 		return err
 	}
 	p := parser{
-		registry: reg,
-		reader:   csv.NewReader(f),
-		builder:  journal.New(),
+		registry:   reg,
+		reader:     csv.NewReader(f),
+		builder:    journal.New(),
+		assertions: r.assertions,
 	}
 	if p.account, err = r.account.Value(reg.Accounts()); err != nil {
 		return err
@@ -105,6 +124,11 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	if p.trading, err = r.trading.Value(reg.Accounts()); err != nil {
 		return err
 	}
+	if r.dividendTemplate != "" {
+		if p.dividendTemplate, err = importer.Template(cmd.Context(), reg, r.templates, r.dividendTemplate); err != nil {
+			return err
+		}
+	}
 	if err = p.parse(); err != nil {
 		return err
 	}
@@ -120,12 +144,18 @@ type parser struct {
 	last     *record
 
 	account, dividend, tax, fee, interest, trading *model.Account
+
+	dividendTemplate *syntax.Template
+
+	balances   importer.CommodityBalances
+	assertions importer.AssertionPolicy
 }
 
 func (p *parser) parse() error {
 	p.reader.LazyQuotes = true
 	p.reader.Comma = ';'
 	p.reader.FieldsPerRecord = 13
+	p.balances = importer.CommodityBalances{Account: p.account}
 	// skip header
 	if _, err := p.reader.Read(); err != nil {
 		return err
@@ -133,6 +163,9 @@ func (p *parser) parse() error {
 	for {
 		err := p.readLine()
 		if err == io.EOF {
+			for _, a := range p.balances.Assertions(p.assertions) {
+				p.builder.Add(a)
+			}
 			return nil
 		}
 		if err != nil {
@@ -150,6 +183,7 @@ func (p *parser) readLine() error {
 	if err != nil {
 		return err
 	}
+	p.balances.Add(r.date, r.currency, r.balance)
 	if ok, err := p.parseTrade(r); err != nil || ok {
 		return err
 	}
@@ -340,6 +374,9 @@ func (p *parser) parseDividend(r *record) (bool, error) {
 	if !w.Has(r.trxType) {
 		return false, nil
 	}
+	if p.dividendTemplate != nil {
+		return true, p.useDividendTemplate(r)
+	}
 	postings := posting.Builders{
 		{
 			Credit:    p.dividend,
@@ -365,6 +402,34 @@ func (p *parser) parseDividend(r *record) (bool, error) {
 	return true, nil
 }
 
+// useDividendTemplate books a dividend with the dividend template.
+func (p *parser) useDividendTemplate(r *record) error {
+	ts, err := use.Instantiate(p.registry, p.dividendTemplate, r.date, map[string]string{
+		"security":   r.symbol.Name(),
+		"name":       syntax.Escape(r.name),
+		"isin":       r.isin,
+		"amount":     r.price.String(),
+		"tax":        r.fee.String(),
+		"commodity":  r.currency.Name(),
+		"account":    p.account.Name(),
+		"dividend":   p.dividend.Name(),
+		"taxaccount": p.tax.Name(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, t := range ts {
+		// Omit postings of zero, such as the tax of a dividend without
+		// withholding tax.
+		t.Postings = slices.DeleteFunc(t.Postings, func(p *model.Posting) bool {
+			return p.Quantity.IsZero()
+		})
+		t.Targets = []*model.Commodity{r.symbol}
+		p.builder.Add(t)
+	}
+	return nil
+}
+
 func (p *parser) parseCustodyFees(r *record) (bool, error) {
 	if r.trxType != "Depotgebühren" {
 		return false, nil
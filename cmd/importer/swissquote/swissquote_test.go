@@ -35,3 +35,34 @@ func TestGolden(t *testing.T) {
 
 	goldie.New(t).Assert(t, "example1", got)
 }
+
+func TestGoldenDailyAssertions(t *testing.T) {
+
+	got := cmdtest.Run(t, CreateCmd(),
+		"--account", "Assets:Swissquote",
+		"--dividend", "Income:Dividends",
+		"--fee", "Expenses:Fees",
+		"--interest", "Income:Interest",
+		"--tax", "Expenses:Tax",
+		"--trading", "Expenses:Trading",
+		"--assertions", "daily",
+		"testdata/example1.input")
+
+	goldie.New(t).Assert(t, "example1-daily", got)
+}
+
+func TestGoldenTemplate(t *testing.T) {
+
+	got := cmdtest.Run(t, CreateCmd(),
+		"--account", "Assets:Swissquote",
+		"--dividend", "Income:Dividends",
+		"--fee", "Expenses:Fees",
+		"--interest", "Income:Interest",
+		"--tax", "Expenses:Tax",
+		"--trading", "Expenses:Trading",
+		"--templates", "testdata/templates.knut",
+		"--dividend-template", "dividend",
+		"testdata/example1.input")
+
+	goldie.New(t).Assert(t, "example1-template", got)
+}
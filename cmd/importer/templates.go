@@ -0,0 +1,24 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Template returns the template with the given name declared in the journal
+// at the given path, such that importers can book entries with templates of
+// the journal.
+func Template(ctx context.Context, reg *model.Registry, path, name string) (*syntax.Template, error) {
+	if _, err := journal.FromPath(ctx, reg, path); err != nil {
+		return nil, err
+	}
+	t, ok := reg.Templates().Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown template %s", path, name)
+	}
+	return t, nil
+}
@@ -0,0 +1,275 @@
+package timetracking
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "timetracking",
+		Short: "Import billable hours from Toggl or Clockify CSV reports",
+		Long: `Export a detailed report as CSV from Toggl Track or Clockify. The billable hours are
+summed up per client and month, and booked at the client's hourly rate from the
+receivable account to the income account, on the last day of the month.`,
+
+		Args: cobra.MinimumNArgs(1),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	receivable, income flags.AccountFlag
+	commodity          flags.CommodityFlag
+	rates              map[string]string
+	defaultRate        string
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().Var(&r.receivable, "receivable", "receivable account")
+	cmd.Flags().Var(&r.income, "income", "income account")
+	cmd.Flags().Var(&r.commodity, "commodity", "commodity of the rates")
+	cmd.Flags().StringToStringVar(&r.rates, "rate", nil, "hourly rate per client, e.g. ACME=150")
+	cmd.Flags().StringVar(&r.defaultRate, "default-rate", "", "hourly rate for clients without a rate")
+	cmd.MarkFlagRequired("receivable")
+	cmd.MarkFlagRequired("income")
+	cmd.MarkFlagRequired("commodity")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	p := parser{
+		hours: make(map[key]decimal.Decimal),
+	}
+	var err error
+	if p.receivable, err = r.receivable.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.income, err = r.income.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.commodity, err = r.commodity.Value(reg); err != nil {
+		return err
+	}
+	if p.rates, err = parseRates(r.rates, r.defaultRate); err != nil {
+		return err
+	}
+	for _, path := range args {
+		f, err := flags.OpenFile(cmd.Context(), path)
+		if err != nil {
+			return err
+		}
+		if err := p.parse(csv.NewReader(f)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	j := journal.New()
+	trx, err := p.transactions()
+	if err != nil {
+		return err
+	}
+	for _, t := range trx {
+		if err := j.Add(t); err != nil {
+			return err
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, j.Build())
+}
+
+func parseRates(rates map[string]string, def string) (map[string]decimal.Decimal, error) {
+	res := make(map[string]decimal.Decimal)
+	for client, s := range rates {
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate %q for client %s", s, client)
+		}
+		res[client] = d
+	}
+	if def != "" {
+		d, err := decimal.NewFromString(def)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default rate %q", def)
+		}
+		res[""] = d
+	}
+	return res, nil
+}
+
+type key struct {
+	month  time.Time
+	client string
+}
+
+type parser struct {
+	receivable, income *model.Account
+	commodity          *model.Commodity
+	rates              map[string]decimal.Decimal
+	hours              map[key]decimal.Decimal
+}
+
+// columns are the indices of the relevant columns, which are looked up by
+// name, as Toggl and Clockify use different names and orders.
+type columns struct {
+	client, billable, start, duration int
+	decimal                           bool
+}
+
+func findColumns(header []string) (columns, error) {
+	cols := columns{client: -1, billable: -1, start: -1, duration: -1}
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) {
+		case "client":
+			cols.client = i
+		case "billable":
+			cols.billable = i
+		case "start date":
+			cols.start = i
+		case "duration":
+			if cols.duration < 0 {
+				cols.duration = i
+			}
+		case "duration (decimal)":
+			cols.duration, cols.decimal = i, true
+		}
+	}
+	if cols.client < 0 || cols.start < 0 || cols.duration < 0 {
+		return cols, fmt.Errorf("missing Client, Start date or Duration column in header %v", header)
+	}
+	return cols, nil
+}
+
+func (p *parser) parse(r *csv.Reader) error {
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	cols, err := findColumns(header)
+	if err != nil {
+		return err
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) != len(header) {
+			return fmt.Errorf("record %v has %d fields, want %d", rec, len(rec), len(header))
+		}
+		if cols.billable >= 0 && strings.EqualFold(rec[cols.billable], "no") {
+			continue
+		}
+		d, err := parseDate(rec[cols.start])
+		if err != nil {
+			return err
+		}
+		h, err := parseHours(rec[cols.duration], cols.decimal)
+		if err != nil {
+			return err
+		}
+		k := key{month: date.EndOf(d, date.Monthly), client: rec[cols.client]}
+		p.hours[k] = p.hours[k].Add(h)
+	}
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "01/02/2006", "02.01.2006"} {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseHours parses a duration in the format HH:MM:SS, or as decimal hours.
+func parseHours(s string, dec bool) (decimal.Decimal, error) {
+	if dec {
+		return decimal.NewFromString(s)
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return decimal.Zero, fmt.Errorf("invalid duration %q, want HH:MM:SS", s)
+	}
+	var seconds int64
+	for _, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid duration %q, want HH:MM:SS", s)
+		}
+		seconds = seconds*60 + n
+	}
+	return decimal.NewFromInt(seconds).Div(decimal.NewFromInt(3600)), nil
+}
+
+func (p *parser) transactions() ([]*model.Transaction, error) {
+	var keys []key
+	for k := range p.hours {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].month.Equal(keys[j].month) {
+			return keys[i].month.Before(keys[j].month)
+		}
+		return keys[i].client < keys[j].client
+	})
+	var res []*model.Transaction
+	for _, k := range keys {
+		rate, ok := p.rates[k.client]
+		if !ok {
+			if rate, ok = p.rates[""]; !ok {
+				return nil, fmt.Errorf("no rate for client %q, use --rate or --default-rate", k.client)
+			}
+		}
+		hours := p.hours[k]
+		amount := hours.Mul(rate).Round(2)
+		if amount.IsZero() {
+			continue
+		}
+		client := k.client
+		if client == "" {
+			client = "(no client)"
+		}
+		res = append(res, transaction.Builder{
+			Date:        k.month,
+			Description: fmt.Sprintf("%s: %s hours at %s %s", client, hours.StringFixed(2), rate, p.commodity.Name()),
+			Postings: posting.Builder{
+				Credit:    p.income,
+				Debit:     p.receivable,
+				Commodity: p.commodity,
+				Quantity:  amount,
+			}.Build(),
+		}.Build())
+	}
+	return res, nil
+}
@@ -0,0 +1,20 @@
+package timetracking
+
+import (
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestGolden(t *testing.T) {
+	for _, name := range []string{"toggl", "clockify"} {
+		t.Run(name, func(t *testing.T) {
+
+			got := cmdtest.Run(t, CreateCmd(), "--receivable", "Assets:Receivables", "--income", "Income:Consulting", "--commodity", "CHF", "--rate", "ACME=150", "--default-rate", "120", "testdata/"+name+".input")
+
+			goldie.New(t).Assert(t, name, got)
+		})
+	}
+}
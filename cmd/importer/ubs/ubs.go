@@ -0,0 +1,216 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ubs
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "ch.ubs",
+		Short: "Import UBS CSV account statements",
+		Long:  `Download the CSV file of the account transactions from the e-banking.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		f   *bufio.Reader
+		err error
+	)
+	if f, err = flags.OpenFile(cmd.Context(), args[0]); err != nil {
+		return err
+	}
+	p := parser{
+		registry: reg,
+		reader:   csv.NewReader(utfbom.SkipOnly(f)),
+		builder:  journal.New(),
+	}
+	if p.account, err = r.account.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return journal.Print(out, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+}
+
+type field int
+
+const (
+	fieldAbschlussdatum field = iota
+	fieldAbschlusszeit
+	fieldBuchungsdatum
+	fieldValutadatum
+	fieldWährung
+	fieldBelastung
+	fieldGutschrift
+	fieldEinzelbetrag
+	fieldSaldo
+	fieldTransaktionsNr
+	fieldBeschreibung1
+	fieldBeschreibung2
+	fieldBeschreibung3
+	fieldFussnoten
+)
+
+func (p *parser) parse() error {
+	p.reader.LazyQuotes = true
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = ';'
+	p.reader.FieldsPerRecord = -1
+	if err := p.skipSummary(); err != nil {
+		return err
+	}
+	for {
+		if err := p.readLine(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// skipSummary skips the account summary preceding the transactions, up to
+// and including the header of the transactions table.
+func (p *parser) skipSummary() error {
+	for {
+		r, err := p.reader.Read()
+		if err != nil {
+			return err
+		}
+		if r[0] == "Abschlussdatum" {
+			return nil
+		}
+		if !strings.HasSuffix(r[0], ":") {
+			return fmt.Errorf("unexpected summary line %q", r)
+		}
+	}
+}
+
+func (p *parser) readLine() error {
+	r, err := p.reader.Read()
+	if err != nil {
+		return err
+	}
+	if len(r) <= int(fieldFussnoten) {
+		return fmt.Errorf("record %q with invalid length %d", r, len(r))
+	}
+	// Lines with an individual amount are the details of a collective
+	// booking, whose total is on the preceding line.
+	if r[fieldBelastung] == "" && r[fieldGutschrift] == "" && r[fieldEinzelbetrag] != "" {
+		return nil
+	}
+	date, err := time.Parse("2006-01-02", r[fieldBuchungsdatum])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	quantity, err := parseAmount(r[fieldGutschrift], r[fieldBelastung])
+	if err != nil {
+		return fmt.Errorf("%q: %w", r, err)
+	}
+	commodity, err := p.registry.Commodities().Get(r[fieldWährung])
+	if err != nil {
+		return err
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: parseDescription(r),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: commodity,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	return nil
+}
+
+var space = regexp.MustCompile(`\s+`)
+
+func parseDescription(r []string) string {
+	desc := strings.Join([]string{r[fieldBeschreibung1], r[fieldBeschreibung2], r[fieldBeschreibung3]}, " ")
+	return strings.TrimSpace(space.ReplaceAllString(desc, " "))
+}
+
+// parseAmount parses the amount. Debits are usually exported with a
+// negative sign, but not in all versions of the export.
+func parseAmount(gutschrift, belastung string) (decimal.Decimal, error) {
+	switch {
+	case len(gutschrift) > 0 && len(belastung) == 0:
+		return parseDecimal(gutschrift)
+	case len(gutschrift) == 0 && len(belastung) > 0:
+		d, err := parseDecimal(belastung)
+		return d.Abs().Neg(), err
+	default:
+		return decimal.Zero, fmt.Errorf("invalid amount fields %q %q", gutschrift, belastung)
+	}
+}
+
+// parseDecimal parses an amount, removing the thousands separators.
+func parseDecimal(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.NewReplacer("'", "", "’", "").Replace(s))
+}
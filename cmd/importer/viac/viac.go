@@ -25,6 +25,7 @@ import (
 
 	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/registry"
@@ -70,7 +71,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	b, err := os.ReadFile(args[0])
+	b, err := os.ReadFile(env.Path(cmd.Context(), args[0]))
 	if err != nil {
 		return err
 	}
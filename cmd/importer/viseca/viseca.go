@@ -0,0 +1,286 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package viseca
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "ch.viseca",
+		Short: "Import Viseca one credit card statements",
+		Long: `Export the transactions as CSV from the one app or one.viseca.ch, or save the JSON
+response of the transactions call in the browser dev tools. The format is detected
+from the content of the file. Pending transactions are skipped, as their amounts
+may still change.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	account flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.account, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	f, err := flags.OpenFile(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	account, err := r.account.Value(reg.Accounts())
+	if err != nil {
+		return err
+	}
+	p := parser{
+		registry: reg,
+		builder:  journal.New(),
+		account:  account,
+	}
+	if err = p.parse(f); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(cmd.OutOrStdout())
+	defer w.Flush()
+	return journal.Print(w, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	account  *model.Account
+	builder  *journal.Builder
+}
+
+// record is a transaction of a statement, independent of its format.
+type record struct {
+	Date             string          `json:"date"`
+	Amount           decimal.Decimal `json:"amount"`
+	Currency         string          `json:"currency"`
+	OriginalAmount   decimal.Decimal `json:"originalAmount"`
+	OriginalCurrency string          `json:"originalCurrency"`
+	MerchantName     string          `json:"merchantName"`
+	PrettyName       string          `json:"prettyName"`
+	MerchantPlace    string          `json:"merchantPlace"`
+	Details          string          `json:"details"`
+	StateType        string          `json:"stateType"`
+	Category         struct {
+		Name string `json:"name"`
+	} `json:"pfmCategory"`
+}
+
+func (p *parser) parse(r *bufio.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	var recs []record
+	switch trimmed := bytes.TrimSpace(b); {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var resp struct {
+			Transactions []record `json:"transactions"`
+		}
+		err = json.Unmarshal(trimmed, &resp)
+		recs = resp.Transactions
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err = json.Unmarshal(trimmed, &recs)
+	default:
+		recs, err = readCSV(csv.NewReader(bytes.NewReader(b)))
+	}
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if err := p.add(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns maps the lower-case names of the CSV columns, in English and
+// German, to the fields of a record.
+var columns = map[string]func(*record, string) error{
+	"date":              func(r *record, s string) error { r.Date = s; return nil },
+	"datum":             func(r *record, s string) error { r.Date = s; return nil },
+	"amount":            func(r *record, s string) error { return parseAmount(&r.Amount, s) },
+	"betrag":            func(r *record, s string) error { return parseAmount(&r.Amount, s) },
+	"currency":          func(r *record, s string) error { r.Currency = s; return nil },
+	"währung":           func(r *record, s string) error { r.Currency = s; return nil },
+	"original amount":   func(r *record, s string) error { return parseAmount(&r.OriginalAmount, s) },
+	"originalbetrag":    func(r *record, s string) error { return parseAmount(&r.OriginalAmount, s) },
+	"original currency": func(r *record, s string) error { r.OriginalCurrency = s; return nil },
+	"originalwährung":   func(r *record, s string) error { r.OriginalCurrency = s; return nil },
+	"merchant":          func(r *record, s string) error { r.MerchantName = s; return nil },
+	"händler":           func(r *record, s string) error { r.MerchantName = s; return nil },
+	"place":             func(r *record, s string) error { r.MerchantPlace = s; return nil },
+	"ort":               func(r *record, s string) error { r.MerchantPlace = s; return nil },
+	"details":           func(r *record, s string) error { r.Details = s; return nil },
+	"category":          func(r *record, s string) error { r.Category.Name = s; return nil },
+	"kategorie":         func(r *record, s string) error { r.Category.Name = s; return nil },
+	"state":             func(r *record, s string) error { r.StateType = s; return nil },
+	"status":            func(r *record, s string) error { r.StateType = s; return nil },
+}
+
+func readCSV(r *csv.Reader) ([]record, error) {
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		setters = make([]func(*record, string) error, len(header))
+		found   = make(map[string]bool)
+	)
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(h))
+		setters[i] = columns[name]
+		found[name] = true
+	}
+	if !(found["date"] || found["datum"]) || !(found["amount"] || found["betrag"]) {
+		return nil, fmt.Errorf("missing date or amount column in header %v", header)
+	}
+	var res []record
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != len(header) {
+			return nil, fmt.Errorf("record %v has %d fields, want %d", fields, len(fields), len(header))
+		}
+		var rec record
+		for i, f := range fields {
+			if setters[i] == nil {
+				continue
+			}
+			if err := setters[i](&rec, strings.TrimSpace(f)); err != nil {
+				return nil, fmt.Errorf("invalid record %v: %w", fields, err)
+			}
+		}
+		res = append(res, rec)
+	}
+}
+
+// parseAmount parses an amount, which may contain apostrophes as thousands
+// separators.
+func parseAmount(d *decimal.Decimal, s string) error {
+	s = strings.ReplaceAll(s, "'", "")
+	if s == "" {
+		return nil
+	}
+	v, err := decimal.NewFromString(s)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	*d = v
+	return nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02", "02.01.2006"} {
+		if len(s) >= len(layout) {
+			if d, err := time.Parse(layout, s[:len(layout)]); err == nil {
+				return d, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func (p *parser) add(r record) error {
+	if r.StateType != "" && !strings.EqualFold(r.StateType, "booked") {
+		return nil
+	}
+	d, err := parseDate(r.Date)
+	if err != nil {
+		return err
+	}
+	currency := r.Currency
+	if currency == "" {
+		currency = "CHF"
+	}
+	c, err := p.registry.Commodities().Get(currency)
+	if err != nil {
+		return err
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        d,
+		Description: r.description(currency),
+		Postings: posting.Builder{
+			Credit:    p.account,
+			Debit:     p.registry.Accounts().TBDAccount(),
+			Commodity: c,
+			Quantity:  r.Amount,
+		}.Build(),
+	}.Build())
+	return nil
+}
+
+// description joins the merchant, the place, the details, the category and
+// the amount in a foreign currency.
+func (r record) description(currency string) string {
+	merchant := r.PrettyName
+	if merchant == "" {
+		merchant = r.MerchantName
+	}
+	var parts []string
+	for _, s := range []string{merchant, r.MerchantPlace, r.Details, r.Category.Name} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if r.OriginalCurrency != "" && r.OriginalCurrency != currency {
+		parts = append(parts, fmt.Sprintf("%s %s", r.OriginalAmount, r.OriginalCurrency))
+	}
+	return strings.Join(parts, " / ")
+}
@@ -81,7 +81,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	)
 	j := journal.New()
 	for _, path := range args {
-		if f, err = flags.OpenFile(path); err != nil {
+		if f, err = flags.OpenFile(cmd.Context(), path); err != nil {
 			return err
 		}
 		p := parser{
@@ -17,29 +17,65 @@ package cmd
 
 import (
 	"github.com/sboehler/knut/cmd/commands"
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/syntax"
 
 	"github.com/spf13/cobra"
 )
 
 // CreateCmd creates the command.
 func CreateCmd(version string) *cobra.Command {
+	var config string
 	c := &cobra.Command{
 		Use:     "knut",
 		Short:   "knut is a plain text accounting tool",
 		Long:    `knut is a plain text accounting tool for tracking personal finances and investments.`,
 		Version: version,
+
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The arguments are valid, so the usage is not shown for
+			// errors of the command, which are printed by the caller.
+			cmd.SilenceUsage, cmd.SilenceErrors = true, true
+			cfg, err := flags.FindConfig(cmd.Context(), config, args)
+			if err != nil || cfg == nil {
+				return err
+			}
+			return cfg.Apply(cmd)
+		},
 	}
+	c.PersistentFlags().StringVar(&config, "config", "", "file with default flag values (default: "+flags.ConfigFile+" next to the journal, or in the working directory)")
+	c.PersistentFlags().IntVar(&syntax.DefaultLimits.MaxIncludeDepth, "max-include-depth", 100, "maximum nesting depth of includes (0 for no limit)")
+	c.PersistentFlags().Int64Var(&syntax.DefaultLimits.MaxFileSize, "max-file-size", 0, "maximum size of a journal file in bytes (0 for no limit)")
+	c.PersistentFlags().Int64Var(&syntax.DefaultLimits.MaxDirectives, "max-directives", 0, "maximum number of directives in a journal (0 for no limit)")
+	flags.SetupProcessing(c)
+	c.AddCommand(commands.CreateAddCommand())
 	c.AddCommand(commands.CreateBalanceCommand())
+	c.AddCommand(commands.CreateChartCommand())
 	c.AddCommand(commands.CreateCheckCommand())
 	c.AddCommand(commands.CreateCompletionCommand(c))
+	c.AddCommand(commands.CreateDaemonCommand(func() *cobra.Command { return CreateCmd(version) }))
+	c.AddCommand(commands.CreateDoctorCommand())
+	c.AddCommand(commands.CreateDocumentsCommand())
+	c.AddCommand(commands.CreateEnvelopesCommand())
+	c.AddCommand(commands.CreateExportCommand())
 	c.AddCommand(commands.CreateFormatCommand())
 	c.AddCommand(commands.CreateImportCommand())
 	c.AddCommand(commands.CreateInferCmd())
 	c.AddCommand(commands.CreatePortfolioCommand())
+	c.AddCommand(commands.CreatePricesCommand())
 	c.AddCommand(commands.CreateFetchCommand())
+	c.AddCommand(commands.CreateRecurringCommand())
 	c.AddCommand(commands.CreateRegisterCmd())
+	c.AddCommand(commands.CreateReimburseCommand())
+	c.AddCommand(commands.CreateRunCommand(func() *cobra.Command { return CreateCmd(version) }))
+	c.AddCommand(commands.CreateSeasonalityCommand())
+	c.AddCommand(commands.CreateServeCommand(func() *cobra.Command { return CreateCmd(version) }))
+	c.AddCommand(commands.CreateSplitCommand())
+	c.AddCommand(commands.CreateStatsCommand())
+	c.AddCommand(commands.CreateToggleCommand())
 	c.AddCommand(commands.CreateTranscodeCommand())
 	c.AddCommand(commands.CreatePrintCommand())
+	flags.RegisterCompletions(c, flags.JournalArgument)
 
 	return c
 }
@@ -0,0 +1,88 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js && wasm
+
+// Command wasm is the WASM module of knut. It defines a global object knut
+// with the functions parse, balance and register:
+//
+//	knut.parse(text)             // {directives: [...], errors: [...]}
+//	knut.balance(text, options)  // {output: "..."} or {error: "..."}
+//	knut.register(text, options) // {output: "..."} or {error: "..."}
+//
+// The options are the parameters of the report links of knut serve, e.g.
+// {account: "Assets", descendants: true, from: "2023-01-01", interval:
+// "monthly", val: "CHF"}, together with format, "text" or "html".
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"syscall/js"
+
+	"github.com/sboehler/knut/lib/jsapi"
+	"github.com/sboehler/knut/lib/reports/query"
+)
+
+func main() {
+	js.Global().Set("knut", map[string]any{
+		"parse":    js.FuncOf(parse),
+		"balance":  js.FuncOf(report(jsapi.Balance)),
+		"register": js.FuncOf(report(jsapi.Register)),
+	})
+	select {}
+}
+
+func parse(_ js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return failure("missing journal text")
+	}
+	bs, err := json.Marshal(jsapi.Parse(args[0].String()))
+	if err != nil {
+		return failure(err.Error())
+	}
+	return js.Global().Get("JSON").Call("parse", string(bs))
+}
+
+type reportFunc func(context.Context, string, query.Params, string) (string, error)
+
+func report(f reportFunc) func(js.Value, []js.Value) any {
+	return func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 {
+			return failure("missing journal text")
+		}
+		vs := make(url.Values)
+		if len(args) > 1 && args[1].Type() == js.TypeObject {
+			keys := js.Global().Get("Object").Call("keys", args[1])
+			for i := 0; i < keys.Length(); i++ {
+				k := keys.Index(i).String()
+				vs.Set(k, js.Global().Call("String", args[1].Get(k)).String())
+			}
+		}
+		q, err := query.Parse(vs)
+		if err != nil {
+			return failure(err.Error())
+		}
+		out, err := f(context.Background(), args[0].String(), q, vs.Get("format"))
+		if err != nil {
+			return failure(err.Error())
+		}
+		return map[string]any{"output": out}
+	}
+}
+
+func failure(msg string) any {
+	return map[string]any{"error": msg}
+}
@@ -21,6 +21,14 @@ type Key struct {
 	Commodity      *model.Commodity
 	Valuation      *model.Commodity
 	Description    string
+	Comment        string
+
+	// Season is the index of the season of the date in a date.Cycle, used
+	// to group amounts of different years together.
+	Season int
+
+	// Virtual is true for amounts of virtual postings.
+	Virtual bool
 }
 
 func DateKey(date time.Time) Key {
@@ -157,7 +165,12 @@ type KeyMapper struct {
 	Date                 mapper.Mapper[time.Time]
 	Account, Other       mapper.Mapper[*model.Account]
 	Commodity, Valuation mapper.Mapper[*model.Commodity]
-	Description          mapper.Mapper[string]
+	Description, Comment mapper.Mapper[string]
+
+	// Season, if set, assigns the key to a season based on its date.
+	Season func(time.Time) int
+
+	Virtual mapper.Mapper[bool]
 }
 
 func (km KeyMapper) Build() mapper.Mapper[Key] {
@@ -181,6 +194,15 @@ func (km KeyMapper) Build() mapper.Mapper[Key] {
 		if km.Description != nil {
 			res.Description = km.Description(k.Description)
 		}
+		if km.Comment != nil {
+			res.Comment = km.Comment(k.Comment)
+		}
+		if km.Season != nil {
+			res.Season = km.Season(k.Date)
+		}
+		if km.Virtual != nil {
+			res.Virtual = km.Virtual(k.Virtual)
+		}
 		return res
 	}
 }
@@ -209,6 +231,18 @@ func AccountMatches(regexes []*regexp.Regexp) predicate.Predicate[Key] {
 	}
 }
 
+func AccountTypeIs(t model.AccountType) predicate.Predicate[Key] {
+	return func(k Key) bool {
+		return k.Account.Type() == t
+	}
+}
+
+// AccountIsOffBalance matches the accounts which are excluded from the
+// balance, such as budget envelopes.
+func AccountIsOffBalance(k Key) bool {
+	return k.Account.IsOffBalance()
+}
+
 func OtherAccountMatches(regexes []*regexp.Regexp) predicate.Predicate[Key] {
 	if regexes == nil {
 		return predicate.True[Key]
@@ -218,3 +252,9 @@ func OtherAccountMatches(regexes []*regexp.Regexp) predicate.Predicate[Key] {
 		return pred(k.Other)
 	}
 }
+
+func CommoditySatisfies(pred predicate.Predicate[*model.Commodity]) predicate.Predicate[Key] {
+	return func(k Key) bool {
+		return pred(k.Commodity)
+	}
+}
@@ -0,0 +1,117 @@
+package amounts
+
+import (
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// sum is an amount which is added to in place. As long as the sum and the
+// added values have small coefficients, it is kept as an int64 coefficient
+// with an exponent, like a decimal, and adding does not allocate. Otherwise,
+// the sum falls back to decimal arithmetic.
+type sum struct {
+	coef  int64
+	exp   int32
+	large bool
+	dec   decimal.Decimal
+}
+
+// maxExp is the number of digits after the decimal point up to which values
+// are added as int64 coefficients.
+const maxExp = 24
+
+// maxCoef bounds the coefficients of the values which are added as int64.
+const maxCoef = 1_000_000_000_000_000_000
+
+// bounds are the decimals with coefficients -maxCoef and maxCoef and the
+// exponents 0 to -maxExp. A decimal is compared without allocating to the
+// bounds with the same exponent.
+var bounds = func() [][2]decimal.Decimal {
+	res := make([][2]decimal.Decimal, maxExp+1)
+	for i := range res {
+		res[i] = [2]decimal.Decimal{decimal.New(-maxCoef, int32(-i)), decimal.New(maxCoef, int32(-i))}
+	}
+	return res
+}()
+
+// pow10 are the powers of ten which fit into an int64.
+var pow10 = func() []int64 {
+	res := []int64{1}
+	for res[len(res)-1] <= math.MaxInt64/10 {
+		res = append(res, res[len(res)-1]*10)
+	}
+	return res
+}()
+
+func (s *sum) add(v decimal.Decimal) {
+	if !s.large {
+		if c, e, ok := small(v); ok && s.addSmall(c, e) {
+			return
+		}
+		s.dec, s.large = decimal.New(s.coef, s.exp), true
+	}
+	s.dec = s.dec.Add(v)
+}
+
+func (s *sum) value() decimal.Decimal {
+	if s.large {
+		return s.dec
+	}
+	return decimal.New(s.coef, s.exp)
+}
+
+// small returns the coefficient and exponent of v if the coefficient is
+// smaller than maxCoef in magnitude.
+func small(v decimal.Decimal) (int64, int32, bool) {
+	e := v.Exponent()
+	if e > 0 || e < -maxExp {
+		return 0, 0, false
+	}
+	if v.Sign() != 0 {
+		b := bounds[-e]
+		if v.Cmp(b[0]) <= 0 || v.Cmp(b[1]) >= 0 {
+			return 0, 0, false
+		}
+	}
+	return v.CoefficientInt64(), e, true
+}
+
+// addSmall adds the coefficient c with exponent e to the sum, using the
+// smaller of the two exponents like decimal.Decimal.Add. It returns false if
+// the result does not fit into an int64.
+func (s *sum) addSmall(c int64, e int32) bool {
+	coef, exp := s.coef, s.exp
+	switch {
+	case e < exp:
+		var ok bool
+		if coef, ok = scale(coef, exp-e); !ok {
+			return false
+		}
+		exp = e
+	case e > exp:
+		var ok bool
+		if c, ok = scale(c, e-exp); !ok {
+			return false
+		}
+	}
+	res := coef + c
+	if (coef > 0 && c > 0 && res < 0) || (coef < 0 && c < 0 && res >= 0) {
+		return false
+	}
+	s.coef, s.exp = res, exp
+	return true
+}
+
+// scale multiplies c by 10^n, and returns false if the result does not fit
+// into an int64.
+func scale(c int64, n int32) (int64, bool) {
+	if int(n) >= len(pow10) {
+		return 0, c == 0
+	}
+	p := pow10[n]
+	if c > math.MaxInt64/p || c < math.MinInt64/p {
+		return 0, false
+	}
+	return c * p, true
+}
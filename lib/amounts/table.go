@@ -0,0 +1,172 @@
+package amounts
+
+import (
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/shopspring/decimal"
+)
+
+// Table sums amounts by key, like Amounts. Keys are encoded compactly, using
+// the identifiers of accounts and commodities and interned strings, so that
+// they are hashed as a single block of memory. Amounts are summed in place,
+// such that adding to an existing key does not allocate. The accounts and commodities
+// in a table must stem from the same registry. Like a nil map, a nil table is
+// empty and can be read, but not written.
+type Table struct {
+	index   map[compactKey]int
+	keys    []Key
+	values  []sum
+	strings map[string]uint32
+}
+
+// compactKey encodes a Key without pointers or strings.
+type compactKey struct {
+	sec                                  int64
+	nsec, season                         int32
+	account, other, commodity, valuation int32
+	description, comment                 uint32
+	virtual                              bool
+}
+
+// NewTable creates an empty table.
+func NewTable() *Table {
+	return &Table{
+		index:   make(map[compactKey]int),
+		strings: map[string]uint32{"": 0},
+	}
+}
+
+// Add adds the value to the amount of the key.
+func (t *Table) Add(k Key, v decimal.Decimal) {
+	ck, _ := t.encode(k, true)
+	i, ok := t.index[ck]
+	if !ok {
+		i = len(t.keys)
+		t.index[ck] = i
+		t.keys = append(t.keys, k)
+		t.values = append(t.values, sum{})
+	}
+	t.values[i].add(v)
+}
+
+// Insert adds the value to the amount of the key.
+func (t *Table) Insert(k Key, v decimal.Decimal) {
+	t.Add(k, v)
+}
+
+// Amount returns the amount for the given key.
+func (t *Table) Amount(k Key) decimal.Decimal {
+	if t == nil {
+		return decimal.Zero
+	}
+	ck, ok := t.encode(k, false)
+	if !ok {
+		return decimal.Zero
+	}
+	if i, ok := t.index[ck]; ok {
+		return t.values[i].value()
+	}
+	return decimal.Zero
+}
+
+// Len returns the number of keys in the table.
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.keys)
+}
+
+// Amounts returns the amounts of the table.
+func (t *Table) Amounts() Amounts {
+	res := make(Amounts, t.Len())
+	for i, k := range t.entries() {
+		res[k] = res[k].Add(t.values[i].value())
+	}
+	return res
+}
+
+func (t *Table) SumBy(pred func(k Key) bool, mapr func(k Key) Key) Amounts {
+	res := make(Amounts)
+	t.SumIntoBy(res, pred, mapr)
+	return res
+}
+
+func (t *Table) SumIntoBy(dest Amounts, pred func(k Key) bool, mapr func(k Key) Key) {
+	if pred == nil {
+		pred = predicate.True[Key]
+	}
+	if mapr == nil {
+		mapr = mapper.Identity[Key]
+	}
+	for i, key := range t.entries() {
+		if !pred(key) {
+			continue
+		}
+		mappedKey := mapr(key)
+		dest[mappedKey] = dest[mappedKey].Add(t.values[i].value())
+	}
+	for key, value := range dest {
+		if value.IsZero() {
+			delete(dest, key)
+		}
+	}
+}
+
+func (t *Table) SumOver(pred func(k Key) bool) decimal.Decimal {
+	var res decimal.Decimal
+	for i, key := range t.entries() {
+		if !pred(key) {
+			continue
+		}
+		res = res.Add(t.values[i].value())
+	}
+	return res
+}
+
+// entries returns the keys of the table. The values have the same index.
+func (t *Table) entries() []Key {
+	if t == nil {
+		return nil
+	}
+	return t.keys
+}
+
+// encode encodes the key. If intern is false, strings are not added to the
+// table, and encode fails for strings which the table does not contain.
+func (t *Table) encode(k Key, intern bool) (compactKey, bool) {
+	ck := compactKey{
+		sec:     k.Date.Unix(),
+		nsec:    int32(k.Date.Nanosecond()),
+		season:  int32(k.Season),
+		virtual: k.Virtual,
+	}
+	if k.Account != nil {
+		ck.account = int32(k.Account.ID())
+	}
+	if k.Other != nil {
+		ck.other = int32(k.Other.ID())
+	}
+	if k.Commodity != nil {
+		ck.commodity = int32(k.Commodity.ID())
+	}
+	if k.Valuation != nil {
+		ck.valuation = int32(k.Valuation.ID())
+	}
+	var ok1, ok2 bool
+	ck.description, ok1 = t.intern(k.Description, intern)
+	ck.comment, ok2 = t.intern(k.Comment, intern)
+	return ck, ok1 && ok2
+}
+
+func (t *Table) intern(s string, add bool) (uint32, bool) {
+	if id, ok := t.strings[s]; ok {
+		return id, true
+	}
+	if !add {
+		return 0, false
+	}
+	id := uint32(len(t.strings))
+	t.strings[s] = id
+	return id, true
+}
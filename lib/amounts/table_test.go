@@ -0,0 +1,156 @@
+package amounts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestTable(t *testing.T) {
+	reg := registry.New()
+	bank := reg.Accounts().MustGet("Assets:Bank")
+	equity := reg.Accounts().MustGet("Equity:Equity")
+	chf := reg.Commodities().MustGet("CHF")
+	usd := reg.Commodities().MustGet("USD")
+	d := date.Date(2023, 1, 1)
+
+	table := NewTable()
+	table.Add(Key{Date: d, Account: bank, Commodity: chf}, decimal.NewFromInt(1))
+	table.Add(Key{Date: d, Account: bank, Commodity: chf}, decimal.NewFromInt(2))
+	table.Add(Key{Date: d, Account: bank, Commodity: usd}, decimal.NewFromInt(4))
+	table.Add(Key{Date: d, Account: equity, Commodity: chf, Description: "foo"}, decimal.NewFromInt(8))
+	table.Add(Key{Account: equity, Commodity: chf}, decimal.NewFromInt(16))
+
+	if got := table.Len(); got != 4 {
+		t.Errorf("Len() = %d, want 4", got)
+	}
+	for _, test := range []struct {
+		key  Key
+		want string
+	}{
+		{Key{Date: d, Account: bank, Commodity: chf}, "3"},
+		{Key{Date: d, Account: bank, Commodity: usd}, "4"},
+		{Key{Date: d, Account: equity, Commodity: chf, Description: "foo"}, "8"},
+		{Key{Date: d, Account: equity, Commodity: chf, Description: "bar"}, "0"},
+		{Key{Date: d, Account: equity, Commodity: chf}, "0"},
+		{Key{Account: equity, Commodity: chf}, "16"},
+	} {
+		if got := table.Amount(test.key).String(); got != test.want {
+			t.Errorf("Amount(%v) = %s, want %s", test.key, got, test.want)
+		}
+	}
+	got := make(map[string]string)
+	for k, v := range table.SumBy(nil, KeyMapper{Account: mapper.Identity[*model.Account]}.Build()) {
+		got[k.Account.Name()] = v.String()
+	}
+	want := map[string]string{"Assets:Bank": "7", "Equity:Equity": "24"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SumBy() returned unexpected amounts (-want/+got):\n%s", diff)
+	}
+	if got := len(table.Amounts()); got != 4 {
+		t.Errorf("Amounts() returned %d amounts, want 4", got)
+	}
+
+	var empty *Table
+	if got := len(empty.SumBy(nil, nil)); empty.Len() != 0 || got != 0 {
+		t.Errorf("a nil table has %d keys and sums to %d amounts, want 0", empty.Len(), got)
+	}
+}
+
+func TestSum(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		values []string
+	}{
+		{desc: "integers", values: []string{"1", "2", "-4"}},
+		{desc: "mixed exponents", values: []string{"1.5", "0.25", "-3", "100.125"}},
+		{desc: "zero with exponent", values: []string{"2", "0.00"}},
+		{desc: "large coefficients", values: []string{"999999999999999999", "1", "-0.5"}},
+		{desc: "overflowing sum", values: []string{"900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "900000000000000000", "0.1"}},
+		{desc: "rescaling overflows", values: []string{"90000000000000000", "0.000000000000000001"}},
+		{desc: "small exponent", values: []string{"1", "0.0000000000000000000000000001"}},
+		{desc: "positive exponent", values: []string{"1", "1e3"}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var (
+				s    sum
+				want decimal.Decimal
+			)
+			for _, v := range test.values {
+				d := decimal.RequireFromString(v)
+				s.add(d)
+				want = want.Add(d)
+			}
+
+			got := s.value()
+
+			if !got.Equal(want) || got.Exponent() != want.Exponent() {
+				t.Errorf("sum of %v = %s (exponent %d), want %s (exponent %d)", test.values, got, got.Exponent(), want, want.Exponent())
+			}
+		})
+	}
+}
+
+// benchmarkKeys returns keys for n postings in 12 months, 200 accounts and 20
+// commodities, as they are aggregated by a monthly report.
+func benchmarkKeys(n int) []Key {
+	reg := registry.New()
+	var (
+		accounts    []*model.Account
+		commodities []*model.Commodity
+		dates       []time.Time
+	)
+	for i := 0; i < 200; i++ {
+		accounts = append(accounts, reg.Accounts().MustGet(fmt.Sprintf("Assets:Account%d", i)))
+	}
+	for i := 0; i < 20; i++ {
+		commodities = append(commodities, reg.Commodities().MustGet(fmt.Sprintf("C%d", i)))
+	}
+	for i := 1; i <= 12; i++ {
+		dates = append(dates, date.EndOf(date.Date(2023, time.Month(i), 1), date.Monthly))
+	}
+	chf := reg.Commodities().MustGet("CHF")
+	keys := make([]Key, n)
+	for i := range keys {
+		keys[i] = Key{
+			Date:      dates[i%len(dates)],
+			Account:   accounts[i%len(accounts)],
+			Other:     accounts[(i*7)%len(accounts)],
+			Commodity: commodities[i%len(commodities)],
+			Valuation: chf,
+		}
+	}
+	return keys
+}
+
+func BenchmarkAmountsAdd(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	value := decimal.NewFromInt(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		am := make(Amounts)
+		for _, k := range keys {
+			am.Add(k, value)
+		}
+	}
+}
+
+func BenchmarkTableAdd(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	value := decimal.NewFromInt(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table := NewTable()
+		for _, k := range keys {
+			table.Add(k, value)
+		}
+	}
+}
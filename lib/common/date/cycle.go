@@ -0,0 +1,75 @@
+package date
+
+import (
+	"fmt"
+	"time"
+)
+
+// Cycle is a recurring calendar pattern. Dates are grouped into the seasons of
+// a cycle regardless of their year, e.g. into weekdays or calendar months.
+type Cycle int
+
+const (
+	// ByWeekday groups dates by their day of the week, starting on Monday.
+	ByWeekday Cycle = iota
+	// ByMonth groups dates by their calendar month.
+	ByMonth
+)
+
+func (c Cycle) String() string {
+	switch c {
+	case ByWeekday:
+		return "weekday"
+	case ByMonth:
+		return "month"
+	}
+	return ""
+}
+
+// ParseCycle parses a cycle.
+func ParseCycle(s string) (Cycle, error) {
+	switch s {
+	case "weekday":
+		return ByWeekday, nil
+	case "month":
+		return ByMonth, nil
+	}
+	return 0, fmt.Errorf("invalid cycle %q, want weekday or month", s)
+}
+
+// Size returns the number of seasons in the cycle.
+func (c Cycle) Size() int {
+	if c == ByWeekday {
+		return 7
+	}
+	return 12
+}
+
+// Season returns the index of the season of the given date.
+func (c Cycle) Season(d time.Time) int {
+	if c == ByWeekday {
+		return (int(d.Weekday()) + 6) % 7
+	}
+	return int(d.Month()) - 1
+}
+
+// Label returns a short label for the season with the given index.
+func (c Cycle) Label(s int) string {
+	if c == ByWeekday {
+		return time.Weekday((s + 1) % 7).String()[:3]
+	}
+	return time.Month(s + 1).String()[:3]
+}
+
+// Occurrences returns how often each season occurs in the given period: the
+// number of days for weekdays, and the number of calendar months which
+// overlap with the period for months.
+func (c Cycle) Occurrences(p Period) []int {
+	res := make([]int, c.Size())
+	for d := p.Start; !d.After(p.End); d = d.AddDate(0, 0, 1) {
+		if c == ByWeekday || d.Day() == 1 || d.Equal(p.Start) {
+			res[c.Season(d)]++
+		}
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package date
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCycle(t *testing.T) {
+	tests := []struct {
+		cycle Cycle
+		date  time.Time
+		want  int
+		label string
+	}{
+		{ByWeekday, Date(2023, 1, 2), 0, "Mon"},
+		{ByWeekday, Date(2023, 1, 7), 5, "Sat"},
+		{ByWeekday, Date(2023, 1, 8), 6, "Sun"},
+		{ByMonth, Date(2023, 1, 31), 0, "Jan"},
+		{ByMonth, Date(2021, 12, 1), 11, "Dec"},
+	}
+	for _, test := range tests {
+		t.Run(test.date.Format("2006-01-02"), func(t *testing.T) {
+			got := test.cycle.Season(test.date)
+			if got != test.want {
+				t.Errorf("%s.Season(%s) = %d, want %d", test.cycle, test.date.Format("2006-01-02"), got, test.want)
+			}
+			if label := test.cycle.Label(got); label != test.label {
+				t.Errorf("%s.Label(%d) = %s, want %s", test.cycle, got, label, test.label)
+			}
+		})
+	}
+}
+
+func TestParseCycle(t *testing.T) {
+	for _, c := range []Cycle{ByWeekday, ByMonth} {
+		got, err := ParseCycle(c.String())
+		if err != nil || got != c {
+			t.Errorf("ParseCycle(%q) = %v, %v, want %v", c.String(), got, err, c)
+		}
+	}
+	if _, err := ParseCycle("quarter"); err == nil {
+		t.Errorf("ParseCycle(\"quarter\") returned no error")
+	}
+}
+
+func TestCycleOccurrences(t *testing.T) {
+	tests := []struct {
+		cycle  Cycle
+		period Period
+		want   []int
+	}{
+		{ByWeekday, Period{Start: Date(2023, 1, 2), End: Date(2023, 1, 15)}, []int{2, 2, 2, 2, 2, 2, 2}},
+		{ByWeekday, Period{Start: Date(2023, 1, 2), End: Date(2023, 1, 10)}, []int{2, 2, 1, 1, 1, 1, 1}},
+		{ByMonth, Period{Start: Date(2022, 11, 15), End: Date(2024, 1, 31)}, []int{2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %s", test.cycle, test.period.Start.Format("2006-01-02")), func(t *testing.T) {
+			got := test.cycle.Occurrences(test.period)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("%s.Occurrences(%v) returned unexpected diff (-want/+got):\n%s", test.cycle, test.period, diff)
+			}
+		})
+	}
+}
@@ -38,6 +38,9 @@ const (
 	Quarterly
 	// Yearly is a yearly interval.
 	Yearly
+	// WeeklyISO is a weekly interval like Weekly, but its periods are
+	// labeled with ISO week numbers.
+	WeeklyISO
 )
 
 func (p Interval) String() string {
@@ -54,6 +57,8 @@ func (p Interval) String() string {
 		return "quarterly"
 	case Yearly:
 		return "yearly"
+	case WeeklyISO:
+		return "week-iso"
 	}
 	return ""
 }
@@ -72,6 +77,8 @@ func ParseInterval(s string) (Interval, error) {
 		return Quarterly, nil
 	case "yearly":
 		return Yearly, nil
+	case "week-iso":
+		return WeeklyISO, nil
 	}
 	return Once, fmt.Errorf("invalid interval: %s", s)
 }
@@ -89,7 +96,7 @@ func StartOf(d time.Time, p Interval) time.Time {
 		return d
 	case Daily:
 		return d
-	case Weekly:
+	case Weekly, WeeklyISO:
 		x := (int(d.Weekday()) + 6) % 7
 		return d.AddDate(0, 0, -x)
 	case Monthly:
@@ -110,7 +117,7 @@ func EndOf(d time.Time, p Interval) time.Time {
 		return d
 	case Daily:
 		return d
-	case Weekly:
+	case Weekly, WeeklyISO:
 		x := (7 - int(d.Weekday())) % 7
 		return d.AddDate(0, 0, x)
 	case Monthly:
@@ -130,6 +137,68 @@ func Today() time.Time {
 	return Date(now.Year(), now.Month(), now.Day())
 }
 
+// FiscalYear is the start of the fiscal year, given as month and day. The
+// zero value represents the calendar year.
+type FiscalYear struct {
+	Month time.Month
+	Day   int
+}
+
+// ParseFiscalYear parses the start of a fiscal year in the format MM-DD.
+func ParseFiscalYear(s string) (FiscalYear, error) {
+	t, err := time.Parse("01-02", s)
+	if err != nil {
+		return FiscalYear{}, fmt.Errorf("invalid fiscal year start %q, want MM-DD", s)
+	}
+	if t.Day() > 28 {
+		return FiscalYear{}, fmt.Errorf("invalid fiscal year start %q, day must not be after the 28th", s)
+	}
+	return FiscalYear{Month: t.Month(), Day: t.Day()}, nil
+}
+
+func (fy FiscalYear) String() string {
+	if fy.isCalendar() {
+		return "01-01"
+	}
+	return fmt.Sprintf("%02d-%02d", fy.Month, fy.Day)
+}
+
+func (fy FiscalYear) isCalendar() bool {
+	return fy == FiscalYear{} || fy == FiscalYear{Month: time.January, Day: 1}
+}
+
+// StartOf returns the first date in the given period which contains the
+// receiver. Quarters and years are aligned to the fiscal year.
+func (fy FiscalYear) StartOf(d time.Time, p Interval) time.Time {
+	if fy.isCalendar() || (p != Quarterly && p != Yearly) {
+		return StartOf(d, p)
+	}
+	start := Date(d.Year(), fy.Month, fy.Day)
+	if start.After(d) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	if p == Yearly {
+		return start
+	}
+	for next := start.AddDate(0, 3, 0); !next.After(d); next = next.AddDate(0, 3, 0) {
+		start = next
+	}
+	return start
+}
+
+// EndOf returns the last date in the given period that contains the
+// receiver. Quarters and years are aligned to the fiscal year.
+func (fy FiscalYear) EndOf(d time.Time, p Interval) time.Time {
+	switch {
+	case fy.isCalendar() || (p != Quarterly && p != Yearly):
+		return EndOf(d, p)
+	case p == Yearly:
+		return fy.StartOf(d, p).AddDate(1, 0, -1)
+	default:
+		return fy.StartOf(d, p).AddDate(0, 3, -1)
+	}
+}
+
 type Period struct {
 	Start, End time.Time
 }
@@ -144,6 +213,17 @@ func (p Period) Clip(p2 Period) Period {
 	return p
 }
 
+// Union returns the smallest period containing both periods.
+func (p Period) Union(p2 Period) Period {
+	if p.Start.IsZero() || p2.Start.Before(p.Start) {
+		p.Start = p2.Start
+	}
+	if p2.End.After(p.End) {
+		p.End = p2.End
+	}
+	return p
+}
+
 func (p Period) Contains(t time.Time) bool {
 	return !t.Before(p.Start) && !t.After(p.End)
 }
@@ -159,6 +239,12 @@ func (part Partition) Contains(d time.Time) bool {
 }
 
 func NewPartition(period Period, interval Interval, last int) Partition {
+	return FiscalYear{}.Partition(period, interval, last)
+}
+
+// Partition creates a partition of the given period, with quarters and years
+// aligned to the fiscal year.
+func (fy FiscalYear) Partition(period Period, interval Interval, last int) Partition {
 	if period.Start.IsZero() {
 		panic("can't create partition with zero time")
 	}
@@ -169,7 +255,7 @@ func NewPartition(period Period, interval Interval, last int) Partition {
 		var start time.Time
 		var counter int
 		for end := period.End; !end.Before(period.Start) && !(counter >= last && last > 0); end = start.AddDate(0, 0, -1) {
-			start = StartOf(end, interval)
+			start = fy.StartOf(end, interval)
 			if start.Before(period.Start) {
 				start = period.Start
 			}
@@ -187,6 +273,28 @@ func NewPartition(period Period, interval Interval, last int) Partition {
 		periods:  periods,
 	}
 }
+
+// PreviousYear returns the partition with all periods shifted back by one
+// year. Periods ending on the last day of a month end on the last day of the
+// same month in the previous year.
+func (part Partition) PreviousYear() Partition {
+	res := Partition{
+		span:     Period{Start: previousYear(part.span.Start), End: previousYear(part.span.End)},
+		interval: part.interval,
+	}
+	for _, p := range part.periods {
+		res.periods = append(res.periods, Period{Start: previousYear(p.Start), End: previousYear(p.End)})
+	}
+	return res
+}
+
+func previousYear(d time.Time) time.Time {
+	if d == EndOf(d, Monthly) {
+		return EndOf(Date(d.Year()-1, d.Month(), 1), Monthly)
+	}
+	return Date(d.Year()-1, d.Month(), d.Day())
+}
+
 func (part Partition) Size() int {
 	return len(part.periods)
 }
@@ -212,6 +320,42 @@ func (part Partition) StartDates() []time.Time {
 	return res
 }
 
+// AnchoredDates returns, for each period, the given day of the month in which
+// the period ends, clipped to the period. Days beyond the end of a month
+// refer to the last day of that month.
+func (part Partition) AnchoredDates(day int) []time.Time {
+	var res []time.Time
+	for _, p := range part.periods {
+		d := Date(p.End.Year(), p.End.Month(), day)
+		if last := EndOf(p.End, Monthly); d.After(last) {
+			d = last
+		}
+		if d.Before(p.Start) {
+			d = p.Start
+		}
+		if d.After(p.End) {
+			d = p.End
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+// Labels returns a label for each period. Periods are labeled with their
+// end date, or with the ISO week (e.g. 2023-W05) for WeeklyISO.
+func (part Partition) Labels() []string {
+	var res []string
+	for _, p := range part.periods {
+		if part.interval == WeeklyISO {
+			year, week := p.End.ISOWeek()
+			res = append(res, fmt.Sprintf("%d-W%02d", year, week))
+		} else {
+			res = append(res, p.End.Format("2006-01-02"))
+		}
+	}
+	return res
+}
+
 func (part Partition) EndDates() []time.Time {
 	var res []time.Time
 	for _, p := range part.periods {
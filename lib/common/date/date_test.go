@@ -194,3 +194,235 @@ func TestPartitionEndDates(t *testing.T) {
 		})
 	}
 }
+
+func TestPartitionAnchoredDates(t *testing.T) {
+	tests := []struct {
+		period   Period
+		interval Interval
+		day      int
+		result   []time.Time
+	}{
+		{
+			period:   Period{Start: Date(2020, 1, 1), End: Date(2020, 3, 31)},
+			interval: Monthly,
+			day:      15,
+			result: []time.Time{
+				Date(2020, 1, 15),
+				Date(2020, 2, 15),
+				Date(2020, 3, 15),
+			},
+		},
+		{
+			period:   Period{Start: Date(2020, 1, 20), End: Date(2020, 3, 10)},
+			interval: Monthly,
+			day:      15,
+			result: []time.Time{
+				Date(2020, 1, 20),
+				Date(2020, 2, 15),
+				Date(2020, 3, 10),
+			},
+		},
+		{
+			period:   Period{Start: Date(2020, 1, 1), End: Date(2020, 4, 30)},
+			interval: Monthly,
+			day:      31,
+			result: []time.Time{
+				Date(2020, 1, 31),
+				Date(2020, 2, 29),
+				Date(2020, 3, 31),
+				Date(2020, 4, 30),
+			},
+		},
+		{
+			period:   Period{Start: Date(2020, 1, 1), End: Date(2020, 12, 31)},
+			interval: Quarterly,
+			day:      25,
+			result: []time.Time{
+				Date(2020, 3, 25),
+				Date(2020, 6, 25),
+				Date(2020, 9, 25),
+				Date(2020, 12, 25),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+			part := NewPartition(test.period, test.interval, 0)
+
+			got := part.AnchoredDates(test.day)
+
+			if diff := cmp.Diff(test.result, got); diff != "" {
+				t.Fatalf("AnchoredDates(%d): unexpected diff (+got/-want):\n%s", test.day, diff)
+			}
+		})
+	}
+}
+
+func TestFiscalYearPartitionEndDates(t *testing.T) {
+	tests := []struct {
+		fy       FiscalYear
+		period   Period
+		interval Interval
+		result   []time.Time
+	}{
+		{
+			fy:       FiscalYear{Month: 4, Day: 6},
+			period:   Period{Start: Date(2021, 1, 1), End: Date(2023, 1, 31)},
+			interval: Yearly,
+			result: []time.Time{
+				Date(2021, 4, 5),
+				Date(2022, 4, 5),
+				Date(2023, 1, 31),
+			},
+		},
+		{
+			fy:       FiscalYear{Month: 7, Day: 1},
+			period:   Period{Start: Date(2022, 7, 1), End: Date(2023, 6, 30)},
+			interval: Quarterly,
+			result: []time.Time{
+				Date(2022, 9, 30),
+				Date(2022, 12, 31),
+				Date(2023, 3, 31),
+				Date(2023, 6, 30),
+			},
+		},
+		{
+			fy:       FiscalYear{Month: 2, Day: 15},
+			period:   Period{Start: Date(2023, 1, 1), End: Date(2023, 6, 30)},
+			interval: Quarterly,
+			result: []time.Time{
+				Date(2023, 2, 14),
+				Date(2023, 5, 14),
+				Date(2023, 6, 30),
+			},
+		},
+		{
+			fy:       FiscalYear{Month: 4, Day: 1},
+			period:   Period{Start: Date(2023, 1, 1), End: Date(2023, 2, 28)},
+			interval: Monthly,
+			result: []time.Time{
+				Date(2023, 1, 31),
+				Date(2023, 2, 28),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+			part := test.fy.Partition(test.period, test.interval, 0)
+
+			got := part.EndDates()
+
+			if diff := cmp.Diff(test.result, got); diff != "" {
+				t.Fatalf("Periods(%v, %v): unexpected diff (+got/-want):\n%s", test.period, test.interval, diff)
+			}
+		})
+	}
+}
+
+func TestParseFiscalYear(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    FiscalYear
+		wantErr bool
+	}{
+		{text: "04-01", want: FiscalYear{Month: 4, Day: 1}},
+		{text: "04-06", want: FiscalYear{Month: 4, Day: 6}},
+		{text: "12-31", wantErr: true},
+		{text: "13-01", wantErr: true},
+		{text: "April", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseFiscalYear(test.text)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseFiscalYear(%q) returned error %v, want error: %t", test.text, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("ParseFiscalYear(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestPartitionLabels(t *testing.T) {
+	tests := []struct {
+		period   Period
+		interval Interval
+		result   []string
+	}{
+		{
+			period:   Period{Start: Date(2020, 12, 28), End: Date(2021, 1, 13)},
+			interval: WeeklyISO,
+			result:   []string{"2020-W53", "2021-W01", "2021-W02"},
+		},
+		{
+			period:   Period{Start: Date(2021, 1, 1), End: Date(2021, 2, 15)},
+			interval: Monthly,
+			result:   []string{"2021-01-31", "2021-02-15"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+			part := NewPartition(test.period, test.interval, 0)
+
+			got := part.Labels()
+
+			if diff := cmp.Diff(test.result, got); diff != "" {
+				t.Fatalf("Labels(%v, %v): unexpected diff (+got/-want):\n%s", test.period, test.interval, diff)
+			}
+		})
+	}
+}
+
+func TestPartitionPreviousYear(t *testing.T) {
+	part := NewPartition(Period{Start: Date(2024, 1, 15), End: Date(2024, 3, 10)}, Monthly, 0)
+
+	got := part.PreviousYear().EndDates()
+
+	want := []time.Time{Date(2023, 1, 31), Date(2023, 2, 28), Date(2023, 3, 10)}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("PreviousYear(): unexpected diff (+got/-want):\n%s", diff)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	today := Date(2023, 5, 17)
+	tests := []struct {
+		text    string
+		want    Period
+		wantErr bool
+	}{
+		{text: "2023", want: Period{Start: Date(2023, 1, 1), End: Date(2023, 12, 31)}},
+		{text: "2023-Q2", want: Period{Start: Date(2023, 4, 1), End: Date(2023, 6, 30)}},
+		{text: "2023-02", want: Period{Start: Date(2023, 2, 1), End: Date(2023, 2, 28)}},
+		{text: "2023-02-14", want: Period{Start: Date(2023, 2, 14), End: Date(2023, 2, 14)}},
+		{text: "today", want: Period{Start: Date(2023, 5, 17), End: Date(2023, 5, 17)}},
+		{text: "yesterday", want: Period{Start: Date(2023, 5, 16), End: Date(2023, 5, 16)}},
+		{text: "this-week", want: Period{Start: Date(2023, 5, 15), End: Date(2023, 5, 21)}},
+		{text: "last-week", want: Period{Start: Date(2023, 5, 8), End: Date(2023, 5, 14)}},
+		{text: "this-month", want: Period{Start: Date(2023, 5, 1), End: Date(2023, 5, 31)}},
+		{text: "last-month", want: Period{Start: Date(2023, 4, 1), End: Date(2023, 4, 30)}},
+		{text: "last-quarter", want: Period{Start: Date(2023, 1, 1), End: Date(2023, 3, 31)}},
+		{text: "last-year", want: Period{Start: Date(2022, 1, 1), End: Date(2022, 12, 31)}},
+		{text: "mtd", want: Period{Start: Date(2023, 5, 1), End: Date(2023, 5, 17)}},
+		{text: "ytd", want: Period{Start: Date(2023, 1, 1), End: Date(2023, 5, 17)}},
+		{text: "2022-Q4..2023-01", want: Period{Start: Date(2022, 10, 1), End: Date(2023, 1, 31)}},
+		{text: "2022..ytd", want: Period{Start: Date(2022, 1, 1), End: Date(2023, 5, 17)}},
+		{text: "2023..2022", wantErr: true},
+		{text: "2023-Q5", wantErr: true},
+		{text: "2023-13", wantErr: true},
+		{text: "last-decade", wantErr: true},
+		{text: "23", wantErr: true},
+		{text: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParsePeriod(test.text, today)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParsePeriod(%q) returned error %v, want error: %t", test.text, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("ParsePeriod(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package date
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParsePeriod parses a period expression, relative to the given day:
+//
+//   - a year, quarter, month or day: 2023, 2023-Q2, 2023-05, 2023-05-14
+//   - the current or previous day, week, month, quarter or year: today,
+//     yesterday, this-week, last-week, this-month, last-month, this-quarter,
+//     last-quarter, this-year, last-year
+//   - the current week, month, quarter or year up to the given day: wtd, mtd,
+//     qtd, ytd
+//   - a range of two expressions, from the start of the first to the end of
+//     the second: 2022-Q4..2023-Q1
+func ParsePeriod(s string, today time.Time) (Period, error) {
+	if from, to, ok := strings.Cut(s, ".."); ok {
+		p1, err := ParsePeriod(from, today)
+		if err != nil {
+			return Period{}, err
+		}
+		p2, err := ParsePeriod(to, today)
+		if err != nil {
+			return Period{}, err
+		}
+		if p2.End.Before(p1.Start) {
+			return Period{}, fmt.Errorf("invalid period %q: %s ends before %s starts", s, to, from)
+		}
+		return Period{Start: p1.Start, End: p2.End}, nil
+	}
+	switch s {
+	case "today":
+		return Period{Start: today, End: today}, nil
+	case "yesterday":
+		d := today.AddDate(0, 0, -1)
+		return Period{Start: d, End: d}, nil
+	case "wtd":
+		return Period{Start: StartOf(today, Weekly), End: today}, nil
+	case "mtd":
+		return Period{Start: StartOf(today, Monthly), End: today}, nil
+	case "qtd":
+		return Period{Start: StartOf(today, Quarterly), End: today}, nil
+	case "ytd":
+		return Period{Start: StartOf(today, Yearly), End: today}, nil
+	}
+	if rel, name, ok := strings.Cut(s, "-"); ok && (rel == "this" || rel == "last") {
+		var interval Interval
+		switch name {
+		case "week":
+			interval = Weekly
+		case "month":
+			interval = Monthly
+		case "quarter":
+			interval = Quarterly
+		case "year":
+			interval = Yearly
+		default:
+			return Period{}, fmt.Errorf("invalid period %q", s)
+		}
+		d := today
+		if rel == "last" {
+			d = StartOf(today, interval).AddDate(0, 0, -1)
+		}
+		return Period{Start: StartOf(d, interval), End: EndOf(d, interval)}, nil
+	}
+	if y, q, ok := strings.Cut(s, "-Q"); ok {
+		year, err1 := strconv.Atoi(y)
+		quarter, err2 := strconv.Atoi(q)
+		if len(y) != 4 || err1 != nil || err2 != nil || quarter < 1 || quarter > 4 {
+			return Period{}, fmt.Errorf("invalid quarter %q, want YYYY-QN", s)
+		}
+		d := Date(year, time.Month(3*quarter-2), 1)
+		return Period{Start: d, End: EndOf(d, Quarterly)}, nil
+	}
+	for _, f := range []struct {
+		layout   string
+		interval Interval
+	}{
+		{"2006", Yearly},
+		{"2006-01", Monthly},
+		{"2006-01-02", Daily},
+	} {
+		if len(s) != len(f.layout) {
+			continue
+		}
+		if d, err := time.Parse(f.layout, s); err == nil {
+			return Period{Start: d, End: EndOf(d, f.interval)}, nil
+		}
+	}
+	return Period{}, fmt.Errorf("invalid period %q", s)
+}
@@ -0,0 +1,99 @@
+// Package env describes the environment in which a command is executed: its
+// working directory, environment variables and terminal. By default, this is
+// the environment of the process. A command executed on behalf of another
+// process, such as by the daemon, takes the environment of that process from
+// its context instead, as it must not change the state of the process.
+package env
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sboehler/knut/lib/common/table"
+)
+
+// Env is the environment of another process.
+type Env struct {
+	// Dir is the working directory, against which relative paths are
+	// resolved.
+	Dir string
+
+	// Vars are the environment variables, in the form "key=value".
+	Vars []string
+
+	// Terminal describes the terminal to which output is written.
+	Terminal table.Terminal
+}
+
+// Current returns the environment of the process.
+func Current() (Env, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return Env{}, err
+	}
+	return Env{Dir: dir, Vars: os.Environ(), Terminal: table.DetectTerminal()}, nil
+}
+
+type key struct{}
+
+// With returns a context in which commands are executed in the given
+// environment.
+func With(ctx context.Context, e Env) context.Context {
+	return context.WithValue(ctx, key{}, e)
+}
+
+func from(ctx context.Context) (Env, bool) {
+	e, ok := ctx.Value(key{}).(Env)
+	return e, ok
+}
+
+// Path returns the path at which the named file is opened. A relative name
+// is resolved against the working directory of the context, if it has one.
+func Path(ctx context.Context, name string) string {
+	e, ok := from(ctx)
+	if !ok || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(e.Dir, name)
+}
+
+// Dir returns the working directory of the context, or the empty string for
+// the working directory of the process.
+func Dir(ctx context.Context) string {
+	e, _ := from(ctx)
+	return e.Dir
+}
+
+// Environ returns the environment variables of the context, in the form
+// "key=value".
+func Environ(ctx context.Context) []string {
+	if e, ok := from(ctx); ok {
+		return e.Vars
+	}
+	return os.Environ()
+}
+
+// Getenv returns the value of the environment variable key in the context.
+func Getenv(ctx context.Context, key string) string {
+	e, ok := from(ctx)
+	if !ok {
+		return os.Getenv(key)
+	}
+	for _, v := range e.Vars {
+		if k, value, ok := strings.Cut(v, "="); ok && k == key {
+			return value
+		}
+	}
+	return ""
+}
+
+// Terminal describes the terminal to which the output of the context is
+// written.
+func Terminal(ctx context.Context) table.Terminal {
+	if e, ok := from(ctx); ok {
+		return e.Terminal
+	}
+	return table.DetectTerminal()
+}
@@ -0,0 +1,58 @@
+package rounding
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Mode determines how numbers are rounded to a number of digits.
+type Mode int
+
+const (
+	// HalfUp rounds to the nearest number, and halfway cases away from zero,
+	// as bank statements usually do.
+	HalfUp Mode = iota
+	// HalfEven rounds to the nearest number, and halfway cases to the
+	// nearest even digit.
+	HalfEven
+	// Truncate rounds towards zero.
+	Truncate
+)
+
+func (m Mode) String() string {
+	switch m {
+	case HalfUp:
+		return "half-up"
+	case HalfEven:
+		return "half-even"
+	case Truncate:
+		return "truncate"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Parse parses a rounding mode.
+func Parse(s string) (Mode, error) {
+	switch s {
+	case "half-up":
+		return HalfUp, nil
+	case "half-even":
+		return HalfEven, nil
+	case "truncate":
+		return Truncate, nil
+	}
+	return 0, fmt.Errorf("invalid rounding mode %q, want half-up, half-even or truncate", s)
+}
+
+// Round rounds d to the given number of digits after the decimal point.
+func (m Mode) Round(d decimal.Decimal, digits int32) decimal.Decimal {
+	switch m {
+	case HalfEven:
+		return d.RoundBank(digits)
+	case Truncate:
+		return d.Truncate(digits)
+	default:
+		return d.Round(digits)
+	}
+}
@@ -0,0 +1,45 @@
+package rounding
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRound(t *testing.T) {
+	for _, test := range []struct {
+		mode   Mode
+		d      string
+		digits int32
+		want   string
+	}{
+		{HalfUp, "2.345", 2, "2.35"},
+		{HalfUp, "-2.345", 2, "-2.35"},
+		{HalfUp, "2.5", 0, "3"},
+		{HalfEven, "2.345", 2, "2.34"},
+		{HalfEven, "2.355", 2, "2.36"},
+		{HalfEven, "-2.5", 0, "-2"},
+		{Truncate, "2.349", 2, "2.34"},
+		{Truncate, "-2.349", 2, "-2.34"},
+	} {
+		t.Run(test.mode.String()+" "+test.d, func(t *testing.T) {
+			got := test.mode.Round(decimal.RequireFromString(test.d), test.digits)
+
+			if got.String() != test.want {
+				t.Errorf("Round(%s, %d) = %s, want %s", test.d, test.digits, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, m := range []Mode{HalfUp, HalfEven, Truncate} {
+		got, err := Parse(m.String())
+		if err != nil || got != m {
+			t.Errorf("Parse(%q) = %v, %v, want %v", m.String(), got, err, m)
+		}
+	}
+	if _, err := Parse("ceiling"); err == nil {
+		t.Errorf("Parse(%q) returned no error", "ceiling")
+	}
+}
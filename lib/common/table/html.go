@@ -0,0 +1,276 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/sboehler/knut/lib/common/rounding"
+	"github.com/shopspring/decimal"
+)
+
+// HTMLRenderer renders a table to a standalone HTML document. Rows with
+// indented children can be collapsed by clicking on them.
+type HTMLRenderer struct {
+	Title     string
+	Thousands bool
+	Round     int32
+
+	// Rounding determines how numbers are rounded to Round digits.
+	Rounding rounding.Mode
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th { position: sticky; top: 0; background: #fff; border-bottom: 2px solid #000; }
+th, td { padding: 0.1em 0.6em; white-space: nowrap; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+td.right { text-align: right; }
+td.center { text-align: center; }
+td.neg { color: #c00; }
+td.num a { color: inherit; text-decoration: none; }
+td.num a:hover { text-decoration: underline; }
+tr.sep td { border-top: 1px solid #000; }
+tr.parent > td:first-child { cursor: pointer; }
+tr.parent > td:first-child::before { content: "\25BE "; }
+tr.parent.collapsed > td:first-child::before { content: "\25B8 "; }
+ol.notes { font-size: smaller; }
+</style>
+<script>
+function toggle(tr) {
+  var level = +tr.dataset.level;
+  var collapsed = tr.classList.toggle("collapsed");
+  var child = false;
+  for (var r = tr.nextElementSibling; r; r = r.nextElementSibling) {
+    var l = +r.dataset.level;
+    if (l <= level) {
+      if (!child && r.dataset.cont) {
+        continue;
+      }
+      break;
+    }
+    child = true;
+    r.style.display = collapsed ? "none" : "";
+    r.classList.remove("collapsed");
+  }
+}
+</script>
+</head>
+<body>
+<table>
+`
+
+const htmlFoot = `</body>
+</html>
+`
+
+// Render renders the table to w.
+func (r *HTMLRenderer) Render(t *Table, w io.Writer) error {
+	title := r.Title
+	if title == "" {
+		title = "knut"
+	}
+	if _, err := fmt.Fprintf(w, htmlHead, html.EscapeString(title)); err != nil {
+		return err
+	}
+	var (
+		rows   []*Row
+		sep    []bool
+		levels []int
+		conts  []bool
+		pend   bool
+		level  int
+	)
+	for _, row := range t.rows {
+		if len(row.cells) == 0 {
+			continue
+		}
+		if row.cells[0].isSep() {
+			pend = true
+			continue
+		}
+		cont := false
+		switch c := row.cells[0].(type) {
+		case textCell:
+			level = c.Indent / 2
+			if c.Align != Left {
+				level = 0
+			}
+		case emptyCell:
+			cont = !isEmptyRow(row)
+			if !cont {
+				level = 0
+			}
+		}
+		rows = append(rows, row)
+		sep = append(sep, pend)
+		levels = append(levels, level)
+		conts = append(conts, cont)
+		pend = false
+	}
+	for i, row := range rows {
+		if i == 0 {
+			if err := r.renderHeader(row, w); err != nil {
+				return err
+			}
+			continue
+		}
+		parent := !conts[i] && hasChildren(levels, conts, i)
+		if err := r.renderRow(row, levels[i], conts[i], sep[i], parent, w); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "</table>\n"); err != nil {
+		return err
+	}
+	if err := r.renderFootnotes(t.footnotes, w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, htmlFoot)
+	return err
+}
+
+func (r *HTMLRenderer) renderFootnotes(notes []string, w io.Writer) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(w, `<ol class="notes">`+"\n"); err != nil {
+		return err
+	}
+	for i, n := range notes {
+		if _, err := fmt.Fprintf(w, `<li id="note-%d">%s</li>`+"\n", i+1, html.EscapeString(n)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</ol>\n")
+	return err
+}
+
+func isEmptyRow(row *Row) bool {
+	for _, c := range row.cells {
+		if _, ok := c.(emptyCell); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasChildren(levels []int, conts []bool, i int) bool {
+	for j := i + 1; j < len(levels); j++ {
+		if conts[j] {
+			continue
+		}
+		return levels[j] > levels[i]
+	}
+	return false
+}
+
+func (r *HTMLRenderer) renderHeader(row *Row, w io.Writer) error {
+	if _, err := io.WriteString(w, "<thead><tr>"); err != nil {
+		return err
+	}
+	for _, c := range row.cells {
+		s, _ := r.renderContent(c)
+		if _, err := fmt.Fprintf(w, "<th>%s</th>", s); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</tr></thead>\n")
+	return err
+}
+
+func (r *HTMLRenderer) renderRow(row *Row, level int, cont, sep, parent bool, w io.Writer) error {
+	var (
+		attrs   strings.Builder
+		classes []string
+	)
+	fmt.Fprintf(&attrs, ` data-level="%d"`, level)
+	if cont {
+		attrs.WriteString(` data-cont="1"`)
+	}
+	if sep {
+		classes = append(classes, "sep")
+	}
+	if parent {
+		classes = append(classes, "parent")
+		attrs.WriteString(` onclick="toggle(this)"`)
+	}
+	if len(classes) > 0 {
+		fmt.Fprintf(&attrs, ` class="%s"`, strings.Join(classes, " "))
+	}
+	if _, err := fmt.Fprintf(w, "<tr%s>", attrs.String()); err != nil {
+		return err
+	}
+	for _, c := range row.cells {
+		s, class := r.renderContent(c)
+		if tc, ok := c.(textCell); ok {
+			for _, n := range tc.Notes {
+				s += fmt.Sprintf(`<sup><a href="#note-%d">%d</a></sup>`, n, n)
+			}
+			if tc.Align == Left && tc.Indent > 0 {
+				s = fmt.Sprintf(`<span style="padding-left:%dem">%s</span>`, tc.Indent/2, s)
+			}
+		}
+		if nc, ok := c.(numberCell); ok && nc.link != "" && s != "" {
+			s = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(nc.link), s)
+		}
+		if class != "" {
+			class = fmt.Sprintf(` class="%s"`, class)
+		}
+		if _, err := fmt.Fprintf(w, "<td%s>%s</td>", class, s); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</tr>\n")
+	return err
+}
+
+func (r *HTMLRenderer) renderContent(c cell) (string, string) {
+	switch t := c.(type) {
+	case textCell:
+		switch t.Align {
+		case Right:
+			return html.EscapeString(t.Content), "right"
+		case Center:
+			return html.EscapeString(t.Content), "center"
+		}
+		return html.EscapeString(t.Content), ""
+	case numberCell:
+		if t.n.IsZero() {
+			return "", "num"
+		}
+		tr := TextRenderer{Thousands: r.Thousands, Round: r.Round, Rounding: r.Rounding}
+		if t.n.LessThan(decimal.Zero) {
+			return tr.numToString(t.n), "num neg"
+		}
+		return tr.numToString(t.n), "num"
+	case percentCell:
+		s := fmt.Sprintf("%.*f%%", r.Round, t.n*100)
+		if t.n < 0 {
+			return s, "num neg"
+		}
+		return s, "num"
+	}
+	return "", ""
+}
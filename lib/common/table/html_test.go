@@ -0,0 +1,62 @@
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func renderHTML(t *testing.T, r HTMLRenderer, tbl *Table) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.Render(tbl, &b); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+	return b.String()
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	tbl := New(1, 1)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().AddText("Account", Center).AddText("2024", Center)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().AddIndented("Assets", 0).AddEmpty()
+	tbl.AddRow().AddIndented("Bank <&>", 2).AddDecimal(decimal.NewFromInt(1000))
+	tbl.AddRow().AddIndented("Loan", 2).AddDecimal(decimal.NewFromInt(-50))
+	tbl.AddSeparatorRow()
+	tbl.AddRow().AddText("Total", Right).AddDecimal(decimal.NewFromInt(950))
+	tbl.AddSeparatorRow()
+
+	got := renderHTML(t, HTMLRenderer{Title: "Balance & Co"}, tbl)
+
+	for _, want := range []string{
+		"<title>Balance &amp; Co</title>",
+		"<thead><tr><th>Account</th><th>2024</th></tr></thead>\n",
+		`<tr data-level="0" onclick="toggle(this)" class="sep parent"><td>Assets</td><td></td></tr>`,
+		`<tr data-level="1"><td><span style="padding-left:1em">Bank &lt;&amp;&gt;</span></td><td class="num">1,000</td></tr>`,
+		`<tr data-level="1"><td><span style="padding-left:1em">Loan</span></td><td class="num neg">-50</td></tr>`,
+		`<tr data-level="0" class="sep"><td class="right">Total</td><td class="num">950</td></tr>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Bank <&>") {
+		t.Errorf("Render() did not escape the account name:\n%s", got)
+	}
+}
+
+func TestHTMLRendererEmptyRow(t *testing.T) {
+	tbl := New(1)
+	tbl.AddRow()
+	tbl.AddRow().AddText("Account", Center)
+	tbl.AddRow()
+	tbl.AddRow().AddIndented("Assets", 0)
+
+	got := renderHTML(t, HTMLRenderer{}, tbl)
+
+	if !strings.Contains(got, "<th>Account</th>") || !strings.Contains(got, "<td>Assets</td>") {
+		t.Errorf("Render() output does not contain the rows:\n%s", got)
+	}
+}
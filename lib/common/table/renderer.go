@@ -22,6 +22,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/fatih/color"
+	"github.com/sboehler/knut/lib/common/rounding"
 	"github.com/shopspring/decimal"
 )
 
@@ -31,16 +32,29 @@ type TextRenderer struct {
 	Color     bool
 	Thousands bool
 	Round     int32
-}
 
-var (
-	green = color.New(color.FgGreen)
-	red   = color.New(color.FgRed)
-)
+	// Rounding determines how numbers are rounded to Round digits.
+	Rounding rounding.Mode
+
+	// Theme determines the colors if Color is set. If Theme is nil, the
+	// default theme is used.
+	Theme *Theme
+
+	// MaxWidth is the maximum width of a line. If a table is wider, its
+	// columns are split into pages, which repeat the leading text columns
+	// (e.g. the account names). Text which does not fit is truncated. If
+	// MaxWidth is zero, the width is not limited.
+	MaxWidth int
+
+	// Page selects a single page to render, starting at 1. If Page is zero,
+	// all pages are rendered.
+	Page int
+}
 
 // Render renders this table to a string.
 func (r *TextRenderer) Render(t *Table, w io.Writer) error {
 	r.table = t
+	defer func() { r.table = nil }()
 	color.NoColor = !r.Color
 
 	widths := make([]int, r.table.Width())
@@ -62,8 +76,90 @@ func (r *TextRenderer) Render(t *Table, w io.Writer) error {
 			widths[i] = groups[i]
 		}
 	}
+	pages := r.paginate(widths)
+	if r.Page < 0 || r.Page > len(pages) {
+		return fmt.Errorf("page %d does not exist, the table has %d pages", r.Page, len(pages))
+	}
+	for i, page := range pages {
+		if r.Page > 0 && r.Page != i+1 {
+			continue
+		}
+		if err := r.renderPage(page, widths, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paginate splits the columns into pages which fit into MaxWidth. The
+// leading columns which contain no numbers are repeated on every page, and
+// shrunk if necessary.
+func (r *TextRenderer) paginate(widths []int) [][]int {
+	var all []int
+	for i := range widths {
+		all = append(all, i)
+	}
+	if r.MaxWidth <= 0 || lineWidth(widths, all) <= r.MaxWidth {
+		return [][]int{all}
+	}
+	var fixed []int
+	for i := range widths {
+		if !r.isTextColumn(i) || len(fixed) == len(widths)-1 {
+			break
+		}
+		fixed = append(fixed, i)
+	}
+	// Shrink the widest fixed column until at least one other column fits.
+	for len(fixed) > 0 && lineWidth(widths, append(fixed[:len(fixed):len(fixed)], len(fixed))) > r.MaxWidth {
+		widest := fixed[0]
+		for _, i := range fixed {
+			if widths[i] > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minTruncatedWidth {
+			break
+		}
+		widths[widest]--
+	}
+	var (
+		pages [][]int
+		page  = fixed[:len(fixed):len(fixed)]
+	)
+	for i := len(fixed); i < len(widths); i++ {
+		if len(page) > len(fixed) && lineWidth(widths, append(page, i)) > r.MaxWidth {
+			pages = append(pages, page)
+			page = fixed[:len(fixed):len(fixed)]
+		}
+		page = append(page, i)
+	}
+	return append(pages, page)
+}
+
+// minTruncatedWidth is the minimum width to which text columns are shrunk.
+const minTruncatedWidth = 8
+
+func (r *TextRenderer) isTextColumn(col int) bool {
+	for _, row := range r.table.rows {
+		switch row.cells[col].(type) {
+		case numberCell, percentCell:
+			return false
+		}
+	}
+	return true
+}
+
+func lineWidth(widths []int, cols []int) int {
+	res := 4 + 3*(len(cols)-1)
+	for _, c := range cols {
+		res += widths[c]
+	}
+	return res
+}
+
+func (r *TextRenderer) renderPage(cols []int, widths []int, w io.Writer) error {
 	for _, row := range r.table.rows {
-		if row.cells[0].isSep() {
+		if row.cells[cols[0]].isSep() {
 			if _, err := io.WriteString(w, "+-"); err != nil {
 				return err
 			}
@@ -73,15 +169,16 @@ func (r *TextRenderer) Render(t *Table, w io.Writer) error {
 			}
 		}
 
-		for i, c := range row.cells {
-			r.renderCell(c, widths[i], w)
-			if i < len(row.cells)-1 {
-				if _, err := io.WriteString(w, createSep(c, row.cells[i+1])); err != nil {
+		for i, col := range cols {
+			c := row.cells[col]
+			r.renderCell(c, row.total, widths[col], w)
+			if i < len(cols)-1 {
+				if _, err := io.WriteString(w, createSep(c, row.cells[cols[i+1]])); err != nil {
 					return err
 				}
 			}
 		}
-		if row.cells[len(row.cells)-1].isSep() {
+		if row.cells[cols[len(cols)-1]].isSep() {
 			if _, err := io.WriteString(w, "-+\n"); err != nil {
 				return err
 			}
@@ -92,11 +189,18 @@ func (r *TextRenderer) Render(t *Table, w io.Writer) error {
 		}
 	}
 	_, err := io.WriteString(w, "\n")
-	r.table = nil
 	return err
 }
 
-func (r *TextRenderer) renderCell(c cell, l int, w io.Writer) error {
+func (r *TextRenderer) renderCell(c cell, total bool, l int, w io.Writer) error {
+	theme := Themes["default"]
+	if r.Theme != nil {
+		theme = *r.Theme
+	}
+	var totalAttrs []color.Attribute
+	if total {
+		totalAttrs = theme.Total
+	}
 	switch t := c.(type) {
 
 	case emptyCell:
@@ -106,6 +210,7 @@ func (r *TextRenderer) renderCell(c cell, l int, w io.Writer) error {
 		return writeStrings(w, "-", l)
 
 	case textCell:
+		t = truncate(t, l)
 		var before int
 		switch t.Align {
 		case Left:
@@ -118,39 +223,67 @@ func (r *TextRenderer) renderCell(c cell, l int, w io.Writer) error {
 		if err := writeSpace(w, before); err != nil {
 			return err
 		}
-		if err := writeString(w, t.Content); err != nil {
+		var attrs []color.Attribute
+		if t.Commodity {
+			attrs = theme.Commodity
+		}
+		if err := writeStyled(w, style(attrs, totalAttrs), "%s", t.Content); err != nil {
 			return err
 		}
 		return writeSpace(w, l-before-utf8.RuneCountInString(t.Content))
 
 	case numberCell:
 		s := r.numToString(t.n)
-		var err error
 		switch {
 		case t.n.LessThan(decimal.Zero):
-			_, err = red.Fprintf(w, "%*s", l, s)
-		case t.n.Equal(decimal.Zero):
-			_, err = fmt.Fprintf(w, "%*s", l, "")
+			return writeStyled(w, style(theme.Negative, totalAttrs), "%*s", l, s)
 		case t.n.GreaterThan(decimal.Zero):
-			_, err = green.Fprintf(w, "%*s", l, s)
+			return writeStyled(w, style(theme.Positive, totalAttrs), "%*s", l, s)
+		default:
+			return writeSpace(w, l)
 		}
-		return err
 
 	case percentCell:
-		var err error
+		var attrs []color.Attribute
 		switch {
 		case t.n < 0:
-			_, err = red.Fprintf(w, "%*.*f%%", l-1, r.Round, t.n*100)
+			attrs = theme.Negative
 		case t.n > 0:
-			_, err = green.Fprintf(w, "%*.*f%%", l-1, r.Round, t.n*100)
-		case t.n == 0:
-			_, err = fmt.Fprintf(w, "%*.*f%%", l-1, r.Round, t.n*100)
+			attrs = theme.Positive
 		}
-		return err
+		return writeStyled(w, style(attrs, totalAttrs), "%*.*f%%", l-1, r.Round, t.n*100)
 	}
 	return fmt.Errorf("%v is not a valid cell type", c)
 }
 
+func writeStyled(w io.Writer, c *color.Color, format string, args ...any) error {
+	var err error
+	if c == nil {
+		_, err = fmt.Fprintf(w, format, args...)
+	} else {
+		_, err = c.Fprintf(w, format, args...)
+	}
+	return err
+}
+
+// truncate shortens the content of a text cell to the given width, marking
+// the cut with an ellipsis.
+func truncate(t textCell, l int) textCell {
+	indent := 0
+	if t.Align == Left {
+		indent = t.Indent
+	}
+	if indent+utf8.RuneCountInString(t.Content) <= l {
+		return t
+	}
+	if indent >= l {
+		indent, t.Indent = 0, 0
+	}
+	runes := []rune(t.Content)
+	t.Content = string(runes[:max(0, l-indent-1)]) + "…"
+	return t
+}
+
 func writeStrings(w io.Writer, s string, l int) error {
 	for i := 0; i < l; i++ {
 		if err := writeString(w, s); err != nil {
@@ -205,7 +338,7 @@ func (r *TextRenderer) numToString(d decimal.Decimal) string {
 	if r.Thousands {
 		d = d.Div(k)
 	}
-	return addThousandsSep(d.StringFixed(r.Round))
+	return addThousandsSep(r.Rounding.Round(d, r.Round).StringFixed(r.Round))
 }
 
 func addThousandsSep(e string) string {
@@ -23,8 +23,9 @@ type CellType int
 
 // Table is a matrix of table cells.
 type Table struct {
-	columns []int
-	rows    []*Row
+	columns   []int
+	rows      []*Row
+	footnotes []string
 }
 
 // New creates a new table with column groups.
@@ -47,7 +48,7 @@ func (t *Table) Width() int {
 func (t *Table) AddRow() *Row {
 	var (
 		cells = make([]cell, 0, t.Width())
-		row   = &Row{cells}
+		row   = &Row{cells: cells}
 	)
 	t.rows = append(t.rows, row)
 	return row
@@ -69,9 +70,16 @@ func (t *Table) AddEmptyRow() {
 	}
 }
 
+// AddFootnote adds a footnote and returns its number.
+func (t *Table) AddFootnote(text string) int {
+	t.footnotes = append(t.footnotes, text)
+	return len(t.footnotes)
+}
+
 // Row is a table row.
 type Row struct {
 	cells []cell
+	total bool
 }
 
 func (r *Row) addCell(c cell) {
@@ -94,9 +102,25 @@ func (r *Row) AddText(content string, align Alignment) *Row {
 	return r
 }
 
+// AddCommodity adds a cell with the name of a commodity.
+func (r *Row) AddCommodity(name string) *Row {
+	r.addCell(textCell{
+		Content:   name,
+		Align:     Left,
+		Commodity: true,
+	})
+	return r
+}
+
+// MarkTotal marks the row as containing totals.
+func (r *Row) MarkTotal() *Row {
+	r.total = true
+	return r
+}
+
 // AddDecimal adds a number cell.
 func (r *Row) AddDecimal(n decimal.Decimal) *Row {
-	r.addCell(numberCell{n})
+	r.addCell(numberCell{n: n})
 	return r
 }
 
@@ -115,6 +139,27 @@ func (r *Row) AddIndented(content string, indent int) *Row {
 	return r
 }
 
+// Annotate references the given footnotes from the last cell, which must
+// be a text cell.
+func (r *Row) Annotate(notes ...int) *Row {
+	if len(notes) == 0 {
+		return r
+	}
+	c := r.cells[len(r.cells)-1].(textCell)
+	c.Notes = append(c.Notes, notes...)
+	r.cells[len(r.cells)-1] = c
+	return r
+}
+
+// Link links the last cell, which must be a number cell, to the given URL.
+// Links are rendered in HTML output only.
+func (r *Row) Link(url string) *Row {
+	c := r.cells[len(r.cells)-1].(numberCell)
+	c.link = url
+	r.cells[len(r.cells)-1] = c
+	return r
+}
+
 // FillEmpty fills the row with empty cells.
 func (r *Row) FillEmpty() {
 	for i := len(r.cells); i < cap(r.cells); i++ {
@@ -143,6 +188,10 @@ type textCell struct {
 	Content string
 	Align   Alignment
 	Indent  int
+	Notes   []int
+
+	// Commodity marks cells containing the name of a commodity.
+	Commodity bool
 }
 
 func (t textCell) isSep() bool {
@@ -151,7 +200,8 @@ func (t textCell) isSep() bool {
 
 // textCell is a cell containing text.
 type numberCell struct {
-	n decimal.Decimal
+	n    decimal.Decimal
+	link string
 }
 
 func (t numberCell) isSep() bool {
@@ -14,7 +14,14 @@
 
 package table
 
-import "testing"
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
 
 func TestAddThousandsSep(t *testing.T) {
 	tests := []struct {
@@ -46,3 +53,146 @@ func TestAddThousandsSep(t *testing.T) {
 		})
 	}
 }
+
+func TestTextRendererMaxWidth(t *testing.T) {
+	tbl := New(1, 3)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().AddText("Account", Center).AddText("Q1", Center).AddText("Q2", Center).AddText("Q3", Center)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().AddIndented("Assets:Portfolio", 0).AddDecimal(decimal.NewFromInt(-100)).AddDecimal(decimal.NewFromInt(-200)).AddDecimal(decimal.NewFromInt(-300))
+	tbl.AddSeparatorRow()
+
+	tests := []struct {
+		desc     string
+		maxWidth int
+		page     int
+		want     string
+	}{
+		{
+			desc: "unlimited",
+			want: strings.Join([]string{
+				"+------------------+------+------+------+",
+				"|     Account      |  Q1  |  Q2  |  Q3  |",
+				"+------------------+------+------+------+",
+				"| Assets:Portfolio | -100 | -200 | -300 |",
+				"+------------------+------+------+------+",
+				"",
+				"",
+			}, "\n"),
+		},
+		{
+			desc:     "pages",
+			maxWidth: 36,
+			want: strings.Join([]string{
+				"+------------------+------+------+",
+				"|     Account      |  Q1  |  Q2  |",
+				"+------------------+------+------+",
+				"| Assets:Portfolio | -100 | -200 |",
+				"+------------------+------+------+",
+				"",
+				"+------------------+------+",
+				"|     Account      |  Q3  |",
+				"+------------------+------+",
+				"| Assets:Portfolio | -300 |",
+				"+------------------+------+",
+				"",
+				"",
+			}, "\n"),
+		},
+		{
+			desc:     "single page",
+			maxWidth: 36,
+			page:     2,
+			want: strings.Join([]string{
+				"+------------------+------+",
+				"|     Account      |  Q3  |",
+				"+------------------+------+",
+				"| Assets:Portfolio | -300 |",
+				"+------------------+------+",
+				"",
+				"",
+			}, "\n"),
+		},
+		{
+			desc:     "truncate",
+			maxWidth: 20,
+			want: strings.Join([]string{
+				"+-----------+------+",
+				"|  Account  |  Q1  |",
+				"+-----------+------+",
+				"| Assets:P… | -100 |",
+				"+-----------+------+",
+				"",
+				"+-----------+------+",
+				"|  Account  |  Q2  |",
+				"+-----------+------+",
+				"| Assets:P… | -200 |",
+				"+-----------+------+",
+				"",
+				"+-----------+------+",
+				"|  Account  |  Q3  |",
+				"+-----------+------+",
+				"| Assets:P… | -300 |",
+				"+-----------+------+",
+				"",
+				"",
+			}, "\n"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got strings.Builder
+			r := TextRenderer{MaxWidth: test.maxWidth, Page: test.page}
+
+			if err := r.Render(tbl, &got); err != nil {
+				t.Fatalf("Render() returned unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, got.String()); diff != "" {
+				t.Errorf("Render() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTextRendererInvalidPage(t *testing.T) {
+	tbl := New(1)
+	tbl.AddRow().AddText("foo", Left)
+	r := TextRenderer{Page: 2}
+
+	if err := r.Render(tbl, io.Discard); err == nil {
+		t.Errorf("Render() returned no error, want an error for page 2")
+	}
+}
+
+func TestTextRendererTheme(t *testing.T) {
+	tbl := New(1, 1, 1)
+	tbl.AddRow().AddText("A", Left).AddCommodity("CHF").AddDecimal(decimal.NewFromInt(-1))
+	tbl.AddRow().MarkTotal().AddText("Total", Left).AddEmpty().AddDecimal(decimal.NewFromInt(2))
+	theme, err := ParseTheme("mono")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	r := TextRenderer{Color: true, Theme: &theme}
+
+	if err := r.Render(tbl, &got); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"| A     | \x1b[2mCHF\x1b[0m | -1 |",
+		"| \x1b[1mTotal\x1b[0m |     | \x1b[1m 2\x1b[0m |",
+		"",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("Render() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	if _, err := ParseTheme("nonexistent"); err == nil {
+		t.Errorf("ParseTheme(nonexistent) returned no error")
+	}
+}
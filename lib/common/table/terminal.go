@@ -0,0 +1,34 @@
+package table
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Terminal describes the terminal to which output is written.
+type Terminal struct {
+	// Width is the width of the terminal, or 0 if the output is not a
+	// terminal, e.g. when it is piped.
+	Width int
+
+	// Color is true if the terminal shows colors and the user has not
+	// disabled them with the NO_COLOR environment variable.
+	Color bool
+}
+
+// DetectTerminal describes the terminal attached to stdout. The COLUMNS
+// environment variable takes precedence over the detected width.
+func DetectTerminal() Terminal {
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return Terminal{}
+	}
+	t := Terminal{Color: os.Getenv("NO_COLOR") == ""}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		t.Width = n
+	} else if w, err := terminalWidth(); err == nil {
+		t.Width = w
+	}
+	return t
+}
@@ -0,0 +1,10 @@
+//go:build js
+
+package table
+
+import "errors"
+
+// terminalWidth is not supported in a JavaScript environment.
+func terminalWidth() (int, error) {
+	return 0, errors.New("no terminal")
+}
@@ -0,0 +1,9 @@
+//go:build !js
+
+package table
+
+import "github.com/cheggaaa/pb/v3/termutil"
+
+func terminalWidth() (int, error) {
+	return termutil.TerminalWidth()
+}
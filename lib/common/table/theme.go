@@ -0,0 +1,66 @@
+package table
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Theme determines the colors of a table rendered as text.
+type Theme struct {
+	Positive, Negative []color.Attribute
+
+	// Commodity applies to commodity names.
+	Commodity []color.Attribute
+
+	// Total applies to all cells of total rows, in addition to the other
+	// attributes.
+	Total []color.Attribute
+}
+
+// Themes are the available color themes.
+var Themes = map[string]Theme{
+	"default": {
+		Positive:  []color.Attribute{color.FgGreen},
+		Negative:  []color.Attribute{color.FgRed},
+		Commodity: []color.Attribute{color.Faint},
+		Total:     []color.Attribute{color.Bold},
+	},
+	"minimal": {
+		Negative: []color.Attribute{color.FgRed},
+		Total:    []color.Attribute{color.Bold},
+	},
+	"mono": {
+		Commodity: []color.Attribute{color.Faint},
+		Total:     []color.Attribute{color.Bold},
+	},
+}
+
+// ParseTheme returns the theme with the given name.
+func ParseTheme(name string) (Theme, error) {
+	t, ok := Themes[name]
+	if !ok {
+		var names []string
+		for n := range Themes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Theme{}, fmt.Errorf("invalid theme %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	return t, nil
+}
+
+// style returns the color for the given attributes, or nil if there are
+// none.
+func style(attrs ...[]color.Attribute) *color.Color {
+	var res []color.Attribute
+	for _, a := range attrs {
+		res = append(res, a...)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return color.New(res...)
+}
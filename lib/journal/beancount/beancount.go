@@ -81,13 +81,27 @@ func Transcode(w io.Writer, j *journal.Journal, c *model.Commodity) error {
 }
 
 func writeTrx(w io.Writer, t *model.Transaction, c *model.Commodity) error {
-	if _, err := fmt.Fprintf(w, `%s * "%s"`, t.Date.Format("2006-01-02"), t.Description); err != nil {
+	if _, err := fmt.Fprintf(w, `%s * "%s"`, t.Date.Format("2006-01-02"), escape(t.Description)); err != nil {
 		return err
 	}
+	for _, l := range t.Links {
+		if _, err := fmt.Fprintf(w, " ^%s", l); err != nil {
+			return err
+		}
+	}
 	if _, err := io.WriteString(w, "\n"); err != nil {
 		return err
 	}
+	if t.ID != "" {
+		if _, err := fmt.Fprintf(w, "  id: \"%s\"\n", t.ID); err != nil {
+			return err
+		}
+	}
 	for _, p := range t.Postings {
+		if p.Virtual {
+			// Beancount has no virtual postings.
+			continue
+		}
 		if err := writePosting(w, p, c); err != nil {
 			return err
 		}
@@ -118,3 +132,9 @@ var regex = regexp.MustCompile("[^a-zA-Z]")
 func stripNonAlphanum(c *model.Commodity) string {
 	return regex.ReplaceAllString(c.Name(), "X")
 }
+
+// escape escapes quotes and backslashes for beancount, whose strings may
+// span several lines.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -3,30 +3,60 @@ package check
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/compare"
 	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/lots"
 	"github.com/sboehler/knut/lib/journal/printer"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/assertion"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+	"github.com/shopspring/decimal"
 	"golang.org/x/exp/slices"
 )
 
+// Rules which are checked. A rule can be disabled for an individual
+// directive by preceding it with a `; knut:disable <rule>` comment.
+const (
+	RuleAlreadyOpen  = "already-open"
+	RuleNotOpen      = "not-open"
+	RuleAssertion    = "assertion"
+	RuleNonzeroClose = "nonzero-close"
+	RuleDuplicateID  = "duplicate-id"
+)
+
 // Error is a processing error, with a reference to a directive with
 // a source location.
 type Error struct {
 	Directive model.Directive
+	Rule      string
 	Msg       string
+
+	// Fix is an optional transaction which fixes the error.
+	Fix *model.Transaction
 }
 
 func (be Error) Error() string {
 	var s strings.Builder
+	if src, ok := model.Source(be.Directive); ok {
+		s.WriteString(src.Position())
+		s.WriteString(": ")
+	}
 	s.WriteString(be.Msg)
 	s.WriteRune('\n')
 	s.WriteRune('\n')
 	p := printer.New(&s)
 	p.PrintDirectiveLn(be.Directive)
+	if be.Fix != nil {
+		s.WriteString("\nSuggested fix:\n\n")
+		p.PrintDirectiveLn(be.Fix)
+	}
 	return s.String()
 }
 
@@ -34,59 +64,260 @@ type Checker struct {
 	Write   bool
 	NoCheck bool
 
-	quantities amounts.Amounts
-	accounts   set.Set[*model.Account]
-	assertions []*model.Assertion
+	// Booking is the booking method used to track lots.
+	Booking lots.Method
+
+	// Slack is the account against which the differences of failing
+	// assertions are booked in the suggested fixes. No fixes are
+	// suggested if it is nil.
+	Slack *model.Account
+
+	quantities   amounts.Amounts
+	lots         *lots.Inventory
+	accounts     set.Set[*model.Account]
+	assertions   []*model.Assertion
+	ids          map[string]*model.Transaction
+	suppressions map[*syntax.Range][]*Suppression
 }
 
 func (ch *Checker) Assertions() []*model.Assertion {
 	return ch.assertions
 }
 
+// Suppression is a rule disabled for a directive.
+type Suppression struct {
+	Range syntax.Range
+	Rule  string
+	Used  bool
+}
+
+// Unused returns the suppressions which did not suppress any error.
+func (ch *Checker) Unused() []*Suppression {
+	var res []*Suppression
+	for _, ss := range ch.suppressions {
+		for _, s := range ss {
+			if !s.Used {
+				res = append(res, s)
+			}
+		}
+	}
+	slices.SortFunc(res, func(s1, s2 *Suppression) int {
+		if c := compare.Ordered(s1.Range.Path, s2.Range.Path); c != compare.Equal {
+			return c
+		}
+		if c := compare.Ordered(s1.Range.Start, s2.Range.Start); c != compare.Equal {
+			return c
+		}
+		return compare.Ordered(s1.Rule, s2.Rule)
+	})
+	return res
+}
+
+// suppressionsFor returns the suppressions attached to the directive at src.
+func (ch *Checker) suppressionsFor(src *syntax.Range) []*Suppression {
+	if src == nil {
+		return nil
+	}
+	if ss, ok := ch.suppressions[src]; ok {
+		return ss
+	}
+	var ss []*Suppression
+	for _, c := range parser.AttachedComments(*src) {
+		rules, ok := strings.CutPrefix(parser.Pragma(c.Extract()), "disable")
+		if !ok {
+			continue
+		}
+		for _, rule := range strings.FieldsFunc(rules, isSeparator) {
+			ss = append(ss, &Suppression{Range: c, Rule: rule})
+		}
+	}
+	ch.suppressions[src] = ss
+	return ss
+}
+
+// report returns err, unless its rule is disabled for the directive at src.
+func (ch *Checker) report(src *syntax.Range, err Error) error {
+	for _, s := range ch.suppressionsFor(src) {
+		if s.Rule == err.Rule {
+			s.Used = true
+			return nil
+		}
+	}
+	return err
+}
+
+func isSeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
 func (ch *Checker) open(o *model.Open) error {
+	var src *syntax.Range
+	if o.Src != nil {
+		src = &o.Src.Range
+	}
+	ch.suppressionsFor(src)
 	if ch.accounts.Has(o.Account) {
-		return Error{Directive: o, Msg: "account is already open"}
+		return ch.report(src, Error{Directive: o, Rule: RuleAlreadyOpen, Msg: "account is already open"})
 	}
 	ch.accounts.Add(o.Account)
 	return nil
 }
 
+func (ch *Checker) transaction(t *model.Transaction) error {
+	if t.ID == "" {
+		return nil
+	}
+	var src *syntax.Range
+	if t.Src != nil {
+		src = &t.Src.Range
+	}
+	ch.suppressionsFor(src)
+	if prev, ok := ch.ids[t.ID]; ok {
+		return ch.report(src, Error{Directive: t, Rule: RuleDuplicateID, Msg: fmt.Sprintf("id %s is already used by the transaction on %s", t.ID, prev.Date.Format("2006-01-02"))})
+	}
+	ch.ids[t.ID] = t
+	return nil
+}
+
 func (ch *Checker) posting(t *model.Transaction, p *model.Posting) error {
-	if !ch.accounts.Has(p.Account) {
-		return Error{Directive: t, Msg: fmt.Sprintf("account %s is not open", p.Account)}
+	var src *syntax.Range
+	if t.Src != nil {
+		src = &t.Src.Range
+	} else if t.Clock != nil {
+		src = &t.Clock.Range
+	}
+	ch.suppressionsFor(src)
+	// The adjustments of value directives book against the valuation
+	// account, which need not be open, and the account of the directive is
+	// checked with the directive itself. Bookings with an exchange rate
+	// convert through the conversion account and timeclock sessions book
+	// from the timeclock account, which need not be open either.
+	if !ch.accounts.Has(p.Account) && t.Value == nil && !isConversion(p) && !isTimeclock(t, p) {
+		if err := ch.report(src, Error{Directive: t, Rule: RuleNotOpen, Msg: fmt.Sprintf("account %s is not open", p.Account)}); err != nil {
+			return err
+		}
 	}
 	if p.Account.IsAL() {
 		ch.quantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
 	}
+	ch.lots.Book(t.Date, p)
 	return nil
 }
 
+// isConversion returns whether the posting books the conversion of a booking
+// with an exchange rate.
+func isConversion(p *model.Posting) bool {
+	if p.Src == nil || p.Src.Rate.Empty() {
+		return false
+	}
+	name := p.Account.Name()
+	return name != p.Src.Credit.Extract() && name != p.Src.Debit.Extract()
+}
+
+// isTimeclock returns whether the posting books the hours of a timeclock
+// session from the timeclock account.
+func isTimeclock(t *model.Transaction, p *model.Posting) bool {
+	return t.Clock != nil && p.Account.Name() != t.Clock.Account.Extract()
+}
+
 func (ch *Checker) balance(a *model.Assertion, bal *model.Balance) error {
+	var src *syntax.Range
+	if a.Src != nil {
+		src = &a.Src.Range
+	}
+	ch.suppressionsFor(src)
 	if !ch.accounts.Has(bal.Account) {
-		return Error{Directive: a, Msg: "account is not open"}
+		if err := ch.report(src, Error{Directive: a, Rule: RuleNotOpen, Msg: "account is not open"}); err != nil {
+			return err
+		}
 	}
 	position := amounts.AccountCommodityKey(bal.Account, bal.Commodity)
 	if ch.NoCheck {
 		return nil
 	}
-	if qty, ok := ch.quantities[position]; !ok || !qty.Equal(bal.Quantity) {
-		return Error{Directive: a, Msg: fmt.Sprintf("failed assertion: %s has position: %s %s", position.Account.Name(), qty, position.Commodity.Name())}
+	if bal.IsLot() {
+		if qty := ch.lots.Quantity(bal.Account, bal.Commodity, bal.Cost, bal.CostCommodity, bal.LotDate); !bal.Matches(qty) {
+			return ch.report(src, Error{Directive: a, Rule: RuleAssertion, Msg: fmt.Sprintf("failed lot assertion: %s has lot position: %s %s", position.Account.Name(), qty, position.Commodity.Name())})
+		}
+		return nil
+	}
+	if bal.Subtree {
+		if qty, ok := ch.subtreeQuantity(bal); !ok || !bal.Matches(qty) {
+			return ch.report(src, Error{Directive: a, Rule: RuleAssertion, Msg: fmt.Sprintf("failed assertion: %s:* has position: %s %s", position.Account.Name(), qty, position.Commodity.Name()), Fix: ch.fix(a, bal, qty)})
+		}
+		return nil
+	}
+	if qty, ok := ch.quantities[position]; !ok || !bal.Matches(qty) {
+		return ch.report(src, Error{Directive: a, Rule: RuleAssertion, Msg: fmt.Sprintf("failed assertion: %s has position: %s %s", position.Account.Name(), qty, position.Commodity.Name()), Fix: ch.fix(a, bal, qty)})
 	}
 	return nil
 }
 
+// fix returns a transaction which books the difference between the asserted
+// and the actual quantity against the slack account.
+func (ch *Checker) fix(a *model.Assertion, bal *model.Balance, qty decimal.Decimal) *model.Transaction {
+	if ch.Slack == nil {
+		return nil
+	}
+	return transaction.Builder{
+		Date:        a.Date,
+		Description: fmt.Sprintf("Adjust balance of %s", bal.Account.Name()),
+		Postings: posting.Builder{
+			Credit:    ch.Slack,
+			Debit:     bal.Account,
+			Commodity: bal.Commodity,
+			Quantity:  bal.Quantity.Sub(qty),
+		}.Build(),
+	}.Build()
+}
+
+func (ch *Checker) value(v *model.Value) error {
+	var src *syntax.Range
+	if v.Src != nil {
+		src = &v.Src.Range
+	}
+	ch.suppressionsFor(src)
+	if !ch.accounts.Has(v.Account) {
+		return ch.report(src, Error{Directive: v, Rule: RuleNotOpen, Msg: "account is not open"})
+	}
+	return nil
+}
+
+// subtreeQuantity returns the combined position of the account of the balance
+// and its descendants, and whether any of them has a position.
+func (ch *Checker) subtreeQuantity(bal *model.Balance) (decimal.Decimal, bool) {
+	var (
+		res = decimal.Zero
+		ok  bool
+	)
+	for pos, qty := range ch.quantities {
+		if pos.Commodity == bal.Commodity && pos.Account.InSubtree(bal.Account) {
+			res, ok = res.Add(qty), true
+		}
+	}
+	return res, ok
+}
+
 func (ch *Checker) close(c *model.Close) error {
+	var src *syntax.Range
+	if c.Src != nil {
+		src = &c.Src.Range
+	}
+	ch.suppressionsFor(src)
 	for pos, amount := range ch.quantities {
 		if pos.Account != c.Account {
 			continue
 		}
 		if !amount.IsZero() {
-			return Error{Directive: c, Msg: fmt.Sprintf("account has nonzero position: %s %s", amount, pos.Commodity.Name())}
+			if err := ch.report(src, Error{Directive: c, Rule: RuleNonzeroClose, Msg: fmt.Sprintf("account has nonzero position: %s %s", amount, pos.Commodity.Name())}); err != nil {
+				return err
+			}
 		}
 		delete(ch.quantities, pos)
 	}
+	ch.lots.Close(c.Account)
 	if !ch.accounts.Has(c.Account) {
-		return Error{Directive: c, Msg: "account is not open"}
+		return ch.report(src, Error{Directive: c, Rule: RuleNotOpen, Msg: "account is not open"})
 	}
 	ch.accounts.Remove(c.Account)
 	return nil
@@ -114,8 +345,11 @@ func (ch *Checker) dayEnd(d *journal.Day) error {
 
 func (ch *Checker) Check() *journal.Processor {
 	ch.quantities = make(amounts.Amounts)
+	ch.lots = lots.New(ch.Booking)
 	ch.accounts = set.New[*model.Account]()
 	ch.assertions = nil
+	ch.ids = make(map[string]*model.Transaction)
+	ch.suppressions = make(map[*syntax.Range][]*Suppression)
 
 	var dayEnd func(*journal.Day) error
 	if ch.Write {
@@ -123,11 +357,13 @@ func (ch *Checker) Check() *journal.Processor {
 	}
 
 	return &journal.Processor{
-		Open:    ch.open,
-		Posting: ch.posting,
-		Balance: ch.balance,
-		Close:   ch.close,
-		DayEnd:  dayEnd,
+		Open:        ch.open,
+		Transaction: ch.transaction,
+		Posting:     ch.posting,
+		Balance:     ch.balance,
+		Value:       ch.value,
+		Close:       ch.close,
+		DayEnd:      dayEnd,
 	}
 }
 
@@ -0,0 +1,456 @@
+package check
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/lots"
+	"github.com/sboehler/knut/lib/journal/printer"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/syntax/parser"
+	"github.com/shopspring/decimal"
+)
+
+func buildJournal(t *testing.T, text string) *journal.Journal {
+	t.Helper()
+	return buildJournalIn(t, registry.New(), text)
+}
+
+func buildJournalIn(t *testing.T, reg *model.Registry, text string) *journal.Journal {
+	t.Helper()
+	p := parser.New(text, "")
+	if err := p.Advance(); err != nil {
+		t.Fatalf("p.Advance() = %v, want nil", err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatalf("p.ParseFile() = %v, want nil", err)
+	}
+	b := journal.New()
+	for _, d := range f.Directives {
+		ds, err := model.ParseDirective(reg, d)
+		if err != nil {
+			t.Fatalf("model.ParseDirective() = %v, want nil", err)
+		}
+		for _, d := range ds {
+			if err := b.Add(d); err != nil {
+				t.Fatalf("b.Add() = %v, want nil", err)
+			}
+		}
+	}
+	return b.Build()
+}
+
+func TestSuppressions(t *testing.T) {
+	tests := []struct {
+		desc       string
+		text       []string
+		wantRule   string
+		wantUnused []string
+	}{
+		{
+			desc: "failing assertion",
+			text: []string{
+				"2021-01-01 open Assets:A",
+				"2021-01-02 balance Assets:A 10 CHF",
+			},
+			wantRule: RuleAssertion,
+		},
+		{
+			desc: "suppressed assertion",
+			text: []string{
+				"2021-01-01 open Assets:A",
+				"; knut:disable assertion",
+				"2021-01-02 balance Assets:A 10 CHF",
+			},
+		},
+		{
+			desc: "suppression of another rule",
+			text: []string{
+				"2021-01-01 open Assets:A",
+				"; knut:disable not-open",
+				"2021-01-02 balance Assets:A 10 CHF",
+			},
+			wantRule: RuleAssertion,
+		},
+		{
+			desc: "value of an account which is not open",
+			text: []string{
+				"2021-01-02 value Assets:A 10 CHF",
+			},
+			wantRule: RuleNotOpen,
+		},
+		{
+			desc: "unused suppressions",
+			text: []string{
+				"2021-01-01 open Assets:A",
+				"2021-01-01 open Equity:Equity",
+				"",
+				"; knut:disable assertion, not-open",
+				"2021-01-02 \"transaction\"",
+				"Equity:Equity Assets:B 1 CHF",
+				"",
+				"; knut:disable nonzero-close",
+				"2021-01-03 close Assets:A",
+			},
+			wantUnused: []string{"assertion", "nonzero-close"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			j := buildJournal(t, strings.Join(test.text, "\n"))
+			var checker Checker
+
+			err := j.Process(checker.Check())
+
+			var gotRule string
+			if e, ok := err.(Error); ok {
+				gotRule = e.Rule
+			} else if err != nil {
+				t.Fatalf("Process() returned unexpected error %v", err)
+			}
+			if gotRule != test.wantRule {
+				t.Errorf("Process() returned error for rule %q, want %q", gotRule, test.wantRule)
+			}
+			if err != nil {
+				return
+			}
+			var gotUnused []string
+			for _, s := range checker.Unused() {
+				gotUnused = append(gotUnused, s.Rule)
+			}
+			if diff := cmp.Diff(test.wantUnused, gotUnused); diff != "" {
+				t.Errorf("Unused() returned unexpected diff (-want/+got)\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestLotAssertions(t *testing.T) {
+	header := []string{
+		"2023-01-01 open Assets:Bank",
+		"2023-01-01 open Assets:Broker",
+		"",
+		`2023-02-01 "Buy"`,
+		"Assets:Bank Assets:Broker 10 AAPL {150 USD}",
+		"",
+		`2023-03-01 "Buy"`,
+		"Assets:Bank Assets:Broker 5 AAPL {160 USD}",
+		"",
+	}
+	tests := []struct {
+		desc    string
+		text    []string
+		booking lots.Method
+		wantErr bool
+	}{
+		{
+			desc: "lots",
+			text: []string{
+				"2023-04-01 balance",
+				"Assets:Broker 15 AAPL",
+				"Assets:Broker 10 AAPL {150 USD}",
+				"Assets:Broker 10 AAPL {150 USD, 2023-02-01}",
+				"Assets:Broker 5 AAPL {160 USD, 2023-03-01}",
+			},
+		},
+		{
+			desc: "wrong lot date",
+			text: []string{
+				"2023-04-01 balance Assets:Broker 10 AAPL {150 USD, 2023-03-01}",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "sale reduces the oldest lot first",
+			text: []string{
+				`2023-04-01 "Sell"`,
+				"Assets:Broker Assets:Bank 12 AAPL",
+				"",
+				"2023-04-02 balance",
+				"Assets:Broker 0 AAPL {150 USD}",
+				"Assets:Broker 3 AAPL {160 USD}",
+			},
+		},
+		{
+			desc: "sale of a given lot",
+			text: []string{
+				`2023-04-01 "Sell"`,
+				"Assets:Broker Assets:Bank 4 AAPL {160 USD}",
+				"",
+				"2023-04-02 balance",
+				"Assets:Broker 10 AAPL {150 USD}",
+				"Assets:Broker 1 AAPL {160 USD}",
+			},
+		},
+		{
+			desc: "sale of a missing lot leaves the lots unchanged",
+			text: []string{
+				`2023-04-01 "Sell"`,
+				"Assets:Broker Assets:Bank 4 AAPL {170 USD}",
+				"",
+				"2023-04-02 balance",
+				"Assets:Broker 11 AAPL",
+				"Assets:Broker 10 AAPL {150 USD}",
+				"Assets:Broker 5 AAPL {160 USD}",
+			},
+		},
+		{
+			desc: "lot date of a booking",
+			text: []string{
+				`2023-04-01 "Transfer"`,
+				"Assets:Bank Assets:Broker 1 AAPL {150 USD, 2022-12-01}",
+				"",
+				"2023-04-02 balance Assets:Broker 1 AAPL {150 USD, 2022-12-01}",
+			},
+		},
+		{
+			desc:    "average cost",
+			booking: lots.Average,
+			text: []string{
+				`2023-04-01 "Sell"`,
+				"Assets:Broker Assets:Bank 12 AAPL {160 USD}",
+				"",
+				"2023-04-02 balance",
+				"Assets:Broker 3 AAPL {153.33 USD}",
+				"Assets:Broker 3 AAPL {153.33 USD, 2023-02-01}",
+			},
+		},
+		{
+			desc:    "average cost has no individual lots",
+			booking: lots.Average,
+			text: []string{
+				"2023-04-02 balance Assets:Broker 5 AAPL {160 USD}",
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			j := buildJournal(t, strings.Join(append(header, test.text...), "\n"))
+
+			checker := Checker{Booking: test.booking}
+
+			err := j.Process(checker.Check())
+
+			if test.wantErr != (err != nil) {
+				t.Errorf("Process() returned error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	header := []string{
+		"2023-01-01 open Equity:Equity",
+		"2023-01-01 open Assets:Broker",
+		"2023-01-01 open Assets:Broker:Cash",
+		"2023-01-01 open Assets:BrokerX",
+		"",
+		`2023-02-01 "Deposit"`,
+		"Equity:Equity Assets:Broker 100.004 USD",
+		"Equity:Equity Assets:Broker:Cash 50 USD",
+		"Equity:Equity Assets:BrokerX 7 USD",
+		"",
+	}
+	tests := []struct {
+		desc    string
+		text    string
+		wantErr bool
+	}{
+		{desc: "exact", text: "2023-02-02 balance Assets:Broker 100.004 USD"},
+		{desc: "deviation", text: "2023-02-02 balance Assets:Broker 100.00 USD", wantErr: true},
+		{desc: "within tolerance", text: "2023-02-02 balance Assets:Broker 100.00 ~ 0.01 USD"},
+		{desc: "outside tolerance", text: "2023-02-02 balance Assets:Broker 100.00 ~ 0.001 USD", wantErr: true},
+		{desc: "subtree", text: "2023-02-02 balance Assets:Broker:* 150.004 USD"},
+		{desc: "subtree with tolerance", text: "2023-02-02 balance Assets:Broker:* 150 ~ 0.01 USD"},
+		{desc: "subtree excludes siblings", text: "2023-02-02 balance Assets:Broker:* 157.004 USD", wantErr: true},
+		{desc: "subtree of a leaf", text: "2023-02-02 balance Assets:Broker:Cash:* 50 USD"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			j := buildJournal(t, strings.Join(append(header, test.text), "\n"))
+
+			err := j.Process(Check())
+
+			if test.wantErr != (err != nil) {
+				t.Errorf("Process() returned error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssertionFix(t *testing.T) {
+	header := []string{
+		"2023-01-01 open Equity:Equity",
+		"2023-01-01 open Assets:Broker",
+		"2023-01-01 open Assets:Broker:Cash",
+		"",
+		`2023-02-01 "Deposit"`,
+		"Equity:Equity Assets:Broker 100 USD",
+		"Equity:Equity Assets:Broker:Cash 50 USD",
+		"",
+	}
+	tests := []struct {
+		desc    string
+		text    string
+		wantFix string
+	}{
+		{
+			desc:    "deviation",
+			text:    "2023-02-02 balance Assets:Broker 120 USD",
+			wantFix: "2023-02-02 \"Adjust balance of Assets:Broker\"\nEquity:Equity Assets:Broker         20 USD\n\n",
+		},
+		{
+			desc:    "no position",
+			text:    "2023-02-02 balance Assets:Broker 5 CHF",
+			wantFix: "2023-02-02 \"Adjust balance of Assets:Broker\"\nEquity:Equity Assets:Broker          5 CHF\n\n",
+		},
+		{
+			desc:    "subtree",
+			text:    "2023-02-02 balance Assets:Broker:* 140 USD",
+			wantFix: "2023-02-02 \"Adjust balance of Assets:Broker\"\nAssets:Broker Equity:Equity         10 USD\n\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			j := buildJournalIn(t, reg, strings.Join(append(header, test.text), "\n"))
+			checker := Checker{Slack: reg.Accounts().MustGet("Equity:Equity")}
+
+			err := j.Process(checker.Check())
+
+			e, ok := err.(Error)
+			if !ok {
+				t.Fatalf("Process() returned error %v, want a check error", err)
+			}
+			if e.Fix == nil {
+				t.Fatalf("Process() returned error without fix")
+			}
+			var got strings.Builder
+			printer.New(&got).PrintDirectiveLn(e.Fix)
+			if diff := cmp.Diff(test.wantFix, got.String()); diff != "" {
+				t.Errorf("Fix returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	header := []string{
+		"2023-01-01 open Equity:Equity",
+		"2023-01-01 open Assets:Bank",
+		"",
+		`2023-02-01 "Deposit" id:a`,
+		"Equity:Equity Assets:Bank 100 USD",
+		"",
+	}
+	tests := []struct {
+		desc     string
+		text     []string
+		wantRule string
+	}{
+		{
+			desc: "different ids",
+			text: []string{`2023-02-02 "Deposit" id:b`, "Equity:Equity Assets:Bank 100 USD"},
+		},
+		{
+			desc: "no id",
+			text: []string{`2023-02-02 "Deposit"`, "Equity:Equity Assets:Bank 100 USD"},
+		},
+		{
+			desc:     "duplicate id",
+			text:     []string{`2023-02-02 "Withdrawal" id:a`, "Assets:Bank Equity:Equity 100 USD"},
+			wantRule: RuleDuplicateID,
+		},
+		{
+			desc: "suppressed duplicate id",
+			text: []string{"; knut:disable duplicate-id", `2023-02-02 "Withdrawal" id:a`, "Assets:Bank Equity:Equity 100 USD"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			j := buildJournal(t, strings.Join(append(header, test.text...), "\n"))
+
+			err := j.Process(Check())
+
+			var gotRule string
+			if e, ok := err.(Error); ok {
+				gotRule = e.Rule
+			} else if err != nil {
+				t.Fatalf("Process() returned unexpected error %v", err)
+			}
+			if gotRule != test.wantRule {
+				t.Errorf("Process() returned error for rule %q, want %q", gotRule, test.wantRule)
+			}
+		})
+	}
+}
+
+func TestGeneratedTransactions(t *testing.T) {
+	reg := registry.New()
+	j := buildJournalIn(t, reg, strings.Join([]string{
+		"2023-01-01 open Equity:Equity",
+		"2023-01-01 open Assets:House",
+		"",
+		`2023-02-01 "Purchase"`,
+		"Equity:Equity Assets:House 100 CHF",
+		"",
+		"2023-12-31 value Assets:House 120 CHF",
+	}, "\n"))
+	generate := func(account string) *journal.Processor {
+		return &journal.Processor{
+			DayStart: func(d *journal.Day) error {
+				if d.Date.Equal(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)) {
+					d.Transactions = append(d.Transactions, transaction.Builder{
+						Date:        d.Date,
+						Description: "Generated",
+						Postings: posting.Builder{
+							Credit:    reg.Accounts().MustGet("Equity:Equity"),
+							Debit:     reg.Accounts().MustGet(account),
+							Commodity: reg.Commodities().MustGet("CHF"),
+							Quantity:  decimal.NewFromInt(1),
+						}.Build(),
+					}.Build())
+				}
+				return nil
+			},
+		}
+	}
+	tests := []struct {
+		desc     string
+		account  string
+		wantRule string
+	}{
+		{
+			desc:    "adjustment booked to the valuation account",
+			account: "Assets:House",
+		},
+		{
+			desc:     "generated transaction booked to an account which is not open",
+			account:  "Assets:Hose",
+			wantRule: RuleNotOpen,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := j.Process(generate(test.account), journal.ApplyValues(reg), Check())
+
+			var gotRule string
+			if e, ok := err.(Error); ok {
+				gotRule = e.Rule
+			} else if err != nil {
+				t.Fatalf("Process() returned unexpected error %v", err)
+			}
+			if gotRule != test.wantRule {
+				t.Errorf("Process() returned error for rule %q, want %q", gotRule, test.wantRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package claims
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v2"
+)
+
+type yamlRules struct {
+	Receivable string     `yaml:"receivable"`
+	Account    string     `yaml:"account"`
+	Commodity  string     `yaml:"commodity"`
+	Rules      []yamlRule `yaml:"rules"`
+}
+
+type yamlRule struct {
+	Tag         string           `yaml:"tag"`
+	Description string           `yaml:"description"`
+	Rate        *decimal.Decimal `yaml:"rate"`
+}
+
+// Rules determine how tagged postings are turned into claims.
+type Rules struct {
+	// Receivable is debited and Account is credited with the total claim.
+	Receivable, Account *model.Account
+	Commodity           *model.Commodity
+	Rules               []Rule
+}
+
+// Rule reimburses postings carrying a tag. If Rate is set, the quantity
+// given with the tag (e.g. #km:42) is multiplied by the rate. Otherwise,
+// the booked amount is reimbursed.
+type Rule struct {
+	Tag         string
+	Description string
+	Rate        *decimal.Decimal
+}
+
+// LoadRulesFromFile loads rules from a YAML file.
+func LoadRulesFromFile(reg *registry.Registry, path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadRules(reg, f)
+}
+
+// LoadRules loads rules in YAML format.
+func LoadRules(reg *registry.Registry, r io.Reader) (*Rules, error) {
+	dec := yaml.NewDecoder(r)
+	dec.SetStrict(true)
+	var y yamlRules
+	if err := dec.Decode(&y); err != nil {
+		return nil, err
+	}
+	receivable, err := reg.Accounts().Get(y.Receivable)
+	if err != nil {
+		return nil, fmt.Errorf("receivable: %w", err)
+	}
+	account, err := reg.Accounts().Get(y.Account)
+	if err != nil {
+		return nil, fmt.Errorf("account: %w", err)
+	}
+	commodity, err := reg.Commodities().Get(y.Commodity)
+	if err != nil {
+		return nil, fmt.Errorf("commodity: %w", err)
+	}
+	rules := &Rules{
+		Receivable: receivable,
+		Account:    account,
+		Commodity:  commodity,
+	}
+	seen := make(map[string]bool)
+	for _, yr := range y.Rules {
+		if !tagNameRegex.MatchString(yr.Tag) {
+			return nil, fmt.Errorf("invalid tag %q", yr.Tag)
+		}
+		if seen[yr.Tag] {
+			return nil, fmt.Errorf("duplicate rule for tag %q", yr.Tag)
+		}
+		seen[yr.Tag] = true
+		if yr.Description == "" {
+			yr.Description = yr.Tag
+		}
+		rules.Rules = append(rules.Rules, Rule(yr))
+	}
+	return rules, nil
+}
+
+// Tag is a tag in a posting comment, with an optional quantity.
+type Tag struct {
+	Name     string
+	Quantity decimal.Decimal
+}
+
+var (
+	tagRegex     = regexp.MustCompile(`#([\p{L}\d_-]+)(?::(\d+(?:\.\d+)?))?`)
+	tagNameRegex = regexp.MustCompile(`^[\p{L}\d_-]+$`)
+)
+
+// ParseTags returns the tags in the given comment. Tags without an explicit
+// quantity have quantity 1.
+func ParseTags(comment string) []Tag {
+	var res []Tag
+	for _, m := range tagRegex.FindAllStringSubmatch(comment, -1) {
+		q := decimal.NewFromInt(1)
+		if m[2] != "" {
+			q = decimal.RequireFromString(m[2])
+		}
+		res = append(res, Tag{Name: m[1], Quantity: q})
+	}
+	return res
+}
+
+// Claim is a single reimbursable item.
+type Claim struct {
+	Date        time.Time
+	Description string
+	Rule        *Rule
+	Quantity    decimal.Decimal
+	Amount      decimal.Decimal
+}
+
+// Report is a reimbursement claim report.
+type Report struct {
+	Rules  *Rules
+	Claims []Claim
+}
+
+// NewReport creates a new report.
+func NewReport(rules *Rules) *Report {
+	return &Report{Rules: rules}
+}
+
+// Collect returns a processor which collects claims from tagged postings.
+func (r *Report) Collect() *journal.Processor {
+	return &journal.Processor{
+		Transaction: func(t *model.Transaction) error {
+			// Postings come in pairs, consider each booking once. Virtual
+			// postings do not move money and are ignored.
+			for i, p := range t.Postings {
+				if p.Virtual || i%2 == 0 {
+					continue
+				}
+				if err := r.add(t, p); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (r *Report) add(t *model.Transaction, p *model.Posting) error {
+	for _, tag := range ParseTags(p.Comment) {
+		rule := r.Rules.find(tag.Name)
+		if rule == nil {
+			continue
+		}
+		c := Claim{
+			Date:        t.Date,
+			Description: t.Description,
+			Rule:        rule,
+			Quantity:    tag.Quantity,
+		}
+		if rule.Rate != nil {
+			c.Amount = tag.Quantity.Mul(*rule.Rate).Round(2)
+		} else {
+			if p.Commodity != r.Rules.Commodity {
+				return fmt.Errorf("%s: cannot reimburse %s %s in %s", t.Date.Format("2006-01-02"), p.Quantity, p.Commodity.Name(), r.Rules.Commodity.Name())
+			}
+			c.Quantity = p.Quantity
+			c.Amount = p.Quantity
+		}
+		r.Claims = append(r.Claims, c)
+	}
+	return nil
+}
+
+func (rs *Rules) find(tag string) *Rule {
+	for i := range rs.Rules {
+		if rs.Rules[i].Tag == tag {
+			return &rs.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Total returns the total amount claimed.
+func (r *Report) Total() decimal.Decimal {
+	var total decimal.Decimal
+	for _, c := range r.Claims {
+		total = total.Add(c.Amount)
+	}
+	return total
+}
+
+// Transaction returns the transaction booking the total claim as a
+// receivable, or nil if nothing is claimed.
+func (r *Report) Transaction(d time.Time, description string) *model.Transaction {
+	total := r.Total()
+	if total.IsZero() {
+		return nil
+	}
+	return transaction.Builder{
+		Date:        d,
+		Description: description,
+		Postings: posting.Builder{
+			Credit:    r.Rules.Account,
+			Debit:     r.Rules.Receivable,
+			Commodity: r.Rules.Commodity,
+			Quantity:  total,
+		}.Build(),
+	}.Build()
+}
+
+// Renderer renders a claim report.
+type Renderer struct{}
+
+// Render renders a claim report.
+func (rn Renderer) Render(r *Report) *table.Table {
+	tbl := table.New(1, 1, 1, 1, 1, 1)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().
+		AddText("Date", table.Center).
+		AddText("Description", table.Center).
+		AddText("Item", table.Center).
+		AddText("Quantity", table.Center).
+		AddText("Rate", table.Center).
+		AddText(r.Rules.Commodity.Name(), table.Center)
+	tbl.AddSeparatorRow()
+	for _, c := range r.Claims {
+		row := tbl.AddRow().
+			AddText(c.Date.Format("2006-01-02"), table.Left).
+			AddText(c.Description, table.Left).
+			AddText(c.Rule.Description, table.Left).
+			AddDecimal(c.Quantity)
+		if c.Rule.Rate != nil {
+			row.AddDecimal(*c.Rule.Rate)
+		} else {
+			row.AddEmpty()
+		}
+		row.AddDecimal(c.Amount)
+	}
+	tbl.AddSeparatorRow()
+	tbl.AddRow().
+		AddText("Total", table.Left).
+		AddEmpty().
+		AddEmpty().
+		AddEmpty().
+		AddEmpty().
+		AddDecimal(r.Total()).
+		MarkTotal()
+	tbl.AddSeparatorRow()
+	return tbl
+}
@@ -0,0 +1,103 @@
+package claims
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    []Tag
+	}{
+		{"", nil},
+		{"no tags", nil},
+		{"#perdiem", []Tag{{"perdiem", decimal.NewFromInt(1)}}},
+		{"trip #km:42.5 and #perdiem:2", []Tag{
+			{"km", decimal.RequireFromString("42.5")},
+			{"perdiem", decimal.NewFromInt(2)},
+		}},
+	}
+	for _, test := range tests {
+		got := ParseTags(test.comment)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("ParseTags(%q): unexpected diff (-want, +got):\n%s", test.comment, diff)
+		}
+	}
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"invalid tag":     "receivable: Assets:R\naccount: Income:R\ncommodity: CHF\nrules:\n- tag: a b\n",
+		"duplicate tag":   "receivable: Assets:R\naccount: Income:R\ncommodity: CHF\nrules:\n- tag: km\n- tag: km\n",
+		"unknown field":   "receivable: Assets:R\naccount: Income:R\ncommodity: CHF\nfoo: bar\n",
+		"invalid account": "receivable: Foo:R\naccount: Income:R\ncommodity: CHF\n",
+	}
+	for desc, input := range tests {
+		if _, err := LoadRules(registry.New(), strings.NewReader(input)); err == nil {
+			t.Errorf("%s: LoadRules() did not return an error", desc)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	reg := registry.New()
+	rules, err := LoadRules(reg, strings.NewReader(`
+receivable: Assets:Receivables
+account: Income:Reimbursements
+commodity: CHF
+rules:
+- tag: km
+  rate: 0.7
+- tag: expense
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		chf    = reg.Commodities().MustGet("CHF")
+		usd    = reg.Commodities().MustGet("USD")
+		cash   = reg.Accounts().MustGet("Assets:Cash")
+		travel = reg.Accounts().MustGet("Expenses:Travel")
+	)
+	rep := NewReport(rules)
+	proc := rep.Collect()
+	err = proc.Transaction(transaction.Builder{
+		Date:        date.Date(2024, 3, 4),
+		Description: "trip",
+		Postings: append(
+			posting.Builder{Credit: travel, Debit: travel, Commodity: chf, Comment: "#km:84"}.Build(),
+			posting.Builder{Credit: cash, Debit: travel, Commodity: chf, Quantity: decimal.NewFromInt(12), Comment: "#expense #other"}.Build()...,
+		),
+	}.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rep.Claims), 2; got != want {
+		t.Fatalf("got %d claims, want %d", got, want)
+	}
+	if got, want := rep.Total(), decimal.RequireFromString("70.8"); !got.Equal(want) {
+		t.Errorf("Total() = %s, want %s", got, want)
+	}
+	trx := rep.Transaction(date.Date(2024, 3, 31), "claim")
+	if p := trx.Postings[1]; p.Account != rules.Receivable || !p.Quantity.Equal(rep.Total()) {
+		t.Errorf("unexpected receivable posting %v", p)
+	}
+
+	err = proc.Transaction(transaction.Builder{
+		Date: date.Date(2024, 3, 5),
+		Postings: posting.Builder{
+			Credit: cash, Debit: travel, Commodity: usd, Quantity: decimal.NewFromInt(5), Comment: "#expense",
+		}.Build(),
+	}.Build())
+	if err == nil {
+		t.Error("expected an error for an expense in another commodity")
+	}
+}
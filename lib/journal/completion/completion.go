@@ -0,0 +1,140 @@
+// Package completion completes account names for editors and interactive
+// commands. Completions are ranked by frecency, a score which grows with
+// every use of an account in the journal and decays with the age of the use,
+// such that frequently and recently used accounts come first.
+package completion
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+)
+
+// DefaultHalfLife is the default time after which the weight of a use has
+// halved.
+const DefaultHalfLife = 90 * 24 * time.Hour
+
+// Completer ranks the accounts of a registry.
+type Completer struct {
+	registry *model.Registry
+
+	// HalfLife is the time after which the weight of a use has halved.
+	HalfLife time.Duration
+
+	usage  map[*model.Account]usage
+	closed set.Set[*model.Account]
+	latest time.Time
+}
+
+// usage is the score of an account as of its last use.
+type usage struct {
+	score float64
+	last  time.Time
+}
+
+// New creates a completer for the accounts of the given registry.
+func New(reg *model.Registry) *Completer {
+	return &Completer{
+		registry: reg,
+		HalfLife: DefaultHalfLife,
+		usage:    make(map[*model.Account]usage),
+		closed:   set.New[*model.Account](),
+	}
+}
+
+// Record returns a processor which records the use of accounts in
+// transactions. Accounts which are closed are not completed.
+func (c *Completer) Record() *journal.Processor {
+	return &journal.Processor{
+		Open: func(o *model.Open) error {
+			c.closed.Remove(o.Account)
+			return nil
+		},
+		Posting: func(t *model.Transaction, p *model.Posting) error {
+			c.Use(p.Account, t.Date)
+			return nil
+		},
+		Close: func(cl *model.Close) error {
+			c.closed.Add(cl.Account)
+			return nil
+		},
+	}
+}
+
+// Use records a use of an account at the given date. Uses must be recorded
+// in chronological order.
+func (c *Completer) Use(a *model.Account, d time.Time) {
+	u := c.usage[a]
+	c.usage[a] = usage{score: c.decay(u, d) + 1, last: d}
+	if d.After(c.latest) {
+		c.latest = d
+	}
+}
+
+// Score returns the frecency of an account as of the latest recorded use.
+func (c *Completer) Score(a *model.Account) float64 {
+	return c.decay(c.usage[a], c.latest)
+}
+
+func (c *Completer) decay(u usage, d time.Time) float64 {
+	if u.score == 0 || c.HalfLife <= 0 {
+		return u.score
+	}
+	return u.score * math.Exp2(-float64(d.Sub(u.last))/float64(c.HalfLife))
+}
+
+// Complete returns at most n accounts matching the input, or all of them if
+// n is not positive. The input is split into segments at colons, and each
+// segment must be a case-insensitive prefix of the corresponding segment of
+// the account, such that "ex:gro" matches "Expenses:Groceries". Accounts are
+// ranked by frecency, then by depth and name.
+func (c *Completer) Complete(input string, n int) []*model.Account {
+	var res []*model.Account
+	for _, a := range c.registry.Accounts().All() {
+		if a.Level() == 1 || c.closed.Has(a) || !Matches(a, input) {
+			continue
+		}
+		res = append(res, a)
+	}
+	scores := make(map[*model.Account]float64, len(res))
+	for _, a := range res {
+		scores[a] = c.Score(a)
+	}
+	compare.Sort(res, func(a1, a2 *model.Account) compare.Order {
+		if o := compare.Ordered(scores[a2], scores[a1]); o != compare.Equal {
+			return o
+		}
+		if o := compare.Ordered(a1.Level(), a2.Level()); o != compare.Equal {
+			return o
+		}
+		return account.Compare(a1, a2)
+	})
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// Matches returns whether the input matches the account.
+func Matches(a *model.Account, input string) bool {
+	if input == "" {
+		return true
+	}
+	ss := strings.Split(input, ":")
+	segments := a.Segments()
+	if len(ss) > len(segments) {
+		return false
+	}
+	for i, s := range ss {
+		if !strings.HasPrefix(strings.ToLower(segments[i]), strings.ToLower(s)) {
+			return false
+		}
+	}
+	return true
+}
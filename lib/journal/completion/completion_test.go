@@ -0,0 +1,89 @@
+package completion
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+func TestComplete(t *testing.T) {
+	reg := registry.New()
+	j := buildJournal(t, reg, `2020-01-01 open Equity:Equity
+2020-01-01 open Expenses:Groceries
+2020-01-01 open Expenses:Gifts
+2020-01-01 open Expenses:Garden
+2020-01-01 open Assets:Cash
+
+2020-01-02 "Plants"
+Assets:Cash Expenses:Garden 10 CHF
+
+2020-01-03 "Plants"
+Assets:Cash Expenses:Garden 10 CHF
+
+2020-01-04 "Plants"
+Assets:Cash Expenses:Garden 10 CHF
+
+2021-06-01 "Food"
+Assets:Cash Expenses:Groceries 10 CHF
+
+2021-06-02 "Food"
+Assets:Cash Expenses:Groceries 10 CHF
+
+2021-06-03 close Expenses:Gifts
+`)
+	c := New(reg)
+	if err := j.Process(c.Record()); err != nil {
+		t.Fatalf("j.Process() = %v, want nil", err)
+	}
+	tests := []struct {
+		input string
+		n     int
+		want  []string
+	}{
+		{input: "ex:g", want: []string{"Expenses:Groceries", "Expenses:Garden"}},
+		{input: "Ex:G", n: 1, want: []string{"Expenses:Groceries"}},
+		{input: "e", want: []string{"Expenses:Groceries", "Expenses:Garden", "Equity:Equity"}},
+		{input: "a:c:x"},
+		{input: "x"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var got []string
+			for _, a := range c.Complete(test.input, test.n) {
+				got = append(got, a.Name())
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Complete(%q, %d): unexpected diff (-want, +got):\n%s", test.input, test.n, diff)
+			}
+		})
+	}
+}
+
+func buildJournal(t *testing.T, reg *model.Registry, text string) *journal.Journal {
+	t.Helper()
+	p := parser.New(text, "")
+	if err := p.Advance(); err != nil {
+		t.Fatalf("p.Advance() = %v, want nil", err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatalf("p.ParseFile() = %v, want nil", err)
+	}
+	b := journal.New()
+	for _, d := range f.Directives {
+		ds, err := model.ParseDirective(reg, d)
+		if err != nil {
+			t.Fatalf("model.ParseDirective() = %v, want nil", err)
+		}
+		for _, d := range ds {
+			if err := b.Add(d); err != nil {
+				t.Fatalf("b.Add() = %v, want nil", err)
+			}
+		}
+	}
+	return b.Build()
+}
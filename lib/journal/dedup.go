@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/model"
+)
+
+// Dedup detects transactions which already exist in a journal. Two
+// transactions are considered equal if they have the same date and the same
+// postings to asset and liability accounts, i.e. the lines which appear on
+// account statements. Transactions with an ID are equal if they have the same
+// ID.
+type Dedup struct {
+	counts map[dedupKey]int
+	ids    map[string]bool
+}
+
+type dedupKey struct {
+	date      time.Time
+	account   *model.Account
+	commodity *model.Commodity
+	quantity  string
+}
+
+// NewDedup creates a Dedup for the transactions of the given journal. The
+// transactions to be checked must use the same registry as the journal.
+func NewDedup(j *Journal) *Dedup {
+	d := &Dedup{counts: make(map[dedupKey]int), ids: make(map[string]bool)}
+	for _, day := range j.Days {
+		for _, t := range day.Transactions {
+			if t.ID != "" {
+				d.ids[t.ID] = true
+			}
+			for _, p := range t.Postings {
+				if p.Account.IsAL() && !p.Virtual {
+					d.counts[newDedupKey(t.Date, p)]++
+				}
+			}
+		}
+	}
+	return d
+}
+
+func newDedupKey(date time.Time, p *model.Posting) dedupKey {
+	return dedupKey{
+		date:      date,
+		account:   p.Account,
+		commodity: p.Commodity,
+		quantity:  p.Quantity.String(),
+	}
+}
+
+// IsDuplicate returns whether all asset and liability postings of the
+// transaction exist in the journal. Matched postings are consumed, such that
+// identical transactions on the same day are matched one by one.
+// Transactions without asset or liability postings are never duplicates,
+// unless their ID exists in the journal.
+func (d *Dedup) IsDuplicate(t *model.Transaction) bool {
+	if t.ID != "" && d.ids[t.ID] {
+		return true
+	}
+	needed := make(map[dedupKey]int)
+	for _, p := range t.Postings {
+		if p.Account.IsAL() && !p.Virtual {
+			needed[newDedupKey(t.Date, p)]++
+		}
+	}
+	if len(needed) == 0 {
+		return false
+	}
+	for k, n := range needed {
+		if d.counts[k] < n {
+			return false
+		}
+	}
+	for k, n := range needed {
+		d.counts[k] -= n
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+)
+
+func TestDedup(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	bank := reg.Accounts().MustGet("Assets:Bank")
+	food := reg.Accounts().MustGet("Expenses:Food")
+	tbd := reg.Accounts().MustGet("Expenses:TBD")
+	trx := func(day int, credit, debit *model.Account, qty int64) *model.Transaction {
+		return transaction.Builder{
+			Date: date.Date(2021, 1, day),
+			Postings: posting.Builder{
+				Credit:    credit,
+				Debit:     debit,
+				Commodity: chf,
+				Quantity:  decimal.NewFromInt(qty),
+			}.Build(),
+		}.Build()
+	}
+	b := New()
+	b.Add(trx(1, bank, food, 10))
+	b.Add(trx(1, bank, food, 10))
+	b.Add(trx(2, bank, food, 20))
+	d := NewDedup(b.Build())
+
+	tests := []struct {
+		desc string
+		trx  *model.Transaction
+		want bool
+	}{
+		{"first duplicate", trx(1, bank, tbd, 10), true},
+		{"second duplicate", trx(1, bank, tbd, 10), true},
+		{"third duplicate", trx(1, bank, tbd, 10), false},
+		{"different amount", trx(2, bank, tbd, 21), false},
+		{"different date", trx(3, bank, tbd, 20), false},
+		{"different direction", trx(2, tbd, bank, 20), false},
+		{"duplicate", trx(2, bank, tbd, 20), true},
+		{"no balance sheet postings", trx(2, food, tbd, 20), false},
+	}
+	for _, test := range tests {
+		if got := d.IsDuplicate(test.trx); got != test.want {
+			t.Errorf("%s: IsDuplicate() = %t, want %t", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestDedupByID(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	bank := reg.Accounts().MustGet("Assets:Bank")
+	food := reg.Accounts().MustGet("Expenses:Food")
+	trx := func(day int, id string) *model.Transaction {
+		return transaction.Builder{
+			Date: date.Date(2021, 1, day),
+			ID:   id,
+			Postings: posting.Builder{
+				Credit:    bank,
+				Debit:     food,
+				Commodity: chf,
+				Quantity:  decimal.NewFromInt(10),
+			}.Build(),
+		}.Build()
+	}
+	b := New()
+	b.Add(trx(1, "a"))
+	d := NewDedup(b.Build())
+
+	tests := []struct {
+		desc string
+		trx  *model.Transaction
+		want bool
+	}{
+		{"same id on a different date", trx(5, "a"), true},
+		{"same id again", trx(1, "a"), true},
+		{"different id with the same postings", trx(1, "b"), true},
+		{"different id", trx(1, "c"), false},
+	}
+	for _, test := range tests {
+		if got := d.IsDuplicate(test.trx); got != test.want {
+			t.Errorf("%s: IsDuplicate() = %t, want %t", test.desc, got, test.want)
+		}
+	}
+}
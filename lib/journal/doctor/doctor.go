@@ -0,0 +1,293 @@
+// Package doctor diagnoses problems of a journal which the parser and the
+// checks accept, but which are likely mistakes, such as files which are
+// included twice or accounts which are used after they have been closed.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/multierr"
+
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Problem is a problem found in a journal, with a hint how to fix it.
+type Problem struct {
+	// Range locates the problem. Problems of a file as a whole have an
+	// empty range at the start of the file.
+	Range   syntax.Range
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Range.Position(), p.Message)
+}
+
+// Diagnose examines the journal at the given path and the files it includes.
+// Files are parsed leniently, so that all problems are reported at once.
+func Diagnose(ctx context.Context, file string) ([]Problem, error) {
+	d := &doctor{ctx: ctx, included: make(map[string]syntax.Range)}
+	if err := d.visit(filepath.Clean(file), nil); err != nil {
+		return nil, err
+	}
+	d.checkAccounts()
+	d.checkCommodities()
+	slices.SortStableFunc(d.problems, func(p1, p2 Problem) int {
+		if c := strings.Compare(p1.Range.Path, p2.Range.Path); c != 0 {
+			return c
+		}
+		return p1.Range.Start - p2.Range.Start
+	})
+	return d.problems, nil
+}
+
+type doctor struct {
+	ctx      context.Context
+	problems []Problem
+	files    []syntax.File
+
+	// included maps the files which have been visited to the include
+	// directive which included them first.
+	included map[string]syntax.Range
+}
+
+func (d *doctor) report(r syntax.Range, format string, args ...any) {
+	d.problems = append(d.problems, Problem{Range: r, Message: fmt.Sprintf(format, args...)})
+}
+
+// visit parses the given file and the files it includes. The stack holds the
+// files which include the current one, to detect cycles.
+func (d *doctor) visit(file string, stack []string) error {
+	b, err := os.ReadFile(env.Path(d.ctx, file))
+	if err != nil {
+		return err
+	}
+	text := string(b)
+	d.checkText(file, text)
+	p := parser.New(text, file)
+	p.Recover = true
+	if err := p.Advance(); err != nil {
+		return err
+	}
+	f, err := p.ParseFile()
+	if n := len(multierr.Errors(err)); n > 0 {
+		d.report(syntax.Range{Path: file, Text: text}, "the file has %d syntax errors, run `knut check` for details", n)
+	}
+	d.files = append(d.files, f)
+	stack = append(stack, file)
+	for _, dir := range f.Directives {
+		inc, ok := dir.Directive.(syntax.Include)
+		if !ok {
+			continue
+		}
+		target, err := inc.IncludePath.Parse()
+		if err != nil {
+			d.report(inc.Range, "%v", err)
+			continue
+		}
+		target = filepath.Clean(path.Join(filepath.Dir(file), target))
+		if i := slices.Index(stack, target); i >= 0 {
+			d.report(inc.Range, "include cycle %s, remove one of the includes", strings.Join(append(stack[i:], target), " -> "))
+			continue
+		}
+		if first, ok := d.included[target]; ok {
+			d.report(inc.Range, "%s is already included at %s, its directives would be counted twice; remove one of the includes", target, first.Position())
+			continue
+		}
+		if _, err := os.Stat(env.Path(d.ctx, target)); err != nil {
+			d.report(inc.Range, "%s can't be read: %v", target, err)
+			continue
+		}
+		d.included[target] = inc.Range
+		if err := d.visit(target, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkText checks the encoding and the line endings of a file.
+func (d *doctor) checkText(file, text string) {
+	start := syntax.Range{Path: file, Text: text}
+	if strings.HasPrefix(text, "\ufeff") {
+		d.report(start, "the file starts with a byte order mark, save it as UTF-8 without BOM")
+	}
+	if !utf8.ValidString(text) {
+		pos := 0
+		for pos < len(text) {
+			r, size := utf8.DecodeRuneInString(text[pos:])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			pos += size
+		}
+		d.report(syntax.Range{Path: file, Text: text, Start: pos, End: pos}, "the file is not valid UTF-8, convert it, e.g. with `iconv -f latin1 -t utf-8`")
+	}
+	crlf := strings.Count(text, "\r\n")
+	if lf := strings.Count(text, "\n") - crlf; crlf > 0 && lf > 0 {
+		pos := strings.Index(text, "\r\n")
+		if first := strings.Index(text, "\n"); first < pos {
+			pos = first
+		}
+		d.report(syntax.Range{Path: file, Text: text, Start: pos, End: pos}, "the file mixes %d CRLF and %d LF line endings, convert it to LF, e.g. with `dos2unix`", crlf, lf)
+	}
+}
+
+// event is an open or close directive, or a use of an account.
+type event struct {
+	date  time.Time
+	kind  int
+	rng   syntax.Range
+	order int
+}
+
+const (
+	opened = iota
+	used
+	closed
+)
+
+// checkAccounts reports accounts which are opened while they are open, and
+// accounts which are used after they have been closed. Accounts may be used
+// on the day they are closed.
+func (d *doctor) checkAccounts() {
+	events := make(map[string][]event)
+	add := func(acc syntax.Account, date syntax.Date, kind int) {
+		if acc.Empty() || acc.Macro {
+			return
+		}
+		t, err := date.Parse()
+		if err != nil {
+			return
+		}
+		name := acc.Extract()
+		events[name] = append(events[name], event{date: t, kind: kind, rng: acc.Range, order: len(events[name])})
+	}
+	for _, f := range d.files {
+		for _, dir := range f.Directives {
+			switch t := dir.Directive.(type) {
+			case syntax.Open:
+				add(t.Account, t.Date, opened)
+			case syntax.Close:
+				add(t.Account, t.Date, closed)
+			case syntax.Transaction:
+				for _, b := range t.Bookings {
+					add(b.Credit, t.Date, used)
+					add(b.Debit, t.Date, used)
+					add(b.Virtual, t.Date, used)
+				}
+			case syntax.Assertion:
+				for _, b := range t.Balances {
+					add(b.Account, t.Date, used)
+				}
+			case syntax.Note:
+				add(t.Account, t.Date, used)
+			case syntax.Document:
+				add(t.Account, t.Date, used)
+			case syntax.Value:
+				add(t.Account, t.Date, used)
+			case syntax.Clock:
+				add(t.Account, t.Date, used)
+			}
+		}
+	}
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		es := events[name]
+		slices.SortStableFunc(es, func(e1, e2 event) int {
+			if c := e1.date.Compare(e2.date); c != 0 {
+				return c
+			}
+			if e1.kind != e2.kind {
+				return e1.kind - e2.kind
+			}
+			return e1.order - e2.order
+		})
+		var lastOpen, lastClose *event
+		for i := range es {
+			e := &es[i]
+			switch e.kind {
+			case opened:
+				if lastOpen != nil {
+					d.report(e.rng, "account %s is opened again, it has been opened at %s; remove one of the open directives", name, lastOpen.rng.Position())
+					continue
+				}
+				lastOpen, lastClose = e, nil
+			case closed:
+				lastOpen, lastClose = nil, e
+			case used:
+				if lastClose != nil {
+					d.report(e.rng, "account %s is used after it has been closed at %s; move the close directive or book to another account", name, lastClose.rng.Position())
+					// Report only the first use after a close.
+					lastClose = nil
+				}
+			}
+		}
+	}
+}
+
+// checkCommodities reports commodities whose names differ only in case,
+// which are likely typos.
+func (d *doctor) checkCommodities() {
+	var (
+		names = make(map[string]string)
+		first = make(map[string]syntax.Range)
+	)
+	add := func(c syntax.Commodity) {
+		if c.Empty() {
+			return
+		}
+		name := c.Extract()
+		if _, ok := first[name]; ok {
+			return
+		}
+		first[name] = c.Range
+		key := strings.ToLower(name)
+		if other, ok := names[key]; ok {
+			d.report(c.Range, "commodity %s differs from %s at %s only in case; use the same name", name, other, first[other].Position())
+			return
+		}
+		names[key] = name
+	}
+	for _, f := range d.files {
+		for _, dir := range f.Directives {
+			switch t := dir.Directive.(type) {
+			case syntax.Transaction:
+				for _, b := range t.Bookings {
+					add(b.Commodity)
+					add(b.Cost.Commodity)
+					add(b.Rate.Commodity)
+				}
+			case syntax.Assertion:
+				for _, b := range t.Balances {
+					add(b.Commodity)
+					add(b.Cost.Commodity)
+				}
+			case syntax.Price:
+				add(t.Commodity)
+				add(t.Target)
+			case syntax.Value:
+				add(t.Commodity)
+			case syntax.CommodityDeclaration:
+				add(t.Commodity)
+			case syntax.Alias:
+				add(t.Commodity)
+				add(t.Target)
+			}
+		}
+	}
+}
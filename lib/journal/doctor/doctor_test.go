@@ -0,0 +1,115 @@
+package doctor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		desc  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			desc: "no problems",
+			files: map[string]string{
+				"main.knut": "2020-01-01 open Assets:Cash\n2020-01-02 \"foo\"\nAssets:Cash Expenses:Food 10 USD\n",
+			},
+		},
+		{
+			desc: "duplicate include",
+			files: map[string]string{
+				"main.knut": "include \"a.knut\"\ninclude \"a.knut\"\n",
+				"a.knut":    "",
+			},
+			want: []string{
+				"main.knut:2:1: a.knut is already included at main.knut:1:1, its directives would be counted twice; remove one of the includes",
+			},
+		},
+		{
+			desc: "include cycle",
+			files: map[string]string{
+				"main.knut": "include \"a.knut\"\n",
+				"a.knut":    "include \"main.knut\"\n",
+			},
+			want: []string{
+				"a.knut:1:1: include cycle main.knut -> a.knut -> main.knut, remove one of the includes",
+			},
+		},
+		{
+			desc: "mixed line endings",
+			files: map[string]string{
+				"main.knut": "2020-01-01 open Assets:Cash\r\n2020-01-01 open Assets:Bank\n",
+			},
+			want: []string{
+				"main.knut:1:28: the file mixes 1 CRLF and 1 LF line endings, convert it to LF, e.g. with `dos2unix`",
+			},
+		},
+		{
+			desc: "latin1",
+			files: map[string]string{
+				"main.knut": "2020-01-01 \"Caf\xe9\"\nAssets:Cash Expenses:Food 10 USD\n",
+			},
+			want: []string{
+				"main.knut:1:1: the file has 1 syntax errors, run `knut check` for details",
+				"main.knut:1:16: the file is not valid UTF-8, convert it, e.g. with `iconv -f latin1 -t utf-8`",
+			},
+		},
+		{
+			desc: "account opened twice",
+			files: map[string]string{
+				"main.knut": "2020-01-01 open Assets:Cash\n2020-02-01 open Assets:Cash\n",
+			},
+			want: []string{
+				"main.knut:2:17: account Assets:Cash is opened again, it has been opened at main.knut:1:17; remove one of the open directives",
+			},
+		},
+		{
+			desc: "use after close",
+			files: map[string]string{
+				"main.knut": "2020-01-01 open Assets:Cash\n2020-02-01 close Assets:Cash\n\n2020-02-01 \"foo\"\nAssets:Cash Expenses:Food 10 USD\n\n2020-03-01 \"bar\"\nAssets:Cash Expenses:Food 10 USD\n\n2020-04-01 \"baz\"\nAssets:Cash Expenses:Food 10 USD\n",
+			},
+			want: []string{
+				"main.knut:8:1: account Assets:Cash is used after it has been closed at main.knut:2:18; move the close directive or book to another account",
+			},
+		},
+		{
+			desc: "commodity collision",
+			files: map[string]string{
+				"main.knut": "2020-01-01 \"foo\"\nAssets:Cash Expenses:Food 10 USD\n\n2020-01-02 \"bar\"\nAssets:Cash Expenses:Food 10 usd\n",
+			},
+			want: []string{
+				"main.knut:5:30: commodity usd differs from USD at main.knut:2:30 only in case; use the same name",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			problems, err := Diagnose(context.Background(), filepath.Join(dir, "main.knut"))
+
+			if err != nil {
+				t.Fatalf("Diagnose() returned unexpected error: %v", err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, strings.ReplaceAll(p.String(), dir+string(filepath.Separator), ""))
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/sboehler/knut/lib/model"
+)
+
+// IDs generates stable IDs for transactions. An ID is a name-based UUID
+// derived from the source, the date, the description and the postings of the
+// transaction, such that importing the same statement again yields the same
+// IDs. Identical transactions of a source are distinguished by their order.
+type IDs struct {
+	source string
+	seen   map[[sha256.Size]byte]int
+}
+
+// NewIDs creates an ID generator for the transactions of the given source,
+// e.g. the name of an importer. The imported file is deliberately not part of
+// the source, so that a statement which is downloaded again, or a statement
+// which overlaps with an earlier one, yields the same IDs.
+func NewIDs(source string) *IDs {
+	return &IDs{source: source, seen: make(map[[sha256.Size]byte]int)}
+}
+
+// Assign sets the ID of the transaction, unless it already has one.
+func (ids *IDs) Assign(t *model.Transaction) {
+	if t.ID != "" {
+		return
+	}
+	h := sha256.New()
+	io.WriteString(h, ids.source)
+	fmt.Fprintf(h, "\x00%s\x00%s", t.Date.Format("2006-01-02"), t.Description)
+	for _, p := range t.Postings {
+		fmt.Fprintf(h, "\x00%s %s %s", p.Account.Name(), p.Quantity.String(), p.Commodity.Name())
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	n := ids.seen[key]
+	ids.seen[key]++
+
+	h.Reset()
+	h.Write(key[:])
+	fmt.Fprintf(h, "\x00%d", n)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	sum[6] = sum[6]&0x0f | 0x50 // version 5
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	t.ID = fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
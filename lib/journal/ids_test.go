@@ -0,0 +1,67 @@
+package journal
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+)
+
+func TestIDs(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	bank := reg.Accounts().MustGet("Assets:Bank")
+	food := reg.Accounts().MustGet("Expenses:Food")
+	trx := func(desc, id string) *model.Transaction {
+		return transaction.Builder{
+			Date:        date.Date(2021, 1, 1),
+			Description: desc,
+			ID:          id,
+			Postings: posting.Builder{
+				Credit:    bank,
+				Debit:     food,
+				Commodity: chf,
+				Quantity:  decimal.NewFromInt(10),
+			}.Build(),
+		}.Build()
+	}
+	assign := func(source string, ts ...*model.Transaction) []string {
+		ids := NewIDs(source)
+		var res []string
+		for _, t := range ts {
+			ids.Assign(t)
+			res = append(res, t.ID)
+		}
+		return res
+	}
+
+	first := assign("ch.zkb", trx("foo", ""), trx("foo", ""), trx("bar", ""), trx("baz", "fixed"))
+	second := assign("ch.zkb", trx("foo", ""), trx("foo", ""), trx("bar", ""), trx("baz", "fixed"))
+	other := assign("ch.ubs", trx("foo", ""))
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, id := range first[:3] {
+		if !uuid.MatchString(id) {
+			t.Errorf("Assign() = %q, want a UUID", id)
+		}
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Assign() = %q for transaction %d, want %q", second[i], i, first[i])
+		}
+	}
+	if first[0] == first[1] {
+		t.Errorf("Assign() = %q for identical transactions, want different IDs", first[0])
+	}
+	if first[3] != "fixed" {
+		t.Errorf("Assign() = %q, want existing ID to be kept", first[3])
+	}
+	if other[0] == first[0] {
+		t.Errorf("Assign() = %q for different sources, want different IDs", other[0])
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"time"
 
@@ -52,7 +53,12 @@ func (j *Builder) Day(d time.Time) *Day {
 	return dict.GetDefault(j.days, d, func() *Day { return &Day{Date: d} })
 }
 
+// Build builds the journal. The commodities of directives which have been
+// created before the commodities were declared aliases are resolved.
 func (j *Builder) Build() *Journal {
+	for _, d := range j.days {
+		d.resolveAliases()
+	}
 	return &Journal{
 		Days: dict.SortedValues(j.days, CompareDays),
 	}
@@ -86,10 +92,28 @@ func (j *Builder) Add(d model.Directive) error {
 		d := j.Day(t.Date)
 		d.Assertions = append(d.Assertions, t)
 
+	case *model.Value:
+		d := j.Day(t.Date)
+		if j.max.Before(d.Date) {
+			j.max = d.Date
+		}
+		if j.min.After(t.Date) {
+			j.min = d.Date
+		}
+		d.Values = append(d.Values, t)
+
 	case *model.Close:
 		d := j.Day(t.Date)
 		d.Closings = append(d.Closings, t)
 
+	case *model.Note:
+		d := j.Day(t.Date)
+		d.Notes = append(d.Notes, t)
+
+	case *model.Document:
+		d := j.Day(t.Date)
+		d.Documents = append(d.Documents, t)
+
 	default:
 		return fmt.Errorf("unknown: %v (%T)", t, t)
 	}
@@ -109,7 +133,15 @@ func (j *Builder) Days(dates []time.Time) []*Day {
 }
 
 func FromPath(ctx context.Context, reg *model.Registry, path string) (*Builder, error) {
-	syntaxCh, worker1 := syntax.ParseFileRecursively(path)
+	return FromPathUntil(ctx, reg, path, time.Time{})
+}
+
+// FromFile creates a journal from a single parsed file. Include directives
+// are ignored, so it can be used without a file system, e.g. in a browser.
+func FromFile(ctx context.Context, reg *model.Registry, f syntax.File) (*Builder, error) {
+	syntaxCh, worker1 := cpr.Produce(func(ctx context.Context, ch chan<- syntax.File) error {
+		return cpr.Push(ctx, ch, f)
+	})
 	modelCh, worker2 := model.FromStream(reg, syntaxCh)
 	journalCh, worker3 := FromModelStream(modelCh)
 	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
@@ -122,6 +154,93 @@ func FromPath(ctx context.Context, reg *model.Registry, path string) (*Builder,
 	return <-journalCh, nil
 }
 
+// FromPathUntil is like FromPath, but skips the dated directives of files
+// which only affect days after end, such as the files of later years in a
+// journal split per year. The period of the journal still extends to the
+// last skipped directive. Reports which end on or before end are the same as
+// for FromPath, unless prices are interpolated. If end is zero, no files are
+// skipped.
+func FromPathUntil(ctx context.Context, reg *model.Registry, path string, end time.Time) (*Builder, error) {
+	var skipped time.Time
+	syntaxCh, worker1 := syntax.ParseFileRecursively(path)
+	sparseCh, worker2 := cpr.Produce(func(ctx context.Context, ch chan<- syntax.File) error {
+		return cpr.ForEach(ctx, syntaxCh, func(f syntax.File) error {
+			if last, ok := skippable(f, end); ok {
+				f = undated(f)
+				if last.After(skipped) {
+					skipped = last
+				}
+			}
+			return cpr.Push(ctx, ch, f)
+		})
+	})
+	modelCh, worker3 := model.FromStream(reg, sparseCh)
+	journalCh, worker4 := FromModelStream(modelCh)
+	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
+	p.Go(worker1)
+	p.Go(worker2)
+	p.Go(worker3)
+	p.Go(worker4)
+	if err := p.Wait(); err != nil {
+		return nil, err
+	}
+	j := <-journalCh
+	if j.max.Before(skipped) {
+		j.max = skipped
+	}
+	return j, nil
+}
+
+// skippable returns whether all dated directives of the file only affect
+// days after end, and the last of their dates.
+func skippable(f syntax.File, end time.Time) (time.Time, bool) {
+	if end.IsZero() {
+		return time.Time{}, false
+	}
+	var last time.Time
+	for _, d := range f.Directives {
+		sd, ok := syntax.DateOf(d)
+		if !ok {
+			continue
+		}
+		t, err := sd.Parse()
+		if err != nil {
+			// Let the model report the error.
+			return time.Time{}, false
+		}
+		first := t
+		if trx, ok := d.Directive.(syntax.Transaction); ok && !trx.Addons.Accrual.Empty() {
+			// Accruals may book before the date of the transaction.
+			start, err := trx.Addons.Accrual.Start.Parse()
+			if err != nil {
+				return time.Time{}, false
+			}
+			if start.Before(first) {
+				first = start
+			}
+		}
+		if !first.After(end) {
+			return time.Time{}, false
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// undated returns the file without its dated directives.
+func undated(f syntax.File) syntax.File {
+	var ds []syntax.Directive
+	for _, d := range f.Directives {
+		if _, ok := syntax.DateOf(d); !ok {
+			ds = append(ds, d)
+		}
+	}
+	f.Directives = ds
+	return f
+}
+
 func FromModelStream(modelCh <-chan []model.Directive) (<-chan *Builder, func(context.Context) error) {
 	return cpr.FanIn(func(ctx context.Context, ch chan<- *Builder) error {
 		j := New()
@@ -144,31 +263,166 @@ type Journal struct {
 	Days []*Day
 }
 
+// Options determine how ProcessWithOptions processes a journal.
+type Options struct {
+	// AutoOpen opens accounts on their first use, see AutoOpen.
+	AutoOpen bool
+
+	// KeepGoing continues after an error and returns all errors together,
+	// see Errors. It does not apply to building the journal, which still
+	// fails on the first syntax or model error.
+	KeepGoing bool
+}
+
+// Process processes the journal with the given processors, stopping at the
+// first error.
 func (j *Journal) Process(ps ...*Processor) error {
-	var fs []func(*Day) error
+	return j.ProcessWithOptions(Options{}, ps...)
+}
+
+// ProcessWithOptions processes the journal like Process, with the given
+// options.
+func (j *Journal) ProcessWithOptions(opts Options, ps ...*Processor) error {
+	var procs []*Processor
+	if opts.AutoOpen {
+		procs = append(procs, AutoOpen())
+	}
 	for _, proc := range ps {
 		if proc != nil {
-			fs = append(fs, proc.Process)
+			procs = append(procs, proc)
 		}
 	}
+	if opts.KeepGoing {
+		return j.processAll(procs)
+	}
+	var fs []func(*Day) error
+	for _, proc := range procs {
+		fs = append(fs, proc.Process)
+	}
 	_, err := cpr.Seq(context.Background(), j.Days, fs...)
 	return err
 }
 
+// processAll processes the journal like Process, but collects the errors of
+// all processors instead of aborting on the first one.
+func (j *Journal) processAll(procs []*Processor) error {
+	// Each processor runs in its own goroutine and only appends to its own
+	// slice of errors.
+	errs := make([][]dayError, len(procs))
+	var fs []func(*Day) error
+	for i, proc := range procs {
+		i, proc := i, proc
+		fs = append(fs, func(d *Day) error {
+			return proc.process(d, func(err error) error {
+				if err != nil {
+					errs[i] = append(errs[i], dayError{date: d.Date, stage: i, err: err})
+				}
+				return nil
+			})
+		})
+	}
+	if _, err := cpr.Seq(context.Background(), j.Days, fs...); err != nil {
+		return err
+	}
+	var all []dayError
+	for _, es := range errs {
+		all = append(all, es...)
+	}
+	slices.SortStableFunc(all, func(e1, e2 dayError) int {
+		if o := compare.Time(e1.date, e2.date); o != compare.Equal {
+			return o
+		}
+		return compare.Ordered(e1.stage, e2.stage)
+	})
+	// Errors which recur on every day, such as a missing price, are reported
+	// only once.
+	var (
+		res  Errors
+		seen = make(map[string]bool)
+	)
+	for _, e := range all {
+		if msg := e.err.Error(); !seen[msg] {
+			seen[msg] = true
+			res = append(res, e.err)
+		}
+	}
+	switch len(res) {
+	case 0:
+		return nil
+	case 1:
+		return res[0]
+	default:
+		return res
+	}
+}
+
+type dayError struct {
+	date  time.Time
+	stage int
+	err   error
+}
+
+// Errors are the distinct errors collected by ProcessWithOptions if
+// KeepGoing is set, ordered by date.
+type Errors []error
+
+func (es Errors) Error() string {
+	var buf strings.Builder
+	for _, err := range es {
+		buf.WriteString(strings.TrimRight(err.Error(), "\n"))
+		buf.WriteString("\n\n")
+	}
+	fmt.Fprintf(&buf, "%d errors", len(es))
+	return buf.String()
+}
+
+// Unwrap returns the individual errors, for errors.As and multierr.Errors.
+func (es Errors) Unwrap() []error {
+	return es
+}
+
 // Day groups all commands for a given date.
 type Day struct {
 	Date         time.Time
 	Prices       []*model.Price
 	Assertions   []*model.Assertion
+	Values       []*model.Value
 	Openings     []*model.Open
 	Transactions []*model.Transaction
 	Closings     []*model.Close
+	Notes        []*model.Note
+	Documents    []*model.Document
 
 	Normalized price.NormalizedPrices
 
 	Performance *Performance
 }
 
+// resolveAliases replaces the commodities of the directives of the day by
+// the commodities they have been declared aliases of.
+func (d *Day) resolveAliases() {
+	for _, p := range d.Prices {
+		p.Commodity, p.Target = p.Commodity.Resolve(), p.Target.Resolve()
+	}
+	for _, a := range d.Assertions {
+		for i := range a.Balances {
+			b := &a.Balances[i]
+			b.Commodity, b.CostCommodity = b.Commodity.Resolve(), b.CostCommodity.Resolve()
+		}
+	}
+	for _, v := range d.Values {
+		v.Commodity = v.Commodity.Resolve()
+	}
+	for _, t := range d.Transactions {
+		for _, p := range t.Postings {
+			p.Commodity, p.CostCommodity = p.Commodity.Resolve(), p.CostCommodity.Resolve()
+		}
+		for i, c := range t.Targets {
+			t.Targets[i] = c.Resolve()
+		}
+	}
+}
+
 // Less establishes an ordering on Day.
 func CompareDays(d *Day, d2 *Day) compare.Order {
 	return compare.Time(d.Date, d2.Date)
@@ -256,6 +510,16 @@ func Print(w io.Writer, j *Journal) error {
 				return err
 			}
 		}
+		for _, v := range day.Values {
+			if _, err := p.PrintDirectiveLn(v); err != nil {
+				return err
+			}
+		}
+		if len(day.Values) > 0 {
+			if _, err := io.WriteString(p, "\n"); err != nil {
+				return err
+			}
+		}
 		for _, c := range day.Closings {
 			if _, err := p.PrintDirectiveLn(c); err != nil {
 				return err
@@ -266,6 +530,26 @@ func Print(w io.Writer, j *Journal) error {
 				return err
 			}
 		}
+		for _, n := range day.Notes {
+			if _, err := p.PrintDirectiveLn(n); err != nil {
+				return err
+			}
+		}
+		if len(day.Notes) > 0 {
+			if _, err := io.WriteString(p, "\n"); err != nil {
+				return err
+			}
+		}
+		for _, doc := range day.Documents {
+			if _, err := p.PrintDirectiveLn(doc); err != nil {
+				return err
+			}
+		}
+		if len(day.Documents) > 0 {
+			if _, err := io.WriteString(p, "\n"); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -278,38 +562,48 @@ type Processor struct {
 	Posting     func(*model.Transaction, *model.Posting) error
 	Assertion   func(*model.Assertion) error
 	Balance     func(*model.Assertion, *model.Balance) error
+	Value       func(*model.Value) error
 	Close       func(*model.Close) error
+	Note        func(*model.Note) error
+	Document    func(*model.Document) error
 	DayEnd      func(*Day) error
 }
 
+// Process processes the day, aborting on the first error.
 func (proc *Processor) Process(d *Day) error {
+	return proc.process(d, func(err error) error { return err })
+}
+
+// process processes the day, passing the result of each callback to report.
+// Processing continues if report returns nil.
+func (proc *Processor) process(d *Day, report func(error) error) error {
 	if proc.DayStart != nil {
-		if err := proc.DayStart(d); err != nil {
+		if err := report(proc.DayStart(d)); err != nil {
 			return err
 		}
 	}
 	if proc.Price != nil {
 		for _, p := range d.Prices {
-			if err := proc.Price(p); err != nil {
+			if err := report(proc.Price(p)); err != nil {
 				return err
 			}
 		}
 	}
 	if proc.Open != nil {
 		for _, o := range d.Openings {
-			if err := proc.Open(o); err != nil {
+			if err := report(proc.Open(o)); err != nil {
 				return err
 			}
 		}
 	}
 	if proc.Transaction != nil {
 		for _, t := range d.Transactions {
-			if err := proc.Transaction(t); err != nil {
+			if err := report(proc.Transaction(t)); err != nil {
 				return err
 			}
 			if proc.Posting != nil {
 				for _, p := range t.Postings {
-					if err := proc.Posting(t, p); err != nil {
+					if err := report(proc.Posting(t, p)); err != nil {
 						return err
 					}
 				}
@@ -318,7 +612,7 @@ func (proc *Processor) Process(d *Day) error {
 	} else if proc.Posting != nil {
 		for _, t := range d.Transactions {
 			for _, p := range t.Postings {
-				if err := proc.Posting(t, p); err != nil {
+				if err := report(proc.Posting(t, p)); err != nil {
 					return err
 				}
 			}
@@ -326,12 +620,12 @@ func (proc *Processor) Process(d *Day) error {
 	}
 	if proc.Assertion != nil {
 		for _, a := range d.Assertions {
-			if err := proc.Assertion(a); err != nil {
+			if err := report(proc.Assertion(a)); err != nil {
 				return err
 			}
 			if proc.Balance != nil {
 				for i := range a.Balances {
-					if err := proc.Balance(a, &a.Balances[i]); err != nil {
+					if err := report(proc.Balance(a, &a.Balances[i])); err != nil {
 						return err
 					}
 				}
@@ -340,21 +634,42 @@ func (proc *Processor) Process(d *Day) error {
 	} else if proc.Balance != nil {
 		for _, a := range d.Assertions {
 			for i := range a.Balances {
-				if err := proc.Balance(a, &a.Balances[i]); err != nil {
+				if err := report(proc.Balance(a, &a.Balances[i])); err != nil {
 					return err
 				}
 			}
 		}
 	}
+	if proc.Value != nil {
+		for _, v := range d.Values {
+			if err := report(proc.Value(v)); err != nil {
+				return err
+			}
+		}
+	}
 	if proc.Close != nil {
 		for _, a := range d.Closings {
-			if err := proc.Close(a); err != nil {
+			if err := report(proc.Close(a)); err != nil {
+				return err
+			}
+		}
+	}
+	if proc.Note != nil {
+		for _, n := range d.Notes {
+			if err := report(proc.Note(n)); err != nil {
+				return err
+			}
+		}
+	}
+	if proc.Document != nil {
+		for _, doc := range d.Documents {
+			if err := report(proc.Document(doc)); err != nil {
 				return err
 			}
 		}
 	}
 	if proc.DayEnd != nil {
-		if err := proc.DayEnd(d); err != nil {
+		if err := report(proc.DayEnd(d)); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,364 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	"go.uber.org/multierr"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+func TestFromPathUntil(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.knut": `accounttype Memo off-balance
+2022-01-01 open Assets:Bank
+2022-01-01 open Income:Salary
+include "2022.knut"
+include "2023.knut"
+include "2024.knut"
+`,
+		"2022.knut": "2022-05-01 \"Salary\"\nIncome:Salary Assets:Bank 100 CHF\n",
+		"2023.knut": "2023-05-01 \"Salary\"\nIncome:Salary Assets:Bank 100 CHF\n\n2024-01-01 open Memo:Guarantees\n",
+		"2024.knut": "@accrue monthly 2022-01-01 2022-12-31 Assets:Bank\n2024-05-01 \"Accrued salary\"\nIncome:Salary Assets:Bank 1200 CHF\n",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		desc     string
+		end      time.Time
+		salaries int
+		accruals bool
+	}{
+		{
+			desc:     "no end",
+			salaries: 2,
+			accruals: true,
+		},
+		{
+			desc:     "skip a file after the end",
+			end:      date.Date(2022, 12, 31),
+			salaries: 1,
+			accruals: true,
+		},
+		{
+			desc: "skip all files after the end",
+			end:  date.Date(2021, 12, 31),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			b, err := FromPathUntil(context.Background(), registry.New(), filepath.Join(dir, "main.knut"), test.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var (
+				salaries int
+				accruals bool
+			)
+			for _, d := range b.Build().Days {
+				for _, trx := range d.Transactions {
+					if trx.Description == "Salary" {
+						salaries++
+					} else {
+						accruals = true
+					}
+				}
+			}
+			if salaries != test.salaries || accruals != test.accruals {
+				t.Errorf("FromPathUntil() returned %d salaries and accruals %t, want %d and %t", salaries, accruals, test.salaries, test.accruals)
+			}
+			if end := b.Period().End; !end.Equal(date.Date(2024, 5, 1)) {
+				t.Errorf("FromPathUntil() returned a journal ending on %s, want 2024-05-01", end.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestBuildResolvesAliases(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.knut")
+	text := strings.Join([]string{
+		"2022-01-01 open Assets:Cash",
+		"2022-01-01 open Assets:Broker",
+		"",
+		"2022-01-02 \"Buy\"",
+		"Assets:Cash Assets:Broker 10 TWTR {40 USD}",
+		"",
+		"2022-01-03 price TWTR 41 USD",
+		"",
+		"2022-01-04 balance Assets:Broker 10 TWTR",
+		"",
+	}, "\n")
+	if err := os.WriteFile(file, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.New()
+	b, err := FromPath(context.Background(), reg, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The alias is declared after the directives have been created, as by
+	// a file which is processed later.
+	if err := reg.Commodities().Alias("TWTR", "X"); err != nil {
+		t.Fatal(err)
+	}
+	x := reg.Commodities().MustGet("X")
+
+	var got []*model.Commodity
+	for _, d := range b.Build().Days {
+		for _, trx := range d.Transactions {
+			for _, p := range trx.Postings {
+				got = append(got, p.Commodity)
+			}
+		}
+		for _, p := range d.Prices {
+			got = append(got, p.Commodity)
+		}
+		for _, a := range d.Assertions {
+			for _, bal := range a.Balances {
+				got = append(got, bal.Commodity)
+			}
+		}
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("got %d commodities, want 4", len(got))
+	}
+	for _, c := range got {
+		if c != x {
+			t.Errorf("got commodity %s, want X", c)
+		}
+	}
+}
+
+func TestApplyValues(t *testing.T) {
+	var (
+		reg    = registry.New()
+		chf    = reg.Commodities().MustGet("CHF")
+		house  = reg.Accounts().MustGet("Assets:House")
+		equity = reg.Accounts().MustGet("Equity:Equity")
+		b      = New()
+	)
+	b.Add(transaction.Builder{
+		Date: date.Date(2023, 1, 1),
+		Postings: posting.Builder{
+			Credit:    equity,
+			Debit:     house,
+			Commodity: chf,
+			Quantity:  decimal.NewFromInt(700000),
+		}.Build(),
+	}.Build())
+	for _, v := range []*model.Value{
+		{Date: date.Date(2023, 6, 30), Account: house, Commodity: chf, Quantity: decimal.NewFromInt(750000)},
+		{Date: date.Date(2023, 12, 31), Account: house, Commodity: chf, Quantity: decimal.NewFromInt(750000)},
+		{Date: date.Date(2024, 12, 31), Account: house, Commodity: chf, Quantity: decimal.NewFromInt(720000)},
+	} {
+		b.Add(v)
+	}
+	var got []string
+	record := &Processor{
+		Posting: func(t *model.Transaction, p *model.Posting) error {
+			if p.Account == house {
+				got = append(got, t.Date.Format("2006-01-02")+" "+p.Other.Name()+" "+p.Quantity.String())
+			}
+			return nil
+		},
+	}
+
+	if err := b.Build().Process(ApplyValues(reg), record); err != nil {
+		t.Fatalf("Process() returned unexpected error: %v", err)
+	}
+
+	want := []string{
+		"2023-01-01 Equity:Equity 700000",
+		"2023-06-30 Income:House 50000",
+		"2024-12-31 Income:House -30000",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Process() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if got, want := b.Period().End, date.Date(2024, 12, 31); !got.Equal(want) {
+		t.Errorf("Period().End = %s, want %s", got, want)
+	}
+}
+
+func TestAutoOpen(t *testing.T) {
+	var (
+		reg    = registry.New()
+		chf    = reg.Commodities().MustGet("CHF")
+		bank   = reg.Accounts().MustGet("Assets:Bank")
+		cash   = reg.Accounts().MustGet("Assets:Cash")
+		salary = reg.Accounts().MustGet("Income:Salary")
+		b      = New()
+	)
+	b.Add(&model.Open{Date: date.Date(2023, 1, 1), Account: bank})
+	for _, d := range []time.Time{date.Date(2023, 1, 25), date.Date(2023, 2, 25)} {
+		b.Add(transaction.Builder{
+			Date: d,
+			Postings: posting.Builder{
+				Credit:    salary,
+				Debit:     bank,
+				Commodity: chf,
+				Quantity:  decimal.NewFromInt(5000),
+			}.Build(),
+		}.Build())
+	}
+	b.Add(&model.Assertion{
+		Date:     date.Date(2023, 3, 1),
+		Balances: []model.Balance{{Account: cash, Commodity: chf}},
+	})
+	b.Add(&model.Close{Date: date.Date(2023, 3, 31), Account: cash})
+	b.Add(&model.Assertion{
+		Date:     date.Date(2023, 4, 1),
+		Balances: []model.Balance{{Account: cash, Commodity: chf}},
+	})
+	var got []string
+	record := &Processor{
+		Open: func(o *model.Open) error {
+			got = append(got, o.Date.Format("2006-01-02")+" "+o.Account.Name())
+			return nil
+		},
+	}
+
+	if err := b.Build().Process(AutoOpen(), record); err != nil {
+		t.Fatalf("Process() returned unexpected error: %v", err)
+	}
+
+	want := []string{
+		"2023-01-01 Assets:Bank",
+		"2023-01-25 Income:Salary",
+		"2023-03-01 Assets:Cash",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Process() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func TestProcessKeepGoing(t *testing.T) {
+	var (
+		reg  = registry.New()
+		chf  = reg.Commodities().MustGet("CHF")
+		bank = reg.Accounts().MustGet("Assets:Bank")
+		b    = New()
+	)
+	for _, d := range []time.Time{date.Date(2023, 1, 25), date.Date(2023, 1, 1), date.Date(2023, 2, 25)} {
+		b.Add(&model.Assertion{
+			Date:     d,
+			Balances: []model.Balance{{Account: bank, Commodity: chf}},
+		})
+	}
+	fail := &Processor{
+		Assertion: func(a *model.Assertion) error {
+			return fmt.Errorf("%s: failed", a.Date.Format("2006-01-02"))
+		},
+	}
+	var days int
+	count := &Processor{
+		DayEnd: func(d *Day) error {
+			days++
+			return nil
+		},
+	}
+	if err := b.Build().Process(fail, count); err == nil || days != 0 {
+		t.Fatalf("Process() returned %v after %d days, want the first error", err, days)
+	}
+
+	err := b.Build().ProcessWithOptions(Options{KeepGoing: true}, fail, count)
+
+	var got []string
+	for _, err := range multierr.Errors(err) {
+		got = append(got, err.Error())
+	}
+	want := []string{"2023-01-01: failed", "2023-01-25: failed", "2023-02-25: failed"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ProcessWithOptions() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if days != 3 {
+		t.Errorf("ProcessWithOptions() processed %d days, want 3", days)
+	}
+}
+
+func TestIntoCost(t *testing.T) {
+	var (
+		reg       = registry.New()
+		usd       = reg.Commodities().MustGet("USD")
+		aapl      = reg.Commodities().MustGet("AAPL")
+		portfolio = reg.Accounts().MustGet("Assets:Portfolio")
+		equity    = reg.Accounts().MustGet("Equity:Equity")
+		b         = New()
+	)
+	for _, p := range []*model.Price{
+		{Date: date.Date(2023, 1, 1), Commodity: aapl, Target: usd, Price: decimal.NewFromInt(100)},
+		{Date: date.Date(2023, 2, 1), Commodity: aapl, Target: usd, Price: decimal.NewFromInt(120)},
+		{Date: date.Date(2023, 3, 1), Commodity: aapl, Target: usd, Price: decimal.NewFromInt(150)},
+	} {
+		b.Add(p)
+	}
+	for _, trx := range []struct {
+		date     time.Time
+		quantity int64
+		cost     int64
+	}{
+		{date: date.Date(2023, 1, 2), quantity: 10},
+		{date: date.Date(2023, 2, 2), quantity: 10, cost: 115},
+		{date: date.Date(2023, 3, 2), quantity: -5},
+		{date: date.Date(2023, 3, 3), quantity: -20},
+	} {
+		pb := posting.Builder{
+			Credit:    equity,
+			Debit:     portfolio,
+			Commodity: aapl,
+			Quantity:  decimal.NewFromInt(trx.quantity),
+		}
+		if trx.cost != 0 {
+			pb.Cost, pb.CostCommodity = decimal.NewFromInt(trx.cost), usd
+		}
+		b.Add(transaction.Builder{Date: trx.date, Postings: pb.Build()}.Build())
+	}
+	costs := amounts.NewTable()
+	query := Query{
+		Select:    amounts.KeyMapper{Date: mapper.Identity[time.Time]}.Build(),
+		Valuation: usd,
+	}
+
+	if err := b.Build().Process(ComputePrices(usd), Valuate(reg, usd), query.IntoCost(costs)); err != nil {
+		t.Fatalf("Process() returned unexpected error: %v", err)
+	}
+
+	want := map[time.Time]string{
+		// Acquisition at value.
+		date.Date(2023, 1, 2): "1000",
+		// Acquisition at cost.
+		date.Date(2023, 2, 2): "1150",
+		// Reduction at the average cost of 107.5.
+		date.Date(2023, 3, 2): "-537.5",
+		// Reduction of the remaining position at its cost of 1612.5, and a
+		// short position of 5 at its value of 750.
+		date.Date(2023, 3, 3): "-2362.5",
+	}
+	for d, w := range want {
+		if got := costs.Amount(amounts.Key{Date: d}); !got.Equal(decimal.RequireFromString(w)) {
+			t.Errorf("cost on %s = %s, want %s", d.Format("2006-01-02"), got, w)
+		}
+	}
+	if costs.Len() != len(want) {
+		t.Errorf("got %d costs, want %d", costs.Len(), len(want))
+	}
+}
@@ -0,0 +1,212 @@
+// Package lots tracks the lots of commodities held in asset and liability
+// accounts. A lot is opened by a posting with a cost which increases the
+// position and is reduced by postings which decrease it, first in, first
+// out. Reductions with a cost only reduce lots with that cost, and, if the
+// posting carries a lot date, with that date.
+//
+// With average cost booking, all lots of a commodity in an account with the
+// same cost commodity are pooled into a single lot at the weighted average
+// cost, and reductions are valued at that cost.
+package lots
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/shopspring/decimal"
+)
+
+// Method is a booking method, which determines which lots are reduced.
+type Method int
+
+const (
+	// FIFO reduces the oldest lots first.
+	FIFO Method = iota
+	// Average pools the lots at their weighted average cost.
+	Average
+)
+
+func (m Method) String() string {
+	switch m {
+	case FIFO:
+		return "fifo"
+	case Average:
+		return "avg"
+	}
+	return ""
+}
+
+// ParseMethod parses a booking method, ignoring case.
+func ParseMethod(s string) (Method, error) {
+	for _, m := range []Method{FIFO, Average} {
+		if strings.EqualFold(m.String(), s) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid booking method %q, want fifo or avg", s)
+}
+
+// Lot is a quantity of a commodity acquired at a given date and unit cost.
+type Lot struct {
+	Date          time.Time
+	Quantity      decimal.Decimal
+	Cost          decimal.Decimal
+	CostCommodity *model.Commodity
+}
+
+func (l *Lot) matches(cost decimal.Decimal, costCommodity *model.Commodity, date time.Time) bool {
+	if costCommodity != nil && (l.CostCommodity != costCommodity || !l.Cost.Equal(cost)) {
+		return false
+	}
+	return date.IsZero() || l.Date.Equal(date)
+}
+
+// Inventory contains the lots per account and commodity.
+type Inventory struct {
+	method Method
+	lots   map[amounts.Key][]*Lot
+}
+
+// New creates an empty inventory using the given booking method.
+func New(m Method) *Inventory {
+	return &Inventory{method: m, lots: make(map[amounts.Key][]*Lot)}
+}
+
+// Book books a posting of a transaction at the given date. It returns the
+// parts of the lots which have been reduced by the posting.
+func (inv *Inventory) Book(date time.Time, p *model.Posting) []Lot {
+	if p.Virtual || !p.Account.IsAL() || p.Quantity.IsZero() {
+		return nil
+	}
+	key := amounts.AccountCommodityKey(p.Account, p.Commodity)
+	if p.Quantity.IsPositive() {
+		if p.CostCommodity == nil {
+			return nil
+		}
+		if !p.LotDate.IsZero() {
+			date = p.LotDate
+		}
+		inv.add(key, date, p.Quantity, p.Cost, p.CostCommodity)
+		return nil
+	}
+	if inv.method == Average {
+		// Pooled lots have no identity, so the cost and date of the
+		// posting do not select lots.
+		return inv.reduce(key, p.Quantity.Neg(), decimal.Zero, nil, time.Time{})
+	}
+	return inv.reduce(key, p.Quantity.Neg(), p.Cost, p.CostCommodity, p.LotDate)
+}
+
+func (inv *Inventory) add(key amounts.Key, date time.Time, qty, cost decimal.Decimal, costCommodity *model.Commodity) {
+	lots := inv.lots[key]
+	for _, l := range lots {
+		if inv.method == Average && l.CostCommodity == costCommodity {
+			total := l.Quantity.Add(qty)
+			l.Cost = l.Cost.Mul(l.Quantity).Add(cost.Mul(qty)).Div(total)
+			l.Quantity = total
+			return
+		}
+		if inv.method == FIFO && l.matches(cost, costCommodity, date) {
+			l.Quantity = l.Quantity.Add(qty)
+			return
+		}
+	}
+	// Keep the lots ordered by date, such that reductions are first in,
+	// first out.
+	i := len(lots)
+	for i > 0 && lots[i-1].Date.After(date) {
+		i--
+	}
+	inv.lots[key] = slices.Insert(lots, i, &Lot{
+		Date:          date,
+		Quantity:      qty,
+		Cost:          cost,
+		CostCommodity: costCommodity,
+	})
+}
+
+func (inv *Inventory) reduce(key amounts.Key, qty, cost decimal.Decimal, costCommodity *model.Commodity, date time.Time) []Lot {
+	var (
+		lots    = inv.lots[key]
+		reduced []Lot
+	)
+	for _, l := range lots {
+		if qty.IsZero() {
+			break
+		}
+		if !l.matches(cost, costCommodity, date) {
+			continue
+		}
+		r := decimal.Min(qty, l.Quantity)
+		l.Quantity = l.Quantity.Sub(r)
+		qty = qty.Sub(r)
+		reduced = append(reduced, Lot{Date: l.Date, Quantity: r, Cost: l.Cost, CostCommodity: l.CostCommodity})
+	}
+	lots = slices.DeleteFunc(lots, func(l *Lot) bool { return l.Quantity.IsZero() })
+	if len(lots) == 0 {
+		delete(inv.lots, key)
+	} else {
+		inv.lots[key] = lots
+	}
+	return reduced
+}
+
+// Lots returns the lots of a commodity in an account, ordered by date.
+func (inv *Inventory) Lots(a *model.Account, c *model.Commodity) []Lot {
+	var res []Lot
+	for _, l := range inv.lots[amounts.AccountCommodityKey(a, c)] {
+		res = append(res, *l)
+	}
+	return res
+}
+
+// Positions returns the accounts and commodities which have lots, ordered by
+// account and commodity.
+func (inv *Inventory) Positions() []amounts.Key {
+	keys := make([]amounts.Key, 0, len(inv.lots))
+	for k := range inv.lots {
+		keys = append(keys, k)
+	}
+	compare.Sort(keys, func(k1, k2 amounts.Key) compare.Order {
+		if o := account.Compare(k1.Account, k2.Account); o != compare.Equal {
+			return o
+		}
+		return commodity.Compare(k1.Commodity, k2.Commodity)
+	})
+	return keys
+}
+
+// Quantity returns the quantity of the lots of a commodity in an account with
+// the given cost. If date is not zero, only lots of that date are considered.
+// With average cost booking, the average cost is rounded to the precision of
+// the given cost, and the date is the date of the first acquisition.
+func (inv *Inventory) Quantity(a *model.Account, c *model.Commodity, cost decimal.Decimal, costCommodity *model.Commodity, date time.Time) decimal.Decimal {
+	res := decimal.Zero
+	for _, l := range inv.lots[amounts.AccountCommodityKey(a, c)] {
+		if inv.method == Average {
+			rounded := *l
+			rounded.Cost = l.Cost.Round(-cost.Exponent())
+			l = &rounded
+		}
+		if l.matches(cost, costCommodity, date) {
+			res = res.Add(l.Quantity)
+		}
+	}
+	return res
+}
+
+// Close removes the lots of an account.
+func (inv *Inventory) Close(a *model.Account) {
+	for key := range inv.lots {
+		if key.Account == a {
+			delete(inv.lots, key)
+		}
+	}
+}
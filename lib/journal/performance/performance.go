@@ -2,14 +2,19 @@ package performance
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"regexp"
+	"slices"
 
 	"github.com/sboehler/knut/lib/amounts"
 	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
 	"github.com/sboehler/knut/lib/common/predicate"
 	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/registry"
 )
 
@@ -19,6 +24,10 @@ type Calculator struct {
 	Valuation       *model.Commodity
 	AccountFilter   predicate.Predicate[*model.Account]
 	CommodityFilter predicate.Predicate[*model.Commodity]
+
+	// Groups are used to match the patterns of performance annotations
+	// against the qualified names of commodities, e.g. Crypto:BTC.
+	Groups commodity.Groups
 }
 
 // ComputeValues computes portfolio performance.
@@ -37,6 +46,10 @@ func (calc *Calculator) ComputeValues() *journal.Processor {
 		},
 
 		Posting: func(_ *model.Transaction, p *model.Posting) error {
+			if p.Virtual {
+				// virtual postings are not part of the portfolio.
+				return nil
+			}
 			if !calc.CommodityFilter(p.Commodity) {
 				return nil
 			}
@@ -88,10 +101,15 @@ func (calc *Calculator) ComputeFlows() *journal.Processor {
 
 			// tgts contains the commodities among which the performance effects of this
 			// transaction should be split: non-currencies > currencies > valuation currency.
-			tgts := pickTargets(calc.Valuation, t.Targets)
+			tgts := pickTargets(calc.Valuation, calc.targets(t, performance.V0))
 
 			for _, p := range t.Postings {
 
+				if p.Virtual {
+					// virtual posting - no performance impact.
+					continue
+				}
+
 				if !calc.isPortfolioAccount(p.Account) {
 					// not a portfolio booking - no performance impact.
 					continue
@@ -158,6 +176,42 @@ func get(m *pcv) pcv {
 	return *m
 }
 
+// targets returns the targets of the transaction, including the commodities
+// of the portfolio and the transaction which match its patterns.
+func (calc *Calculator) targets(t *model.Transaction, values pcv) []*model.Commodity {
+	if len(t.Patterns) == 0 {
+		return t.Targets
+	}
+	candidates := set.New[*model.Commodity]()
+	for c := range values {
+		candidates.Add(c)
+	}
+	for _, p := range t.Postings {
+		if calc.CommodityFilter(p.Commodity) {
+			candidates.Add(p.Commodity)
+		}
+	}
+	res := append([]*model.Commodity{}, t.Targets...)
+	for _, c := range candidates.Sorted(commodity.Compare) {
+		if !slices.Contains(res, c) && calc.matches(t.Patterns, c) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func (calc *Calculator) matches(patterns []*regexp.Regexp, c *model.Commodity) bool {
+	for _, rx := range patterns {
+		if rx.MatchString(c.Name()) {
+			return true
+		}
+		if calc.Groups != nil && rx.MatchString(calc.Groups.Group(c)+":"+c.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
 func pickTargets(valuation *model.Commodity, tgts []*model.Commodity) []*model.Commodity {
 	if len(tgts) == 0 {
 		return tgts
@@ -166,7 +220,7 @@ func pickTargets(valuation *model.Commodity, tgts []*model.Commodity) []*model.C
 
 	// collect non-currencies
 	for _, c := range tgts {
-		if !c.IsCurrency {
+		if !c.IsCurrency() {
 			res = append(res, c)
 		}
 	}
@@ -192,10 +246,38 @@ func (calc Calculator) isPortfolioAccount(a *model.Account) bool {
 	return a.IsAL() && calc.AccountFilter(a)
 }
 
-// perf = ( V1 - Outflow ) / ( V0 + Inflow )
+// Weights are the fractions of a day during which the external inflows and
+// outflows of the day are invested in the portfolio.
+type Weights struct {
+	Inflow, Outflow float64
+}
+
+// EndOfDay assumes that inflows arrive at the start and outflows leave at
+// the end of the day, so that both take part in the market moves of the day.
+var EndOfDay = Weights{Inflow: 1, Outflow: 0}
 
-// Performance computes the portfolio performance.
+// AtTime returns the weights of the Modified Dietz method for flows which
+// occur at the given time of the day, as a fraction between 0 (start of the
+// day) and 1 (end of the day). Midday flows, AtTime(0.5), reduce the
+// distortion on days with large deposits or withdrawals and market moves.
+func AtTime(t float64) (Weights, error) {
+	if t < 0 || t > 1 {
+		return Weights{}, fmt.Errorf("invalid time of day %v, want a fraction between 0 and 1", t)
+	}
+	return Weights{Inflow: 1 - t, Outflow: 1 - t}, nil
+}
+
+// perf = 1 + ( V1 - V0 - Inflow - Outflow ) / ( V0 + wIn * Inflow + wOut * Outflow )
+
+// Performance computes the portfolio performance, with inflows at the start
+// and outflows at the end of the day.
 func Performance(dpv *journal.Performance) float64 {
+	return EndOfDay.Performance(dpv)
+}
+
+// Performance computes the portfolio performance, weighting the external
+// flows of the day with w.
+func (w Weights) Performance(dpv *journal.Performance) float64 {
 	var (
 		v0, v1          float64
 		inflow, outflow = dpv.PortfolioInflow, dpv.PortfolioOutflow
@@ -215,23 +297,122 @@ func Performance(dpv *journal.Performance) float64 {
 	if v0 == v1 && inflow == 0 && outflow == 0 {
 		return 1
 	}
-	return (v1 - outflow) / (v0 + inflow)
+	capital := w.capital(v0, inflow, outflow)
+	if capital == 0 {
+		// nothing was invested during the day, e.g. an inflow at its very end.
+		return 1
+	}
+	return 1 + (v1-v0-inflow-outflow)/capital
+}
+
+// capital returns the average capital invested during the day.
+func (w Weights) capital(v0, inflow, outflow float64) float64 {
+	return v0 + w.Inflow*inflow + w.Outflow*outflow
+}
+
+// Contributions computes the contribution of each commodity to the return of
+// a day, i.e. the weight of the commodity times its return. The part of the
+// return which cannot be attributed to a commodity, e.g. fees charged to the
+// portfolio as a whole, is reported under the nil key. The contributions sum
+// up to Performance(dpv) - 1.
+func Contributions(dpv *journal.Performance) map[*model.Commodity]float64 {
+	return EndOfDay.Contributions(dpv)
+}
+
+// Contributions is like the package-level Contributions, but weights the
+// external flows of the day with w.
+func (w Weights) Contributions(dpv *journal.Performance) map[*model.Commodity]float64 {
+	var (
+		v0, inflow, outflow = 0.0, dpv.PortfolioInflow, dpv.PortfolioOutflow
+		commodities         = set.New[*model.Commodity]()
+	)
+	for c, v := range dpv.V0 {
+		v0 += v
+		commodities.Add(c)
+	}
+	for c, v := range dpv.Inflow {
+		inflow += v
+		commodities.Add(c)
+	}
+	for c, v := range dpv.Outflow {
+		outflow += v
+		commodities.Add(c)
+	}
+	for _, m := range []pcv{dpv.V1, dpv.InternalInflow, dpv.InternalOutflow} {
+		for c := range m {
+			commodities.Add(c)
+		}
+	}
+	res := make(pcv)
+	capital := w.capital(v0, inflow, outflow)
+	if capital == 0 {
+		return res
+	}
+	var total float64
+	for c := range commodities {
+		in := dpv.Inflow[c] + dpv.InternalInflow[c]
+		out := dpv.Outflow[c] + dpv.InternalOutflow[c]
+		if contrib := (dpv.V1[c] - out - dpv.V0[c] - in) / capital; contrib != 0 {
+			res[c] = contrib
+			total += contrib
+		}
+	}
+	if rest := w.Performance(dpv) - 1 - total; math.Abs(rest) > 1e-12 {
+		res[nil] = rest
+	}
+	return res
+}
+
+// Perf prints the portfolio return for each period of the partition to out,
+// weighting the external flows of each day with w.
+func Perf(out io.Writer, j *journal.Builder, part date.Partition, w Weights) *journal.Processor {
+	return perf(out, j, part, w, false)
+}
+
+// PerfByCommodity prints the portfolio return for each period of the
+// partition to out, followed by the contribution of each commodity.
+func PerfByCommodity(out io.Writer, j *journal.Builder, part date.Partition, w Weights) *journal.Processor {
+	return perf(out, j, part, w, true)
 }
 
-func Perf(j *journal.Builder, part date.Partition) *journal.Processor {
+func perf(out io.Writer, j *journal.Builder, part date.Partition, w Weights, breakdown bool) *journal.Processor {
 	ds := set.FromSlice(j.Days(part.EndDates()))
 	running := 1.0
+	contributions := make(pcv)
 	return &journal.Processor{
 		DayEnd: func(d *journal.Day) error {
 			if !part.Contains(d.Date) {
 				return nil
 			}
-			running *= Performance(d.Performance)
+			if breakdown {
+				// Daily contributions are scaled by the growth of the portfolio
+				// since the start of the period, so that they add up to the
+				// return of the period.
+				for c, v := range w.Contributions(d.Performance) {
+					contributions[c] += running * v
+				}
+			}
+			running *= w.Performance(d.Performance)
 			if ds.Has(d) {
-				fmt.Printf("%v: %0.1f%%\n", d.Date, 100*(running-1))
+				fmt.Fprintf(out, "%v: %0.1f%%\n", d.Date, 100*(running-1))
+				if breakdown {
+					printContributions(out, contributions)
+				}
 				running = 1.0
+				contributions = make(pcv)
 			}
 			return nil
 		},
 	}
 }
+
+func printContributions(out io.Writer, contributions pcv) {
+	rest, ok := contributions[nil]
+	delete(contributions, nil)
+	for _, c := range dict.SortedKeys(contributions, commodity.Compare) {
+		fmt.Fprintf(out, "  %s: %0.1f%%\n", c.Name(), 100*contributions[c])
+	}
+	if ok {
+		fmt.Fprintf(out, "  (unallocated): %0.1f%%\n", 100*rest)
+	}
+}
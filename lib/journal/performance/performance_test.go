@@ -5,10 +5,12 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/sboehler/knut/lib/common/date"
 	"github.com/sboehler/knut/lib/common/predicate"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/registry"
 	"github.com/sboehler/knut/lib/model/transaction"
@@ -29,9 +31,11 @@ func TestComputeFlows(t *testing.T) {
 	expense := ctx.Accounts().MustGet("Expenses:Investments")
 	equity := ctx.Accounts().MustGet("Equity:Equity")
 
-	chf.IsCurrency = true
-	usd.IsCurrency = true
-	gbp.IsCurrency = true
+	for _, c := range []string{"CHF", "USD", "GBP"} {
+		if err := ctx.Commodities().TagCurrency(c); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	tests := []struct {
 		desc string
@@ -274,6 +278,21 @@ func TestComputeFlows(t *testing.T) {
 				InternalInflow:  pcv{usd: 1370.0},
 			},
 		},
+		{
+			desc: "virtual posting",
+			trx: transaction.Builder{
+				Postings: []*model.Posting{
+					{
+						Account:   portfolio,
+						Other:     portfolio,
+						Value:     decimal.NewFromInt(100),
+						Commodity: usd,
+						Virtual:   true,
+					},
+				},
+			}.Build(),
+			want: &journal.Performance{},
+		},
 	}
 
 	for _, test := range tests {
@@ -298,3 +317,268 @@ func TestComputeFlows(t *testing.T) {
 	}
 
 }
+
+func TestComputeValues(t *testing.T) {
+	ctx := registry.New()
+	usd := ctx.Commodities().MustGet("USD")
+	portfolio := ctx.Accounts().MustGet("Assets:Portfolio")
+	equity := ctx.Accounts().MustGet("Equity:Equity")
+	day := &journal.Day{
+		Date: date.Date(2021, 11, 15),
+		Transactions: []*model.Transaction{
+			transaction.Builder{
+				Postings: append(posting.Builder{
+					Credit:    equity,
+					Debit:     portfolio,
+					Value:     decimal.NewFromInt(100),
+					Commodity: usd,
+				}.Build(), &model.Posting{
+					Account:   portfolio,
+					Other:     portfolio,
+					Value:     decimal.NewFromInt(50),
+					Commodity: usd,
+					Virtual:   true,
+				}),
+			}.Build(),
+		},
+	}
+	calc := Calculator{
+		AccountFilter: predicate.ByName[*model.Account]([]*regexp.Regexp{
+			regexp.MustCompile("Assets:Portfolio"),
+		}),
+		CommodityFilter: predicate.True[*model.Commodity],
+	}
+
+	if err := calc.ComputeValues().Process(day); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(pcv{usd: 100}, day.Performance.V1); diff != "" {
+		t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestComputeFlowsPatterns(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	btc := reg.Commodities().MustGet("BTC")
+	eth := reg.Commodities().MustGet("ETH")
+	aapl := reg.Commodities().MustGet("AAPL")
+	portfolio := reg.Accounts().MustGet("Assets:Portfolio")
+	expense := reg.Accounts().MustGet("Expenses:Fees")
+	if err := reg.Commodities().TagCurrency("CHF"); err != nil {
+		t.Fatal(err)
+	}
+	fee := posting.Builder{
+		Credit:    portfolio,
+		Debit:     expense,
+		Value:     decimal.NewFromInt(10),
+		Commodity: chf,
+	}.Build()
+
+	tests := []struct {
+		desc     string
+		patterns []string
+		want     *journal.Performance
+	}{
+		{
+			desc:     "commodity names",
+			patterns: []string{"^(BTC|ETH)$"},
+			want: &journal.Performance{
+				InternalOutflow: pcv{chf: -10},
+				InternalInflow:  pcv{btc: 5, eth: 5},
+			},
+		},
+		{
+			desc:     "group names",
+			patterns: []string{"^Crypto:"},
+			want: &journal.Performance{
+				InternalOutflow: pcv{chf: -10},
+				InternalInflow:  pcv{btc: 5, eth: 5},
+			},
+		},
+		{
+			desc:     "held commodities only",
+			patterns: []string{"AAPL|MSFT"},
+			want: &journal.Performance{
+				InternalOutflow: pcv{chf: -10},
+				InternalInflow:  pcv{aapl: 10},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var patterns []*regexp.Regexp
+			for _, p := range test.patterns {
+				patterns = append(patterns, regexp.MustCompile(p))
+			}
+			values := pcv{btc: 100, eth: 100, aapl: 100}
+			day := &journal.Day{
+				Date: date.Date(2021, 11, 15),
+				Transactions: []*model.Transaction{
+					transaction.Builder{Targets: []*model.Commodity{}, Patterns: patterns, Postings: fee}.Build(),
+				},
+				Performance: &journal.Performance{V0: values},
+			}
+			calc := Calculator{
+				AccountFilter: predicate.ByName[*model.Account]([]*regexp.Regexp{
+					regexp.MustCompile("Assets:Portfolio"),
+				}),
+				CommodityFilter: predicate.True[*model.Commodity],
+				Valuation:       chf,
+				Groups:          commodity.Groups{btc: "Crypto", eth: "Crypto"},
+			}
+
+			calc.ComputeFlows().Process(day)
+
+			test.want.V0 = values
+			if diff := cmp.Diff(test.want, day.Performance); diff != "" {
+				t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContributions(t *testing.T) {
+	reg := registry.New()
+	usd := reg.Commodities().MustGet("USD")
+	aapl := reg.Commodities().MustGet("AAPL")
+
+	tests := []struct {
+		desc string
+		dpv  *journal.Performance
+		want pcv
+	}{
+		{
+			desc: "price change",
+			dpv: &journal.Performance{
+				V0: pcv{aapl: 100, usd: 100},
+				V1: pcv{aapl: 110, usd: 100},
+			},
+			want: pcv{aapl: 0.05},
+		},
+		{
+			desc: "dividend attributed to stock",
+			dpv: &journal.Performance{
+				V0:              pcv{aapl: 100},
+				V1:              pcv{aapl: 100, usd: 2},
+				InternalInflow:  pcv{usd: 2},
+				InternalOutflow: pcv{aapl: -2},
+			},
+			want: pcv{aapl: 0.02},
+		},
+		{
+			desc: "unallocated fee",
+			dpv: &journal.Performance{
+				V0:               pcv{aapl: 100},
+				V1:               pcv{aapl: 99},
+				PortfolioOutflow: -1,
+			},
+			want: pcv{aapl: -0.01, nil: 0.01},
+		},
+		{
+			desc: "empty portfolio",
+			dpv:  &journal.Performance{},
+			want: pcv{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := Contributions(test.dpv)
+
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Contributions() returned unexpected diff (-want/+got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestWeightsPerformance(t *testing.T) {
+	reg := registry.New()
+	usd := reg.Commodities().MustGet("USD")
+	midday, err := AtTime(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc    string
+		weights Weights
+		dpv     *journal.Performance
+		want    float64
+	}{
+		{
+			desc:    "end of day, inflow",
+			weights: EndOfDay,
+			dpv: &journal.Performance{
+				V0:     pcv{usd: 100},
+				V1:     pcv{usd: 1210},
+				Inflow: pcv{usd: 1000},
+			},
+			want: 1.1,
+		},
+		{
+			desc:    "midday, inflow",
+			weights: midday,
+			dpv: &journal.Performance{
+				V0:     pcv{usd: 100},
+				V1:     pcv{usd: 1210},
+				Inflow: pcv{usd: 1000},
+			},
+			want: 1 + 110.0/600,
+		},
+		{
+			desc:    "midday, outflow",
+			weights: midday,
+			dpv: &journal.Performance{
+				V0:      pcv{usd: 1000},
+				V1:      pcv{usd: 110},
+				Outflow: pcv{usd: -900},
+			},
+			want: 1 + 10.0/550,
+		},
+		{
+			desc:    "end of day, outflow",
+			weights: EndOfDay,
+			dpv: &journal.Performance{
+				V0:      pcv{usd: 1000},
+				V1:      pcv{usd: 110},
+				Outflow: pcv{usd: -900},
+			},
+			want: 1.01,
+		},
+		{
+			desc:    "inflow into empty portfolio at the end of the day",
+			weights: Weights{},
+			dpv: &journal.Performance{
+				V1:     pcv{usd: 1000},
+				Inflow: pcv{usd: 1000},
+			},
+			want: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := test.weights.Performance(test.dpv)
+
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Performance() returned unexpected diff (-want/+got):\n%s\n", diff)
+			}
+			var sum float64
+			for _, c := range test.weights.Contributions(test.dpv) {
+				sum += c
+			}
+			if diff := cmp.Diff(test.want-1, sum, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Contributions() do not sum up to the return (-want/+got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestAtTime(t *testing.T) {
+	for _, v := range []float64{-0.1, 1.1} {
+		if _, err := AtTime(v); err == nil {
+			t.Errorf("AtTime(%v) returned no error", v)
+		}
+	}
+}
@@ -51,6 +51,19 @@ func fromYAML(reg *commodity.Registry, yaml yamlUniverseFile) (Universe, error)
 	return universe, nil
 }
 
+// UniverseByMetadata classifies the commodities of the registry by their
+// value for the given metadata key. Commodities without a value are
+// classified as Other.
+func UniverseByMetadata(reg *commodity.Registry, key string) Universe {
+	universe := make(Universe)
+	for _, com := range reg.All() {
+		if class, ok := com.Metadata(key); ok {
+			universe[com] = []string{class, com.Name()}
+		}
+	}
+	return universe
+}
+
 func (un Universe) Locate(c *model.Commodity) []string {
 	class, ok := un[c]
 	if ok {
@@ -0,0 +1,173 @@
+package journal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/shopspring/decimal"
+)
+
+// PriceCheck configures CheckPrices.
+type PriceCheck struct {
+	// MaxAge is the maximum age in days of the most recent price of a
+	// commodity which is held in an asset or liability account.
+	MaxAge int
+
+	// MaxChange is the maximum relative change between consecutive prices
+	// of a commodity pair, e.g. 0.5 for 50%. If it is zero, changes are not
+	// checked.
+	MaxChange decimal.Decimal
+}
+
+// PriceProblem is a problem with the prices of a journal.
+type PriceProblem struct {
+	Date    time.Time
+	Message string
+}
+
+func (p PriceProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Date.Format("2006-01-02"), p.Message)
+}
+
+// inversionTolerance is the relative deviation from 1 of the product of a
+// price and its inverse, above which they conflict. Inverses are often
+// written with few digits.
+var inversionTolerance = decimal.RequireFromString("0.01")
+
+// CheckPrices reports commodities which are held while their most recent
+// price is older than the maximum age, price directives on the same day
+// which disagree, also when they quote the inverse price, and changes
+// between consecutive prices which exceed the maximum change. The problems
+// are sorted by date.
+func CheckPrices(j *Journal, check PriceCheck) []PriceProblem {
+	c := &priceChecker{
+		check:    check,
+		seen:     make(map[pricePair]*model.Price),
+		latest:   make(map[pricePair]*model.Price),
+		updated:  make(map[*model.Commodity]time.Time),
+		holdings: make(map[*model.Commodity]decimal.Decimal),
+		gaps:     make(map[*model.Commodity]*priceGap),
+	}
+	for i, d := range j.Days {
+		clear(c.seen)
+		for _, p := range d.Prices {
+			c.checkPrice(p)
+		}
+		for _, t := range d.Transactions {
+			for _, p := range t.Postings {
+				if p.Account.IsAL() {
+					c.holdings[p.Commodity] = c.holdings[p.Commodity].Add(p.Quantity)
+				}
+			}
+		}
+		// Holdings and prices don't change until the next day.
+		end := d.Date
+		if i+1 < len(j.Days) {
+			end = j.Days[i+1].Date.AddDate(0, 0, -1)
+		}
+		c.checkGaps(d.Date, end)
+	}
+	for _, com := range dict.SortedKeys(c.gaps, commodity.Compare) {
+		c.reportGap(com)
+	}
+	slices.SortStableFunc(c.problems, func(p1, p2 PriceProblem) int {
+		return p1.Date.Compare(p2.Date)
+	})
+	return c.problems
+}
+
+type priceChecker struct {
+	check    PriceCheck
+	problems []PriceProblem
+
+	// seen holds the prices of the current day.
+	seen map[pricePair]*model.Price
+
+	// latest holds the most recent price of each commodity pair, in both
+	// directions.
+	latest map[pricePair]*model.Price
+
+	updated  map[*model.Commodity]time.Time
+	holdings map[*model.Commodity]decimal.Decimal
+	gaps     map[*model.Commodity]*priceGap
+}
+
+// priceGap is a period during which a commodity is held without a recent
+// price.
+type priceGap struct {
+	start, end, last time.Time
+}
+
+func (c *priceChecker) report(d time.Time, format string, args ...any) {
+	c.problems = append(c.problems, PriceProblem{Date: d, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *priceChecker) checkPrice(p *model.Price) {
+	if prev, ok := c.seen[pricePair{p.Commodity, p.Target}]; ok && !prev.Price.Equal(p.Price) {
+		c.report(p.Date, "conflicting prices for %s in %s: %s (%s) and %s (%s)",
+			p.Commodity.Name(), p.Target.Name(), prev.Price, priceLocation(prev), p.Price, priceLocation(p))
+	}
+	if prev, ok := c.seen[pricePair{p.Target, p.Commodity}]; ok {
+		if dev := prev.Price.Mul(p.Price).Sub(decimal.NewFromInt(1)).Abs(); dev.GreaterThan(inversionTolerance) {
+			c.report(p.Date, "conflicting inverse prices for %s in %s: %s (%s) and %s (%s)",
+				p.Commodity.Name(), p.Target.Name(), p.Price, priceLocation(p), decimal.NewFromInt(1).Div(prev.Price).Truncate(8), priceLocation(prev))
+		}
+	}
+	c.seen[pricePair{p.Commodity, p.Target}] = p
+	if prev, ok := c.latest[pricePair{p.Commodity, p.Target}]; ok && prev.Date.Before(p.Date) && !c.check.MaxChange.IsZero() {
+		price := prev.Price
+		if prev.Commodity != p.Commodity {
+			price = decimal.NewFromInt(1).Div(price)
+		}
+		if change := p.Price.Div(price).Sub(decimal.NewFromInt(1)); change.Abs().GreaterThan(c.check.MaxChange) {
+			c.report(p.Date, "price of %s in %s changes by %s%% from %s on %s to %s (%s)",
+				p.Commodity.Name(), p.Target.Name(), change.Mul(decimal.NewFromInt(100)).Round(0),
+				price.Truncate(8), prev.Date.Format("2006-01-02"), p.Price, priceLocation(p))
+		}
+	}
+	c.latest[pricePair{p.Commodity, p.Target}] = p
+	c.latest[pricePair{p.Target, p.Commodity}] = p
+	c.updated[p.Commodity] = p.Date
+	c.updated[p.Target] = p.Date
+}
+
+// checkGaps checks the commodities which are held from start to end, with
+// unchanged holdings and prices.
+func (c *priceChecker) checkGaps(start, end time.Time) {
+	for _, com := range dict.SortedKeys(c.holdings, commodity.Compare) {
+		last, ok := c.updated[com]
+		if !ok || c.holdings[com].IsZero() {
+			c.reportGap(com)
+			continue
+		}
+		s := start
+		if stale := last.AddDate(0, 0, c.check.MaxAge+1); stale.After(s) {
+			s = stale
+		}
+		if s.After(end) {
+			c.reportGap(com)
+			continue
+		}
+		if g, ok := c.gaps[com]; ok && g.end.AddDate(0, 0, 1).Equal(s) {
+			g.end = end
+			continue
+		}
+		c.reportGap(com)
+		c.gaps[com] = &priceGap{start: s, end: end, last: last}
+	}
+}
+
+// reportGap reports the current gap of the commodity, if any.
+func (c *priceChecker) reportGap(com *model.Commodity) {
+	g, ok := c.gaps[com]
+	if !ok {
+		return
+	}
+	delete(c.gaps, com)
+	c.report(g.start, "%s is held without a price for more than %d days until %s (last price on %s)",
+		com.Name(), c.check.MaxAge, g.end.Format("2006-01-02"), g.last.Format("2006-01-02"))
+}
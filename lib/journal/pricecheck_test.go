@@ -0,0 +1,69 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+)
+
+func TestCheckPrices(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	usd := reg.Commodities().MustGet("USD")
+	aapl := reg.Commodities().MustGet("AAPL")
+	b := New()
+	for _, p := range []*model.Price{
+		{Date: date.Date(2021, 1, 1), Commodity: usd, Target: chf, Price: decimal.RequireFromString("0.9")},
+		{Date: date.Date(2021, 1, 1), Commodity: chf, Target: usd, Price: decimal.RequireFromString("1.11")},
+		{Date: date.Date(2021, 1, 1), Commodity: aapl, Target: usd, Price: decimal.NewFromInt(100)},
+		{Date: date.Date(2021, 1, 2), Commodity: aapl, Target: usd, Price: decimal.NewFromInt(101)},
+		{Date: date.Date(2021, 1, 2), Commodity: aapl, Target: usd, Price: decimal.NewFromInt(102)},
+		{Date: date.Date(2021, 1, 3), Commodity: usd, Target: aapl, Price: decimal.RequireFromString("0.001")},
+		{Date: date.Date(2021, 1, 3), Commodity: chf, Target: usd, Price: decimal.NewFromInt(2)},
+		{Date: date.Date(2021, 1, 3), Commodity: usd, Target: chf, Price: decimal.RequireFromString("0.9")},
+		{Date: date.Date(2021, 1, 20), Commodity: usd, Target: chf, Price: decimal.RequireFromString("0.91")},
+	} {
+		b.Add(p)
+	}
+	b.Add(transaction.Builder{
+		Date: date.Date(2021, 1, 1),
+		Postings: posting.Builder{
+			Credit:    reg.Accounts().MustGet("Equity:Equity"),
+			Debit:     reg.Accounts().MustGet("Assets:Broker"),
+			Commodity: aapl,
+			Quantity:  decimal.NewFromInt(1),
+		}.Build(),
+	}.Build())
+	b.Add(transaction.Builder{
+		Date: date.Date(2021, 1, 30),
+		Postings: posting.Builder{
+			Credit:    reg.Accounts().MustGet("Assets:Broker"),
+			Debit:     reg.Accounts().MustGet("Equity:Equity"),
+			Commodity: aapl,
+			Quantity:  decimal.NewFromInt(1),
+		}.Build(),
+	}.Build())
+
+	got := CheckPrices(b.Build(), PriceCheck{MaxAge: 10, MaxChange: decimal.RequireFromString("0.5")})
+
+	var messages []string
+	for _, p := range got {
+		messages = append(messages, p.String())
+	}
+	want := []string{
+		"2021-01-02: conflicting prices for AAPL in USD: 101 (unknown location) and 102 (unknown location)",
+		"2021-01-03: price of USD in AAPL changes by -90% from 0.00980392 on 2021-01-02 to 0.001 (unknown location)",
+		"2021-01-03: price of CHF in USD changes by 80% from 1.11 on 2021-01-01 to 2 (unknown location)",
+		"2021-01-03: conflicting inverse prices for USD in CHF: 0.9 (unknown location) and 0.5 (unknown location)",
+		"2021-01-14: AAPL is held without a price for more than 10 days until 2021-01-29 (last price on 2021-01-03)",
+	}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("CheckPrices() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,409 @@
+package journal
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/price"
+	"github.com/shopspring/decimal"
+	"github.com/sourcegraph/conc/pool"
+)
+
+// PriceMode determines the price of a commodity on days without a price
+// directive.
+type PriceMode int
+
+const (
+	// PriceLast uses the most recent price.
+	PriceLast PriceMode = iota
+	// PriceInterpolate interpolates linearly between the surrounding prices.
+	PriceInterpolate
+	// PriceStrict uses the most recent price, but fails if it is too old.
+	PriceStrict
+)
+
+var priceModes = map[string]PriceMode{
+	"last":        PriceLast,
+	"interpolate": PriceInterpolate,
+	"strict":      PriceStrict,
+}
+
+// ParsePriceMode parses a price mode.
+func ParsePriceMode(s string) (PriceMode, error) {
+	m, ok := priceModes[s]
+	if !ok {
+		return m, fmt.Errorf("invalid price policy %q, want last, interpolate or strict", s)
+	}
+	return m, nil
+}
+
+func (m PriceMode) String() string {
+	for s, m2 := range priceModes {
+		if m == m2 {
+			return s
+		}
+	}
+	return fmt.Sprintf("PriceMode(%d)", int(m))
+}
+
+// PricePolicy configures how prices are computed.
+type PricePolicy struct {
+	Mode PriceMode
+
+	// MaxAge is the maximum age of a price in days, used with PriceStrict.
+	MaxAge int
+
+	// Warn is called with conflicting price directives, unless the mode is
+	// PriceStrict, where they are an error. If nil, conflicts are ignored.
+	Warn func(error)
+
+	// Via are the commodities through which prices are triangulated if
+	// possible, in order of preference.
+	Via []*model.Commodity
+}
+
+// ComputePricesWithPolicy updates prices according to the given policy.
+func ComputePricesWithPolicy(j *Builder, v *model.Commodity, policy PricePolicy) *Processor {
+	if v == nil {
+		return nil
+	}
+	var proc *Processor
+	switch policy.Mode {
+	case PriceInterpolate:
+		proc = interpolatePrices(j, v, policy.Via)
+	case PriceStrict:
+		proc = strictPrices(j, v, policy.MaxAge, policy.Via)
+	default:
+		proc = normalizePrices(j, v, policy.Via)
+	}
+	return detectConflicts(proc, policy)
+}
+
+// priceRegime holds the normalized prices from a day with price directives
+// up to the next such day.
+type priceRegime struct {
+	date       time.Time
+	normalized price.NormalizedPrices
+}
+
+// normalizePrices computes prices like ComputePrices. As normalizing the
+// prices dominates the cost, the normalized prices of all price regimes of
+// the journal are computed in advance, in parallel, on the first day.
+func normalizePrices(j *Builder, v *model.Commodity, via []*model.Commodity) *Processor {
+	var regimes []priceRegime
+	return &Processor{
+		DayStart: func(d *Day) error {
+			if regimes != nil {
+				return nil
+			}
+			var err error
+			regimes, err = computeRegimes(j, v, via)
+			return err
+		},
+		DayEnd: func(d *Day) error {
+			i, found := slices.BinarySearchFunc(regimes, d.Date, func(r priceRegime, t time.Time) int {
+				return compare.Time(r.date, t)
+			})
+			if found {
+				d.Normalized = regimes[i].normalized
+			} else if i > 0 {
+				d.Normalized = regimes[i-1].normalized
+			}
+			return nil
+		},
+	}
+}
+
+// computeRegimes inserts the price directives of the journal day by day, and
+// normalizes a snapshot of the prices of each day with price directives in
+// a pool of workers. The result is not nil.
+func computeRegimes(j *Builder, v *model.Commodity, via []*model.Commodity) ([]priceRegime, error) {
+	var (
+		days    = dict.SortedValues(j.days, CompareDays)
+		prc     = make(price.Prices)
+		regimes = make([]priceRegime, 0, len(days))
+		workers = pool.New().WithMaxGoroutines(runtime.GOMAXPROCS(0))
+	)
+	for _, d := range days {
+		if len(d.Prices) > 0 {
+			regimes = append(regimes, priceRegime{date: d.Date})
+		}
+	}
+	i := 0
+	for _, d := range days {
+		if len(d.Prices) == 0 {
+			continue
+		}
+		for _, p := range d.Prices {
+			if err := prc.Insert(p.Commodity, p.Price, p.Target); err != nil {
+				workers.Wait()
+				return nil, err
+			}
+		}
+		r, snapshot := &regimes[i], prc.Clone()
+		workers.Go(func() {
+			r.normalized = snapshot.Normalize(v, via...)
+		})
+		i++
+	}
+	workers.Wait()
+	return regimes, nil
+}
+
+// detectConflicts reports price directives for the same commodity pair and
+// date which disagree, also when one of them quotes the inverse price.
+func detectConflicts(proc *Processor, policy PricePolicy) *Processor {
+	var (
+		computePrice = proc.Price
+		computeStart = proc.DayStart
+		seen         = make(map[pricePair]*model.Price)
+	)
+	proc.DayStart = func(d *Day) error {
+		clear(seen)
+		if computeStart != nil {
+			return computeStart(d)
+		}
+		return nil
+	}
+	proc.Price = func(p *model.Price) error {
+		if err := priceConflict(seen, p); err != nil {
+			if policy.Mode == PriceStrict {
+				return err
+			}
+			if policy.Warn != nil {
+				policy.Warn(err)
+			}
+		}
+		seen[pricePair{p.Commodity, p.Target}] = p
+		if computePrice != nil {
+			return computePrice(p)
+		}
+		return nil
+	}
+	return proc
+}
+
+// priceConflict returns an error if p disagrees with a price of the same day
+// for the same commodity pair, or with the inverse price beyond
+// inversionTolerance.
+func priceConflict(seen map[pricePair]*model.Price, p *model.Price) error {
+	if prev, ok := seen[pricePair{p.Commodity, p.Target}]; ok && !prev.Price.Equal(p.Price) {
+		return fmt.Errorf("%s: conflicting prices for %s in %s: %s (%s) and %s (%s)",
+			p.Date.Format("2006-01-02"), p.Commodity.Name(), p.Target.Name(),
+			prev.Price, priceLocation(prev), p.Price, priceLocation(p))
+	}
+	if prev, ok := seen[pricePair{p.Target, p.Commodity}]; ok {
+		if dev := prev.Price.Mul(p.Price).Sub(decimal.NewFromInt(1)).Abs(); dev.GreaterThan(inversionTolerance) {
+			return fmt.Errorf("%s: conflicting inverse prices for %s in %s: %s (%s) and %s (%s)",
+				p.Date.Format("2006-01-02"), p.Commodity.Name(), p.Target.Name(),
+				p.Price, priceLocation(p), decimal.NewFromInt(1).Div(prev.Price).Truncate(8), priceLocation(prev))
+		}
+	}
+	return nil
+}
+
+func priceLocation(p *model.Price) string {
+	if p.Src == nil {
+		return "unknown location"
+	}
+	return p.Src.Position()
+}
+
+// strictPrices computes prices like ComputePrices, but fails if a commodity
+// is held in an asset or liability account while its most recent price is
+// older than maxAge days.
+func strictPrices(j *Builder, v *model.Commodity, maxAge int, via []*model.Commodity) *Processor {
+	proc := normalizePrices(j, v, via)
+	var (
+		computePrice = proc.Price
+		computeEnd   = proc.DayEnd
+		updated      = make(map[*model.Commodity]time.Time)
+		holdings     = make(map[*model.Commodity]decimal.Decimal)
+	)
+	proc.Price = func(p *model.Price) error {
+		updated[p.Commodity] = p.Date
+		updated[p.Target] = p.Date
+		if computePrice != nil {
+			return computePrice(p)
+		}
+		return nil
+	}
+	proc.Posting = func(_ *model.Transaction, p *model.Posting) error {
+		if p.Account.IsAL() {
+			holdings[p.Commodity] = holdings[p.Commodity].Add(p.Quantity)
+		}
+		return nil
+	}
+	proc.DayEnd = func(d *Day) error {
+		if err := computeEnd(d); err != nil {
+			return err
+		}
+		for _, c := range dict.SortedKeys(holdings, commodity.Compare) {
+			if c == v || holdings[c].IsZero() {
+				continue
+			}
+			last, ok := updated[c]
+			if !ok {
+				continue
+			}
+			if age := int(d.Date.Sub(last).Hours() / 24); age > maxAge {
+				return fmt.Errorf("%s: price of %s is %d days old (last price on %s), maximum is %d days", d.Date.Format("2006-01-02"), c.Name(), age, last.Format("2006-01-02"), maxAge)
+			}
+		}
+		return nil
+	}
+	return proc
+}
+
+type pricePair struct {
+	commodity, target *model.Commodity
+}
+
+func comparePricePairs(p1, p2 pricePair) compare.Order {
+	if o := commodity.Compare(p1.commodity, p2.commodity); o != compare.Equal {
+		return o
+	}
+	return commodity.Compare(p1.target, p2.target)
+}
+
+type pricePoint struct {
+	date  time.Time
+	price decimal.Decimal
+}
+
+// interpolatePrices computes prices by linearly interpolating between the
+// price directives surrounding each day. Prices after the last price
+// directive of a commodity are carried forward.
+func interpolatePrices(j *Builder, v *model.Commodity, via []*model.Commodity) *Processor {
+	series := make(map[pricePair][]pricePoint)
+	for _, d := range j.days {
+		for _, p := range d.Prices {
+			pair := pricePair{p.Commodity, p.Target}
+			series[pair] = append(series[pair], pricePoint{p.Date, p.Price})
+		}
+	}
+	for _, points := range series {
+		slices.SortStableFunc(points, func(p1, p2 pricePoint) int {
+			return compare.Time(p1.date, p2.date)
+		})
+	}
+	// Interpolated prices are inserted in a fixed order, as a price
+	// overrides the inverse price of an earlier one.
+	pairs := dict.SortedKeys(series, comparePricePairs)
+	var (
+		previous price.NormalizedPrices
+		prc      = make(price.Prices)
+		pos      = make(map[pricePair]int)
+	)
+	return &Processor{
+		Price: func(p *model.Price) error {
+			return prc.Insert(p.Commodity, p.Price, p.Target)
+		},
+		DayEnd: func(d *Day) error {
+			changed := len(d.Prices) > 0
+			for _, pair := range pairs {
+				points := series[pair]
+				i := pos[pair]
+				for i < len(points) && !points[i].date.After(d.Date) {
+					i++
+				}
+				pos[pair] = i
+				if i == 0 || i == len(points) || points[i-1].date.Equal(d.Date) {
+					continue
+				}
+				p := interpolate(points[i-1], points[i], d.Date)
+				if err := prc.Insert(pair.commodity, p, pair.target); err != nil {
+					return err
+				}
+				changed = true
+			}
+			if changed {
+				previous = prc.Normalize(v, via...)
+			}
+			d.Normalized = previous
+			return nil
+		},
+	}
+}
+
+func interpolate(p0, p1 pricePoint, t time.Time) decimal.Decimal {
+	elapsed := decimal.NewFromFloat(t.Sub(p0.date).Hours())
+	total := decimal.NewFromFloat(p1.date.Sub(p0.date).Hours())
+	return p0.price.Add(price.Multiply(p1.price.Sub(p0.price), elapsed.Div(total)))
+}
+
+// PriceStep is a step of the chain of prices used to valuate a commodity.
+type PriceStep struct {
+	price.Step
+
+	// Source is the most recent price directive for the commodity pair of
+	// the step. It may quote the price of Target in Commodity, in which case
+	// the step uses the inverse price.
+	Source *model.Price
+
+	// Next is the following price directive if the price is interpolated
+	// between Source and Next, and nil otherwise.
+	Next *model.Price
+}
+
+// ExplainPrice returns the chain of prices which values c in v at the end of
+// the given date. Like ComputePricesWithPolicy, it uses the most recent price
+// of each commodity pair, or interpolates between the surrounding prices with
+// PriceInterpolate.
+func ExplainPrice(j *Journal, v, c *model.Commodity, date time.Time, policy PricePolicy) ([]PriceStep, error) {
+	var (
+		prc    = make(price.Prices)
+		latest = make(map[pricePair]*model.Price)
+		last   = make(map[pricePair]*model.Price)
+		next   = make(map[pricePair]*model.Price)
+	)
+	for _, d := range j.Days {
+		for _, p := range d.Prices {
+			pair := pricePair{p.Commodity, p.Target}
+			if d.Date.After(date) {
+				if _, ok := next[pair]; !ok {
+					next[pair] = p
+				}
+				continue
+			}
+			if err := prc.Insert(p.Commodity, p.Price, p.Target); err != nil {
+				return nil, err
+			}
+			last[pair] = p
+			latest[pair] = p
+			latest[pricePair{p.Target, p.Commodity}] = p
+		}
+	}
+	following := make(map[pricePair]*model.Price)
+	if policy.Mode == PriceInterpolate {
+		for _, pair := range dict.SortedKeys(next, comparePricePairs) {
+			p0, p1 := last[pair], next[pair]
+			if p0 == nil || p0.Date.Equal(date) {
+				continue
+			}
+			p := interpolate(pricePoint{p0.Date, p0.Price}, pricePoint{p1.Date, p1.Price}, date)
+			if err := prc.Insert(pair.commodity, p, pair.target); err != nil {
+				return nil, err
+			}
+			for _, pp := range []pricePair{pair, {pair.target, pair.commodity}} {
+				latest[pp], following[pp] = p0, p1
+			}
+		}
+	}
+	steps, err := prc.Path(v, c, policy.Via...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", date.Format("2006-01-02"), err)
+	}
+	var res []PriceStep
+	for _, s := range steps {
+		pair := pricePair{s.Commodity, s.Target}
+		res = append(res, PriceStep{Step: s, Source: latest[pair], Next: following[pair]})
+	}
+	return res, nil
+}
@@ -0,0 +1,352 @@
+package journal
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/shopspring/decimal"
+)
+
+func TestComputePricesWithPolicy(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	usd := reg.Commodities().MustGet("USD")
+	assets := reg.Accounts().MustGet("Assets:Acc")
+	equity := reg.Accounts().MustGet("Equity:Equity")
+
+	build := func() *Builder {
+		b := New()
+		for _, p := range []*model.Price{
+			{Date: date.Date(2021, 1, 1), Commodity: usd, Target: chf, Price: decimal.NewFromInt(1)},
+			{Date: date.Date(2021, 1, 11), Commodity: usd, Target: chf, Price: decimal.NewFromInt(2)},
+		} {
+			b.Add(p)
+		}
+		for _, d := range []int{1, 6, 21} {
+			b.Add(transaction.Builder{
+				Date: date.Date(2021, 1, d),
+				Postings: posting.Builder{
+					Credit:    equity,
+					Debit:     assets,
+					Commodity: usd,
+					Quantity:  decimal.NewFromInt(1),
+				}.Build(),
+			}.Build())
+		}
+		return b
+	}
+
+	tests := []struct {
+		policy  PricePolicy
+		want    []string
+		wantErr bool
+	}{
+		{
+			policy: PricePolicy{Mode: PriceLast},
+			want:   []string{"1", "1", "2", "2"},
+		},
+		{
+			policy: PricePolicy{Mode: PriceInterpolate},
+			want:   []string{"1", "1.5", "2", "2"},
+		},
+		{
+			policy: PricePolicy{Mode: PriceStrict, MaxAge: 10},
+			want:   []string{"1", "1", "2", "2"},
+		},
+		{
+			policy:  PricePolicy{Mode: PriceStrict, MaxAge: 9},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.Mode.String(), func(t *testing.T) {
+			b := build()
+			var got []string
+			err := b.Build().Process(
+				ComputePricesWithPolicy(b, chf, test.policy),
+				&Processor{
+					DayEnd: func(d *Day) error {
+						got = append(got, d.Normalized[usd].String())
+						return nil
+					},
+				},
+			)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Process() returned error %v, want error: %t", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Process() computed unexpected prices (-want/+got)\n%s\n", diff)
+			}
+		})
+	}
+}
+
+// syntheticJournal creates a journal with daily prices on weekdays for the
+// given number of years. Stocks are quoted in USD or EUR, which are quoted in
+// CHF, and a transaction on each Saturday creates days without prices.
+func syntheticJournal(reg *model.Registry, years int) *Builder {
+	var (
+		rnd    = rand.New(rand.NewSource(1))
+		chf    = reg.Commodities().MustGet("CHF")
+		usd    = reg.Commodities().MustGet("USD")
+		eur    = reg.Commodities().MustGet("EUR")
+		assets = reg.Accounts().MustGet("Assets:Acc")
+		equity = reg.Accounts().MustGet("Equity:Equity")
+		stocks []*commodity.Commodity
+		b      = New()
+	)
+	for i := 0; i < 10; i++ {
+		stocks = append(stocks, reg.Commodities().MustGet(fmt.Sprintf("STOCK%d", i)))
+	}
+	randomPrice := func() decimal.Decimal {
+		return decimal.NewFromInt(1 + rnd.Int63n(10000)).Shift(-2)
+	}
+	start := date.Date(2000, 1, 1)
+	for d := start; d.Before(start.AddDate(years, 0, 0)); d = d.AddDate(0, 0, 1) {
+		switch d.Weekday() {
+		case time.Saturday:
+			b.Add(transaction.Builder{
+				Date: d,
+				Postings: posting.Builder{
+					Credit:    equity,
+					Debit:     assets,
+					Commodity: stocks[rnd.Intn(len(stocks))],
+					Quantity:  decimal.NewFromInt(1),
+				}.Build(),
+			}.Build())
+		case time.Sunday:
+		default:
+			b.Add(&model.Price{Date: d, Commodity: usd, Target: chf, Price: randomPrice()})
+			b.Add(&model.Price{Date: d, Commodity: eur, Target: chf, Price: randomPrice()})
+			for i, s := range stocks {
+				target := usd
+				if i%2 == 1 {
+					target = eur
+				}
+				b.Add(&model.Price{Date: d, Commodity: s, Target: target, Price: randomPrice()})
+			}
+		}
+	}
+	return b
+}
+
+func TestComputePricesConcurrently(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	b := syntheticJournal(reg, 2)
+	collect := func(proc *Processor) []map[string]string {
+		var res []map[string]string
+		err := b.Build().Process(proc, &Processor{
+			DayEnd: func(d *Day) error {
+				m := make(map[string]string)
+				for c, p := range d.Normalized {
+					m[c.Name()] = p.String()
+				}
+				res = append(res, m)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Process() returned unexpected error: %v", err)
+		}
+		return res
+	}
+
+	want := collect(ComputePrices(chf))
+	got := collect(ComputePricesWithPolicy(b, chf, PricePolicy{Mode: PriceLast}))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ComputePricesWithPolicy() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func BenchmarkComputePrices(b *testing.B) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	j := syntheticJournal(reg, 20)
+	days := j.Build()
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := days.Process(ComputePrices(chf)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := days.Process(ComputePricesWithPolicy(j, chf, PricePolicy{Mode: PriceLast})); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestComputePricesConflicts(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	usd := reg.Commodities().MustGet("USD")
+
+	build := func(p2 *model.Price) *Builder {
+		b := New()
+		for _, p := range []*model.Price{
+			{Date: date.Date(2021, 1, 1), Commodity: usd, Target: chf, Price: decimal.NewFromInt(2)},
+			p2,
+			{Date: date.Date(2021, 1, 2), Commodity: usd, Target: chf, Price: decimal.NewFromInt(3)},
+		} {
+			b.Add(p)
+		}
+		return b
+	}
+	price := func(p string) *model.Price {
+		return &model.Price{Date: date.Date(2021, 1, 1), Commodity: usd, Target: chf, Price: decimal.RequireFromString(p)}
+	}
+	inverse := func(p string) *model.Price {
+		return &model.Price{Date: date.Date(2021, 1, 1), Commodity: chf, Target: usd, Price: decimal.RequireFromString(p)}
+	}
+
+	tests := []struct {
+		desc         string
+		price        *model.Price
+		mode         PriceMode
+		wantWarnings int
+		wantErr      bool
+	}{
+		{desc: "agreeing", price: price("2"), mode: PriceLast},
+		{desc: "conflicting", price: price("3"), mode: PriceLast, wantWarnings: 1},
+		{desc: "conflicting interpolated", price: price("3"), mode: PriceInterpolate, wantWarnings: 1},
+		{desc: "conflicting strict", price: price("3"), mode: PriceStrict, wantErr: true},
+		{desc: "agreeing inverse", price: inverse("0.5"), mode: PriceLast},
+		{desc: "inverse within tolerance", price: inverse("0.502"), mode: PriceLast},
+		{desc: "conflicting inverse", price: inverse("0.25"), mode: PriceLast, wantWarnings: 1},
+		{desc: "conflicting inverse strict", price: inverse("0.25"), mode: PriceStrict, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			b := build(test.price)
+			var warnings []error
+			policy := PricePolicy{
+				Mode:   test.mode,
+				MaxAge: 10,
+				Warn:   func(err error) { warnings = append(warnings, err) },
+			}
+
+			err := b.Build().Process(ComputePricesWithPolicy(b, chf, policy))
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Process() returned error %v, want error: %t", err, test.wantErr)
+			}
+			if len(warnings) != test.wantWarnings {
+				t.Errorf("Process() emitted warnings %v, want %d", warnings, test.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestExplainPrice(t *testing.T) {
+	reg := registry.New()
+	btc := reg.Commodities().MustGet("BTC")
+	chf := reg.Commodities().MustGet("CHF")
+	usd := reg.Commodities().MustGet("USD")
+	b := New()
+	for _, p := range []*model.Price{
+		{Date: date.Date(2021, 1, 1), Commodity: usd, Target: chf, Price: decimal.NewFromInt(2)},
+		{Date: date.Date(2021, 1, 1), Commodity: btc, Target: chf, Price: decimal.NewFromInt(100)},
+		{Date: date.Date(2021, 1, 2), Commodity: btc, Target: chf, Price: decimal.NewFromInt(120)},
+	} {
+		b.Add(p)
+	}
+
+	steps, err := ExplainPrice(b.Build(), usd, btc, date.Date(2021, 1, 1), PricePolicy{})
+
+	if err != nil {
+		t.Fatalf("ExplainPrice() returned unexpected error %v", err)
+	}
+	var got []string
+	for _, s := range steps {
+		got = append(got, fmt.Sprintf("%s %s %s from %s %s", s.Commodity.Name(), s.Price, s.Target.Name(), s.Source.Date.Format("2006-01-02"), s.Source.Commodity.Name()))
+	}
+	want := []string{"BTC 100 CHF from 2021-01-01 BTC", "CHF 0.5 USD from 2021-01-01 USD"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ExplainPrice() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if _, err := ExplainPrice(b.Build(), usd, btc, date.Date(2020, 12, 31), PricePolicy{}); err == nil {
+		t.Errorf("ExplainPrice() before the first price returned no error, want an error")
+	}
+}
+
+func TestExplainPriceInterpolate(t *testing.T) {
+	reg := registry.New()
+	btc := reg.Commodities().MustGet("BTC")
+	chf := reg.Commodities().MustGet("CHF")
+	b := New()
+	for _, p := range []*model.Price{
+		{Date: date.Date(2021, 1, 1), Commodity: btc, Target: chf, Price: decimal.NewFromInt(100)},
+		{Date: date.Date(2021, 1, 3), Commodity: btc, Target: chf, Price: decimal.NewFromInt(120)},
+	} {
+		b.Add(p)
+	}
+	j := b.Build()
+
+	for _, test := range []struct {
+		desc   string
+		policy PricePolicy
+		date   time.Time
+		want   string
+	}{
+		{
+			desc: "last price",
+			date: date.Date(2021, 1, 2),
+			want: "BTC 100 CHF from 2021-01-01",
+		},
+		{
+			desc:   "interpolated price",
+			policy: PricePolicy{Mode: PriceInterpolate},
+			date:   date.Date(2021, 1, 2),
+			want:   "BTC 110 CHF from 2021-01-01 to 2021-01-03",
+		},
+		{
+			desc:   "price on the date of a directive",
+			policy: PricePolicy{Mode: PriceInterpolate},
+			date:   date.Date(2021, 1, 3),
+			want:   "BTC 120 CHF from 2021-01-03",
+		},
+		{
+			desc:   "price after the last directive",
+			policy: PricePolicy{Mode: PriceInterpolate},
+			date:   date.Date(2021, 1, 4),
+			want:   "BTC 120 CHF from 2021-01-03",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			steps, err := ExplainPrice(j, chf, btc, test.date, test.policy)
+
+			if err != nil {
+				t.Fatalf("ExplainPrice() returned unexpected error %v", err)
+			}
+			if len(steps) != 1 {
+				t.Fatalf("ExplainPrice() returned %d steps, want 1", len(steps))
+			}
+			s := steps[0]
+			got := fmt.Sprintf("%s %s %s from %s", s.Commodity.Name(), s.Price, s.Target.Name(), s.Source.Date.Format("2006-01-02"))
+			if s.Next != nil {
+				got += " to " + s.Next.Date.Format("2006-01-02")
+			}
+			if got != test.want {
+				t.Errorf("ExplainPrice() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
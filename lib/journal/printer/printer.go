@@ -18,9 +18,12 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/shopspring/decimal"
 )
 
 // Printer prints directives.
@@ -50,10 +53,16 @@ func (p *Printer) PrintDirective(directive model.Directive) (n int, err error) {
 		return p.printOpen(d)
 	case *model.Close:
 		return p.printClose(d)
+	case *model.Note:
+		return p.printNote(d)
+	case *model.Document:
+		return p.printDocument(d)
 	case *model.Assertion:
 		return p.printAssertion(d)
 	case *model.Price:
 		return p.printPrice(d)
+	case *model.Value:
+		return p.printValue(d)
 	}
 	return 0, fmt.Errorf("unknown directive: %v", directive)
 }
@@ -75,18 +84,31 @@ func (p *Printer) printTransaction(t *model.Transaction) (n int, err error) {
 		for _, t := range t.Targets {
 			s = append(s, t.Name())
 		}
+		for _, rx := range t.Patterns {
+			s = append(s, "/"+rx.String()+"/")
+		}
 		if _, err := fmt.Fprintf(p, "@performance(%s)\n", strings.Join(s, ",")); err != nil {
 			return p.count - start, err
 		}
 	}
-	if _, err := fmt.Fprintf(p, "%s \"%s\"", t.Date.Format("2006-01-02"), t.Description); err != nil {
+	if _, err := fmt.Fprintf(p, "%s \"%s\"", t.Date.Format("2006-01-02"), syntax.Escape(t.Description)); err != nil {
 		return p.count - start, err
 	}
+	if t.ID != "" {
+		if _, err := fmt.Fprintf(p, " id:%s", t.ID); err != nil {
+			return p.count - start, err
+		}
+	}
+	for _, l := range t.Links {
+		if _, err := fmt.Fprintf(p, " ^%s", l); err != nil {
+			return p.count - start, err
+		}
+	}
 	if _, err := io.WriteString(p, "\n"); err != nil {
 		return p.count - start, err
 	}
 	for i, po := range t.Postings {
-		if i%2 == 0 {
+		if !po.Virtual && i%2 == 0 {
 			continue
 		}
 		if _, err := p.printPosting(po); err != nil {
@@ -100,33 +122,73 @@ func (p *Printer) printTransaction(t *model.Transaction) (n int, err error) {
 }
 
 func (p *Printer) printPosting(t *model.Posting) (int, error) {
-	return fmt.Fprintf(p, "%-*s %-*s %10s %s", p.padding, t.Other.String(), p.padding, t.Account.String(), t.Quantity.String(), t.Commodity.Name())
+	var (
+		n   int
+		err error
+	)
+	if t.Virtual {
+		n, err = fmt.Fprintf(p, "%-*s %10s %s", 2*p.padding+1, "("+t.Account.String()+")", t.Quantity.String(), t.Commodity.Name())
+	} else {
+		n, err = fmt.Fprintf(p, "%-*s %-*s %10s %s", p.padding, t.Other.String(), p.padding, t.Account.String(), t.Quantity.String(), t.Commodity.Name())
+	}
+	if err != nil {
+		return n, err
+	}
+	if t.CostCommodity != nil {
+		m, err := p.printLot(t.Cost, t.CostCommodity, t.LotDate)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	if t.Comment == "" {
+		return n, nil
+	}
+	m, err := fmt.Fprintf(p, " ; %s", t.Comment)
+	return n + m, err
 }
 
 func (p *Printer) printOpen(o *model.Open) (int, error) {
-	return fmt.Fprintf(p, "%s open %s", o.Date.Format("2006-01-02"), o.Account)
+	n, err := fmt.Fprintf(p, "%s open %s", o.Date.Format("2006-01-02"), o.Account)
+	if err != nil || o.Valuation == nil {
+		return n, err
+	}
+	m, err := fmt.Fprintf(p, " valuation %s", o.Valuation)
+	return n + m, err
 }
 
 func (p *Printer) printClose(c *model.Close) (int, error) {
 	return fmt.Fprintf(p, "%s close %s", c.Date.Format("2006-01-02"), c.Account)
 }
 
+func (p *Printer) printNote(n *model.Note) (int, error) {
+	return fmt.Fprintf(p, `%s note %s "%s"`, n.Date.Format("2006-01-02"), n.Account, syntax.Escape(n.Text))
+}
+
+func (p *Printer) printDocument(d *model.Document) (int, error) {
+	return fmt.Fprintf(p, `%s document %s "%s"`, d.Date.Format("2006-01-02"), d.Account, syntax.Escape(d.Path))
+}
+
 func (p *Printer) printPrice(pr *model.Price) (int, error) {
 	return fmt.Fprintf(p, "%s price %s %s %s", pr.Date.Format("2006-01-02"), pr.Commodity.Name(), pr.Price, pr.Target.Name())
 }
 
+func (p *Printer) printValue(v *model.Value) (int, error) {
+	return fmt.Fprintf(p, "%s value %s %s %s", v.Date.Format("2006-01-02"), v.Account.Name(), v.Quantity, v.Commodity.Name())
+}
+
 func (p *Printer) printAssertion(a *model.Assertion) (int, error) {
 	start := p.count
 	if _, err := fmt.Fprintf(p, "%s balance", a.Date.Format("2006-01-02")); err != nil {
 		return p.count - start, err
 	}
 	if len(a.Balances) == 1 {
-		if _, err := fmt.Fprintf(p, " %s %s %s", a.Balances[0].Account, a.Balances[0].Quantity, a.Balances[0].Commodity.Name()); err != nil {
+		if err := p.printBalance(" ", a.Balances[0]); err != nil {
 			return p.count - start, err
 		}
 	} else {
 		for _, bal := range a.Balances {
-			if _, err := fmt.Fprintf(p, "\n%s %s %s", bal.Account, bal.Quantity, bal.Commodity.Name()); err != nil {
+			if err := p.printBalance("\n", bal); err != nil {
 				return p.count - start, err
 			}
 		}
@@ -134,6 +196,31 @@ func (p *Printer) printAssertion(a *model.Assertion) (int, error) {
 	return p.count - start, nil
 }
 
+func (p *Printer) printBalance(sep string, bal model.Balance) error {
+	var subtree, tolerance string
+	if bal.Subtree {
+		subtree = ":*"
+	}
+	if !bal.Tolerance.IsZero() {
+		tolerance = fmt.Sprintf(" ~ %s", bal.Tolerance)
+	}
+	if _, err := fmt.Fprintf(p, "%s%s%s %s%s %s", sep, bal.Account, subtree, bal.Quantity, tolerance, bal.Commodity.Name()); err != nil {
+		return err
+	}
+	if !bal.IsLot() {
+		return nil
+	}
+	_, err := p.printLot(bal.Cost, bal.CostCommodity, bal.LotDate)
+	return err
+}
+
+func (p *Printer) printLot(cost decimal.Decimal, c *model.Commodity, date time.Time) (int, error) {
+	if date.IsZero() {
+		return fmt.Fprintf(p, " {%s %s}", cost, c.Name())
+	}
+	return fmt.Fprintf(p, " {%s %s, %s}", cost, c.Name(), date.Format("2006-01-02"))
+}
+
 // Initialize initializes the padding of this printer.
 func (p *Printer) Initialize(directive []model.Directive) {
 	for _, d := range directive {
@@ -2,12 +2,15 @@ package journal
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/sboehler/knut/lib/amounts"
 	"github.com/sboehler/knut/lib/common/compare"
 	"github.com/sboehler/knut/lib/common/date"
 	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/regex"
+	"github.com/sboehler/knut/lib/common/rounding"
 	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/posting"
@@ -16,8 +19,9 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// ComputePrices updates prices.
-func ComputePrices(v *model.Commodity) *Processor {
+// ComputePrices updates prices. Prices are triangulated through the
+// commodities in via if possible.
+func ComputePrices(v *model.Commodity, via ...*model.Commodity) *Processor {
 	if v == nil {
 		return nil
 	}
@@ -29,7 +33,7 @@ func ComputePrices(v *model.Commodity) *Processor {
 		},
 		DayEnd: func(d *Day) error {
 			if len(d.Prices) > 0 {
-				previous = prc.Normalize(v)
+				previous = prc.Normalize(v, via...)
 			}
 			d.Normalized = previous
 			return nil
@@ -37,8 +41,14 @@ func ComputePrices(v *model.Commodity) *Processor {
 	}
 }
 
-// Balance balances the journal.
+// Valuate valuates the postings in the given commodity and adds transactions
+// for the gains and losses of positions. Values are truncated.
 func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
+	return ValuateWithRounding(reg, valuation, rounding.Truncate)
+}
+
+// ValuateWithRounding is like Valuate, rounding values with the given mode.
+func ValuateWithRounding(reg *model.Registry, valuation *model.Commodity, mode rounding.Mode) *Processor {
 	if valuation == nil {
 		return nil
 	}
@@ -73,7 +83,7 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 				if delta.IsZero() {
 					continue
 				}
-				gain := price.Multiply(delta, qty)
+				gain := price.Convert(qty, delta, mode)
 				credit := reg.Accounts().ValuationAccountFor(pos.Account)
 				d.Transactions = append(d.Transactions, transaction.Builder{
 					Date:        d.Date,
@@ -90,19 +100,22 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 			return nil
 		},
 
-		Posting: func(_ *model.Transaction, p *model.Posting) error {
+		Posting: func(t *model.Transaction, p *model.Posting) error {
 			if p.Quantity.IsZero() {
 				return nil
 			}
-			if p.Account.IsAL() {
+			if p.Account.IsAL() && !p.Virtual {
 				quantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
 			}
 			if valuation == p.Commodity {
 				p.Value = p.Quantity
 				return nil
 			}
-			v, err := prices.Valuate(p.Commodity, p.Quantity)
+			v, err := prices.Valuate(p.Commodity, p.Quantity, mode)
 			if err != nil {
+				if src, ok := model.Source(t); ok {
+					return fmt.Errorf("%s: %w", src.Position(), err)
+				}
 				return err
 			}
 			p.Value = v
@@ -116,6 +129,81 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 	}
 }
 
+// ApplyValues books the difference between the balance declared by a value
+// directive and the actual balance of the account against the valuation
+// account of the account.
+func ApplyValues(reg *model.Registry) *Processor {
+	quantities := make(amounts.Amounts)
+	return &Processor{
+		Posting: func(t *model.Transaction, p *model.Posting) error {
+			if p.Account.IsAL() && !p.Virtual {
+				quantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
+			}
+			return nil
+		},
+		DayEnd: func(d *Day) error {
+			for _, v := range d.Values {
+				k := amounts.AccountCommodityKey(v.Account, v.Commodity)
+				delta := v.Quantity.Sub(quantities[k])
+				if delta.IsZero() {
+					continue
+				}
+				quantities.Add(k, delta)
+				d.Transactions = append(d.Transactions, transaction.Builder{
+					Date:        d.Date,
+					Description: fmt.Sprintf("Adjust value of account %s to %s %s", v.Account.Name(), v.Quantity, v.Commodity.Name()),
+					Postings: posting.Builder{
+						Credit:    reg.Accounts().ValuationAccountFor(v.Account),
+						Debit:     v.Account,
+						Commodity: v.Commodity,
+						Quantity:  delta,
+					}.Build(),
+					Value: v,
+				}.Build())
+			}
+			return nil
+		},
+	}
+}
+
+// AutoOpen synthesizes open directives for the accounts which are used
+// before they have been opened, dated on the day of their first use. Accounts
+// which have been closed are not opened again.
+func AutoOpen() *Processor {
+	seen := set.New[*model.Account]()
+	return &Processor{
+		DayStart: func(d *Day) error {
+			for _, o := range d.Openings {
+				seen.Add(o.Account)
+			}
+			open := func(a *model.Account) {
+				if seen.Has(a) {
+					return
+				}
+				seen.Add(a)
+				d.Openings = append(d.Openings, &model.Open{Date: d.Date, Account: a})
+			}
+			for _, t := range d.Transactions {
+				for _, p := range t.Postings {
+					open(p.Account)
+				}
+			}
+			for _, a := range d.Assertions {
+				for _, bal := range a.Balances {
+					open(bal.Account)
+				}
+			}
+			for _, v := range d.Values {
+				open(v.Account)
+			}
+			for _, c := range d.Closings {
+				open(c.Account)
+			}
+			return nil
+		},
+	}
+}
+
 func Filter(part date.Partition) *Processor {
 	return &Processor{
 		DayEnd: func(d *Day) error {
@@ -127,6 +215,43 @@ func Filter(part date.Partition) *Processor {
 	}
 }
 
+// FilterLinks drops the transactions which have no link matching one of the
+// regexes. If there are no regexes, no transactions are dropped.
+func FilterLinks(rxs regex.Regexes) *Processor {
+	if rxs == nil {
+		return nil
+	}
+	return &Processor{
+		DayEnd: func(d *Day) error {
+			var ts []*model.Transaction
+			for _, t := range d.Transactions {
+				if slices.ContainsFunc(t.Links, rxs.MatchString) {
+					ts = append(ts, t)
+				}
+			}
+			d.Transactions = ts
+			return nil
+		},
+	}
+}
+
+// Notes passes the notes dated up to the end of the partition to f, with
+// their accounts mapped by m.
+func Notes(part date.Partition, m mapper.Mapper[*model.Account], f func(*model.Account, string)) *Processor {
+	ends := part.EndDates()
+	return &Processor{
+		Note: func(n *model.Note) error {
+			if len(ends) == 0 || n.Date.After(ends[len(ends)-1]) {
+				return nil
+			}
+			if a := m(n.Account); a != nil {
+				f(a, n.Text)
+			}
+			return nil
+		},
+	}
+}
+
 // Balance balances the journal.
 func CloseAccounts(j *Builder, reg *model.Registry, enable bool, partition date.Partition) *Processor {
 	if !enable {
@@ -137,11 +262,32 @@ func CloseAccounts(j *Builder, reg *model.Registry, enable bool, partition date.
 
 	quantities, values := make(amounts.Amounts), make(amounts.Amounts)
 
+	// Virtual postings are closed by virtual postings, as they have no
+	// counterpart in equity.
+	virtualQuantities, virtualValues := make(amounts.Amounts), make(amounts.Amounts)
+
 	return &Processor{
 		DayStart: func(d *Day) error {
 			if !closingDays.Has(d) {
 				return nil
 			}
+			for k, quantity := range virtualQuantities {
+				if quantity.IsZero() && virtualValues[k].IsZero() {
+					continue
+				}
+				d.Transactions = append(d.Transactions, transaction.Builder{
+					Date:        d.Date,
+					Description: fmt.Sprintf("Closing virtual account %s in %s", k.Account.Name(), k.Commodity.Name()),
+					Postings: []*model.Posting{{
+						Account:   k.Account,
+						Other:     k.Account,
+						Commodity: k.Commodity,
+						Quantity:  quantity.Neg(),
+						Value:     virtualValues[k].Neg(),
+						Virtual:   true,
+					}},
+				}.Build())
+			}
 			for k, quantity := range quantities {
 				if quantity.IsZero() && values[k].IsZero() {
 					continue
@@ -167,6 +313,11 @@ func CloseAccounts(j *Builder, reg *model.Registry, enable bool, partition date.
 			if p.Account == equityAccount {
 				return nil
 			}
+			if p.Virtual {
+				virtualQuantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
+				virtualValues.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Value)
+				return nil
+			}
 			quantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
 			values.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Value)
 			return nil
@@ -188,10 +339,27 @@ type Collection interface {
 	Insert(k amounts.Key, v decimal.Decimal)
 }
 
+// Negate returns a collection which inserts the negated amounts into the
+// given collection.
+func Negate(c Collection) Collection {
+	return negated{c}
+}
+
+type negated struct {
+	c Collection
+}
+
+func (n negated) Insert(k amounts.Key, v decimal.Decimal) {
+	n.c.Insert(k, v.Neg())
+}
+
 type Query struct {
 	Select    mapper.Mapper[amounts.Key]
 	Where     predicate.Predicate[amounts.Key]
 	Valuation *model.Commodity
+
+	// Virtual includes virtual postings.
+	Virtual bool
 }
 
 func (query Query) Into(c Collection) *Processor {
@@ -203,10 +371,98 @@ func (query Query) Into(c Collection) *Processor {
 	}
 	return &Processor{
 		Posting: func(t *model.Transaction, b *model.Posting) error {
+			if b.Virtual && !query.Virtual {
+				return nil
+			}
 			amount := b.Quantity
 			if query.Valuation != nil {
 				amount = b.Value
 			}
+			key := query.key(t, b)
+			if query.Where(key) {
+				c.Insert(query.Select(key), amount)
+			}
+			return nil
+		},
+	}
+}
+
+// PostingCollection collects postings by key.
+type PostingCollection interface {
+	InsertPosting(k amounts.Key, p *model.Posting)
+}
+
+// IntoPostings inserts the postings which match the query into the
+// collection, for collections which need more of a posting than its amount,
+// such as the cost of its lot.
+func (query Query) IntoPostings(c PostingCollection) *Processor {
+	if query.Where == nil {
+		query.Where = predicate.True[amounts.Key]
+	}
+	if query.Select == nil {
+		query.Select = mapper.Identity[amounts.Key]
+	}
+	return &Processor{
+		Posting: func(t *model.Transaction, b *model.Posting) error {
+			if b.Virtual && !query.Virtual {
+				return nil
+			}
+			key := query.key(t, b)
+			if query.Where(key) {
+				c.InsertPosting(query.Select(key), b)
+			}
+			return nil
+		},
+	}
+}
+
+// key returns the key of a posting.
+func (query Query) key(t *model.Transaction, b *model.Posting) amounts.Key {
+	return amounts.Key{
+		Date:        t.Date,
+		Account:     b.Account,
+		Other:       b.Other,
+		Commodity:   b.Commodity,
+		Valuation:   query.Valuation,
+		Description: t.Description,
+		Comment:     b.Comment,
+		Virtual:     b.Virtual,
+	}
+}
+
+// IntoCost inserts the historical cost of the postings of asset and
+// liability accounts into the collection, expressed in the valuation
+// commodity of the query. Acquisitions are inserted at their cost, or at
+// their value if they have no cost in the valuation commodity. Reductions
+// are inserted at the average cost of the position. The postings of other
+// accounts, virtual postings and valuation gains are ignored.
+//
+// The cost of a position depends on all its postings, so the processor must
+// run before the journal is filtered. Query.Where can restrict the dates
+// instead.
+func (query Query) IntoCost(c Collection) *Processor {
+	if query.Valuation == nil {
+		return nil
+	}
+	if query.Where == nil {
+		query.Where = predicate.True[amounts.Key]
+	}
+	if query.Select == nil {
+		query.Select = mapper.Identity[amounts.Key]
+	}
+	positions := make(map[amounts.Key]*position)
+	return &Processor{
+		Posting: func(t *model.Transaction, b *model.Posting) error {
+			if b.Virtual || !b.Account.IsAL() || b.Quantity.IsZero() {
+				return nil
+			}
+			k := amounts.AccountCommodityKey(b.Account, b.Commodity)
+			pos, ok := positions[k]
+			if !ok {
+				pos = new(position)
+				positions[k] = pos
+			}
+			cost := pos.book(b, query.Valuation)
 			key := amounts.Key{
 				Date:        t.Date,
 				Account:     b.Account,
@@ -214,11 +470,48 @@ func (query Query) Into(c Collection) *Processor {
 				Commodity:   b.Commodity,
 				Valuation:   query.Valuation,
 				Description: t.Description,
+				Comment:     b.Comment,
 			}
 			if query.Where(key) {
-				c.Insert(query.Select(key), amount)
+				c.Insert(query.Select(key), cost)
 			}
 			return nil
 		},
 	}
 }
+
+// position is the quantity of a commodity in an account and its total cost.
+type position struct {
+	quantity, cost decimal.Decimal
+}
+
+// book books the posting and returns the change of the cost of the
+// position.
+func (pos *position) book(p *model.Posting, valuation *model.Commodity) decimal.Decimal {
+	qty, value := p.Quantity, p.Value
+	if p.CostCommodity == valuation && !p.Cost.IsZero() {
+		value = qty.Mul(p.Cost)
+	}
+	var cost decimal.Decimal
+	if !pos.quantity.IsZero() && pos.quantity.IsPositive() != qty.IsPositive() {
+		// The posting reduces the position at its average cost. Whatever
+		// exceeds the position opens a position on the other side.
+		reduced := qty
+		if qty.Abs().GreaterThanOrEqual(pos.quantity.Abs()) {
+			reduced = pos.quantity.Neg()
+			cost = pos.cost.Neg()
+		} else {
+			cost = pos.cost.Mul(reduced).Div(pos.quantity)
+		}
+		value = value.Mul(qty.Sub(reduced)).Div(qty)
+		pos.quantity = pos.quantity.Add(reduced)
+		pos.cost = pos.cost.Add(cost)
+		qty = qty.Sub(reduced)
+	}
+	if !qty.IsZero() {
+		pos.quantity = pos.quantity.Add(qty)
+		pos.cost = pos.cost.Add(value)
+		cost = cost.Add(value)
+	}
+	return cost
+}
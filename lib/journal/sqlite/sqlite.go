@@ -0,0 +1,334 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+)
+
+// Schema is the schema of an exported journal. Dates are stored as text in
+// the format YYYY-MM-DD, and amounts as numbers.
+const Schema = `
+CREATE TABLE commodities (
+	id          INTEGER PRIMARY KEY,
+	name        TEXT NOT NULL UNIQUE,
+	is_currency INTEGER NOT NULL
+);
+
+CREATE TABLE accounts (
+	id         INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL UNIQUE,
+	type       TEXT NOT NULL,
+	parent_id  INTEGER REFERENCES accounts(id),
+	open_date  TEXT,
+	close_date TEXT
+);
+
+CREATE TABLE prices (
+	id           INTEGER PRIMARY KEY,
+	date         TEXT NOT NULL,
+	commodity_id INTEGER NOT NULL REFERENCES commodities(id),
+	target_id    INTEGER NOT NULL REFERENCES commodities(id),
+	price        NUMERIC NOT NULL
+);
+
+CREATE TABLE transactions (
+	id          INTEGER PRIMARY KEY,
+	date        TEXT NOT NULL,
+	description TEXT NOT NULL,
+	uid         TEXT,
+	path        TEXT,
+	line        INTEGER
+);
+
+CREATE TABLE postings (
+	id                INTEGER PRIMARY KEY,
+	transaction_id    INTEGER NOT NULL REFERENCES transactions(id),
+	account_id        INTEGER NOT NULL REFERENCES accounts(id),
+	other_account_id  INTEGER NOT NULL REFERENCES accounts(id),
+	commodity_id      INTEGER NOT NULL REFERENCES commodities(id),
+	quantity          NUMERIC NOT NULL,
+	value             NUMERIC,
+	cost              NUMERIC,
+	cost_commodity_id INTEGER REFERENCES commodities(id),
+	lot_date          TEXT,
+	is_virtual        INTEGER NOT NULL,
+	comment           TEXT
+);
+
+CREATE TABLE links (
+	transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+	link           TEXT NOT NULL
+);
+
+CREATE INDEX transactions_date ON transactions(date);
+CREATE INDEX postings_transaction ON postings(transaction_id);
+CREATE INDEX postings_account ON postings(account_id);
+CREATE INDEX postings_commodity ON postings(commodity_id);
+CREATE INDEX links_link ON links(link);
+`
+
+// Exporter writes a journal into a database.
+type Exporter struct {
+	reg      *model.Registry
+	valuated bool
+
+	accounts    map[*model.Account]int64
+	commodities map[*model.Commodity]int64
+	lines       map[string][]int
+
+	insertCommodity, insertAccount, openAccount, closeAccount,
+	insertPrice, insertTransaction, insertLink, insertPosting *sql.Stmt
+}
+
+// New creates the schema in the given database transaction and returns an
+// exporter which writes into it. If valuated is true, the values of the
+// postings are exported as well.
+func New(tx *sql.Tx, reg *model.Registry, valuated bool) (*Exporter, error) {
+	if _, err := tx.Exec(Schema); err != nil {
+		return nil, err
+	}
+	e := &Exporter{
+		reg:         reg,
+		valuated:    valuated,
+		accounts:    make(map[*model.Account]int64),
+		commodities: make(map[*model.Commodity]int64),
+		lines:       make(map[string][]int),
+	}
+	for _, s := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&e.insertCommodity, `INSERT INTO commodities (name, is_currency) VALUES (?, ?)`},
+		{&e.insertAccount, `INSERT INTO accounts (name, type, parent_id) VALUES (?, ?, ?)`},
+		{&e.openAccount, `UPDATE accounts SET open_date = ? WHERE id = ?`},
+		{&e.closeAccount, `UPDATE accounts SET close_date = ? WHERE id = ?`},
+		{&e.insertPrice, `INSERT INTO prices (date, commodity_id, target_id, price) VALUES (?, ?, ?, ?)`},
+		{&e.insertTransaction, `INSERT INTO transactions (date, description, uid, path, line) VALUES (?, ?, ?, ?, ?)`},
+		{&e.insertLink, `INSERT INTO links (transaction_id, link) VALUES (?, ?)`},
+		{&e.insertPosting, `INSERT INTO postings (transaction_id, account_id, other_account_id, commodity_id, quantity, value, cost, cost_commodity_id, lot_date, is_virtual, comment) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+	} {
+		stmt, err := tx.Prepare(s.query)
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		*s.stmt = stmt
+	}
+	return e, nil
+}
+
+// Close closes the prepared statements of the exporter.
+func (e *Exporter) Close() error {
+	var res error
+	for _, stmt := range []*sql.Stmt{
+		e.insertCommodity, e.insertAccount, e.openAccount, e.closeAccount,
+		e.insertPrice, e.insertTransaction, e.insertLink, e.insertPosting,
+	} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// Processor returns a processor which exports the journal.
+func (e *Exporter) Processor() *journal.Processor {
+	return &journal.Processor{
+		Price:       e.price,
+		Open:        e.open,
+		Transaction: e.transaction,
+		Close:       e.close,
+	}
+}
+
+func (e *Exporter) price(p *model.Price) error {
+	c, err := e.commodity(p.Commodity)
+	if err != nil {
+		return err
+	}
+	t, err := e.commodity(p.Target)
+	if err != nil {
+		return err
+	}
+	_, err = e.insertPrice.Exec(formatDate(p.Date), c, t, p.Price.String())
+	return err
+}
+
+func (e *Exporter) open(o *model.Open) error {
+	a, err := e.account(o.Account)
+	if err != nil {
+		return err
+	}
+	_, err = e.openAccount.Exec(formatDate(o.Date), a)
+	return err
+}
+
+func (e *Exporter) close(c *model.Close) error {
+	a, err := e.account(c.Account)
+	if err != nil {
+		return err
+	}
+	_, err = e.closeAccount.Exec(formatDate(c.Date), a)
+	return err
+}
+
+func (e *Exporter) transaction(t *model.Transaction) error {
+	var (
+		uid, path sql.NullString
+		line      sql.NullInt64
+	)
+	if t.ID != "" {
+		uid = sql.NullString{String: t.ID, Valid: true}
+	}
+	if t.Src != nil && t.Src.Path != "" {
+		path = sql.NullString{String: t.Src.Path, Valid: true}
+		line = sql.NullInt64{Int64: int64(e.line(t.Src.Path, t.Src.Text, t.Src.Start)), Valid: true}
+	}
+	res, err := e.insertTransaction.Exec(formatDate(t.Date), t.Description, uid, path, line)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, l := range t.Links {
+		if _, err := e.insertLink.Exec(id, l); err != nil {
+			return err
+		}
+	}
+	for _, p := range t.Postings {
+		if err := e.posting(id, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) posting(trx int64, p *model.Posting) error {
+	a, err := e.account(p.Account)
+	if err != nil {
+		return err
+	}
+	o, err := e.account(p.Other)
+	if err != nil {
+		return err
+	}
+	c, err := e.commodity(p.Commodity)
+	if err != nil {
+		return err
+	}
+	var (
+		value, cost, comment sql.NullString
+		costCommodity        sql.NullInt64
+		lotDate              sql.NullString
+	)
+	if e.valuated {
+		value = number(p.Value)
+	}
+	if p.CostCommodity != nil {
+		cc, err := e.commodity(p.CostCommodity)
+		if err != nil {
+			return err
+		}
+		cost = number(p.Cost)
+		costCommodity = sql.NullInt64{Int64: cc, Valid: true}
+	}
+	if !p.LotDate.IsZero() {
+		lotDate = sql.NullString{String: formatDate(p.LotDate), Valid: true}
+	}
+	if p.Comment != "" {
+		comment = sql.NullString{String: p.Comment, Valid: true}
+	}
+	_, err = e.insertPosting.Exec(trx, a, o, c, p.Quantity.String(), value, cost, costCommodity, lotDate, p.Virtual, comment)
+	return err
+}
+
+// account returns the id of the account, inserting it and its ancestors if
+// necessary.
+func (e *Exporter) account(a *model.Account) (int64, error) {
+	if id, ok := e.accounts[a]; ok {
+		return id, nil
+	}
+	var parent sql.NullInt64
+	if segments := a.Segments(); len(segments) > 1 {
+		id, err := e.account(e.reg.Accounts().MustGetPath(segments[:len(segments)-1]))
+		if err != nil {
+			return 0, err
+		}
+		parent = sql.NullInt64{Int64: id, Valid: true}
+	}
+	res, err := e.insertAccount.Exec(a.Name(), a.Type().String(), parent)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.accounts[a] = id
+	return id, nil
+}
+
+// commodity returns the id of the commodity, inserting it if necessary.
+func (e *Exporter) commodity(c *model.Commodity) (int64, error) {
+	if id, ok := e.commodities[c]; ok {
+		return id, nil
+	}
+	res, err := e.insertCommodity.Exec(c.Name(), c.IsCurrency())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.commodities[c] = id
+	return id, nil
+}
+
+// line returns the line number of the given position in the text of a file.
+// The line offsets are computed once per file.
+func (e *Exporter) line(path, text string, pos int) int {
+	offsets, ok := e.lines[path]
+	if !ok {
+		for i, ch := range text {
+			if ch == '\n' {
+				offsets = append(offsets, i)
+			}
+		}
+		e.lines[path] = offsets
+	}
+	return sort.SearchInts(offsets, pos) + 1
+}
+
+func formatDate(d time.Time) string {
+	return d.Format("2006-01-02")
+}
+
+func number(d decimal.Decimal) sql.NullString {
+	return sql.NullString{String: d.String(), Valid: true}
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+	_ "modernc.org/sqlite"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+func TestExporter(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	usd := reg.Commodities().MustGet("USD")
+	bank := reg.Accounts().MustGet("Assets:Bank")
+	equity := reg.Accounts().MustGet("Equity:Equity")
+	b := journal.New()
+	b.Add(&model.Open{Date: date.Date(2021, 1, 1), Account: bank})
+	b.Add(&model.Open{Date: date.Date(2021, 1, 1), Account: equity})
+	b.Add(&model.Price{Date: date.Date(2021, 1, 1), Commodity: usd, Target: chf, Price: decimal.RequireFromString("0.9")})
+	b.Add(transaction.Builder{
+		Date:        date.Date(2021, 1, 2),
+		Description: "deposit",
+		ID:          "abc",
+		Links:       []string{"invoice-1"},
+		Postings: posting.Builder{
+			Credit:    equity,
+			Debit:     bank,
+			Commodity: usd,
+			Quantity:  decimal.RequireFromString("100.5"),
+		}.Build(),
+	}.Build())
+	b.Add(&model.Close{Date: date.Date(2021, 1, 3), Account: bank})
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Every connection to :memory: opens a separate database.
+	db.SetMaxOpenConns(1)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(tx, reg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Build().Process(e.Processor()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{
+			query: `SELECT name || ' ' || type || ' ' || IFNULL(parent_id, '-') || ' ' || IFNULL(open_date, '-') || ' ' || IFNULL(close_date, '-') FROM accounts ORDER BY name`,
+			want: []string{
+				"Assets Assets - - -",
+				"Assets:Bank Assets 1 2021-01-01 2021-01-03",
+				"Equity Equity - - -",
+				"Equity:Equity Equity 3 2021-01-01 -",
+			},
+		},
+		{
+			query: `SELECT p.date || ' ' || c.name || ' ' || p.price || ' ' || t.name FROM prices p JOIN commodities c ON c.id = p.commodity_id JOIN commodities t ON t.id = p.target_id`,
+			want:  []string{"2021-01-01 USD 0.9 CHF"},
+		},
+		{
+			query: `SELECT t.date || ' ' || t.description || ' ' || t.uid || ' ' || a.name || ' ' || o.name || ' ' || p.quantity || ' ' || c.name || ' ' || IFNULL(p.value, '-')
+				FROM postings p
+				JOIN transactions t ON t.id = p.transaction_id
+				JOIN accounts a ON a.id = p.account_id
+				JOIN accounts o ON o.id = p.other_account_id
+				JOIN commodities c ON c.id = p.commodity_id
+				ORDER BY a.name`,
+			want: []string{
+				"2021-01-02 deposit abc Assets:Bank Equity:Equity 100.5 USD -",
+				"2021-01-02 deposit abc Equity:Equity Assets:Bank -100.5 USD -",
+			},
+		},
+		{
+			query: `SELECT t.description || ' ' || l.link FROM links l JOIN transactions t ON t.id = l.transaction_id`,
+			want:  []string{"deposit invoice-1"},
+		},
+	}
+	for _, test := range tests {
+		rows, err := db.Query(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, s)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("query %q returned unexpected rows (-want/+got):\n%s", test.query, diff)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Stats contains statistics about a journal.
+type Stats struct {
+	Transactions, Bookings, Prices, Assertions int
+
+	Accounts    set.Set[*model.Account]
+	Commodities set.Set[*model.Commodity]
+
+	// First and Last are the dates of the first and last transaction.
+	First, Last time.Time
+
+	// LastByAccount contains the date of the last transaction per account.
+	LastByAccount map[*model.Account]time.Time
+
+	Files   []File
+	Timings []Timing
+
+	dates []time.Time
+	files map[string]int
+}
+
+// File contains the number of directives in a file and the date of the last
+// transaction originating from it.
+type File struct {
+	Path            string
+	Directives      int
+	LastTransaction time.Time
+}
+
+// Timing is the duration of a processing stage.
+type Timing struct {
+	Stage    string
+	Duration time.Duration
+}
+
+// Gap is a period without transactions.
+type Gap struct {
+	From, To time.Time
+}
+
+// Days returns the number of days between the two transactions.
+func (g Gap) Days() int {
+	return int(g.To.Sub(g.From).Hours() / 24)
+}
+
+// New creates new stats.
+func New() *Stats {
+	return &Stats{
+		Accounts:      set.New[*model.Account](),
+		Commodities:   set.New[*model.Commodity](),
+		LastByAccount: make(map[*model.Account]time.Time),
+		files:         make(map[string]int),
+	}
+}
+
+// AddFile records the directives of a parsed file.
+func (s *Stats) AddFile(f syntax.File) {
+	s.files[f.Path] = len(s.Files)
+	s.Files = append(s.Files, File{Path: f.Path, Directives: len(f.Directives)})
+}
+
+// AddTiming records the duration of a stage.
+func (s *Stats) AddTiming(stage string, d time.Duration) {
+	s.Timings = append(s.Timings, Timing{Stage: stage, Duration: d})
+}
+
+// Collect returns a processor which collects statistics.
+func (s *Stats) Collect() *journal.Processor {
+	return &journal.Processor{
+		Price: func(p *model.Price) error {
+			s.Prices++
+			s.Commodities.Add(p.Commodity)
+			s.Commodities.Add(p.Target)
+			return nil
+		},
+		Open: func(o *model.Open) error {
+			s.Accounts.Add(o.Account)
+			return nil
+		},
+		Transaction: func(t *model.Transaction) error {
+			s.Transactions++
+			for i, p := range t.Postings {
+				if p.Virtual || i%2 == 1 {
+					s.Bookings++
+				}
+			}
+			if s.First.IsZero() {
+				s.First = t.Date
+			}
+			s.Last = t.Date
+			if len(s.dates) == 0 || s.dates[len(s.dates)-1] != t.Date {
+				s.dates = append(s.dates, t.Date)
+			}
+			if t.Src != nil {
+				if i, ok := s.files[t.Src.Path]; ok && t.Date.After(s.Files[i].LastTransaction) {
+					s.Files[i].LastTransaction = t.Date
+				}
+			}
+			return nil
+		},
+		Posting: func(t *model.Transaction, p *model.Posting) error {
+			s.Accounts.Add(p.Account)
+			if t.Date.After(s.LastByAccount[p.Account]) {
+				s.LastByAccount[p.Account] = t.Date
+			}
+			s.Commodities.Add(p.Commodity)
+			return nil
+		},
+		Assertion: func(a *model.Assertion) error {
+			s.Assertions++
+			return nil
+		},
+	}
+}
+
+// Gaps returns the n longest periods without transactions.
+func (s *Stats) Gaps(n int) []Gap {
+	var gaps []Gap
+	for i := 1; i < len(s.dates); i++ {
+		gaps = append(gaps, Gap{From: s.dates[i-1], To: s.dates[i]})
+	}
+	sort.SliceStable(gaps, func(i, j int) bool {
+		return gaps[i].Days() > gaps[j].Days()
+	})
+	if len(gaps) > n {
+		gaps = gaps[:n]
+	}
+	return gaps
+}
+
+// Renderer renders stats.
+type Renderer struct {
+	Gaps int
+}
+
+// Render renders stats.
+func (rn Renderer) Render(s *Stats) *table.Table {
+	tbl := table.New(1, 1)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().AddText("Journal", table.Center).AddEmpty()
+	tbl.AddSeparatorRow()
+	rn.addCount(tbl, "Transactions", s.Transactions)
+	rn.addCount(tbl, "Bookings", s.Bookings)
+	rn.addCount(tbl, "Prices", s.Prices)
+	rn.addCount(tbl, "Assertions", s.Assertions)
+	rn.addCount(tbl, "Accounts", len(s.Accounts))
+	rn.addCount(tbl, "Commodities", len(s.Commodities))
+	if !s.First.IsZero() {
+		tbl.AddRow().AddText("First transaction", table.Left).AddText(s.First.Format("2006-01-02"), table.Right)
+		tbl.AddRow().AddText("Last transaction", table.Left).AddText(s.Last.Format("2006-01-02"), table.Right)
+	}
+	if gaps := s.Gaps(rn.Gaps); len(gaps) > 0 {
+		tbl.AddSeparatorRow()
+		tbl.AddRow().AddText("Largest gaps", table.Center).AddText("Days", table.Center)
+		tbl.AddSeparatorRow()
+		for _, g := range gaps {
+			period := fmt.Sprintf("%s - %s", g.From.Format("2006-01-02"), g.To.Format("2006-01-02"))
+			rn.addCount(tbl, period, g.Days())
+		}
+	}
+	if len(s.Files) > 0 {
+		tbl.AddSeparatorRow()
+		tbl.AddRow().AddText("File", table.Center).AddText("Directives", table.Center)
+		tbl.AddSeparatorRow()
+		for _, f := range s.sortedFiles() {
+			rn.addCount(tbl, f.Path, f.Directives)
+		}
+	}
+	if len(s.Timings) > 0 {
+		tbl.AddSeparatorRow()
+		tbl.AddRow().AddText("Stage", table.Center).AddText("Duration", table.Center)
+		tbl.AddSeparatorRow()
+		for _, t := range s.Timings {
+			tbl.AddRow().AddText(t.Stage, table.Left).AddText(t.Duration.Round(time.Microsecond).String(), table.Right)
+		}
+	}
+	tbl.AddSeparatorRow()
+	return tbl
+}
+
+func (s *Stats) sortedFiles() []File {
+	files := append([]File(nil), s.Files...)
+	compare.Sort(files, func(f1, f2 File) compare.Order {
+		return compare.Ordered(f1.Path, f2.Path)
+	})
+	return files
+}
+
+func (rn Renderer) addCount(tbl *table.Table, name string, n int) {
+	tbl.AddRow().AddText(name, table.Left).AddText(strconv.Itoa(n), table.Right)
+}
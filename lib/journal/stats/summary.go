@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/model/account"
+)
+
+// Summary is a machine-readable representation of the statistics.
+type Summary struct {
+	Transactions     int              `json:"transactions"`
+	Bookings         int              `json:"bookings"`
+	Prices           int              `json:"prices"`
+	Assertions       int              `json:"assertions"`
+	Commodities      int              `json:"commodities"`
+	FirstTransaction string           `json:"first_transaction,omitempty"`
+	LastTransaction  string           `json:"last_transaction,omitempty"`
+	Gaps             []GapSummary     `json:"gaps"`
+	Accounts         []AccountSummary `json:"accounts"`
+	Files            []FileSummary    `json:"files"`
+	Timings          []TimingSummary  `json:"timings"`
+}
+
+// GapSummary describes a period without transactions.
+type GapSummary struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days int    `json:"days"`
+}
+
+// AccountSummary describes the most recent activity in an account.
+type AccountSummary struct {
+	Account         string `json:"account"`
+	LastTransaction string `json:"last_transaction,omitempty"`
+	DaysSince       *int   `json:"days_since,omitempty"`
+}
+
+// FileSummary describes a file and its most recent transaction.
+type FileSummary struct {
+	Path            string `json:"path"`
+	Directives      int    `json:"directives"`
+	LastTransaction string `json:"last_transaction,omitempty"`
+	DaysSince       *int   `json:"days_since,omitempty"`
+}
+
+// TimingSummary describes the duration of a stage.
+type TimingSummary struct {
+	Stage   string  `json:"stage"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Summary creates a summary containing the given number of gaps. Ages of
+// the last transactions are computed relative to today.
+func (s *Stats) Summary(gaps int, today time.Time) Summary {
+	res := Summary{
+		Transactions:     s.Transactions,
+		Bookings:         s.Bookings,
+		Prices:           s.Prices,
+		Assertions:       s.Assertions,
+		Commodities:      len(s.Commodities),
+		FirstTransaction: formatDate(s.First),
+		LastTransaction:  formatDate(s.Last),
+		Gaps:             []GapSummary{},
+		Accounts:         []AccountSummary{},
+		Files:            []FileSummary{},
+		Timings:          []TimingSummary{},
+	}
+	for _, g := range s.Gaps(gaps) {
+		res.Gaps = append(res.Gaps, GapSummary{
+			From: formatDate(g.From),
+			To:   formatDate(g.To),
+			Days: g.Days(),
+		})
+	}
+	for _, a := range s.Accounts.Sorted(account.Compare) {
+		last := s.LastByAccount[a]
+		res.Accounts = append(res.Accounts, AccountSummary{
+			Account:         a.Name(),
+			LastTransaction: formatDate(last),
+			DaysSince:       daysSince(last, today),
+		})
+	}
+	for _, f := range s.sortedFiles() {
+		res.Files = append(res.Files, FileSummary{
+			Path:            f.Path,
+			Directives:      f.Directives,
+			LastTransaction: formatDate(f.LastTransaction),
+			DaysSince:       daysSince(f.LastTransaction, today),
+		})
+	}
+	for _, t := range s.Timings {
+		res.Timings = append(res.Timings, TimingSummary{Stage: t.Stage, Seconds: t.Duration.Seconds()})
+	}
+	return res
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func daysSince(t, today time.Time) *int {
+	if t.IsZero() {
+		return nil
+	}
+	days := int(today.Sub(t).Hours() / 24)
+	return &days
+}
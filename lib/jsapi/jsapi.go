@@ -0,0 +1,254 @@
+// Package jsapi implements the functions which the WASM module exposes to
+// JavaScript: parsing a journal and rendering its balance and register. A
+// journal is given as the text of a single file, as there is no file system
+// in a browser, so include directives are ignored.
+package jsapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+
+	"go.uber.org/multierr"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/rounding"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/balance"
+	"github.com/sboehler/knut/lib/reports/query"
+	"github.com/sboehler/knut/lib/reports/register"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Path is the path of the journal in positions and error messages.
+const Path = "journal.knut"
+
+// Directive describes a directive of a parsed journal.
+type Directive struct {
+	Kind string `json:"kind"`
+	Date string `json:"date,omitempty"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// ParseResult is the result of parsing a journal.
+type ParseResult struct {
+	Directives []Directive `json:"directives"`
+	Errors     []string    `json:"errors"`
+}
+
+// Parse parses a journal leniently, returning the directives which could be
+// parsed together with all syntax errors.
+func Parse(text string) ParseResult {
+	res := ParseResult{Directives: []Directive{}, Errors: []string{}}
+	p := parser.New(text, Path)
+	p.Recover = true
+	if err := p.Advance(); err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return res
+	}
+	f, err := p.ParseFile()
+	for _, err := range multierr.Errors(err) {
+		res.Errors = append(res.Errors, err.Error())
+	}
+	for _, d := range f.Directives {
+		start := d.Range
+		start.End = start.Start
+		dir := Directive{
+			Kind: kind(d),
+			Line: start.Location().Line,
+			Text: d.Extract(),
+		}
+		if dt, ok := syntax.DateOf(d); ok {
+			dir.Date = dt.Extract()
+		}
+		res.Directives = append(res.Directives, dir)
+	}
+	return res
+}
+
+func kind(d syntax.Directive) string {
+	switch d.Directive.(type) {
+	case syntax.Transaction:
+		return "transaction"
+	case syntax.Open:
+		return "open"
+	case syntax.Close:
+		return "close"
+	case syntax.Note:
+		return "note"
+	case syntax.Document:
+		return "document"
+	case syntax.Assertion:
+		return "assertion"
+	case syntax.Price:
+		return "price"
+	case syntax.Value:
+		return "value"
+	case syntax.Include:
+		return "include"
+	case syntax.Template:
+		return "template"
+	case syntax.Use:
+		return "use"
+	case syntax.AccountType:
+		return "accountType"
+	case syntax.CommodityDeclaration:
+		return "commodity"
+	case syntax.Alias:
+		return "alias"
+	case syntax.Clock:
+		return "clock"
+	}
+	return "unknown"
+}
+
+// Balance renders the balance of a journal for the query in the given
+// format, text or html.
+func Balance(ctx context.Context, text string, q query.Params, format string) (string, error) {
+	reg := registry.New()
+	j, valuation, err := load(ctx, reg, text, q)
+	if err != nil {
+		return "", err
+	}
+	partition := partition(j, q)
+	report := balance.NewReport(reg, partition)
+	reportQuery := journal.Query{
+		Select: amounts.KeyMapper{
+			Date:      partition.Align(),
+			Account:   mapper.Identity[*model.Account],
+			Commodity: mapper.Identity[*model.Commodity],
+			Valuation: commodity.IdentityIf(valuation != nil),
+		}.Build(),
+		Where: predicate.And(
+			predicate.Not(amounts.AccountIsOffBalance),
+			amounts.AccountMatches(regexes(q.AccountRegex())),
+			amounts.CommodityMatches(regexes(q.CommodityRegex())),
+		),
+		Valuation: valuation,
+	}
+	// The processors are created before the journal is built, as
+	// CloseAccounts adds the days on which accounts are closed.
+	procs := []*journal.Processor{
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ComputePrices(valuation),
+		// Values are truncated, like by default on the command line.
+		journal.ValuateWithRounding(reg, valuation, rounding.Truncate),
+		journal.Filter(partition),
+		journal.CloseAccounts(j, reg, true, partition),
+		reportQuery.Into(report),
+	}
+	err = j.Build().Process(procs...)
+	if err != nil {
+		return "", err
+	}
+	rn := balance.Renderer{Valuation: valuation}
+	return render(rn.Render(report), "Balance", format)
+}
+
+// Register renders the register of a journal for the query in the given
+// format, text or html. The account of the query is used as the source
+// account.
+func Register(ctx context.Context, text string, q query.Params, format string) (string, error) {
+	reg := registry.New()
+	j, valuation, err := load(ctx, reg, text, q)
+	if err != nil {
+		return "", err
+	}
+	partition := partition(j, q)
+	report := register.NewReport(reg)
+	reportQuery := journal.Query{
+		Select: amounts.KeyMapper{
+			Date:      partition.Align(),
+			Other:     mapper.Identity[*model.Account],
+			Commodity: commodity.IdentityIf(valuation == nil),
+			Valuation: mapper.Identity[*model.Commodity],
+		}.Build(),
+		Where: predicate.And(
+			amounts.AccountMatches(regexes(q.AccountRegex())),
+			amounts.CommodityMatches(regexes(q.CommodityRegex())),
+		),
+		Valuation: valuation,
+	}
+	err = j.Build().Process(
+		journal.Sort(),
+		journal.ComputePrices(valuation),
+		journal.ApplyValues(reg),
+		check.Check(),
+		journal.ValuateWithRounding(reg, valuation, rounding.Truncate),
+		journal.Filter(partition),
+		reportQuery.Into(report),
+	)
+	if err != nil {
+		return "", err
+	}
+	rn := register.Renderer{ShowCommodities: valuation == nil}
+	return render(rn.Render(report), "Register", format)
+}
+
+func load(ctx context.Context, reg *model.Registry, text string, q query.Params) (*journal.Builder, *model.Commodity, error) {
+	var valuation *model.Commodity
+	if q.Valuation != "" {
+		var err error
+		if valuation, err = reg.Commodities().Get(q.Valuation); err != nil {
+			return nil, nil, err
+		}
+	}
+	p := parser.New(text, Path)
+	if err := p.Advance(); err != nil {
+		return nil, nil, err
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	j, err := journal.FromFile(ctx, reg, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return j, valuation, nil
+}
+
+// partition partitions the period of the query, which defaults to the
+// period of the journal up to today.
+func partition(j *journal.Builder, q query.Params) date.Partition {
+	period := q.Period
+	if period.End.IsZero() {
+		period.End = date.Today()
+	}
+	return date.NewPartition(period.Clip(j.Period()), q.Interval, 0)
+}
+
+func regexes(rx string) []*regexp.Regexp {
+	if rx == "" {
+		return nil
+	}
+	return []*regexp.Regexp{regexp.MustCompile(rx)}
+}
+
+func render(t *table.Table, title, format string) (string, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	switch format {
+	case "", "text":
+		err = (&table.TextRenderer{}).Render(t, &buf)
+	case "html":
+		err = (&table.HTMLRenderer{Title: title}).Render(t, &buf)
+	default:
+		return "", fmt.Errorf("invalid format %q, want text or html", format)
+	}
+	return buf.String(), err
+}
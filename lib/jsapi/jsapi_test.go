@@ -0,0 +1,112 @@
+package jsapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/reports/query"
+)
+
+const journalText = `2023-01-01 open Assets:Bank
+2023-01-01 open Expenses:Food
+
+2023-01-05 "Groceries"
+Assets:Bank Expenses:Food 12 CHF
+`
+
+func TestParse(t *testing.T) {
+	got := Parse(journalText)
+
+	want := []Directive{
+		{Kind: "open", Date: "2023-01-01", Line: 1, Text: "2023-01-01 open Assets:Bank"},
+		{Kind: "open", Date: "2023-01-01", Line: 2, Text: "2023-01-01 open Expenses:Food"},
+		{Kind: "transaction", Date: "2023-01-05", Line: 4, Text: "2023-01-05 \"Groceries\"\nAssets:Bank Expenses:Food 12 CHF\n"},
+	}
+	if diff := cmp.Diff(want, got.Directives); diff != "" {
+		t.Errorf("Parse() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if len(got.Errors) != 0 {
+		t.Errorf("Parse() returned unexpected errors: %v", got.Errors)
+	}
+}
+
+func TestParseRecover(t *testing.T) {
+	got := Parse("2023-01-06 bogus\n\n" + journalText)
+
+	if len(got.Errors) != 1 {
+		t.Errorf("Parse() returned %d errors, want 1: %v", len(got.Errors), got.Errors)
+	}
+	if n := len(got.Directives); n < 3 {
+		t.Errorf("Parse() returned %d directives, want at least 3", n)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	q := query.Params{Period: date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 1, 31)}}
+
+	got, err := Balance(context.Background(), journalText, q, "text")
+
+	if err != nil {
+		t.Fatalf("Balance() returned unexpected error: %v", err)
+	}
+	for _, s := range []string{"Assets", "Bank", "Expenses", "Food", "-12"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("Balance() = %q, want it to contain %q", got, s)
+		}
+	}
+}
+
+func TestBalanceMultiperiod(t *testing.T) {
+	text := journalText + `
+2023-02-05 "Groceries"
+Assets:Bank Expenses:Food 7 CHF
+`
+	q := query.Params{
+		Period:   date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 2, 28)},
+		Interval: date.Monthly,
+	}
+
+	got, err := Balance(context.Background(), text, q, "text")
+
+	if err != nil {
+		t.Fatalf("Balance() returned unexpected error: %v", err)
+	}
+	var food string
+	for _, line := range strings.Split(got, "\n") {
+		if strings.Contains(line, "Food") {
+			food = strings.Join(strings.Fields(line), " ")
+		}
+	}
+	// Expenses are closed into equity at the start of each period.
+	if want := "| Food | CHF | -12 | -7 |"; food != want {
+		t.Errorf("Balance() returned food row %q, want %q:\n%s", food, want, got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	q := query.Params{
+		Account: "Assets:Bank",
+		Period:  date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 1, 31)},
+	}
+
+	got, err := Register(context.Background(), journalText, q, "html")
+
+	if err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	for _, s := range []string{"<table", "Expenses:Food", "12"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("Register() = %q, want it to contain %q", got, s)
+		}
+	}
+}
+
+func TestRenderInvalidFormat(t *testing.T) {
+	if _, err := Balance(context.Background(), journalText, query.Params{}, "pdf"); err == nil {
+		t.Errorf("Balance() with format pdf returned no error")
+	}
+}
@@ -1,65 +1,129 @@
 package account
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/sboehler/knut/lib/common/compare"
 	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/regex"
+	"github.com/sboehler/knut/lib/common/set"
 )
 
-// Type is the type of an account.
-type Type int
+// Type is the type of an account, which is the name of its root account.
+// Besides the standard types, a journal can declare custom types.
+type Type string
 
 const (
 	// ASSETS represents an asset account.
-	ASSETS Type = iota
+	ASSETS Type = "Assets"
 	// LIABILITIES represents a liability account.
-	LIABILITIES
+	LIABILITIES Type = "Liabilities"
 	// EQUITY represents an equity account.
-	EQUITY
+	EQUITY Type = "Equity"
 	// INCOME represents an income account.
-	INCOME
+	INCOME Type = "Income"
 	// EXPENSES represents an expenses account.
-	EXPENSES
+	EXPENSES Type = "Expenses"
+	// ENVELOPES represents a budget envelope, which is not part of the
+	// balance sheet.
+	ENVELOPES Type = "Envelopes"
 )
 
 func (t Type) String() string {
+	return string(t)
+}
+
+// placement returns the placement of the standard account types.
+func (t Type) placement() Placement {
 	switch t {
-	case ASSETS:
-		return "Assets"
-	case LIABILITIES:
-		return "Liabilities"
-	case EQUITY:
-		return "Equity"
-	case INCOME:
-		return "Income"
-	case EXPENSES:
-		return "Expenses"
+	case ASSETS, LIABILITIES:
+		return AL
+	case ENVELOPES:
+		return OffBalance
 	}
-	return ""
+	return EIE
+}
+
+// IsCustom returns whether the type has been declared in a journal.
+func (t Type) IsCustom() bool {
+	return !slices.Contains(Types, t)
+}
+
+// CompareTypes orders the standard account types before the custom ones,
+// which are ordered by name.
+func CompareTypes(t1, t2 Type) compare.Order {
+	if t1.IsCustom() && t2.IsCustom() {
+		return compare.Ordered(t1, t2)
+	}
+	return compare.Ordered(typeIndex(t1), typeIndex(t2))
+}
+
+// typeIndex returns the position of a standard type in Types, and the
+// length of Types for custom types.
+func typeIndex(t Type) int {
+	if i := slices.Index(Types, t); i >= 0 {
+		return i
+	}
+	return len(Types)
 }
 
-// Types is an array with the ordered accont types.
-var Types = []Type{ASSETS, LIABILITIES, EQUITY, INCOME, EXPENSES}
+// Types is an array with the ordered standard accont types.
+var Types = []Type{ASSETS, LIABILITIES, EQUITY, INCOME, EXPENSES, ENVELOPES}
+
+// Placement determines where the accounts of a type appear in reports.
+type Placement int
 
-var types = map[string]Type{
-	"Assets":      ASSETS,
-	"Liabilities": LIABILITIES,
-	"Equity":      EQUITY,
-	"Expenses":    EXPENSES,
-	"Income":      INCOME,
+const (
+	// AL places accounts with the assets and liabilities.
+	AL Placement = iota
+	// EIE places accounts with equity, income and expenses.
+	EIE
+	// OffBalance excludes accounts from the balance.
+	OffBalance
+)
+
+func (p Placement) String() string {
+	switch p {
+	case AL:
+		return "al"
+	case EIE:
+		return "eie"
+	case OffBalance:
+		return "off-balance"
+	}
+	return ""
+}
+
+// ParsePlacement parses a placement.
+func ParsePlacement(s string) (Placement, error) {
+	for _, p := range []Placement{AL, EIE, OffBalance} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid placement %q, want al, eie or off-balance", s)
 }
 
 // Account represents an account which can be used in bookings.
 type Account struct {
+	id          int
 	accountType Type
+	placement   Placement
 	name        string
 	segments    []string
 }
 
+// ID returns the identifier of the account, which is unique within its
+// registry. Identifiers are assigned consecutively, starting at 1.
+func (a *Account) ID() int {
+	return a.id
+}
+
 // Segments returns the account name split into segments.
 func (a *Account) Segments() []string {
 	return a.segments
@@ -75,9 +139,15 @@ func (a Account) Type() Type {
 	return a.accountType
 }
 
-// IsAL returns whether this account is an asset or liability account.
+// Placement returns the placement of the account type.
+func (a Account) Placement() Placement {
+	return a.placement
+}
+
+// IsAL returns whether this account is an asset or liability account, or of
+// a custom type placed with them.
 func (a Account) IsAL() bool {
-	return a.accountType == ASSETS || a.accountType == LIABILITIES
+	return a.placement == AL
 }
 
 // IsIE returns whether this account is an income or expense account.
@@ -85,6 +155,22 @@ func (a Account) IsIE() bool {
 	return a.accountType == EXPENSES || a.accountType == INCOME
 }
 
+// IsEnvelope returns whether this account is a budget envelope.
+func (a Account) IsEnvelope() bool {
+	return a.accountType == ENVELOPES
+}
+
+// IsOffBalance returns whether this account is excluded from the balance,
+// like budget envelopes.
+func (a Account) IsOffBalance() bool {
+	return a.placement == OffBalance
+}
+
+// InSubtree returns whether this account is root or one of its descendants.
+func (a *Account) InSubtree(root *Account) bool {
+	return len(a.segments) >= len(root.segments) && slices.Equal(a.segments[:len(root.segments)], root.segments)
+}
+
 func (a Account) String() string {
 	return a.name
 }
@@ -94,25 +180,41 @@ func (a Account) Level() int {
 }
 
 func Compare(a1, a2 *Account) compare.Order {
-	o := compare.Ordered(a1.accountType, a2.accountType)
+	o := CompareTypes(a1.accountType, a2.accountType)
 	if o != compare.Equal {
 		return o
 	}
 	return compare.Ordered(a1.name, a2.name)
 }
 
-// Rule is a rule to shorten accounts which match the given regex.
+// Rule is a rule to shorten accounts which match the given regex. If Types
+// is not empty, the rule only applies to accounts of the given types.
 type Rule struct {
+	Types  set.Set[Type]
 	Level  int
 	Suffix int
 	Regex  *regexp.Regexp
 }
 
 func (rule Rule) String() string {
-	return fmt.Sprintf("%d,%v", rule.Level, rule.Regex)
+	var prefix string
+	if len(rule.Types) > 0 {
+		var ts []string
+		for _, t := range rule.Types.Sorted(CompareTypes) {
+			ts = append(ts, t.String())
+		}
+		prefix = strings.Join(ts, "|") + "="
+	}
+	return fmt.Sprintf("%s%d,%v", prefix, rule.Level, rule.Regex)
 }
 
 func (rule Rule) Match(s string) (int, int, bool) {
+	if len(rule.Types) > 0 {
+		head, _, _ := strings.Cut(s, ":")
+		if !rule.Types.Has(Type(head)) {
+			return 0, 0, false
+		}
+	}
 	if rule.Regex == nil {
 		return rule.Level, rule.Suffix, true
 	}
@@ -168,6 +270,90 @@ func Shorten(reg *Registry, m Mapping) mapper.Mapper[*Account] {
 	}
 }
 
+// Substitution maps the accounts whose name matches Regex to Target.
+type Substitution struct {
+	Regex  *regexp.Regexp
+	Target *Account
+}
+
+func (s Substitution) String() string {
+	return fmt.Sprintf("match: %v => %s", s.Regex, s.Target)
+}
+
+// ReadSubstitutions reads a file of substitutions, one per line, of the form
+//
+//	match: <regex> => <account>
+//
+// Blank lines and lines starting with '#' are ignored.
+func ReadSubstitutions(reg *Registry, r io.Reader) ([]Substitution, error) {
+	var (
+		res []Substitution
+		s   = bufio.NewScanner(r)
+	)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, ok := strings.CutPrefix(text, "match:")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected match: <regex> => <account>, got %q", line, text)
+		}
+		expr, target, ok := strings.Cut(rule, "=>")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected match: <regex> => <account>, got %q", line, text)
+		}
+		regex, err := regexp.Compile(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		acc, err := reg.Get(strings.TrimSpace(target))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		res = append(res, Substitution{Regex: regex, Target: acc})
+	}
+	return res, s.Err()
+}
+
+// Substitute maps accounts to the target of the first substitution whose
+// regex matches their name. Other accounts are left unchanged.
+func Substitute(ss []Substitution) mapper.Mapper[*Account] {
+	if len(ss) == 0 {
+		return mapper.Identity[*Account]
+	}
+	return func(a *Account) *Account {
+		if a == nil {
+			return a
+		}
+		for _, s := range ss {
+			if s.Regex.MatchString(a.name) {
+				return s.Target
+			}
+		}
+		return a
+	}
+}
+
+// Collapse maps accounts below the given depth to their ancestor at that
+// depth. Ancestors which absorbed deeper accounts are added to collapsed, if
+// it is not nil. A depth of zero or less disables collapsing.
+func Collapse(reg *Registry, depth int, collapsed set.Set[*Account]) mapper.Mapper[*Account] {
+	if depth <= 0 {
+		return mapper.Identity[*Account]
+	}
+	return func(a *Account) *Account {
+		if a == nil || a.Level() <= depth {
+			return a
+		}
+		res := reg.MustGetPath(a.Segments()[:depth])
+		if collapsed != nil {
+			collapsed.Add(res)
+		}
+		return res
+	}
+}
+
 func Remap(reg *Registry, rs regex.Regexes) mapper.Mapper[*Account] {
 	return func(a *Account) *Account {
 		if rs.MatchString(a.name) {
@@ -0,0 +1,71 @@
+package account
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sboehler/knut/lib/common/set"
+)
+
+func TestSubstitute(t *testing.T) {
+	reg := NewRegistry()
+	ss, err := ReadSubstitutions(reg, strings.NewReader(`# groceries and restaurants
+match: ^Expenses:Food.* => Expenses:Living
+
+match: ^Expenses:(Fees|Taxes)$ => Expenses:Other:Charges
+match: ^Expenses => Expenses:Other
+`))
+	if err != nil {
+		t.Fatalf("ReadSubstitutions() returned unexpected error: %v", err)
+	}
+	m := Substitute(ss)
+	for _, test := range []struct {
+		account, want string
+	}{
+		{"Expenses:Food:Groceries", "Expenses:Living"},
+		{"Expenses:Fees", "Expenses:Other:Charges"},
+		{"Expenses:Fees:Bank", "Expenses:Other"},
+		{"Assets:Bank", "Assets:Bank"},
+	} {
+		if got := m(reg.MustGet(test.account)); got.Name() != test.want {
+			t.Errorf("Substitute(%s) = %s, want %s", test.account, got.Name(), test.want)
+		}
+	}
+}
+
+func TestReadSubstitutionsErrors(t *testing.T) {
+	for _, text := range []string{
+		"Expenses => Expenses:Other",
+		"match: ^Expenses",
+		"match: ^Expenses( => Expenses:Other",
+		"match: ^Expenses => Foo:Other",
+	} {
+		if _, err := ReadSubstitutions(NewRegistry(), strings.NewReader(text)); err == nil {
+			t.Errorf("ReadSubstitutions(%q) returned nil, want an error", text)
+		}
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	reg := NewRegistry()
+	collapsed := set.New[*Account]()
+	m := Collapse(reg, 2, collapsed)
+	for _, test := range []struct {
+		account, want string
+	}{
+		{"Expenses:Food:Groceries", "Expenses:Food"},
+		{"Expenses:Food:Restaurants:Lunch", "Expenses:Food"},
+		{"Expenses:Rent", "Expenses:Rent"},
+		{"Assets", "Assets"},
+	} {
+		if got := m(reg.MustGet(test.account)); got.Name() != test.want {
+			t.Errorf("Collapse(%s) = %s, want %s", test.account, got.Name(), test.want)
+		}
+	}
+	if got := collapsed.Sorted(Compare); len(got) != 1 || got[0].Name() != "Expenses:Food" {
+		t.Errorf("collapsed = %v, want [Expenses:Food]", got)
+	}
+	if got := Collapse(reg, 0, nil)(reg.MustGet("Expenses:Food:Groceries")); got.Name() != "Expenses:Food:Groceries" {
+		t.Errorf("Collapse() with depth 0 = %s, want Expenses:Food:Groceries", got.Name())
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"sync"
 	"unicode"
 
+	"github.com/sboehler/knut/lib/common/compare"
 	"github.com/sboehler/knut/lib/common/multimap"
 	"github.com/sboehler/knut/lib/syntax"
 )
@@ -30,6 +31,19 @@ type Registry struct {
 	index    map[string]*Account
 	accounts *multimap.Node[*Account]
 	swaps    map[*Account]*Account
+	custom   map[Type]Placement
+
+	valuations map[*Account]*Account
+}
+
+// UnknownTypeError is returned for an account whose type is neither a
+// standard type nor declared.
+type UnknownTypeError struct {
+	Account, Type string
+}
+
+func (e UnknownTypeError) Error() string {
+	return fmt.Sprintf("account %s has an invalid account type %s", e.Account, e.Type)
 }
 
 // NewRegistry creates a new thread-safe collection of accounts.
@@ -38,8 +52,11 @@ func NewRegistry() *Registry {
 		accounts: multimap.New[*Account](""),
 		index:    make(map[string]*Account),
 		swaps:    make(map[*Account]*Account),
+		custom:   make(map[Type]Placement),
+
+		valuations: make(map[*Account]*Account),
 	}
-	for _, t := range types {
+	for _, t := range Types {
 		reg.Get(t.String())
 	}
 
@@ -78,9 +95,13 @@ func (as *Registry) getOrCreatePath(segments []string) (*Account, error) {
 		return nil, fmt.Errorf("invalid account: %s", segments)
 	}
 	head, tail := segments[0], segments[1:]
-	accountType, ok := types[head]
-	if !ok {
-		return nil, fmt.Errorf("account %s has an invalid account type %s", segments, head)
+	accountType := Type(head)
+	placement := accountType.placement()
+	if accountType.IsCustom() {
+		var ok bool
+		if placement, ok = as.custom[accountType]; !ok {
+			return nil, UnknownTypeError{Account: strings.Join(segments, ":"), Type: head}
+		}
 	}
 	for _, s := range tail {
 		if !isValidSegment(s) {
@@ -99,7 +120,9 @@ func (as *Registry) getOrCreatePath(segments []string) (*Account, error) {
 		}
 		name := strings.Join(segments[:i+1], ":")
 		current.Value = &Account{
+			id:          len(as.index) + 1,
 			accountType: accountType,
+			placement:   placement,
 			name:        name,
 			segments:    strings.Split(name, ":"),
 		}
@@ -108,6 +131,54 @@ func (as *Registry) getOrCreatePath(segments []string) (*Account, error) {
 	return current.Value, nil
 }
 
+// DeclareType declares a custom account type with the given placement.
+// Declaring a type again with the same placement has no effect.
+func (as *Registry) DeclareType(name string, p Placement) (Type, error) {
+	t := Type(name)
+	if !t.IsCustom() {
+		return t, fmt.Errorf("%s is a standard account type", name)
+	}
+	if !isValidSegment(name) {
+		return "", fmt.Errorf("invalid account type %q", name)
+	}
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if q, ok := as.custom[t]; ok && q != p {
+		return t, fmt.Errorf("account type %s is already declared as %s", name, q)
+	}
+	as.custom[t] = p
+	return t, nil
+}
+
+// ParseType parses an account type, which is either a standard type, matched
+// ignoring case, or a type declared in this registry.
+func (as *Registry) ParseType(s string) (Type, error) {
+	for _, t := range Types {
+		if strings.EqualFold(t.String(), s) {
+			return t, nil
+		}
+	}
+	as.mutex.RLock()
+	_, ok := as.custom[Type(s)]
+	as.mutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown account type %q", s)
+	}
+	return Type(s), nil
+}
+
+// All returns all accounts, ordered by type and name.
+func (as *Registry) All() []*Account {
+	as.mutex.RLock()
+	res := make([]*Account, 0, len(as.index))
+	for _, a := range as.index {
+		res = append(res, a)
+	}
+	as.mutex.RUnlock()
+	compare.Sort(res, Compare)
+	return res
+}
+
 func (as *Registry) MustGet(name string) *Account {
 	a, err := as.Get(name)
 	if err != nil {
@@ -177,9 +248,36 @@ func (as *Registry) TBDAccount() *Account {
 	return as.MustGet("Expenses:TBD")
 }
 
+// ConversionAccount returns the account through which bookings with an
+// exchange rate convert one commodity into another.
+func (as *Registry) ConversionAccount() *Account {
+	return as.MustGet("Equity:Conversion")
+}
+
+// TimeclockAccount returns the account from which the hours of timeclock
+// sessions are booked.
+func (as *Registry) TimeclockAccount() *Account {
+	return as.MustGet("Equity:Timeclock")
+}
+
+// SetValuationAccount configures the account which receives the valuation
+// gains and losses of the given account.
+func (as *Registry) SetValuationAccount(a, valuation *Account) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	as.valuations[a] = valuation
+}
+
 // ValuationAccountFor returns the valuation account which corresponds to
-// the given Asset or Liability account.
+// the given Asset or Liability account. Unless configured otherwise, this is
+// the account with the same name in Income.
 func (as *Registry) ValuationAccountFor(a *Account) *Account {
+	as.mutex.RLock()
+	v, ok := as.valuations[a]
+	as.mutex.RUnlock()
+	if ok {
+		return v
+	}
 	segments := append(as.MustGet("Income").Segments(), a.Segments()[1:]...)
 	return as.MustGet(strings.Join(segments, ":"))
 }
@@ -23,6 +23,32 @@ type Balance struct {
 	Account   *account.Account
 	Quantity  decimal.Decimal
 	Commodity *commodity.Commodity
+
+	// Tolerance is the maximum deviation of the actual from the asserted
+	// quantity.
+	Tolerance decimal.Decimal
+
+	// Subtree is set if the assertion covers the combined balance of the
+	// account and its descendants.
+	Subtree bool
+
+	// Cost, CostCommodity and LotDate are set for lot assertions, which
+	// assert the quantity of the lots with the given unit cost and, if
+	// LotDate is not zero, date.
+	Cost          decimal.Decimal
+	CostCommodity *commodity.Commodity
+	LotDate       time.Time
+}
+
+// IsLot returns whether the balance asserts the quantity of a lot.
+func (b Balance) IsLot() bool {
+	return b.CostCommodity != nil
+}
+
+// Matches returns whether the given quantity satisfies the balance, within
+// the tolerance.
+func (b Balance) Matches(qty decimal.Decimal) bool {
+	return qty.Sub(b.Quantity).Abs().LessThanOrEqual(b.Tolerance)
 }
 
 func Create(reg *registry.Registry, a *syntax.Assertion) (*Assertion, error) {
@@ -31,7 +57,8 @@ func Create(reg *registry.Registry, a *syntax.Assertion) (*Assertion, error) {
 		return nil, err
 	}
 	balances := make([]Balance, 0, len(a.Balances))
-	for _, bal := range a.Balances {
+	for i := range a.Balances {
+		bal := &a.Balances[i]
 		account, err := reg.Accounts().Create(bal.Account)
 		if err != nil {
 			return nil, err
@@ -44,13 +71,28 @@ func Create(reg *registry.Registry, a *syntax.Assertion) (*Assertion, error) {
 		if err != nil {
 			return nil, err
 		}
-		balances = append(balances, Balance{
-			Src:       &bal,
+		balance := Balance{
+			Src:       bal,
 			Account:   account,
 			Quantity:  quantity,
 			Commodity: commodity,
-		})
-
+			Subtree:   !bal.Subtree.Empty(),
+		}
+		if !bal.Tolerance.Empty() {
+			if balance.Tolerance, err = bal.Tolerance.Parse(); err != nil {
+				return nil, err
+			}
+			if balance.Tolerance.IsNegative() {
+				return nil, syntax.Error{Range: bal.Tolerance.Range, Message: "tolerance must not be negative"}
+			}
+		}
+		if err := createLot(reg, &balance, bal.Cost); err != nil {
+			return nil, err
+		}
+		if balance.Subtree && balance.IsLot() {
+			return nil, syntax.Error{Range: bal.Range, Message: "lot assertions cannot cover a subtree"}
+		}
+		balances = append(balances, balance)
 	}
 	return &Assertion{
 		Src:      a,
@@ -59,6 +101,33 @@ func Create(reg *registry.Registry, a *syntax.Assertion) (*Assertion, error) {
 	}, nil
 }
 
+// createLot sets the lot of a lot assertion.
+func createLot(reg *registry.Registry, b *Balance, c syntax.Cost) error {
+	if c.Empty() {
+		return nil
+	}
+	if c.Total {
+		return syntax.Error{Range: c.Range, Message: "lot assertions require a unit cost"}
+	}
+	cost, err := c.Amount.Parse()
+	if err != nil {
+		return err
+	}
+	if cost.IsNegative() {
+		return syntax.Error{Range: c.Range, Message: "cost must not be negative"}
+	}
+	if b.CostCommodity, err = reg.Commodities().Create(c.Commodity); err != nil {
+		return err
+	}
+	b.Cost = cost
+	if !c.Date.Empty() {
+		if b.LotDate, err = c.Date.Parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func CompareBalance(x, y Balance) compare.Order {
 	if x.Account != y.Account {
 		return account.Compare(x.Account, y.Account)
@@ -66,5 +135,20 @@ func CompareBalance(x, y Balance) compare.Order {
 	if x.Commodity != y.Commodity {
 		return commodity.Compare(x.Commodity, y.Commodity)
 	}
+	if x.IsLot() != y.IsLot() {
+		if x.IsLot() {
+			return compare.Greater
+		}
+		return compare.Smaller
+	}
+	if x.CostCommodity != y.CostCommodity {
+		return commodity.Compare(x.CostCommodity, y.CostCommodity)
+	}
+	if o := compare.Decimal(x.Cost, y.Cost); o != compare.Equal {
+		return o
+	}
+	if o := compare.Time(x.LotDate, y.LotDate); o != compare.Equal {
+		return o
+	}
 	return compare.Decimal(x.Quantity, y.Quantity)
 }
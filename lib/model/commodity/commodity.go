@@ -1,15 +1,76 @@
 package commodity
 
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
 // Commodity represents a currency or security.
 type Commodity struct {
-	name       string
-	IsCurrency bool
+	id       int
+	name     string
+	currency atomic.Bool
+
+	// alias is the commodity which the commodity has been declared an
+	// alias of, after the commodity had been created.
+	alias atomic.Pointer[Commodity]
+
+	mutex    sync.RWMutex
+	metadata map[string]string
 }
 
-func (c Commodity) Name() string {
+// ID returns the identifier of the commodity, which is unique within its
+// registry. Identifiers are assigned consecutively, starting at 1.
+func (c *Commodity) ID() int {
+	return c.id
+}
+
+func (c *Commodity) Name() string {
 	return c.name
 }
 
-func (c Commodity) String() string {
+func (c *Commodity) String() string {
 	return c.name
 }
+
+// Resolve returns the commodity which the commodity has been declared an
+// alias of after it had been created, or the commodity itself. Directives
+// created before the alias was declared still refer to the commodity, and
+// are resolved when the journal is built.
+func (c *Commodity) Resolve() *Commodity {
+	for c != nil {
+		a := c.alias.Load()
+		if a == nil {
+			return c
+		}
+		c = a
+	}
+	return nil
+}
+
+// IsCurrency returns whether the commodity has been tagged as a currency.
+func (c *Commodity) IsCurrency() bool {
+	return c.currency.Load()
+}
+
+// Metadata returns the value of the given metadata key of the commodity.
+func (c *Commodity) Metadata(key string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	v, ok := c.metadata[key]
+	return v, ok
+}
+
+func (c *Commodity) setMetadata(key, value string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if v, ok := c.metadata[key]; ok && v != value {
+		return fmt.Errorf("commodity %s has conflicting values %q and %q for %s", c.name, v, value, key)
+	}
+	if c.metadata == nil {
+		c.metadata = make(map[string]string)
+	}
+	c.metadata[key] = value
+	return nil
+}
@@ -0,0 +1,123 @@
+package commodity
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/regex"
+	"gopkg.in/yaml.v2"
+)
+
+// OtherGroup is the group of commodities which are not explicitly
+// assigned to a group.
+const OtherGroup = "Other"
+
+// Groups assigns commodities to named groups, such as "Crypto" or "Cash".
+type Groups map[*Commodity]string
+
+// LoadGroupsFromFile loads commodity groups from a YAML file.
+func LoadGroupsFromFile(reg *Registry, path string) (Groups, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadGroups(reg, f)
+}
+
+// LoadGroups loads commodity groups in YAML format. The file maps group
+// names to lists of commodities:
+//
+//	Cash: [CHF, EUR, USD]
+//	Crypto: [BTC, ETH]
+func LoadGroups(reg *Registry, r io.Reader) (Groups, error) {
+	dec := yaml.NewDecoder(r)
+	dec.SetStrict(true)
+	var t map[string][]string
+	if err := dec.Decode(&t); err != nil {
+		return nil, err
+	}
+	groups := make(Groups)
+	for group, commodities := range t {
+		if !isValidCommodity(group) {
+			return nil, fmt.Errorf("invalid group name %q", group)
+		}
+		for _, name := range commodities {
+			com, err := reg.Get(name)
+			if err != nil {
+				return nil, err
+			}
+			if g, ok := groups[com]; ok {
+				return nil, fmt.Errorf("commodity %s is already in group %s", com.Name(), g)
+			}
+			groups[com] = group
+		}
+	}
+	return groups, nil
+}
+
+// GroupsByMetadata assigns the commodities of the registry to groups named
+// after their value for the given metadata key. Commodities without a value
+// belong to OtherGroup.
+func GroupsByMetadata(reg *Registry, key string) (Groups, error) {
+	groups := make(Groups)
+	for _, com := range reg.All() {
+		group, ok := com.Metadata(key)
+		if !ok {
+			continue
+		}
+		if !isValidCommodity(group) {
+			return nil, fmt.Errorf("invalid group name %q of commodity %s", group, com.Name())
+		}
+		groups[com] = group
+	}
+	return groups, nil
+}
+
+// Group returns the group of the given commodity.
+func (gs Groups) Group(c *Commodity) string {
+	if g, ok := gs[c]; ok {
+		return g
+	}
+	return OtherGroup
+}
+
+// Map returns a mapper which replaces each commodity by a commodity
+// representing its group. The group commodities are not part of a registry,
+// such that group names can't be used as commodities of the journal. Their
+// identifiers are negative, to be distinct from those of a registry.
+func (gs Groups) Map() mapper.Mapper[*Commodity] {
+	var (
+		mutex  sync.Mutex
+		groups = make(map[string]*Commodity)
+	)
+	return func(c *Commodity) *Commodity {
+		if c == nil {
+			return nil
+		}
+		name := gs.Group(c)
+		mutex.Lock()
+		defer mutex.Unlock()
+		g, ok := groups[name]
+		if !ok {
+			g = &Commodity{id: -len(groups) - 1, name: name}
+			groups[name] = g
+		}
+		return g
+	}
+}
+
+// Matches returns a predicate which holds for commodities whose group
+// matches any of the given regexes.
+func (gs Groups) Matches(rxs regex.Regexes) predicate.Predicate[*Commodity] {
+	if len(rxs) == 0 {
+		return predicate.True[*Commodity]
+	}
+	return func(c *Commodity) bool {
+		return rxs.MatchString(gs.Group(c))
+	}
+}
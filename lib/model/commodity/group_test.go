@@ -0,0 +1,125 @@
+package commodity
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadGroups(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			desc:  "assigns commodities to groups",
+			input: "Cash: [CHF, USD]\nCrypto: [BTC]\n",
+			want:  map[string]string{"CHF": "Cash", "USD": "Cash", "BTC": "Crypto", "AAPL": OtherGroup},
+		},
+		{
+			desc:    "rejects a commodity in two groups",
+			input:   "Cash: [CHF]\nSafe: [CHF]\n",
+			wantErr: true,
+		},
+		{
+			desc:    "rejects an invalid group name",
+			input:   "\"Cash Money\": [CHF]\n",
+			wantErr: true,
+		},
+		{
+			desc:    "rejects unknown keys",
+			input:   "Cash: CHF\n",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := NewCommodities()
+
+			groups, err := LoadGroups(reg, strings.NewReader(test.input))
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("LoadGroups() returned no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadGroups() returned unexpected error: %v", err)
+			}
+			got := make(map[string]string)
+			for name := range test.want {
+				got[name] = groups.Group(reg.MustGet(name))
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGroupsByMetadata(t *testing.T) {
+	reg := NewCommodities()
+	reg.MustGet("AAPL")
+	for name, class := range map[string]string{"CHF": "Cash", "BTC": "Crypto"} {
+		if err := reg.SetMetadata(name, "class", class); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := GroupsByMetadata(reg, "class")
+
+	if err != nil {
+		t.Fatalf("GroupsByMetadata() returned unexpected error: %v", err)
+	}
+	want := map[string]string{"CHF": "Cash", "BTC": "Crypto", "AAPL": OtherGroup}
+	got := make(map[string]string)
+	for name := range want {
+		got[name] = groups.Group(reg.MustGet(name))
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func TestGroupsMapAndMatches(t *testing.T) {
+	reg := NewCommodities()
+	var (
+		chf  = reg.MustGet("CHF")
+		btc  = reg.MustGet("BTC")
+		aapl = reg.MustGet("AAPL")
+	)
+	groups := Groups{chf: "Cash", btc: "Crypto"}
+
+	m := groups.Map()
+	cash := m(chf)
+	if cash.Name() != "Cash" || m(chf) != cash {
+		t.Errorf("Map(CHF) = %v, want Cash", cash)
+	}
+	if got := m(aapl); got.Name() != OtherGroup || got == cash {
+		t.Errorf("Map(AAPL) = %v, want %s", got, OtherGroup)
+	}
+	if got := m(nil); got != nil {
+		t.Errorf("Map(nil) = %v, want nil", got)
+	}
+	if got := len(reg.All()); got != 3 {
+		t.Errorf("Map() added groups to the registry, which has %d commodities, want 3", got)
+	}
+	if cash.ID() >= 0 {
+		t.Errorf("Map(CHF) has identifier %d, want a negative identifier", cash.ID())
+	}
+
+	pred := groups.Matches([]*regexp.Regexp{regexp.MustCompile("^Cash$")})
+	for c, want := range map[*Commodity]bool{chf: true, btc: false, aapl: false} {
+		if got := pred(c); got != want {
+			t.Errorf("Matches(%s) = %t, want %t", c.Name(), got, want)
+		}
+	}
+	if !groups.Matches(nil)(aapl) {
+		t.Errorf("Matches() without regexes must match all commodities")
+	}
+}
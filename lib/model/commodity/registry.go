@@ -26,20 +26,24 @@ import (
 
 // Registry is a thread-safe collection of commodities.
 type Registry struct {
-	index map[string]*Commodity
-	mutex sync.RWMutex
+	index   map[string]*Commodity
+	aliases map[string]string
+	mutex   sync.RWMutex
 }
 
 // NewCommodities creates a new thread-safe collection of commodities.
 func NewCommodities() *Registry {
 	return &Registry{
-		index: make(map[string]*Commodity),
+		index:   make(map[string]*Commodity),
+		aliases: make(map[string]string),
 	}
 }
 
-// Get creates a new commodity.
+// Get creates a new commodity. For an alias, it returns the commodity which
+// the alias refers to.
 func (cs *Registry) Get(name string) (*Commodity, error) {
 	cs.mutex.RLock()
+	name = cs.resolve(name)
 	res, ok := cs.index[name]
 	cs.mutex.RUnlock()
 	if ok {
@@ -47,14 +51,15 @@ func (cs *Registry) Get(name string) (*Commodity, error) {
 	}
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
-	// check if the commodity has been created in the meantime
+	// check if the commodity or an alias has been created in the meantime
+	name = cs.resolve(name)
 	if res, ok = cs.index[name]; ok {
 		return res, nil
 	}
 	if !isValidCommodity(name) {
 		return nil, fmt.Errorf("invalid commodity name %q", name)
 	}
-	res = &Commodity{name: name}
+	res = &Commodity{id: len(cs.index) + 1, name: name}
 	cs.insert(res)
 
 	return res, nil
@@ -76,18 +81,99 @@ func (cs *Registry) insert(c *Commodity) {
 	cs.index[c.name] = c
 }
 
+// Alias declares name as an alias of the commodity target, e.g. after a
+// ticker change, such that Get returns the target for both names. An alias
+// may be declared repeatedly, but not changed. If the commodity name has
+// been created before, it is resolved to the target, which takes over its
+// currency tag and metadata.
+func (cs *Registry) Alias(name, target string) error {
+	for _, n := range []string{name, target} {
+		if !isValidCommodity(n) {
+			return fmt.Errorf("invalid commodity name %q", n)
+		}
+	}
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if t, ok := cs.aliases[name]; ok {
+		if t != target {
+			return fmt.Errorf("commodity %s is already an alias of %s", name, t)
+		}
+		return nil
+	}
+	if cs.resolve(target) == name {
+		return fmt.Errorf("alias of commodity %s to %s forms a cycle", name, target)
+	}
+	cs.aliases[name] = target
+	old, ok := cs.index[name]
+	if !ok {
+		return nil
+	}
+	target = cs.resolve(target)
+	t, ok := cs.index[target]
+	if !ok {
+		t = &Commodity{id: len(cs.index) + 1, name: target}
+		cs.insert(t)
+	}
+	if old.IsCurrency() {
+		t.currency.Store(true)
+	}
+	old.mutex.RLock()
+	defer old.mutex.RUnlock()
+	for key, value := range old.metadata {
+		if err := t.setMetadata(key, value); err != nil {
+			return err
+		}
+	}
+	old.alias.Store(t)
+	return nil
+}
+
+// resolve follows the aliases of name. The caller must hold the mutex.
+func (cs *Registry) resolve(name string) string {
+	for {
+		target, ok := cs.aliases[name]
+		if !ok {
+			return name
+		}
+		name = target
+	}
+}
+
 // TagCurrency tags the commodity as a currency.
 func (cs *Registry) TagCurrency(name string) error {
 	commodity, err := cs.Get(name)
 	if err != nil {
 		return err
 	}
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
-	commodity.IsCurrency = true
+	commodity.currency.Store(true)
 	return nil
 }
 
+// SetMetadata sets the value of a metadata key of the commodity. A value may
+// be declared repeatedly, but not changed.
+func (cs *Registry) SetMetadata(name, key, value string) error {
+	commodity, err := cs.Get(name)
+	if err != nil {
+		return err
+	}
+	return commodity.setMetadata(key, value)
+}
+
+// All returns the commodities of the registry, without the commodities which
+// have been replaced by an alias.
+func (cs *Registry) All() []*Commodity {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	res := make([]*Commodity, 0, len(cs.index))
+	for name, c := range cs.index {
+		if _, ok := cs.aliases[name]; ok {
+			continue
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
 func isValidCommodity(s string) bool {
 	if len(s) == 0 {
 		return false
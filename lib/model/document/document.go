@@ -0,0 +1,49 @@
+package document
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Document represents a file, such as a receipt or a statement, linked to
+// an account.
+type Document struct {
+	Src     *syntax.Document
+	Date    time.Time
+	Account *account.Account
+	Path    string
+}
+
+func Create(reg *registry.Registry, d *syntax.Document) (*Document, error) {
+	account, err := reg.Accounts().Create(d.Account)
+	if err != nil {
+		return nil, err
+	}
+	date, err := d.Date.Parse()
+	if err != nil {
+		return nil, err
+	}
+	path, err := d.DocumentPath.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		Src:     d,
+		Date:    date,
+		Account: account,
+		Path:    path,
+	}, nil
+}
+
+// File returns the path of the document. Relative paths are resolved
+// against the directory of the journal file containing the directive.
+func (d *Document) File() string {
+	if filepath.IsAbs(d.Path) || d.Src == nil || d.Src.Range.Path == "" {
+		return d.Path
+	}
+	return filepath.Join(filepath.Dir(d.Src.Range.Path), d.Path)
+}
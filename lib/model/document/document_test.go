@@ -0,0 +1,27 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		journal, path, want string
+	}{
+		{journal: "", path: "a.pdf", want: "a.pdf"},
+		{journal: "journal.knut", path: "a.pdf", want: "a.pdf"},
+		{journal: "books/2023.knut", path: "statements/a.pdf", want: "books/statements/a.pdf"},
+		{journal: "books/2023.knut", path: "/tmp/a.pdf", want: "/tmp/a.pdf"},
+	}
+	for _, test := range tests {
+		d := &Document{
+			Src:  &syntax.Document{Range: syntax.Range{Path: test.journal}},
+			Path: test.path,
+		}
+		if got := d.File(); got != test.want {
+			t.Errorf("File() for %q in %q = %q, want %q", test.path, test.journal, got, test.want)
+		}
+	}
+}
@@ -2,18 +2,26 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/sboehler/knut/lib/common/cpr"
 	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/assertion"
 	cls "github.com/sboehler/knut/lib/model/close"
 	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/document"
+	"github.com/sboehler/knut/lib/model/note"
 	"github.com/sboehler/knut/lib/model/open"
 	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/price"
 	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/template"
+	"github.com/sboehler/knut/lib/model/timeclock"
 	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/model/use"
+	"github.com/sboehler/knut/lib/model/value"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/sourcegraph/conc/pool"
 )
@@ -25,9 +33,12 @@ type Posting = posting.Posting
 type Transaction = transaction.Transaction
 type Open = open.Open
 type Close = cls.Close
+type Note = note.Note
+type Document = document.Document
 type Price = price.Price
 type Assertion = assertion.Assertion
 type Balance = assertion.Balance
+type Value = value.Value
 
 type Registry = registry.Registry
 
@@ -36,37 +47,266 @@ type Directive any
 var (
 	_ Directive = (*assertion.Assertion)(nil)
 	_ Directive = (*cls.Close)(nil)
+	_ Directive = (*note.Note)(nil)
+	_ Directive = (*document.Document)(nil)
 	_ Directive = (*open.Open)(nil)
 	_ Directive = (*price.Price)(nil)
 	_ Directive = (*transaction.Transaction)(nil)
+	_ Directive = (*value.Value)(nil)
 )
 
+// Source returns the range of the syntax from which the directive, or the
+// posting or balance of a directive, has been created. Directives which are
+// generated while processing a journal, such as valuation and closing
+// transactions, and imported directives have no source.
+func Source(d any) (syntax.Range, bool) {
+	var src *syntax.Range
+	switch t := d.(type) {
+	case *Transaction:
+		if t.Src != nil {
+			src = &t.Src.Range
+		} else if t.Clock != nil {
+			src = &t.Clock.Range
+		}
+	case *Posting:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Open:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Close:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Note:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Document:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Price:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Assertion:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Balance:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	case *Value:
+		if t.Src != nil {
+			src = &t.Src.Range
+		}
+	}
+	if src == nil {
+		return syntax.Range{}, false
+	}
+	return *src, true
+}
+
 type Result struct {
 	Err        error
 	Directives []any
 }
 
+// FromStream creates the model directives of the given files. The account
+// types, templates and commodity aliases declared in a file are registered
+// before its directives are created. Files are processed concurrently, so a
+// file which uses an account type or a template declared in another file is
+// processed again after all files have been read. The directives of a file
+// may refer to a commodity which is declared an alias in a later file; see
+// Commodity.Resolve.
 func FromStream(reg *registry.Registry, inCh <-chan syntax.File) (<-chan []Directive, func(context.Context) error) {
 	return cpr.Produce(func(ctx context.Context, ch chan<- []Directive) error {
-		wg := pool.New().WithContext(ctx).WithCancelOnError().WithFirstError()
-		cpr.ForEach(ctx, inCh, func(input syntax.File) error {
+		var (
+			wg      = pool.New().WithContext(ctx).WithCancelOnError().WithFirstError()
+			mutex   sync.Mutex
+			pending []syntax.File
+		)
+		err := cpr.ForEach(ctx, inCh, func(input syntax.File) error {
+			if err := declareAccountTypes(reg, input); err != nil {
+				return err
+			}
+			if err := declareTemplates(reg, input); err != nil {
+				return err
+			}
+			if err := declareAliases(reg, input); err != nil {
+				return err
+			}
 			wg.Go(func(ctx context.Context) error {
-				var ds []Directive
-				for _, d := range input.Directives {
-					m, err := ParseDirective(reg, d)
-					if err != nil {
-						return err
-					}
-					ds = append(ds, m...)
+				ds, err := parseFile(reg, input)
+				if errors.As(err, new(account.UnknownTypeError)) || errors.As(err, new(template.UnknownError)) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					pending = append(pending, input)
+					return nil
+				}
+				if err != nil {
+					return err
 				}
 				return cpr.Push(ctx, ch, ds)
 			})
 			return nil
 		})
-		return wg.Wait()
+		if err := wg.Wait(); err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		for _, input := range pending {
+			ds, err := parseFile(reg, input)
+			if err != nil {
+				return err
+			}
+			if err := cpr.Push(ctx, ch, ds); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
+// parseFile creates the model directives of a file. Each check-out of a
+// timeclock is paired with the preceding check-in in the same file. A
+// check-in without a check-out is an error, as its session can't be booked.
+func parseFile(reg *registry.Registry, input syntax.File) ([]Directive, error) {
+	var (
+		ds []Directive
+		in *syntax.Clock
+	)
+	for _, d := range input.Directives {
+		if c, ok := d.Directive.(syntax.Clock); ok {
+			switch {
+			case c.In && in != nil:
+				return nil, syntax.Error{Range: c.Range, Message: fmt.Sprintf("check-in while checked in at %s", in.Range.Position())}
+			case c.In:
+				in = &c
+			case in == nil:
+				return nil, syntax.Error{Range: c.Range, Message: "check-out without check-in"}
+			default:
+				t, err := timeclock.Create(reg, in, &c)
+				if err != nil {
+					return nil, err
+				}
+				ds, in = append(ds, t), nil
+			}
+			continue
+		}
+		m, err := ParseDirective(reg, d)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, m...)
+	}
+	if in != nil {
+		return nil, syntax.Error{Range: in.Range, Message: "check-in without check-out"}
+	}
+	return ds, nil
+}
+
+func declareAccountTypes(reg *registry.Registry, input syntax.File) error {
+	for _, d := range input.Directives {
+		if t, ok := d.Directive.(syntax.AccountType); ok {
+			if err := declareAccountType(reg, &t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func declareTemplates(reg *registry.Registry, input syntax.File) error {
+	for _, d := range input.Directives {
+		if t, ok := d.Directive.(syntax.Template); ok {
+			if err := reg.Templates().Declare(&t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// declareAliases declares the commodity aliases of the file.
+func declareAliases(reg *registry.Registry, input syntax.File) error {
+	for _, d := range input.Directives {
+		if a, ok := d.Directive.(syntax.Alias); ok {
+			if err := declareAlias(reg, &a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func declareAlias(reg *registry.Registry, a *syntax.Alias) error {
+	if err := reg.Commodities().Alias(a.Commodity.Extract(), a.Target.Extract()); err != nil {
+		return syntax.Error{Range: a.Range, Message: err.Error()}
+	}
+	return nil
+}
+
+func declareAccountType(reg *registry.Registry, t *syntax.AccountType) error {
+	placement, err := account.ParsePlacement(t.Placement.Extract())
+	if err != nil {
+		return syntax.Error{Range: t.Placement, Message: err.Error()}
+	}
+	if _, err := reg.Accounts().DeclareType(t.Name.Extract(), placement); err != nil {
+		return syntax.Error{Range: t.Range, Message: err.Error()}
+	}
+	return nil
+}
+
+func declareCommodity(reg *registry.Registry, d *syntax.CommodityDeclaration) error {
+	for _, m := range d.Metadata {
+		value, err := m.Value.Parse()
+		if err != nil {
+			return err
+		}
+		if err := reg.Commodities().SetMetadata(d.Commodity.Extract(), m.Key.Extract(), value); err != nil {
+			return syntax.Error{Range: m.Range, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// withImpliedPrices returns the transactions, followed by a price for every
+// booking with an exchange rate.
+func withImpliedPrices(reg *registry.Registry, ts []*Transaction) ([]Directive, error) {
+	var (
+		res  []Directive
+		srcs = make(map[*syntax.Transaction]bool)
+	)
+	for _, t := range ts {
+		res = append(res, t)
+	}
+	for _, t := range ts {
+		// Accruals expand into several transactions with the same source.
+		if t.Src == nil || srcs[t.Src] {
+			continue
+		}
+		srcs[t.Src] = true
+		for _, b := range t.Src.Bookings {
+			if b.Rate.Empty() {
+				continue
+			}
+			p, err := price.Implied(reg, t.Src.Date, b)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
 func ParseDirective(reg *registry.Registry, w syntax.Directive) ([]Directive, error) {
 	switch d := w.Directive.(type) {
 	case syntax.Transaction:
@@ -74,11 +314,7 @@ func ParseDirective(reg *registry.Registry, w syntax.Directive) ([]Directive, er
 		if err != nil {
 			return nil, err
 		}
-		var res []Directive
-		for _, t := range ts {
-			res = append(res, t)
-		}
-		return res, nil
+		return withImpliedPrices(reg, ts)
 	case syntax.Open:
 		o, err := open.Create(reg, &d)
 		if err != nil {
@@ -91,6 +327,18 @@ func ParseDirective(reg *registry.Registry, w syntax.Directive) ([]Directive, er
 			return nil, err
 		}
 		return []Directive{o}, nil
+	case syntax.Note:
+		o, err := note.Create(reg, &d)
+		if err != nil {
+			return nil, err
+		}
+		return []Directive{o}, nil
+	case syntax.Document:
+		o, err := document.Create(reg, &d)
+		if err != nil {
+			return nil, err
+		}
+		return []Directive{o}, nil
 	case syntax.Assertion:
 		o, err := assertion.Create(reg, &d)
 		if err != nil {
@@ -103,8 +351,27 @@ func ParseDirective(reg *registry.Registry, w syntax.Directive) ([]Directive, er
 			return nil, err
 		}
 		return []Directive{o}, nil
-	case syntax.Include:
+	case syntax.Value:
+		o, err := value.Create(reg, &d)
+		if err != nil {
+			return nil, err
+		}
+		return []Directive{o}, nil
+	case syntax.Use:
+		ts, err := use.Create(reg, &d)
+		if err != nil {
+			return nil, err
+		}
+		return withImpliedPrices(reg, ts)
+	case syntax.Include, syntax.Template, syntax.Clock:
+		// Timeclock entries are paired when their file is parsed.
 		return nil, nil
+	case syntax.AccountType:
+		return nil, declareAccountType(reg, &d)
+	case syntax.CommodityDeclaration:
+		return nil, declareCommodity(reg, &d)
+	case syntax.Alias:
+		return nil, declareAlias(reg, &d)
 	}
 	return nil, fmt.Errorf("unknown directive: %T", w)
 }
@@ -0,0 +1,500 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+func parse(t *testing.T, text string) syntax.File {
+	t.Helper()
+	p := parser.New(text, "")
+	if err := p.Advance(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFromStreamAccountTypes(t *testing.T) {
+	tests := []struct {
+		desc  string
+		files []string
+		want  int
+		err   string
+	}{
+		{
+			desc: "declared in a later file",
+			files: []string{
+				"2023-01-01 open Memo:Guarantees\n2023-01-01 open Memo:Counter\n",
+				"accounttype Memo off-balance\n",
+			},
+			want: 2,
+		},
+		{
+			desc: "not declared",
+			files: []string{
+				"2023-01-01 open Memo:Guarantees\n",
+			},
+			err: "invalid account type Memo",
+		},
+		{
+			desc: "booked against an asset",
+			files: []string{
+				"accounttype Memo off-balance\n",
+				"2023-01-01 \"guarantee\"\nAssets:Bank Memo:Guarantees 100 CHF\n",
+			},
+			err: "off-balance accounts can only be booked against accounts of the same type",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			ch := make(chan syntax.File, len(test.files))
+			for _, text := range test.files {
+				ch <- parse(t, text)
+			}
+			close(ch)
+			resCh, worker := FromStream(reg, ch)
+			var (
+				got  int
+				done = make(chan struct{})
+			)
+			go func() {
+				defer close(done)
+				for ds := range resCh {
+					got += len(ds)
+				}
+			}()
+			err := worker(context.Background())
+			<-done
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("FromStream() returned error %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromStream() returned unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("FromStream() returned %d directives, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFromStreamTemplates(t *testing.T) {
+	const tpl = "template dividend(security, amount)\n\"Dividend $security $cost\"\nIncome:Dividends Assets:Broker $amount USD\n"
+	tests := []struct {
+		desc  string
+		files []string
+		want  []string
+		err   string
+	}{
+		{
+			desc: "declared in a later file",
+			files: []string{
+				"2023-01-01 use dividend(AAPL, 12.50)\n\n2023-02-01 use dividend(\"MSFT Corp\", 3)\n",
+				tpl,
+			},
+			want: []string{
+				"2023-01-01 Dividend AAPL $cost 12.5 USD",
+				"2023-02-01 Dividend MSFT Corp $cost 3 USD",
+			},
+		},
+		{
+			desc: "not declared",
+			files: []string{
+				"2023-01-01 use dividend(AAPL, 12.50)\n",
+			},
+			err: "unknown template dividend",
+		},
+		{
+			desc: "wrong number of arguments",
+			files: []string{
+				tpl + "\n2023-01-01 use dividend(AAPL)\n",
+			},
+			err: "template dividend has 2 parameters, got 1 arguments",
+		},
+		{
+			desc: "invalid argument",
+			files: []string{
+				tpl + "\n2023-01-01 use dividend(AAPL, twelve)\n",
+			},
+			err: "instantiating template dividend",
+		},
+		{
+			desc: "unknown parameter",
+			files: []string{
+				"template dividend(amount)\n\"Dividend\"\nIncome:Dividends Assets:Broker $amount $currency\n\n2023-01-01 use dividend(1)\n",
+			},
+			err: "unknown parameter $currency",
+		},
+		{
+			desc: "declared twice",
+			files: []string{
+				tpl,
+				tpl,
+			},
+			err: "template dividend is already declared",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			ch := make(chan syntax.File, len(test.files))
+			for _, text := range test.files {
+				ch <- parse(t, text)
+			}
+			close(ch)
+			resCh, worker := FromStream(reg, ch)
+			var (
+				got  []string
+				done = make(chan struct{})
+			)
+			go func() {
+				defer close(done)
+				for ds := range resCh {
+					for _, d := range ds {
+						trx := d.(*Transaction)
+						p := trx.Postings[0]
+						got = append(got, fmt.Sprintf("%s %s %s %s", trx.Date.Format("2006-01-02"), trx.Description, p.Quantity.Abs(), p.Commodity.Name()))
+					}
+				}
+			}()
+			err := worker(context.Background())
+			<-done
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("FromStream() returned error %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromStream() returned unexpected error: %v", err)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("FromStream() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFromStreamAliases(t *testing.T) {
+	const (
+		alias = "alias commodity TWTR X\n"
+		trx   = "2022-01-01 \"buy\"\nAssets:Cash Assets:Broker 10 TWTR\n\n2023-01-01 price TWTR 40 USD\n"
+	)
+	tests := []struct {
+		desc  string
+		files []string
+		want  []string
+		err   string
+	}{
+		{
+			desc:  "declared first",
+			files: []string{alias, trx},
+			want:  []string{"price X", "transaction X"},
+		},
+		{
+			desc:  "declared in a later file",
+			files: []string{trx, alias},
+			want:  []string{"price X", "transaction X"},
+		},
+		{
+			desc:  "chained",
+			files: []string{trx, "alias commodity X Y\n", alias},
+			want:  []string{"price Y", "transaction Y"},
+		},
+		{
+			desc:  "conflicting",
+			files: []string{alias, "alias commodity TWTR Y\n"},
+			err:   "commodity TWTR is already an alias of X",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			ch := make(chan syntax.File, len(test.files))
+			for _, text := range test.files {
+				ch <- parse(t, text)
+			}
+			close(ch)
+			resCh, worker := FromStream(reg, ch)
+			var (
+				ds   []Directive
+				done = make(chan struct{})
+			)
+			go func() {
+				defer close(done)
+				for res := range resCh {
+					ds = append(ds, res...)
+				}
+			}()
+			err := worker(context.Background())
+			<-done
+			var got []string
+			for _, d := range ds {
+				switch d := d.(type) {
+				case *Transaction:
+					got = append(got, "transaction "+d.Postings[0].Commodity.Resolve().Name())
+				case *Price:
+					got = append(got, "price "+d.Commodity.Resolve().Name())
+				}
+			}
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("FromStream() returned error %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromStream() returned unexpected error: %v", err)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("FromStream() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTimeclock(t *testing.T) {
+	tests := []struct {
+		desc string
+		text string
+		want []string
+		err  string
+	}{
+		{
+			desc: "sessions",
+			text: "i 2023-03-01 09:00 Project:X\no 2023-03-01 12:30\ni 2023-03-01 13:00 Project:Y\no 2023-03-01 13:20\n",
+			want: []string{"2023-03-01 Project:X 3.5 h", "2023-03-01 Project:Y 0.333333 h"},
+		},
+		{
+			desc: "overnight",
+			text: "i 2023-03-01 22:00 Project:X\no 2023-03-02 01:00\n",
+			want: []string{"2023-03-01 Project:X 3 h"},
+		},
+		{
+			desc: "check-in without check-out",
+			text: "i 2023-03-01 09:00 Project:X\no 2023-03-01 10:00\ni 2023-03-02 09:00 Project:X\n",
+			err:  "check-in without check-out",
+		},
+		{
+			desc: "check-out before check-in",
+			text: "i 2023-03-01 09:00 Project:X\no 2023-03-01 08:00\n",
+			err:  "check-out must be after the check-in",
+		},
+		{
+			desc: "check-out without check-in",
+			text: "o 2023-03-01 08:00\n",
+			err:  "check-out without check-in",
+		},
+		{
+			desc: "check-in twice",
+			text: "i 2023-03-01 09:00 Project:X\ni 2023-03-01 10:00 Project:Y\n",
+			err:  "check-in while checked in",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			if _, err := reg.Accounts().DeclareType("Project", account.AL); err != nil {
+				t.Fatal(err)
+			}
+
+			ds, err := parseFile(reg, parse(t, test.text))
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("parseFile() returned error %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFile() returned unexpected error: %v", err)
+			}
+			var got []string
+			for _, d := range ds {
+				trx := d.(*Transaction)
+				p := trx.Postings[1]
+				if p.Other.Name() != "Equity:Timeclock" {
+					t.Errorf("got counterpart %s, want Equity:Timeclock", p.Other.Name())
+				}
+				got = append(got, fmt.Sprintf("%s %s %s %s", trx.Date.Format("2006-01-02"), p.Account.Name(), p.Quantity, p.Commodity.Name()))
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("parseFile() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommodityDeclaration(t *testing.T) {
+	tests := []struct {
+		desc string
+		text string
+		want map[string]string
+		err  string
+	}{
+		{
+			desc: "metadata",
+			text: "commodity VT\n  asset-class: \"equity\"\n  region: \"world\"\n",
+			want: map[string]string{"asset-class": "equity", "region": "world"},
+		},
+		{
+			desc: "repeated declaration",
+			text: "commodity VT\n  asset-class: \"equity\"\n\ncommodity VT\n  asset-class: \"equity\"\n",
+			want: map[string]string{"asset-class": "equity"},
+		},
+		{
+			desc: "conflicting declaration",
+			text: "commodity VT\n  asset-class: \"equity\"\n\ncommodity VT\n  asset-class: \"bonds\"\n",
+			err:  `commodity VT has conflicting values "equity" and "bonds" for asset-class`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			var err error
+			for _, d := range parse(t, test.text).Directives {
+				if _, err = ParseDirective(reg, d); err != nil {
+					break
+				}
+			}
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ParseDirective() returned error %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDirective() returned unexpected error: %v", err)
+			}
+			vt := reg.Commodities().MustGet("VT")
+			for key, want := range test.want {
+				if got, ok := vt.Metadata(key); !ok || got != want {
+					t.Errorf("Metadata(%q) = %q, %t, want %q", key, got, ok, want)
+				}
+			}
+			groups, err := commodity.GroupsByMetadata(reg.Commodities(), "asset-class")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := groups.Group(reg.Commodities().MustGet("USD")); got != commodity.OtherGroup {
+				t.Errorf("Group(USD) = %s, want %s", got, commodity.OtherGroup)
+			}
+			if got := groups.Group(vt); got != test.want["asset-class"] {
+				t.Errorf("Group(VT) = %s, want %s", got, test.want["asset-class"])
+			}
+		})
+	}
+}
+
+func TestImpliedPrices(t *testing.T) {
+	reg := registry.New()
+	text := "2023-01-01 \"Exchange\"\nAssets:EUR Assets:USD 100 EUR @@ 108 USD\nAssets:Bank Expenses:Food 1 CHF\n"
+
+	ds, err := ParseDirective(reg, parse(t, text).Directives[0])
+
+	if err != nil {
+		t.Fatalf("ParseDirective() returned unexpected error: %v", err)
+	}
+	if len(ds) != 2 {
+		t.Fatalf("ParseDirective() returned %d directives, want 2", len(ds))
+	}
+	if _, ok := ds[0].(*Transaction); !ok {
+		t.Errorf("ParseDirective() returned %T, want a transaction", ds[0])
+	}
+	p, ok := ds[1].(*Price)
+	if !ok {
+		t.Fatalf("ParseDirective() returned %T, want a price", ds[1])
+	}
+	if got := fmt.Sprintf("%s %s %s", p.Commodity.Name(), p.Price, p.Target.Name()); got != "EUR 1.08 USD" {
+		t.Errorf("ParseDirective() returned price %s, want EUR 1.08 USD", got)
+	}
+}
+
+func TestEscapedDescription(t *testing.T) {
+	reg := registry.New()
+	text := "2023-01-01 \"say \\\"hi\\\"\\tto \\\\ caf\\u00e9\"\nAssets:Bank Expenses:Food 1 CHF\n"
+	want := "say \"hi\"\tto \\ café"
+
+	for i := 0; i < 2; i++ {
+		ds, err := ParseDirective(reg, parse(t, text).Directives[0])
+		if err != nil {
+			t.Fatalf("ParseDirective() returned unexpected error: %v", err)
+		}
+		if got := ds[0].(*Transaction).Description; got != want {
+			t.Fatalf("Description = %q, want %q", got, want)
+		}
+		// The escaped description must parse to the same description.
+		text = "2023-01-01 \"" + syntax.Escape(want) + "\"\nAssets:Bank Expenses:Food 1 CHF\n"
+	}
+}
+
+func TestSource(t *testing.T) {
+	reg := registry.New()
+	text := "2020-01-01 open Assets:Cash\n\n@accrue monthly 2020-01-01 2020-02-01 Assets:Accrual\n2020-01-15 \"tax\"\nAssets:Cash Expenses:Tax 20 CHF\n"
+	p := parser.New(text, "journal.knut")
+	if err := p.Advance(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range f.Directives {
+		ds, err := ParseDirective(reg, d)
+		if err != nil {
+			t.Fatalf("ParseDirective() returned unexpected error: %v", err)
+		}
+		for _, d := range ds {
+			src, ok := Source(d)
+			if !ok {
+				t.Fatalf("Source(%v) returned no source", d)
+			}
+			got = append(got, src.Position())
+			if trx, ok := d.(*Transaction); ok {
+				for _, p := range trx.Postings {
+					src, ok := Source(p)
+					if !ok {
+						t.Fatalf("Source(%v) returned no source", p)
+					}
+					got = append(got, src.Position())
+				}
+			}
+		}
+	}
+	if _, ok := Source(&Transaction{}); ok {
+		t.Errorf("Source() of a generated transaction returned a source")
+	}
+	want := []string{"journal.knut:1:1"}
+	for i := 0; i < 3; i++ {
+		// The accrual and its two monthly installments.
+		want = append(want, "journal.knut:3:1", "journal.knut:5:1", "journal.knut:5:1")
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Source() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,38 @@
+package note
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Note represents a note attached to an account.
+type Note struct {
+	Src     *syntax.Note
+	Date    time.Time
+	Account *account.Account
+	Text    string
+}
+
+func Create(reg *registry.Registry, n *syntax.Note) (*Note, error) {
+	account, err := reg.Accounts().Create(n.Account)
+	if err != nil {
+		return nil, err
+	}
+	date, err := n.Date.Parse()
+	if err != nil {
+		return nil, err
+	}
+	text, err := n.Text.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Note{
+		Src:     n,
+		Date:    date,
+		Account: account,
+		Text:    text,
+	}, nil
+}
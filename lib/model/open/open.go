@@ -1,6 +1,7 @@
 package open
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/sboehler/knut/lib/model/account"
@@ -13,10 +14,14 @@ type Open struct {
 	Src     *syntax.Open
 	Date    time.Time
 	Account *account.Account
+
+	// Valuation is the account which receives the valuation gains and
+	// losses of Account, or nil for the default.
+	Valuation *account.Account
 }
 
 func Create(reg *registry.Registry, o *syntax.Open) (*Open, error) {
-	account, err := reg.Accounts().Create(o.Account)
+	acc, err := reg.Accounts().Create(o.Account)
 	if err != nil {
 		return nil, err
 	}
@@ -24,9 +29,29 @@ func Create(reg *registry.Registry, o *syntax.Open) (*Open, error) {
 	if err != nil {
 		return nil, err
 	}
+	var valuation *account.Account
+	if !o.Valuation.Empty() {
+		if !acc.IsAL() {
+			return nil, syntax.Error{
+				Message: fmt.Sprintf("account %s is not an asset or liability account and can't have a valuation account", acc),
+				Range:   o.Valuation.Range,
+			}
+		}
+		if valuation, err = reg.Accounts().Create(o.Valuation); err != nil {
+			return nil, err
+		}
+		if valuation.IsAL() {
+			return nil, syntax.Error{
+				Message: fmt.Sprintf("valuation account %s must be an income or expense account", valuation),
+				Range:   o.Valuation.Range,
+			}
+		}
+		reg.Accounts().SetValuationAccount(acc, valuation)
+	}
 	return &Open{
-		Src:     o,
-		Date:    date,
-		Account: account,
+		Src:       o,
+		Date:      date,
+		Account:   acc,
+		Valuation: valuation,
 	}, nil
 }
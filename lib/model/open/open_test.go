@@ -0,0 +1,52 @@
+package open
+
+import (
+	"testing"
+
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+func TestCreateValuation(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		err  bool
+	}{
+		{text: "2022-03-03 open Assets:Broker", want: "Income:Broker"},
+		{text: "2022-03-03 open Assets:Broker valuation Income:Trading", want: "Income:Trading"},
+		{text: "2022-03-03 open Liabilities:Loan valuation Expenses:FX", want: "Expenses:FX"},
+		{text: "2022-03-03 open Assets:Broker valuation Assets:Other", err: true},
+		{text: "2022-03-03 open Expenses:Food valuation Income:Trading", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			reg := registry.New()
+			p := parser.New(test.text+"\n", "")
+			if err := p.Advance(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := p.ParseFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := f.Directives[0].Directive.(syntax.Open)
+
+			got, err := Create(reg, &o)
+
+			if test.err {
+				if err == nil {
+					t.Fatalf("Create() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() returned unexpected error: %v", err)
+			}
+			if v := reg.Accounts().ValuationAccountFor(got.Account); v.Name() != test.want {
+				t.Errorf("ValuationAccountFor(%s) = %s, want %s", got.Account, v, test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package posting
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/sboehler/knut/lib/common/compare"
 	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/commodity"
@@ -15,6 +18,20 @@ type Posting struct {
 	Quantity, Value decimal.Decimal
 	Account, Other  *account.Account
 	Commodity       *commodity.Commodity
+	Comment         string
+
+	// Cost is the optional cost of a single unit of Commodity, expressed in
+	// CostCommodity.
+	Cost          decimal.Decimal
+	CostCommodity *commodity.Commodity
+
+	// LotDate is the optional date of the lot, which defaults to the date of
+	// the transaction.
+	LotDate time.Time
+
+	// Virtual is true for a posting without a counterpart. Account and Other
+	// are the same account.
+	Virtual bool
 }
 
 type Builder struct {
@@ -22,6 +39,10 @@ type Builder struct {
 	Quantity, Value decimal.Decimal
 	Credit, Debit   *account.Account
 	Commodity       *commodity.Commodity
+	Comment         string
+	Cost            decimal.Decimal
+	CostCommodity   *commodity.Commodity
+	LotDate         time.Time
 }
 
 func (pb Builder) Build() []*Posting {
@@ -30,20 +51,28 @@ func (pb Builder) Build() []*Posting {
 	}
 	return []*Posting{
 		{
-			Src:       pb.Src,
-			Account:   pb.Credit,
-			Other:     pb.Debit,
-			Commodity: pb.Commodity,
-			Quantity:  pb.Quantity.Neg(),
-			Value:     pb.Value.Neg(),
+			Src:           pb.Src,
+			Account:       pb.Credit,
+			Other:         pb.Debit,
+			Commodity:     pb.Commodity,
+			Quantity:      pb.Quantity.Neg(),
+			Value:         pb.Value.Neg(),
+			Comment:       pb.Comment,
+			Cost:          pb.Cost,
+			CostCommodity: pb.CostCommodity,
+			LotDate:       pb.LotDate,
 		},
 		{
-			Src:       pb.Src,
-			Account:   pb.Debit,
-			Other:     pb.Credit,
-			Commodity: pb.Commodity,
-			Quantity:  pb.Quantity,
-			Value:     pb.Value,
+			Src:           pb.Src,
+			Account:       pb.Debit,
+			Other:         pb.Credit,
+			Commodity:     pb.Commodity,
+			Quantity:      pb.Quantity,
+			Value:         pb.Value,
+			Comment:       pb.Comment,
+			Cost:          pb.Cost,
+			CostCommodity: pb.CostCommodity,
+			LotDate:       pb.LotDate,
 		},
 	}
 }
@@ -75,8 +104,19 @@ func Compare(p, p2 *Posting) compare.Order {
 }
 
 func Create(reg *registry.Registry, bs []syntax.Booking) ([]*Posting, error) {
-	var builder Builders
+	var (
+		builder Builders
+		virtual []*Posting
+	)
 	for i, b := range bs {
+		if !b.Virtual.Empty() {
+			p, err := createVirtual(reg, &bs[i])
+			if err != nil {
+				return nil, err
+			}
+			virtual = append(virtual, p)
+			continue
+		}
 		credit, err := reg.Accounts().Create(b.Credit)
 		if err != nil {
 			return nil, err
@@ -85,6 +125,12 @@ func Create(reg *registry.Registry, bs []syntax.Booking) ([]*Posting, error) {
 		if err != nil {
 			return nil, err
 		}
+		if credit.IsEnvelope() != debit.IsEnvelope() {
+			return nil, syntax.Error{Range: b.Range, Message: "envelope accounts can only be booked against envelope accounts"}
+		}
+		if (credit.IsOffBalance() || debit.IsOffBalance()) && credit.Type() != debit.Type() {
+			return nil, syntax.Error{Range: b.Range, Message: fmt.Sprintf("off-balance accounts can only be booked against accounts of the same type, got %s and %s", credit, debit)}
+		}
 		amount, err := decimal.NewFromString(b.Quantity.Extract())
 		if err != nil {
 			return nil, syntax.Error{Range: b.Quantity.Range, Message: "parsing amount", Wrapped: err}
@@ -93,13 +139,141 @@ func Create(reg *registry.Registry, bs []syntax.Booking) ([]*Posting, error) {
 		if err != nil {
 			return nil, err
 		}
+		cost, costCommodity, lotDate, err := createCost(reg, b.Cost, amount)
+		if err != nil {
+			return nil, err
+		}
+		if b.Rate.Empty() {
+			builder = append(builder, Builder{
+				Src:           &bs[i],
+				Credit:        credit,
+				Debit:         debit,
+				Quantity:      amount,
+				Commodity:     commodity,
+				Comment:       b.Comment.Extract(),
+				Cost:          cost,
+				CostCommodity: costCommodity,
+				LotDate:       lotDate,
+			})
+			continue
+		}
+		// A booking with an exchange rate is split into two bookings
+		// through the conversion account, one in each commodity.
+		rate, target, err := CreateRate(reg, b.Rate, amount)
+		if err != nil {
+			return nil, err
+		}
+		conversion := reg.Accounts().ConversionAccount()
 		builder = append(builder, Builder{
+			Src:           &bs[i],
+			Credit:        credit,
+			Debit:         conversion,
+			Quantity:      amount,
+			Commodity:     commodity,
+			Comment:       b.Comment.Extract(),
+			Cost:          cost,
+			CostCommodity: costCommodity,
+			LotDate:       lotDate,
+		}, Builder{
 			Src:       &bs[i],
-			Credit:    credit,
+			Credit:    conversion,
 			Debit:     debit,
-			Quantity:  amount,
-			Commodity: commodity,
+			Quantity:  amount.Mul(rate),
+			Commodity: target,
+			Comment:   b.Comment.Extract(),
 		})
 	}
-	return builder.Build(), nil
+	// Virtual postings come last, such that the other postings remain in
+	// pairs of credit and debit.
+	return append(builder.Build(), virtual...), nil
+}
+
+// CreateRate converts an exchange rate annotation into the rate per unit and
+// the target commodity.
+func CreateRate(reg *registry.Registry, r syntax.Rate, quantity decimal.Decimal) (decimal.Decimal, *commodity.Commodity, error) {
+	rate, err := r.Amount.Parse()
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+	if !rate.IsPositive() {
+		return decimal.Zero, nil, syntax.Error{Range: r.Range, Message: "rate must be positive"}
+	}
+	com, err := reg.Commodities().Create(r.Commodity)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+	if r.Total {
+		if quantity.IsZero() {
+			return decimal.Zero, nil, syntax.Error{Range: r.Range, Message: "total rate requires a nonzero quantity"}
+		}
+		rate = rate.Div(quantity.Abs())
+	}
+	return rate, com, nil
+}
+
+// createVirtual creates a virtual posting.
+func createVirtual(reg *registry.Registry, b *syntax.Booking) (*Posting, error) {
+	if !b.Rate.Empty() {
+		return nil, syntax.Error{Range: b.Rate.Range, Message: "virtual bookings cannot have a rate"}
+	}
+	acc, err := reg.Accounts().Create(b.Virtual)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := decimal.NewFromString(b.Quantity.Extract())
+	if err != nil {
+		return nil, syntax.Error{Range: b.Quantity.Range, Message: "parsing amount", Wrapped: err}
+	}
+	commodity, err := reg.Commodities().Create(b.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	cost, costCommodity, lotDate, err := createCost(reg, b.Cost, amount)
+	if err != nil {
+		return nil, err
+	}
+	return &Posting{
+		Src:           b,
+		Account:       acc,
+		Other:         acc,
+		Quantity:      amount,
+		Commodity:     commodity,
+		Comment:       b.Comment.Extract(),
+		Cost:          cost,
+		CostCommodity: costCommodity,
+		LotDate:       lotDate,
+		Virtual:       true,
+	}, nil
+}
+
+// createCost converts a cost annotation into the cost of a single unit and
+// the optional date of the lot.
+func createCost(reg *registry.Registry, c syntax.Cost, quantity decimal.Decimal) (decimal.Decimal, *commodity.Commodity, time.Time, error) {
+	if c.Empty() {
+		return decimal.Zero, nil, time.Time{}, nil
+	}
+	cost, err := c.Amount.Parse()
+	if err != nil {
+		return decimal.Zero, nil, time.Time{}, err
+	}
+	if cost.IsNegative() {
+		return decimal.Zero, nil, time.Time{}, syntax.Error{Range: c.Range, Message: "cost must not be negative"}
+	}
+	com, err := reg.Commodities().Create(c.Commodity)
+	if err != nil {
+		return decimal.Zero, nil, time.Time{}, err
+	}
+	if c.Total {
+		if quantity.IsZero() {
+			return decimal.Zero, nil, time.Time{}, syntax.Error{Range: c.Range, Message: "total cost requires a nonzero quantity"}
+		}
+		cost = cost.Div(quantity.Abs())
+	}
+	var date time.Time
+	if !c.Date.Empty() {
+		if date, err = c.Date.Parse(); err != nil {
+			return decimal.Zero, nil, time.Time{}, err
+		}
+	}
+	return cost, com, date, nil
 }
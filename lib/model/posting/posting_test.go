@@ -0,0 +1,186 @@
+package posting
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+	"github.com/shopspring/decimal"
+)
+
+func TestCreateCost(t *testing.T) {
+	tests := []struct {
+		booking string
+		want    decimal.Decimal
+		err     bool
+	}{
+		{booking: "Assets:A Assets:B 4 AAPL {150 USD}", want: decimal.NewFromInt(150)},
+		{booking: "Assets:A Assets:B 4 AAPL {{600 USD}}", want: decimal.NewFromInt(150)},
+		{booking: "Assets:A Assets:B -4 AAPL {{600 USD}}", want: decimal.NewFromInt(150)},
+		{booking: "Assets:A Assets:B 0 AAPL {{600 USD}}", err: true},
+		{booking: "Assets:A Assets:B 4 AAPL {-150 USD}", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.booking, func(t *testing.T) {
+			reg := registry.New()
+			text := strings.Join([]string{`2022-03-03 "Buy"`, test.booking, ""}, "\n")
+			p := parser.New(text, "")
+			if err := p.Advance(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := p.ParseFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			trx := f.Directives[0].Directive.(syntax.Transaction)
+
+			got, err := Create(reg, trx.Bookings)
+
+			if test.err {
+				if err == nil {
+					t.Fatalf("Create() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() returned unexpected error: %v", err)
+			}
+			for _, p := range got {
+				if !p.Cost.Equal(test.want) || p.CostCommodity.Name() != "USD" {
+					t.Errorf("Create() returned cost %s %s, want %s USD", p.Cost, p.CostCommodity.Name(), test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateEnvelopes(t *testing.T) {
+	tests := []struct {
+		booking string
+		err     bool
+	}{
+		{booking: "Envelopes:A Envelopes:B 10 USD"},
+		{booking: "Assets:A Envelopes:B 10 USD", err: true},
+		{booking: "Envelopes:A Expenses:B 10 USD", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.booking, func(t *testing.T) {
+			reg := registry.New()
+			text := strings.Join([]string{`2022-03-03 "Transfer"`, test.booking, ""}, "\n")
+			p := parser.New(text, "")
+			if err := p.Advance(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := p.ParseFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			trx := f.Directives[0].Directive.(syntax.Transaction)
+
+			_, err = Create(reg, trx.Bookings)
+
+			if test.err != (err != nil) {
+				t.Errorf("Create() returned error %v, want error: %t", err, test.err)
+			}
+		})
+	}
+}
+
+func TestCreateVirtual(t *testing.T) {
+	reg := registry.New()
+	text := strings.Join([]string{
+		`2022-03-03 "Groceries"`,
+		`Assets:Bank Expenses:Food 10 USD`,
+		`(Envelopes:Food) -10 USD`,
+		`Assets:Bank Expenses:Misc 5 USD`,
+		"",
+	}, "\n")
+	p := parser.New(text, "")
+	if err := p.Advance(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trx := f.Directives[0].Directive.(syntax.Transaction)
+
+	got, err := Create(reg, trx.Bookings)
+
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("Create() returned %d postings, want 5", len(got))
+	}
+	for _, p := range got[:4] {
+		if p.Virtual {
+			t.Errorf("Create() returned virtual posting %v, want a regular posting", p)
+		}
+	}
+	v := got[4]
+	if !v.Virtual || v.Account.Name() != "Envelopes:Food" || v.Other != v.Account || !v.Quantity.Equal(decimal.NewFromInt(-10)) {
+		t.Errorf("Create() returned %v, want a virtual posting of -10 USD to Envelopes:Food", v)
+	}
+}
+
+func TestCreateRate(t *testing.T) {
+	tests := []struct {
+		booking string
+		want    decimal.Decimal
+		err     bool
+	}{
+		{booking: "Assets:EUR Assets:USD 100 EUR @ 1.08 USD", want: decimal.NewFromInt(108)},
+		{booking: "Assets:EUR Assets:USD 100 EUR @@ 108 USD", want: decimal.NewFromInt(108)},
+		{booking: "Assets:EUR Assets:USD -100 EUR @@ 108 USD", want: decimal.NewFromInt(-108)},
+		{booking: "Assets:EUR Assets:USD 100 EUR @ 0 USD", err: true},
+		{booking: "Assets:EUR Assets:USD 0 EUR @@ 108 USD", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.booking, func(t *testing.T) {
+			reg := registry.New()
+			text := strings.Join([]string{`2022-03-03 "Exchange"`, test.booking, ""}, "\n")
+			p := parser.New(text, "")
+			if err := p.Advance(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := p.ParseFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			trx := f.Directives[0].Directive.(syntax.Transaction)
+
+			got, err := Create(reg, trx.Bookings)
+
+			if test.err {
+				if err == nil {
+					t.Fatalf("Create() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() returned unexpected error: %v", err)
+			}
+			if len(got) != 4 {
+				t.Fatalf("Create() returned %d postings, want 4", len(got))
+			}
+			var usd decimal.Decimal
+			for _, p := range got {
+				if p.Account.Name() == "Assets:USD" {
+					if p.Commodity.Name() != "USD" {
+						t.Errorf("Create() booked %s to Assets:USD, want USD", p.Commodity.Name())
+					}
+					usd = usd.Add(p.Quantity)
+				}
+				if p.Account.Name() == "Assets:EUR" && p.Commodity.Name() != "EUR" {
+					t.Errorf("Create() booked %s to Assets:EUR, want EUR", p.Commodity.Name())
+				}
+			}
+			if !usd.Equal(test.want) {
+				t.Errorf("Create() booked %s USD to Assets:USD, want %s USD", usd, test.want)
+			}
+		})
+	}
+}
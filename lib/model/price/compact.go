@@ -0,0 +1,90 @@
+package price
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/shopspring/decimal"
+)
+
+// pair is an unordered pair of commodities, with the commodities in the
+// order of commodity.Compare.
+type pair struct {
+	commodity, target *commodity.Commodity
+}
+
+func pairOf(p *Price) pair {
+	if commodity.Compare(p.Commodity, p.Target) == compare.Greater {
+		return pair{p.Target, p.Commodity}
+	}
+	return pair{p.Commodity, p.Target}
+}
+
+// Compact removes redundant prices. As Prices.Insert stores a price in both
+// directions, prices of a commodity pair are grouped regardless of their
+// direction. Prices for the same pair and date are merged (the last one
+// wins), and a price is dropped if its relative deviation from the
+// previously retained price of the same pair does not exceed tolerance. A
+// tolerance of zero only removes prices which leave the stored prices in both
+// directions unchanged, which leaves valuation results unchanged. The result
+// is sorted by date.
+func Compact(prices []*Price, tolerance decimal.Decimal) []*Price {
+	byPair := make(map[pair]map[time.Time]*Price)
+	for _, p := range prices {
+		byDate := dict.GetDefault(byPair, pairOf(p), func() map[time.Time]*Price {
+			return make(map[time.Time]*Price)
+		})
+		byDate[p.Date] = p
+	}
+	var res []*Price
+	for pr, byDate := range byPair {
+		var lastPrice, lastInverse decimal.Decimal
+		for i, p := range dict.SortedValues(byDate, compareDate) {
+			price, inverse := stored(pr, p)
+			if i > 0 && (price.Equal(lastPrice) && inverse.Equal(lastInverse) || withinTolerance(lastPrice, price, tolerance)) {
+				continue
+			}
+			res = append(res, p)
+			lastPrice, lastInverse = price, inverse
+		}
+	}
+	compare.Sort(res, Compare)
+	return res
+}
+
+// stored returns the prices which Prices.Insert stores for p, in the
+// direction of the pair and in the inverse direction.
+func stored(pr pair, p *Price) (decimal.Decimal, decimal.Decimal) {
+	var inverse decimal.Decimal
+	if !p.Price.IsZero() {
+		inverse = one.Div(p.Price).Truncate(8)
+	}
+	if p.Commodity == pr.commodity {
+		return p.Price, inverse
+	}
+	return inverse, p.Price
+}
+
+func withinTolerance(prev, cur, tolerance decimal.Decimal) bool {
+	if tolerance.IsZero() || prev.IsZero() {
+		return false
+	}
+	return cur.Sub(prev).Div(prev).Abs().LessThanOrEqual(tolerance)
+}
+
+func compareDate(p1, p2 *Price) compare.Order {
+	return compare.Time(p1.Date, p2.Date)
+}
+
+// Compare establishes an order on prices by date, commodity and target.
+func Compare(p1, p2 *Price) compare.Order {
+	if o := compare.Time(p1.Date, p2.Date); o != compare.Equal {
+		return o
+	}
+	if o := commodity.Compare(p1.Commodity, p2.Commodity); o != compare.Equal {
+		return o
+	}
+	return commodity.Compare(p1.Target, p2.Target)
+}
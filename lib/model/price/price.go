@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/registry"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/shopspring/decimal"
@@ -18,6 +19,33 @@ type Price struct {
 	Target    *commodity.Commodity
 }
 
+// Implied creates the price implied by the exchange rate of a booking, such
+// as `EUR 1.08 USD` for `100 EUR @ 1.08 USD`.
+func Implied(reg *registry.Registry, d syntax.Date, b syntax.Booking) (*Price, error) {
+	date, err := d.Parse()
+	if err != nil {
+		return nil, err
+	}
+	quantity, err := b.Quantity.Parse()
+	if err != nil {
+		return nil, err
+	}
+	com, err := reg.Commodities().Create(b.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	rate, tgt, err := posting.CreateRate(reg, b.Rate, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return &Price{
+		Date:      date,
+		Commodity: com,
+		Price:     rate,
+		Target:    tgt,
+	}, nil
+}
+
 func Create(reg *registry.Registry, p *syntax.Price) (*Price, error) {
 	date, err := p.Date.Parse()
 	if err != nil {
@@ -16,8 +16,11 @@ package price
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/rounding"
 	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/shopspring/decimal"
 )
@@ -44,23 +47,127 @@ func (ps Prices) addPrice(target, commodity *commodity.Commodity, price decimal.
 	dict.GetDefault(ps, target, newNormalizedPrices)[commodity] = price
 }
 
-// Normalize creates a normalized price map for the given commodity.
-func (ps Prices) Normalize(t *commodity.Commodity) NormalizedPrices {
-	res := NormalizedPrices{t: one}
-	ps.normalize(t, res)
+// Clone returns a copy of the prices, which is not affected by later
+// inserts.
+func (ps Prices) Clone() Prices {
+	res := make(Prices, len(ps))
+	for target, np := range ps {
+		res[target] = maps.Clone(np)
+	}
+	return res
+}
+
+// Normalize creates a normalized price map for the given commodity. Prices
+// are derived along the shortest chain of prices. The chains go through the
+// commodities in via if possible, in the given order of preference. Ties are
+// broken by commodity name, such that the result does not depend on the order
+// of insertion. Before, prices were derived along the first chain found in a
+// depth-first search in map order, so commodities which can be priced along
+// several chains may be valued differently than in earlier versions.
+func (ps Prices) Normalize(t *commodity.Commodity, via ...*commodity.Commodity) NormalizedPrices {
+	res, _ := ps.normalize(t, via)
 	return res
 }
 
-// normalize recursively computes prices by traversing the price graph.
-// res must already contain a price for c.
-func (ps Prices) normalize(c *commodity.Commodity, res NormalizedPrices) {
-	for neighbor, price := range ps[c] {
-		if _, done := res[neighbor]; done {
+// Step is a step of a chain of prices.
+type Step struct {
+	// Commodity is converted to Target at Price, in units of Target per
+	// unit of Commodity.
+	Commodity, Target *commodity.Commodity
+	Price             decimal.Decimal
+}
+
+// Path returns the chain of prices which Normalize uses to price c in t.
+func (ps Prices) Path(t, c *commodity.Commodity, via ...*commodity.Commodity) ([]Step, error) {
+	res, parents := ps.normalize(t, via)
+	if _, ok := res[c]; !ok {
+		return nil, fmt.Errorf("no price found for %s in %s", c.Name(), t.Name())
+	}
+	var steps []Step
+	for c != t {
+		p := parents[c]
+		steps = append(steps, Step{Commodity: c, Target: p, Price: ps[p][c]})
+		c = p
+	}
+	return steps, nil
+}
+
+// normalize computes the prices in t with a breadth-first search of the price
+// graph, and returns them together with the commodity each price has been
+// derived from.
+func (ps Prices) normalize(t *commodity.Commodity, via []*commodity.Commodity) (NormalizedPrices, map[*commodity.Commodity]*commodity.Commodity) {
+	var (
+		res     = NormalizedPrices{t: one}
+		parents = make(map[*commodity.Commodity]*commodity.Commodity)
+		queue   = []*commodity.Commodity{t}
+	)
+	add := func(c, parent *commodity.Commodity) {
+		res[c] = Multiply(ps[parent][c], res[parent])
+		parents[c] = parent
+		queue = append(queue, c)
+	}
+	for _, v := range via {
+		if _, ok := res[v]; ok {
+			continue
+		}
+		path := ps.shortestPath(queue, v)
+		for i := 1; i < len(path); i++ {
+			add(path[i], path[i-1])
+		}
+	}
+	// Expand the preferred commodities first, such that prices are derived
+	// from them rather than from the target.
+	var front []*commodity.Commodity
+	for _, v := range via {
+		if _, ok := res[v]; ok {
+			front = append(front, v)
+		}
+	}
+	queue = append(front, queue...)
+	expanded := make(map[*commodity.Commodity]bool)
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if expanded[c] {
 			continue
 		}
-		res[neighbor] = Multiply(price, res[c])
-		ps.normalize(neighbor, res)
+		expanded[c] = true
+		for _, neighbor := range dict.SortedKeys(ps[c], commodity.Compare) {
+			if _, done := res[neighbor]; !done {
+				add(neighbor, c)
+			}
+		}
+	}
+	return res, parents
+}
+
+// shortestPath returns the shortest path from one of the sources to c, or nil
+// if there is none.
+func (ps Prices) shortestPath(sources []*commodity.Commodity, c *commodity.Commodity) []*commodity.Commodity {
+	prev := make(map[*commodity.Commodity]*commodity.Commodity)
+	for _, s := range sources {
+		prev[s] = nil
+	}
+	queue := slices.Clone(sources)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == c {
+			var path []*commodity.Commodity
+			for ; n != nil; n = prev[n] {
+				path = append(path, n)
+			}
+			slices.Reverse(path)
+			return path
+		}
+		for _, neighbor := range dict.SortedKeys(ps[n], commodity.Compare) {
+			if _, seen := prev[neighbor]; !seen {
+				prev[neighbor] = n
+				queue = append(queue, neighbor)
+			}
+		}
 	}
+	return nil
 }
 
 // NormalizedPrices is a map representing the price of
@@ -79,15 +186,24 @@ func (np NormalizedPrices) Price(c *commodity.Commodity) (decimal.Decimal, error
 	return price, nil
 }
 
-// Valuate valuates the given amount.
-func (np NormalizedPrices) Valuate(c *commodity.Commodity, a decimal.Decimal) (decimal.Decimal, error) {
+// Valuate valuates the given amount, rounding the value with the given mode.
+func (np NormalizedPrices) Valuate(c *commodity.Commodity, a decimal.Decimal, mode rounding.Mode) (decimal.Decimal, error) {
 	price, ok := np[c]
 	if !ok {
 		return decimal.Zero, fmt.Errorf("no price found for %v in %v", c, np)
 	}
-	return Multiply(a, price), nil
+	return Convert(a, price, mode), nil
 }
 
+// precision is the number of digits of prices and values.
+const precision = 8
+
 func Multiply(n1, n2 decimal.Decimal) decimal.Decimal {
-	return n1.Mul(n2).Truncate(8)
+	return n1.Mul(n2).Truncate(precision)
+}
+
+// Convert converts an amount with the given price, rounding the value with
+// the given mode.
+func Convert(a, price decimal.Decimal, mode rounding.Mode) decimal.Decimal {
+	return mode.Round(a.Mul(price), precision)
 }
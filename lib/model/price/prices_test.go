@@ -1,7 +1,9 @@
 package price
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/sboehler/knut/lib/model/commodity"
@@ -134,3 +136,149 @@ func TestNormalize(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeVia(t *testing.T) {
+	reg := registry.New()
+	btc := reg.Commodities().MustGet("BTC")
+	chf := reg.Commodities().MustGet("CHF")
+	eur := reg.Commodities().MustGet("EUR")
+	usd := reg.Commodities().MustGet("USD")
+	pr := make(Prices)
+	pr.Insert(usd, decimal.RequireFromString("0.5"), chf)
+	pr.Insert(eur, decimal.RequireFromString("1"), chf)
+	pr.Insert(eur, decimal.RequireFromString("4"), usd)
+	pr.Insert(btc, decimal.RequireFromString("100"), chf)
+	pr.Insert(btc, decimal.RequireFromString("150"), usd)
+
+	tests := []struct {
+		desc      string
+		via       []*commodity.Commodity
+		want      NormalizedPrices
+		wantSteps []string
+	}{
+		{
+			desc: "shortest path",
+			want: NormalizedPrices{
+				usd: decimal.RequireFromString("1"),
+				chf: decimal.RequireFromString("2"),
+				eur: decimal.RequireFromString("4"),
+				btc: decimal.RequireFromString("150"),
+			},
+			wantSteps: []string{
+				"BTC 150 USD",
+			},
+		},
+		{
+			desc: "via CHF",
+			via:  []*commodity.Commodity{chf},
+			want: NormalizedPrices{
+				usd: decimal.RequireFromString("1"),
+				chf: decimal.RequireFromString("2"),
+				eur: decimal.RequireFromString("2"),
+				btc: decimal.RequireFromString("200"),
+			},
+			wantSteps: []string{
+				"BTC 100 CHF",
+				"CHF 2 USD",
+			},
+		},
+		{
+			desc: "via EUR, then CHF",
+			via:  []*commodity.Commodity{eur, chf},
+			want: NormalizedPrices{
+				usd: decimal.RequireFromString("1"),
+				chf: decimal.RequireFromString("2"),
+				eur: decimal.RequireFromString("4"),
+				btc: decimal.RequireFromString("200"),
+			},
+			wantSteps: []string{
+				"BTC 100 CHF",
+				"CHF 2 USD",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := pr.Normalize(usd, test.via...)
+			gotSteps, err := pr.Path(usd, btc, test.via...)
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Normalize() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+			if err != nil {
+				t.Fatalf("Path() returned unexpected error %v", err)
+			}
+			var steps []string
+			for _, s := range gotSteps {
+				steps = append(steps, fmt.Sprintf("%s %s %s", s.Commodity.Name(), s.Price, s.Target.Name()))
+			}
+			if diff := cmp.Diff(test.wantSteps, steps); diff != "" {
+				t.Errorf("Path() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	reg := registry.New()
+	com1 := reg.Commodities().MustGet("COM1")
+	com2 := reg.Commodities().MustGet("COM2")
+	day := func(d int) time.Time { return time.Date(2021, 1, d, 0, 0, 0, 0, time.UTC) }
+	p := func(d int, c *commodity.Commodity, price string) *Price {
+		return &Price{Date: day(d), Commodity: c, Target: com2, Price: decimal.RequireFromString(price)}
+	}
+	inv := func(d int, price string) *Price {
+		return &Price{Date: day(d), Commodity: com2, Target: com1, Price: decimal.RequireFromString(price)}
+	}
+
+	tests := []struct {
+		desc      string
+		input     []*Price
+		tolerance decimal.Decimal
+		want      []*Price
+	}{
+		{
+			desc:  "removes unchanged prices",
+			input: []*Price{p(3, com1, "2"), p(1, com1, "1"), p(2, com1, "1"), p(4, com1, "1")},
+			want:  []*Price{p(1, com1, "1"), p(3, com1, "2"), p(4, com1, "1")},
+		},
+		{
+			desc:  "merges prices on the same date",
+			input: []*Price{p(1, com1, "1"), p(1, com1, "1.5")},
+			want:  []*Price{p(1, com1, "1.5")},
+		},
+		{
+			desc:      "drops prices within tolerance",
+			input:     []*Price{p(1, com1, "100"), p(2, com1, "100.5"), p(3, com1, "101"), p(4, com1, "102")},
+			tolerance: decimal.RequireFromString("0.01"),
+			want:      []*Price{p(1, com1, "100"), p(4, com1, "102")},
+		},
+		{
+			desc:  "groups interleaved inverse prices",
+			input: []*Price{p(1, com1, "2"), inv(2, "0.5"), p(3, com1, "2"), inv(4, "0.25"), p(5, com1, "4"), p(6, com1, "3"), inv(7, "0.33333333")},
+			want:  []*Price{p(1, com1, "2"), inv(4, "0.25"), p(6, com1, "3"), inv(7, "0.33333333")},
+		},
+		{
+			desc:  "merges inverse prices on the same date",
+			input: []*Price{p(1, com1, "2"), inv(1, "0.25")},
+			want:  []*Price{inv(1, "0.25")},
+		},
+		{
+			desc:      "applies tolerance to inverse prices",
+			input:     []*Price{p(1, com1, "100"), inv(2, "0.00995")},
+			tolerance: decimal.RequireFromString("0.01"),
+			want:      []*Price{p(1, com1, "100")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := Compact(test.input, test.tolerance)
+
+			if diff := cmp.Diff(test.want, got, cmp.Comparer(func(c1, c2 *commodity.Commodity) bool { return c1 == c2 })); diff != "" {
+				t.Fatalf("unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
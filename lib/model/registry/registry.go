@@ -17,16 +17,20 @@ package registry
 import (
 	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/template"
 )
 
 type Account = account.Account
 type Commodity = commodity.Commodity
 
 // Registry has context for the model, namely a collection of
-// referenced accounts and commodities.
+// referenced accounts and commodities, and the declared templates. A Registry is safe for concurrent
+// use by multiple goroutines, and accounts and commodities obtained from it
+// can be shared between goroutines.
 type Registry struct {
 	accounts    *account.Registry
 	commodities *commodity.Registry
+	templates   *template.Registry
 }
 
 // New creates a new, empty context.
@@ -34,6 +38,7 @@ func New() *Registry {
 	return &Registry{
 		accounts:    account.NewRegistry(),
 		commodities: commodity.NewCommodities(),
+		templates:   template.NewRegistry(),
 	}
 }
 
@@ -46,3 +51,8 @@ func (reg Registry) Accounts() *account.Registry {
 func (reg Registry) Commodities() *commodity.Registry {
 	return reg.commodities
 }
+
+// Templates returns the templates.
+func (reg Registry) Templates() *template.Registry {
+	return reg.templates
+}
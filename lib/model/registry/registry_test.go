@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sboehler/knut/lib/model/account"
+)
+
+// TestConcurrentAccess exercises the registry from multiple goroutines. It is
+// most useful when run with the race detector.
+func TestConcurrentAccess(t *testing.T) {
+	reg := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a := reg.Accounts().MustGet(fmt.Sprintf("Assets:Acc%d", j%10))
+				reg.Accounts().SwapType(a)
+				reg.Accounts().ValuationAccountFor(a)
+				c := reg.Commodities().MustGet(fmt.Sprintf("C%d", j%10))
+				if i%2 == 0 {
+					if err := reg.Commodities().TagCurrency(c.Name()); err != nil {
+						t.Error(err)
+					}
+				} else {
+					c.IsCurrency()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for j := 0; j < 10; j++ {
+		if a := reg.Accounts().MustGet(fmt.Sprintf("Assets:Acc%d", j)); reg.Accounts().SwapType(reg.Accounts().SwapType(a)) != a {
+			t.Errorf("SwapType() of %s is not an involution", a)
+		}
+		if c := reg.Commodities().MustGet(fmt.Sprintf("C%d", j)); !c.IsCurrency() {
+			t.Errorf("%s.IsCurrency() = false, want true", c)
+		}
+	}
+}
+
+func TestAlias(t *testing.T) {
+	reg := New()
+	twtr := reg.Commodities().MustGet("TWTR")
+	if err := reg.Commodities().SetMetadata("TWTR", "sector", "tech"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Commodities().Alias("TWTR", "X"); err != nil {
+		t.Fatalf("Alias(TWTR, X) returned unexpected error: %v", err)
+	}
+	x := reg.Commodities().MustGet("X")
+	if got := reg.Commodities().MustGet("TWTR"); got != x || got == twtr {
+		t.Errorf("Get(TWTR) = %s, want X", got)
+	}
+	if got := twtr.Resolve(); got != x {
+		t.Errorf("TWTR.Resolve() = %s, want X", got)
+	}
+	if got, _ := x.Metadata("sector"); got != "tech" {
+		t.Errorf("X.Metadata(sector) = %q, want the metadata of TWTR", got)
+	}
+	if err := reg.Commodities().Alias("TWTR", "X"); err != nil {
+		t.Errorf("Alias(TWTR, X) again returned unexpected error: %v", err)
+	}
+	if err := reg.Commodities().Alias("TWTR", "Y"); err == nil {
+		t.Errorf("Alias(TWTR, Y) succeeded for an alias of X, want an error")
+	}
+	if err := reg.Commodities().Alias("X", "TWTR"); err == nil {
+		t.Errorf("Alias(X, TWTR) succeeded, want an error for a cycle")
+	}
+	if err := reg.Commodities().Alias("OLD", "TWTR"); err != nil {
+		t.Errorf("Alias(OLD, TWTR) returned unexpected error: %v", err)
+	}
+	if got := reg.Commodities().MustGet("OLD"); got != x {
+		t.Errorf("Get(OLD) = %s, want X", got)
+	}
+	var names []string
+	for _, c := range reg.Commodities().All() {
+		names = append(names, c.Name())
+	}
+	if want := []string{"X"}; strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("All() = %v, want %v", names, want)
+	}
+}
+
+func TestDeclareType(t *testing.T) {
+	reg := New()
+	if _, err := reg.Accounts().Get("Memo:Guarantees"); err == nil {
+		t.Fatalf("Get(Memo:Guarantees) succeeded for an undeclared type")
+	}
+	if _, err := reg.Accounts().DeclareType("Memo", account.OffBalance); err != nil {
+		t.Fatalf("DeclareType(Memo) returned unexpected error: %v", err)
+	}
+	if _, err := reg.Accounts().DeclareType("Memo", account.OffBalance); err != nil {
+		t.Fatalf("DeclareType(Memo) again returned unexpected error: %v", err)
+	}
+	if _, err := reg.Accounts().DeclareType("Memo", account.AL); err == nil {
+		t.Errorf("DeclareType(Memo) with a different placement succeeded, want an error")
+	}
+	if _, err := reg.Accounts().DeclareType("Assets", account.EIE); err == nil {
+		t.Errorf("DeclareType(Assets) succeeded, want an error")
+	}
+	a, err := reg.Accounts().Get("Memo:Guarantees")
+	if err != nil {
+		t.Fatalf("Get(Memo:Guarantees) returned unexpected error: %v", err)
+	}
+	if a.Type().String() != "Memo" || !a.IsOffBalance() || a.IsAL() {
+		t.Errorf("Memo:Guarantees has type %s and placement %s, want Memo and off-balance", a.Type(), a.Placement())
+	}
+	if _, err := New().Accounts().Get("Memo:Guarantees"); err == nil {
+		t.Errorf("Get(Memo:Guarantees) succeeded in a registry without the declaration")
+	}
+	var names []string
+	for _, a := range reg.Accounts().All() {
+		if a.Level() == 1 {
+			names = append(names, a.Name())
+		}
+	}
+	if want := "Assets Liabilities Equity Income Expenses Envelopes Memo"; strings.Join(names, " ") != want {
+		t.Errorf("All() returned root accounts %v, want %s", names, want)
+	}
+}
+
+func TestParseType(t *testing.T) {
+	reg := New()
+	if _, err := reg.Accounts().DeclareType("Memo", account.OffBalance); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		input   string
+		want    account.Type
+		wantErr bool
+	}{
+		{input: "Expenses", want: account.EXPENSES},
+		{input: "liabilities", want: account.LIABILITIES},
+		{input: "Memo", want: "Memo"},
+		{input: "Expnses", wantErr: true},
+	} {
+		got, err := reg.Accounts().ParseType(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseType(%s) = %s, want an error", test.input, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ParseType(%s) = %s, %v, want %s", test.input, got, err, test.want)
+		}
+	}
+	if _, err := New().Accounts().ParseType("Memo"); err == nil {
+		t.Errorf("ParseType(Memo) succeeded in a registry without the declaration")
+	}
+}
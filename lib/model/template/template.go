@@ -0,0 +1,53 @@
+package template
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Registry holds the templates declared in a journal. A Registry is safe
+// for concurrent use by multiple goroutines.
+type Registry struct {
+	mutex     sync.RWMutex
+	templates map[string]*syntax.Template
+}
+
+// NewRegistry creates a new, empty collection of templates.
+func NewRegistry() *Registry {
+	return &Registry{
+		templates: make(map[string]*syntax.Template),
+	}
+}
+
+// UnknownError is returned for the use of a template which has not been
+// declared.
+type UnknownError struct {
+	Range syntax.Range
+	Name  string
+}
+
+func (e UnknownError) Error() string {
+	return syntax.Error{Range: e.Range, Message: fmt.Sprintf("unknown template %s", e.Name)}.Error()
+}
+
+// Declare declares the given template. Templates can only be declared once.
+func (r *Registry) Declare(t *syntax.Template) error {
+	name := t.Name.Extract()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.templates[name]; ok {
+		return syntax.Error{Range: t.Name, Message: fmt.Sprintf("template %s is already declared", name)}
+	}
+	r.templates[name] = t
+	return nil
+}
+
+// Get returns the template with the given name.
+func (r *Registry) Get(name string) (*syntax.Template, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	t, ok := r.templates[name]
+	return t, ok
+}
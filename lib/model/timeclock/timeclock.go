@@ -0,0 +1,61 @@
+package timeclock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/shopspring/decimal"
+)
+
+// Commodity is the commodity in which durations are booked, in hours.
+const Commodity = "h"
+
+// Create creates the transaction of a timeclock session, which books the
+// hours between checking in and checking out from the timeclock account to
+// the account of the check-in. The transaction is dated on the day of the
+// check-in, whose location it reports.
+func Create(reg *registry.Registry, in, out *syntax.Clock) (*transaction.Transaction, error) {
+	account, err := reg.Accounts().Create(in.Account)
+	if err != nil {
+		return nil, err
+	}
+	start, err := parse(in)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parse(out)
+	if err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, syntax.Error{Range: out.Range, Message: fmt.Sprintf("check-out must be after the check-in at %s", in.Range.Position())}
+	}
+	hours := decimal.NewFromInt(int64(end.Sub(start)/time.Second)).DivRound(decimal.NewFromInt(3600), 6)
+	return transaction.Builder{
+		Date:        start.Truncate(24 * time.Hour),
+		Description: fmt.Sprintf("%s from %s to %s", account.Name(), start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04")),
+		Postings: posting.Builder{
+			Credit:    reg.Accounts().TimeclockAccount(),
+			Debit:     account,
+			Commodity: reg.Commodities().MustGet(Commodity),
+			Quantity:  hours,
+		}.Build(),
+		Clock: in,
+	}.Build(), nil
+}
+
+func parse(c *syntax.Clock) (time.Time, error) {
+	date, err := c.Date.Parse()
+	if err != nil {
+		return time.Time{}, err
+	}
+	offset, err := c.Time.Parse()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.Add(offset), nil
+}
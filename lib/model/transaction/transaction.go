@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/sboehler/knut/lib/common/compare"
@@ -9,6 +10,7 @@ import (
 	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/value"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/shopspring/decimal"
 )
@@ -18,8 +20,29 @@ type Transaction struct {
 	Src         *syntax.Transaction
 	Date        time.Time
 	Description string
-	Postings    []*posting.Posting
-	Targets     []*commodity.Commodity
+
+	// ID is an optional stable identifier, which references the transaction
+	// independently of its position in the journal.
+	ID string
+
+	// Links relate the transaction to other transactions, such as an
+	// invoice to its payment.
+	Links []string
+
+	Postings []*posting.Posting
+	Targets  []*commodity.Commodity
+
+	// Patterns select further targets among the commodities in the
+	// portfolio when the performance is computed.
+	Patterns []*regexp.Regexp
+
+	// Value is the value directive whose adjustment the transaction books,
+	// if it has been generated by one.
+	Value *value.Value
+
+	// Clock is the check-in of the timeclock session which the transaction
+	// books, if it has been generated by one.
+	Clock *syntax.Clock
 }
 
 // Less defines an order on transactions.
@@ -43,8 +66,13 @@ type Builder struct {
 	Src         *syntax.Transaction
 	Date        time.Time
 	Description string
+	ID          string
+	Links       []string
 	Postings    []*posting.Posting
 	Targets     []*commodity.Commodity
+	Patterns    []*regexp.Regexp
+	Value       *value.Value
+	Clock       *syntax.Clock
 }
 
 // Build builds a transactions.
@@ -53,8 +81,13 @@ func (tb Builder) Build() *Transaction {
 		Src:         tb.Src,
 		Date:        tb.Date,
 		Description: tb.Description,
+		ID:          tb.ID,
+		Links:       tb.Links,
 		Postings:    tb.Postings,
 		Targets:     tb.Targets,
+		Patterns:    tb.Patterns,
+		Value:       tb.Value,
+		Clock:       tb.Clock,
 	}
 }
 
@@ -63,12 +96,22 @@ func Create(reg *registry.Registry, t *syntax.Transaction) ([]*Transaction, erro
 	if err != nil {
 		return nil, err
 	}
-	desc := t.Description.Content.Extract()
+	desc, err := t.Description.Parse()
+	if err != nil {
+		return nil, err
+	}
 	postings, err := posting.Create(reg, t.Bookings)
 	if err != nil {
 		return nil, err
 	}
-	var targets []*commodity.Commodity
+	var (
+		links    []string
+		targets  []*commodity.Commodity
+		patterns []*regexp.Regexp
+	)
+	for _, l := range t.Links {
+		links = append(links, l.Extract())
+	}
 	if !t.Addons.Performance.Empty() {
 		targets = []*commodity.Commodity{}
 		for _, c := range t.Addons.Performance.Targets {
@@ -78,13 +121,26 @@ func Create(reg *registry.Registry, t *syntax.Transaction) ([]*Transaction, erro
 			}
 			targets = append(targets, com)
 		}
+		for _, r := range t.Addons.Performance.Patterns {
+			if r.Empty() {
+				return nil, syntax.Error{Range: r, Message: "empty pattern"}
+			}
+			rx, err := regexp.Compile(r.Extract())
+			if err != nil {
+				return nil, syntax.Error{Range: r, Message: "invalid pattern", Wrapped: err}
+			}
+			patterns = append(patterns, rx)
+		}
 	}
 	res := Builder{
 		Src:         t,
 		Date:        date,
 		Description: desc,
+		ID:          t.ID.Extract(),
+		Links:       links,
 		Postings:    postings,
 		Targets:     targets,
+		Patterns:    patterns,
 	}.Build()
 	if !t.Addons.Accrual.Empty() {
 		return expand(reg, res, &t.Addons.Accrual)
@@ -115,11 +171,28 @@ func expand(reg *registry.Registry, t *Transaction, accrual *syntax.Accrual) ([]
 			Wrapped: err,
 		}
 	}
+	var anchor int
+	if !accrual.Anchor.Empty() {
+		if interval != date.Monthly && interval != date.Quarterly {
+			return nil, syntax.Error{
+				Message: fmt.Sprintf("anchors are not supported for %s accruals", interval),
+				Range:   accrual.Anchor.Range,
+			}
+		}
+		if anchor, err = accrual.Anchor.Parse(); err != nil {
+			return nil, err
+		}
+	}
+	reverse := !accrual.Reverse.Empty()
 	var result []*Transaction
 	for _, p := range t.Postings {
+		if p.Virtual {
+			continue
+		}
 		if p.Account.IsAL() {
 			result = append(result, Builder{
 				Src:         t.Src,
+				Links:       t.Links,
 				Date:        t.Date,
 				Description: t.Description,
 				Postings: posting.Builder{
@@ -127,20 +200,35 @@ func expand(reg *registry.Registry, t *Transaction, accrual *syntax.Accrual) ([]
 					Debit:     p.Account,
 					Commodity: p.Commodity,
 					Quantity:  p.Quantity,
+					Comment:   p.Comment,
+					Src:       p.Src,
 				}.Build(),
-				Targets: t.Targets,
+				Targets:  t.Targets,
+				Patterns: t.Patterns,
 			}.Build())
 		}
 		if p.Account.IsIE() {
 			partition := date.NewPartition(date.Period{Start: start, End: end}, interval, 0)
 			amount, rem := p.Quantity.QuoRem(decimal.NewFromInt(int64(partition.Size())), 1)
-			for i, dt := range partition.EndDates() {
+			dates := partition.EndDates()
+			if anchor > 0 {
+				dates = partition.AnchoredDates(anchor)
+			}
+			var cumulative decimal.Decimal
+			for i, dt := range dates {
 				a := amount
 				if i == 0 {
 					a = a.Add(rem)
 				}
+				if reverse {
+					// Book the accrued total at the end of each period and
+					// reverse it at the start of the next one.
+					cumulative = cumulative.Add(a)
+					a = cumulative
+				}
 				result = append(result, Builder{
 					Src:         t.Src,
+					Links:       t.Links,
 					Date:        dt,
 					Description: fmt.Sprintf("%s (accrual %d/%d)", t.Description, i+1, partition.Size()),
 					Postings: posting.Builder{
@@ -148,9 +236,30 @@ func expand(reg *registry.Registry, t *Transaction, accrual *syntax.Accrual) ([]
 						Debit:     p.Account,
 						Commodity: p.Commodity,
 						Quantity:  a,
+						Comment:   p.Comment,
+						Src:       p.Src,
 					}.Build(),
-					Targets: t.Targets,
+					Targets:  t.Targets,
+					Patterns: t.Patterns,
 				}.Build())
+				if reverse && i < len(dates)-1 {
+					result = append(result, Builder{
+						Src:         t.Src,
+						Links:       t.Links,
+						Date:        dt.AddDate(0, 0, 1),
+						Description: fmt.Sprintf("%s (reversal %d/%d)", t.Description, i+1, partition.Size()),
+						Postings: posting.Builder{
+							Credit:    p.Account,
+							Debit:     account,
+							Commodity: p.Commodity,
+							Quantity:  a,
+							Comment:   p.Comment,
+							Src:       p.Src,
+						}.Build(),
+						Targets:  t.Targets,
+						Patterns: t.Patterns,
+					}.Build())
+				}
 			}
 		}
 	}
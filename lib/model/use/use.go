@@ -0,0 +1,52 @@
+package use
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/template"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Create creates the transactions of a use directive, assigning its
+// arguments to the parameters of the template in order.
+func Create(reg *registry.Registry, u *syntax.Use) ([]*transaction.Transaction, error) {
+	name := u.Name.Extract()
+	t, ok := reg.Templates().Get(name)
+	if !ok {
+		return nil, template.UnknownError{Range: u.Name, Name: name}
+	}
+	if len(u.Arguments) != len(t.Parameters) {
+		return nil, syntax.Error{
+			Range:   u.Range,
+			Message: fmt.Sprintf("template %s has %d parameters, got %d arguments", name, len(t.Parameters), len(u.Arguments)),
+		}
+	}
+	args := make(map[string]string)
+	for i, p := range t.Parameters {
+		arg := u.Arguments[i].Extract()
+		if strings.HasPrefix(arg, `"`) {
+			arg = strings.TrimSuffix(strings.TrimPrefix(arg, `"`), `"`)
+		}
+		args[p.Extract()] = arg
+	}
+	trx, err := syntax.Instantiate(*t, u.Date.Extract(), args)
+	if err != nil {
+		return nil, syntax.Error{Range: u.Range, Message: fmt.Sprintf("instantiating template %s", name), Wrapped: err}
+	}
+	return transaction.Create(reg, &trx)
+}
+
+// Instantiate creates the transactions of the given template on the given
+// date, with the arguments given by parameter name. Importers use it to book
+// entries with templates of a journal.
+func Instantiate(reg *registry.Registry, t *syntax.Template, date time.Time, args map[string]string) ([]*transaction.Transaction, error) {
+	trx, err := syntax.Instantiate(*t, date.Format("2006-01-02"), args)
+	if err != nil {
+		return nil, fmt.Errorf("instantiating template %s: %w", t.Name.Extract(), err)
+	}
+	return transaction.Create(reg, &trx)
+}
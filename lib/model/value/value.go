@@ -0,0 +1,50 @@
+package value
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/shopspring/decimal"
+)
+
+// Value represents a value directive, which declares the balance of an
+// account in a commodity.
+type Value struct {
+	Src       *syntax.Value
+	Date      time.Time
+	Account   *account.Account
+	Quantity  decimal.Decimal
+	Commodity *commodity.Commodity
+}
+
+func Create(reg *registry.Registry, v *syntax.Value) (*Value, error) {
+	date, err := v.Date.Parse()
+	if err != nil {
+		return nil, err
+	}
+	account, err := reg.Accounts().Create(v.Account)
+	if err != nil {
+		return nil, err
+	}
+	if !account.IsAL() {
+		return nil, syntax.Error{Range: v.Account.Range, Message: "value directives require an asset or liability account"}
+	}
+	quantity, err := v.Quantity.Parse()
+	if err != nil {
+		return nil, err
+	}
+	commodity, err := reg.Commodities().Create(v.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	return &Value{
+		Src:       v,
+		Date:      date,
+		Account:   account,
+		Quantity:  quantity,
+		Commodity: commodity,
+	}, nil
+}
@@ -15,15 +15,21 @@
 package balance
 
 import (
+	"slices"
 	"time"
 
 	"github.com/sboehler/knut/lib/amounts"
 	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
 	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/regex"
+	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/common/table"
 	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/reports/query"
+	"github.com/sboehler/knut/lib/reports/view"
 	"github.com/shopspring/decimal"
 )
 
@@ -34,100 +40,276 @@ type Renderer struct {
 	SortAlphabetically bool
 	Diff               bool
 
+	// Collapsed contains accounts into which deeper accounts have been
+	// collapsed. These are marked in the output.
+	Collapsed set.Set[*model.Account]
+
+	// Baseline is an optional report for the previous year. If set, each
+	// period is compared to the same period in the baseline.
+	Baseline *Report
+
+	// Entities are the reports of the entities of a consolidated report.
+	// They are shown in columns of their own before the consolidated
+	// amounts of each period.
+	Entities []Entity
+
+	// Notes adds the notes of the accounts to their rows.
+	Notes bool
+
+	// Subtotals adds a total row for each account type.
+	Subtotals bool
+
+	// NetTotals adds the net worth (assets and liabilities) and the net
+	// income (income and expenses) to the totals.
+	NetTotals bool
+
+	// Invert contains the account types which are shown with inverted
+	// sign. If it is nil, the section with equity, income and expenses is
+	// shown with inverted sign, such that income is positive.
+	Invert set.Set[account.Type]
+
+	// Links returns the URL to which an account cell is linked, given the
+	// parameters of the register query listing the bookings behind it.
+	// Cells are not linked if it is nil.
+	Links func(query.Params) string
+
 	drawCommsColumn bool
 	partition       date.Partition
+	notes           map[*model.Account][]string
+}
+
+// Entity is the report of a single entity of a consolidated report.
+type Entity struct {
+	Name   string
+	Report *Report
 }
 
 // Render renders a report.
 func (rn *Renderer) Render(r *Report) *table.Table {
+	return rn.Build(r).Table()
+}
+
+// Build builds the view of a report.
+func (rn *Renderer) Build(r *Report) *view.Report {
 	rn.drawCommsColumn = rn.Valuation == nil || len(rn.CommodityDetails) > 0
 	rn.partition = r.partition
+	if rn.Notes {
+		rn.notes = r.notes
+	}
+	if rn.Baseline != nil {
+		r.Include(rn.Baseline)
+		rn.Baseline.Include(r)
+		rn.Baseline.SetAccounts()
+	}
+	for _, e := range rn.Entities {
+		r.Include(e.Report)
+	}
 	r.SetAccounts()
 	if rn.SortAlphabetically {
 		r.SortAlpha()
 	} else {
 		r.SortWeighted()
 	}
-	var tbl *table.Table
+	res := new(view.Report)
+	res.AddColumn("Account", 0)
 	if rn.drawCommsColumn {
-		tbl = table.New(1, 1, rn.partition.Size())
-	} else {
-		tbl = table.New(1, rn.partition.Size())
+		res.AddColumn("Comm", 1)
 	}
-	tbl.AddSeparatorRow()
-	header := tbl.AddRow().AddText("Account", table.Center)
-	if rn.drawCommsColumn {
-		header.AddText("Comm", table.Center)
+	var baseLabels []string
+	if rn.Baseline != nil {
+		baseLabels = rn.Baseline.partition.Labels()
 	}
-	for _, d := range rn.partition.EndDates() {
-		header.AddText(d.Format("2006-01-02"), table.Center)
+	for i, l := range rn.partition.Labels() {
+		for _, e := range rn.Entities {
+			res.AddColumn(e.Name, 2)
+		}
+		res.AddColumn(l, 2)
+		if rn.Baseline != nil {
+			res.AddColumn(baseLabels[i], 2)
+			res.AddColumn("Δ", 2)
+			res.AddColumn("Δ %", 3)
+		}
 	}
-	tbl.AddSeparatorRow()
 
-	totalAL, totalEIE := r.Totals(amounts.KeyMapper{
+	totalsMapper := amounts.KeyMapper{
 		Date:      mapper.Identity[time.Time],
 		Commodity: commodity.IdentityIf(rn.Valuation == nil),
-	}.Build())
+	}.Build()
+	totalAL, totalEIE := r.Totals(totalsMapper)
+	var baseAL, baseEIE amounts.Amounts
+	if rn.Baseline != nil {
+		baseAL, baseEIE = rn.Baseline.Totals(totalsMapper)
+	}
+	entAL := make([]amounts.Amounts, len(rn.Entities))
+	entEIE := make([]amounts.Amounts, len(rn.Entities))
+	for i, e := range rn.Entities {
+		entAL[i], entEIE[i] = e.Report.Totals(totalsMapper)
+	}
 
+	al := res.AddSection()
+	al.Spaced = true
 	for _, n := range r.AL.Sorted {
-		rn.renderNode(tbl, 0, false, n)
-		tbl.AddEmptyRow()
+		rn.renderType(al, false, n, totalsMapper)
 	}
-	rn.render(tbl, 0, "Total (A+L)", false, totalAL)
-	tbl.AddSeparatorRow()
+	rn.render(al, 0, "Total (A+L)", nil, false, totalAL, baseAL, entAL).Total = true
+	eie := res.AddSection()
+	eie.Spaced = true
 	for _, n := range r.EIE.Sorted {
-		rn.renderNode(tbl, 0, true, n)
-		tbl.AddEmptyRow()
+		rn.renderType(eie, true, n, totalsMapper)
+	}
+	rn.render(eie, 0, "Total (E+I+E)", nil, true, totalEIE, baseEIE, entEIE).Total = true
+	if rn.NetTotals {
+		net := res.AddSection()
+		var baseWorth, baseIncome amounts.Amounts
+		if rn.Baseline != nil {
+			baseWorth = rn.Baseline.typeTotals(totalsMapper, account.ASSETS, account.LIABILITIES)
+			baseIncome = rn.Baseline.typeTotals(totalsMapper, account.INCOME, account.EXPENSES)
+		}
+		entWorth := rn.entityAmounts(func(e *Report) amounts.Amounts {
+			return e.typeTotals(totalsMapper, account.ASSETS, account.LIABILITIES)
+		})
+		entIncome := rn.entityAmounts(func(e *Report) amounts.Amounts {
+			return e.typeTotals(totalsMapper, account.INCOME, account.EXPENSES)
+		})
+		rn.render(net, 0, "Net worth (A-L)", nil, false, r.typeTotals(totalsMapper, account.ASSETS, account.LIABILITIES), baseWorth, entWorth).Total = true
+		rn.render(net, 0, "Net income (I-E)", nil, true, r.typeTotals(totalsMapper, account.INCOME, account.EXPENSES), baseIncome, entIncome).Total = true
 	}
-	rn.render(tbl, 0, "Total (E+I+E)", true, totalEIE)
-	tbl.AddSeparatorRow()
 	totalAL.Plus(totalEIE)
-	rn.render(tbl, 0, "Delta", false, totalAL)
-	tbl.AddSeparatorRow()
+	if baseAL != nil {
+		baseAL.Plus(baseEIE)
+	}
+	for i := range entAL {
+		entAL[i].Plus(entEIE[i])
+	}
+	rn.render(res.AddSection(), 0, "Delta", nil, false, totalAL, baseAL, entAL).Total = true
 
-	return tbl
+	return res
 }
 
-func (rn *Renderer) renderNode(t *table.Table, indent int, neg bool, n *Node) {
-	var vals amounts.Amounts
+// renderType renders the accounts of a type, followed by their total if
+// subtotals are enabled.
+func (rn *Renderer) renderType(s *view.Section, neg bool, n *Node, m mapper.Mapper[amounts.Key]) {
+	if rn.Invert != nil && n.Value.Account != nil {
+		neg = rn.Invert.Has(n.Value.Account.Type())
+	}
+	rn.renderNode(s, 0, neg, n)
+	if !rn.Subtotals || n.Value.Account == nil {
+		return
+	}
+	var base amounts.Amounts
+	if rn.Baseline != nil {
+		if bn, ok := rn.Baseline.lookup(n.Value.Account); ok {
+			base = sumTree(bn, m)
+		}
+	}
+	ents := rn.entityAmounts(func(e *Report) amounts.Amounts {
+		if en, ok := e.lookup(n.Value.Account); ok {
+			return sumTree(en, m)
+		}
+		return nil
+	})
+	sel := &query.Params{Account: n.Value.Account.Name(), Descendants: true}
+	rn.render(s, 1, "Total "+n.Segment, sel, neg, sumTree(n, m), base, ents).Total = true
+}
+
+// entityAmounts returns the amounts of each entity computed by f.
+func (rn *Renderer) entityAmounts(f func(*Report) amounts.Amounts) []amounts.Amounts {
+	res := make([]amounts.Amounts, 0, len(rn.Entities))
+	for _, e := range rn.Entities {
+		res = append(res, f(e.Report))
+	}
+	return res
+}
+
+func (rn *Renderer) renderNode(s *view.Section, depth int, neg bool, n *Node) {
+	var (
+		vals, base amounts.Amounts
+		ents       []amounts.Amounts
+	)
 	if n.Value.Account != nil {
 		showCommodities := rn.Valuation == nil || rn.CommodityDetails.MatchString(n.Value.Account.Name())
-		vals = n.Value.Amounts.SumBy(nil, amounts.KeyMapper{
+		m := amounts.KeyMapper{
 			Date:      mapper.Identity[time.Time],
 			Commodity: commodity.IdentityIf(showCommodities),
-		}.Build())
+		}.Build()
+		vals = n.Value.Amounts.SumBy(nil, m)
+		if rn.Baseline != nil {
+			if bn, ok := rn.Baseline.lookup(n.Value.Account); ok {
+				base = bn.Value.Amounts.SumBy(nil, m)
+			}
+		}
+		ents = rn.entityAmounts(func(e *Report) amounts.Amounts {
+			if en, ok := e.lookup(n.Value.Account); ok {
+				return en.Value.Amounts.SumBy(nil, m)
+			}
+			return nil
+		})
 	}
 	if n.Segment != "" {
-		rn.render(t, indent, n.Segment, neg, vals)
+		name := n.Segment
+		if n.Value.Account != nil && rn.Collapsed.Has(n.Value.Account) {
+			name += " (collapsed)"
+		}
+		var sel *query.Params
+		if n.Value.Account != nil {
+			// Accounts without children in the report may contain the
+			// amounts of their descendants in the journal, if these have
+			// been shortened or collapsed.
+			sel = &query.Params{Account: n.Value.Account.Name(), Descendants: len(n.Children) == 0}
+		}
+		row := rn.render(s, depth, name, sel, neg, vals, base, ents)
+		if n.Value.Account != nil {
+			row.Notes = rn.notes[n.Value.Account]
+		}
 	}
 	for _, ch := range n.Sorted {
-		rn.renderNode(t, indent+2, neg, ch)
+		rn.renderNode(s, depth+1, neg, ch)
 	}
 }
 
-func (rn *Renderer) render(t *table.Table, indent int, name string, neg bool, vals amounts.Amounts) {
-	if len(vals) == 0 {
-		t.AddRow().AddIndented(name, indent).FillEmpty()
-		return
+func (rn *Renderer) render(s *view.Section, depth int, name string, sel *query.Params, neg bool, vals, base amounts.Amounts, ents []amounts.Amounts) *view.Row {
+	row := s.AddRow(name, depth)
+	commodities := vals.Commodities()
+	for k := range base {
+		commodities.Add(k.Commodity)
 	}
-	for i, commodity := range vals.CommoditiesSorted() {
-		row := t.AddRow()
-		if i == 0 {
-			row.AddIndented(name, indent)
-		} else {
-			row.AddEmpty()
+	for _, e := range ents {
+		for k := range e {
+			commodities.Add(k.Commodity)
 		}
+	}
+	var baseDates []time.Time
+	if rn.Baseline != nil {
+		baseDates = rn.Baseline.partition.EndDates()
+	}
+	for _, commodity := range dict.SortedKeys(commodities, commodity.Compare) {
+		line := row.AddLine()
 		if rn.drawCommsColumn {
 			if commodity != nil {
-				row.AddText(commodity.Name(), table.Left)
+				line.AddCommodity(commodity.Name())
 			} else if rn.Valuation != nil {
-				row.AddText(rn.Valuation.Name(), table.Left)
+				line.AddCommodity(rn.Valuation.Name())
 			} else {
-				row.AddEmpty()
+				line.AddEmpty()
 			}
 		}
-		var total decimal.Decimal
-		for _, date := range rn.partition.EndDates() {
+		var total, baseTotal decimal.Decimal
+		entTotals := make([]decimal.Decimal, len(rn.Entities))
+		for i, date := range rn.partition.EndDates() {
+			for j := range rn.Entities {
+				var e decimal.Decimal
+				if j < len(ents) {
+					e = ents[j][amounts.DateCommodityKey(date, commodity)]
+				}
+				if !rn.Diff {
+					entTotals[j] = entTotals[j].Add(e)
+					e = entTotals[j]
+				}
+				if neg {
+					e = e.Neg()
+				}
+				line.AddDecimal(e)
+			}
 			v := vals[amounts.DateCommodityKey(date, commodity)]
 			if !rn.Diff {
 				total = total.Add(v)
@@ -136,7 +318,72 @@ func (rn *Renderer) render(t *table.Table, indent int, name string, neg bool, va
 			if neg {
 				v = v.Neg()
 			}
-			row.AddDecimal(v)
+			line.AddDecimal(v)
+			if rn.Links != nil && sel != nil {
+				line.Link(rn.link(*sel, commodity, i))
+			}
+			if rn.Baseline == nil {
+				continue
+			}
+			b := base[amounts.DateCommodityKey(baseDates[i], commodity)]
+			if !rn.Diff {
+				baseTotal = baseTotal.Add(b)
+				b = baseTotal
+			}
+			if neg {
+				b = b.Neg()
+			}
+			line.AddDecimal(b)
+			line.AddDecimal(v.Sub(b))
+			if b.IsZero() {
+				line.AddEmpty()
+			} else {
+				line.AddPercent(v.Sub(b).Div(b.Abs()).InexactFloat64())
+			}
+		}
+	}
+	return row
+}
+
+// link returns the link of the cell of the selected accounts and the given
+// commodity in the i-th period. Without diffs, the cell is the balance
+// accumulated since the start of the report. The bookings are listed by day.
+func (rn *Renderer) link(q query.Params, com *model.Commodity, i int) string {
+	q.Period = date.Period{
+		Start: rn.partition.StartDates()[0],
+		End:   rn.partition.EndDates()[i],
+	}
+	q.Interval = date.Daily
+	if rn.Diff {
+		q.Period.Start = rn.partition.StartDates()[i]
+	}
+	if com != nil {
+		q.Commodity = com.Name()
+	}
+	if rn.Valuation != nil {
+		q.Valuation = rn.Valuation.Name()
+	}
+	return rn.Links(q)
+}
+
+// typeTotals sums the amounts of the accounts of the given types.
+func (r *Report) typeTotals(m mapper.Mapper[amounts.Key], types ...account.Type) amounts.Amounts {
+	res := make(amounts.Amounts)
+	for _, root := range []*Node{r.AL, r.EIE} {
+		for _, n := range root.Children {
+			if n.Value.Account != nil && slices.Contains(types, n.Value.Account.Type()) {
+				res.Plus(sumTree(n, m))
+			}
 		}
 	}
+	return res
+}
+
+// sumTree sums the amounts of the given node and its descendants.
+func sumTree(n *Node, m mapper.Mapper[amounts.Key]) amounts.Amounts {
+	res := make(amounts.Amounts)
+	n.PostOrder(func(n *Node) {
+		n.Value.Amounts.SumIntoBy(res, nil, m)
+	})
+	return res
 }
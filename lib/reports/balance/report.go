@@ -15,11 +15,15 @@ type Report struct {
 	Registry  *model.Registry
 	AL, EIE   *multimap.Node[Value]
 	partition date.Partition
+	notes     map[*model.Account][]string
+
+	// nodes caches the nodes of the accounts, indexed by account ID.
+	nodes []*Node
 }
 
 type Value struct {
 	Account *model.Account
-	Amounts amounts.Amounts
+	Amounts *amounts.Table
 	Weight  decimal.Decimal
 }
 
@@ -31,6 +35,7 @@ func NewReport(reg *model.Registry, part date.Partition) *Report {
 		AL:        multimap.New[Value](""),
 		EIE:       multimap.New[Value](""),
 		partition: part,
+		notes:     make(map[*model.Account][]string),
 	}
 }
 
@@ -38,23 +43,60 @@ func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
 	if k.Account == nil {
 		return
 	}
+	r.node(k.Account).Value.Amounts.Add(k, v)
+}
+
+// AddNote attaches a note to an account.
+func (r *Report) AddNote(a *model.Account, text string) {
+	r.notes[a] = append(r.notes[a], text)
+}
+
+func (r *Report) node(a *model.Account) *Node {
+	id := a.ID()
+	if id < len(r.nodes) && r.nodes[id] != nil {
+		return r.nodes[id]
+	}
 	var n *Node
-	if k.Account.IsAL() {
-		n = r.AL.GetOrCreate(k.Account.Segments())
+	if a.IsAL() {
+		n = r.AL.GetOrCreate(a.Segments())
 	} else {
-		n = r.EIE.GetOrCreate(k.Account.Segments())
+		n = r.EIE.GetOrCreate(a.Segments())
 	}
 	if n.Value.Account == nil {
-		n.Value.Account = k.Account
-		n.Value.Amounts = make(amounts.Amounts)
+		n.Value.Account = a
+		n.Value.Amounts = amounts.NewTable()
+	}
+	if id >= len(r.nodes) {
+		r.nodes = append(r.nodes, make([]*Node, id+1-len(r.nodes))...)
+	}
+	r.nodes[id] = n
+	return n
+}
+
+// lookup returns the node for the given account, if it exists.
+func (r *Report) lookup(a *model.Account) (*Node, bool) {
+	if a.IsAL() {
+		return r.AL.GetPath(a.Segments())
+	}
+	return r.EIE.GetPath(a.Segments())
+}
+
+// Include adds empty nodes for the accounts of the other report which do not
+// exist in this report.
+func (r *Report) Include(other *Report) {
+	f := func(n *Node) {
+		if n.Value.Account != nil {
+			r.node(n.Value.Account)
+		}
 	}
-	n.Value.Amounts.Add(k, v)
+	other.AL.PostOrder(f)
+	other.EIE.PostOrder(f)
 }
 
 func (r *Report) SortAlpha() {
 	f := func(n1, n2 *Node) compare.Order {
 		if n1.Value.Account.Level() == 1 && n2.Value.Account.Level() == 1 {
-			return compare.Ordered(n1.Value.Account.Type(), n2.Value.Account.Type())
+			return account.CompareTypes(n1.Value.Account.Type(), n2.Value.Account.Type())
 		}
 		return multimap.SortAlpha(n1, n2)
 	}
@@ -76,9 +118,14 @@ func (r *Report) SortWeighted() {
 	r.EIE.PostOrder(computeWeights)
 	f := func(n1, n2 *Node) compare.Order {
 		if n1.Value.Account.Level() == 1 && n2.Value.Account.Level() == 1 {
-			return compare.Ordered(n1.Value.Account.Type(), n2.Value.Account.Type())
+			return account.CompareTypes(n1.Value.Account.Type(), n2.Value.Account.Type())
 		}
-		return compare.Decimal(n1.Value.Weight, n2.Value.Weight)
+		// Accounts of equal weight, such as income and expense accounts
+		// which are closed at the start of each period, are sorted by name.
+		if o := compare.Decimal(n1.Value.Weight, n2.Value.Weight); o != compare.Equal {
+			return o
+		}
+		return multimap.SortAlpha(n1, n2)
 	}
 	r.AL.Sort(f)
 	r.EIE.Sort(f)
@@ -0,0 +1,264 @@
+package balance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestRendererInvert(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		invert set.Set[account.Type]
+		want   map[string]string
+	}{
+		{
+			desc: "default",
+			want: map[string]string{"Bank": "100", "Card": "-50", "Salary": "200", "Food": "-150"},
+		},
+		{
+			desc:   "liabilities and income",
+			invert: set.Of(account.LIABILITIES, account.INCOME),
+			want:   map[string]string{"Bank": "100", "Card": "50", "Salary": "200", "Food": "150"},
+		},
+		{
+			desc:   "none",
+			invert: set.New[account.Type](),
+			want:   map[string]string{"Bank": "100", "Card": "-50", "Salary": "-200", "Food": "150"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var (
+				reg       = registry.New()
+				chf       = reg.Commodities().MustGet("CHF")
+				end       = date.Date(2023, 12, 31)
+				partition = date.NewPartition(date.Period{Start: date.Date(2023, 1, 1), End: end}, date.Once, 0)
+				report    = NewReport(reg, partition)
+			)
+			for name, v := range map[string]int64{
+				"Assets:Bank":      100,
+				"Liabilities:Card": -50,
+				"Income:Salary":    -200,
+				"Expenses:Food":    150,
+			} {
+				report.Insert(amounts.Key{Date: end, Account: reg.Accounts().MustGet(name), Commodity: chf}, decimal.NewFromInt(v))
+			}
+			rn := Renderer{Valuation: chf, Invert: test.invert}
+
+			got := make(map[string]string)
+			for _, s := range rn.Build(report).Sections {
+				for _, row := range s.Rows {
+					if _, ok := test.want[row.Label]; ok {
+						got[row.Label] = row.Lines[0][0].Decimal.String()
+					}
+				}
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Build() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRendererTotals(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		renderer Renderer
+		want     map[string]string
+	}{
+		{
+			desc: "subtotals",
+			renderer: Renderer{
+				Subtotals: true,
+			},
+			want: map[string]string{
+				"Total Assets":      "130",
+				"Total Liabilities": "-50",
+				"Total Income":      "200",
+				"Total Expenses":    "-150",
+			},
+		},
+		{
+			desc: "subtotals with inverted signs",
+			renderer: Renderer{
+				Subtotals: true,
+				Invert:    set.Of(account.LIABILITIES, account.EQUITY, account.INCOME),
+			},
+			want: map[string]string{
+				"Total Assets":      "130",
+				"Total Liabilities": "50",
+				"Total Income":      "200",
+				"Total Expenses":    "150",
+			},
+		},
+		{
+			desc: "net totals",
+			renderer: Renderer{
+				NetTotals: true,
+			},
+			want: map[string]string{
+				"Net worth (A-L)":  "80",
+				"Net income (I-E)": "50",
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var (
+				reg       = registry.New()
+				chf       = reg.Commodities().MustGet("CHF")
+				end       = date.Date(2023, 12, 31)
+				partition = date.NewPartition(date.Period{Start: date.Date(2023, 1, 1), End: end}, date.Once, 0)
+				report    = NewReport(reg, partition)
+			)
+			for name, v := range map[string]int64{
+				"Assets:Bank":      100,
+				"Assets:Cash":      30,
+				"Liabilities:Card": -50,
+				"Income:Salary":    -200,
+				"Expenses:Food":    150,
+			} {
+				report.Insert(amounts.Key{Date: end, Account: reg.Accounts().MustGet(name), Commodity: chf}, decimal.NewFromInt(v))
+			}
+			rn := test.renderer
+			rn.Valuation = chf
+
+			got := make(map[string]string)
+			for _, s := range rn.Build(report).Sections {
+				for _, row := range s.Rows {
+					if _, ok := test.want[row.Label]; ok {
+						got[row.Label] = row.Lines[0][0].Decimal.String()
+					}
+				}
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Build() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRendererEntities(t *testing.T) {
+	var (
+		reg       = registry.New()
+		chf       = reg.Commodities().MustGet("CHF")
+		end       = date.Date(2023, 12, 31)
+		partition = date.NewPartition(date.Period{Start: date.Date(2023, 1, 1), End: end}, date.Once, 0)
+		report    = NewReport(reg, partition)
+		alice     = NewReport(reg, partition)
+		bob       = NewReport(reg, partition)
+		elims     = NewReport(reg, partition)
+	)
+	insert := func(r *Report, name string, v int64) {
+		r.Insert(amounts.Key{Date: end, Account: reg.Accounts().MustGet(name), Commodity: chf}, decimal.NewFromInt(v))
+	}
+	insert(alice, "Assets:Bank", 100)
+	insert(alice, "Assets:Loan", 50)
+	insert(bob, "Assets:Bank", 20)
+	insert(bob, "Liabilities:Loan", -50)
+	insert(elims, "Assets:Loan", -50)
+	insert(elims, "Liabilities:Loan", 50)
+	insert(report, "Assets:Bank", 120)
+	rn := Renderer{
+		Valuation: chf,
+		Entities: []Entity{
+			{Name: "alice", Report: alice},
+			{Name: "bob", Report: bob},
+			{Name: "Elim.", Report: elims},
+		},
+	}
+
+	v := rn.Build(report)
+
+	var headers []string
+	for _, c := range v.Columns {
+		headers = append(headers, c.Header)
+	}
+	if diff := cmp.Diff([]string{"Account", "alice", "bob", "Elim.", "2023-12-31"}, headers); diff != "" {
+		t.Errorf("Build() returned unexpected columns (-want/+got):\n%s", diff)
+	}
+	want := map[string][]string{
+		"Bank":        {"100", "20", "0", "120"},
+		"Loan":        {"50", "0", "-50", "0"},
+		"Total (A+L)": {"150", "-30", "0", "120"},
+	}
+	got := make(map[string][]string)
+	for _, row := range v.Sections[0].Rows {
+		if _, ok := want[row.Label]; !ok || got[row.Label] != nil {
+			// The first Loan row is the one of the assets.
+			continue
+		}
+		for _, c := range row.Lines[0] {
+			got[row.Label] = append(got[row.Label], c.Decimal.String())
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Build() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func BenchmarkReportInsert(b *testing.B) {
+	reg := registry.New()
+	var (
+		accounts []*model.Account
+		dates    []time.Time
+	)
+	for i := 0; i < 200; i++ {
+		accounts = append(accounts, reg.Accounts().MustGet(fmt.Sprintf("Assets:Group%d:Account%d", i%10, i)))
+	}
+	for i := 1; i <= 12; i++ {
+		dates = append(dates, date.EndOf(date.Date(2023, time.Month(i), 1), date.Monthly))
+	}
+	chf := reg.Commodities().MustGet("CHF")
+	keys := make([]amounts.Key, 100000)
+	for i := range keys {
+		keys[i] = amounts.Key{
+			Date:      dates[i%len(dates)],
+			Account:   accounts[i%len(accounts)],
+			Commodity: chf,
+			Valuation: chf,
+		}
+	}
+	value := decimal.NewFromInt(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReport(reg, date.Partition{})
+		for _, k := range keys {
+			r.Insert(k, value)
+		}
+	}
+}
+
+func TestSortWeightedTies(t *testing.T) {
+	var (
+		reg       = registry.New()
+		chf       = reg.Commodities().MustGet("CHF")
+		end       = date.Date(2023, 12, 31)
+		partition = date.NewPartition(date.Period{Start: date.Date(2023, 1, 1), End: end}, date.Once, 0)
+		report    = NewReport(reg, partition)
+	)
+	for _, name := range []string{"Expenses:Rent", "Expenses:Fees", "Expenses:Groceries"} {
+		report.Insert(amounts.Key{Date: end, Account: reg.Accounts().MustGet(name), Commodity: chf, Valuation: chf}, decimal.Zero)
+	}
+
+	report.SortWeighted()
+
+	var got []string
+	for _, n := range report.EIE.Sorted[0].Sorted {
+		got = append(got, n.Segment)
+	}
+	if diff := cmp.Diff([]string{"Fees", "Groceries", "Rent"}, got); diff != "" {
+		t.Errorf("SortWeighted() returned unexpected order (-want/+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,134 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestReportChart(t *testing.T) {
+	var (
+		reg       = registry.New()
+		chf       = reg.Commodities().MustGet("CHF")
+		usd       = reg.Commodities().MustGet("USD")
+		bank      = reg.Accounts().MustGet("Assets:Bank")
+		card      = reg.Accounts().MustGet("Liabilities:Card")
+		empty     = reg.Accounts().MustGet("Assets:Empty")
+		partition = date.NewPartition(date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 3, 31)}, date.Monthly, 0)
+		report    = NewReport(partition)
+	)
+	for _, e := range []struct {
+		month int
+		acc   *model.Account
+		com   *model.Commodity
+		v     int64
+	}{
+		{1, bank, chf, 100},
+		{1, bank, usd, 50},
+		{2, card, chf, -30},
+		{3, bank, chf, -20},
+		{2, empty, chf, 10},
+		{3, empty, chf, -10},
+	} {
+		d := date.EndOf(date.Date(2023, time.Month(e.month), 1), date.Monthly)
+		report.Insert(amounts.Key{Date: d, Account: e.acc, Commodity: e.com}, decimal.NewFromInt(e.v))
+	}
+
+	got := report.Chart()
+
+	want := &Chart{
+		Labels: []string{"2023-01-31", "2023-02-28", "2023-03-31"},
+		Series: []Series{
+			{Name: "Assets:Bank", Values: []float64{150, 150, 130}},
+			{Name: "Assets:Empty", Values: []float64{0, 10, 0}},
+			{Name: "Liabilities:Card", Values: []float64{0, -30, -30}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Chart() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]float64{150, 130, 100}, got.Totals()); diff != "" {
+		t.Errorf("Totals() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	for _, test := range []struct {
+		lo, hi   float64
+		want     []float64
+		wantStep float64
+	}{
+		{0, 15500, []float64{0, 5000, 10000, 15000, 20000}, 5000},
+		{-30, 150, []float64{-50, 0, 50, 100, 150}, 50},
+		{0, 0, []float64{-1, -0.5, 0, 0.5, 1}, 0.5},
+	} {
+		got, gotStep := niceTicks(test.lo, test.hi, 6)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("niceTicks(%g, %g) returned unexpected diff (-want/+got):\n%s", test.lo, test.hi, diff)
+		}
+		if gotStep != test.wantStep {
+			t.Errorf("niceTicks(%g, %g) returned step %g, want %g", test.lo, test.hi, gotStep, test.wantStep)
+		}
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	c := &Chart{
+		Labels: []string{"2023-01-31", "2023-02-28"},
+		Series: []Series{
+			{Name: "Assets:Bank", Values: []float64{100, 150}},
+			{Name: "Liabilities:Card & Co", Values: []float64{0, -30}},
+		},
+	}
+	for _, test := range []struct {
+		kind                       Kind
+		wantPolygons, wantPolyline int
+	}{
+		// Two areas and three legend entries; five grid lines, two ticks and
+		// the total.
+		{Area, 5, 8},
+		// Two legend entries; five grid lines, two ticks and two lines.
+		{Line, 2, 9},
+	} {
+		rn := Renderer{Kind: test.kind, Title: "Net worth"}
+		var buf bytes.Buffer
+
+		if err := rn.SVG(&buf, c); err != nil {
+			t.Fatalf("SVG() returned error %v", err)
+		}
+
+		got := buf.String()
+		if n := strings.Count(got, "<polygon "); n != test.wantPolygons {
+			t.Errorf("SVG() drew %d polygons, want %d", n, test.wantPolygons)
+		}
+		if n := strings.Count(got, "<polyline "); n != test.wantPolyline {
+			t.Errorf("SVG() drew %d polylines, want %d", n, test.wantPolyline)
+		}
+		for _, s := range []string{">Net worth</text>", ">Liabilities:Card &amp; Co</text>", ">2023-02-28</text>"} {
+			if !strings.Contains(got, s) {
+				t.Errorf("SVG() output does not contain %q", s)
+			}
+		}
+
+		buf.Reset()
+		if err := rn.PNG(&buf, c); err != nil {
+			t.Fatalf("PNG() returned error %v", err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatalf("png.Decode() returned error %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != 800 || b.Dy() != 500 {
+			t.Errorf("PNG() returned image of size %dx%d, want 800x500", b.Dx(), b.Dy())
+		}
+	}
+}
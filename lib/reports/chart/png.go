@@ -0,0 +1,84 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+type pngCanvas struct {
+	img *image.RGBA
+	r   *vector.Rasterizer
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return &pngCanvas{img: img, r: vector.NewRasterizer(width, height)}
+}
+
+func (cv *pngCanvas) fill(c color.RGBA) {
+	cv.r.Draw(cv.img, cv.img.Bounds(), image.NewUniform(c), image.Point{})
+	cv.r.Reset(cv.img.Bounds().Dx(), cv.img.Bounds().Dy())
+}
+
+func (cv *pngCanvas) polygon(ps []point, c color.RGBA) {
+	if len(ps) < 3 {
+		return
+	}
+	cv.r.MoveTo(float32(ps[0].x), float32(ps[0].y))
+	for _, p := range ps[1:] {
+		cv.r.LineTo(float32(p.x), float32(p.y))
+	}
+	cv.r.ClosePath()
+	cv.fill(c)
+}
+
+// polyline draws each segment as a rectangle of the given width. Segments
+// are rasterized together, such that overlapping joints are not drawn twice.
+func (cv *pngCanvas) polyline(ps []point, c color.RGBA, width float64) {
+	for i := 1; i < len(ps); i++ {
+		p, q := ps[i-1], ps[i]
+		l := math.Hypot(q.x-p.x, q.y-p.y)
+		if l == 0 {
+			continue
+		}
+		// (dx, dy) is orthogonal to the segment, with half the width.
+		dx, dy := (p.y-q.y)/l*width/2, (q.x-p.x)/l*width/2
+		cv.r.MoveTo(float32(p.x+dx), float32(p.y+dy))
+		cv.r.LineTo(float32(q.x+dx), float32(q.y+dy))
+		cv.r.LineTo(float32(q.x-dx), float32(q.y-dy))
+		cv.r.LineTo(float32(p.x-dx), float32(p.y-dy))
+		cv.r.ClosePath()
+	}
+	cv.fill(c)
+}
+
+func (cv *pngCanvas) text(p point, s string, a anchor, c color.RGBA) {
+	d := font.Drawer{
+		Dst:  cv.img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+	}
+	x := fixed.Int26_6(p.x * 64)
+	switch a {
+	case middle:
+		x -= d.MeasureString(s) / 2
+	case end:
+		x -= d.MeasureString(s)
+	}
+	d.Dot = fixed.Point26_6{X: x, Y: fixed.Int26_6(p.y * 64)}
+	d.DrawString(s)
+}
+
+func (cv *pngCanvas) encode(w io.Writer) error {
+	return png.Encode(w, cv.img)
+}
@@ -0,0 +1,287 @@
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Kind is the kind of a chart.
+type Kind int
+
+const (
+	// Area stacks the series as areas, positive values above and negative
+	// values below zero, and draws their total as a line.
+	Area Kind = iota
+	// Line draws each series as a line.
+	Line
+)
+
+// ParseKind parses a kind of chart.
+func ParseKind(s string) (Kind, error) {
+	switch s {
+	case "area":
+		return Area, nil
+	case "line":
+		return Line, nil
+	}
+	return 0, fmt.Errorf("invalid chart type %q, want area or line", s)
+}
+
+// Renderer renders charts.
+type Renderer struct {
+	Kind          Kind
+	Title         string
+	Width, Height int
+}
+
+// SVG renders the chart as SVG.
+func (rn *Renderer) SVG(w io.Writer, c *Chart) error {
+	cv := newSVGCanvas(w, rn.width(), rn.height())
+	rn.draw(cv, c)
+	return cv.close()
+}
+
+// PNG renders the chart as PNG.
+func (rn *Renderer) PNG(w io.Writer, c *Chart) error {
+	cv := newPNGCanvas(rn.width(), rn.height())
+	rn.draw(cv, c)
+	return cv.encode(w)
+}
+
+func (rn *Renderer) width() int {
+	if rn.Width <= 0 {
+		return 800
+	}
+	return rn.Width
+}
+
+func (rn *Renderer) height() int {
+	if rn.Height <= 0 {
+		return 500
+	}
+	return rn.Height
+}
+
+type point struct {
+	x, y float64
+}
+
+type anchor int
+
+const (
+	start anchor = iota
+	middle
+	end
+)
+
+// canvas is a surface to draw on. Coordinates are in pixels, with the origin
+// in the top left corner.
+type canvas interface {
+	polygon(ps []point, c color.RGBA)
+	polyline(ps []point, c color.RGBA, width float64)
+	text(p point, s string, a anchor, c color.RGBA)
+}
+
+var (
+	black = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	grey  = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	light = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+
+	// palette is the Tableau 10 palette.
+	palette = []color.RGBA{
+		{0x4e, 0x79, 0xa7, 0xff},
+		{0xf2, 0x8e, 0x2b, 0xff},
+		{0xe1, 0x57, 0x59, 0xff},
+		{0x76, 0xb7, 0xb2, 0xff},
+		{0x59, 0xa1, 0x4f, 0xff},
+		{0xed, 0xc9, 0x48, 0xff},
+		{0xb0, 0x7a, 0xa1, 0xff},
+		{0xff, 0x9d, 0xa7, 0xff},
+		{0x9c, 0x75, 0x5f, 0xff},
+		{0xba, 0xb0, 0xac, 0xff},
+	}
+)
+
+const (
+	marginLeft   = 80
+	marginRight  = 200
+	marginTop    = 40
+	marginBottom = 40
+	lineHeight   = 18
+)
+
+// layout maps values to coordinates.
+type layout struct {
+	left, right, top, bottom float64
+	min, max                 float64
+	n                        int
+}
+
+func (l layout) x(i int) float64 {
+	if l.n <= 1 {
+		return (l.left + l.right) / 2
+	}
+	return l.left + float64(i)*(l.right-l.left)/float64(l.n-1)
+}
+
+func (l layout) y(v float64) float64 {
+	return l.bottom - (v-l.min)/(l.max-l.min)*(l.bottom-l.top)
+}
+
+func (rn *Renderer) draw(cv canvas, c *Chart) {
+	w, h := float64(rn.width()), float64(rn.height())
+	lower, upper := rn.stack(c)
+	var lo, hi float64
+	for i := range c.Series {
+		for j := range c.Labels {
+			lo, hi = math.Min(lo, lower[i][j]), math.Max(hi, upper[i][j])
+		}
+	}
+	ticks, step := niceTicks(lo, hi, 6)
+	lt := layout{
+		left:   marginLeft,
+		right:  w - marginRight,
+		top:    marginTop,
+		bottom: h - marginBottom,
+		min:    ticks[0],
+		max:    ticks[len(ticks)-1],
+		n:      len(c.Labels),
+	}
+	seriesColor := func(i int) color.RGBA { return palette[i%len(palette)] }
+	drawTotal := rn.Kind == Area && len(c.Series) > 1
+
+	if rn.Title != "" {
+		cv.text(point{w / 2, marginTop / 2}, rn.Title, middle, black)
+	}
+
+	// grid and y axis
+	for _, t := range ticks {
+		y := lt.y(t)
+		col := light
+		if t == 0 {
+			col = grey
+		}
+		cv.polyline([]point{{lt.left, y}, {lt.right, y}}, col, 1)
+		cv.text(point{lt.left - 8, y + 4}, formatTick(t, step), end, black)
+	}
+
+	// x axis labels, such that they don't overlap
+	every := int(math.Ceil(float64(len(c.Labels)) * 80 / (lt.right - lt.left)))
+	for i, l := range c.Labels {
+		if (len(c.Labels)-1-i)%max(every, 1) != 0 {
+			continue
+		}
+		x := lt.x(i)
+		cv.polyline([]point{{x, lt.bottom}, {x, lt.bottom + 4}}, grey, 1)
+		cv.text(point{x, lt.bottom + 18}, l, middle, black)
+	}
+
+	// series
+	for i := range c.Series {
+		col := seriesColor(i)
+		tops := make([]point, 0, len(c.Labels))
+		for j := range c.Labels {
+			tops = append(tops, point{lt.x(j), lt.y(upper[i][j])})
+		}
+		if rn.Kind == Line {
+			cv.polyline(tops, col, 2)
+			continue
+		}
+		ps := tops
+		for j := len(c.Labels) - 1; j >= 0; j-- {
+			ps = append(ps, point{lt.x(j), lt.y(lower[i][j])})
+		}
+		if len(c.Labels) == 1 {
+			// A single period is drawn as a bar.
+			x := lt.x(0)
+			ps = []point{{x - 20, ps[0].y}, {x + 20, ps[0].y}, {x + 20, ps[1].y}, {x - 20, ps[1].y}}
+		}
+		cv.polygon(ps, col)
+	}
+	if drawTotal {
+		ps := make([]point, 0, len(c.Labels))
+		for j, v := range c.Totals() {
+			ps = append(ps, point{lt.x(j), lt.y(v)})
+		}
+		cv.polyline(ps, black, 2)
+	}
+
+	// legend
+	x, y := lt.right+20, lt.top
+	legend := func(name string, col color.RGBA) {
+		cv.polygon([]point{{x, y}, {x + 12, y}, {x + 12, y + 12}, {x, y + 12}}, col)
+		cv.text(point{x + 18, y + 10}, name, start, black)
+		y += lineHeight
+	}
+	if drawTotal {
+		legend("Total", black)
+	}
+	for i := len(c.Series) - 1; i >= 0; i-- {
+		legend(c.Series[i].Name, seriesColor(i))
+	}
+}
+
+// stack returns the lower and upper bounds of each series at each label. In
+// area charts, positive values are stacked above zero and negative values
+// below. In line charts, both bounds are the values.
+func (rn *Renderer) stack(c *Chart) (lower, upper [][]float64) {
+	pos := make([]float64, len(c.Labels))
+	neg := make([]float64, len(c.Labels))
+	for _, s := range c.Series {
+		lo := make([]float64, len(c.Labels))
+		up := make([]float64, len(c.Labels))
+		for j, v := range s.Values {
+			switch {
+			case rn.Kind == Line:
+				lo[j], up[j] = v, v
+			case v >= 0:
+				lo[j], up[j] = pos[j], pos[j]+v
+				pos[j] = up[j]
+			default:
+				lo[j], up[j] = neg[j]+v, neg[j]
+				neg[j] = lo[j]
+			}
+		}
+		lower, upper = append(lower, lo), append(upper, up)
+	}
+	return lower, upper
+}
+
+// niceTicks returns about n evenly spaced ticks at round numbers, which
+// cover the interval from lo to hi, and the distance between them.
+func niceTicks(lo, hi float64, n int) ([]float64, float64) {
+	if lo == hi {
+		lo, hi = lo-1, hi+1
+	}
+	step := niceNumber((hi - lo) / float64(n-1))
+	first, last := math.Floor(lo/step), math.Ceil(hi/step)
+	var res []float64
+	for i := first; i <= last; i++ {
+		res = append(res, i*step)
+	}
+	return res, step
+}
+
+// niceNumber returns the smallest number of the form 1, 2 or 5 times a power
+// of ten which is at least x.
+func niceNumber(x float64) float64 {
+	exp := math.Pow(10, math.Floor(math.Log10(x)))
+	for _, f := range []float64{1, 2, 5} {
+		if f*exp >= x {
+			return f * exp
+		}
+	}
+	return 10 * exp
+}
+
+// formatTick formats a tick with the digits needed for the given step.
+func formatTick(v, step float64) string {
+	digits := max(0, -int(math.Floor(math.Log10(step))))
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', digits, 64)
+}
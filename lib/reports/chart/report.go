@@ -0,0 +1,102 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chart renders the balances of accounts over time as line or
+// stacked area charts, in SVG or PNG.
+package chart
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/shopspring/decimal"
+)
+
+// Report collects the amounts of accounts by date. Amounts of different
+// commodities are added up, so they are expected to be valuated.
+type Report struct {
+	partition date.Partition
+	amounts   map[*model.Account]map[time.Time]decimal.Decimal
+}
+
+// NewReport creates a new report. Dates are expected to be aligned to the
+// end dates of the partition.
+func NewReport(part date.Partition) *Report {
+	return &Report{
+		partition: part,
+		amounts:   make(map[*model.Account]map[time.Time]decimal.Decimal),
+	}
+}
+
+// Insert inserts an amount.
+func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
+	if k.Account == nil {
+		return
+	}
+	as, ok := r.amounts[k.Account]
+	if !ok {
+		as = make(map[time.Time]decimal.Decimal)
+		r.amounts[k.Account] = as
+	}
+	as[k.Date] = as[k.Date].Add(v)
+}
+
+// Chart is a chart of series over the periods of a partition.
+type Chart struct {
+	Labels []string
+	Series []Series
+}
+
+// Series is a named series of values, one for each label of a chart.
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+// Chart returns a chart with a series for each account, which holds the
+// balance of the account at the end of each period. Accounts whose balance
+// is always zero are left out.
+func (r *Report) Chart() *Chart {
+	res := &Chart{Labels: r.partition.Labels()}
+	dates := r.partition.EndDates()
+	for _, a := range dict.SortedKeys(r.amounts, account.Compare) {
+		s := Series{Name: a.Name(), Values: make([]float64, 0, len(dates))}
+		var total decimal.Decimal
+		nonzero := false
+		for _, d := range dates {
+			total = total.Add(r.amounts[a][d])
+			nonzero = nonzero || !total.IsZero()
+			s.Values = append(s.Values, total.InexactFloat64())
+		}
+		if nonzero {
+			res.Series = append(res.Series, s)
+		}
+	}
+	return res
+}
+
+// Totals returns the sum of the series for each label.
+func (c *Chart) Totals() []float64 {
+	res := make([]float64, len(c.Labels))
+	for _, s := range c.Series {
+		for i, v := range s.Values {
+			res[i] += v
+		}
+	}
+	return res
+}
@@ -0,0 +1,57 @@
+package chart
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+type svgCanvas struct {
+	w *bufio.Writer
+}
+
+func newSVGCanvas(w io.Writer, width, height int) *svgCanvas {
+	cv := &svgCanvas{w: bufio.NewWriter(w)}
+	fmt.Fprintf(cv.w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	fmt.Fprintf(cv.w, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	return cv
+}
+
+func (cv *svgCanvas) polygon(ps []point, c color.RGBA) {
+	fmt.Fprintf(cv.w, `<polygon points="%s" fill="%s"/>`+"\n", svgPoints(ps), svgColor(c))
+}
+
+func (cv *svgCanvas) polyline(ps []point, c color.RGBA, width float64) {
+	fmt.Fprintf(cv.w, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%g"/>`+"\n", svgPoints(ps), svgColor(c), width)
+}
+
+var svgAnchors = map[anchor]string{start: "start", middle: "middle", end: "end"}
+
+func (cv *svgCanvas) text(p point, s string, a anchor, c color.RGBA) {
+	fmt.Fprintf(cv.w, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">`, p.x, p.y, svgAnchors[a], svgColor(c))
+	xml.EscapeText(cv.w, []byte(s))
+	cv.w.WriteString("</text>\n")
+}
+
+func (cv *svgCanvas) close() error {
+	cv.w.WriteString("</svg>\n")
+	return cv.w.Flush()
+}
+
+func svgPoints(ps []point) string {
+	var s strings.Builder
+	for i, p := range ps {
+		if i > 0 {
+			s.WriteRune(' ')
+		}
+		fmt.Fprintf(&s, "%.1f,%.1f", p.x, p.y)
+	}
+	return s.String()
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
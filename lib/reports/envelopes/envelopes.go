@@ -0,0 +1,172 @@
+// Package envelopes implements an envelope budgeting report. Money is
+// assigned to envelopes by transfers between accounts of type Envelopes,
+// income funds the unallocated envelope and expenses are charged to the
+// envelope with the longest matching name, e.g. Expenses:Food:Groceries to
+// Envelopes:Food.
+package envelopes
+
+import (
+	"slices"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/reports/view"
+	"github.com/shopspring/decimal"
+)
+
+// Report is an envelope budgeting report.
+type Report struct {
+	period      date.Period
+	unallocated *model.Account
+
+	// budgeted and spent contain the amounts within the period, funds and
+	// expenses the cumulative amounts up to the end of the period.
+	budgeted, spent, funds, expenses amounts.Amounts
+	envelopes                        set.Set[*model.Account]
+}
+
+// NewReport creates a new report for the given period. Income funds the
+// unallocated envelope.
+func NewReport(p date.Period, unallocated *model.Account) *Report {
+	return &Report{
+		period:      p,
+		unallocated: unallocated,
+		budgeted:    make(amounts.Amounts),
+		spent:       make(amounts.Amounts),
+		funds:       make(amounts.Amounts),
+		expenses:    make(amounts.Amounts),
+		envelopes:   set.Of(unallocated),
+	}
+}
+
+// Insert inserts an amount. Keys are expected to carry a date, an account
+// and optionally a commodity.
+func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
+	if k.Account == nil || k.Date.After(r.period.End) {
+		return
+	}
+	inPeriod := !k.Date.Before(r.period.Start)
+	switch k.Account.Type() {
+	case account.INCOME:
+		k.Account, v = r.unallocated, v.Neg()
+		fallthrough
+	case account.ENVELOPES:
+		key := amounts.AccountCommodityKey(k.Account, k.Commodity)
+		r.envelopes.Add(k.Account)
+		r.funds.Add(key, v)
+		if inPeriod {
+			r.budgeted.Add(key, v)
+		}
+	case account.EXPENSES:
+		key := amounts.AccountCommodityKey(k.Account, k.Commodity)
+		r.expenses.Add(key, v)
+		if inPeriod {
+			r.spent.Add(key, v)
+		}
+	}
+}
+
+// envelope returns the envelope an expense account is charged to, or nil.
+func (r *Report) envelope(a *model.Account) *model.Account {
+	var res *model.Account
+	for e := range r.envelopes {
+		if e == r.unallocated || !isPrefix(e.Segments()[1:], a.Segments()[1:]) {
+			continue
+		}
+		if res == nil || e.Level() > res.Level() {
+			res = e
+		}
+	}
+	return res
+}
+
+func isPrefix(prefix, s []string) bool {
+	return len(prefix) > 0 && len(prefix) <= len(s) && slices.Equal(prefix, s[:len(prefix)])
+}
+
+// Renderer renders a report.
+type Renderer struct {
+	ShowCommodities bool
+}
+
+func (rn *Renderer) Render(r *Report) *table.Table {
+	return rn.Build(r).Table()
+}
+
+// Build builds the view of a report.
+func (rn *Renderer) Build(r *Report) *view.Report {
+	var (
+		budgeted   = r.budgeted
+		available  = r.funds.Clone()
+		spent      = make(amounts.Amounts)
+		unbudgeted bool
+	)
+	// Charge expenses to envelopes. Expenses without an envelope are kept
+	// under a nil account.
+	for k, v := range r.expenses {
+		key := amounts.AccountCommodityKey(r.envelope(k.Account), k.Commodity)
+		unbudgeted = unbudgeted || key.Account == nil
+		available.Add(key, v.Neg())
+		if v, ok := r.spent[k]; ok {
+			spent.Add(key, v)
+		}
+	}
+	res := new(view.Report)
+	res.AddColumn("Envelope", 0)
+	if rn.ShowCommodities {
+		res.AddColumn("Comm", 1)
+	}
+	res.AddColumn("Budgeted", 2)
+	res.AddColumn("Spent", 2)
+	res.AddColumn("Available", 2)
+	s := res.AddSection()
+	for _, e := range r.envelopes.Sorted(account.Compare) {
+		rn.render(s, e.Name(), e, budgeted, spent, available)
+	}
+	if unbudgeted {
+		rn.render(s, "Unbudgeted", nil, budgeted, spent, available)
+	}
+	rn.render(res.AddSection(), "Total", nil,
+		budgeted.SumBy(nil, commodityKey),
+		spent.SumBy(nil, commodityKey),
+		available.SumBy(nil, commodityKey),
+	).Total = true
+	return res
+}
+
+func commodityKey(k amounts.Key) amounts.Key {
+	return amounts.CommodityKey(k.Commodity)
+}
+
+func (rn *Renderer) render(s *view.Section, label string, a *model.Account, budgeted, spent, available amounts.Amounts) *view.Row {
+	row := s.AddRow(label, 0)
+	coms := set.New[*model.Commodity]()
+	for _, vals := range []amounts.Amounts{budgeted, spent, available} {
+		for k := range vals {
+			if k.Account == a {
+				coms.Add(k.Commodity)
+			}
+		}
+	}
+	for _, com := range dict.SortedKeys(coms, commodity.Compare) {
+		line := row.AddLine()
+		if rn.ShowCommodities {
+			if com != nil {
+				line.AddCommodity(com.Name())
+			} else {
+				line.AddEmpty()
+			}
+		}
+		key := amounts.AccountCommodityKey(a, com)
+		line.AddDecimal(budgeted[key])
+		line.AddDecimal(spent[key])
+		line.AddDecimal(available[key])
+	}
+	return row
+}
@@ -0,0 +1,61 @@
+package envelopes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestReport(t *testing.T) {
+	var (
+		reg         = registry.New()
+		unallocated = reg.Accounts().MustGet("Envelopes:Unallocated")
+		food        = reg.Accounts().MustGet("Envelopes:Food")
+		salary      = reg.Accounts().MustGet("Income:Salary")
+		groceries   = reg.Accounts().MustGet("Expenses:Food:Groceries")
+		misc        = reg.Accounts().MustGet("Expenses:Misc")
+		bank        = reg.Accounts().MustGet("Assets:Bank")
+	)
+	rep := NewReport(date.Period{Start: date.Date(2024, 2, 1), End: date.Date(2024, 2, 29)}, unallocated)
+	insert := func(day time.Time, credit, debit *model.Account, qty int64) {
+		rep.Insert(amounts.Key{Date: day, Account: credit}, decimal.NewFromInt(-qty))
+		rep.Insert(amounts.Key{Date: day, Account: debit}, decimal.NewFromInt(qty))
+	}
+	insert(date.Date(2024, 1, 25), salary, bank, 1000)
+	insert(date.Date(2024, 1, 26), unallocated, food, 300)
+	insert(date.Date(2024, 2, 3), bank, groceries, 120)
+	insert(date.Date(2024, 2, 4), bank, misc, 50)
+	insert(date.Date(2024, 2, 5), unallocated, food, 100)
+	insert(date.Date(2024, 3, 1), bank, groceries, 999)
+
+	var got strings.Builder
+	tr := table.TextRenderer{}
+	if err := tr.Render((&Renderer{}).Render(rep), &got); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"+-----------------------+-----------+-------+-----------+",
+		"|       Envelope        | Budgeted  | Spent | Available |",
+		"+-----------------------+-----------+-------+-----------+",
+		"| Envelopes:Food        |       100 |   120 |       280 |",
+		"| Envelopes:Unallocated |      -100 |       |       600 |",
+		"| Unbudgeted            |           |    50 |       -50 |",
+		"+-----------------------+-----------+-------+-----------+",
+		"| Total                 |           |   170 |       830 |",
+		"+-----------------------+-----------+-------+-----------+",
+		"",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Fatalf("Render() returned unexpected diff (-want/+got):\n%s\n", diff)
+	}
+}
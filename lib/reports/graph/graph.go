@@ -0,0 +1,172 @@
+// Package graph renders the account hierarchy and the flows between accounts
+// as a Graphviz (dot) or d2 diagram.
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/multimap"
+	"github.com/sboehler/knut/lib/common/rounding"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/shopspring/decimal"
+)
+
+// Report contains the accounts and the flows between them.
+type Report struct {
+	accounts *Node
+	flows    map[Edge]decimal.Decimal
+}
+
+type Node = multimap.Node[*model.Account]
+
+// Edge is a flow from one account to another.
+type Edge struct {
+	From, To *model.Account
+}
+
+// NewReport creates a new report.
+func NewReport() *Report {
+	return &Report{
+		accounts: multimap.New[*model.Account](""),
+		flows:    make(map[Edge]decimal.Decimal),
+	}
+}
+
+// Insert inserts an amount. Keys are expected to carry an account and the
+// other account of the booking. Only positive amounts are recorded, as a flow
+// from the other account to the account, so every booking is counted once.
+func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
+	if k.Account == nil || k.Other == nil || k.Account == k.Other || !v.IsPositive() {
+		return
+	}
+	r.accounts.GetOrCreate(k.Account.Segments()).Value = k.Account
+	r.accounts.GetOrCreate(k.Other.Segments()).Value = k.Other
+	e := Edge{From: k.Other, To: k.Account}
+	r.flows[e] = r.flows[e].Add(v)
+}
+
+// Format is a diagram format.
+type Format string
+
+const (
+	// DOT is the Graphviz format.
+	DOT Format = "dot"
+	// D2 is the d2 format.
+	D2 Format = "d2"
+)
+
+// ParseFormat parses a format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case DOT, D2:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid format %q, want dot or d2", s)
+}
+
+// Renderer renders a report.
+type Renderer struct {
+	Format Format
+
+	// Digits is the number of digits the flows are rounded to.
+	Digits int32
+
+	// Rounding determines how the flows are rounded.
+	Rounding rounding.Mode
+}
+
+const maxWidth = 10
+
+// Render writes the diagram.
+func (rn Renderer) Render(r *Report, w io.Writer) error {
+	r.accounts.Sort(multimap.SortAlpha[*model.Account])
+	var b strings.Builder
+	switch rn.Format {
+	case DOT:
+		b.WriteString("digraph accounts {\n  rankdir=LR;\n  node [shape=box];\n")
+		for _, n := range r.accounts.Sorted {
+			rn.writeDOTNode(&b, 1, nil, n)
+		}
+	case D2:
+		b.WriteString("direction: right\n")
+		for _, n := range r.accounts.Sorted {
+			rn.writeD2Node(&b, 0, n)
+		}
+	default:
+		return fmt.Errorf("invalid format %q", rn.Format)
+	}
+	largest := decimal.Zero
+	for _, v := range r.flows {
+		largest = decimal.Max(largest, v)
+	}
+	for _, e := range dict.SortedKeys(r.flows, compareEdges) {
+		v := r.flows[e]
+		width := v.Div(largest).Mul(decimal.NewFromInt(maxWidth - 1)).Add(decimal.NewFromInt(1)).StringFixed(1)
+		label := rn.Rounding.Round(v, rn.Digits).String()
+		switch rn.Format {
+		case DOT:
+			fmt.Fprintf(&b, "  %q -> %q [label=%q, penwidth=%s];\n", e.From.Name(), e.To.Name(), label, width)
+		case D2:
+			fmt.Fprintf(&b, "%s -> %s: %q {style.stroke-width: %s}\n", d2ID(e.From), d2ID(e.To), label, width)
+		}
+	}
+	if rn.Format == DOT {
+		b.WriteString("}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeDOTNode writes an account as a node. Accounts with children are
+// written as clusters containing their children.
+func (rn Renderer) writeDOTNode(b *strings.Builder, depth int, path []string, n *Node) {
+	path = append(path, n.Segment)
+	indent := strings.Repeat("  ", depth)
+	if len(n.Sorted) == 0 {
+		fmt.Fprintf(b, "%s%q [label=%q];\n", indent, strings.Join(path, ":"), n.Segment)
+		return
+	}
+	fmt.Fprintf(b, "%ssubgraph %q {\n%s  label=%q;\n", indent, "cluster_"+strings.Join(path, ":"), indent, n.Segment)
+	if n.Value != nil {
+		fmt.Fprintf(b, "%s  %q [label=%q];\n", indent, strings.Join(path, ":"), n.Segment)
+	}
+	for _, ch := range n.Sorted {
+		rn.writeDOTNode(b, depth+1, path, ch)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// writeD2Node writes an account as a d2 shape, nesting its children.
+func (rn Renderer) writeD2Node(b *strings.Builder, depth int, n *Node) {
+	indent := strings.Repeat("  ", depth)
+	if len(n.Sorted) == 0 {
+		fmt.Fprintf(b, "%s%q\n", indent, n.Segment)
+		return
+	}
+	fmt.Fprintf(b, "%s%q: {\n", indent, n.Segment)
+	for _, ch := range n.Sorted {
+		rn.writeD2Node(b, depth+1, ch)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func d2ID(a *model.Account) string {
+	var ss []string
+	for _, s := range a.Segments() {
+		ss = append(ss, fmt.Sprintf("%q", s))
+	}
+	return strings.Join(ss, ".")
+}
+
+func compareEdges(e1, e2 Edge) compare.Order {
+	if o := account.Compare(e1.From, e2.From); o != compare.Equal {
+		return o
+	}
+	return account.Compare(e1.To, e2.To)
+}
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestRender(t *testing.T) {
+	var (
+		reg     = registry.New()
+		bank    = reg.Accounts().MustGet("Assets:Bank")
+		savings = reg.Accounts().MustGet("Assets:Bank:Savings")
+		salary  = reg.Accounts().MustGet("Income:Salary")
+	)
+	rep := NewReport()
+	insert := func(credit, debit *model.Account, v int64) {
+		rep.Insert(amounts.Key{Account: credit, Other: debit}, decimal.NewFromInt(-v))
+		rep.Insert(amounts.Key{Account: debit, Other: credit}, decimal.NewFromInt(v))
+	}
+	insert(salary, bank, 1000)
+	insert(bank, savings, 250)
+	insert(bank, bank, 10)
+
+	tests := []struct {
+		format Format
+		want   []string
+	}{
+		{
+			format: DOT,
+			want: []string{
+				`digraph accounts {`,
+				`  rankdir=LR;`,
+				`  node [shape=box];`,
+				`  subgraph "cluster_Assets" {`,
+				`    label="Assets";`,
+				`    subgraph "cluster_Assets:Bank" {`,
+				`      label="Bank";`,
+				`      "Assets:Bank" [label="Bank"];`,
+				`      "Assets:Bank:Savings" [label="Savings"];`,
+				`    }`,
+				`  }`,
+				`  subgraph "cluster_Income" {`,
+				`    label="Income";`,
+				`    "Income:Salary" [label="Salary"];`,
+				`  }`,
+				`  "Assets:Bank" -> "Assets:Bank:Savings" [label="250", penwidth=3.3];`,
+				`  "Income:Salary" -> "Assets:Bank" [label="1000", penwidth=10.0];`,
+				`}`,
+				``,
+			},
+		},
+		{
+			format: D2,
+			want: []string{
+				`direction: right`,
+				`"Assets": {`,
+				`  "Bank": {`,
+				`    "Savings"`,
+				`  }`,
+				`}`,
+				`"Income": {`,
+				`  "Salary"`,
+				`}`,
+				`"Assets"."Bank" -> "Assets"."Bank"."Savings": "250" {style.stroke-width: 3.3}`,
+				`"Income"."Salary" -> "Assets"."Bank": "1000" {style.stroke-width: 10.0}`,
+				``,
+			},
+		},
+	}
+	for _, test := range tests {
+		var got strings.Builder
+		if err := (Renderer{Format: test.format}).Render(rep, &got); err != nil {
+			t.Fatalf("Render() returned unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(strings.Join(test.want, "\n"), got.String()); diff != "" {
+			t.Errorf("Render(%s) returned unexpected diff (-want/+got):\n%s\n", test.format, diff)
+		}
+	}
+}
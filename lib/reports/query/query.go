@@ -0,0 +1,174 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package query defines the parameters of a report query, which are shared
+// by the balance and the register reports. A balance cell is encoded as the
+// parameters of the register query listing the bookings behind it.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/date"
+)
+
+// Params are the parameters of a report query.
+type Params struct {
+	// Account selects the bookings of an account.
+	Account string
+
+	// Descendants additionally selects the bookings of the descendants of
+	// the account.
+	Descendants bool
+
+	// Commodity selects a single commodity.
+	Commodity string
+
+	Period    date.Period
+	Interval  date.Interval
+	Valuation string
+}
+
+const dateFormat = "2006-01-02"
+
+// Values encodes the parameters as URL query values. Unset parameters are
+// omitted.
+func (p Params) Values() url.Values {
+	vs := make(url.Values)
+	set := func(k, v string) {
+		if v != "" {
+			vs.Set(k, v)
+		}
+	}
+	set("account", p.Account)
+	if p.Descendants {
+		vs.Set("descendants", "true")
+	}
+	set("commodity", p.Commodity)
+	if !p.Period.Start.IsZero() {
+		vs.Set("from", p.Period.Start.Format(dateFormat))
+	}
+	if !p.Period.End.IsZero() {
+		vs.Set("to", p.Period.End.Format(dateFormat))
+	}
+	if p.Interval != date.Once {
+		vs.Set("interval", p.Interval.String())
+	}
+	set("val", p.Valuation)
+	return vs
+}
+
+// Encode encodes the parameters as a URL query string.
+func (p Params) Encode() string {
+	return p.Values().Encode()
+}
+
+// Parse decodes the parameters from URL query values.
+func Parse(vs url.Values) (Params, error) {
+	p := Params{
+		Account:   vs.Get("account"),
+		Commodity: vs.Get("commodity"),
+		Valuation: vs.Get("val"),
+	}
+	var err error
+	if s := vs.Get("descendants"); s != "" {
+		if p.Descendants, err = strconv.ParseBool(s); err != nil {
+			return Params{}, fmt.Errorf("invalid value %q for descendants, want true or false", s)
+		}
+	}
+	if p.Period.Start, err = parseDate(vs, "from"); err != nil {
+		return Params{}, err
+	}
+	if p.Period.End, err = parseDate(vs, "to"); err != nil {
+		return Params{}, err
+	}
+	if s := vs.Get("interval"); s != "" {
+		if p.Interval, err = date.ParseInterval(s); err != nil {
+			return Params{}, err
+		}
+	}
+	return p, nil
+}
+
+func parseDate(vs url.Values, key string) (time.Time, error) {
+	s := vs.Get(key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(dateFormat, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date %q, want YYYY-MM-DD", key, s)
+	}
+	return t, nil
+}
+
+// BalanceArgs returns the flags of the balance command for the parameters.
+func (p Params) BalanceArgs() []string {
+	return p.args("--account")
+}
+
+// RegisterArgs returns the flags of the register command for the
+// parameters. The account is used as the source account.
+func (p Params) RegisterArgs() []string {
+	return p.args("--source")
+}
+
+// AccountRegex returns the regex selecting the account, and its descendants
+// if requested. It returns the empty string if no account is selected.
+func (p Params) AccountRegex() string {
+	switch {
+	case p.Account == "":
+		return ""
+	case p.Descendants:
+		return "^" + regexp.QuoteMeta(p.Account) + "(:|$)"
+	default:
+		return "^" + regexp.QuoteMeta(p.Account) + "$"
+	}
+}
+
+// CommodityRegex returns the regex selecting the commodity. It returns the
+// empty string if no commodity is selected.
+func (p Params) CommodityRegex() string {
+	if p.Commodity == "" {
+		return ""
+	}
+	return "^" + regexp.QuoteMeta(p.Commodity) + "$"
+}
+
+func (p Params) args(accountFlag string) []string {
+	var res []string
+	if rx := p.AccountRegex(); rx != "" {
+		res = append(res, accountFlag, rx)
+	}
+	if rx := p.CommodityRegex(); rx != "" {
+		res = append(res, "--commodity", rx)
+	}
+	if !p.Period.Start.IsZero() {
+		res = append(res, "--from", p.Period.Start.Format(dateFormat))
+	}
+	if !p.Period.End.IsZero() {
+		res = append(res, "--to", p.Period.End.Format(dateFormat))
+	}
+	if p.Interval != date.Once {
+		res = append(res, "--interval", p.Interval.String())
+	}
+	if p.Valuation != "" {
+		res = append(res, "--val", p.Valuation)
+	}
+	return res
+}
@@ -0,0 +1,96 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+)
+
+func TestParseValues(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		params Params
+		want   string
+	}{
+		{
+			desc: "empty",
+			want: "",
+		},
+		{
+			desc: "all",
+			params: Params{
+				Account:     "Assets:Bank",
+				Descendants: true,
+				Commodity:   "CHF",
+				Period:      date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 3, 31)},
+				Interval:    date.Daily,
+				Valuation:   "USD",
+			},
+			want: "account=Assets%3ABank&commodity=CHF&descendants=true&from=2023-01-01&interval=daily&to=2023-03-31&val=USD",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := test.params.Encode(); got != test.want {
+				t.Errorf("Encode() = %q, want %q", got, test.want)
+			}
+			vs, err := url.ParseQuery(test.want)
+			if err != nil {
+				t.Fatalf("url.ParseQuery(%q) returned unexpected error: %v", test.want, err)
+			}
+			got, err := Parse(vs)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned unexpected error: %v", test.want, err)
+			}
+			if diff := cmp.Diff(test.params, got); diff != "" {
+				t.Errorf("Parse(%q) returned unexpected diff (-want/+got):\n%s", test.want, diff)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		"from=2023-13-01",
+		"to=yesterday",
+		"interval=hourly",
+		"descendants=maybe",
+	} {
+		t.Run(query, func(t *testing.T) {
+			vs, err := url.ParseQuery(query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery(%q) returned unexpected error: %v", query, err)
+			}
+			if _, err := Parse(vs); err == nil {
+				t.Errorf("Parse(%q) returned no error, want one", query)
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	p := Params{
+		Account:   "Expenses:Food.Out",
+		Commodity: "CHF",
+		Period:    date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 1, 31)},
+		Interval:  date.Daily,
+		Valuation: "CHF",
+	}
+	want := []string{
+		"--source", `^Expenses:Food\.Out$`,
+		"--commodity", "^CHF$",
+		"--from", "2023-01-01",
+		"--to", "2023-01-31",
+		"--interval", "daily",
+		"--val", "CHF",
+	}
+	if diff := cmp.Diff(want, p.RegisterArgs()); diff != "" {
+		t.Errorf("RegisterArgs() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	p.Descendants = true
+	want[0], want[1] = "--account", `^Expenses:Food\.Out(:|$)`
+	if diff := cmp.Diff(want, p.BalanceArgs()); diff != "" {
+		t.Errorf("BalanceArgs() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recurring detects recurring payments, such as subscriptions and
+// standing orders, by the periodicity of bookings with the same payee.
+package recurring
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/reports/view"
+	"github.com/shopspring/decimal"
+)
+
+// Report collects bookings by payee, account and commodity.
+type Report struct {
+	clusters map[clusterKey]*cluster
+}
+
+type clusterKey struct {
+	payee     string
+	account   *model.Account
+	commodity *model.Commodity
+}
+
+type cluster struct {
+	description string
+	last        time.Time
+	bookings    map[time.Time]decimal.Decimal
+}
+
+// NewReport creates a new report.
+func NewReport() *Report {
+	return &Report{clusters: make(map[clusterKey]*cluster)}
+}
+
+// Insert inserts an amount. Keys are expected to carry a date, an account,
+// a description and optionally a commodity. Amounts of the same day are
+// added up.
+func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
+	if k.Account == nil || k.Description == "" {
+		return
+	}
+	ck := clusterKey{payee: Payee(k.Description), account: k.Account, commodity: k.Commodity}
+	c, ok := r.clusters[ck]
+	if !ok {
+		c = &cluster{bookings: make(map[time.Time]decimal.Decimal)}
+		r.clusters[ck] = c
+	}
+	if !k.Date.Before(c.last) {
+		// The report shows the most recent description of a payee.
+		c.description, c.last = k.Description, k.Date
+	}
+	c.bookings[k.Date] = c.bookings[k.Date].Add(v)
+}
+
+// Payee returns the payee of a description, which is the description in
+// lower case without digits and punctuation, such that references and
+// dates in descriptions don't separate the bookings of a payee.
+func Payee(desc string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(desc), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ")
+}
+
+// Recurrence is a detected recurring payment.
+type Recurrence struct {
+	Description string
+	Account     *model.Account
+	Commodity   *model.Commodity
+	Interval    date.Interval
+	Count       int
+	Last, Next  time.Time
+
+	// Amount is the median amount of the bookings.
+	Amount decimal.Decimal
+}
+
+// AnnualCost returns the estimated cost per year.
+func (r Recurrence) AnnualCost() decimal.Decimal {
+	return r.Amount.Mul(decimal.NewFromInt(perYear[r.Interval]))
+}
+
+// Ended returns whether the recurrence has ended by the given date, which
+// is the case if the next booking is overdue by more than half an interval.
+func (r Recurrence) Ended(t time.Time) bool {
+	return t.After(r.Next.AddDate(0, 0, int(nominalDays[r.Interval]/2)))
+}
+
+var (
+	intervals   = []date.Interval{date.Weekly, date.Monthly, date.Quarterly, date.Yearly}
+	nominalDays = map[date.Interval]float64{
+		date.Weekly:    7,
+		date.Monthly:   30.44,
+		date.Quarterly: 91.31,
+		date.Yearly:    365.25,
+	}
+	perYear = map[date.Interval]int64{
+		date.Weekly:    52,
+		date.Monthly:   12,
+		date.Quarterly: 4,
+		date.Yearly:    1,
+	}
+)
+
+const (
+	// tolerance is the relative deviation from the nominal length of an
+	// interval, and from the median amount, which is still regular.
+	tolerance = 0.2
+
+	// regularity is the minimum share of intervals and amounts which must
+	// be regular.
+	regularity = 0.75
+)
+
+// Detect returns the recurring payments with at least minCount bookings,
+// sorted by decreasing annual cost.
+func (r *Report) Detect(minCount int) []Recurrence {
+	var res []Recurrence
+	for ck, c := range r.clusters {
+		rec, ok := c.detect(minCount)
+		if !ok {
+			continue
+		}
+		rec.Account, rec.Commodity = ck.account, ck.commodity
+		res = append(res, rec)
+	}
+	slices.SortFunc(res, func(r1, r2 Recurrence) int {
+		if c := r2.AnnualCost().Abs().Cmp(r1.AnnualCost().Abs()); c != 0 {
+			return c
+		}
+		if c := strings.Compare(r1.Description, r2.Description); c != 0 {
+			return c
+		}
+		return strings.Compare(r1.Account.Name(), r2.Account.Name())
+	})
+	return res
+}
+
+func (c *cluster) detect(minCount int) (Recurrence, bool) {
+	var dates []time.Time
+	for d, v := range c.bookings {
+		if !v.IsZero() {
+			dates = append(dates, d)
+		}
+	}
+	if len(dates) < max(minCount, 2) {
+		return Recurrence{}, false
+	}
+	slices.SortFunc(dates, func(d1, d2 time.Time) int { return d1.Compare(d2) })
+	gaps := make([]float64, 0, len(dates)-1)
+	for i := 1; i < len(dates); i++ {
+		gaps = append(gaps, dates[i].Sub(dates[i-1]).Hours()/24)
+	}
+	interval, ok := classify(gaps)
+	if !ok {
+		return Recurrence{}, false
+	}
+	vals := make([]decimal.Decimal, 0, len(dates))
+	for _, d := range dates {
+		vals = append(vals, c.bookings[d])
+	}
+	amount := median(vals)
+	var stable int
+	for _, v := range vals {
+		if v.Sub(amount).Abs().LessThanOrEqual(amount.Abs().Mul(decimal.NewFromFloat(tolerance))) {
+			stable++
+		}
+	}
+	if float64(stable) < regularity*float64(len(vals)) {
+		return Recurrence{}, false
+	}
+	last := dates[len(dates)-1]
+	return Recurrence{
+		Description: c.description,
+		Interval:    interval,
+		Count:       len(dates),
+		Last:        last,
+		Next:        next(last, interval),
+		Amount:      amount,
+	}, true
+}
+
+// classify returns the interval which matches the median gap, if most gaps
+// match it.
+func classify(gaps []float64) (date.Interval, bool) {
+	sorted := slices.Clone(gaps)
+	slices.Sort(sorted)
+	m := sorted[len(sorted)/2]
+	for _, interval := range intervals {
+		if !matches(m, interval) {
+			continue
+		}
+		var regular int
+		for _, g := range gaps {
+			if matches(g, interval) {
+				regular++
+			}
+		}
+		return interval, float64(regular) >= regularity*float64(len(gaps))
+	}
+	return date.Once, false
+}
+
+func matches(gap float64, interval date.Interval) bool {
+	n := nominalDays[interval]
+	return gap >= n*(1-tolerance) && gap <= n*(1+tolerance)
+}
+
+func median(vals []decimal.Decimal) decimal.Decimal {
+	sorted := slices.Clone(vals)
+	slices.SortFunc(sorted, func(v1, v2 decimal.Decimal) int { return v1.Cmp(v2) })
+	return sorted[len(sorted)/2]
+}
+
+func next(t time.Time, interval date.Interval) time.Time {
+	switch interval {
+	case date.Weekly:
+		return t.AddDate(0, 0, 7)
+	case date.Monthly:
+		return t.AddDate(0, 1, 0)
+	case date.Quarterly:
+		return t.AddDate(0, 3, 0)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+// Renderer renders recurring payments.
+type Renderer struct {
+	ShowCommodities bool
+}
+
+// Render renders the recurring payments.
+func (rn *Renderer) Render(rs []Recurrence) *table.Table {
+	return rn.Build(rs).Table()
+}
+
+// Build builds the view of the recurring payments.
+func (rn *Renderer) Build(rs []Recurrence) *view.Report {
+	res := new(view.Report)
+	res.AddColumn("Payee", 0)
+	res.AddColumn("Account", 1)
+	if rn.ShowCommodities {
+		res.AddColumn("Comm", 2)
+	}
+	res.AddColumn("Interval", 3)
+	res.AddColumn("Count", 4)
+	res.AddColumn("Last", 5)
+	res.AddColumn("Next", 5)
+	res.AddColumn("Amount", 6)
+	res.AddColumn("Annual", 6)
+	s := res.AddSection()
+	totals := make(map[*model.Commodity]decimal.Decimal)
+	for _, r := range rs {
+		line := s.AddRow(r.Description, 0).AddLine()
+		line.AddText(r.Account.Name())
+		if rn.ShowCommodities {
+			line.AddCommodity(r.Commodity.Name())
+		}
+		line.AddText(r.Interval.String()).
+			AddText(strconv.Itoa(r.Count)).
+			AddText(r.Last.Format("2006-01-02")).
+			AddText(r.Next.Format("2006-01-02")).
+			AddDecimal(r.Amount).
+			AddDecimal(r.AnnualCost())
+		totals[r.Commodity] = totals[r.Commodity].Add(r.AnnualCost())
+	}
+	total := res.AddSection().AddRow("Total", 0)
+	total.Total = true
+	for _, com := range dict.SortedKeys(totals, commodity.Compare) {
+		line := total.AddLine().AddEmpty()
+		if rn.ShowCommodities {
+			line.AddCommodity(com.Name())
+		}
+		line.AddEmpty().AddEmpty().AddEmpty().AddEmpty().AddEmpty().AddDecimal(totals[com])
+	}
+	return res
+}
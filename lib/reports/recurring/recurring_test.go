@@ -0,0 +1,98 @@
+package recurring
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestDetect(t *testing.T) {
+	var (
+		reg       = registry.New()
+		chf       = reg.Commodities().MustGet("CHF")
+		rent      = reg.Accounts().MustGet("Expenses:Rent")
+		streaming = reg.Accounts().MustGet("Expenses:Streaming")
+		food      = reg.Accounts().MustGet("Expenses:Food")
+		insurance = reg.Accounts().MustGet("Expenses:Insurance")
+	)
+	rep := NewReport()
+	insert := func(day time.Time, desc string, acc *model.Account, qty float64) {
+		rep.Insert(amounts.Key{Date: day, Account: acc, Commodity: chf, Description: desc}, decimal.NewFromFloat(qty))
+	}
+	for m := time.January; m <= time.June; m++ {
+		// Rent is paid around the end of the month, with a different
+		// reference every month.
+		insert(date.EndOf(date.Date(2023, m, 1), date.Monthly).AddDate(0, 0, -int(m%3)), fmt.Sprintf("Rent %02d/2023", m), rent, 2000)
+		insert(date.Date(2023, m, 3), "NETFLIX.COM", streaming, 15.9)
+		// Groceries are bought irregularly and with varying amounts.
+		insert(date.Date(2023, m, int(m)*4), "Migros", food, 10*float64(m))
+	}
+	// A price increase doesn't break the series.
+	insert(date.Date(2023, 7, 3), "Netflix.com", streaming, 17.9)
+	insert(date.Date(2023, 1, 15), "Helsana", insurance, 300)
+	insert(date.Date(2023, 4, 14), "Helsana", insurance, 300)
+	// Too few bookings.
+	insert(date.Date(2023, 1, 1), "Gym", food, 500)
+	insert(date.Date(2024, 1, 1), "Gym", food, 500)
+
+	var got []string
+	for _, r := range rep.Detect(3) {
+		got = append(got, fmt.Sprintf("%s %s %s %s %d %s %s %s",
+			r.Description, r.Account.Name(), r.Commodity.Name(), r.Interval, r.Count,
+			r.Last.Format("2006-01-02"), r.Next.Format("2006-01-02"), r.Amount))
+	}
+
+	want := []string{
+		"Rent 06/2023 Expenses:Rent CHF monthly 6 2023-06-30 2023-07-30 2000",
+		"Netflix.com Expenses:Streaming CHF monthly 7 2023-07-03 2023-08-03 15.9",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Detect() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if got := rep.Detect(2); len(got) != 4 {
+		t.Errorf("Detect(2) returned %d recurring payments, want 4", len(got))
+	}
+}
+
+func TestRecurrence(t *testing.T) {
+	r := Recurrence{
+		Interval: date.Quarterly,
+		Last:     date.Date(2023, 1, 15),
+		Next:     date.Date(2023, 4, 15),
+		Amount:   decimal.NewFromInt(300),
+	}
+	if got, want := r.AnnualCost(), decimal.NewFromInt(1200); !got.Equal(want) {
+		t.Errorf("AnnualCost() = %s, want %s", got, want)
+	}
+	for _, test := range []struct {
+		date time.Time
+		want bool
+	}{
+		{date.Date(2023, 4, 20), false},
+		{date.Date(2023, 5, 30), false},
+		{date.Date(2023, 6, 1), true},
+	} {
+		if got := r.Ended(test.date); got != test.want {
+			t.Errorf("Ended(%s) = %t, want %t", test.date.Format("2006-01-02"), got, test.want)
+		}
+	}
+}
+
+func TestPayee(t *testing.T) {
+	for desc, want := range map[string]string{
+		"Rent 06/2023":          "rent",
+		"NETFLIX.COM":           "netflix com",
+		"Zürich Versicherung 7": "zürich versicherung",
+	} {
+		if got := Payee(desc); got != want {
+			t.Errorf("Payee(%q) = %q, want %q", desc, got, want)
+		}
+	}
+}
@@ -1,33 +1,94 @@
 package register
 
 import (
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/sboehler/knut/lib/amounts"
 	"github.com/sboehler/knut/lib/common/compare"
-	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/mapper"
 	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/view"
 	"github.com/shopspring/decimal"
 )
 
 type Report struct {
 	Context *registry.Registry
 
-	nodes map[time.Time]*Node
+	// nodes are sorted by date.
+	nodes []*Node
 }
 
 type Node struct {
 	Date    time.Time
 	Amounts amounts.Amounts
+
+	// Trades are the quantities and the costs of the rows, keyed without
+	// the valuation. They are only recorded for the trades columns.
+	Trades map[amounts.Key]Trade
+}
+
+// Trade is the quantity of the postings of a row, with the quantity and the
+// total cost of those whose lot has a cost.
+type Trade struct {
+	Quantity             decimal.Decimal
+	LotQuantity, LotCost decimal.Decimal
+}
+
+// Add returns the sum of two trades.
+func (t Trade) Add(o Trade) Trade {
+	return Trade{
+		Quantity:    t.Quantity.Add(o.Quantity),
+		LotQuantity: t.LotQuantity.Add(o.LotQuantity),
+		LotCost:     t.LotCost.Add(o.LotCost),
+	}
+}
+
+// Price returns the average cost per unit of the lots of the trade. It is
+// false if none of the postings has a cost.
+func (t Trade) Price() (decimal.Decimal, bool) {
+	if t.LotQuantity.IsZero() {
+		return decimal.Zero, false
+	}
+	return t.LotCost.Div(t.LotQuantity).Abs(), true
+}
+
+func (n *Node) addTrade(k amounts.Key, t Trade) {
+	if n.Trades == nil {
+		n.Trades = make(map[amounts.Key]Trade)
+	}
+	n.Trades[k] = n.Trades[k].Add(t)
 }
 
 func NewReport(reg *registry.Registry) *Report {
-	return &Report{
-		nodes: make(map[time.Time]*Node),
+	return new(Report)
+}
+
+// Grow reserves space for n dates, e.g. the size of the partition.
+func (r *Report) Grow(n int) {
+	r.nodes = slices.Grow(r.nodes, n)
+}
+
+// node returns the node for the given date, inserting it if necessary.
+func (r *Report) node(d time.Time) *Node {
+	// Dates are mostly inserted in ascending order.
+	if l := len(r.nodes); l > 0 && r.nodes[l-1].Date.Equal(d) {
+		return r.nodes[l-1]
+	}
+	i := sort.Search(len(r.nodes), func(i int) bool {
+		return !r.nodes[i].Date.Before(d)
+	})
+	if i < len(r.nodes) && r.nodes[i].Date.Equal(d) {
+		return r.nodes[i]
 	}
+	n := newNode(d)
+	r.nodes = slices.Insert(r.nodes, i, n)
+	return n
 }
 
 func newNode(d time.Time) *Node {
@@ -38,93 +99,241 @@ func newNode(d time.Time) *Node {
 }
 
 func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
-	n := dict.GetDefault(r.nodes, k.Date, func() *Node { return newNode(k.Date) })
-	n.Amounts.Add(k, v)
+	r.node(k.Date).Amounts.Add(k, v)
+}
+
+// Trades returns a collection which records the quantities and the costs
+// of the lots of the postings alongside the amounts of the report. Keys are
+// matched with the amounts ignoring the valuation.
+func (r *Report) Trades() *Trades {
+	return &Trades{report: r}
+}
+
+// Trades collects the trades of a report.
+type Trades struct {
+	report *Report
+}
+
+func (ts *Trades) InsertPosting(k amounts.Key, p *model.Posting) {
+	t := Trade{Quantity: p.Quantity}
+	if p.CostCommodity != nil {
+		t.LotQuantity, t.LotCost = p.Quantity, p.Quantity.Mul(p.Cost)
+	}
+	k.Valuation = nil
+	ts.report.node(k.Date).addTrade(k, t)
 }
 
 type Renderer struct {
 	ShowCommodities    bool
 	ShowSource         bool
 	ShowDescriptions   bool
+	ShowComments       bool
+	ShowTrades         bool
 	SortAlphabetically bool
+
+	// ByDescription shows a row per description instead of a row per dest
+	// account. The amounts of a description are summed over the dest
+	// accounts, which are not shown.
+	ByDescription bool
+
+	// Periods maps each date to the period it belongs to. If it is set,
+	// the rows are grouped by period, with a subtotal after each period and
+	// a grand total at the end.
+	Periods mapper.Mapper[time.Time]
 }
 
 func (rn *Renderer) Render(r *Report) *table.Table {
-	cols := []int{1, 1, 1}
-	if rn.ShowCommodities {
-		cols = append(cols, 1)
+	return rn.Build(r).Table()
+}
+
+// Build builds the view of a report.
+func (rn *Renderer) Build(r *Report) *view.Report {
+	res := new(view.Report)
+	group := 0
+	addColumn := func(header string) {
+		res.AddColumn(header, group)
+		group++
 	}
+	addColumn("Date")
 	if rn.ShowSource {
-		cols = append(cols, 1)
+		addColumn("Source")
 	}
-	if rn.ShowDescriptions {
-		cols = append(cols, 1)
-	}
-	tbl := table.New(cols...)
-	tbl.AddSeparatorRow()
-	header := tbl.AddRow().AddText("Date", table.Center)
-	if rn.ShowSource {
-		header.AddText("Source", table.Center)
+	if rn.ByDescription {
+		addColumn("Desc")
+	} else {
+		addColumn("Dest")
 	}
-	header.AddText("Dest", table.Center)
-	header.AddText("Amount", table.Center)
-	if rn.ShowCommodities {
-		header.AddText("Comm", table.Center)
+	if rn.ShowTrades {
+		addColumn("Quantity")
+		addColumn("Comm")
+		addColumn("Price")
+		addColumn("Amount")
+	} else {
+		addColumn("Amount")
+		if rn.ShowCommodities {
+			addColumn("Comm")
+		}
 	}
 	if rn.ShowDescriptions {
-		header.AddText("Desc", table.Center)
+		addColumn("Desc")
+	}
+	if rn.ShowComments {
+		addColumn("Comment")
 	}
-	tbl.AddSeparatorRow()
 
-	dates := dict.SortedKeys(r.nodes, compare.Time)
-	for _, d := range dates {
-		n := r.nodes[d]
-		rn.renderNode(tbl, n)
+	if rn.Periods == nil {
+		for _, n := range r.nodes {
+			rn.renderNode(res.AddSection(), n.Date.Format("2006-01-02"), false, n)
+		}
+		return res
+	}
+	var (
+		s            *view.Section
+		period       time.Time
+		total, grand = newNode(time.Time{}), newNode(time.Time{})
+	)
+	for _, n := range r.nodes {
+		if p := rn.Periods(n.Date); s == nil || !p.Equal(period) {
+			if s != nil {
+				rn.renderNode(s, "Total", true, total)
+			}
+			s, period, total = res.AddSection(), p, newNode(time.Time{})
+		}
+		rn.renderNode(s, n.Date.Format("2006-01-02"), false, n)
+		for _, t := range []*Node{total, grand} {
+			n.Amounts.SumIntoBy(t.Amounts, nil, subtotalKey)
+			for k, trade := range n.Trades {
+				t.addTrade(subtotalKey(k), trade)
+			}
+		}
+	}
+	if s != nil {
+		rn.renderNode(s, "Total", true, total)
 	}
-	return tbl
+	rn.renderNode(res.AddSection(), "Grand total", true, grand)
+	return res
 }
 
-func (rn *Renderer) renderNode(tbl *table.Table, n *Node) {
-	var cmp compare.Compare[amounts.Key]
+// subtotalKey maps a key to the key of its subtotal, which omits the date
+// and the texts of the individual postings.
+func subtotalKey(k amounts.Key) amounts.Key {
+	k.Date = time.Time{}
+	k.Description = ""
+	k.Comment = ""
+	return k
+}
+
+// index returns the keys of the amounts of a node in the order of the rows.
+func (rn *Renderer) index(n *Node) []amounts.Key {
+	if rn.ByDescription {
+		return n.Amounts.Index(rn.compareDescription)
+	}
 	if rn.ShowCommodities {
-		cmp = compareAccountAndCommodities
-	} else {
-		cmp = compareAccount
+		return n.Amounts.Index(compareAccountAndCommodities)
 	}
-	idx := n.Amounts.Index(cmp)
-	for i, k := range idx {
-		row := tbl.AddRow()
-		if i == 0 {
-			row.AddText(n.Date.Format("2006-01-02"), table.Left)
-		} else {
-			row.AddEmpty()
-		}
+	return n.Amounts.Index(compareAccount)
+}
+
+func (rn *Renderer) renderNode(s *view.Section, label string, total bool, n *Node) {
+	idx := rn.index(n)
+	if len(idx) == 0 {
+		return
+	}
+	row := s.AddRow(label, 0)
+	row.Total = total
+	for _, k := range idx {
+		line := row.AddLine()
 		if rn.ShowSource {
-			row.AddText(k.Account.Name(), table.Left)
+			line.AddText(k.Account.Name())
 		}
-		row.AddText(k.Other.Name(), table.Left)
-		row.AddDecimal(n.Amounts[k].Neg())
-		if rn.ShowCommodities {
-			row.AddText(k.Commodity.Name(), table.Left)
+		if rn.ByDescription {
+			line.AddText(truncate(k.Description))
+		} else {
+			line.AddText(k.Other.Name())
 		}
-		if rn.ShowDescriptions {
-			desc := k.Description
-			if len(desc) > 100 {
-				desc = desc[:100]
+		if rn.ShowTrades {
+			rn.renderTrade(line, n, k)
+		} else {
+			line.AddDecimal(flow(k, n.Amounts[k]))
+			if rn.ShowCommodities {
+				line.AddCommodity(k.Commodity.Name())
 			}
-			row.AddText(desc, table.Left)
 		}
+		if rn.ShowDescriptions {
+			line.AddText(truncate(k.Description))
+		}
+		if rn.ShowComments {
+			line.AddText(k.Comment)
+		}
+	}
+}
+
+func truncate(desc string) string {
+	if len(desc) > 100 {
+		return desc[:100]
+	}
+	return desc
+}
+
+func (rn *Renderer) renderTrade(line *view.Line, n *Node, k amounts.Key) {
+	qk := k
+	qk.Valuation = nil
+	t := n.Trades[qk]
+	line.AddDecimal(flow(k, t.Quantity))
+	line.AddCommodity(k.Commodity.Name())
+	if price, ok := t.Price(); ok {
+		line.AddDecimal(price)
+	} else {
+		line.AddEmpty()
 	}
-	tbl.AddSeparatorRow()
+	line.AddDecimal(flow(k, n.Amounts[k]))
+}
+
+// flow returns the amount flowing into the other account of the key. Virtual
+// postings have no counterpart, their account is also the other account.
+func flow(k amounts.Key, v decimal.Decimal) decimal.Decimal {
+	if k.Virtual {
+		return v
+	}
+	return v.Neg()
 }
 
 func compareAccount(k1, k2 amounts.Key) compare.Order {
-	return account.Compare(k1.Other, k2.Other)
+	if c := account.Compare(k1.Other, k2.Other); c != compare.Equal {
+		return c
+	}
+	if c := compare.Ordered(k1.Description, k2.Description); c != compare.Equal {
+		return c
+	}
+	return compare.Ordered(k1.Comment, k2.Comment)
 }
 
 func compareAccountAndCommodities(k1, k2 amounts.Key) compare.Order {
 	if c := account.Compare(k1.Other, k2.Other); c != compare.Equal {
 		return c
 	}
-	return commodity.Compare(k1.Commodity, k2.Commodity)
+	if c := commodity.Compare(k1.Commodity, k2.Commodity); c != compare.Equal {
+		return c
+	}
+	if c := compare.Ordered(k1.Description, k2.Description); c != compare.Equal {
+		return c
+	}
+	return compare.Ordered(k1.Comment, k2.Comment)
+}
+
+func (rn *Renderer) compareDescription(k1, k2 amounts.Key) compare.Order {
+	if c := compare.Ordered(k1.Description, k2.Description); c != compare.Equal {
+		return c
+	}
+	if rn.ShowSource {
+		if c := account.Compare(k1.Account, k2.Account); c != compare.Equal {
+			return c
+		}
+	}
+	if rn.ShowCommodities {
+		if c := commodity.Compare(k1.Commodity, k2.Commodity); c != compare.Equal {
+			return c
+		}
+	}
+	return compare.Ordered(k1.Comment, k2.Comment)
 }
@@ -0,0 +1,163 @@
+package register
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/view"
+	"github.com/shopspring/decimal"
+)
+
+func TestReportOrdersDates(t *testing.T) {
+	reg := registry.New()
+	a := reg.Accounts().MustGet("Assets:A")
+	b := reg.Accounts().MustGet("Expenses:B")
+	chf := reg.Commodities().MustGet("CHF")
+	rep := NewReport(reg)
+	rep.Grow(3)
+	for _, d := range []time.Time{
+		date.Date(2023, 1, 2),
+		date.Date(2023, 1, 3),
+		date.Date(2023, 1, 1),
+		date.Date(2023, 1, 3),
+		date.Date(2023, 1, 2),
+	} {
+		rep.Insert(amounts.Key{Date: d, Account: a, Other: b, Commodity: chf}, decimal.NewFromInt(1))
+	}
+
+	got := new(Renderer).Build(rep)
+
+	var labels []string
+	var values []string
+	for _, s := range got.Sections {
+		for _, r := range s.Rows {
+			labels = append(labels, r.Label)
+			values = append(values, r.Lines[0][1].Decimal.String())
+		}
+	}
+	if diff := cmp.Diff([]string{"2023-01-01", "2023-01-02", "2023-01-03"}, labels); diff != "" {
+		t.Errorf("Build() returned unexpected dates (-want/+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"-1", "-2", "-2"}, values); diff != "" {
+		t.Errorf("Build() returned unexpected amounts (-want/+got):\n%s", diff)
+	}
+	for _, n := range rep.nodes {
+		if n.Trades != nil {
+			t.Errorf("node %s has trades, want none without the trades columns", n.Date.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestRenderSubtotals(t *testing.T) {
+	reg := registry.New()
+	a := reg.Accounts().MustGet("Assets:A")
+	b := reg.Accounts().MustGet("Expenses:B")
+	chf := reg.Commodities().MustGet("CHF")
+	rep := NewReport(reg)
+	for _, d := range []time.Time{
+		date.Date(2023, 1, 2),
+		date.Date(2023, 1, 3),
+		date.Date(2023, 2, 1),
+	} {
+		rep.Insert(amounts.Key{Date: d, Account: a, Other: b, Commodity: chf, Description: d.String()}, decimal.NewFromInt(1))
+	}
+	part := date.NewPartition(date.Period{Start: date.Date(2023, 1, 1), End: date.Date(2023, 2, 28)}, date.Monthly, 0)
+
+	got := (&Renderer{Periods: part.Align()}).Build(rep)
+
+	var labels [][]string
+	var values [][]string
+	for _, s := range got.Sections {
+		var ls, vs []string
+		for _, r := range s.Rows {
+			ls = append(ls, r.Label)
+			vs = append(vs, r.Lines[0][1].Decimal.String())
+		}
+		labels = append(labels, ls)
+		values = append(values, vs)
+	}
+	wantLabels := [][]string{
+		{"2023-01-02", "2023-01-03", "Total"},
+		{"2023-02-01", "Total"},
+		{"Grand total"},
+	}
+	if diff := cmp.Diff(wantLabels, labels); diff != "" {
+		t.Errorf("Build() returned unexpected rows (-want/+got):\n%s", diff)
+	}
+	wantValues := [][]string{{"-1", "-1", "-2"}, {"-1", "-1"}, {"-3"}}
+	if diff := cmp.Diff(wantValues, values); diff != "" {
+		t.Errorf("Build() returned unexpected amounts (-want/+got):\n%s", diff)
+	}
+}
+
+func TestRenderByDescription(t *testing.T) {
+	reg := registry.New()
+	a := reg.Accounts().MustGet("Assets:A")
+	chf := reg.Commodities().MustGet("CHF")
+	rep := NewReport(reg)
+	d := date.Date(2023, 12, 31)
+	for _, desc := range []string{"migros", "coop", "migros"} {
+		rep.Insert(amounts.Key{Date: d, Account: a, Commodity: chf, Description: desc}, decimal.NewFromInt(-2))
+	}
+
+	got := (&Renderer{ByDescription: true}).Build(rep)
+
+	var headers []string
+	for _, c := range got.Columns {
+		headers = append(headers, c.Header)
+	}
+	if diff := cmp.Diff([]string{"Date", "Desc", "Amount"}, headers); diff != "" {
+		t.Errorf("Build() returned unexpected headers (-want/+got):\n%s", diff)
+	}
+	var lines [][]string
+	for _, l := range got.Sections[0].Rows[0].Lines {
+		lines = append(lines, []string{l[0].Text, l[1].Decimal.String()})
+	}
+	if diff := cmp.Diff([][]string{{"coop", "2"}, {"migros", "4"}}, lines); diff != "" {
+		t.Errorf("Build() returned unexpected lines (-want/+got):\n%s", diff)
+	}
+}
+
+func TestRenderTrades(t *testing.T) {
+	reg := registry.New()
+	cash := reg.Accounts().MustGet("Assets:Cash")
+	broker := reg.Accounts().MustGet("Assets:Broker")
+	chf := reg.Commodities().MustGet("CHF")
+	aapl := reg.Commodities().MustGet("AAPL")
+	rep := NewReport(reg)
+	trades := rep.Trades()
+	for _, p := range []struct {
+		date  time.Time
+		value int64
+		p     *model.Posting
+	}{
+		// The price is the cost of the lot, not the value per unit.
+		{date.Date(2023, 1, 1), -1600, &model.Posting{Quantity: decimal.NewFromInt(-10), Cost: decimal.NewFromInt(150), CostCommodity: chf}},
+		{date.Date(2023, 1, 2), -900, &model.Posting{Quantity: decimal.NewFromInt(-5)}},
+	} {
+		k := amounts.Key{Date: p.date, Account: cash, Other: broker, Commodity: aapl, Valuation: chf}
+		rep.Insert(k, decimal.NewFromInt(p.value))
+		trades.InsertPosting(k, p.p)
+	}
+
+	got := (&Renderer{ShowTrades: true}).Build(rep)
+
+	var lines [][]string
+	for _, s := range got.Sections {
+		l := s.Rows[0].Lines[0]
+		price := ""
+		if l[3].Kind == view.Decimal {
+			price = l[3].Decimal.String()
+		}
+		lines = append(lines, []string{l[1].Decimal.String(), l[2].Text, price, l[4].Decimal.String()})
+	}
+	want := [][]string{{"10", "AAPL", "150", "1600"}, {"5", "AAPL", "", "900"}}
+	if diff := cmp.Diff(want, lines); diff != "" {
+		t.Errorf("Build() returned unexpected lines (-want/+got):\n%s", diff)
+	}
+}
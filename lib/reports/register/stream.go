@@ -0,0 +1,193 @@
+package register
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/shopspring/decimal"
+)
+
+// Stream is a collection which writes the rows of a register as the amounts
+// are inserted, instead of building the report in memory. Amounts must be
+// inserted in ascending order of their dates, as they are when processing a
+// journal. The amounts of a date are kept until a later date is inserted.
+type Stream struct {
+	rn   *Renderer
+	enc  rowEncoder
+	node *Node
+	err  error
+}
+
+// Row is a row of a streamed register.
+type Row struct {
+	Date        string          `json:"date"`
+	Source      string          `json:"source,omitempty"`
+	Dest        string          `json:"dest,omitempty"`
+	Amount      decimal.Decimal `json:"amount"`
+	Commodity   string          `json:"commodity,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Comment     string          `json:"comment,omitempty"`
+}
+
+type rowEncoder interface {
+	encode(Row) error
+	close() error
+}
+
+// NewCSVStream creates a stream which writes CSV with a header row. The
+// columns are determined by the renderer.
+func (rn *Renderer) NewCSVStream(w io.Writer) *Stream {
+	enc := &csvEncoder{rn: rn, w: csv.NewWriter(w)}
+	s := &Stream{rn: rn, enc: enc}
+	s.err = enc.header()
+	return s
+}
+
+// NewJSONStream creates a stream which writes a JSON array of rows. Fields
+// without a value are omitted.
+func (rn *Renderer) NewJSONStream(w io.Writer) *Stream {
+	return &Stream{rn: rn, enc: &jsonEncoder{w: bufio.NewWriter(w)}}
+}
+
+// Insert inserts an amount.
+func (s *Stream) Insert(k amounts.Key, v decimal.Decimal) {
+	if s.err != nil {
+		return
+	}
+	if s.node == nil || k.Date.After(s.node.Date) {
+		s.flush()
+		s.node = newNode(k.Date)
+	} else if k.Date.Before(s.node.Date) {
+		s.err = fmt.Errorf("amount of %s inserted after %s", k.Date.Format("2006-01-02"), s.node.Date.Format("2006-01-02"))
+		return
+	}
+	s.node.Amounts.Add(k, v)
+}
+
+func (s *Stream) flush() {
+	if s.node == nil || s.err != nil {
+		return
+	}
+	for _, k := range s.rn.index(s.node) {
+		row := Row{
+			Date:        s.node.Date.Format("2006-01-02"),
+			Amount:      flow(k, s.node.Amounts[k]),
+			Description: k.Description,
+			Comment:     k.Comment,
+		}
+		if k.Other != nil {
+			row.Dest = k.Other.Name()
+		}
+		if k.Account != nil {
+			row.Source = k.Account.Name()
+		}
+		if k.Commodity != nil {
+			row.Commodity = k.Commodity.Name()
+		}
+		if s.err = s.enc.encode(row); s.err != nil {
+			return
+		}
+	}
+}
+
+// Close writes the remaining rows and returns the first error which
+// occurred while writing.
+func (s *Stream) Close() error {
+	s.flush()
+	s.node = nil
+	if s.err != nil {
+		return s.err
+	}
+	return s.enc.close()
+}
+
+type csvEncoder struct {
+	rn *Renderer
+	w  *csv.Writer
+}
+
+func (e *csvEncoder) header() error {
+	rec := []string{"Date"}
+	if e.rn.ShowSource {
+		rec = append(rec, "Source")
+	}
+	if e.rn.ByDescription {
+		rec = append(rec, "Desc", "Amount")
+	} else {
+		rec = append(rec, "Dest", "Amount")
+	}
+	if e.rn.ShowCommodities {
+		rec = append(rec, "Comm")
+	}
+	if e.rn.ShowDescriptions {
+		rec = append(rec, "Desc")
+	}
+	if e.rn.ShowComments {
+		rec = append(rec, "Comment")
+	}
+	return e.w.Write(rec)
+}
+
+func (e *csvEncoder) encode(r Row) error {
+	rec := []string{r.Date}
+	if e.rn.ShowSource {
+		rec = append(rec, r.Source)
+	}
+	if e.rn.ByDescription {
+		rec = append(rec, r.Description, r.Amount.String())
+	} else {
+		rec = append(rec, r.Dest, r.Amount.String())
+	}
+	if e.rn.ShowCommodities {
+		rec = append(rec, r.Commodity)
+	}
+	if e.rn.ShowDescriptions {
+		rec = append(rec, r.Description)
+	}
+	if e.rn.ShowComments {
+		rec = append(rec, r.Comment)
+	}
+	return e.w.Write(rec)
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonEncoder struct {
+	w    *bufio.Writer
+	rows int
+}
+
+func (e *jsonEncoder) encode(r Row) error {
+	sep := ",\n"
+	if e.rows == 0 {
+		sep = "[\n"
+	}
+	e.rows++
+	if _, err := e.w.WriteString(sep); err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonEncoder) close() error {
+	end := "\n]\n"
+	if e.rows == 0 {
+		end = "[]\n"
+	}
+	if _, err := e.w.WriteString(end); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
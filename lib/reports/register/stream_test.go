@@ -0,0 +1,87 @@
+package register
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestStream(t *testing.T) {
+	reg := registry.New()
+	a := reg.Accounts().MustGet("Assets:A")
+	b := reg.Accounts().MustGet("Expenses:B")
+	chf := reg.Commodities().MustGet("CHF")
+	insert := func(s *Stream) {
+		for _, d := range []time.Time{
+			date.Date(2023, 1, 1),
+			date.Date(2023, 1, 1),
+			date.Date(2023, 1, 2),
+		} {
+			s.Insert(amounts.Key{Date: d, Account: a, Other: b, Commodity: chf, Description: "Shop, Inc."}, decimal.NewFromInt(1))
+		}
+	}
+	rn := &Renderer{ShowSource: true, ShowCommodities: true, ShowDescriptions: true}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf strings.Builder
+		s := rn.NewCSVStream(&buf)
+		insert(s)
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() returned unexpected error: %v", err)
+		}
+		want := strings.Join([]string{
+			"Date,Source,Dest,Amount,Comm,Desc",
+			`2023-01-01,Assets:A,Expenses:B,-2,CHF,"Shop, Inc."`,
+			`2023-01-02,Assets:A,Expenses:B,-1,CHF,"Shop, Inc."`,
+			"",
+		}, "\n")
+		if diff := cmp.Diff(want, buf.String()); diff != "" {
+			t.Errorf("stream wrote unexpected CSV (-want/+got):\n%s", diff)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf strings.Builder
+		s := rn.NewJSONStream(&buf)
+		insert(s)
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() returned unexpected error: %v", err)
+		}
+		want := strings.Join([]string{
+			"[",
+			`{"date":"2023-01-01","source":"Assets:A","dest":"Expenses:B","amount":"-2","commodity":"CHF","description":"Shop, Inc."},`,
+			`{"date":"2023-01-02","source":"Assets:A","dest":"Expenses:B","amount":"-1","commodity":"CHF","description":"Shop, Inc."}`,
+			"]",
+			"",
+		}, "\n")
+		if diff := cmp.Diff(want, buf.String()); diff != "" {
+			t.Errorf("stream wrote unexpected JSON (-want/+got):\n%s", diff)
+		}
+	})
+
+	t.Run("empty json", func(t *testing.T) {
+		var buf strings.Builder
+		if err := rn.NewJSONStream(&buf).Close(); err != nil {
+			t.Fatalf("Close() returned unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "[]\n"; got != want {
+			t.Errorf("stream wrote %q, want %q", got, want)
+		}
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		var buf strings.Builder
+		s := rn.NewCSVStream(&buf)
+		s.Insert(amounts.Key{Date: date.Date(2023, 1, 2), Account: a, Other: b, Commodity: chf}, decimal.NewFromInt(1))
+		s.Insert(amounts.Key{Date: date.Date(2023, 1, 1), Account: a, Other: b, Commodity: chf}, decimal.NewFromInt(1))
+		if err := s.Close(); err == nil {
+			t.Errorf("Close() returned no error, want an error for amounts out of order")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+// Package seasonality aggregates amounts by the seasons of a date.Cycle, e.g.
+// by weekday or by calendar month, to reveal recurring patterns.
+package seasonality
+
+import (
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/multimap"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/reports/view"
+	"github.com/shopspring/decimal"
+)
+
+type Report struct {
+	cycle    date.Cycle
+	accounts *Node
+	total    amounts.Amounts
+}
+
+type Value struct {
+	Account *model.Account
+	Amounts amounts.Amounts
+}
+
+type Node = multimap.Node[Value]
+
+func NewReport(c date.Cycle) *Report {
+	return &Report{
+		cycle:    c,
+		accounts: multimap.New[Value](""),
+		total:    make(amounts.Amounts),
+	}
+}
+
+// Insert inserts an amount. Keys are expected to carry an account, a season
+// and optionally a commodity.
+func (r *Report) Insert(k amounts.Key, v decimal.Decimal) {
+	if k.Account == nil {
+		return
+	}
+	n := r.accounts.GetOrCreate(k.Account.Segments())
+	if n.Value.Amounts == nil {
+		n.Value.Account = k.Account
+		n.Value.Amounts = make(amounts.Amounts)
+	}
+	key := amounts.Key{Season: k.Season, Commodity: k.Commodity}
+	n.Value.Amounts.Add(key, v)
+	r.total.Add(key, v)
+}
+
+// Renderer renders a report.
+type Renderer struct {
+	ShowCommodities bool
+
+	// Occurrences, if set, holds the number of occurrences of each season,
+	// see date.Cycle.Occurrences. The amounts of a season are divided by its
+	// occurrences, e.g. to show the average expenses per Monday instead of
+	// their sum. The total is the sum of the averages.
+	Occurrences []int
+}
+
+func (rn *Renderer) Render(r *Report) *table.Table {
+	return rn.Build(r).Table()
+}
+
+// Build builds the view of a report.
+func (rn *Renderer) Build(r *Report) *view.Report {
+	r.accounts.Sort(multimap.SortAlpha[Value])
+	res := new(view.Report)
+	res.AddColumn("Account", 0)
+	if rn.ShowCommodities {
+		res.AddColumn("Comm", 1)
+	}
+	for s := 0; s < r.cycle.Size(); s++ {
+		res.AddColumn(r.cycle.Label(s), 2)
+	}
+	res.AddColumn("Total", 3)
+	s := res.AddSection()
+	for _, n := range r.accounts.Sorted {
+		rn.renderNode(s, r.cycle, 0, n)
+	}
+	rn.render(res.AddSection(), r.cycle, 0, "Total", r.total).Total = true
+	return res
+}
+
+func (rn *Renderer) renderNode(s *view.Section, c date.Cycle, depth int, n *Node) {
+	rn.render(s, c, depth, n.Segment, n.Value.Amounts)
+	for _, ch := range n.Sorted {
+		rn.renderNode(s, c, depth+1, ch)
+	}
+}
+
+func (rn *Renderer) render(s *view.Section, c date.Cycle, depth int, label string, vals amounts.Amounts) *view.Row {
+	row := s.AddRow(label, depth)
+	for _, com := range dict.SortedKeys(vals.Commodities(), commodity.Compare) {
+		line := row.AddLine()
+		if rn.ShowCommodities {
+			if com != nil {
+				line.AddCommodity(com.Name())
+			} else {
+				line.AddEmpty()
+			}
+		}
+		var total decimal.Decimal
+		for season := 0; season < c.Size(); season++ {
+			v := vals[amounts.Key{Season: season, Commodity: com}]
+			if rn.Occurrences != nil && rn.Occurrences[season] > 0 {
+				v = v.Div(decimal.NewFromInt(int64(rn.Occurrences[season])))
+			}
+			total = total.Add(v)
+			line.AddDecimal(v)
+		}
+		line.AddDecimal(total)
+	}
+	return row
+}
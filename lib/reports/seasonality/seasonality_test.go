@@ -0,0 +1,95 @@
+package seasonality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/mapper"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestReport(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		cycle    date.Cycle
+		renderer Renderer
+		want     map[string][]string
+	}{
+		{
+			desc:  "by month",
+			cycle: date.ByMonth,
+			want: map[string][]string{
+				"Food":  {"30", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "5", "35"},
+				"Rent":  {"1000", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "1000"},
+				"Total": {"1030", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "5", "1035"},
+			},
+		},
+		{
+			desc:  "by weekday",
+			cycle: date.ByWeekday,
+			want: map[string][]string{
+				"Food":  {"10", "0", "0", "0", "0", "20", "5", "35"},
+				"Rent":  {"1000", "0", "0", "0", "0", "0", "0", "1000"},
+				"Total": {"1010", "0", "0", "0", "0", "20", "5", "1035"},
+			},
+		},
+		{
+			desc:     "average by month",
+			cycle:    date.ByMonth,
+			renderer: Renderer{Occurrences: []int{2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+			want: map[string][]string{
+				"Food":  {"15", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "5", "20"},
+				"Rent":  {"500", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "500"},
+				"Total": {"515", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "5", "520"},
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var (
+				reg = registry.New()
+				chf = reg.Commodities().MustGet("CHF")
+				rep = NewReport(test.cycle)
+				km  = amounts.KeyMapper{
+					Account:   mapper.Identity[*model.Account],
+					Commodity: mapper.Identity[*model.Commodity],
+					Season:    test.cycle.Season,
+				}.Build()
+			)
+			for _, b := range []struct {
+				date    time.Time
+				account string
+				value   int64
+			}{
+				{date.Date(2022, 12, 25), "Expenses:Food", 5},  // Sunday
+				{date.Date(2023, 1, 2), "Expenses:Rent", 1000}, // Monday
+				{date.Date(2023, 1, 7), "Expenses:Food", 20},   // Saturday
+				{date.Date(2024, 1, 1), "Expenses:Food", 10},   // Monday
+			} {
+				k := km(amounts.Key{Date: b.date, Account: reg.Accounts().MustGet(b.account), Commodity: chf})
+				rep.Insert(k, decimal.NewFromInt(b.value))
+			}
+
+			got := make(map[string][]string)
+			for _, s := range test.renderer.Build(rep).Sections {
+				for _, row := range s.Rows {
+					if _, ok := test.want[row.Label]; !ok {
+						continue
+					}
+					for _, c := range row.Lines[0] {
+						got[row.Label] = append(got[row.Label], c.Decimal.String())
+					}
+				}
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Build() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
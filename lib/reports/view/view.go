@@ -0,0 +1,221 @@
+// Package view contains a renderer-agnostic model of a report. Report
+// builders produce a Report, which output formats consume without knowing
+// about the internals of the individual reports.
+package view
+
+import (
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/shopspring/decimal"
+)
+
+// Report is a report consisting of columns and sections of rows.
+type Report struct {
+	Columns  []Column
+	Sections []*Section
+}
+
+// Column is a column of a report.
+type Column struct {
+	Header string
+
+	// Group identifies a group of adjacent columns, which are rendered
+	// with the same width in tabular output.
+	Group int
+}
+
+// AddColumn adds a column.
+func (r *Report) AddColumn(header string, group int) {
+	r.Columns = append(r.Columns, Column{Header: header, Group: group})
+}
+
+// AddSection adds a section.
+func (r *Report) AddSection() *Section {
+	s := new(Section)
+	r.Sections = append(r.Sections, s)
+	return s
+}
+
+// Section is a group of rows, e.g. the assets and liabilities of a balance.
+type Section struct {
+	Rows []*Row
+
+	// Spaced marks sections whose top-level rows are visually separated.
+	Spaced bool
+}
+
+// AddRow adds a row.
+func (s *Section) AddRow(label string, depth int) *Row {
+	r := &Row{Label: label, Depth: depth}
+	s.Rows = append(s.Rows, r)
+	return r
+}
+
+// Row is a row of a report, consisting of a label in the first column and
+// one or more lines of cells for the remaining columns.
+type Row struct {
+	Label string
+
+	// Depth is the level of the row in the hierarchy of the report. Rows
+	// follow their parent row.
+	Depth int
+
+	// Total marks rows which contain totals.
+	Total bool
+
+	// Notes are explanatory texts, which are rendered as footnotes.
+	Notes []string
+
+	Lines [][]Cell
+}
+
+// AddLine adds a line.
+func (r *Row) AddLine() *Line {
+	r.Lines = append(r.Lines, nil)
+	return &Line{row: r, index: len(r.Lines) - 1}
+}
+
+// Line is a builder for a line of a row.
+type Line struct {
+	row   *Row
+	index int
+}
+
+func (l *Line) add(c Cell) *Line {
+	l.row.Lines[l.index] = append(l.row.Lines[l.index], c)
+	return l
+}
+
+// AddEmpty adds an empty cell.
+func (l *Line) AddEmpty() *Line {
+	return l.add(Cell{Kind: Empty})
+}
+
+// AddText adds a text cell.
+func (l *Line) AddText(s string) *Line {
+	return l.add(Cell{Kind: Text, Text: s})
+}
+
+// AddCommodity adds a cell with the name of a commodity.
+func (l *Line) AddCommodity(name string) *Line {
+	return l.add(Cell{Kind: Commodity, Text: name})
+}
+
+// AddDecimal adds a decimal cell.
+func (l *Line) AddDecimal(d decimal.Decimal) *Line {
+	return l.add(Cell{Kind: Decimal, Decimal: d})
+}
+
+// AddPercent adds a percentage cell.
+func (l *Line) AddPercent(f float64) *Line {
+	return l.add(Cell{Kind: Percent, Percent: f})
+}
+
+// Link links the last cell to the given URL.
+func (l *Line) Link(url string) *Line {
+	cells := l.row.Lines[l.index]
+	cells[len(cells)-1].Link = url
+	return l
+}
+
+// CellKind is the kind of a cell.
+type CellKind int
+
+const (
+	// Empty is an empty cell.
+	Empty CellKind = iota
+	// Text is a cell containing text.
+	Text
+	// Decimal is a cell containing a number.
+	Decimal
+	// Percent is a cell containing a percentage.
+	Percent
+	// Commodity is a cell containing the name of a commodity.
+	Commodity
+)
+
+// Cell is a cell of a report.
+type Cell struct {
+	Kind    CellKind
+	Text    string
+	Decimal decimal.Decimal
+	Percent float64
+
+	// Link is the URL of a report which explains the cell.
+	Link string
+}
+
+// Table lays out the report as a table.
+func (r *Report) Table() *table.Table {
+	var groups []int
+	for i, c := range r.Columns {
+		if i == 0 || c.Group != r.Columns[i-1].Group {
+			groups = append(groups, 0)
+		}
+		groups[len(groups)-1]++
+	}
+	tbl := table.New(groups...)
+	tbl.AddSeparatorRow()
+	header := tbl.AddRow()
+	for _, c := range r.Columns {
+		header.AddText(c.Header, table.Center)
+	}
+	tbl.AddSeparatorRow()
+	footnotes := make(map[string]int)
+	for _, s := range r.Sections {
+		for i, row := range s.Rows {
+			if s.Spaced && i > 0 && row.Depth == 0 {
+				tbl.AddEmptyRow()
+			}
+			var notes []int
+			for _, n := range row.Notes {
+				if _, ok := footnotes[n]; !ok {
+					footnotes[n] = tbl.AddFootnote(n)
+				}
+				notes = append(notes, footnotes[n])
+			}
+			addRow(tbl, row, notes)
+		}
+		tbl.AddSeparatorRow()
+	}
+	return tbl
+}
+
+func addRow(tbl *table.Table, row *Row, notes []int) {
+	if len(row.Lines) == 0 {
+		r := tbl.AddRow()
+		if row.Total {
+			r.MarkTotal()
+		}
+		r.AddIndented(row.Label, 2*row.Depth).Annotate(notes...).FillEmpty()
+		return
+	}
+	for i, line := range row.Lines {
+		r := tbl.AddRow()
+		if row.Total {
+			r.MarkTotal()
+		}
+		if i == 0 {
+			r.AddIndented(row.Label, 2*row.Depth).Annotate(notes...)
+		} else {
+			r.AddEmpty()
+		}
+		for _, c := range line {
+			switch c.Kind {
+			case Empty:
+				r.AddEmpty()
+			case Text:
+				r.AddText(c.Text, table.Left)
+			case Commodity:
+				r.AddCommodity(c.Text)
+			case Decimal:
+				r.AddDecimal(c.Decimal)
+				if c.Link != "" {
+					r.Link(c.Link)
+				}
+			case Percent:
+				r.AddPercent(c.Percent)
+			}
+		}
+		r.FillEmpty()
+	}
+}
@@ -0,0 +1,106 @@
+package view
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/shopspring/decimal"
+)
+
+func TestTable(t *testing.T) {
+	r := new(Report)
+	r.AddColumn("Account", 0)
+	r.AddColumn("Comm", 1)
+	r.AddColumn("2021", 2)
+	r.AddColumn("2022", 2)
+	s := r.AddSection()
+	s.Spaced = true
+	s.AddRow("Assets", 0)
+	row := s.AddRow("Bank", 1)
+	row.AddLine().AddText("CHF").AddDecimal(decimal.NewFromInt(10)).AddDecimal(decimal.NewFromInt(20))
+	row.AddLine().AddText("USD").AddEmpty().AddDecimal(decimal.NewFromInt(5))
+	s.AddRow("Liabilities", 0)
+	total := s.AddRow("Total", 0)
+	total.Total = true
+	total.AddLine().AddText("CHF").AddDecimal(decimal.NewFromInt(10)).AddDecimal(decimal.NewFromInt(20))
+	r.AddSection().AddRow("Delta", 0)
+
+	var got strings.Builder
+	tr := table.TextRenderer{}
+	if err := tr.Render(r.Table(), &got); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"+-------------+------+------+------+",
+		"|   Account   | Comm | 2021 | 2022 |",
+		"+-------------+------+------+------+",
+		"| Assets      |      |      |      |",
+		"|   Bank      | CHF  |   10 |   20 |",
+		"|             | USD  |      |    5 |",
+		"|             |      |      |      |",
+		"| Liabilities |      |      |      |",
+		"|             |      |      |      |",
+		"| Total       | CHF  |   10 |   20 |",
+		"+-------------+------+------+------+",
+		"| Delta       |      |      |      |",
+		"+-------------+------+------+------+",
+		"",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Fatalf("Table() returned unexpected diff (-want/+got):\n%s\n", diff)
+	}
+}
+
+func TestTableNotes(t *testing.T) {
+	r := new(Report)
+	r.AddColumn("Account", 0)
+	r.AddColumn("2021", 1)
+	s := r.AddSection()
+	s.AddRow("Assets", 0).Notes = []string{"first"}
+	bank := s.AddRow("Bank", 1)
+	bank.Notes = []string{"second", "first"}
+	bank.AddLine().AddDecimal(decimal.NewFromInt(10))
+
+	var got strings.Builder
+	hr := table.HTMLRenderer{}
+	if err := hr.Render(r.Table(), &got); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`<td>Assets<sup><a href="#note-1">1</a></sup></td>`,
+		`Bank<sup><a href="#note-2">2</a></sup><sup><a href="#note-1">1</a></sup>`,
+		"<li id=\"note-1\">first</li>\n<li id=\"note-2\">second</li>\n",
+	} {
+		if !strings.Contains(got.String(), want) {
+			t.Errorf("Render() = %s, want it to contain %s", got.String(), want)
+		}
+	}
+}
+
+func TestTableLinks(t *testing.T) {
+	r := new(Report)
+	r.AddColumn("Account", 0)
+	r.AddColumn("2021", 1)
+	s := r.AddSection()
+	line := s.AddRow("Bank", 0).AddLine()
+	line.AddDecimal(decimal.NewFromInt(10)).Link("/register?account=Bank&x=<")
+	s.AddRow("Cash", 0).AddLine().AddDecimal(decimal.Zero).Link("/register?account=Cash")
+
+	var got strings.Builder
+	hr := table.HTMLRenderer{}
+	if err := hr.Render(r.Table(), &got); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+
+	if want := `<td class="num"><a href="/register?account=Bank&amp;x=&lt;">10</a></td>`; !strings.Contains(got.String(), want) {
+		t.Errorf("Render() = %s, want it to contain %s", got.String(), want)
+	}
+	if strings.Contains(got.String(), "account=Cash") {
+		t.Errorf("Render() = %s, want no link on empty cells", got.String())
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/journal/performance"
 	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/reports/view"
 )
 
 type Query struct {
@@ -104,12 +105,15 @@ func (r *Report) SortWeighted() {
 type Renderer struct {
 	SortAlphabetically bool
 
-	table  *table.Table
-	report *Report
-	dates  []time.Time
+	dates []time.Time
 }
 
 func (rn *Renderer) Render(rep *Report) *table.Table {
+	return rn.Build(rep).Table()
+}
+
+// Build builds the view of a report.
+func (rn *Renderer) Build(rep *Report) *view.Report {
 	rep.PropagateWeights()
 	if rn.SortAlphabetically {
 		rep.weights.Sort(multimap.SortAlpha)
@@ -118,40 +122,28 @@ func (rn *Renderer) Render(rep *Report) *table.Table {
 	}
 
 	rn.dates = rep.dates.Sorted(compare.Time)
-	rn.table = table.New(1, len(rn.dates))
-	rn.report = rep
-
-	rn.table.AddSeparatorRow()
-	rn.renderHeader()
-	rn.table.AddSeparatorRow()
-
-	for _, node := range rep.weights.Sorted {
-		rn.renderNode(node, 0)
-	}
-	rn.table.AddSeparatorRow()
-
-	return rn.table
-}
-
-func (rn *Renderer) renderHeader() {
-	row := rn.table.AddRow()
-	row.AddText("Commodity", table.Center)
+	res := new(view.Report)
+	res.AddColumn("Commodity", 0)
 	for _, date := range rn.dates {
-		row.AddText(date.Format("2006-01-02"), table.Center)
+		res.AddColumn(date.Format("2006-01-02"), 1)
+	}
+	s := res.AddSection()
+	for _, node := range rep.weights.Sorted {
+		rn.renderNode(s, node, 0)
 	}
+	return res
 }
 
-func (rn *Renderer) renderNode(n *Node, indent int) {
-	row := rn.table.AddRow()
-	row.AddIndented(n.Segment, indent)
+func (rn *Renderer) renderNode(s *view.Section, n *Node, depth int) {
+	line := s.AddRow(n.Segment, depth).AddLine()
 	for _, date := range rn.dates {
 		if weight, ok := n.Value.Weights[date]; ok && weight != 0 {
-			row.AddPercent(weight)
+			line.AddPercent(weight)
 		} else {
-			row.AddEmpty()
+			line.AddEmpty()
 		}
 	}
 	for _, child := range n.Sorted {
-		rn.renderNode(child, indent+2)
+		rn.renderNode(s, child, depth+1)
 	}
 }
@@ -0,0 +1,136 @@
+package syntax
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sboehler/knut/lib/syntax/directives"
+)
+
+// Cache holds parsed files in memory, for long-running processes which parse
+// a journal repeatedly. A cached file is reused as long as its size and
+// modification time are unchanged. The cache is used by ParseFileRecursively
+// with a context returned by Lease.
+//
+// Files are memory-mapped, and the cache owns their mappings. A file is
+// evicted when it has changed, and its mapping is removed as soon as no
+// lease uses it anymore. Without a lease, files are not cached, and their
+// mappings are kept until the process exits, which suits a process parsing
+// the journal once.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+// cacheKey identifies a cached file by its absolute path and by the name
+// under which it has been parsed, which the ranges of its directives refer
+// to.
+type cacheKey struct {
+	path, name string
+}
+
+type cacheEntry struct {
+	size    int64
+	modTime time.Time
+	file    directives.File
+
+	// unmap removes the mapping holding the text of the file, once the
+	// entry is evicted and refs, the number of leases using it, is zero.
+	unmap   func() error
+	refs    int
+	evicted bool
+}
+
+// NewCache creates an empty cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[cacheKey]*cacheEntry)}
+}
+
+type leaseKey struct{}
+
+type lease struct {
+	cache   *Cache
+	entries []*cacheEntry
+}
+
+// Lease returns a context for parsing files with the cache, which keeps the
+// mappings of the files in place until release is called, even if they are
+// evicted in the meantime. The directives parsed with the context must not be
+// used after release.
+func (c *Cache) Lease(ctx context.Context) (context.Context, func()) {
+	l := &lease{cache: c}
+	release := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, e := range l.entries {
+			e.refs--
+			c.release(e)
+		}
+		l.entries = nil
+	}
+	return context.WithValue(ctx, leaseKey{}, l), release
+}
+
+// leaseFrom returns the lease of the context, or nil.
+func leaseFrom(ctx context.Context) *lease {
+	l, _ := ctx.Value(leaseKey{}).(*lease)
+	return l
+}
+
+func (l *lease) get(key cacheKey, info os.FileInfo) (directives.File, bool) {
+	if l == nil {
+		return directives.File{}, false
+	}
+	c := l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.size != info.Size() || !e.modTime.Equal(info.ModTime()) {
+		return directives.File{}, false
+	}
+	e.refs++
+	l.entries = append(l.entries, e)
+	return e.file, true
+}
+
+// put adds a parsed file to the cache, evicting the previous entry of the
+// file. If the text of the file is mapped, unmap removes the mapping.
+func (l *lease) put(key cacheKey, info os.FileInfo, f directives.File, unmap func() error) {
+	if l == nil {
+		return
+	}
+	c := l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &cacheEntry{size: info.Size(), modTime: info.ModTime(), file: f, unmap: unmap, refs: 1}
+	l.entries = append(l.entries, e)
+	if old, ok := c.entries[key]; ok {
+		old.evicted = true
+		c.release(old)
+	}
+	c.entries[key] = e
+}
+
+// keep removes the mapping of a file which is not cached, because it failed
+// to parse, when the lease is released. The error refers to the text of the
+// file.
+func (l *lease) keep(unmap func() error) {
+	if l == nil || unmap == nil {
+		return
+	}
+	c := l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l.entries = append(l.entries, &cacheEntry{unmap: unmap, refs: 1, evicted: true})
+}
+
+// release removes the mapping of an evicted entry which is not used anymore.
+// It must be called with c.mu held.
+func (c *Cache) release(e *cacheEntry) {
+	if e.evicted && e.refs == 0 && e.unmap != nil {
+		e.unmap()
+		e.unmap = nil
+	}
+}
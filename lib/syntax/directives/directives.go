@@ -2,6 +2,7 @@ package directives
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,6 +30,26 @@ func (d Date) Parse() (time.Time, error) {
 	return date, nil
 }
 
+// Time is a time of day, such as `09:00` or `17:30:15`.
+type Time struct{ Range }
+
+// Parse returns the time elapsed since midnight.
+func (t Time) Parse() (time.Duration, error) {
+	layout := "15:04"
+	if len(t.Extract()) > len(layout) {
+		layout = "15:04:05"
+	}
+	tm, err := time.Parse(layout, t.Extract())
+	if err != nil {
+		return 0, Error{
+			Message: "parsing time",
+			Range:   t.Range,
+			Wrapped: err,
+		}
+	}
+	return time.Duration(tm.Hour())*time.Hour + time.Duration(tm.Minute())*time.Minute + time.Duration(tm.Second())*time.Second, nil
+}
+
 type Decimal struct{ Range }
 
 func (d Decimal) Parse() (decimal.Decimal, error) {
@@ -48,16 +69,142 @@ type QuotedString struct {
 	Content Range
 }
 
+// Parse returns the content of the quoted string, with its escape sequences
+// replaced by the characters they stand for.
+func (q QuotedString) Parse() (string, error) {
+	s, err := Unescape(q.Content.Extract())
+	if err != nil {
+		return s, Error{
+			Message: "parsing quoted string",
+			Range:   q.Range,
+			Wrapped: err,
+		}
+	}
+	return s, nil
+}
+
+// Unescape replaces the escape sequences \", \\, \n, \t and \uXXXX in s by
+// the characters they stand for.
+func Unescape(s string) (string, error) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("incomplete escape sequence at the end of %q", s)
+		}
+		switch s[i] {
+		case '"', '\\':
+			b.WriteByte(s[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("incomplete escape sequence %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape sequence %q", s[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			return "", fmt.Errorf("invalid escape sequence %q", s[i-1:i+1])
+		}
+	}
+	return b.String(), nil
+}
+
+// Escape escapes quotes, backslashes and control characters in s, such that
+// it can be written as the content of a quoted string.
+func Escape(s string) string {
+	if !strings.ContainsFunc(s, needsEscape) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func needsEscape(r rune) bool {
+	return r == '"' || r == '\\' || r < 0x20 || r == 0x7f
+}
+
 type Booking struct {
 	Range
 	Credit, Debit Account
 	Quantity      Decimal
 	Commodity     Commodity
+
+	// Virtual is the account of a virtual booking, written as `(account)`
+	// instead of a credit and a debit account. Virtual bookings are not
+	// balanced.
+	Virtual Account
+
+	// Cost is an optional `{unit cost}` or `{{total cost}}` annotation.
+	Cost Cost
+
+	// Rate is an optional `@ rate` or `@@ total` conversion into another
+	// commodity, in which the debit account receives the booking.
+	Rate Rate
+
+	// Comment is the text of an optional trailing `; comment`.
+	Comment Range
+}
+
+type Cost struct {
+	Range
+	Amount    Decimal
+	Commodity Commodity
+
+	// Total is set for `{{total cost}}`, where Amount is the cost of the
+	// entire quantity rather than of a single unit.
+	Total bool
+
+	// Date is the optional date of the lot, as in `{150 USD, 2023-02-01}`.
+	Date Date
+}
+
+// Rate is the exchange rate of a booking, such as `@ 1.08 USD` per unit or
+// `@@ 108 USD` for the entire quantity.
+type Rate struct {
+	Range
+	Amount    Decimal
+	Commodity Commodity
+
+	// Total is set for `@@ total`.
+	Total bool
 }
 
 type Performance struct {
 	Range
 	Targets []Commodity
+
+	// Patterns are regexes, without the enclosing slashes, which select
+	// further targets among the commodities of the portfolio.
+	Patterns []Range
 }
 
 type Interval struct{ Range }
@@ -75,8 +222,33 @@ type File struct {
 type Accrual struct {
 	Range
 	Interval   Interval
+	Anchor     Anchor
 	Start, End Date
 	Account    Account
+	// Reverse is the optional `reverse` keyword, which requests reversing
+	// entries for the accruals.
+	Reverse Range
+}
+
+// Anchor is the day of the month on which accruals are booked.
+type Anchor struct{ Range }
+
+func (a Anchor) Parse() (int, error) {
+	day, err := strconv.Atoi(a.Extract())
+	if err != nil {
+		return day, Error{
+			Message: "parsing anchor",
+			Range:   a.Range,
+			Wrapped: err,
+		}
+	}
+	if day < 1 || day > 31 {
+		return day, Error{
+			Message: fmt.Sprintf("invalid anchor %d, want a day of the month between 1 and 31", day),
+			Range:   a.Range,
+		}
+	}
+	return day, nil
 }
 
 type Addons struct {
@@ -89,14 +261,27 @@ type Transaction struct {
 	Range
 	Date        Date
 	Description QuotedString
-	Bookings    []Booking
-	Addons      Addons
+
+	// ID is the identifier of an optional `id:<identifier>` following the
+	// description.
+	ID Range
+
+	// Links are the names of the `^<link>` annotations following the
+	// description, which relate transactions to each other.
+	Links []Range
+
+	Bookings []Booking
+	Addons   Addons
 }
 
 type Open struct {
 	Range
 	Date    Date
 	Account Account
+
+	// Valuation is the optional account which receives the valuation
+	// gains and losses of Account.
+	Valuation Account
 }
 
 type Close struct {
@@ -105,6 +290,20 @@ type Close struct {
 	Account Account
 }
 
+type Note struct {
+	Range
+	Date    Date
+	Account Account
+	Text    QuotedString
+}
+
+type Document struct {
+	Range
+	Date         Date
+	Account      Account
+	DocumentPath QuotedString
+}
+
 type Assertion struct {
 	Range
 	Date     Date
@@ -116,6 +315,18 @@ type Balance struct {
 	Account   Account
 	Quantity  Decimal
 	Commodity Commodity
+
+	// Subtree is the `:*` suffix of the account, asserting the combined
+	// balance of the account and its descendants.
+	Subtree Range
+
+	// Tolerance is the optional maximum deviation of the balance, as in
+	// `100.00 ~ 0.01 USD`.
+	Tolerance Decimal
+
+	// Cost restricts the assertion to the lot with the given cost and
+	// optionally date.
+	Cost Cost
 }
 
 type Price struct {
@@ -125,11 +336,82 @@ type Price struct {
 	Price             Decimal
 }
 
+// Value declares the balance of an account in a commodity, such as the
+// estimated value of a house. The difference to the actual balance is booked
+// against the valuation account of the account.
+type Value struct {
+	Range
+	Date      Date
+	Account   Account
+	Quantity  Decimal
+	Commodity Commodity
+}
+
+// Template declares a transaction template with parameters. Its body is a
+// transaction without a date, in which `$parameter` stands for the argument
+// of the parameter.
+type Template struct {
+	Range
+	Name       Range
+	Parameters []Range
+	Body       Transaction
+}
+
+// Use instantiates a template on a date. Arguments are bare words or quoted
+// strings, which stand for their content.
+type Use struct {
+	Range
+	Date      Date
+	Name      Range
+	Arguments []Range
+}
+
 type Include struct {
 	Range
 	IncludePath QuotedString
 }
 
+// AccountType declares an additional account type, i.e. a root account, and
+// its placement in reports: al, eie or off-balance.
+type AccountType struct {
+	Range
+	Name      Range
+	Placement Range
+}
+
+// Alias declares a new name for a commodity, such as after a ticker change.
+// Directives keep the old name, but the model uses the new one.
+type Alias struct {
+	Range
+	Commodity Commodity
+	Target    Commodity
+}
+
+// Clock is a timeclock entry, which either checks in to an account, such as
+// `i 2023-03-01 09:00 Project:X`, or checks out of the account of the
+// previous entry, such as `o 2023-03-01 17:00`.
+type Clock struct {
+	Range
+	In      bool
+	Date    Date
+	Time    Time
+	Account Account
+}
+
+// CommodityDeclaration declares metadata of a commodity, such as its asset
+// class, as indented `key: "value"` lines.
+type CommodityDeclaration struct {
+	Range
+	Commodity Commodity
+	Metadata  []Metadata
+}
+
+type Metadata struct {
+	Range
+	Key   Range
+	Value QuotedString
+}
+
 type Range struct {
 	Start, End int
 	Path, Text string
@@ -156,6 +438,9 @@ func (r *Range) Extend(r2 Range) {
 	}
 }
 
+// SetRange sets the range of t and returns it. Since t is accessed through
+// a type parameter, it is moved to the heap; the parser sets the range of
+// frequent directives directly instead.
 func SetRange[T any, P interface {
 	*T
 	SetRange(Range)
@@ -208,6 +493,13 @@ func (r Range) lastOfLine(pos int) int {
 	return pos
 }
 
+// Position returns the position of the start of the range, in the form
+// path:line:col.
+func (r Range) Position() string {
+	r.End = r.Start
+	return fmt.Sprintf("%s:%s", r.Path, r.Location())
+}
+
 type Location struct {
 	Line, Col int
 }
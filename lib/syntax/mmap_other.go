@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package syntax
+
+import "os"
+
+// mmap is not supported on this platform.
+func mmap(f *os.File, size int) (string, func() error, bool) {
+	return "", nil, false
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package syntax
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mmap maps the file into memory and returns the mapped memory as a
+// string, without copying it, and a function which removes the mapping. It
+// returns false if the file can't be mapped.
+func mmap(f *os.File, size int) (string, func() error, bool) {
+	b, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_PRIVATE)
+	if err != nil {
+		return "", nil, false
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b)), func() error { return syscall.Munmap(b) }, true
+}
@@ -0,0 +1,150 @@
+package syntax
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Names contains the account and commodity names found in a journal.
+type Names struct {
+	Accounts, Commodities []string
+}
+
+var (
+	accountRegex   = regexp.MustCompile(`^\(?(\p{Lu}[\p{L}\p{N}]*(?::[\p{L}\p{N}]+)+)(?::\*)?\)?$`)
+	commodityRegex = regexp.MustCompile(`^[\p{L}\p{N}]+$`)
+	decimalRegex   = regexp.MustCompile(`^-?[0-9]+(?:\.[0-9]+)?$`)
+)
+
+// ScanNames collects the account and commodity names referenced in the given
+// file and the files it includes. Unlike the parser, it processes the journal
+// line by line without building directives, which is fast enough to be used
+// for shell completion, and it tolerates syntax errors and missing included
+// files. Names are returned sorted.
+func ScanNames(file string) (Names, error) {
+	ns := nameScanner{
+		accounts:    set.New[string](),
+		commodities: set.New[string](),
+		visited:     set.New[string](),
+	}
+	if err := ns.scan(file); err != nil {
+		return Names{}, err
+	}
+	res := Names{
+		Accounts:    ns.accounts.Slice(),
+		Commodities: ns.commodities.Slice(),
+	}
+	slices.Sort(res.Accounts)
+	slices.Sort(res.Commodities)
+	return res, nil
+}
+
+type nameScanner struct {
+	accounts, commodities, visited set.Set[string]
+}
+
+func (ns *nameScanner) scan(file string) error {
+	if ns.visited.Has(file) {
+		return nil
+	}
+	ns.visited.Add(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	var ignoring bool
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if pragma := parser.Pragma(line); pragma != "" {
+			switch pragma {
+			case "ignore-file":
+				return nil
+			case "ignore-begin":
+				ignoring = true
+			case "ignore-end":
+				ignoring = false
+			}
+			continue
+		}
+		if ignoring {
+			continue
+		}
+		if inc, ok := strings.CutPrefix(line, "include "); ok {
+			if p, ok := unquote(inc); ok {
+				ns.scan(path.Join(filepath.Dir(file), p))
+			}
+			continue
+		}
+		ns.scanLine(line)
+	}
+	return s.Err()
+}
+
+func unquote(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+	res, err := directives.Unescape(s[1 : len(s)-1])
+	return res, err == nil
+}
+
+// scanLine collects the names on a line. Accounts are recognized by their
+// shape, and commodities by following a quantity or a price keyword.
+func (ns *nameScanner) scanLine(line string) {
+	if line == "" || strings.ContainsRune("*#;", rune(line[0])) || strings.HasPrefix(line, "//") {
+		return
+	}
+	line = stripQuoted(line)
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	tokens := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '{' || r == '}' || r == ','
+	})
+	for i, t := range tokens {
+		if m := accountRegex.FindStringSubmatch(t); m != nil {
+			ns.accounts.Add(m[1])
+			continue
+		}
+		if i == 0 || !commodityRegex.MatchString(t) {
+			continue
+		}
+		if prev := tokens[i-1]; prev == "price" || decimalRegex.MatchString(prev) {
+			ns.commodities.Add(t)
+		}
+	}
+}
+
+// stripQuoted removes quoted strings, such as descriptions, from a line.
+func stripQuoted(line string) string {
+	if !strings.ContainsRune(line, '"') {
+		return line
+	}
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+			b.WriteByte(' ')
+		case !quoted:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -2,10 +2,13 @@ package parser
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"unicode"
 
 	"github.com/sboehler/knut/lib/syntax/directives"
 	"github.com/sboehler/knut/lib/syntax/scanner"
+	"go.uber.org/multierr"
 )
 
 // Parser parses a journal.
@@ -13,6 +16,16 @@ type Parser struct {
 	scanner.Scanner
 
 	Callback func(d directives.Directive)
+
+	// Recover makes ParseFile continue after an erroneous directive. The
+	// parser skips to the next blank line and resumes parsing, and all errors
+	// are returned together.
+	Recover bool
+
+	// template is set while parsing the body of a template, where
+	// `$parameter` may stand for a quantity, a commodity or segments of an
+	// account.
+	template bool
 }
 
 // New creates a new parser.
@@ -24,7 +37,7 @@ func New(text, path string) *Parser {
 
 func (p *Parser) readComment() (directives.Range, error) {
 	s := p.Scope("reading comment")
-	if _, err := p.ReadAlternative([]string{"*", "//", "#"}); err != nil {
+	if _, err := p.ReadAlternative([]string{"*", "//", "#", ";"}); err != nil {
 		return s.Range(), s.Annotate(err)
 	}
 	if _, err := p.ReadWhile(func(r rune) bool { return !isNewlineOrEOF(r) }); err != nil {
@@ -35,92 +48,331 @@ func (p *Parser) readComment() (directives.Range, error) {
 
 func (p *Parser) ParseFile() (directives.File, error) {
 	s := p.Scope(fmt.Sprintf("parsing file `%s`", p.Path))
-	var file directives.File
+	var (
+		file directives.File
+		errs error
+	)
 	for p.Current() != scanner.EOF {
-		switch {
-
-		case p.Current() == '*' || p.Current() == '#' || p.Current() == '/':
-			if _, err := p.readComment(); err != nil {
+		if err := p.parseFileItem(&file); err != nil {
+			if !p.Recover {
 				return directives.SetRange(&file, s.Range()), s.Annotate(err)
 			}
+			errs = multierr.Append(errs, s.Annotate(err))
+			if err := p.skipToBlankLine(); err != nil {
+				return directives.SetRange(&file, s.Range()), multierr.Append(errs, s.Annotate(err))
+			}
+		}
+	}
+	return directives.SetRange(&file, s.Range()), errs
+}
 
-		case isAlphanumeric(p.Current()) || p.Current() == '@':
-			dir, err := p.parseDirective()
-			file.Directives = append(file.Directives, dir)
-			if err != nil {
-				return directives.SetRange(&file, s.Range()), s.Annotate(err)
+func (p *Parser) parseFileItem(file *directives.File) error {
+	switch {
+
+	case isCommentStart(p.Current()):
+		r, err := p.readComment()
+		if err != nil {
+			return err
+		}
+		switch Pragma(r.Extract()) {
+		case "ignore-file":
+			if len(file.Directives) > 0 {
+				return directives.Error{
+					Message: "`knut: ignore-file` must precede all directives",
+					Range:   r,
+				}
 			}
-			if p.Callback != nil {
-				p.Callback(dir)
+			_, err := p.ReadWhile(func(r rune) bool { return r != scanner.EOF })
+			return err
+		case "ignore-begin":
+			return p.skipIgnored(r)
+		case "ignore-end":
+			return directives.Error{
+				Message: "`knut: ignore-end` without matching `knut: ignore-begin`",
+				Range:   r,
 			}
 		}
-		if p.Current() == scanner.EOF {
+
+	case isAlphanumeric(p.Current()) || p.Current() == '@':
+		dir, err := p.parseDirective()
+		file.Directives = append(file.Directives, dir)
+		if err != nil {
+			return err
+		}
+		if p.Callback != nil {
+			p.Callback(dir)
+		}
+	}
+	if p.Current() == scanner.EOF {
+		return nil
+	}
+	_, err := p.readRestOfWhitespaceLine()
+	return err
+}
+
+// Pragma returns the pragma contained in the given comment, i.e. the text
+// following `knut:`, or the empty string if the comment is not a pragma.
+func Pragma(comment string) string {
+	for _, prefix := range []string{"*", "//", "#", ";"} {
+		if s, ok := strings.CutPrefix(comment, prefix); ok {
+			if s, ok := strings.CutPrefix(strings.TrimSpace(s), "knut:"); ok {
+				return strings.TrimSpace(s)
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// AttachedComments returns the comment lines immediately preceding the
+// directive at the given range, in order of appearance.
+func AttachedComments(r directives.Range) []directives.Range {
+	var res []directives.Range
+	for end := r.Start; end > 0 && r.Text[end-1] == '\n'; {
+		start := strings.LastIndexByte(r.Text[:end-1], '\n') + 1
+		line := strings.TrimSpace(r.Text[start : end-1])
+		if len(line) == 0 || !isCommentStart(rune(line[0])) {
 			break
 		}
-		if _, err := p.readRestOfWhitespaceLine(); err != nil {
-			return directives.SetRange(&file, s.Range()), s.Annotate(err)
+		res = append(res, directives.Range{Start: start, End: end - 1, Path: r.Path, Text: r.Text})
+		end = start
+	}
+	slices.Reverse(res)
+	return res
+}
+
+// skipIgnored skips all lines up to and including the next
+// `knut: ignore-end` pragma. The begin range is used for reporting
+// unterminated blocks.
+func (p *Parser) skipIgnored(begin directives.Range) error {
+	for {
+		if p.Current() == scanner.EOF {
+			return directives.Error{
+				Message: "`knut: ignore-begin` without matching `knut: ignore-end`",
+				Range:   begin,
+			}
+		}
+		if _, err := p.ReadCharacter('\n'); err != nil {
+			return err
+		}
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return err
+		}
+		line, err := p.ReadWhile(func(r rune) bool { return !isNewlineOrEOF(r) })
+		if err != nil {
+			return err
+		}
+		if Pragma(strings.TrimSpace(line.Extract())) == "ignore-end" {
+			return nil
+		}
+	}
+}
+
+// skipToBlankLine advances the scanner to the end of the next line which
+// contains only whitespace, or to the end of the file.
+func (p *Parser) skipToBlankLine() error {
+	for {
+		if _, err := p.ReadWhile(func(r rune) bool { return !isNewlineOrEOF(r) }); err != nil {
+			return err
+		}
+		if p.Current() == scanner.EOF {
+			return nil
+		}
+		if _, err := p.ReadCharacter('\n'); err != nil {
+			return err
+		}
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return err
+		}
+		if isNewlineOrEOF(p.Current()) {
+			return nil
 		}
 	}
-	return directives.SetRange(&file, s.Range()), nil
 }
 
-func (p *Parser) parseDirective() (directives.Directive, error) {
+func (p *Parser) parseDirective() (dir directives.Directive, err error) {
 	s := p.Scope("parsing directive")
-	var (
-		dir    directives.Directive
-		addons directives.Addons
-	)
-	var err error
+	defer func() { dir.Range = s.Range() }()
+	var addons directives.Addons
 	if p.Current() == '@' {
 		if addons, err = p.parseAddons(); err != nil {
-			return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+			return dir, s.Annotate(err)
 		}
 	}
-	if p.Current() == 'i' {
+	if p.Current() == 'o' || p.startsWith("i ") || p.startsWith("i\t") {
+		if dir.Directive, err = p.parseClock(); err != nil {
+			return dir, s.Annotate(err)
+		}
+	} else if p.Current() == 'i' {
 		if dir.Directive, err = p.parseInclude(); err != nil {
-			return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+			return dir, s.Annotate(err)
+		}
+	} else if p.Current() == 'a' && p.startsWith("alias") {
+		if dir.Directive, err = p.parseAlias(); err != nil {
+			return dir, s.Annotate(err)
+		}
+	} else if p.Current() == 'a' {
+		if dir.Directive, err = p.parseAccountType(); err != nil {
+			return dir, s.Annotate(err)
+		}
+	} else if p.Current() == 'c' {
+		if dir.Directive, err = p.parseCommodityDeclaration(); err != nil {
+			return dir, s.Annotate(err)
+		}
+	} else if p.Current() == 't' {
+		if dir.Directive, err = p.parseTemplate(); err != nil {
+			return dir, s.Annotate(err)
 		}
 	} else {
 		date, err := p.parseDate()
 		if err != nil {
-			return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+			return dir, s.Annotate(err)
 		}
 		if _, err := p.readWhitespace1(); err != nil {
-			return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+			return dir, s.Annotate(err)
 		}
 		if p.Current() == '"' {
 			if dir.Directive, err = p.parseTransaction(s, date, addons); err != nil {
-				return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+				return dir, s.Annotate(err)
 			}
 		} else {
-			r, err := p.ReadAlternative([]string{"open", "close", "balance", "price"})
+			r, err := p.ReadAlternative([]string{"open", "close", "note", "document", "balance", "price", "value", "use"})
 			if err != nil {
-				return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+				return dir, s.Annotate(err)
 			}
 			if _, err := p.readWhitespace1(); err != nil {
-				return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+				return dir, s.Annotate(err)
 			}
 			switch r.Extract() {
 			case "open":
 				if dir.Directive, err = p.parseOpen(s, date); err != nil {
-					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+					return dir, s.Annotate(err)
 				}
 			case "close":
 				if dir.Directive, err = p.parseClose(s, date); err != nil {
-					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+					return dir, s.Annotate(err)
+				}
+			case "note":
+				if dir.Directive, err = p.parseNote(s, date); err != nil {
+					return dir, s.Annotate(err)
+				}
+			case "document":
+				if dir.Directive, err = p.parseDocument(s, date); err != nil {
+					return dir, s.Annotate(err)
 				}
 			case "balance":
 				if dir.Directive, err = p.parseAssertion(s, date); err != nil {
-					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+					return dir, s.Annotate(err)
 				}
 			case "price":
 				if dir.Directive, err = p.parsePrice(s, date); err != nil {
-					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+					return dir, s.Annotate(err)
+				}
+			case "value":
+				if dir.Directive, err = p.parseValue(s, date); err != nil {
+					return dir, s.Annotate(err)
+				}
+			case "use":
+				if dir.Directive, err = p.parseUse(s, date); err != nil {
+					return dir, s.Annotate(err)
 				}
 			}
 		}
 	}
-	return directives.SetRange(&dir, s.Range()), nil
+	return dir, nil
+}
+
+// parseTemplate parses a template, which consists of a line such as
+// `template dividend(security, amount)` followed by the description and the
+// bookings of a transaction.
+func (p *Parser) parseTemplate() (tpl directives.Template, err error) {
+	s := p.Scope("parsing `template` directive")
+	defer func() { tpl.Range = s.Range() }()
+	if _, err := p.ReadString("template"); err != nil {
+		return tpl, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return tpl, s.Annotate(err)
+	}
+	if tpl.Name, err = p.ReadWhile1("a letter or a digit", isAlphanumeric); err != nil {
+		return tpl, s.Annotate(err)
+	}
+	tpl.Parameters, err = p.parseList(func() (directives.Range, error) {
+		return p.ReadWhile1("a letter", unicode.IsLetter)
+	})
+	if err != nil {
+		return tpl, s.Annotate(err)
+	}
+	if _, err := p.readRestOfWhitespaceLine(); err != nil {
+		return tpl, s.Annotate(err)
+	}
+	if _, err := p.readIndentation(); err != nil {
+		return tpl, s.Annotate(err)
+	}
+	p.template = true
+	defer func() { p.template = false }()
+	if tpl.Body, err = p.parseTransaction(p.Scope(""), directives.Date{}, directives.Addons{}); err != nil {
+		return tpl, s.Annotate(err)
+	}
+	return tpl, nil
+}
+
+func (p *Parser) parseUse(s scanner.Scope, date directives.Date) (use directives.Use, err error) {
+	s.UpdateDesc("parsing `use` directive")
+	defer func() { use.Range = s.Range() }()
+	use.Date = date
+	if use.Name, err = p.ReadWhile1("a letter or a digit", isAlphanumeric); err != nil {
+		return use, s.Annotate(err)
+	}
+	if use.Arguments, err = p.parseList(p.parseArgument); err != nil {
+		return use, s.Annotate(err)
+	}
+	return use, nil
+}
+
+// parseArgument parses an argument of a `use` directive, which is either a
+// quoted string or a word.
+func (p *Parser) parseArgument() (directives.Range, error) {
+	if p.Current() == '"' {
+		qs, err := p.parseQuotedString()
+		return qs.Range, err
+	}
+	return p.ReadWhile1("an argument", func(r rune) bool {
+		return !isWhitespaceOrNewline(r) && r != scanner.EOF && !strings.ContainsRune(`,()"`, r)
+	})
+}
+
+// parseList parses a parenthesized, comma-separated list of items.
+func (p *Parser) parseList(item func() (directives.Range, error)) ([]directives.Range, error) {
+	var res []directives.Range
+	if _, err := p.ReadCharacter('('); err != nil {
+		return res, err
+	}
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return res, err
+	}
+	for p.Current() != ')' {
+		if len(res) > 0 {
+			if _, err := p.ReadCharacter(','); err != nil {
+				return res, err
+			}
+			if _, err := p.ReadWhile(isWhitespace); err != nil {
+				return res, err
+			}
+		}
+		r, err := item()
+		if err != nil {
+			return res, err
+		}
+		res = append(res, r)
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return res, err
+		}
+	}
+	if _, err := p.ReadCharacter(')'); err != nil {
+		return res, err
+	}
+	return res, nil
 }
 
 func (p *Parser) parseInclude() (directives.Include, error) {
@@ -141,50 +393,239 @@ func (p *Parser) parseInclude() (directives.Include, error) {
 	return directives.SetRange(&include, s.Range()), nil
 }
 
-func (p *Parser) parseOpen(s scanner.Scope, date directives.Date) (directives.Open, error) {
+// parseClock parses a timeclock entry, such as `i 2023-03-01 09:00 Project:X`
+// or `o 2023-03-01 17:00`.
+func (p *Parser) parseClock() (clock directives.Clock, err error) {
+	s := p.Scope("parsing timeclock entry")
+	defer func() { clock.Range = s.Range() }()
+	r, err := p.ReadAlternative([]string{"i", "o"})
+	if err != nil {
+		return clock, s.Annotate(err)
+	}
+	clock.In = r.Extract() == "i"
+	if _, err := p.readWhitespace1(); err != nil {
+		return clock, s.Annotate(err)
+	}
+	if clock.Date, err = p.parseDate(); err != nil {
+		return clock, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return clock, s.Annotate(err)
+	}
+	if clock.Time, err = p.parseTime(); err != nil {
+		return clock, s.Annotate(err)
+	}
+	if !clock.In {
+		return clock, nil
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return clock, s.Annotate(err)
+	}
+	if clock.Account, err = p.parseAccount(); err != nil {
+		return clock, s.Annotate(err)
+	}
+	return clock, nil
+}
+
+func (p *Parser) parseAccountType() (accountType directives.AccountType, err error) {
+	s := p.Scope("parsing `accounttype` directive")
+	defer func() { accountType.Range = s.Range() }()
+	if _, err := p.ReadString("accounttype"); err != nil {
+		return accountType, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return accountType, s.Annotate(err)
+	}
+	if accountType.Name, err = p.ReadWhile1("a letter or a digit", isAlphanumeric); err != nil {
+		return accountType, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return accountType, s.Annotate(err)
+	}
+	if accountType.Placement, err = p.ReadAlternative([]string{"al", "eie", "off-balance"}); err != nil {
+		return accountType, s.Annotate(err)
+	}
+	return accountType, nil
+}
+
+// parseAlias parses an alias, such as `alias commodity TWTR X`.
+func (p *Parser) parseAlias() (alias directives.Alias, err error) {
+	s := p.Scope("parsing `alias` directive")
+	defer func() { alias.Range = s.Range() }()
+	if _, err := p.ReadString("alias"); err != nil {
+		return alias, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return alias, s.Annotate(err)
+	}
+	if _, err := p.ReadAlternative([]string{"commodity"}); err != nil {
+		return alias, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return alias, s.Annotate(err)
+	}
+	if alias.Commodity, err = p.parseCommodity(); err != nil {
+		return alias, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return alias, s.Annotate(err)
+	}
+	if alias.Target, err = p.parseCommodity(); err != nil {
+		return alias, s.Annotate(err)
+	}
+	return alias, nil
+}
+
+func (p *Parser) parseCommodityDeclaration() (decl directives.CommodityDeclaration, err error) {
+	s := p.Scope("parsing `commodity` directive")
+	defer func() { decl.Range = s.Range() }()
+	if _, err := p.ReadString("commodity"); err != nil {
+		return decl, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return decl, s.Annotate(err)
+	}
+	if decl.Commodity, err = p.parseCommodity(); err != nil {
+		return decl, s.Annotate(err)
+	}
+	for {
+		offset := p.Offset()
+		if _, err := p.readRestOfWhitespaceLine(); err != nil {
+			return decl, s.Annotate(err)
+		}
+		// Unlike the lines of other directives, metadata lines must be
+		// indented, so that a declaration can be followed directly by
+		// another directive.
+		if !isWhitespace(p.Current()) || !p.isIndentedLine() {
+			p.Backtrack(offset)
+			break
+		}
+		if _, err := p.readIndentation(); err != nil {
+			return decl, s.Annotate(err)
+		}
+		m, err := p.parseMetadata()
+		decl.Metadata = append(decl.Metadata, m)
+		if err != nil {
+			return decl, s.Annotate(err)
+		}
+	}
+	return decl, nil
+}
+
+func (p *Parser) parseMetadata() (metadata directives.Metadata, err error) {
+	s := p.Scope("parsing metadata")
+	defer func() { metadata.Range = s.Range() }()
+	if metadata.Key, err = p.ReadWhile1("a metadata key", isIdentifier); err != nil {
+		return metadata, s.Annotate(err)
+	}
+	if _, err := p.ReadCharacter(':'); err != nil {
+		return metadata, s.Annotate(err)
+	}
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return metadata, s.Annotate(err)
+	}
+	if metadata.Value, err = p.parseQuotedString(); err != nil {
+		return metadata, s.Annotate(err)
+	}
+	return metadata, nil
+}
+
+func (p *Parser) parseOpen(s scanner.Scope, date directives.Date) (open directives.Open, err error) {
 	s.UpdateDesc("parsing `open` directive")
-	var (
-		open = directives.Open{Date: date}
-		err  error
-	)
+	defer func() { open.Range = s.Range() }()
+	open.Date = date
 	if open.Account, err = p.parseAccount(); err != nil {
+		return open, s.Annotate(err)
+	}
+	if open.Valuation, err = p.parseValuation(); err != nil {
 		err = s.Annotate(err)
 	}
-	return directives.SetRange(&open, s.Range()), err
+	return open, err
+}
+
+// parseValuation parses the optional `valuation <account>` clause of an
+// open directive. It returns an empty account if the clause is absent.
+func (p *Parser) parseValuation() (directives.Account, error) {
+	offset := p.Offset()
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return directives.Account{}, err
+	}
+	if p.Current() != 'v' {
+		p.Backtrack(offset)
+		return directives.Account{}, nil
+	}
+	if _, err := p.ReadString("valuation"); err != nil {
+		return directives.Account{}, err
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return directives.Account{}, err
+	}
+	return p.parseAccount()
 }
 
-func (p *Parser) parseClose(s scanner.Scope, date directives.Date) (directives.Close, error) {
+func (p *Parser) parseClose(s scanner.Scope, date directives.Date) (close directives.Close, err error) {
 	s.UpdateDesc("parsing `close` directive")
-	var (
-		close = directives.Close{Date: date}
-		err   error
-	)
+	defer func() { close.Range = s.Range() }()
+	close.Date = date
 	if close.Account, err = p.parseAccount(); err != nil {
 		err = s.Annotate(err)
 	}
-	return directives.SetRange(&close, s.Range()), err
+	return close, err
+}
+
+func (p *Parser) parseNote(s scanner.Scope, date directives.Date) (note directives.Note, err error) {
+	s.UpdateDesc("parsing `note` directive")
+	defer func() { note.Range = s.Range() }()
+	note.Date = date
+	if note.Account, err = p.parseAccount(); err != nil {
+		return note, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return note, s.Annotate(err)
+	}
+	if note.Text, err = p.parseQuotedString(); err != nil {
+		err = s.Annotate(err)
+	}
+	return note, err
 }
 
-func (p *Parser) parseAssertion(s scanner.Scope, date directives.Date) (directives.Assertion, error) {
+func (p *Parser) parseDocument(s scanner.Scope, date directives.Date) (document directives.Document, err error) {
+	s.UpdateDesc("parsing `document` directive")
+	defer func() { document.Range = s.Range() }()
+	document.Date = date
+	if document.Account, err = p.parseAccount(); err != nil {
+		return document, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return document, s.Annotate(err)
+	}
+	if document.DocumentPath, err = p.parseQuotedString(); err != nil {
+		err = s.Annotate(err)
+	}
+	return document, err
+}
+
+func (p *Parser) parseAssertion(s scanner.Scope, date directives.Date) (assertion directives.Assertion, err error) {
 	s.UpdateDesc("parsing `balance` directive")
-	var (
-		assertion = directives.Assertion{Date: date}
-		err       error
-	)
+	defer func() { assertion.Range = s.Range() }()
+	assertion.Date = date
 	if isNewline(p.Current()) {
 		if _, err := p.readRestOfWhitespaceLine(); err != nil {
-			return directives.SetRange(&assertion, s.Range()), s.Annotate(err)
+			return assertion, s.Annotate(err)
 		}
 		for {
+			if _, err := p.readIndentation(); err != nil {
+				return assertion, s.Annotate(err)
+			}
 			bal, err := p.parseBalance()
 			assertion.Balances = append(assertion.Balances, bal)
 			if err != nil {
-				return directives.SetRange(&assertion, s.Range()), s.Annotate(err)
+				return assertion, s.Annotate(err)
 			}
 			if _, err := p.readRestOfWhitespaceLine(); err != nil {
-				return directives.SetRange(&assertion, s.Range()), s.Annotate(err)
+				return assertion, s.Annotate(err)
 			}
-			if isWhitespaceOrNewline(p.Current()) || p.Current() == scanner.EOF {
+			if !p.isIndentedLine() {
 				break
 			}
 		}
@@ -192,75 +633,129 @@ func (p *Parser) parseAssertion(s scanner.Scope, date directives.Date) (directiv
 		bal, err := p.parseBalance()
 		assertion.Balances = append(assertion.Balances, bal)
 		if err != nil {
-			return directives.SetRange(&assertion, s.Range()), s.Annotate(err)
+			return assertion, s.Annotate(err)
 		}
 	}
-	return directives.SetRange(&assertion, s.Range()), err
+	return assertion, err
 }
 
-func (p *Parser) parseBalance() (directives.Balance, error) {
+func (p *Parser) parseBalance() (balance directives.Balance, err error) {
 	s := p.Scope("parsing balance subdirective")
-	var (
-		balance = directives.Balance{}
-		err     error
-	)
+	defer func() { balance.Range = s.Range() }()
 	if balance.Account, err = p.parseAccount(); err != nil {
-		return directives.SetRange(&balance, s.Range()), s.Annotate(err)
+		return balance, s.Annotate(err)
+	}
+	if p.Current() == ':' {
+		if balance.Subtree, err = p.ReadString(":*"); err != nil {
+			return balance, s.Annotate(err)
+		}
 	}
 	if _, err := p.readWhitespace1(); err != nil {
-		return directives.SetRange(&balance, s.Range()), s.Annotate(err)
+		return balance, s.Annotate(err)
 	}
 	if balance.Quantity, err = p.parseDecimal(); err != nil {
-		return directives.SetRange(&balance, s.Range()), s.Annotate(err)
+		return balance, s.Annotate(err)
 	}
 	if _, err := p.readWhitespace1(); err != nil {
-		return directives.SetRange(&balance, s.Range()), s.Annotate(err)
+		return balance, s.Annotate(err)
+	}
+	if p.Current() == '~' {
+		if _, err := p.ReadCharacter('~'); err != nil {
+			return balance, s.Annotate(err)
+		}
+		if _, err := p.readWhitespace1(); err != nil {
+			return balance, s.Annotate(err)
+		}
+		if balance.Tolerance, err = p.parseDecimal(); err != nil {
+			return balance, s.Annotate(err)
+		}
+		if _, err := p.readWhitespace1(); err != nil {
+			return balance, s.Annotate(err)
+		}
 	}
 	if balance.Commodity, err = p.parseCommodity(); err != nil {
-		err = s.Annotate(err)
+		return balance, s.Annotate(err)
+	}
+	offset := p.Offset()
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return balance, s.Annotate(err)
 	}
-	return directives.SetRange(&balance, s.Range()), err
+	if p.Current() == '{' {
+		if balance.Cost, err = p.parseCost(); err != nil {
+			return balance, s.Annotate(err)
+		}
+	} else {
+		p.Backtrack(offset)
+	}
+	return balance, nil
 }
 
-func (p *Parser) parsePrice(s scanner.Scope, date directives.Date) (directives.Price, error) {
+func (p *Parser) parsePrice(s scanner.Scope, date directives.Date) (price directives.Price, err error) {
 	s.UpdateDesc("parsing `balance` directive")
-	var (
-		price = directives.Price{Date: date}
-		err   error
-	)
+	defer func() { price.Range = s.Range() }()
+	price.Date = date
 	if price.Commodity, err = p.parseCommodity(); err != nil {
-		return directives.SetRange(&price, s.Range()), s.Annotate(err)
+		return price, s.Annotate(err)
 	}
 	if _, err := p.readWhitespace1(); err != nil {
-		return directives.SetRange(&price, s.Range()), s.Annotate(err)
+		return price, s.Annotate(err)
 	}
 	if price.Price, err = p.parseDecimal(); err != nil {
-		return directives.SetRange(&price, s.Range()), s.Annotate(err)
+		return price, s.Annotate(err)
 	}
 	if _, err := p.readWhitespace1(); err != nil {
-		return directives.SetRange(&price, s.Range()), s.Annotate(err)
+		return price, s.Annotate(err)
 	}
 	if price.Target, err = p.parseCommodity(); err != nil {
-		return directives.SetRange(&price, s.Range()), err
+		return price, err
+	}
+	return price, err
+}
+
+func (p *Parser) parseValue(s scanner.Scope, date directives.Date) (value directives.Value, err error) {
+	s.UpdateDesc("parsing `value` directive")
+	defer func() { value.Range = s.Range() }()
+	value.Date = date
+	if value.Account, err = p.parseAccount(); err != nil {
+		return value, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return value, s.Annotate(err)
+	}
+	if value.Quantity, err = p.parseDecimal(); err != nil {
+		return value, s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return value, s.Annotate(err)
 	}
-	return directives.SetRange(&price, s.Range()), err
+	if value.Commodity, err = p.parseCommodity(); err != nil {
+		return value, s.Annotate(err)
+	}
+	return value, nil
 }
 
 func (p *Parser) parseCommodity() (directives.Commodity, error) {
-	var (
-		commodity directives.Commodity
-		err       error
-	)
 	s := p.Scope("parsing commodity")
-	_, err = p.ReadWhile1("a letter or a digit", isAlphanumeric)
-	if err != nil {
-		err = s.Annotate(err)
+	if p.template && p.Current() == '$' {
+		if _, err := p.parsePlaceholder(); err != nil {
+			return directives.Commodity{Range: s.Range()}, s.Annotate(err)
+		}
+		return directives.Commodity{Range: s.Range()}, nil
 	}
-	return directives.SetRange(&commodity, s.Range()), err
+	if _, err := p.ReadWhile1("a letter or a digit", isAlphanumeric); err != nil {
+		return directives.Commodity{Range: s.Range()}, s.Annotate(err)
+	}
+	return directives.Commodity{Range: s.Range()}, nil
 }
 
 func (p *Parser) parseDecimal() (directives.Decimal, error) {
 	s := p.Scope("parsing decimal")
+	if p.template && p.Current() == '$' {
+		if _, err := p.parsePlaceholder(); err != nil {
+			return directives.Decimal{Range: s.Range()}, s.Annotate(err)
+		}
+		return directives.Decimal{Range: s.Range()}, nil
+	}
 	if p.Current() == '-' {
 		if _, err := p.ReadCharacter('-'); err != nil {
 			return directives.Decimal{Range: s.Range()}, s.Annotate(err)
@@ -281,18 +776,28 @@ func (p *Parser) parseDecimal() (directives.Decimal, error) {
 	return directives.Decimal{Range: s.Range()}, nil
 }
 
+// parsePlaceholder parses a `$parameter` in the body of a template.
+func (p *Parser) parsePlaceholder() (directives.Range, error) {
+	s := p.Scope("parsing parameter")
+	if _, err := p.ReadCharacter('$'); err != nil {
+		return s.Range(), s.Annotate(err)
+	}
+	if _, err := p.ReadWhile1("a letter", unicode.IsLetter); err != nil {
+		return s.Range(), s.Annotate(err)
+	}
+	return s.Range(), nil
+}
+
 func (p *Parser) parseAccount() (directives.Account, error) {
 	s := p.Scope("parsing account")
-	acc := directives.Account{}
 	if p.Current() == '$' {
-		acc.Macro = true
 		if _, err := p.ReadCharacter('$'); err != nil {
-			return directives.SetRange(&acc, s.Range()), s.Annotate(err)
+			return directives.Account{Range: s.Range(), Macro: true}, s.Annotate(err)
 		}
 		if _, err := p.ReadWhile1("a letter", unicode.IsLetter); err != nil {
-			return directives.SetRange(&acc, s.Range()), s.Annotate(err)
+			return directives.Account{Range: s.Range(), Macro: true}, s.Annotate(err)
 		}
-		return directives.SetRange(&acc, s.Range()), nil
+		return directives.Account{Range: s.Range(), Macro: true}, nil
 	}
 	if _, err := p.ReadWhile1("a letter or a digit", isAlphanumeric); err != nil {
 		return directives.Account{Range: s.Range()}, s.Annotate(err)
@@ -301,43 +806,216 @@ func (p *Parser) parseAccount() (directives.Account, error) {
 		if p.Current() != ':' {
 			return directives.Account{Range: s.Range()}, nil
 		}
+		offset := p.Offset()
 		if _, err := p.ReadCharacter(':'); err != nil {
 			return directives.Account{Range: s.Range()}, s.Annotate(err)
 		}
+		if p.Current() == '*' {
+			// A subtree wildcard, which is not part of the account.
+			p.Backtrack(offset)
+			return directives.Account{Range: s.Range()}, nil
+		}
+		if p.template && p.Current() == '$' {
+			// A parameter standing for one or more segments.
+			if _, err := p.parsePlaceholder(); err != nil {
+				return directives.Account{Range: s.Range()}, s.Annotate(err)
+			}
+			continue
+		}
 		if _, err := p.ReadWhile1("a letter or a digit", isAlphanumeric); err != nil {
 			return directives.Account{Range: s.Range()}, s.Annotate(err)
 		}
 	}
 }
 
-func (p *Parser) parseBooking() (directives.Booking, error) {
+func (p *Parser) parseBooking() (booking directives.Booking, err error) {
 	s := p.Scope("parsing booking")
-	var (
-		booking directives.Booking
-		err     error
-	)
-	if booking.Credit, err = p.parseAccount(); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+	defer func() { booking.Range = s.Range() }()
+	if p.Current() == '(' {
+		if booking.Virtual, err = p.parseVirtualAccount(); err != nil {
+			return booking, s.Annotate(err)
+		}
+	} else {
+		if booking.Credit, err = p.parseAccount(); err != nil {
+			return booking, s.Annotate(err)
+		}
+		if _, err := p.ReadWhile1("whitespace", isWhitespace); err != nil {
+			return booking, s.Annotate(err)
+		}
+		if booking.Debit, err = p.parseAccount(); err != nil {
+			return booking, s.Annotate(err)
+		}
 	}
 	if _, err := p.ReadWhile1("whitespace", isWhitespace); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+		return booking, s.Annotate(err)
 	}
-	if booking.Debit, err = p.parseAccount(); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+	if booking.Quantity, err = p.parseDecimal(); err != nil {
+		return booking, s.Annotate(err)
 	}
 	if _, err := p.ReadWhile1("whitespace", isWhitespace); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+		return booking, s.Annotate(err)
 	}
-	if booking.Quantity, err = p.parseDecimal(); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+	if booking.Commodity, err = p.parseCommodity(); err != nil {
+		return booking, s.Annotate(err)
+	}
+	offset := p.Offset()
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return booking, s.Annotate(err)
+	}
+	if p.Current() == '{' {
+		if booking.Cost, err = p.parseCost(); err != nil {
+			return booking, s.Annotate(err)
+		}
+		offset = p.Offset()
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return booking, s.Annotate(err)
+		}
+	}
+	if p.Current() == '@' {
+		if booking.Rate, err = p.parseRate(); err != nil {
+			return booking, s.Annotate(err)
+		}
+	} else {
+		p.Backtrack(offset)
+	}
+	if booking.Comment, err = p.parseTrailingComment(); err != nil {
+		return booking, s.Annotate(err)
+	}
+	return booking, nil
+}
+
+// parseRate parses an exchange rate, `@ rate` or `@@ total`.
+func (p *Parser) parseRate() (rate directives.Rate, err error) {
+	s := p.Scope("parsing rate")
+	defer func() { rate.Range = s.Range() }()
+	if _, err := p.ReadCharacter('@'); err != nil {
+		return rate, s.Annotate(err)
+	}
+	if p.Current() == '@' {
+		if _, err := p.ReadCharacter('@'); err != nil {
+			return rate, s.Annotate(err)
+		}
+		rate.Total = true
 	}
 	if _, err := p.ReadWhile1("whitespace", isWhitespace); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+		return rate, s.Annotate(err)
 	}
-	if booking.Commodity, err = p.parseCommodity(); err != nil {
-		return directives.SetRange(&booking, s.Range()), s.Annotate(err)
+	if rate.Amount, err = p.parseDecimal(); err != nil {
+		return rate, s.Annotate(err)
+	}
+	if _, err := p.ReadWhile1("whitespace", isWhitespace); err != nil {
+		return rate, s.Annotate(err)
+	}
+	if rate.Commodity, err = p.parseCommodity(); err != nil {
+		return rate, s.Annotate(err)
 	}
-	return directives.SetRange(&booking, s.Range()), nil
+	return rate, nil
+}
+
+// parseVirtualAccount parses the parenthesized account of a virtual booking.
+func (p *Parser) parseVirtualAccount() (directives.Account, error) {
+	if _, err := p.ReadCharacter('('); err != nil {
+		return directives.Account{}, err
+	}
+	account, err := p.parseAccount()
+	if err != nil {
+		return account, err
+	}
+	if _, err := p.ReadCharacter(')'); err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+// parseCost parses a `{unit cost}` or `{{total cost}}` annotation.
+func (p *Parser) parseCost() (cost directives.Cost, err error) {
+	s := p.Scope("parsing cost")
+	defer func() { cost.Range = s.Range() }()
+	if _, err := p.ReadCharacter('{'); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if p.Current() == '{' {
+		if _, err := p.ReadCharacter('{'); err != nil {
+			return cost, s.Annotate(err)
+		}
+		cost.Total = true
+	}
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if cost.Amount, err = p.parseDecimal(); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if _, err := p.ReadWhile1("whitespace", isWhitespace); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if cost.Commodity, err = p.parseCommodity(); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if p.Current() == ',' {
+		if _, err := p.ReadCharacter(','); err != nil {
+			return cost, s.Annotate(err)
+		}
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return cost, s.Annotate(err)
+		}
+		if cost.Date, err = p.parseDate(); err != nil {
+			return cost, s.Annotate(err)
+		}
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return cost, s.Annotate(err)
+		}
+	}
+	if _, err := p.ReadCharacter('}'); err != nil {
+		return cost, s.Annotate(err)
+	}
+	if cost.Total {
+		if _, err := p.ReadCharacter('}'); err != nil {
+			return cost, s.Annotate(err)
+		}
+	}
+	return cost, nil
+}
+
+// parseTrailingComment parses an optional `; comment` at the end of a
+// line. The returned range covers the comment text without the delimiter
+// and surrounding whitespace. If there is no comment, the scanner is left
+// unchanged.
+func (p *Parser) parseTrailingComment() (directives.Range, error) {
+	offset := p.Offset()
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return directives.Range{}, err
+	}
+	if p.Current() != ';' {
+		if p.Offset() != offset {
+			p.Backtrack(offset)
+		}
+		return directives.Range{}, nil
+	}
+	s := p.Scope("parsing comment")
+	if _, err := p.ReadCharacter(';'); err != nil {
+		return s.Range(), s.Annotate(err)
+	}
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return s.Range(), s.Annotate(err)
+	}
+	c := p.Scope("")
+	end := p.Offset()
+	for !isNewlineOrEOF(p.Current()) {
+		ws := isWhitespace(p.Current())
+		if _, err := p.ReadN(1); err != nil {
+			return s.Range(), s.Annotate(err)
+		}
+		if !ws {
+			end = p.Offset()
+		}
+	}
+	r := c.Range()
+	r.End = end
+	return r, nil
 }
 
 func (p *Parser) parseDate() (directives.Date, error) {
@@ -361,50 +1039,164 @@ func (p *Parser) parseDate() (directives.Date, error) {
 	return directives.Date{Range: s.Range()}, nil
 }
 
-func (p *Parser) parseQuotedString() (directives.QuotedString, error) {
+// parseTime parses a time of day, `hh:mm` or `hh:mm:ss`.
+func (p *Parser) parseTime() (directives.Time, error) {
+	s := p.Scope("parsing the time")
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			if i == 2 && p.Current() != ':' {
+				break
+			}
+			if _, err := p.ReadCharacter(':'); err != nil {
+				return directives.Time{Range: s.Range()}, s.Annotate(err)
+			}
+		}
+		for j := 0; j < 2; j++ {
+			if _, err := p.ReadCharacterWith("a digit", unicode.IsDigit); err != nil {
+				return directives.Time{Range: s.Range()}, s.Annotate(err)
+			}
+		}
+	}
+	return directives.Time{Range: s.Range()}, nil
+}
+
+func (p *Parser) parseQuotedString() (qs directives.QuotedString, err error) {
 	s := p.Scope("parsing quoted string")
-	var (
-		qs  directives.QuotedString
-		err error
-	)
+	defer func() { qs.Range = s.Range() }()
 	if _, err := p.ReadCharacter('"'); err != nil {
-		return directives.SetRange(&qs, s.Range()), s.Annotate(err)
+		return qs, s.Annotate(err)
 	}
-	if qs.Content, err = p.ReadWhile(func(r rune) bool { return r != '"' }); err != nil {
-		return directives.SetRange(&qs, s.Range()), s.Annotate(err)
+	content := p.Scope("")
+	for p.Current() != '"' && p.Current() != scanner.EOF {
+		if p.Current() == '\\' {
+			if err := p.parseEscapeSequence(); err != nil {
+				return qs, s.Annotate(err)
+			}
+			continue
+		}
+		if err := p.Advance(); err != nil {
+			return qs, s.Annotate(err)
+		}
 	}
+	qs.Content = content.Range()
 	if _, err := p.ReadCharacter('"'); err != nil {
-		return directives.SetRange(&qs, s.Range()), s.Annotate(err)
+		return qs, s.Annotate(err)
 	}
-	return directives.SetRange(&qs, s.Range()), nil
+	return qs, nil
 }
 
-func (p *Parser) parseTransaction(s scanner.Scope, date directives.Date, addons directives.Addons) (directives.Transaction, error) {
+// parseEscapeSequence parses one of the escape sequences \", \\, \n, \t and
+// \uXXXX.
+func (p *Parser) parseEscapeSequence() error {
+	s := p.Scope("parsing escape sequence")
+	if _, err := p.ReadCharacter('\\'); err != nil {
+		return s.Annotate(err)
+	}
+	r, err := p.ReadCharacterWith("one of `\"`, `\\`, `n`, `t` or `u`", func(r rune) bool {
+		return strings.ContainsRune(`"\ntu`, r)
+	})
+	if err != nil {
+		return s.Annotate(err)
+	}
+	if r.Extract() != "u" {
+		return nil
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := p.ReadCharacterWith("a hexadecimal digit", isHexDigit); err != nil {
+			return s.Annotate(err)
+		}
+	}
+	return nil
+}
+
+func isHexDigit(r rune) bool {
+	return unicode.Is(unicode.ASCII_Hex_Digit, r)
+}
+
+func (p *Parser) parseTransaction(s scanner.Scope, date directives.Date, addons directives.Addons) (trx directives.Transaction, err error) {
 	s.UpdateDesc("parsing transaction")
-	var (
-		trx = directives.Transaction{Date: date, Addons: addons}
-		err error
-	)
+	defer func() { trx.Range = s.Range() }()
+	trx.Date, trx.Addons = date, addons
 	if trx.Description, err = p.parseQuotedString(); err != nil {
-		return directives.SetRange(&trx, s.Range()), s.Annotate(err)
+		return trx, s.Annotate(err)
+	}
+	if trx.ID, err = p.parseID(); err != nil {
+		return trx, s.Annotate(err)
+	}
+	if trx.Links, err = p.parseLinks(); err != nil {
+		return trx, s.Annotate(err)
 	}
 	if _, err := p.readRestOfWhitespaceLine(); err != nil {
-		return directives.SetRange(&trx, s.Range()), s.Annotate(err)
+		return trx, s.Annotate(err)
 	}
 	for {
+		if _, err := p.readIndentation(); err != nil {
+			return trx, s.Annotate(err)
+		}
 		b, err := p.parseBooking()
 		trx.Bookings = append(trx.Bookings, b)
 		if err != nil {
-			return directives.SetRange(&trx, s.Range()), s.Annotate(err)
+			return trx, s.Annotate(err)
 		}
 		if _, err := p.readRestOfWhitespaceLine(); err != nil {
-			return directives.SetRange(&trx, s.Range()), s.Annotate(err)
+			return trx, s.Annotate(err)
 		}
-		if isWhitespaceOrNewline(p.Current()) || p.Current() == scanner.EOF {
+		if !p.isIndentedLine() {
 			break
 		}
 	}
-	return directives.SetRange(&trx, s.Range()), nil
+	return trx, nil
+}
+
+// parseID parses an optional `id:<identifier>`. The returned range covers
+// the identifier. If there is no identifier, the scanner is left unchanged.
+func (p *Parser) parseID() (directives.Range, error) {
+	offset := p.Offset()
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return directives.Range{}, err
+	}
+	if p.Current() != 'i' {
+		if p.Offset() != offset {
+			p.Backtrack(offset)
+		}
+		return directives.Range{}, nil
+	}
+	s := p.Scope("parsing the id")
+	if _, err := p.ReadString("id:"); err != nil {
+		return s.Range(), s.Annotate(err)
+	}
+	r, err := p.ReadWhile1("an identifier", isIdentifier)
+	if err != nil {
+		return r, s.Annotate(err)
+	}
+	return r, nil
+}
+
+// parseLinks parses optional `^<link>` annotations. The returned ranges
+// cover the names of the links.
+func (p *Parser) parseLinks() ([]directives.Range, error) {
+	var links []directives.Range
+	for {
+		offset := p.Offset()
+		if _, err := p.ReadWhile(isWhitespace); err != nil {
+			return links, err
+		}
+		if p.Current() != '^' {
+			if p.Offset() != offset {
+				p.Backtrack(offset)
+			}
+			return links, nil
+		}
+		s := p.Scope("parsing a link")
+		if _, err := p.ReadCharacter('^'); err != nil {
+			return links, s.Annotate(err)
+		}
+		r, err := p.ReadWhile1("a link", isIdentifier)
+		if err != nil {
+			return links, s.Annotate(err)
+		}
+		links = append(links, r)
+	}
 }
 
 func (p *Parser) parseAddons() (directives.Addons, error) {
@@ -461,10 +1253,8 @@ func (p *Parser) parsePerformance() (directives.Performance, error) {
 		return directives.SetRange(&perf, s.Range()), s.Annotate(err)
 	}
 	if p.Current() != ')' {
-		if c, err := p.parseCommodity(); err != nil {
+		if err := p.parsePerformanceTarget(&perf); err != nil {
 			return directives.SetRange(&perf, s.Range()), s.Annotate(err)
-		} else {
-			perf.Targets = append(perf.Targets, c)
 		}
 		if _, err := p.ReadWhile(isWhitespace); err != nil {
 			return directives.SetRange(&perf, s.Range()), s.Annotate(err)
@@ -477,10 +1267,8 @@ func (p *Parser) parsePerformance() (directives.Performance, error) {
 		if _, err := p.ReadWhile(isWhitespace); err != nil {
 			return directives.SetRange(&perf, s.Range()), s.Annotate(err)
 		}
-		if c, err := p.parseCommodity(); err != nil {
+		if err := p.parsePerformanceTarget(&perf); err != nil {
 			return directives.SetRange(&perf, s.Range()), s.Annotate(err)
-		} else {
-			perf.Targets = append(perf.Targets, c)
 		}
 		if _, err := p.ReadWhile(isWhitespace); err != nil {
 			return directives.SetRange(&perf, s.Range()), s.Annotate(err)
@@ -492,6 +1280,32 @@ func (p *Parser) parsePerformance() (directives.Performance, error) {
 	return directives.SetRange(&perf, s.Range()), nil
 }
 
+// parsePerformanceTarget parses a commodity or a regex pattern enclosed in
+// slashes.
+func (p *Parser) parsePerformanceTarget(perf *directives.Performance) error {
+	if p.Current() != '/' {
+		c, err := p.parseCommodity()
+		if err != nil {
+			return err
+		}
+		perf.Targets = append(perf.Targets, c)
+		return nil
+	}
+	s := p.Scope("parsing pattern")
+	if _, err := p.ReadCharacter('/'); err != nil {
+		return s.Annotate(err)
+	}
+	r, err := p.ReadUntil("`/`", func(r rune) bool { return r == '/' || isNewlineOrEOF(r) })
+	if err != nil {
+		return s.Annotate(err)
+	}
+	if _, err := p.ReadCharacter('/'); err != nil {
+		return s.Annotate(err)
+	}
+	perf.Patterns = append(perf.Patterns, r)
+	return nil
+}
+
 func (p *Parser) parseAccrual() (directives.Accrual, error) {
 	s := p.Scope("parsing addons")
 	accrual := directives.Accrual{Range: s.Range()}
@@ -502,6 +1316,11 @@ func (p *Parser) parseAccrual() (directives.Accrual, error) {
 	if accrual.Interval, err = p.parseInterval(); err != nil {
 		return directives.SetRange(&accrual, s.Range()), s.Annotate(err)
 	}
+	if p.Current() == ':' {
+		if accrual.Anchor, err = p.parseAnchor(); err != nil {
+			return directives.SetRange(&accrual, s.Range()), s.Annotate(err)
+		}
+	}
 	if _, err := p.readWhitespace1(); err != nil {
 		return directives.SetRange(&accrual, s.Range()), s.Annotate(err)
 	}
@@ -520,9 +1339,26 @@ func (p *Parser) parseAccrual() (directives.Accrual, error) {
 	if accrual.Account, err = p.parseAccount(); err != nil {
 		return directives.SetRange(&accrual, s.Range()), s.Annotate(err)
 	}
+	if accrual.Reverse, err = p.parseReverse(); err != nil {
+		return directives.SetRange(&accrual, s.Range()), s.Annotate(err)
+	}
 	return directives.SetRange(&accrual, s.Range()), nil
 }
 
+// parseReverse parses the optional `reverse` keyword of an accrual. It
+// returns an empty range if the keyword is absent.
+func (p *Parser) parseReverse() (directives.Range, error) {
+	offset := p.Offset()
+	if _, err := p.ReadWhile(isWhitespace); err != nil {
+		return directives.Range{}, err
+	}
+	if p.Current() != 'r' {
+		p.Backtrack(offset)
+		return directives.Range{}, nil
+	}
+	return p.ReadString("reverse")
+}
+
 func (p *Parser) parseInterval() (directives.Interval, error) {
 	s := p.Scope("parsing interval")
 	if _, err := p.ReadAlternative([]string{"daily", "weekly", "monthly", "quarterly"}); err != nil {
@@ -531,6 +1367,46 @@ func (p *Parser) parseInterval() (directives.Interval, error) {
 	return directives.Interval{Range: s.Range()}, nil
 }
 
+func (p *Parser) parseAnchor() (directives.Anchor, error) {
+	s := p.Scope("parsing anchor")
+	if _, err := p.ReadCharacter(':'); err != nil {
+		return directives.Anchor{Range: s.Range()}, s.Annotate(err)
+	}
+	r, err := p.ReadWhile1("a digit", unicode.IsDigit)
+	if err != nil {
+		return directives.Anchor{Range: r}, s.Annotate(err)
+	}
+	return directives.Anchor{Range: r}, nil
+}
+
+// readIndentation reads the spaces and tabs at the start of a line.
+func (p *Parser) readIndentation() (directives.Range, error) {
+	return p.ReadWhile(isWhitespace)
+}
+
+// isIndentedLine returns whether the current line is not blank and thus
+// continues a multi-line directive. Lines may be indented with spaces or
+// tabs.
+func (p *Parser) isIndentedLine() bool {
+	offset := p.Offset()
+	defer p.Backtrack(offset)
+	for isWhitespace(p.Current()) {
+		if err := p.Advance(); err != nil {
+			return false
+		}
+	}
+	return !isNewlineOrEOF(p.Current())
+}
+
+// startsWith returns whether the input continues with str, without consuming
+// it.
+func (p *Parser) startsWith(str string) bool {
+	offset := p.Offset()
+	defer p.Backtrack(offset)
+	_, err := p.ReadString(str)
+	return err == nil
+}
+
 func (p *Parser) readWhitespace1() (directives.Range, error) {
 	s := p.Scope("")
 	if !isWhitespaceOrNewline(p.Current()) && p.Current() != scanner.EOF {
@@ -556,10 +1432,18 @@ func (p *Parser) readRestOfWhitespaceLine() (directives.Range, error) {
 	return s.Range(), nil
 }
 
+func isCommentStart(r rune) bool {
+	return r == '*' || r == '#' || r == '/' || r == ';'
+}
+
 func isAlphanumeric(r rune) bool {
 	return unicode.IsLetter(r) || unicode.IsDigit(r)
 }
 
+func isIdentifier(r rune) bool {
+	return isAlphanumeric(r) || r == '-' || r == '_' || r == '.'
+}
+
 func isWhitespace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\r'
 }
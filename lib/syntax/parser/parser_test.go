@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/sboehler/knut/lib/syntax/directives"
+	"go.uber.org/multierr"
 )
 
 type Range = directives.Range
@@ -92,7 +93,7 @@ func TestParseFile(t *testing.T) {
 			{
 				text: strings.Join([]string{
 					"",
-					"asdf",
+					"xsdf",
 				}, "\n"),
 				want: func(s string) directives.File {
 					return directives.File{
@@ -114,7 +115,7 @@ func TestParseFile(t *testing.T) {
 								Range:   Range{Start: 1, End: 1, Text: s},
 								Wrapped: directives.Error{
 									Range:   directives.Range{Start: 1, End: 1, Text: s},
-									Message: "unexpected character `a`, want a digit",
+									Message: "unexpected character `x`, want a digit",
 								},
 							},
 						},
@@ -268,6 +269,42 @@ func TestParsePerformance(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "(CHF, /^Crypto:/)",
+				want: func(s string) directives.Performance {
+					return directives.Performance{
+						Range: Range{End: 17, Text: s},
+						Targets: []directives.Commodity{
+							{Range: Range{Start: 1, End: 4, Text: s}},
+						},
+						Patterns: []Range{
+							{Start: 7, End: 15, Text: s},
+						},
+					}
+				},
+			},
+			{
+				text: "(/BTC)",
+				want: func(s string) directives.Performance {
+					return directives.Performance{
+						Range: Range{End: 6, Text: s},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing performance",
+						Range:   Range{End: 6, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing pattern",
+							Range:   Range{Start: 1, End: 6, Text: s},
+							Wrapped: directives.Error{
+								Message: "unexpected end of file, want `/`",
+								Range:   Range{Start: 2, End: 6, Text: s},
+							},
+						},
+					}
+				},
+			},
 			{
 				text: "(A)",
 				want: func(s string) directives.Performance {
@@ -329,6 +366,42 @@ func TestParseAccrual(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: " monthly 2023-01-01 2023-12-31 A:B reverse",
+				want: func(s string) directives.Accrual {
+					return directives.Accrual{
+						Range:    Range{End: 42, Text: s},
+						Interval: directives.Interval{Range: Range{Start: 1, End: 8, Text: s}},
+						Start:    directives.Date{Range: Range{Start: 9, End: 19, Text: s}},
+						End:      directives.Date{Range: Range{Start: 20, End: 30, Text: s}},
+						Account:  directives.Account{Range: Range{Start: 31, End: 34, Text: s}},
+						Reverse:  Range{Start: 35, End: 42, Text: s},
+					}
+				},
+			},
+			{
+				text: " monthly 2023-01-01 2023-12-31 A:B revert",
+				want: func(s string) directives.Accrual {
+					return directives.Accrual{
+						Range:    Range{End: 40, Text: s},
+						Interval: directives.Interval{Range: Range{Start: 1, End: 8, Text: s}},
+						Start:    directives.Date{Range: Range{Start: 9, End: 19, Text: s}},
+						End:      directives.Date{Range: Range{Start: 20, End: 30, Text: s}},
+						Account:  directives.Account{Range: Range{Start: 31, End: 34, Text: s}},
+						Reverse:  Range{Start: 35, End: 40, Text: s},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing addons",
+						Range:   Range{End: 40, Text: s},
+						Wrapped: directives.Error{
+							Range:   Range{Start: 35, End: 40, Text: s},
+							Message: "while reading \"reverse\"",
+						},
+					}
+				},
+			},
 			{
 				text: "",
 				want: func(s string) directives.Accrual {
@@ -374,6 +447,22 @@ func TestParseAddons(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "@accrue monthly:15 2023-01-01 2023-12-31 A:B",
+				want: func(s string) directives.Addons {
+					return directives.Addons{
+						Range: Range{End: 44, Text: s},
+						Accrual: directives.Accrual{
+							Range:    Range{End: 44, Text: s},
+							Interval: directives.Interval{Range: Range{Start: 8, End: 15, Text: s}},
+							Anchor:   directives.Anchor{Range: Range{Start: 16, End: 18, Text: s}},
+							Start:    directives.Date{Range: Range{Start: 19, End: 29, Text: s}},
+							End:      directives.Date{Range: Range{Start: 30, End: 40, Text: s}},
+							Account:  directives.Account{Range: Range{Start: 41, End: 44, Text: s}},
+						},
+					}
+				},
+			},
 			{
 				text: "@performance(USD)",
 				want: func(s string) directives.Addons {
@@ -739,6 +828,12 @@ func TestReadComment(t *testing.T) {
 					return directives.Range{End: 11, Text: s}
 				},
 			},
+			{
+				text: "; a comment",
+				want: func(s string) directives.Range {
+					return directives.Range{End: 11, Text: s}
+				},
+			},
 			{
 				text: "-- not a comment",
 				want: func(s string) directives.Range {
@@ -749,7 +844,7 @@ func TestReadComment(t *testing.T) {
 						Message: "while reading comment",
 						Range:   directives.Range{Text: s},
 						Wrapped: directives.Error{
-							Message: "unexpected input, want one of {`*`, `//`, `#`}",
+							Message: "unexpected input, want one of {`*`, `//`, `#`, `;`}",
 							Range:   directives.Range{Text: s},
 						},
 					}
@@ -886,6 +981,35 @@ func TestParseBooking(t *testing.T) {
 						}}
 				},
 			},
+			{
+				text: "(A:B) 100.0 CHF",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 15, Text: t},
+						Virtual:   directives.Account{Range: Range{Start: 1, End: 4, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 6, End: 11, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 12, End: 15, Text: t}},
+					}
+				},
+			},
+			{
+				text: "(A:B 100.0 CHF",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:   Range{End: 4, Text: t},
+						Virtual: directives.Account{Range: Range{Start: 1, End: 4, Text: t}},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing booking",
+						Range:   Range{End: 4, Text: s},
+						Wrapped: directives.Error{
+							Range:   directives.Range{Start: 4, End: 4, Text: s},
+							Message: "unexpected character ` `, want `)`",
+						}}
+				},
+			},
 			{
 				text: "C:D  $dividend  100.0  CHF",
 				want: func(t string) directives.Booking {
@@ -898,6 +1022,174 @@ func TestParseBooking(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "A B 1 CHF  ;  a comment  \n",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 25, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 5, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 6, End: 9, Text: t}},
+						Comment:   Range{Start: 14, End: 23, Text: t},
+					}
+				},
+			},
+			{
+				text: "A B 1 CHF  \n",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 9, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 5, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 6, End: 9, Text: t}},
+					}
+				},
+			},
+			{
+				text: "A B 4 AAPL {{600 USD}}",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 22, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 5, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 6, End: 10, Text: t}},
+						Cost: directives.Cost{
+							Range:     Range{Start: 11, End: 22, Text: t},
+							Amount:    directives.Decimal{Range: Range{Start: 13, End: 16, Text: t}},
+							Commodity: directives.Commodity{Range: Range{Start: 17, End: 20, Text: t}},
+							Total:     true,
+						},
+					}
+				},
+			},
+			{
+				text: "A B 2 AAPL { 140.5 USD } ; x",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 28, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 5, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 6, End: 10, Text: t}},
+						Cost: directives.Cost{
+							Range:     Range{Start: 11, End: 24, Text: t},
+							Amount:    directives.Decimal{Range: Range{Start: 13, End: 18, Text: t}},
+							Commodity: directives.Commodity{Range: Range{Start: 19, End: 22, Text: t}},
+						},
+						Comment: Range{Start: 27, End: 28, Text: t},
+					}
+				},
+			},
+			{
+				text: "A B 4 AAPL {{600 USD}",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 21, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 5, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 6, End: 10, Text: t}},
+						Cost: directives.Cost{
+							Range:     Range{Start: 11, End: 21, Text: t},
+							Amount:    directives.Decimal{Range: Range{Start: 13, End: 16, Text: t}},
+							Commodity: directives.Commodity{Range: Range{Start: 17, End: 20, Text: t}},
+							Total:     true,
+						},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing booking",
+						Range:   Range{End: 21, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing cost",
+							Range:   Range{Start: 11, End: 21, Text: s},
+							Wrapped: directives.Error{
+								Range:   Range{Start: 21, End: 21, Text: s},
+								Message: "unexpected end of file, want `}`",
+							},
+						},
+					}
+				},
+			},
+			{
+				text: "A B 100 EUR @ 1.08 USD",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 22, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 7, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 8, End: 11, Text: t}},
+						Rate: directives.Rate{
+							Range:     Range{Start: 12, End: 22, Text: t},
+							Amount:    directives.Decimal{Range: Range{Start: 14, End: 18, Text: t}},
+							Commodity: directives.Commodity{Range: Range{Start: 19, End: 22, Text: t}},
+						},
+					}
+				},
+			},
+			{
+				text: "A B 4 AAPL {150 USD} @@ 540 EUR ; x",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 35, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 5, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 6, End: 10, Text: t}},
+						Cost: directives.Cost{
+							Range:     Range{Start: 11, End: 20, Text: t},
+							Amount:    directives.Decimal{Range: Range{Start: 12, End: 15, Text: t}},
+							Commodity: directives.Commodity{Range: Range{Start: 16, End: 19, Text: t}},
+						},
+						Rate: directives.Rate{
+							Range:     Range{Start: 21, End: 31, Text: t},
+							Amount:    directives.Decimal{Range: Range{Start: 24, End: 27, Text: t}},
+							Commodity: directives.Commodity{Range: Range{Start: 28, End: 31, Text: t}},
+							Total:     true,
+						},
+						Comment: Range{Start: 34, End: 35, Text: t},
+					}
+				},
+			},
+			{
+				text: "A B 100 EUR @ USD",
+				want: func(t string) directives.Booking {
+					return directives.Booking{
+						Range:     Range{End: 14, Text: t},
+						Credit:    directives.Account{Range: Range{End: 1, Text: t}},
+						Debit:     directives.Account{Range: Range{Start: 2, End: 3, Text: t}},
+						Quantity:  directives.Decimal{Range: Range{Start: 4, End: 7, Text: t}},
+						Commodity: directives.Commodity{Range: Range{Start: 8, End: 11, Text: t}},
+						Rate: directives.Rate{
+							Range:  Range{Start: 12, End: 14, Text: t},
+							Amount: directives.Decimal{Range: Range{Start: 14, End: 14, Text: t}},
+						},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing booking",
+						Range:   Range{End: 14, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing rate",
+							Range:   Range{Start: 12, End: 14, Text: s},
+							Wrapped: directives.Error{
+								Message: "while parsing decimal",
+								Range:   Range{Start: 14, End: 14, Text: s},
+								Wrapped: directives.Error{
+									Range:   Range{Start: 14, End: 14, Text: s},
+									Message: "unexpected character `U`, want a digit",
+								},
+							},
+						},
+					}
+				},
+			},
 		},
 		desc: "p.parseBooking()",
 		fn: func(p *Parser) (directives.Booking, error) {
@@ -973,6 +1265,219 @@ func TestParseInclude(t *testing.T) {
 	}.run(t)
 }
 
+func TestParseAccountType(t *testing.T) {
+	parserTest[directives.AccountType]{
+		tests: []testcase[directives.AccountType]{
+			{
+				text: "accounttype Memo off-balance",
+				want: func(t string) directives.AccountType {
+					return directives.AccountType{
+						Range:     Range{End: 28, Text: t},
+						Name:      Range{Start: 12, End: 16, Text: t},
+						Placement: Range{Start: 17, End: 28, Text: t},
+					}
+				},
+			},
+			{
+				text: "accounttype Memo foo",
+				want: func(s string) directives.AccountType {
+					return directives.AccountType{
+						Range:     Range{End: 17, Text: s},
+						Name:      Range{Start: 12, End: 16, Text: s},
+						Placement: Range{Start: 17, End: 17, Text: s},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing `accounttype` directive",
+						Range:   Range{End: 17, Text: s},
+						Wrapped: directives.Error{
+							Range:   Range{Start: 17, End: 17, Text: s},
+							Message: "unexpected input, want one of {`al`, `eie`, `off-balance`}",
+						},
+					}
+				},
+			},
+		},
+		desc: "p.parseAccountType()",
+		fn: func(p *Parser) (directives.AccountType, error) {
+			return p.parseAccountType()
+		},
+	}.run(t)
+}
+
+func TestParseAlias(t *testing.T) {
+	parserTest[directives.Alias]{
+		tests: []testcase[directives.Alias]{
+			{
+				text: "alias commodity TWTR X",
+				want: func(t string) directives.Alias {
+					return directives.Alias{
+						Range:     Range{End: 22, Text: t},
+						Commodity: directives.Commodity{Range: Range{Start: 16, End: 20, Text: t}},
+						Target:    directives.Commodity{Range: Range{Start: 21, End: 22, Text: t}},
+					}
+				},
+			},
+			{
+				text: "alias account A B",
+				want: func(s string) directives.Alias {
+					return directives.Alias{
+						Range: Range{End: 6, Text: s},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing `alias` directive",
+						Range:   Range{End: 6, Text: s},
+						Wrapped: directives.Error{
+							Range:   Range{Start: 6, End: 6, Text: s},
+							Message: "unexpected input, want one of {`commodity`}",
+						},
+					}
+				},
+			},
+		},
+		desc: "p.parseAlias()",
+		fn: func(p *Parser) (directives.Alias, error) {
+			return p.parseAlias()
+		},
+	}.run(t)
+}
+
+func TestParseClock(t *testing.T) {
+	parserTest[directives.Clock]{
+		tests: []testcase[directives.Clock]{
+			{
+				text: "i 2023-03-01 09:00 Project:X",
+				want: func(t string) directives.Clock {
+					return directives.Clock{
+						Range:   Range{End: 28, Text: t},
+						In:      true,
+						Date:    directives.Date{Range: Range{Start: 2, End: 12, Text: t}},
+						Time:    directives.Time{Range: Range{Start: 13, End: 18, Text: t}},
+						Account: directives.Account{Range: Range{Start: 19, End: 28, Text: t}},
+					}
+				},
+			},
+			{
+				text: "o 2023-03-01 17:30:15",
+				want: func(t string) directives.Clock {
+					return directives.Clock{
+						Range: Range{End: 21, Text: t},
+						Date:  directives.Date{Range: Range{Start: 2, End: 12, Text: t}},
+						Time:  directives.Time{Range: Range{Start: 13, End: 21, Text: t}},
+					}
+				},
+			},
+			{
+				text: "o 2023-03-01 9:00",
+				want: func(s string) directives.Clock {
+					return directives.Clock{
+						Range: Range{End: 14, Text: s},
+						Date:  directives.Date{Range: Range{Start: 2, End: 12, Text: s}},
+						Time:  directives.Time{Range: Range{Start: 13, End: 14, Text: s}},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing timeclock entry",
+						Range:   Range{End: 14, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing the time",
+							Range:   Range{Start: 13, End: 14, Text: s},
+							Wrapped: directives.Error{
+								Range:   Range{Start: 14, End: 14, Text: s},
+								Message: "unexpected character `:`, want a digit",
+							},
+						},
+					}
+				},
+			},
+		},
+		desc: "p.parseClock()",
+		fn: func(p *Parser) (directives.Clock, error) {
+			return p.parseClock()
+		},
+	}.run(t)
+}
+
+func TestParseCommodityDeclaration(t *testing.T) {
+	parserTest[directives.CommodityDeclaration]{
+		tests: []testcase[directives.CommodityDeclaration]{
+			{
+				text: "commodity VT",
+				want: func(t string) directives.CommodityDeclaration {
+					return directives.CommodityDeclaration{
+						Range:     Range{End: 12, Text: t},
+						Commodity: directives.Commodity{Range: Range{Start: 10, End: 12, Text: t}},
+					}
+				},
+			},
+			{
+				text: "commodity VT\n  asset-class: \"equity\"\n\tregion:\"US\"\n2023-01-01 open A",
+				want: func(t string) directives.CommodityDeclaration {
+					return directives.CommodityDeclaration{
+						Range:     Range{End: 49, Text: t},
+						Commodity: directives.Commodity{Range: Range{Start: 10, End: 12, Text: t}},
+						Metadata: []directives.Metadata{
+							{
+								Range: Range{Start: 15, End: 36, Text: t},
+								Key:   Range{Start: 15, End: 26, Text: t},
+								Value: directives.QuotedString{
+									Range:   Range{Start: 28, End: 36, Text: t},
+									Content: Range{Start: 29, End: 35, Text: t},
+								},
+							},
+							{
+								Range: Range{Start: 38, End: 49, Text: t},
+								Key:   Range{Start: 38, End: 44, Text: t},
+								Value: directives.QuotedString{
+									Range:   Range{Start: 45, End: 49, Text: t},
+									Content: Range{Start: 46, End: 48, Text: t},
+								},
+							},
+						},
+					}
+				},
+			},
+			{
+				text: "commodity VT\n  asset-class \"equity\"",
+				want: func(s string) directives.CommodityDeclaration {
+					return directives.CommodityDeclaration{
+						Range:     Range{End: 26, Text: s},
+						Commodity: directives.Commodity{Range: Range{Start: 10, End: 12, Text: s}},
+						Metadata: []directives.Metadata{
+							{
+								Range: Range{Start: 15, End: 26, Text: s},
+								Key:   Range{Start: 15, End: 26, Text: s},
+							},
+						},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing `commodity` directive",
+						Range:   Range{End: 26, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing metadata",
+							Range:   Range{Start: 15, End: 26, Text: s},
+							Wrapped: directives.Error{
+								Range:   Range{Start: 26, End: 26, Text: s},
+								Message: "unexpected character ` `, want `:`",
+							},
+						},
+					}
+				},
+			},
+		},
+		desc: "p.parseCommodityDeclaration()",
+		fn: func(p *Parser) (directives.CommodityDeclaration, error) {
+			return p.parseCommodityDeclaration()
+		},
+	}.run(t)
+}
+
 func TestParseQuotedString(t *testing.T) {
 	parserTest[directives.QuotedString]{
 		desc: "p.parseQuotedString()",
@@ -1031,6 +1536,55 @@ func TestParseQuotedString(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: `"a \"b\" \\ \n\t\u00e9"`,
+				want: func(s string) directives.QuotedString {
+					return directives.QuotedString{
+						Range:   Range{End: 23, Text: s},
+						Content: Range{Start: 1, End: 22, Text: s},
+					}
+				},
+			},
+			{
+				text: `"a\x"`,
+				want: func(s string) directives.QuotedString {
+					return directives.QuotedString{Range: Range{End: 3, Text: s}}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing quoted string",
+						Range:   Range{End: 3, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing escape sequence",
+							Range:   Range{Start: 2, End: 3, Text: s},
+							Wrapped: directives.Error{
+								Range:   Range{Start: 3, End: 3, Text: s},
+								Message: "unexpected character `x`, want one of `\"`, `\\`, `n`, `t` or `u`",
+							},
+						},
+					}
+				},
+			},
+			{
+				text: `"\u00g0"`,
+				want: func(s string) directives.QuotedString {
+					return directives.QuotedString{Range: Range{End: 5, Text: s}}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing quoted string",
+						Range:   Range{End: 5, Text: s},
+						Wrapped: directives.Error{
+							Message: "while parsing escape sequence",
+							Range:   Range{Start: 1, End: 5, Text: s},
+							Wrapped: directives.Error{
+								Range:   Range{Start: 5, End: 5, Text: s},
+								Message: "unexpected character `g`, want a hexadecimal digit",
+							},
+						},
+					}
+				},
+			},
 		},
 	}.run(t)
 }
@@ -1108,6 +1662,107 @@ func TestParseTransaction(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "\"foo\"\n" + "\tA B 1 CHF\n" + "  B A 1 CHF\n", // 6 + 11 + 12
+				want: func(t string) directives.Transaction {
+					return directives.Transaction{
+						Range: Range{End: 29, Text: t},
+						Description: directives.QuotedString{
+							Range:   Range{End: 5, Text: t},
+							Content: Range{Start: 1, End: 4, Text: t},
+						},
+						Bookings: []directives.Booking{
+							{
+								Range:     Range{Start: 7, End: 16, Text: t},
+								Credit:    directives.Account{Range: Range{Start: 7, End: 8, Text: t}},
+								Debit:     directives.Account{Range: Range{Start: 9, End: 10, Text: t}},
+								Quantity:  directives.Decimal{Range: Range{Start: 11, End: 12, Text: t}},
+								Commodity: directives.Commodity{Range: Range{Start: 13, End: 16, Text: t}},
+							},
+							{
+								Range:     Range{Start: 19, End: 28, Text: t},
+								Credit:    directives.Account{Range: Range{Start: 19, End: 20, Text: t}},
+								Debit:     directives.Account{Range: Range{Start: 21, End: 22, Text: t}},
+								Quantity:  directives.Decimal{Range: Range{Start: 23, End: 24, Text: t}},
+								Commodity: directives.Commodity{Range: Range{Start: 25, End: 28, Text: t}},
+							},
+						},
+					}
+				},
+			},
+			{
+				text: "\"foo\" id:a-1\n" + "A B 1 CHF\n", // 13 + 10
+				want: func(t string) directives.Transaction {
+					return directives.Transaction{
+						Range: Range{End: 23, Text: t},
+						Description: directives.QuotedString{
+							Range:   Range{End: 5, Text: t},
+							Content: Range{Start: 1, End: 4, Text: t},
+						},
+						ID: Range{Start: 9, End: 12, Text: t},
+						Bookings: []directives.Booking{
+							{
+								Range:     Range{Start: 13, End: 22, Text: t},
+								Credit:    directives.Account{Range: Range{Start: 13, End: 14, Text: t}},
+								Debit:     directives.Account{Range: Range{Start: 15, End: 16, Text: t}},
+								Quantity:  directives.Decimal{Range: Range{Start: 17, End: 18, Text: t}},
+								Commodity: directives.Commodity{Range: Range{Start: 19, End: 22, Text: t}},
+							},
+						},
+					}
+				},
+			},
+			{
+				text: "\"foo\" ^a ^b-1\n" + "A B 1 CHF\n", // 14 + 10
+				want: func(t string) directives.Transaction {
+					return directives.Transaction{
+						Range: Range{End: 24, Text: t},
+						Description: directives.QuotedString{
+							Range:   Range{End: 5, Text: t},
+							Content: Range{Start: 1, End: 4, Text: t},
+						},
+						Links: []Range{
+							{Start: 7, End: 8, Text: t},
+							{Start: 10, End: 13, Text: t},
+						},
+						Bookings: []directives.Booking{
+							{
+								Range:     Range{Start: 14, End: 23, Text: t},
+								Credit:    directives.Account{Range: Range{Start: 14, End: 15, Text: t}},
+								Debit:     directives.Account{Range: Range{Start: 16, End: 17, Text: t}},
+								Quantity:  directives.Decimal{Range: Range{Start: 18, End: 19, Text: t}},
+								Commodity: directives.Commodity{Range: Range{Start: 20, End: 23, Text: t}},
+							},
+						},
+					}
+				},
+			},
+			{
+				text: "\"foo\" ^\n" + "A B 1 CHF\n",
+				want: func(t string) directives.Transaction {
+					return directives.Transaction{
+						Range: Range{End: 7, Text: t},
+						Description: directives.QuotedString{
+							Range:   Range{End: 5, Text: t},
+							Content: Range{Start: 1, End: 4, Text: t},
+						},
+					}
+				},
+				err: func(s string) error {
+					return directives.Error{
+						Message: "while parsing transaction",
+						Range:   Range{End: 7, Text: s},
+						Wrapped: directives.Error{
+							Range:   Range{Start: 6, End: 7, Text: s},
+							Message: "while parsing a link",
+							Wrapped: directives.Error{
+								Range:   Range{Start: 7, End: 7, Text: s},
+								Message: "unexpected character `\n`, want a link",
+							},
+						},
+					}
+				},
+			},
 			{
 				text: strings.Join([]string{`"foo"`, "A B"}, "\n"), // 6 + 10
 				want: func(t string) directives.Transaction {
@@ -1282,6 +1937,20 @@ func TestParseDirective(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "2023-04-03 open B:A valuation C:D",
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 33, Text: s},
+						Directive: directives.Open{
+							Range:     Range{End: 33, Text: s},
+							Date:      directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Account:   directives.Account{Range: directives.Range{Start: 16, End: 19, Text: s}},
+							Valuation: directives.Account{Range: directives.Range{Start: 30, End: 33, Text: s}},
+						},
+					}
+				},
+			},
 			{
 				text: `include "foo/foo.knut"`,
 				want: func(s string) directives.Directive {
@@ -1310,6 +1979,40 @@ func TestParseDirective(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: `2023-04-03 note B:A "foo"`,
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 25, Text: s},
+						Directive: directives.Note{
+							Range:   Range{End: 25, Text: s},
+							Date:    directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Account: directives.Account{Range: directives.Range{Start: 16, End: 19, Text: s}},
+							Text: directives.QuotedString{
+								Range:   Range{Start: 20, End: 25, Text: s},
+								Content: Range{Start: 21, End: 24, Text: s},
+							},
+						},
+					}
+				},
+			},
+			{
+				text: `2023-04-03 document B:A "a.pdf"`,
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 31, Text: s},
+						Directive: directives.Document{
+							Range:   Range{End: 31, Text: s},
+							Date:    directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Account: directives.Account{Range: directives.Range{Start: 20, End: 23, Text: s}},
+							DocumentPath: directives.QuotedString{
+								Range:   Range{Start: 24, End: 31, Text: s},
+								Content: Range{Start: 25, End: 30, Text: s},
+							},
+						},
+					}
+				},
+			},
 			{
 				text: "2023-04-03 balance B:A 1 USD",
 				want: func(s string) directives.Directive {
@@ -1330,6 +2033,54 @@ func TestParseDirective(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "2023-04-03 balance B:A 10 AAPL {150 USD, 2023-02-01}",
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 52, Text: s},
+						Directive: directives.Assertion{
+							Range: Range{End: 52, Text: s},
+							Date:  directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Balances: []directives.Balance{
+								{
+									Range:     Range{Start: 19, End: 52, Text: s},
+									Account:   directives.Account{Range: directives.Range{Start: 19, End: 22, Text: s}},
+									Quantity:  directives.Decimal{Range: directives.Range{Start: 23, End: 25, Text: s}},
+									Commodity: directives.Commodity{Range: Range{Start: 26, End: 30, Text: s}},
+									Cost: directives.Cost{
+										Range:     Range{Start: 31, End: 52, Text: s},
+										Amount:    directives.Decimal{Range: Range{Start: 32, End: 35, Text: s}},
+										Commodity: directives.Commodity{Range: Range{Start: 36, End: 39, Text: s}},
+										Date:      directives.Date{Range: Range{Start: 41, End: 51, Text: s}},
+									},
+								},
+							},
+						},
+					}
+				},
+			},
+			{
+				text: "2023-04-03 balance B:A:* 100.00 ~ 0.01 USD",
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 42, Text: s},
+						Directive: directives.Assertion{
+							Range: Range{End: 42, Text: s},
+							Date:  directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Balances: []directives.Balance{
+								{
+									Range:     Range{Start: 19, End: 42, Text: s},
+									Account:   directives.Account{Range: directives.Range{Start: 19, End: 22, Text: s}},
+									Subtree:   Range{Start: 22, End: 24, Text: s},
+									Quantity:  directives.Decimal{Range: directives.Range{Start: 25, End: 31, Text: s}},
+									Tolerance: directives.Decimal{Range: directives.Range{Start: 34, End: 38, Text: s}},
+									Commodity: directives.Commodity{Range: Range{Start: 39, End: 42, Text: s}},
+								},
+							},
+						},
+					}
+				},
+			},
 			{
 				text: "2023-04-03 balance\nB:A 1 USD\nB:A 1 EUR",
 				want: func(s string) directives.Directive {
@@ -1371,6 +2122,21 @@ func TestParseDirective(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "2023-12-31 value Assets:House 800000 CHF",
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 40, Text: s},
+						Directive: directives.Value{
+							Range:     Range{End: 40, Text: s},
+							Date:      directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Account:   directives.Account{Range: directives.Range{Start: 17, End: 29, Text: s}},
+							Quantity:  directives.Decimal{Range: directives.Range{Start: 30, End: 36, Text: s}},
+							Commodity: directives.Commodity{Range: Range{Start: 37, End: 40, Text: s}},
+						},
+					}
+				},
+			},
 		},
 		desc: "p.parseDirective()",
 		fn: func(p *Parser) (directives.Directive, error) {
@@ -1460,3 +2226,156 @@ func TestReadWhitespace1(t *testing.T) {
 		},
 	}.run(t)
 }
+
+func TestParseFileRecover(t *testing.T) {
+	text := strings.Join([]string{
+		"2021-01-01 open A",
+		"",
+		"2021-01-02 foo B",
+		"2021-01-02 open C",
+		"",
+		"2021-01-03 open D",
+		"2021-01-04 \"desc\"",
+		"A B 1.0",
+		"",
+		"2021-01-05 close A",
+	}, "\n")
+	p := New(text, "")
+	p.Recover = true
+	if err := p.Advance(); err != nil {
+		t.Fatalf("p.Advance() = %v, want nil", err)
+	}
+
+	f, err := p.ParseFile()
+
+	if got := len(multierr.Errors(err)); got != 2 {
+		t.Errorf("ParseFile() returned %d errors, want 2: %v", got, err)
+	}
+	var got []string
+	for _, d := range f.Directives {
+		if d.Directive != nil {
+			got = append(got, d.Extract())
+		}
+	}
+	want := []string{"2021-01-01 open A", "2021-01-03 open D", "2021-01-04 \"desc\"\nA B 1.0", "2021-01-05 close A"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseFile() returned unexpected diff (-want/+got)\n%s\n", diff)
+	}
+}
+
+func TestParseFileIgnore(t *testing.T) {
+	tests := []struct {
+		text    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			text: []string{
+				"; knut: ignore-file",
+				"2021-01-01 open A",
+				"this is not valid",
+			},
+		},
+		{
+			text: []string{
+				"2021-01-01 open A",
+				"# knut: ignore-begin",
+				"this is not valid",
+				"",
+				"2021-01-02 open B",
+				"  ; knut: ignore-end",
+				"2021-01-03 open C",
+			},
+			want: []string{"2021-01-01 open A", "2021-01-03 open C"},
+		},
+		{
+			text: []string{
+				"2021-01-01 open A",
+				"; knut: ignore-file",
+			},
+			want:    []string{"2021-01-01 open A"},
+			wantErr: true,
+		},
+		{
+			text: []string{
+				"; knut: ignore-begin",
+				"2021-01-01 open A",
+			},
+			wantErr: true,
+		},
+		{
+			text: []string{
+				"2021-01-01 open A",
+				"; knut: ignore-end",
+			},
+			want:    []string{"2021-01-01 open A"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		text := strings.Join(test.text, "\n")
+		t.Run(text, func(t *testing.T) {
+			p := New(text, "")
+			if err := p.Advance(); err != nil {
+				t.Fatalf("p.Advance() = %v, want nil", err)
+			}
+
+			f, err := p.ParseFile()
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseFile() returned error %v, want error: %t", err, test.wantErr)
+			}
+			var got []string
+			for _, d := range f.Directives {
+				got = append(got, d.Extract())
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ParseFile() returned unexpected diff (-want/+got)\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestPragma(t *testing.T) {
+	for comment, want := range map[string]string{
+		"; knut: ignore-file":       "ignore-file",
+		"# knut:ignore-begin":       "ignore-begin",
+		"// knut: disable foo":      "disable foo",
+		"* knut: ignore-end  ":      "ignore-end",
+		"; knut is great":           "",
+		"; some comment knut: foo ": "",
+		"2021-01-01 open A":         "",
+	} {
+		if got := Pragma(comment); got != want {
+			t.Errorf("Pragma(%q) = %q, want %q", comment, got, want)
+		}
+	}
+}
+
+func TestAttachedComments(t *testing.T) {
+	text := strings.Join([]string{
+		"; knut:disable foo",
+		"",
+		"# first",
+		"; knut:disable bar",
+		"2021-01-01 open A",
+		"2021-01-02 open B",
+	}, "\n")
+	tests := []struct {
+		start int
+		want  []string
+	}{
+		{start: strings.Index(text, "2021-01-01"), want: []string{"# first", "; knut:disable bar"}},
+		{start: strings.Index(text, "2021-01-02")},
+		{start: 0},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, r := range AttachedComments(Range{Start: test.start, End: test.start, Text: text}) {
+			got = append(got, r.Extract())
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("AttachedComments(%d) returned unexpected diff (-want/+got)\n%s\n", test.start, diff)
+		}
+	}
+}
@@ -55,12 +55,30 @@ func (p *Printer) printDirective(directive directives.Directive) error {
 		return p.printOpen(d)
 	case directives.Close:
 		return p.printClose(d)
+	case directives.Note:
+		return p.printNote(d)
+	case directives.Document:
+		return p.printDocument(d)
 	case directives.Assertion:
 		return p.printAssertion(d)
 	case directives.Include:
 		return p.printInclude(d)
+	case directives.AccountType:
+		return p.printAccountType(d)
+	case directives.CommodityDeclaration:
+		return p.printCommodityDeclaration(d)
+	case directives.Alias:
+		return p.printAlias(d)
+	case directives.Clock:
+		return p.printClock(d)
 	case directives.Price:
 		return p.printPrice(d)
+	case directives.Value:
+		return p.printValue(d)
+	case directives.Template:
+		return p.printTemplate(d)
+	case directives.Use:
+		return p.printUse(d)
 	}
 	return fmt.Errorf("unknown directive: %v", directive)
 }
@@ -76,13 +94,35 @@ func (p *Printer) printTransaction(t directives.Transaction) error {
 		for _, t := range t.Addons.Performance.Targets {
 			s = append(s, t.Extract())
 		}
+		for _, r := range t.Addons.Performance.Patterns {
+			s = append(s, "/"+r.Extract()+"/")
+		}
 		if _, err := fmt.Fprintf(p, "@performance(%s)\n", strings.Join(s, ",")); err != nil {
 			return err
 		}
 	}
-	if _, err := fmt.Fprintf(p, `%s "%s"`, t.Date.Extract(), t.Description.Content.Extract()); err != nil {
+	if _, err := fmt.Fprintf(p, "%s ", t.Date.Extract()); err != nil {
 		return err
 	}
+	return p.printTransactionBody(t)
+}
+
+// printTransactionBody prints the description and the bookings of a
+// transaction.
+func (p *Printer) printTransactionBody(t directives.Transaction) error {
+	if _, err := fmt.Fprintf(p, `"%s"`, t.Description.Content.Extract()); err != nil {
+		return err
+	}
+	if !t.ID.Empty() {
+		if _, err := fmt.Fprintf(p, " id:%s", t.ID.Extract()); err != nil {
+			return err
+		}
+	}
+	for _, l := range t.Links {
+		if _, err := fmt.Fprintf(p, " ^%s", l.Extract()); err != nil {
+			return err
+		}
+	}
 	if _, err := io.WriteString(p, "\n"); err != nil {
 		return err
 	}
@@ -97,18 +137,73 @@ func (p *Printer) printTransaction(t directives.Transaction) error {
 	return nil
 }
 
+func (p *Printer) printTemplate(t directives.Template) error {
+	var params []string
+	for _, r := range t.Parameters {
+		params = append(params, r.Extract())
+	}
+	if _, err := fmt.Fprintf(p, "template %s(%s)\n", t.Name.Extract(), strings.Join(params, ", ")); err != nil {
+		return err
+	}
+	return p.printTransactionBody(t.Body)
+}
+
+func (p *Printer) printUse(u directives.Use) error {
+	var args []string
+	for _, r := range u.Arguments {
+		args = append(args, r.Extract())
+	}
+	_, err := fmt.Fprintf(p, "%s use %s(%s)", u.Date.Extract(), u.Name.Extract(), strings.Join(args, ", "))
+	return err
+}
+
 func (p *Printer) printAccrual(a directives.Accrual) error {
-	_, err := fmt.Fprintf(p, "@accrue %s %s %s %s\n", a.Interval.Extract(), a.Start.Extract(), a.End.Extract(), a.Account.Extract())
+	interval := a.Interval.Extract()
+	if !a.Anchor.Empty() {
+		interval = interval + ":" + a.Anchor.Extract()
+	}
+	if _, err := fmt.Fprintf(p, "@accrue %s %s %s %s", interval, a.Start.Extract(), a.End.Extract(), a.Account.Extract()); err != nil {
+		return err
+	}
+	if !a.Reverse.Empty() {
+		if _, err := io.WriteString(p, " reverse"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(p, "\n")
 	return err
 }
 
 func (p *Printer) printPosting(t directives.Booking) error {
-	_, err := fmt.Fprintf(p, "%-*s %-*s %10s %s", p.padding, t.Credit.Extract(), p.padding, t.Debit.Extract(), t.Quantity.Extract(), t.Commodity.Extract())
-	return err
+	if !t.Virtual.Empty() {
+		if _, err := fmt.Fprintf(p, "%-*s %10s %s", 2*p.padding+1, "("+t.Virtual.Extract()+")", t.Quantity.Extract(), t.Commodity.Extract()); err != nil {
+			return err
+		}
+	} else if _, err := fmt.Fprintf(p, "%-*s %-*s %10s %s", p.padding, t.Credit.Extract(), p.padding, t.Debit.Extract(), t.Quantity.Extract(), t.Commodity.Extract()); err != nil {
+		return err
+	}
+	if err := p.printCost(t.Cost); err != nil {
+		return err
+	}
+	if err := p.printRate(t.Rate); err != nil {
+		return err
+	}
+	if !t.Comment.Empty() {
+		if _, err := fmt.Fprintf(p, " ; %s", t.Comment.Extract()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *Printer) printOpen(o directives.Open) error {
-	_, err := fmt.Fprintf(p, "%s open %s", o.Date.Extract(), o.Account.Extract())
+	if _, err := fmt.Fprintf(p, "%s open %s", o.Date.Extract(), o.Account.Extract()); err != nil {
+		return err
+	}
+	if o.Valuation.Empty() {
+		return nil
+	}
+	_, err := fmt.Fprintf(p, " valuation %s", o.Valuation.Extract())
 	return err
 }
 
@@ -117,35 +212,133 @@ func (p *Printer) printClose(c directives.Close) error {
 	return err
 }
 
+func (p *Printer) printNote(n directives.Note) error {
+	_, err := fmt.Fprintf(p, `%s note %s "%s"`, n.Date.Extract(), n.Account.Extract(), n.Text.Content.Extract())
+	return err
+}
+
+func (p *Printer) printDocument(d directives.Document) error {
+	_, err := fmt.Fprintf(p, `%s document %s "%s"`, d.Date.Extract(), d.Account.Extract(), d.DocumentPath.Content.Extract())
+	return err
+}
+
 func (p *Printer) printPrice(pr directives.Price) error {
 	_, err := fmt.Fprintf(p, "%s price %s %s %s", pr.Date.Extract(), pr.Commodity.Extract(), pr.Price.Extract(), pr.Target.Extract())
 	return err
 }
 
+func (p *Printer) printValue(v directives.Value) error {
+	_, err := fmt.Fprintf(p, "%s value %s %s %s", v.Date.Extract(), v.Account.Extract(), v.Quantity.Extract(), v.Commodity.Extract())
+	return err
+}
+
 func (p *Printer) printInclude(i directives.Include) error {
 	_, err := fmt.Fprintf(p, "include \"%s\"", i.IncludePath.Content.Extract())
 	return err
 }
 
+func (p *Printer) printAccountType(a directives.AccountType) error {
+	_, err := fmt.Fprintf(p, "accounttype %s %s", a.Name.Extract(), a.Placement.Extract())
+	return err
+}
+
+func (p *Printer) printAlias(a directives.Alias) error {
+	_, err := fmt.Fprintf(p, "alias commodity %s %s", a.Commodity.Extract(), a.Target.Extract())
+	return err
+}
+
+func (p *Printer) printClock(c directives.Clock) error {
+	if !c.In {
+		_, err := fmt.Fprintf(p, "o %s %s", c.Date.Extract(), c.Time.Extract())
+		return err
+	}
+	_, err := fmt.Fprintf(p, "i %s %s %s", c.Date.Extract(), c.Time.Extract(), c.Account.Extract())
+	return err
+}
+
+func (p *Printer) printCommodityDeclaration(c directives.CommodityDeclaration) error {
+	if _, err := fmt.Fprintf(p, "commodity %s", c.Commodity.Extract()); err != nil {
+		return err
+	}
+	for _, m := range c.Metadata {
+		if _, err := fmt.Fprintf(p, "\n  %s: \"%s\"", m.Key.Extract(), m.Value.Content.Extract()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *Printer) printAssertion(a directives.Assertion) error {
 	if _, err := fmt.Fprintf(p, "%s balance", a.Date.Extract()); err != nil {
 		return err
 	}
 	if len(a.Balances) == 1 {
-		_, err := fmt.Fprintf(p, " %s %s %s", a.Balances[0].Account.Extract(), a.Balances[0].Quantity.Extract(), a.Balances[0].Commodity.Extract())
-		return err
+		if _, err := io.WriteString(p, " "); err != nil {
+			return err
+		}
+		return p.printBalance(a.Balances[0])
 	}
 	if _, err := io.WriteString(p, "\n"); err != nil {
 		return err
 	}
 	for _, bal := range a.Balances {
-		if _, err := fmt.Fprintf(p, "%s %s %s\n", bal.Account.Extract(), bal.Quantity.Extract(), bal.Commodity.Extract()); err != nil {
+		if err := p.printBalance(bal); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(p, "\n"); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (p *Printer) printBalance(bal directives.Balance) error {
+	if _, err := fmt.Fprintf(p, "%s%s %s", bal.Account.Extract(), bal.Subtree.Extract(), bal.Quantity.Extract()); err != nil {
+		return err
+	}
+	if !bal.Tolerance.Empty() {
+		if _, err := fmt.Fprintf(p, " ~ %s", bal.Tolerance.Extract()); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(p, " %s", bal.Commodity.Extract()); err != nil {
+		return err
+	}
+	return p.printCost(bal.Cost)
+}
+
+func (p *Printer) printCost(c directives.Cost) error {
+	if c.Empty() {
+		return nil
+	}
+	left, right := " {", "}"
+	if c.Total {
+		left, right = " {{", "}}"
+	}
+	if _, err := fmt.Fprintf(p, "%s%s %s", left, c.Amount.Extract(), c.Commodity.Extract()); err != nil {
+		return err
+	}
+	if !c.Date.Empty() {
+		if _, err := fmt.Fprintf(p, ", %s", c.Date.Extract()); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(p, right)
+	return err
+}
+
+func (p *Printer) printRate(r directives.Rate) error {
+	if r.Empty() {
+		return nil
+	}
+	op := "@"
+	if r.Total {
+		op = "@@"
+	}
+	_, err := fmt.Fprintf(p, " %s %s %s", op, r.Amount.Extract(), r.Commodity.Extract())
+	return err
+}
+
 func (p *Printer) PrintFile(f directives.File) (int, error) {
 	start := p.count
 	for _, d := range f.Directives {
@@ -162,8 +355,13 @@ func (p *Printer) PrintFile(f directives.File) (int, error) {
 // Initialize initializes the padding of this printer.
 func (p *Printer) Initialize(directive []directives.Directive) {
 	for _, d := range directive {
-		t, ok := d.Directive.(directives.Transaction)
-		if !ok {
+		var t directives.Transaction
+		switch d := d.Directive.(type) {
+		case directives.Transaction:
+			t = d
+		case directives.Template:
+			t = d.Body
+		default:
 			continue
 		}
 		for _, b := range t.Bookings {
@@ -173,6 +371,9 @@ func (p *Printer) Initialize(directive []directives.Directive) {
 			if l := utf8.RuneCountInString(b.Debit.Extract()); l > p.padding {
 				p.padding = l
 			}
+			if l := utf8.RuneCountInString(b.Virtual.Extract()); l > p.padding {
+				p.padding = l
+			}
 		}
 	}
 }
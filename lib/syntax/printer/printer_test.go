@@ -27,6 +27,93 @@ func TestPrintFile(t *testing.T) {
 				"",
 			),
 		},
+		{
+			desc: "print transaction with comments",
+			text: lines(
+				`2022-03-03    "Hello, world"`,
+				`A:B:C       C:B:ASDF   400 CHF   ;   first  `,
+				`A:B:C       C:B:ASDF   100 CHF`,
+			),
+			want: lines(
+				`2022-03-03 "Hello, world"`,
+				"A:B:C C:B:ASDF        400 CHF ; first",
+				"A:B:C C:B:ASDF        100 CHF",
+				"",
+			),
+		},
+		{
+			desc: "print template and use",
+			text: lines(
+				`template dividend( security,amount )`,
+				`"Dividend $security"`,
+				`Income:Dividends   Assets:Broker   $amount USD`,
+				``,
+				`2022-03-03   use   dividend(AAPL,  "12.50"  )`,
+			),
+			want: lines(
+				`template dividend(security, amount)`,
+				`"Dividend $security"`,
+				"Income:Dividends Assets:Broker    $amount USD",
+				"",
+				`2022-03-03 use dividend(AAPL, "12.50")`,
+			),
+		},
+		{
+			desc: "print transaction with id",
+			text: lines(
+				`2022-03-03    "Hello, world"    id:6f1c-42   `,
+				`A:B:C       C:B:ASDF   400 CHF   `,
+			),
+			want: lines(
+				`2022-03-03 "Hello, world" id:6f1c-42`,
+				"A:B:C C:B:ASDF        400 CHF",
+				"",
+			),
+		},
+		{
+			desc: "print transaction with links",
+			text: lines(
+				`2022-03-03    "Hello, world"    id:6f1c-42  ^invoice-42    ^refund_1 `,
+				`A:B:C       C:B:ASDF   400 CHF   `,
+			),
+			want: lines(
+				`2022-03-03 "Hello, world" id:6f1c-42 ^invoice-42 ^refund_1`,
+				"A:B:C C:B:ASDF        400 CHF",
+				"",
+			),
+		},
+		{
+			desc: "print transaction with virtual booking",
+			text: lines(
+				`2022-03-03    "Hello, world"`,
+				`A:B:C       C:B:ASDF   400 CHF   `,
+				`(E:F)   -400 CHF   ;  memo`,
+			),
+			want: lines(
+				`2022-03-03 "Hello, world"`,
+				"A:B:C C:B:ASDF        400 CHF",
+				"(E:F)       -400 CHF ; memo",
+				"",
+			),
+		},
+		{
+			desc: "print lot assertion",
+			text: lines(
+				`2022-03-03   balance   A:B   10 AAPL   {  150 USD ,  2022-02-01 }`,
+			),
+			want: lines(
+				`2022-03-03 balance A:B 10 AAPL {150 USD, 2022-02-01}`,
+			),
+		},
+		{
+			desc: "print subtree assertion with tolerance",
+			text: lines(
+				`2022-03-03   balance   A:B:*   10.00   ~  0.01   USD`,
+			),
+			want: lines(
+				`2022-03-03 balance A:B:* 10.00 ~ 0.01 USD`,
+			),
+		},
 		{
 			desc: "print transactions",
 			text: lines(
@@ -55,6 +142,14 @@ func TestPrintFile(t *testing.T) {
 				`@accrue    monthly   2023-01-01    2023-12-01    Assets:Receivables   `,
 				`2023-03-03    "Hello, world"`,
 				`A:B:C       C:B:ASDF   400 CHF   `,
+				``,
+				`@accrue  quarterly:25   2023-01-01    2023-12-01    Assets:Receivables`,
+				`2023-03-03    "Hello, world"`,
+				`A:B:C       C:B:ASDF   400 CHF   `,
+				``,
+				`@accrue  monthly   2023-01-01    2023-12-01    Assets:Receivables   reverse `,
+				`2023-03-03    "Hello, world"`,
+				`A:B:C       C:B:ASDF   400 CHF   `,
 			),
 			want: lines(
 				`@performance(USD,EUR)`,
@@ -64,6 +159,14 @@ func TestPrintFile(t *testing.T) {
 				"@accrue monthly 2023-01-01 2023-12-01 Assets:Receivables",
 				`2023-03-03 "Hello, world"`,
 				"A:B:C C:B:ASDF        400 CHF",
+				``,
+				"@accrue quarterly:25 2023-01-01 2023-12-01 Assets:Receivables",
+				`2023-03-03 "Hello, world"`,
+				"A:B:C C:B:ASDF        400 CHF",
+				"",
+				"@accrue monthly 2023-01-01 2023-12-01 Assets:Receivables reverse",
+				`2023-03-03 "Hello, world"`,
+				"A:B:C C:B:ASDF        400 CHF",
 				"",
 			),
 		},
@@ -89,6 +192,62 @@ func TestPrintFile(t *testing.T) {
 				`include "foo3"`,
 			),
 		},
+		{
+			desc: "print performance patterns",
+			text: lines(
+				`@performance(  CHF , /^Crypto:/ )`,
+				`2022-03-03 "buy"`,
+				`A:B C:D 1 BTC`,
+			),
+			want: lines(
+				`@performance(CHF,/^Crypto:/)`,
+				`2022-03-03 "buy"`,
+				`A:B C:D          1 BTC`,
+				``,
+			),
+		},
+		{
+			desc: "print account type",
+			text: lines(
+				`accounttype    Memo   off-balance`,
+			),
+			want: lines(
+				`accounttype Memo off-balance`,
+			),
+		},
+		{
+			desc: "print alias",
+			text: lines(
+				`alias   commodity  TWTR    X  `,
+			),
+			want: lines(
+				`alias commodity TWTR X`,
+			),
+		},
+		{
+			desc: "print timeclock entries",
+			text: lines(
+				`i   2023-03-01   09:00   Project:X`,
+				`o  2023-03-01  17:30:15  `,
+			),
+			want: lines(
+				`i 2023-03-01 09:00 Project:X`,
+				`o 2023-03-01 17:30:15`,
+			),
+		},
+		{
+			desc: "print commodity declaration",
+			text: lines(
+				`commodity   VT`,
+				`    asset-class:"equity"`,
+				"\tregion:   \"world\"   ",
+			),
+			want: lines(
+				`commodity VT`,
+				`  asset-class: "equity"`,
+				`  region: "world"`,
+			),
+		},
 		{
 			desc: "print open",
 			text: lines(
@@ -111,6 +270,15 @@ func TestPrintFile(t *testing.T) {
 				`2022-03-03 open XYZ:ABC3`,
 			),
 		},
+		{
+			desc: "print open with valuation account",
+			text: lines(
+				`2022-03-03   open XYZ:ABC    valuation   Income:Trading`,
+			),
+			want: lines(
+				`2022-03-03 open XYZ:ABC valuation Income:Trading`,
+			),
+		},
 		{
 			desc: "print close",
 			text: lines(
@@ -133,6 +301,44 @@ func TestPrintFile(t *testing.T) {
 				`2022-03-03 close XYZ:ABC3`,
 			),
 		},
+		{
+			desc: "print transaction with costs",
+			text: lines(
+				`2022-03-03 "Buy"`,
+				`A:B   C:D   4 AAPL   {{ 600 USD  }}  ; lot`,
+				`A:B   C:D   2 AAPL {140.5  USD}`,
+			),
+			want: lines(
+				`2022-03-03 "Buy"`,
+				"A:B C:D          4 AAPL {{600 USD}} ; lot",
+				"A:B C:D          2 AAPL {140.5 USD}",
+				"",
+			),
+		},
+		{
+			desc: "print transaction with rates",
+			text: lines(
+				`2022-03-03 "Exchange"`,
+				`A:B   C:D   100 EUR   @   1.08 USD  ; fx`,
+				`A:B   C:D   4 AAPL {150 USD}  @@ 540   EUR`,
+			),
+			want: lines(
+				`2022-03-03 "Exchange"`,
+				"A:B C:D        100 EUR @ 1.08 USD ; fx",
+				"A:B C:D          4 AAPL {150 USD} @@ 540 EUR",
+				"",
+			),
+		},
+		{
+			desc: "print note",
+			text: lines(`2022-03-03   note  XYZ:ABC   "Joint account"`),
+			want: lines(`2022-03-03 note XYZ:ABC "Joint account"`),
+		},
+		{
+			desc: "print document",
+			text: lines(`2022-03-03   document  XYZ:ABC   "receipts/2022-03-03.pdf"`),
+			want: lines(`2022-03-03 document XYZ:ABC "receipts/2022-03-03.pdf"`),
+		},
 		{
 			desc: "print assertion",
 			text: lines(`2022-03-03  balance    XYZ:ABC -80.23 CHF`),
@@ -174,6 +380,15 @@ func TestPrintFile(t *testing.T) {
 				`2022-03-03 price USD 0.894 CHF`,
 			),
 		},
+		{
+			desc: "print value",
+			text: lines(
+				`2023-12-31  value   Assets:House  800000 CHF`,
+			),
+			want: lines(
+				`2023-12-31 value Assets:House 800000 CHF`,
+			),
+		},
 		{
 			desc: "print prices",
 			text: lines(
@@ -235,6 +450,19 @@ func TestFormat(t *testing.T) {
 				`2022-03-03 price USD 0.895 CHF`,
 			),
 		},
+		{
+			desc: "normalize indentation",
+			text: lines(
+				`2022-03-03 "foo"`,
+				"\tA  B 1 CHF",
+				" \t B\tA 1 CHF",
+			),
+			want: lines(
+				`2022-03-03 "foo"`,
+				`A B          1 CHF`,
+				`B A          1 CHF`,
+			),
+		},
 	}
 
 	for _, test := range tests {
@@ -128,6 +128,10 @@ func (s *Scanner) Scope(desc string) Scope {
 
 func (s *Scanner) Backtrack(offset int) {
 	s.offset = offset
+	if s.offset >= len(s.text) {
+		s.current, s.currentLen = EOF, 0
+		return
+	}
 	s.current, s.currentLen = utf8.DecodeRuneInString(s.text[s.offset:])
 }
 
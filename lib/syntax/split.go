@@ -0,0 +1,89 @@
+package syntax
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/printer"
+)
+
+// Part is a part of a split file.
+type Part struct {
+	Key        string
+	Directives []directives.Directive
+	Text       string
+}
+
+// Split distributes the directives of a file to parts with the given key,
+// ordered by their first directive. The text preceding a directive, such as
+// comments, is kept with the directive, and the text following the last
+// directive is kept with the last part. The directives of each part are
+// formatted, and the parts are verified to contain the same directives as the
+// original file.
+func Split(f directives.File, key func(directives.Directive) string) ([]Part, error) {
+	var (
+		parts []*Part
+		index = make(map[string]int)
+		owner = make([]int, len(f.Directives))
+	)
+	for i, d := range f.Directives {
+		k := key(d)
+		j, ok := index[k]
+		if !ok {
+			j = len(parts)
+			index[k] = j
+			parts = append(parts, &Part{Key: k})
+		}
+		parts[j].Directives = append(parts[j].Directives, d)
+		owner[i] = j
+	}
+	bufs := make([]bytes.Buffer, len(parts))
+	printers := make([]*printer.Printer, len(parts))
+	for i, part := range parts {
+		printers[i] = printer.New(&bufs[i])
+		printers[i].Initialize(part.Directives)
+	}
+	var pos int
+	for i, d := range f.Directives {
+		j := owner[i]
+		gap := f.Text[pos:d.Start]
+		if i == 0 || owner[i-1] != j {
+			// The directive does not follow its predecessor in the part.
+			gap = strings.TrimLeft(gap, " \t\r\n")
+			if bufs[j].Len() > 0 {
+				ensureNewline(&bufs[j])
+				bufs[j].WriteString("\n")
+			}
+		}
+		bufs[j].WriteString(gap)
+		if _, err := printers[j].PrintDirective(d); err != nil {
+			return nil, err
+		}
+		pos = d.End
+	}
+	var res []Part
+	for j, part := range parts {
+		if len(f.Directives) > 0 && owner[len(owner)-1] == j {
+			bufs[j].WriteString(f.Text[pos:])
+		}
+		ensureNewline(&bufs[j])
+		part.Text = bufs[j].String()
+		after, err := parse(part.Text, f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("parsing part %q: %w", part.Key, err)
+		}
+		if err := Compare(directives.File{Directives: part.Directives}, after); err != nil {
+			return nil, fmt.Errorf("part %q has different directives: %w", part.Key, err)
+		}
+		res = append(res, *part)
+	}
+	return res, nil
+}
+
+func ensureNewline(b *bytes.Buffer) {
+	if b.Len() > 0 && b.Bytes()[b.Len()-1] != '\n' {
+		b.WriteString("\n")
+	}
+}
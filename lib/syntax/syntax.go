@@ -2,13 +2,16 @@ package syntax
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sync/atomic"
 	"text/scanner"
 
 	"github.com/sboehler/knut/lib/common/cpr"
+	"github.com/sboehler/knut/lib/common/env"
 	"github.com/sboehler/knut/lib/syntax/directives"
 	"github.com/sboehler/knut/lib/syntax/parser"
 	"github.com/sboehler/knut/lib/syntax/printer"
@@ -21,12 +24,18 @@ type Account = directives.Account
 
 type Date = directives.Date
 
+type Time = directives.Time
+
 type Decimal = directives.Decimal
 
 type QuotedString = directives.QuotedString
 
 type Booking = directives.Booking
 
+type Cost = directives.Cost
+
+type Rate = directives.Rate
+
 type Performance = directives.Performance
 
 type Interval = directives.Interval
@@ -45,14 +54,31 @@ type Open = directives.Open
 
 type Close = directives.Close
 
+type Note = directives.Note
+
+type Document = directives.Document
+
 type Assertion = directives.Assertion
 
 type Balance = directives.Balance
 
 type Price = directives.Price
+type Value = directives.Value
 
 type Include = directives.Include
 
+type Template = directives.Template
+
+type Use = directives.Use
+
+type AccountType = directives.AccountType
+
+type CommodityDeclaration = directives.CommodityDeclaration
+
+type Alias = directives.Alias
+
+type Clock = directives.Clock
+
 type Range = directives.Range
 
 type Location = directives.Location
@@ -65,27 +91,85 @@ type Parser = parser.Parser
 
 type Scanner = scanner.Scanner
 
-func ParseFile(file string) (directives.File, error) {
-	text, err := os.ReadFile(file)
+// Escape escapes s, such that it can be written as the content of a quoted
+// string.
+func Escape(s string) string {
+	return directives.Escape(s)
+}
+
+// DateOf returns the date of a directive. Include, accounttype, commodity,
+// alias and template directives have no date.
+func DateOf(d Directive) (Date, bool) {
+	switch d := d.Directive.(type) {
+	case Transaction:
+		return d.Date, true
+	case Open:
+		return d.Date, true
+	case Close:
+		return d.Date, true
+	case Note:
+		return d.Date, true
+	case Document:
+		return d.Date, true
+	case Assertion:
+		return d.Date, true
+	case Price:
+		return d.Date, true
+	case Value:
+		return d.Date, true
+	case Use:
+		return d.Date, true
+	case Clock:
+		return d.Date, true
+	}
+	return Date{}, false
+}
+
+func ParseFile(ctx context.Context, file string) (directives.File, error) {
+	text, err := ReadText(env.Path(ctx, file))
 	if err != nil {
 		return directives.File{}, err
 	}
-	p := parser.New(string(text), file)
+	p := parser.New(text, file)
 	if err := p.Advance(); err != nil {
 		return directives.File{}, err
 	}
 	return p.ParseFile()
 }
 
+// Limits restricts the input accepted when parsing a journal recursively.
+// A zero value means that the respective property is not limited.
+type Limits struct {
+	// MaxIncludeDepth is the maximum nesting depth of include directives.
+	MaxIncludeDepth int
+	// MaxFileSize is the maximum size of a single file in bytes.
+	MaxFileSize int64
+	// MaxDirectives is the maximum number of directives in all files.
+	MaxDirectives int64
+}
+
+// DefaultLimits are the limits used by ParseFileRecursively. The include
+// depth is limited by default, which catches include cycles.
+var DefaultLimits = Limits{
+	MaxIncludeDepth: 100,
+}
+
 func ParseFileRecursively(file string) (<-chan directives.File, func(context.Context) error) {
+	return ParseFileRecursivelyWithLimits(file, DefaultLimits)
+}
+
+// ParseFileRecursivelyWithLimits parses the given file and all included files,
+// failing if the input exceeds the given limits.
+func ParseFileRecursivelyWithLimits(file string, limits Limits) (<-chan directives.File, func(context.Context) error) {
 	return cpr.Produce(func(ctx context.Context, ch chan<- directives.File) error {
 		wg, ctx := errgroup.WithContext(ctx)
+		rp := &recParser{
+			limits: limits,
+			wg:     wg,
+			resCh:  ch,
+		}
 		wg.Go(func() error {
-			res, err := parseRec(ctx, wg, ch, file)
-			if err != nil {
-				return err
-			}
-			return cpr.Push(ctx, ch, res)
+			return rp.parse(ctx, file, 0)
 		})
 		return wg.Wait()
 	})
@@ -96,28 +180,89 @@ type Result struct {
 	Err  error
 }
 
-func parseRec(ctx context.Context, wg *errgroup.Group, resCh chan<- directives.File, file string) (directives.File, error) {
-	text, err := os.ReadFile(file)
+type recParser struct {
+	limits     Limits
+	wg         *errgroup.Group
+	resCh      chan<- directives.File
+	directives atomic.Int64
+}
+
+func (rp *recParser) parse(ctx context.Context, file string, depth int) error {
+	res, err := rp.parseFile(ctx, file, depth)
+	if err != nil {
+		return err
+	}
+	return cpr.Push(ctx, rp.resCh, res)
+}
+
+func (rp *recParser) parseFile(ctx context.Context, file string, depth int) (directives.File, error) {
+	path, err := filepath.Abs(env.Path(ctx, file))
 	if err != nil {
 		return directives.File{}, err
 	}
-	p := parser.New(string(text), file)
-	if err := p.Advance(); err != nil {
+	info, err := os.Stat(path)
+	if err != nil {
 		return directives.File{}, err
 	}
-	p.Callback = func(d directives.Directive) {
-		if inc, ok := d.Directive.(directives.Include); ok {
-			file := path.Join(filepath.Dir(file), inc.IncludePath.Content.Extract())
-			wg.Go(func() error {
-				res, err := parseRec(ctx, wg, resCh, file)
-				if err != nil {
-					return err
-				}
-				return cpr.Push(ctx, resCh, res)
-			})
+	if rp.limits.MaxFileSize > 0 && info.Size() > rp.limits.MaxFileSize {
+		return directives.File{}, fmt.Errorf("%s: file size of %d bytes exceeds the maximum of %d bytes", file, info.Size(), rp.limits.MaxFileSize)
+	}
+	l := leaseFrom(ctx)
+	key := cacheKey{path: path, name: file}
+	f, ok := l.get(key, info)
+	if ok {
+		for _, d := range f.Directives {
+			rp.include(ctx, file, d, depth)
+		}
+	} else {
+		text, unmap, err := mapText(path)
+		if err != nil {
+			return directives.File{}, err
+		}
+		p := parser.New(text, file)
+		if err := p.Advance(); err != nil {
+			l.keep(unmap)
+			return directives.File{}, err
+		}
+		p.Callback = func(d directives.Directive) {
+			rp.include(ctx, file, d, depth)
 		}
+		if f, err = p.ParseFile(); err != nil {
+			l.keep(unmap)
+			return f, err
+		}
+		l.put(key, info, f, unmap)
 	}
-	return p.ParseFile()
+	n := rp.directives.Add(int64(len(f.Directives)))
+	if rp.limits.MaxDirectives > 0 && n > rp.limits.MaxDirectives {
+		return f, fmt.Errorf("%s: journal exceeds the maximum of %d directives", file, rp.limits.MaxDirectives)
+	}
+	return f, nil
+}
+
+func (rp *recParser) include(ctx context.Context, file string, d directives.Directive, depth int) {
+	inc, ok := d.Directive.(directives.Include)
+	if !ok {
+		return
+	}
+	if rp.limits.MaxIncludeDepth > 0 && depth >= rp.limits.MaxIncludeDepth {
+		rp.wg.Go(func() error {
+			return directives.Error{
+				Message: fmt.Sprintf("include depth exceeds the maximum of %d", rp.limits.MaxIncludeDepth),
+				Range:   inc.Range,
+			}
+		})
+		return
+	}
+	target, err := inc.IncludePath.Parse()
+	if err != nil {
+		rp.wg.Go(func() error { return err })
+		return
+	}
+	file = path.Join(filepath.Dir(file), target)
+	rp.wg.Go(func() error {
+		return rp.parse(ctx, file, depth+1)
+	})
 }
 
 func FormatFile(w io.Writer, f directives.File) error {
@@ -0,0 +1,600 @@
+package syntax
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/cpr"
+	"github.com/sboehler/knut/lib/common/env"
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+func TestParseFileRecursivelyWithLimits(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.knut":  "include \"a.knut\"\n",
+		"a.knut":     "include \"b.knut\"\n\n2021-01-01 open Assets:A\n",
+		"b.knut":     "2021-01-01 open Assets:B\n\n2021-01-01 open Assets:C\n",
+		"cycle.knut": "include \"cycle.knut\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		desc    string
+		file    string
+		limits  Limits
+		wantErr string
+	}{
+		{
+			desc: "no limits",
+			file: "main.knut",
+		},
+		{
+			desc:   "within limits",
+			file:   "main.knut",
+			limits: Limits{MaxIncludeDepth: 2, MaxFileSize: 100, MaxDirectives: 5},
+		},
+		{
+			desc:    "include depth",
+			file:    "main.knut",
+			limits:  Limits{MaxIncludeDepth: 1},
+			wantErr: "include depth exceeds the maximum of 1",
+		},
+		{
+			desc:    "include cycle",
+			file:    "cycle.knut",
+			limits:  DefaultLimits,
+			wantErr: "include depth exceeds the maximum of 100",
+		},
+		{
+			desc:    "file size",
+			file:    "main.knut",
+			limits:  Limits{MaxFileSize: 30},
+			wantErr: "exceeds the maximum of 30 bytes",
+		},
+		{
+			desc:    "directives",
+			file:    "main.knut",
+			limits:  Limits{MaxDirectives: 4},
+			wantErr: "journal exceeds the maximum of 4 directives",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ch, worker := ParseFileRecursivelyWithLimits(filepath.Join(dir, test.file), test.limits)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				cpr.ForEach(context.Background(), ch, func(File) error { return nil })
+			}()
+
+			err := worker(context.Background())
+			<-done
+
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("worker() returned unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("worker() = %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFileRecursivelyCached(t *testing.T) {
+	dir := t.TempDir()
+	main, inc := filepath.Join(dir, "main.knut"), filepath.Join(dir, "a.knut")
+	if err := os.WriteFile(main, []byte("include \"a.knut\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(inc, []byte("2021-01-01 open Assets:A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache()
+	count := func() int {
+		t.Helper()
+		ctx, release := c.Lease(context.Background())
+		defer release()
+		ch, worker := ParseFileRecursively(main)
+		var n int
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cpr.ForEach(context.Background(), ch, func(f File) error {
+				n += len(f.Directives)
+				return nil
+			})
+		}()
+		if err := worker(ctx); err != nil {
+			t.Fatalf("worker() returned unexpected error: %v", err)
+		}
+		<-done
+		return n
+	}
+
+	if got := count(); got != 2 {
+		t.Fatalf("got %d directives, want 2", got)
+	}
+	if got := count(); got != 2 {
+		t.Fatalf("got %d directives from cache, want 2", got)
+	}
+	if err := os.WriteFile(inc, []byte("2021-01-01 open Assets:A\n2021-01-01 open Assets:B\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 3 {
+		t.Fatalf("got %d directives after modification, want 3", got)
+	}
+}
+
+func TestCacheLease(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.knut")
+	write := func(text string) {
+		t.Helper()
+		// Files are replaced, like knut does, instead of being modified in
+		// place.
+		tmp := file + ".tmp"
+		if err := os.WriteFile(tmp, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := NewCache()
+	key := cacheKey{path: file, name: file}
+	parse := func(ctx context.Context) File {
+		t.Helper()
+		ch, worker := ParseFileRecursively(file)
+		var f File
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cpr.ForEach(context.Background(), ch, func(g File) error {
+				f = g
+				return nil
+			})
+		}()
+		if err := worker(ctx); err != nil {
+			t.Fatalf("worker() returned unexpected error: %v", err)
+		}
+		<-done
+		return f
+	}
+	write("2021-01-01 open Assets:A\n")
+
+	ctx1, release1 := c.Lease(context.Background())
+	f1 := parse(ctx1)
+	e1 := c.entries[key]
+	if e1 == nil || e1.unmap == nil {
+		t.Fatalf("file parsed with a lease is not cached and mapped")
+	}
+	if f := parse(context.Background()); f.Text != f1.Text || c.entries[key] != e1 {
+		t.Fatalf("got %q without a lease, want %q from a parse which does not use the cache", f.Text, f1.Text)
+	}
+	write("2021-01-01 open Assets:BB\n")
+	ctx2, release2 := c.Lease(context.Background())
+	defer release2()
+	parse(ctx2)
+
+	if !e1.evicted || e1.unmap == nil {
+		t.Fatalf("evicted file is unmapped while it is leased")
+	}
+	if got, want := f1.Directives[0].Extract(), "2021-01-01 open Assets:A"; got != want {
+		t.Fatalf("got %q from the evicted file, want %q", got, want)
+	}
+	release1()
+	if e1.unmap != nil {
+		t.Fatalf("evicted file is still mapped after the lease has been released")
+	}
+	if e2 := c.entries[key]; e2.refs != 1 || e2.unmap == nil {
+		t.Fatalf("got refs = %d, mapped = %t, want 1, true", e2.refs, e2.unmap != nil)
+	}
+}
+
+func TestParseFileRecursivelyInDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.knut"), []byte("include \"a.knut\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.knut"), []byte("2021-01-01 open Assets:A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := env.With(context.Background(), env.Env{Dir: dir})
+	ch, worker := ParseFileRecursively("main.knut")
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cpr.ForEach(context.Background(), ch, func(f File) error {
+			got = append(got, f.Path)
+			return nil
+		})
+	}()
+
+	err := worker(ctx)
+	<-done
+
+	if err != nil {
+		t.Fatalf("worker() returned unexpected error: %v", err)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"a.knut", "main.knut"}, got); diff != "" {
+		t.Errorf("ParseFileRecursively() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func TestScanNames(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.knut": strings.Join([]string{
+			`include "a.knut"`,
+			`include "missing.knut"`,
+			``,
+			`2021-01-01 open Assets:Cash`,
+			`# 2021-01-01 open Assets:Commented`,
+			``,
+			`2021-01-02 "Groceries: Assets:Fake 10 FAKE" ; Assets:Comment`,
+			`Assets:Cash Expenses:Groceries -10.50 CHF`,
+			`(Envelopes:Food) 10 CHF`,
+			`Assets:Cash Assets:Broker 2 AAPL {150 USD, 2021-01-02}`,
+			``,
+			`2021-01-03 price AAPL 151 USD`,
+			`2021-01-04 balance Assets:Broker:* 2 ~ 0.1 AAPL`,
+			``,
+			`; knut: ignore-begin`,
+			`2021-01-01 open Assets:Ignored`,
+			`; knut: ignore-end`,
+		}, "\n"),
+		"a.knut": "include \"main.knut\"\n2021-01-01 open Liabilities:Card\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := Names{
+		Accounts:    []string{"Assets:Broker", "Assets:Cash", "Envelopes:Food", "Expenses:Groceries", "Liabilities:Card"},
+		Commodities: []string{"AAPL", "CHF", "USD"},
+	}
+
+	got, err := ScanNames(filepath.Join(dir, "main.knut"))
+
+	if err != nil {
+		t.Fatalf("ScanNames() returned unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ScanNames(): unexpected diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	text := strings.Join([]string{
+		`# a comment`,
+		`include "a.knut"`,
+		``,
+		`2021-01-01 open Assets:Cash`,
+		``,
+		`@performance(USD)`,
+		`2021-01-02 "Groceries"`,
+		`Assets:Cash Expenses:Groceries 10.50 CHF ; paid cash`,
+		`(Envelopes:Food) -10.50 CHF`,
+		``,
+		`2021-01-03 price AAPL 151 USD`,
+		`2021-01-04 balance Assets:Broker:* 2 ~ 0.1 AAPL {150 USD}`,
+		``,
+		`; knut: ignore-begin`,
+		`2021-01-01 open Assets:Ignored`,
+		`; knut: ignore-end`,
+	}, "\n")
+	want := []string{
+		"comment # a comment",
+		"keyword include",
+		`string "a.knut"`,
+		"date 2021-01-01",
+		"keyword open",
+		"account Assets:Cash",
+		"keyword @performance",
+		"commodity USD",
+		"date 2021-01-02",
+		`string "Groceries"`,
+		"account Assets:Cash",
+		"account Expenses:Groceries",
+		"decimal 10.50",
+		"commodity CHF",
+		"comment ; paid cash",
+		"account Envelopes:Food",
+		"decimal -10.50",
+		"commodity CHF",
+		"date 2021-01-03",
+		"keyword price",
+		"commodity AAPL",
+		"decimal 151",
+		"commodity USD",
+		"date 2021-01-04",
+		"keyword balance",
+		"account Assets:Broker",
+		"account :*",
+		"decimal 2",
+		"decimal 0.1",
+		"commodity AAPL",
+		"decimal 150",
+		"commodity USD",
+		"comment ; knut: ignore-begin",
+		"comment 2021-01-01 open Assets:Ignored",
+		"comment ; knut: ignore-end",
+	}
+
+	tokens, err := Tokenize(text, "")
+
+	if err != nil {
+		t.Fatalf("Tokenize() returned unexpected error: %v", err)
+	}
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Kind.String()+" "+tok.Extract())
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Tokenize(): unexpected diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	text := strings.Join([]string{
+		`include   "a.knut"`,
+		``,
+		`2021-01-01   open   Assets:Cash`,
+		``,
+		`@performance( USD )`,
+		`@accrue monthly 2021-01-01 2021-12-31 Assets:Accrual`,
+		`2021-01-02 "Groceries"`,
+		`Assets:Cash   Expenses:Groceries   10.50 CHF   ;   paid cash`,
+		`(Envelopes:Food)   -10.50   CHF`,
+		``,
+		`2021-01-04 balance Assets:Broker:*   2  ~  0.1 AAPL {150  USD,  2021-01-01}`,
+	}, "\n")
+
+	if err := Verify(text, ""); err != nil {
+		t.Errorf("Verify() returned unexpected error: %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		desc          string
+		before, after string
+		wantErr       bool
+	}{
+		{
+			desc:   "whitespace",
+			before: "2021-01-01 open Assets:A\n",
+			after:  "2021-01-01    open    Assets:A\n",
+		},
+		{
+			desc:    "changed account",
+			before:  "2021-01-01 open Assets:A\n",
+			after:   "2021-01-01 open Assets:B\n",
+			wantErr: true,
+		},
+		{
+			desc:    "changed quantity",
+			before:  "2021-01-01 \"\"\nAssets:A Assets:B 1.0 CHF\n",
+			after:   "2021-01-01 \"\"\nAssets:A Assets:B 1.00 CHF\n",
+			wantErr: true,
+		},
+		{
+			desc:    "missing directive",
+			before:  "2021-01-01 open Assets:A\n\n2021-01-01 open Assets:B\n",
+			after:   "2021-01-01 open Assets:A\n",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			before, err := parse(test.before, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := parse(test.after, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Compare(before, after)
+
+			if test.wantErr != (err != nil) {
+				t.Errorf("Compare() returned error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadText(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		desc string
+		text string
+	}{
+		{desc: "empty", text: ""},
+		{desc: "journal", text: largeJournal(3)},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			file := filepath.Join(dir, test.desc+".knut")
+			if err := os.WriteFile(file, []byte(test.text), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadText(file)
+
+			if err != nil {
+				t.Fatalf("ReadText() returned unexpected error %v", err)
+			}
+			if got != test.text {
+				t.Errorf("ReadText() = %q, want %q", got, test.text)
+			}
+
+			got, unmap, err := mapText(file)
+
+			if err != nil {
+				t.Fatalf("mapText() returned unexpected error %v", err)
+			}
+			if got != test.text {
+				t.Errorf("mapText() = %q, want %q", got, test.text)
+			}
+			if unmap != nil {
+				if err := unmap(); err != nil {
+					t.Errorf("unmap() returned unexpected error %v", err)
+				}
+			}
+		})
+	}
+}
+
+// largeJournal generates a journal with n transactions.
+func largeJournal(n int) string {
+	var b strings.Builder
+	b.WriteString("2020-01-01 open Assets:Bank\n\n2020-01-01 open Expenses:Groceries\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "2020-%02d-%02d \"Groceries %d\"\nAssets:Bank Expenses:Groceries %d.%02d CHF\n\n", i%12+1, i%28+1, i, i%500, i%100)
+	}
+	return b.String()
+}
+
+// BenchmarkParseFile compares reading a large journal with os.ReadFile, which
+// copies the contents from a buffer into the text, with ReadText, which reads
+// them into the text directly, and with mapText, which does not copy them at
+// all.
+func BenchmarkParseFile(b *testing.B) {
+	file := filepath.Join(b.TempDir(), "large.knut")
+	if err := os.WriteFile(file, []byte(largeJournal(100000)), 0644); err != nil {
+		b.Fatal(err)
+	}
+	read := map[string]func(string) (string, func() error, error){
+		"ReadFile": func(file string) (string, func() error, error) {
+			text, err := os.ReadFile(file)
+			return string(text), nil, err
+		},
+		"ReadText": func(file string) (string, func() error, error) {
+			text, err := ReadText(file)
+			return text, nil, err
+		},
+		"mapText": mapText,
+	}
+	for _, name := range []string{"ReadFile", "ReadText", "mapText"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				text, unmap, err := read[name](file)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := parse(text, file); err != nil {
+					b.Fatal(err)
+				}
+				if unmap != nil {
+					unmap()
+				}
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	text := strings.Join([]string{
+		"; header",
+		"",
+		"2020-01-01 open Assets:A",
+		"",
+		"; comment",
+		"2021-01-01 \"foo\"",
+		"Assets:A    Assets:B 1 CHF",
+		"",
+		"2020-12-31 balance Assets:A 0 CHF",
+		"; trailing",
+		"",
+	}, "\n")
+	f, err := parse(text, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Split(f, func(d directives.Directive) string {
+		return d.Extract()[:4]
+	})
+
+	if err != nil {
+		t.Fatalf("Split() returned unexpected error %v", err)
+	}
+	want := []Part{
+		{
+			Key:        "2020",
+			Directives: []directives.Directive{f.Directives[0], f.Directives[2]},
+			Text:       "; header\n\n2020-01-01 open Assets:A\n\n2020-12-31 balance Assets:A 0 CHF\n; trailing\n",
+		},
+		{
+			Key:        "2021",
+			Directives: []directives.Directive{f.Directives[1]},
+			Text:       "; comment\n2021-01-01 \"foo\"\nAssets:A Assets:B          1 CHF\n",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Split() returned unexpected diff (-want/+got)\n%s\n", diff)
+	}
+}
+
+func TestToggle(t *testing.T) {
+	const text = "2021-01-01 open Assets:A\n\n2021-01-02 \"Coffee\"\nAssets:A Expenses:B 4 CHF\n"
+	const toggled = "2021-01-01 open Assets:A\n\n; knut: ignore-begin\n2021-01-02 \"Coffee\"\nAssets:A Expenses:B 4 CHF\n; knut: ignore-end\n"
+	tests := []struct {
+		desc         string
+		text         string
+		line         int
+		want         string
+		wantExcluded bool
+		wantErr      bool
+	}{
+		{desc: "exclude", text: text, line: 4, want: toggled, wantExcluded: true},
+		{desc: "include", text: toggled, line: 5, want: text},
+		{desc: "include at pragma", text: toggled, line: 3, want: text},
+		{desc: "exclude at end of file", text: strings.TrimSuffix(text, "\n"), line: 3, want: toggled, wantExcluded: true},
+		{desc: "blank line", text: text, line: 2, wantErr: true},
+		{desc: "out of range", text: text, line: 10, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := parser.New(test.text, "test.knut")
+			if err := p.Advance(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := p.ParseFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, excluded, err := Toggle(f, test.line)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Toggle() returned error %v, want error: %t", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Toggle() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+			if excluded != test.wantExcluded {
+				t.Errorf("Toggle() returned excluded = %t, want %t", excluded, test.wantExcluded)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Instantiate creates the transaction of a template on the given date, which
+// is formatted as YYYY-MM-DD. Each `$parameter` in the body of the template
+// is replaced by its argument. In the description, words starting with `$`
+// which are not parameters are kept as they are.
+func Instantiate(t Template, date string, args map[string]string) (Transaction, error) {
+	for _, p := range t.Parameters {
+		if _, ok := args[p.Extract()]; !ok {
+			return Transaction{}, Error{
+				Message: fmt.Sprintf("missing argument for parameter %s", p.Extract()),
+				Range:   p,
+			}
+		}
+	}
+	var (
+		body   = t.Body.Range
+		params = make(map[string]bool)
+		b      strings.Builder
+	)
+	for _, p := range t.Parameters {
+		params[p.Extract()] = true
+	}
+	b.WriteString(date)
+	b.WriteString(" ")
+	for pos := body.Start; pos < body.End; {
+		i := strings.IndexByte(body.Text[pos:body.End], '$')
+		if i < 0 {
+			b.WriteString(body.Text[pos:body.End])
+			break
+		}
+		b.WriteString(body.Text[pos : pos+i])
+		start := pos + i
+		end := start + 1
+		for end < body.End {
+			r, n := utf8.DecodeRuneInString(body.Text[end:body.End])
+			if !unicode.IsLetter(r) {
+				break
+			}
+			end += n
+		}
+		name := body.Text[start+1 : end]
+		switch {
+		case params[name]:
+			b.WriteString(args[name])
+		case start >= t.Body.Description.Start && start < t.Body.Description.End:
+			b.WriteString(body.Text[start:end])
+		default:
+			return Transaction{}, Error{
+				Message: fmt.Sprintf("unknown parameter $%s", name),
+				Range:   Range{Start: start, End: end, Path: body.Path, Text: body.Text},
+			}
+		}
+		pos = end
+	}
+	p := parser.New(b.String(), fmt.Sprintf("%s: template %s", t.Path, t.Name.Extract()))
+	if err := p.Advance(); err != nil {
+		return Transaction{}, err
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		return Transaction{}, err
+	}
+	if len(f.Directives) != 1 {
+		return Transaction{}, Error{Message: "template does not define a single transaction", Range: t.Range}
+	}
+	trx, ok := f.Directives[0].Directive.(directives.Transaction)
+	if !ok {
+		return Transaction{}, Error{Message: "template does not define a transaction", Range: t.Range}
+	}
+	return trx, nil
+}
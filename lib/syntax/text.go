@@ -0,0 +1,62 @@
+package syntax
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadText reads the text of a journal file into a single string, which is
+// shared by the ranges of all directives parsed from it. The file is read
+// directly into the string, without the intermediate buffer of os.ReadFile.
+func ReadText(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return readText(f, info.Size())
+}
+
+// mapText is like ReadText, but maps regular files into memory where
+// supported, instead of reading them. The text is the mapped memory itself,
+// it is not copied. It is valid until unmap is called, and afterwards, any
+// use of the text or of the ranges of directives parsed from it faults. The
+// mapped memory changes if the file is modified in place, and faults if it
+// is truncated; knut itself replaces files atomically or appends to them.
+// If the file is read instead, unmap is nil.
+func mapText(file string) (text string, unmap func() error, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", nil, err
+	}
+	if info.Mode().IsRegular() && info.Size() > 0 && int64(int(info.Size())) == info.Size() {
+		if text, unmap, ok := mmap(f, int(info.Size())); ok {
+			return text, unmap, nil
+		}
+	}
+	text, err = readText(f, info.Size())
+	return text, nil, err
+}
+
+// readText reads the text of f, allocating the result only once if size is
+// correct.
+func readText(f io.Reader, size int64) (string, error) {
+	var b strings.Builder
+	if size > 0 && int64(int(size)) == size {
+		b.Grow(int(size))
+	}
+	if _, err := io.Copy(&b, f); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
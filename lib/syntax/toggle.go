@@ -0,0 +1,58 @@
+package syntax
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Toggle excludes the directive at the given line of a file from processing,
+// keeping it in the text, by enclosing it in `knut: ignore-begin` and
+// `knut: ignore-end` pragmas. If the line lies in such a section, the pragmas
+// are removed instead, such that the section is processed again. Lines start
+// at 1. Toggle returns the new text of the file and whether the line is
+// excluded from processing.
+func Toggle(f File, line int) (string, bool, error) {
+	lines := strings.SplitAfter(f.Text, "\n")
+	if line < 1 || line > len(lines) {
+		return "", false, fmt.Errorf("%s:%d: line out of range", f.Path, line)
+	}
+	// Ignored sections are skipped by the parser, so they are found in the
+	// text. Like the parser, the first `knut: ignore-end` ends a section.
+	begin := -1
+	for i, l := range lines {
+		switch parser.Pragma(strings.TrimSpace(l)) {
+		case "ignore-begin":
+			if begin < 0 {
+				begin = i
+			}
+		case "ignore-end":
+			if begin >= 0 && begin < line && line <= i+1 {
+				lines = slices.Delete(lines, i, i+1)
+				lines = slices.Delete(lines, begin, begin+1)
+				return strings.Join(lines, ""), false, nil
+			}
+			begin = -1
+		}
+	}
+	for _, d := range f.Directives {
+		first := strings.Count(f.Text[:d.Start], "\n")
+		last := strings.Count(f.Text[:d.End-1], "\n")
+		if line-1 < first || line-1 > last {
+			continue
+		}
+		newline := "\n"
+		if strings.HasSuffix(lines[first], "\r\n") {
+			newline = "\r\n"
+		}
+		if !strings.HasSuffix(lines[last], "\n") {
+			lines[last] += newline
+		}
+		lines = slices.Insert(lines, last+1, "; knut: ignore-end"+newline)
+		lines = slices.Insert(lines, first, "; knut: ignore-begin"+newline)
+		return strings.Join(lines, ""), true, nil
+	}
+	return "", false, fmt.Errorf("%s:%d: no directive or ignored section", f.Path, line)
+}
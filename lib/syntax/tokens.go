@@ -0,0 +1,311 @@
+package syntax
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// TokenKind classifies a token for syntax highlighting.
+type TokenKind int
+
+const (
+	// TokenComment is a comment, including ignored parts of a file.
+	TokenComment TokenKind = iota
+	// TokenKeyword is a keyword, such as `open` or `@accrue`.
+	TokenKeyword
+	// TokenDate is a date.
+	TokenDate
+	// TokenAccount is an account.
+	TokenAccount
+	// TokenDecimal is a quantity, price, cost or other number.
+	TokenDecimal
+	// TokenCommodity is a commodity.
+	TokenCommodity
+	// TokenString is a quoted string, including the quotes.
+	TokenString
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenComment:
+		return "comment"
+	case TokenKeyword:
+		return "keyword"
+	case TokenDate:
+		return "date"
+	case TokenAccount:
+		return "account"
+	case TokenDecimal:
+		return "decimal"
+	case TokenCommodity:
+		return "commodity"
+	case TokenString:
+		return "string"
+	}
+	return ""
+}
+
+// Token is a classified part of a journal.
+type Token struct {
+	Kind TokenKind
+	Range
+}
+
+// Tokenize splits the text of a journal file into classified tokens, ordered
+// by position. Whitespace, punctuation and text which can't be parsed are not
+// covered by any token. Tokens are derived from the parsed directives, so
+// highlighting is consistent with the parser. Parsing recovers from errors,
+// which are returned along with the tokens of the remaining text.
+func Tokenize(text, path string) ([]Token, error) {
+	p := parser.New(text, path)
+	p.Recover = true
+	if err := p.Advance(); err != nil {
+		return nil, err
+	}
+	f, err := p.ParseFile()
+	var t tokenizer
+	for _, d := range f.Directives {
+		t.directive(d)
+	}
+	slices.SortFunc(t.tokens, func(t1, t2 Token) int {
+		return t1.Start - t2.Start
+	})
+	return t.fillGaps(text, path), err
+}
+
+type tokenizer struct {
+	tokens []Token
+
+	// directives are the ranges of the directives, in order.
+	directives []Range
+}
+
+func (t *tokenizer) add(k TokenKind, r Range) {
+	if !r.Empty() {
+		t.tokens = append(t.tokens, Token{Kind: k, Range: r})
+	}
+}
+
+func (t *tokenizer) directive(d directives.Directive) {
+	t.directives = append(t.directives, d.Range)
+	switch d := d.Directive.(type) {
+	case directives.Transaction:
+		t.addons(d.Addons)
+		t.add(TokenDate, d.Date.Range)
+		t.transaction(d)
+	case directives.Template:
+		t.add(TokenKeyword, d.Name)
+		for _, r := range d.Parameters {
+			t.add(TokenKeyword, r)
+		}
+		t.transaction(d.Body)
+	case directives.Use:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenKeyword, d.Name)
+		for _, r := range d.Arguments {
+			if strings.HasPrefix(r.Extract(), `"`) {
+				t.add(TokenString, r)
+			}
+		}
+	case directives.Open:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenAccount, d.Account.Range)
+		t.add(TokenAccount, d.Valuation.Range)
+	case directives.Close:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenAccount, d.Account.Range)
+	case directives.Note:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenAccount, d.Account.Range)
+		t.add(TokenString, d.Text.Range)
+	case directives.Document:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenAccount, d.Account.Range)
+		t.add(TokenString, d.DocumentPath.Range)
+	case directives.Assertion:
+		t.add(TokenDate, d.Date.Range)
+		for _, b := range d.Balances {
+			t.add(TokenAccount, b.Account.Range)
+			t.add(TokenAccount, b.Subtree)
+			t.add(TokenDecimal, b.Quantity.Range)
+			t.add(TokenDecimal, b.Tolerance.Range)
+			t.add(TokenCommodity, b.Commodity.Range)
+			t.cost(b.Cost)
+		}
+	case directives.Price:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenCommodity, d.Commodity.Range)
+		t.add(TokenDecimal, d.Price.Range)
+		t.add(TokenCommodity, d.Target.Range)
+	case directives.Value:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenAccount, d.Account.Range)
+		t.add(TokenDecimal, d.Quantity.Range)
+		t.add(TokenCommodity, d.Commodity.Range)
+	case directives.Include:
+		t.add(TokenString, d.IncludePath.Range)
+	case directives.AccountType:
+		t.add(TokenAccount, d.Name)
+		t.add(TokenKeyword, d.Placement)
+	case directives.Alias:
+		t.add(TokenCommodity, d.Commodity.Range)
+		t.add(TokenCommodity, d.Target.Range)
+	case directives.Clock:
+		t.add(TokenDate, d.Date.Range)
+		t.add(TokenDate, d.Time.Range)
+		t.add(TokenAccount, d.Account.Range)
+	case directives.CommodityDeclaration:
+		t.add(TokenCommodity, d.Commodity.Range)
+		for _, m := range d.Metadata {
+			t.add(TokenKeyword, m.Key)
+			t.add(TokenString, m.Value.Range)
+		}
+	}
+}
+
+func (t *tokenizer) transaction(d directives.Transaction) {
+	t.add(TokenString, d.Description.Range)
+	for _, b := range d.Bookings {
+		t.add(TokenAccount, b.Credit.Range)
+		t.add(TokenAccount, b.Debit.Range)
+		t.add(TokenAccount, b.Virtual.Range)
+		t.add(TokenDecimal, b.Quantity.Range)
+		t.add(TokenCommodity, b.Commodity.Range)
+		t.cost(b.Cost)
+		t.add(TokenDecimal, b.Rate.Amount.Range)
+		t.add(TokenCommodity, b.Rate.Commodity.Range)
+	}
+}
+
+func (t *tokenizer) addons(a directives.Addons) {
+	for _, c := range a.Performance.Targets {
+		t.add(TokenCommodity, c.Range)
+	}
+	for _, r := range a.Performance.Patterns {
+		t.add(TokenString, r)
+	}
+	t.add(TokenKeyword, a.Accrual.Interval.Range)
+	t.add(TokenDecimal, a.Accrual.Anchor.Range)
+	t.add(TokenDate, a.Accrual.Start.Range)
+	t.add(TokenDate, a.Accrual.End.Range)
+	t.add(TokenAccount, a.Accrual.Account.Range)
+	t.add(TokenKeyword, a.Accrual.Reverse)
+}
+
+func (t *tokenizer) cost(c directives.Cost) {
+	t.add(TokenDecimal, c.Amount.Range)
+	t.add(TokenCommodity, c.Commodity.Range)
+	t.add(TokenDate, c.Date.Range)
+}
+
+// fillGaps merges the tokens of the directives with the tokens of the text
+// between them. Within directives, words are keywords and text following a
+// semicolon is a comment. Outside of directives, lines are comments if they
+// start with a comment character or are part of an ignored section.
+func (t *tokenizer) fillGaps(text, path string) []Token {
+	var (
+		res  []Token
+		pos  int
+		ign  ignoreState
+		next int
+	)
+	emitGap := func(start, end int) {
+		for start < end {
+			i := slices.IndexFunc(t.directives[next:], func(r Range) bool { return r.End > start })
+			if i < 0 {
+				res = append(res, ign.lines(text, path, start, end)...)
+				return
+			}
+			d := t.directives[next+i]
+			if d.Start > start {
+				res = append(res, ign.lines(text, path, start, min(d.Start, end))...)
+				start = min(d.Start, end)
+				continue
+			}
+			res = append(res, words(text, path, start, min(d.End, end))...)
+			if d.End > end {
+				return
+			}
+			start = d.End
+			next += i + 1
+		}
+	}
+	for _, tok := range t.tokens {
+		if tok.Start < pos {
+			continue
+		}
+		emitGap(pos, tok.Start)
+		res = append(res, tok)
+		pos = tok.End
+	}
+	emitGap(pos, len(text))
+	return res
+}
+
+// words classifies the words within a directive.
+func words(text, path string, start, end int) []Token {
+	var res []Token
+	for i := start; i < end; {
+		switch c := rune(text[i]); {
+		case c == ';':
+			j := lineEnd(text, i, end)
+			res = append(res, Token{Kind: TokenComment, Range: Range{Start: i, End: j, Path: path, Text: text}})
+			i = j
+		case c == '@' || unicode.IsLetter(c):
+			j := i + 1
+			for j < end && unicode.IsLetter(rune(text[j])) {
+				j++
+			}
+			res = append(res, Token{Kind: TokenKeyword, Range: Range{Start: i, End: j, Path: path, Text: text}})
+			i = j
+		default:
+			i++
+		}
+	}
+	return res
+}
+
+// ignoreState tracks ignored sections outside of directives.
+type ignoreState struct {
+	ignoring, ignoreFile bool
+}
+
+// lines classifies the lines between directives.
+func (ign *ignoreState) lines(text, path string, start, end int) []Token {
+	var res []Token
+	for i := start; i < end; {
+		j := lineEnd(text, i, end)
+		line := strings.TrimSpace(text[i:j])
+		if line != "" {
+			s := i + strings.Index(text[i:j], line)
+			isComment := strings.ContainsRune("*#;", rune(line[0])) || strings.HasPrefix(line, "//")
+			if isComment || ign.ignoring || ign.ignoreFile {
+				res = append(res, Token{Kind: TokenComment, Range: Range{Start: s, End: s + len(line), Path: path, Text: text}})
+			}
+			if isComment {
+				switch parser.Pragma(line) {
+				case "ignore-file":
+					ign.ignoreFile = true
+				case "ignore-begin":
+					ign.ignoring = true
+				case "ignore-end":
+					ign.ignoring = false
+				}
+			}
+		}
+		i = j + 1
+	}
+	return res
+}
+
+// lineEnd returns the position of the end of the line containing i, or end.
+func lineEnd(text string, i, end int) int {
+	if j := strings.IndexByte(text[i:end], '\n'); j >= 0 {
+		return i + j
+	}
+	return end
+}
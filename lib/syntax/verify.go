@@ -0,0 +1,129 @@
+package syntax
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/parser"
+	"go.uber.org/multierr"
+)
+
+// Verify formats a journal, parses the result again and reports the semantic
+// differences between the original and the formatted directives. Tools which
+// rewrite journals can use it to make sure that formatting is not
+// destructive.
+func Verify(text, path string) error {
+	before, err := parse(text, path)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := FormatFile(&b, before); err != nil {
+		return err
+	}
+	after, err := parse(b.String(), path)
+	if err != nil {
+		return fmt.Errorf("parsing the formatted journal: %w", err)
+	}
+	return Compare(before, after)
+}
+
+func parse(text, path string) (directives.File, error) {
+	p := parser.New(text, path)
+	if err := p.Advance(); err != nil {
+		return directives.File{}, err
+	}
+	return p.ParseFile()
+}
+
+// Compare reports the semantic differences between the directives of two
+// files. Directives are equivalent if their components have the same text,
+// regardless of whitespace and alignment.
+func Compare(before, after directives.File) error {
+	var errs error
+	for i := 0; i < max(len(before.Directives), len(after.Directives)); i++ {
+		switch {
+		case i >= len(after.Directives):
+			errs = multierr.Append(errs, directives.Error{
+				Message: "directive is missing",
+				Range:   before.Directives[i].Range,
+			})
+		case i >= len(before.Directives):
+			errs = multierr.Append(errs, directives.Error{
+				Message: "directive has been added",
+				Range:   after.Directives[i].Range,
+			})
+		case Canonical(before.Directives[i]) != Canonical(after.Directives[i]):
+			errs = multierr.Append(errs, directives.Error{
+				Message: fmt.Sprintf("directive has changed to:\n%s", after.Directives[i].Extract()),
+				Range:   before.Directives[i].Range,
+			})
+		}
+	}
+	return errs
+}
+
+// Canonical returns a representation of a directive which only depends on
+// the text of its components, and not on the whitespace between them.
+func Canonical(d directives.Directive) string {
+	var b strings.Builder
+	canonical(&b, reflect.ValueOf(d))
+	return b.String()
+}
+
+var rangeType = reflect.TypeOf(directives.Range{})
+
+func canonical(b *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == rangeType {
+			fmt.Fprintf(b, "%q", v.Interface().(directives.Range).Extract())
+			return
+		}
+		b.WriteString(v.Type().Name())
+		b.WriteByte('{')
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.Anonymous && f.Type == rangeType && !isLeaf(v.Type()) {
+				// The range of a composite includes the whitespace between
+				// its components.
+				continue
+			}
+			b.WriteString(f.Name)
+			b.WriteByte(':')
+			canonical(b, v.Field(i))
+			b.WriteByte(' ')
+		}
+		b.WriteByte('}')
+	case reflect.Slice:
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			canonical(b, v.Index(i))
+			b.WriteByte(' ')
+		}
+		b.WriteByte(']')
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		canonical(b, v.Elem())
+	default:
+		fmt.Fprint(b, v.Interface())
+	}
+}
+
+// isLeaf returns whether a struct consists of an embedded range and flags,
+// like an account or a commodity.
+func isLeaf(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type != rangeType && f.Type.Kind() != reflect.Bool {
+			return false
+		}
+	}
+	return true
+}
@@ -19,24 +19,44 @@ import (
 	"os"
 
 	"github.com/sboehler/knut/cmd"
+	"github.com/sboehler/knut/cmd/daemon"
 
 	// enable importers here
+	_ "github.com/sboehler/knut/cmd/importer/coinbase"
+	_ "github.com/sboehler/knut/cmd/importer/comdirect"
 	_ "github.com/sboehler/knut/cmd/importer/cumulus"
+	_ "github.com/sboehler/knut/cmd/importer/dkb"
+	_ "github.com/sboehler/knut/cmd/importer/external"
+	_ "github.com/sboehler/knut/cmd/importer/ing"
 	_ "github.com/sboehler/knut/cmd/importer/interactivebrokers"
+	_ "github.com/sboehler/knut/cmd/importer/kraken"
+	_ "github.com/sboehler/knut/cmd/importer/migrosbank"
+	_ "github.com/sboehler/knut/cmd/importer/monzo"
+	_ "github.com/sboehler/knut/cmd/importer/n26"
+	_ "github.com/sboehler/knut/cmd/importer/payslip"
 	_ "github.com/sboehler/knut/cmd/importer/postfinance"
+	_ "github.com/sboehler/knut/cmd/importer/raiffeisen"
 	_ "github.com/sboehler/knut/cmd/importer/revolut"
 	_ "github.com/sboehler/knut/cmd/importer/revolut2"
+	_ "github.com/sboehler/knut/cmd/importer/revolutbusiness"
 	_ "github.com/sboehler/knut/cmd/importer/supercard"
 	_ "github.com/sboehler/knut/cmd/importer/swisscard"
 	_ "github.com/sboehler/knut/cmd/importer/swisscard2"
 	_ "github.com/sboehler/knut/cmd/importer/swissquote"
+	_ "github.com/sboehler/knut/cmd/importer/timetracking"
+	_ "github.com/sboehler/knut/cmd/importer/ubs"
 	_ "github.com/sboehler/knut/cmd/importer/viac"
+	_ "github.com/sboehler/knut/cmd/importer/viseca"
 	_ "github.com/sboehler/knut/cmd/importer/wise"
+	_ "github.com/sboehler/knut/cmd/importer/zkb"
 )
 
 var version = "development"
 
 func main() {
+	if code, ok := daemon.Forward(os.Args[1:], os.Stdout, os.Stderr); ok {
+		os.Exit(code)
+	}
 	c := cmd.CreateCmd(version)
 	if err := c.Execute(); err != nil {
 		fmt.Fprintln(c.ErrOrStderr(), err)